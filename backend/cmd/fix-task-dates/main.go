@@ -17,7 +17,9 @@ func main() {
 	log.Println("开始修复任务日期字段...")
 
 	// 加载配置
-	config.LoadConfig()
+	if err := config.LoadConfig(""); err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
 
 	// 连接数据库
 	var dialector gorm.Dialector
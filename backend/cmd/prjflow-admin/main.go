@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// auditUsername 审计日志中记录的操作者
+const auditUsername = "prjflow-admin"
+
+// app 命令执行环境
+type app struct {
+	configPath string
+	json       bool
+	stdin      io.Reader
+	stdout     io.Writer
+	db         *gorm.DB
+}
+
+// command 子命令
+type command struct {
+	name  string // 如 "user create"
+	usage string
+	run   func(a *app, args []string) error
+}
+
+var commands = []command{
+	{"user list", "列出用户", userList},
+	{"user create", "创建用户：-username -nickname -email -roles admin,developer -password | -password-stdin", userCreate},
+	{"user passwd", "重置密码：-user <用户名或ID> -password | -password-stdin", userPasswd},
+	{"user roles", "修改角色：-user <用户名或ID> -set|-add|-remove admin,developer", userRoles},
+	{"user enable", "启用用户：-user <用户名或ID>", userEnable},
+	{"user disable", "禁用用户：-user <用户名或ID>", userDisable},
+	{"role list", "列出角色", roleList},
+	{"perm list", "列出权限：[-role <角色代码>]", permList},
+	{"config list", "列出支持的系统配置及当前值：[-show-secrets]", configList},
+	{"config get", "读取系统配置：<key> [-show-secrets]", configGet},
+	{"config set", "修改系统配置：<key> <value>", configSet},
+	{"migrate", "执行数据库迁移（主数据库、默认权限和角色、审计数据库）", migrate},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "用法: prjflow-admin [-config config.yaml] [-json] <命令> [参数]\n\n命令:\n")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.usage)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n全局参数:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\n示例:\n")
+	fmt.Fprintf(out, "  prjflow-admin user passwd -user admin -password 'NewPass123'\n")
+	fmt.Fprintf(out, "  echo 'NewPass123' | prjflow-admin user create -username ops -roles admin -password-stdin\n")
+	fmt.Fprintf(out, "  prjflow-admin -json config get backup_time\n")
+	fmt.Fprintf(out, "\n日志级别和自动备份配置修改后需要重启服务器生效\n")
+}
+
+func main() {
+	a := &app{stdin: os.Stdin, stdout: os.Stdout}
+	flag.StringVar(&a.configPath, "config", "", "配置文件路径（可选，默认为 config.yaml）")
+	flag.BoolVar(&a.json, "json", false, "以 JSON 格式输出（便于脚本处理）")
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, args, ok := findCommand(flag.Args())
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(a, args); err != nil {
+		if a.json {
+			json.NewEncoder(os.Stdout).Encode(map[string]string{"error": err.Error()})
+		} else {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// findCommand 按参数匹配子命令，返回子命令自己的参数
+func findCommand(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) < len(words) {
+			continue
+		}
+		matched := true
+		for i, word := range words {
+			if args[i] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+// open 加载配置并连接数据库
+func (a *app) open() error {
+	// GORM 和工具函数的日志输出到标准错误，只保留警告以上级别，避免干扰命令输出
+	utils.Logger = logrus.New()
+	utils.Logger.SetOutput(os.Stderr)
+	utils.Logger.SetLevel(logrus.WarnLevel)
+
+	if err := config.LoadConfig(a.configPath); err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+	db, err := utils.InitDB()
+	if err != nil {
+		return fmt.Errorf("初始化数据库失败: %w", err)
+	}
+	a.db = db
+	auditDB, err := utils.InitAuditDB()
+	if err != nil {
+		return fmt.Errorf("初始化审计数据库失败: %w", err)
+	}
+	utils.AuditDB = auditDB
+	return nil
+}
+
+// audit 记录审计日志（写入失败不影响命令结果）
+func (a *app) audit(actionType, resourceType string, resourceID uint, comment string) {
+	utils.RecordAuditLog(a.db, 0, auditUsername, actionType, resourceType, resourceID, nil, true, "", comment)
+}
+
+// print 输出结果：JSON 模式输出 data，否则调用 text 输出文本
+func (a *app) print(data interface{}, text func(w io.Writer)) error {
+	if a.json {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	}
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	text(w)
+	return w.Flush()
+}
+
+// newFlagSet 创建子命令的参数解析器
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// readPassword 读取密码：-password-stdin 时从标准输入读取一行
+func (a *app) readPassword(password string, fromStdin bool) (string, error) {
+	if fromStdin {
+		if password != "" {
+			return "", errors.New("-password 和 -password-stdin 不能同时使用")
+		}
+		line, err := bufio.NewReader(a.stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("读取密码失败: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if password == "" {
+		return "", errors.New("请通过 -password 或 -password-stdin 提供密码")
+	}
+	return password, nil
+}
+
+// splitList 解析逗号分隔的列表
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// userView 输出的用户信息
+type userView struct {
+	ID       uint     `json:"id"`
+	Username string   `json:"username"`
+	Nickname string   `json:"nickname"`
+	Email    string   `json:"email"`
+	Enabled  bool     `json:"enabled"`
+	Roles    []string `json:"roles"`
+}
+
+func newUserView(user *model.User) userView {
+	view := userView{
+		ID:       user.ID,
+		Username: user.Username,
+		Nickname: user.Nickname,
+		Email:    user.Email,
+		Enabled:  user.Status == 1,
+		Roles:    []string{},
+	}
+	for _, role := range user.Roles {
+		view.Roles = append(view.Roles, role.Code)
+	}
+	return view
+}
+
+func (a *app) printUser(user *model.User, message string) error {
+	view := newUserView(user)
+	return a.print(view, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: %s (ID %d, 角色: %s, 状态: %s)\n", message, view.Username, view.ID, strings.Join(view.Roles, ","), statusText(view.Enabled))
+	})
+}
+
+func statusText(enabled bool) string {
+	if enabled {
+		return "正常"
+	}
+	return "禁用"
+}
+
+func userList(a *app, args []string) error {
+	if err := newFlagSet("user list").Parse(args); err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	var users []model.User
+	if err := a.db.Preload("Roles").Order("id").Find(&users).Error; err != nil {
+		return err
+	}
+	views := make([]userView, 0, len(users))
+	for i := range users {
+		views = append(views, newUserView(&users[i]))
+	}
+	return a.print(views, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\t用户名\t昵称\t邮箱\t状态\t角色")
+		for _, v := range views {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", v.ID, v.Username, v.Nickname, v.Email, statusText(v.Enabled), strings.Join(v.Roles, ","))
+		}
+	})
+}
+
+func userCreate(a *app, args []string) error {
+	fs := newFlagSet("user create")
+	username := fs.String("username", "", "用户名（必填）")
+	nickname := fs.String("nickname", "", "昵称（默认与用户名相同）")
+	email := fs.String("email", "", "邮箱")
+	roles := fs.String("roles", "", "角色代码（逗号分隔）")
+	password := fs.String("password", "", "密码（至少6位，包含大小写字母和数字）")
+	passwordStdin := fs.Bool("password-stdin", false, "从标准输入读取密码")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pwd, err := a.readPassword(*password, *passwordStdin)
+	if err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	user, err := utils.AdminCreateUser(a.db, utils.AdminUserInput{
+		Username: *username,
+		Nickname: *nickname,
+		Email:    *email,
+		Password: pwd,
+		Roles:    splitList(*roles),
+	})
+	if err != nil {
+		return err
+	}
+	a.audit("create", "user", user.ID, "通过管理工具创建用户")
+	return a.printUser(user, "已创建用户")
+}
+
+func userPasswd(a *app, args []string) error {
+	fs := newFlagSet("user passwd")
+	ref := fs.String("user", "", "用户名或用户ID（必填）")
+	password := fs.String("password", "", "新密码（至少6位，包含大小写字母和数字）")
+	passwordStdin := fs.Bool("password-stdin", false, "从标准输入读取新密码")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	pwd, err := a.readPassword(*password, *passwordStdin)
+	if err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	user, err := utils.AdminResetPassword(a.db, *ref, pwd)
+	if err != nil {
+		return err
+	}
+	a.audit("update", "user", user.ID, "通过管理工具重置密码")
+	return a.printUser(user, "已重置密码")
+}
+
+func userRoles(a *app, args []string) error {
+	fs := newFlagSet("user roles")
+	ref := fs.String("user", "", "用户名或用户ID（必填）")
+	var mode, codes string
+	conflict := false
+	for _, m := range []struct{ name, usage string }{
+		{utils.RoleAssignSet, "替换为指定角色（逗号分隔，传空字符串清除所有角色）"},
+		{utils.RoleAssignAdd, "增加角色（逗号分隔）"},
+		{utils.RoleAssignRemove, "移除角色（逗号分隔）"},
+	} {
+		name := m.name
+		fs.Func(name, m.usage, func(value string) error {
+			conflict = conflict || mode != ""
+			mode, codes = name, value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if mode == "" {
+		return errors.New("请指定 -set、-add 或 -remove")
+	}
+	if conflict {
+		return errors.New("-set、-add、-remove 只能使用一个")
+	}
+
+	if err := a.open(); err != nil {
+		return err
+	}
+	user, err := utils.AdminAssignRoles(a.db, *ref, splitList(codes), mode)
+	if err != nil {
+		return err
+	}
+	a.audit("update", "user", user.ID, fmt.Sprintf("通过管理工具修改角色（%s: %s）", mode, codes))
+	return a.printUser(user, "已修改角色")
+}
+
+func userEnable(a *app, args []string) error {
+	return setUserStatus(a, "user enable", args, true)
+}
+
+func userDisable(a *app, args []string) error {
+	return setUserStatus(a, "user disable", args, false)
+}
+
+func setUserStatus(a *app, name string, args []string, enabled bool) error {
+	fs := newFlagSet(name)
+	ref := fs.String("user", "", "用户名或用户ID（必填）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	user, err := utils.AdminSetUserStatus(a.db, *ref, enabled)
+	if err != nil {
+		return err
+	}
+	action := "禁用"
+	if enabled {
+		action = "启用"
+	}
+	a.audit("update", "user", user.ID, "通过管理工具"+action+"用户")
+	return a.printUser(user, "已"+action+"用户")
+}
+
+func roleList(a *app, args []string) error {
+	if err := newFlagSet("role list").Parse(args); err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	var roles []model.Role
+	if err := a.db.Order("id").Find(&roles).Error; err != nil {
+		return err
+	}
+	return a.print(roles, func(w io.Writer) {
+		fmt.Fprintln(w, "ID\t代码\t名称\t状态\t描述")
+		for _, role := range roles {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", role.ID, role.Code, role.Name, statusText(role.Status == 1), role.Description)
+		}
+	})
+}
+
+func permList(a *app, args []string) error {
+	fs := newFlagSet("perm list")
+	role := fs.String("role", "", "只列出该角色的权限（角色代码）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	permissions, err := utils.ListPermissions(a.db, *role)
+	if err != nil {
+		return err
+	}
+	return a.print(permissions, func(w io.Writer) {
+		fmt.Fprintln(w, "代码\t名称\t资源\t操作")
+		for _, p := range permissions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Code, p.Name, p.Resource, p.Action)
+		}
+	})
+}
+
+// configView 输出的系统配置
+type configView struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Set         bool   `json:"set"` // 是否已设置
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// loadConfigView 读取配置项，敏感配置的值不显示（除非 showSecrets）
+func (a *app) loadConfigView(item utils.SystemConfigKey, showSecrets bool) (configView, error) {
+	value, set, err := utils.GetSystemConfig(a.db, item.Key)
+	if err != nil {
+		return configView{}, err
+	}
+	if item.Secret && set && value != "" && !showSecrets {
+		value = "******"
+	}
+	return configView{Key: item.Key, Value: value, Set: set, Type: item.Type, Description: item.Description}, nil
+}
+
+func configList(a *app, args []string) error {
+	fs := newFlagSet("config list")
+	showSecrets := fs.Bool("show-secrets", false, "显示敏感配置的值")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	var views []configView
+	for _, item := range utils.SystemConfigKeys() {
+		view, err := a.loadConfigView(item, *showSecrets)
+		if err != nil {
+			return err
+		}
+		views = append(views, view)
+	}
+	return a.print(views, func(w io.Writer) {
+		fmt.Fprintln(w, "配置项\t值\t说明")
+		for _, v := range views {
+			value := v.Value
+			if !v.Set {
+				value = "(未设置)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", v.Key, value, v.Description)
+		}
+	})
+}
+
+func configGet(a *app, args []string) error {
+	fs := newFlagSet("config get")
+	showSecrets := fs.Bool("show-secrets", false, "显示敏感配置的值")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("用法: config get <key>")
+	}
+	item, ok := utils.LookupSystemConfigKey(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("不支持的配置项: %s（使用 config list 查看支持的配置项）", fs.Arg(0))
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	view, err := a.loadConfigView(item, *showSecrets)
+	if err != nil {
+		return err
+	}
+	return a.print(view, func(w io.Writer) {
+		fmt.Fprintln(w, view.Value)
+	})
+}
+
+func configSet(a *app, args []string) error {
+	fs := newFlagSet("config set")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("用法: config set <key> <value>")
+	}
+	key, value := fs.Arg(0), fs.Arg(1)
+	if err := a.open(); err != nil {
+		return err
+	}
+	if err := utils.SetSystemConfig(a.db, key, value); err != nil {
+		return err
+	}
+	a.audit("update", "system_config", 0, "通过管理工具修改系统配置: "+key)
+	item, _ := utils.LookupSystemConfigKey(key)
+	view, err := a.loadConfigView(item, false)
+	if err != nil {
+		return err
+	}
+	return a.print(view, func(w io.Writer) {
+		fmt.Fprintf(w, "已修改 %s = %s\n", view.Key, view.Value)
+		if key == "log_level" || strings.HasPrefix(key, "backup_") {
+			fmt.Fprintln(w, "该配置在服务器重启后生效")
+		}
+	})
+}
+
+func migrate(a *app, args []string) error {
+	if err := newFlagSet("migrate").Parse(args); err != nil {
+		return err
+	}
+	if err := a.open(); err != nil {
+		return err
+	}
+	if err := utils.AutoMigrate(a.db); err != nil {
+		return fmt.Errorf("迁移数据库失败: %w", err)
+	}
+	if err := utils.MigrateAuditDB(a.db, utils.AuditDB); err != nil {
+		return fmt.Errorf("迁移审计数据库失败: %w", err)
+	}
+	return a.print(map[string]interface{}{"migrated": true, "models": len(utils.Models())}, func(w io.Writer) {
+		fmt.Fprintf(w, "数据库迁移完成（%d 个模型）\n", len(utils.Models()))
+	})
+}
@@ -24,6 +24,7 @@ import (
 	"prjflow/internal/api"
 	"prjflow/internal/config"
 	"prjflow/internal/middleware"
+	"prjflow/internal/model"
 	"prjflow/internal/utils"
 	"prjflow/internal/websocket"
 
@@ -529,6 +530,168 @@ func stopServerCommand() error {
 	return nil
 }
 
+// resolveBackupFile 备份文件参数可以是备份目录中的文件名或文件路径
+func resolveBackupFile(file string) (string, error) {
+	if _, err := os.Stat(file); err == nil || strings.ContainsAny(file, `/\`) {
+		return file, nil
+	}
+	// 备份目录中的备份，保存在对象存储中时先下载到本地
+	return utils.FetchBackup(file)
+}
+
+// listBackupsCommand 列出备份目录中的备份
+func listBackupsCommand() error {
+	if err := config.LoadConfig(""); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	backupDir := utils.BackupDir()
+	backups, err := utils.ListStoredBackups()
+	if err != nil {
+		return err
+	}
+	if config.AppConfig.Storage.Type == "s3" {
+		fmt.Printf("Backup storage: s3://%s\n", config.AppConfig.Storage.S3.Bucket)
+	} else {
+		fmt.Printf("Backup directory: %s\n", backupDir)
+	}
+	for _, backup := range backups {
+		detail := "legacy backup without manifest"
+		if config.AppConfig.Storage.Type == "s3" {
+			detail = "stored in object storage"
+		}
+		if backup.Manifest != nil {
+			detail = fmt.Sprintf("%s, %d upload files", backup.Manifest.DatabaseType, backup.Manifest.UploadFiles)
+		}
+		fmt.Printf("  %s  %s  %s  (%s)\n", backup.Name, backup.CreatedAt.Format("2006-01-02 15:04:05"), formatSize(backup.Size), detail)
+	}
+	if pending := utils.GetPendingRestore(backupDir); pending != nil {
+		fmt.Printf("Pending restore: %s (requested by %s at %s)\n", pending.Name, pending.RequestedBy, pending.RequestedAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// verifyBackupCommand 校验备份文件
+func verifyBackupCommand(file string) error {
+	if err := config.LoadConfig(""); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	path, err := resolveBackupFile(file)
+	if err != nil {
+		return err
+	}
+	manifest, err := utils.VerifyBackup(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Backup verified: %s database, %d files, %d upload files", manifest.DatabaseType, len(manifest.Files), manifest.UploadFiles)
+	return nil
+}
+
+// restoreCommand 离线恢复备份（服务器运行时拒绝执行）
+func restoreCommand(file string) error {
+	if err := config.LoadConfig(""); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if pid, err := findProcessByPort(config.AppConfig.Server.Port); err == nil {
+		return fmt.Errorf("server is running (PID: %d), stop it first with --stop", pid)
+	}
+	path, err := resolveBackupFile(file)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restoring backup %s...", path)
+	manifest, err := utils.RestoreBackup(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Backup restored: %s database, %d upload files", manifest.DatabaseType, manifest.UploadFiles)
+	return nil
+}
+
+// migrateStorageCommand 在本地存储和对象存储之间复制附件和备份，完成后需修改配置中的 storage.type
+func migrateStorageCommand(direction string, deleteSource bool) error {
+	from, to, ok := strings.Cut(direction, ":")
+	if !ok || (from != "local" && from != "s3") || (to != "local" && to != "s3") || from == to {
+		return fmt.Errorf("invalid direction %q, expected local:s3 or s3:local", direction)
+	}
+	if err := config.LoadConfig(""); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	db, err := utils.InitDB()
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	log.Printf("Migrating attachments and backups from %s to %s...", from, to)
+	result, err := utils.MigrateStorage(db, from, to, deleteSource)
+	if result != nil {
+		log.Printf("Copied %d attachments and %d backups (%s), skipped %d existing, %d attachment files missing",
+			result.Attachments, result.Backups, formatSize(result.Bytes), result.Skipped, result.Missing)
+	}
+	if err != nil {
+		return err
+	}
+	if config.AppConfig.Storage.Type != to {
+		log.Printf("Set storage.type to %q in config.yaml and restart the server to use the new storage", to)
+	}
+	return nil
+}
+
+// doctorCommand 检查数据一致性，repair 时在一个事务中修复可以自动修复的问题（dryRun 时回滚）
+// 返回仍需处理的问题数
+func doctorCommand(checks string, repair, dryRun bool) (int, error) {
+	if err := config.LoadConfig(""); err != nil {
+		return 0, fmt.Errorf("failed to load config: %w", err)
+	}
+	db, err := utils.InitDB()
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	opts := utils.DoctorOptions{Repair: repair, DryRun: dryRun}
+	if checks != "" {
+		opts.Checks = strings.Split(checks, ",")
+	}
+	report, err := utils.RunDoctor(db, opts)
+	if report != nil {
+		printDoctorReport(report)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return report.Unresolved(), nil
+}
+
+// printDoctorReport 输出检查报告
+func printDoctorReport(report *utils.DoctorReport) {
+	for _, result := range report.Results {
+		status := "OK"
+		if result.Error != "" {
+			status = "ERROR: " + result.Error
+		} else if len(result.Issues) > 0 {
+			status = fmt.Sprintf("%d issues", len(result.Issues))
+		}
+		fmt.Printf("[%s] %s: %s\n", result.Name, result.Description, status)
+		for _, issue := range result.Issues {
+			mark := "manual"
+			if issue.Fixable {
+				mark = "fixable"
+			}
+			fmt.Printf("  - %s: %s (%s)\n", issue.Object, issue.Message, mark)
+		}
+	}
+
+	fmt.Printf("Found %d issues, %d can be fixed automatically\n", report.Issues, report.Fixable)
+	switch {
+	case report.DryRun:
+		fmt.Printf("Dry run: %d issues would be fixed and %d files deleted, no changes were made\n", report.Fixed, report.DeletedFiles)
+	case report.Repaired:
+		fmt.Printf("Repaired %d issues, deleted %d files\n", report.Fixed, report.DeletedFiles)
+	case report.Fixable > 0:
+		fmt.Println("Run with --doctor --repair to fix them (add --dry-run to preview the changes)")
+	}
+}
+
 // restartServer 重启服务器
 func restartServer() error {
 	// 加载配置以获取端口
@@ -576,12 +739,21 @@ func restartServer() error {
 func main() {
 	// 定义命令行参数
 	var (
-		backup    = flag.Bool("backup", false, "备份数据库")
-		stop      = flag.Bool("stop", false, "停止服务器")
-		restart   = flag.Bool("restart", false, "重启服务器")
-		version   = flag.Bool("version", false, "显示版本信息")
-		versionV  = flag.Bool("v", false, "显示版本信息（简写）")
-		versionV2 = flag.Bool("V", false, "显示版本信息（简写）")
+		backup       = flag.Bool("backup", false, "备份数据库、审计数据库和上传文件")
+		listBackups  = flag.Bool("list-backups", false, "列出备份")
+		verifyBackup = flag.String("verify-backup", "", "校验备份文件（文件名或路径）")
+		restore      = flag.String("restore", "", "从备份恢复（文件名或路径，需先停止服务器）")
+		migrate      = flag.String("migrate-storage", "", "在存储之间复制附件和备份（local:s3 或 s3:local）")
+		deleteSource = flag.Bool("delete-source", false, "与 --migrate-storage 一起使用：复制成功后删除源文件")
+		doctor       = flag.Bool("doctor", false, "检查数据一致性（工时、进度、成员、关联记录、孤立附件和文件）")
+		checks       = flag.String("checks", "", "与 --doctor 一起使用：只执行指定的检查（逗号分隔）")
+		repair       = flag.Bool("repair", false, "与 --doctor 一起使用：在一个事务中修复可以自动修复的问题")
+		dryRun       = flag.Bool("dry-run", false, "与 --doctor --repair 一起使用：执行修复后回滚，只显示将要修复的内容")
+		stop         = flag.Bool("stop", false, "停止服务器")
+		restart      = flag.Bool("restart", false, "重启服务器")
+		version      = flag.Bool("version", false, "显示版本信息")
+		versionV     = flag.Bool("v", false, "显示版本信息（简写）")
+		versionV2    = flag.Bool("V", false, "显示版本信息（简写）")
 	)
 
 	// 自定义 Usage
@@ -593,6 +765,10 @@ func main() {
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\n示例:\n")
 		fmt.Fprintf(os.Stderr, "  %s --backup      # 备份数据库\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --list-backups                          # 列出备份\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --restore backup_20060102_150405.zip    # 从备份恢复\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --migrate-storage local:s3              # 将附件和备份迁移到对象存储\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --doctor --repair --dry-run             # 检查数据一致性并预览修复\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --stop        # 停止服务器\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --restart     # 重启服务器\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s --version     # 显示版本信息\n", os.Args[0])
@@ -613,6 +789,12 @@ func main() {
 		if err != nil {
 			log.Fatalf("Failed to initialize database: %v", err)
 		}
+		// 初始化审计日志数据库（独立的审计数据库一并备份）
+		auditDB, err := utils.InitAuditDB()
+		if err != nil {
+			log.Fatalf("Failed to initialize audit database: %v", err)
+		}
+		utils.AuditDB = auditDB
 		// 执行备份
 		if err := utils.BackupDatabase(db); err != nil {
 			log.Fatalf("Backup failed: %v", err)
@@ -621,6 +803,45 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *listBackups {
+		if err := listBackupsCommand(); err != nil {
+			log.Fatalf("List backups failed: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	if *verifyBackup != "" {
+		if err := verifyBackupCommand(*verifyBackup); err != nil {
+			log.Fatalf("Backup verification failed: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	if *restore != "" {
+		if err := restoreCommand(*restore); err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	if *migrate != "" {
+		if err := migrateStorageCommand(*migrate, *deleteSource); err != nil {
+			log.Fatalf("Storage migration failed: %v", err)
+		}
+		os.Exit(0)
+	}
+
+	if *doctor {
+		unresolved, err := doctorCommand(*checks, *repair, *dryRun)
+		if err != nil {
+			log.Fatalf("Doctor failed: %v", err)
+		}
+		if unresolved > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if *stop {
 		if err := stopServerCommand(); err != nil {
 			log.Fatalf("Stop failed: %v", err)
@@ -655,6 +876,13 @@ func main() {
 	// 设置Gin模式
 	gin.SetMode(config.AppConfig.Server.Mode)
 
+	// 执行管理员暂存的备份恢复（必须在打开数据库之前）
+	if result, err := utils.ApplyPendingRestore(); err != nil {
+		log.Printf("Warning: Failed to restore backup %s: %v", result.Name, err)
+	} else if result != nil {
+		log.Printf("Backup %s restored successfully", result.Name)
+	}
+
 	// 初始化数据库（先初始化数据库，因为日志级别配置存储在数据库中）
 	db, err := utils.InitDB()
 	if err != nil {
@@ -671,6 +899,15 @@ func main() {
 		gin.DefaultErrorWriter = utils.Logger.WriterLevel(logrus.ErrorLevel)
 	}
 
+	// 检查附件存储配置
+	if err := utils.CheckStorage(); err != nil {
+		if utils.Logger != nil {
+			utils.Logger.Warnf("Storage check failed: %v", err)
+		} else {
+			log.Printf("Warning: Storage check failed: %v", err)
+		}
+	}
+
 	// 自动迁移数据库
 	if err := utils.AutoMigrate(db); err != nil {
 		if utils.Logger != nil {
@@ -801,6 +1038,14 @@ func main() {
 		permGroup.GET("/roles/:id/permissions", permHandler.GetRolePermissions)
 		permGroup.POST("/roles/:id/permissions", middleware.RequirePermission(db, "permission:manage"), permHandler.AssignRolePermissions)
 
+		// 项目角色管理（项目成员的角色对应项目角色，决定成员在项目内的权限）
+		permGroup.GET("/project-roles", permHandler.GetProjectRoles)
+		permGroup.GET("/project-roles/:id", permHandler.GetProjectRole)
+		permGroup.POST("/project-roles", middleware.RequirePermission(db, "permission:manage"), permHandler.CreateProjectRole)
+		permGroup.PUT("/project-roles/:id", middleware.RequirePermission(db, "permission:manage"), permHandler.UpdateProjectRole)
+		permGroup.DELETE("/project-roles/:id", middleware.RequirePermission(db, "permission:manage"), permHandler.DeleteProjectRole)
+		permGroup.POST("/project-roles/:id/permissions", middleware.RequirePermission(db, "permission:manage"), permHandler.AssignProjectRolePermissions)
+
 		// 权限管理
 		permGroup.GET("/permissions", permHandler.GetPermissions)
 		permGroup.GET("/permissions/:id", permHandler.GetPermission)
@@ -856,6 +1101,16 @@ func main() {
 		dashboardGroup.GET("", dashboardHandler.GetDashboard)
 		dashboardGroup.GET("/config", dashboardHandler.GetDashboardConfig)
 		dashboardGroup.POST("/config", dashboardHandler.SaveDashboardConfig)
+		dashboardGroup.GET("/widgets", dashboardHandler.GetDashboardWidgets)             // 可用组件列表
+		dashboardGroup.POST("/widgets/preview", dashboardHandler.PreviewDashboardWidget) // 计算单个组件
+	}
+	sharedDashboardGroup := r.Group("/api/dashboards", middleware.Auth())
+	{
+		sharedDashboardGroup.GET("", dashboardHandler.GetSharedDashboards)
+		sharedDashboardGroup.POST("", dashboardHandler.CreateSharedDashboard)
+		sharedDashboardGroup.GET("/:id", dashboardHandler.GetSharedDashboard)
+		sharedDashboardGroup.PUT("/:id", dashboardHandler.UpdateSharedDashboard)
+		sharedDashboardGroup.DELETE("/:id", dashboardHandler.DeleteSharedDashboard)
 	}
 
 	// 标签管理路由（标签是系统资源，使用项目权限）
@@ -875,45 +1130,107 @@ func main() {
 	// 看板管理路由（需要在项目路由之前定义，因为项目路由中会用到）
 	boardHandler := api.NewBoardHandler(db)
 
+	// 项目模板路由（克隆和另存为模板挂在项目路由下）
+	projectTemplateHandler := api.NewProjectTemplateHandler(db)
+	// 项目数据包导入导出
+	projectBundleHandler := api.NewProjectBundleHandler(db)
+
+	// 项目API令牌（供CI等非交互式客户端使用）
+	apiTokenHandler := api.NewAPITokenHandler(db)
+
 	projectGroup := r.Group("/api/projects", middleware.Auth())
 	{
 		projectGroup.GET("", middleware.RequirePermission(db, "project:read"), projectHandler.GetProjects)
+		projectGroup.POST("/import", middleware.RequirePermission(db, "project:create"), projectBundleHandler.ImportProject)
 		// 注意：统计接口、看板接口和甘特图接口需要在详情接口之前，避免路由冲突
-		projectGroup.GET("/:id/statistics", middleware.RequirePermission(db, "project:read"), projectHandler.GetProjectStatistics)
-		projectGroup.GET("/:id/progress", middleware.RequirePermission(db, "project:read"), projectHandler.GetProjectProgress)
-		projectGroup.GET("/:id/gantt", middleware.RequirePermission(db, "project:read"), projectHandler.GetProjectGantt)
+		projectGroup.GET("/:id/statistics", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProjectStatistics)
+		projectGroup.GET("/:id/progress", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProjectProgress)
+		projectGroup.GET("/:id/flow", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProjectFlow)
+		projectGroup.GET("/:id/gantt", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProjectGantt)
+		projectGroup.GET("/:id/storage", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProjectStorage)
+		projectGroup.PUT("/:id/storage-quota", middleware.RequirePermission(db, "system:settings"), projectHandler.UpdateProjectStorageQuota)
 		// 项目看板路由（需要在详情路由之前）
-		projectGroup.GET("/:id/boards", middleware.RequirePermission(db, "project:read"), boardHandler.GetProjectBoards)
-		projectGroup.POST("/:id/boards", middleware.RequirePermission(db, "project:manage"), boardHandler.CreateBoard)
-		projectGroup.GET("/:id", middleware.RequirePermission(db, "project:read"), projectHandler.GetProject)
+		projectGroup.GET("/:id/boards", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), boardHandler.GetProjectBoards)
+		projectGroup.POST("/:id/boards", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), boardHandler.CreateBoard)
+		projectGroup.GET("/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProject)
 		projectGroup.POST("", middleware.RequirePermission(db, "project:create"), projectHandler.CreateProject)
-		projectGroup.PUT("/:id", middleware.RequirePermission(db, "project:update"), projectHandler.UpdateProject)
-		projectGroup.DELETE("/:id", middleware.RequirePermission(db, "project:delete"), projectHandler.DeleteProject)
+		projectGroup.PUT("/:id", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromParam("id")), projectHandler.UpdateProject)
+		projectGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "project:delete", utils.ProjectFromParam("id")), projectHandler.DeleteProject)
 		// 项目历史记录
-		projectGroup.GET("/:id/history", middleware.RequirePermission(db, "project:read"), projectHandler.GetProjectHistory)
-		projectGroup.POST("/:id/history/note", middleware.RequirePermission(db, "project:update"), projectHandler.AddProjectHistoryNote)
+		projectGroup.GET("/:id/history", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProjectHistory)
+		projectGroup.POST("/:id/history/note", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromParam("id")), projectHandler.AddProjectHistoryNote)
 		// 项目成员管理
-		projectGroup.GET("/:id/members", middleware.RequirePermission(db, "project:read"), projectHandler.GetProjectMembers)
-		projectGroup.POST("/:id/members", middleware.RequirePermission(db, "project:manage"), projectHandler.AddProjectMembers)
-		projectGroup.PUT("/:id/members/:member_id", middleware.RequirePermission(db, "project:manage"), projectHandler.UpdateProjectMember)
-		projectGroup.DELETE("/:id/members/:member_id", middleware.RequirePermission(db, "project:manage"), projectHandler.RemoveProjectMember)
+		projectGroup.GET("/:id/permissions", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), permHandler.GetProjectUserPermissions)
+		projectGroup.GET("/:id/members", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectHandler.GetProjectMembers)
+		projectGroup.POST("/:id/members", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), projectHandler.AddProjectMembers)
+		projectGroup.PUT("/:id/members/:member_id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), projectHandler.UpdateProjectMember)
+		projectGroup.DELETE("/:id/members/:member_id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), projectHandler.RemoveProjectMember)
+		// 项目克隆与另存为模板
+		projectGroup.POST("/:id/clone", middleware.RequirePermission(db, "project:create"), projectTemplateHandler.CloneProject)
+		projectGroup.POST("/:id/save-as-template", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), projectTemplateHandler.SaveProjectAsTemplate)
+		// 项目数据包导出
+		projectGroup.GET("/:id/export", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), projectBundleHandler.ExportProject)
+		// 项目API令牌
+		projectGroup.GET("/:id/api-tokens", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), apiTokenHandler.GetProjectAPITokens)
+		projectGroup.POST("/:id/api-tokens", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), apiTokenHandler.CreateProjectAPIToken)
+		projectGroup.DELETE("/:id/api-tokens/:token_id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), apiTokenHandler.DeleteProjectAPIToken)
+	}
+
+	projectTemplateGroup := r.Group("/api/project-templates", middleware.Auth())
+	{
+		projectTemplateGroup.GET("", middleware.RequirePermission(db, "project:read"), projectTemplateHandler.GetProjectTemplates)
+		projectTemplateGroup.GET("/:id", middleware.RequirePermission(db, "project:read"), projectTemplateHandler.GetProjectTemplate)
+		projectTemplateGroup.POST("", middleware.RequirePermission(db, "project:manage"), projectTemplateHandler.CreateProjectTemplate)
+		projectTemplateGroup.PUT("/:id", middleware.RequirePermission(db, "project:manage"), projectTemplateHandler.UpdateProjectTemplate)
+		projectTemplateGroup.DELETE("/:id", middleware.RequirePermission(db, "project:manage"), projectTemplateHandler.DeleteProjectTemplate)
+		projectTemplateGroup.POST("/:id/projects", middleware.RequirePermission(db, "project:create"), projectTemplateHandler.CreateProjectFromTemplate)
+	}
+
+	// 项目集（产品线）管理路由
+	programHandler := api.NewProgramHandler(db)
+	programGroup := r.Group("/api/programs", middleware.Auth())
+	{
+		programGroup.GET("", middleware.RequirePermission(db, "program:read"), programHandler.GetPrograms)
+		programGroup.GET("/:id", middleware.RequirePermission(db, "program:read"), programHandler.GetProgram)
+		programGroup.GET("/:id/statistics", middleware.RequirePermission(db, "program:read"), programHandler.GetProgramStatistics)
+		programGroup.GET("/:id/portfolio", middleware.RequirePermission(db, "program:read"), programHandler.GetProgramPortfolio)
+		programGroup.POST("", middleware.RequirePermission(db, "program:create"), programHandler.CreateProgram)
+		programGroup.PUT("/:id", middleware.RequirePermission(db, "program:update"), programHandler.UpdateProgram)
+		programGroup.DELETE("/:id", middleware.RequirePermission(db, "program:delete"), programHandler.DeleteProgram)
+		// 项目集内的项目
+		programGroup.POST("/:id/projects", middleware.RequirePermission(db, "program:update"), programHandler.AddProgramProjects)
+		programGroup.DELETE("/:id/projects/:project_id", middleware.RequirePermission(db, "program:update"), programHandler.RemoveProgramProject)
+		// 项目集成员
+		programGroup.GET("/:id/members", middleware.RequirePermission(db, "program:read"), programHandler.GetProgramMembers)
+		programGroup.POST("/:id/members", middleware.RequirePermission(db, "program:update"), programHandler.AddProgramMembers)
+		programGroup.PUT("/:id/members/:member_id", middleware.RequirePermission(db, "program:update"), programHandler.UpdateProgramMember)
+		programGroup.DELETE("/:id/members/:member_id", middleware.RequirePermission(db, "program:update"), programHandler.RemoveProgramMember)
+		// 项目集级需求
+		programGroup.GET("/:id/requirements", middleware.RequirePermission(db, "program:read"), programHandler.GetProgramRequirements)
+		programGroup.PUT("/:id/requirements/:requirement_id", middleware.RequirePermission(db, "program:update"), programHandler.SetProgramRequirement)
+		programGroup.DELETE("/:id/requirements/:requirement_id", middleware.RequirePermission(db, "program:update"), programHandler.RemoveProgramRequirement)
 	}
 
 	// 需求管理路由
 	requirementHandler := api.NewRequirementHandler(db)
+	// 受限对象访问控制（保密Bug、受限需求和任务）
+	itemAccessHandler := api.NewItemAccessHandler(db)
+
 	requirementGroup := r.Group("/api/requirements", middleware.Auth())
 	{
 		requirementGroup.GET("/statistics", middleware.RequirePermission(db, "requirement:read"), requirementHandler.GetRequirementStatistics)
 		requirementGroup.GET("", middleware.RequirePermission(db, "requirement:read"), requirementHandler.GetRequirements)
-		requirementGroup.GET("/:id", middleware.RequirePermission(db, "requirement:read"), requirementHandler.GetRequirement)
-		requirementGroup.POST("", middleware.RequirePermission(db, "requirement:create"), requirementHandler.CreateRequirement)
-		requirementGroup.PUT("/:id", middleware.RequirePermission(db, "requirement:update"), requirementHandler.UpdateRequirement)
-		requirementGroup.DELETE("/:id", middleware.RequirePermission(db, "requirement:delete"), requirementHandler.DeleteRequirement)
-		requirementGroup.PATCH("/:id/status", middleware.RequirePermission(db, "requirement:update"), requirementHandler.UpdateRequirementStatus)
-		requirementGroup.POST("/:id/assign", middleware.RequirePermission(db, "requirement:update"), requirementHandler.AssignRequirement)
+		requirementGroup.GET("/:id", middleware.RequireProjectPermission(db, "requirement:read", utils.ProjectFromObjectParam("requirements", "id")), requirementHandler.GetRequirement)
+		requirementGroup.POST("", middleware.RequireProjectPermission(db, "requirement:create", utils.ProjectFromBody("project_id")), requirementHandler.CreateRequirement)
+		requirementGroup.PUT("/:id", middleware.RequireProjectPermission(db, "requirement:update", utils.ProjectFromObjectParam("requirements", "id")), requirementHandler.UpdateRequirement)
+		requirementGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "requirement:delete", utils.ProjectFromObjectParam("requirements", "id")), requirementHandler.DeleteRequirement)
+		requirementGroup.PATCH("/:id/status", middleware.RequireProjectPermission(db, "requirement:update", utils.ProjectFromObjectParam("requirements", "id")), requirementHandler.UpdateRequirementStatus)
+		requirementGroup.POST("/:id/assign", middleware.RequireProjectPermission(db, "requirement:update", utils.ProjectFromObjectParam("requirements", "id")), requirementHandler.AssignRequirement)
 		// 需求历史记录
-		requirementGroup.GET("/:id/history", middleware.RequirePermission(db, "requirement:read"), requirementHandler.GetRequirementHistory)
-		requirementGroup.POST("/:id/history/note", middleware.RequirePermission(db, "requirement:update"), requirementHandler.AddRequirementHistoryNote)
+		requirementGroup.GET("/:id/access", middleware.RequireProjectPermission(db, "requirement:read", utils.ProjectFromObjectParam("requirements", "id")), itemAccessHandler.GetRequirementAccess)
+		requirementGroup.PUT("/:id/access", middleware.RequireProjectPermission(db, "requirement:update", utils.ProjectFromObjectParam("requirements", "id")), itemAccessHandler.UpdateRequirementAccess)
+		requirementGroup.GET("/:id/history", middleware.RequireProjectPermission(db, "requirement:read", utils.ProjectFromObjectParam("requirements", "id")), requirementHandler.GetRequirementHistory)
+		requirementGroup.POST("/:id/history/note", middleware.RequireProjectPermission(db, "requirement:update", utils.ProjectFromObjectParam("requirements", "id")), requirementHandler.AddRequirementHistoryNote)
 	}
 
 	// 功能模块管理路由（模块是系统资源，使用项目权限）
@@ -937,15 +1254,17 @@ func main() {
 		bugGroup.POST("/column-settings", middleware.RequirePermission(db, "bug:read"), bugHandler.SaveBugColumnSettings)
 		bugGroup.GET("", middleware.RequirePermission(db, "bug:read"), bugHandler.GetBugs)
 		// 历史记录路由（必须在 /:id 之前）
-		bugGroup.GET("/:id/history", middleware.RequirePermission(db, "bug:read"), bugHandler.GetBugHistory)
-		bugGroup.POST("/:id/history/note", middleware.RequirePermission(db, "bug:update"), bugHandler.AddBugHistoryNote)
-		bugGroup.GET("/:id", middleware.RequirePermission(db, "bug:read"), bugHandler.GetBug)
-		bugGroup.POST("", middleware.RequirePermission(db, "bug:create"), bugHandler.CreateBug)
-		bugGroup.PUT("/:id", middleware.RequirePermission(db, "bug:update"), bugHandler.UpdateBug)
-		bugGroup.DELETE("/:id", middleware.RequirePermission(db, "bug:delete"), bugHandler.DeleteBug)
-		bugGroup.PATCH("/:id/status", middleware.RequirePermission(db, "bug:update"), bugHandler.UpdateBugStatus)
-		bugGroup.POST("/:id/assign", middleware.RequirePermission(db, "bug:assign"), bugHandler.AssignBug)
-		bugGroup.POST("/:id/confirm", middleware.RequirePermission(db, "bug:update"), bugHandler.ConfirmBug)
+		bugGroup.GET("/:id/access", middleware.RequireProjectPermission(db, "bug:read", utils.ProjectFromObjectParam("bugs", "id")), itemAccessHandler.GetBugAccess)
+		bugGroup.PUT("/:id/access", middleware.RequireProjectPermission(db, "bug:update", utils.ProjectFromObjectParam("bugs", "id")), itemAccessHandler.UpdateBugAccess)
+		bugGroup.GET("/:id/history", middleware.RequireProjectPermission(db, "bug:read", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.GetBugHistory)
+		bugGroup.POST("/:id/history/note", middleware.RequireProjectPermission(db, "bug:update", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.AddBugHistoryNote)
+		bugGroup.GET("/:id", middleware.RequireProjectPermission(db, "bug:read", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.GetBug)
+		bugGroup.POST("", middleware.RequireProjectPermission(db, "bug:create", utils.ProjectFromBody("project_id")), bugHandler.CreateBug)
+		bugGroup.PUT("/:id", middleware.RequireProjectPermission(db, "bug:update", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.UpdateBug)
+		bugGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "bug:delete", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.DeleteBug)
+		bugGroup.PATCH("/:id/status", middleware.RequireProjectPermission(db, "bug:update", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.UpdateBugStatus)
+		bugGroup.POST("/:id/assign", middleware.RequireProjectPermission(db, "bug:assign", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.AssignBug)
+		bugGroup.POST("/:id/confirm", middleware.RequireProjectPermission(db, "bug:update", utils.ProjectFromObjectParam("bugs", "id")), bugHandler.ConfirmBug)
 	}
 
 	// 任务管理路由
@@ -953,29 +1272,39 @@ func main() {
 	taskGroup := r.Group("/api/tasks", middleware.Auth())
 	{
 		taskGroup.GET("", middleware.RequirePermission(db, "task:read"), taskHandler.GetTasks)
-		taskGroup.GET("/:id", middleware.RequirePermission(db, "task:read"), taskHandler.GetTask)
-		taskGroup.POST("", middleware.RequirePermission(db, "task:create"), taskHandler.CreateTask)
-		taskGroup.PUT("/:id", middleware.RequirePermission(db, "task:update"), taskHandler.UpdateTask)
-		taskGroup.DELETE("/:id", middleware.RequirePermission(db, "task:delete"), taskHandler.DeleteTask)
-		taskGroup.PATCH("/:id/status", middleware.RequirePermission(db, "task:update"), taskHandler.UpdateTaskStatus)
-		taskGroup.POST("/:id/assign", middleware.RequirePermission(db, "task:update"), taskHandler.AssignTask)
+		taskGroup.GET("/:id", middleware.RequireProjectPermission(db, "task:read", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.GetTask)
+		taskGroup.POST("", middleware.RequireProjectPermission(db, "task:create", utils.ProjectFromBody("project_id")), taskHandler.CreateTask)
+		taskGroup.PUT("/:id", middleware.RequireProjectPermission(db, "task:update", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.UpdateTask)
+		taskGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "task:delete", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.DeleteTask)
+		taskGroup.PATCH("/:id/status", middleware.RequireProjectPermission(db, "task:update", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.UpdateTaskStatus)
+		taskGroup.POST("/:id/assign", middleware.RequireProjectPermission(db, "task:update", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.AssignTask)
 		// 任务历史记录
-		taskGroup.GET("/:id/history", middleware.RequirePermission(db, "task:read"), taskHandler.GetTaskHistory)
-		taskGroup.POST("/:id/history/note", middleware.RequirePermission(db, "task:update"), taskHandler.AddTaskHistoryNote)
-		taskGroup.PATCH("/:id/progress", middleware.RequirePermission(db, "task:update"), taskHandler.UpdateTaskProgress)
+		taskGroup.GET("/:id/access", middleware.RequireProjectPermission(db, "task:read", utils.ProjectFromObjectParam("tasks", "id")), itemAccessHandler.GetTaskAccess)
+		taskGroup.PUT("/:id/access", middleware.RequireProjectPermission(db, "task:update", utils.ProjectFromObjectParam("tasks", "id")), itemAccessHandler.UpdateTaskAccess)
+		taskGroup.GET("/:id/history", middleware.RequireProjectPermission(db, "task:read", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.GetTaskHistory)
+		taskGroup.POST("/:id/history/note", middleware.RequireProjectPermission(db, "task:update", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.AddTaskHistoryNote)
+		taskGroup.PATCH("/:id/progress", middleware.RequireProjectPermission(db, "task:update", utils.ProjectFromObjectParam("tasks", "id")), taskHandler.UpdateTaskProgress)
 	}
 
 	// 看板管理路由（看板属于项目的一部分）
 	boardGroup := r.Group("/api/boards", middleware.Auth())
 	{
-		boardGroup.GET("/:id", middleware.RequirePermission(db, "project:read"), boardHandler.GetBoard)
-		boardGroup.PUT("/:id", middleware.RequirePermission(db, "project:manage"), boardHandler.UpdateBoard)
-		boardGroup.DELETE("/:id", middleware.RequirePermission(db, "project:manage"), boardHandler.DeleteBoard)
-		boardGroup.GET("/:id/tasks", middleware.RequirePermission(db, "project:read"), boardHandler.GetBoardTasks)
-		boardGroup.PATCH("/:id/tasks/:task_id/move", middleware.RequirePermission(db, "project:manage"), boardHandler.MoveTask)
-		boardGroup.POST("/:id/columns", middleware.RequirePermission(db, "project:manage"), boardHandler.CreateBoardColumn)
-		boardGroup.PUT("/:id/columns/:column_id", middleware.RequirePermission(db, "project:manage"), boardHandler.UpdateBoardColumn)
-		boardGroup.DELETE("/:id/columns/:column_id", middleware.RequirePermission(db, "project:manage"), boardHandler.DeleteBoardColumn)
+		boardGroup.GET("/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("boards", "id")), boardHandler.GetBoard)
+		boardGroup.PUT("/:id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("boards", "id")), boardHandler.UpdateBoard)
+		boardGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("boards", "id")), boardHandler.DeleteBoard)
+		boardGroup.GET("/:id/tasks", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("boards", "id")), boardHandler.GetBoardTasks)
+		boardGroup.PATCH("/:id/tasks/:task_id/move", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("boards", "id")), boardHandler.MoveTask)
+		boardGroup.PATCH("/:id/cards/:type/:card_id/move", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("boards", "id")), boardHandler.MoveCard)
+		boardGroup.POST("/:id/columns", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("boards", "id")), boardHandler.CreateBoardColumn)
+		boardGroup.PUT("/:id/columns/:column_id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("boards", "id")), boardHandler.UpdateBoardColumn)
+		boardGroup.DELETE("/:id/columns/:column_id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("boards", "id")), boardHandler.DeleteBoardColumn)
+	}
+
+	// 看板实时订阅路由（WebSocket，浏览器通过查询参数 token 传递认证Token）
+	liveGroup := r.Group("/ws", middleware.TokenFromQuery(), middleware.Auth())
+	{
+		liveGroup.GET("/boards/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("boards", "id")), boardHandler.SubscribeBoard)
+		liveGroup.GET("/projects/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), boardHandler.SubscribeProject)
 	}
 
 	// 版本管理路由（版本属于项目的一部分）
@@ -983,13 +1312,49 @@ func main() {
 	versionGroup := r.Group("/api/versions", middleware.Auth())
 	{
 		versionGroup.GET("", middleware.RequirePermission(db, "project:read"), versionHandler.GetVersions)
-		versionGroup.GET("/:id", middleware.RequirePermission(db, "project:read"), versionHandler.GetVersion)
-		versionGroup.POST("", middleware.RequirePermission(db, "project:update"), versionHandler.CreateVersion)
-		versionGroup.PUT("/:id", middleware.RequirePermission(db, "project:update"), versionHandler.UpdateVersion)
-		versionGroup.DELETE("/:id", middleware.RequirePermission(db, "project:delete"), versionHandler.DeleteVersion)
-		versionGroup.PATCH("/:id/status", middleware.RequirePermission(db, "project:update"), versionHandler.UpdateVersionStatus)
-		versionGroup.POST("/:id/release", middleware.RequirePermission(db, "project:update"), versionHandler.ReleaseVersion)
+		versionGroup.GET("/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("versions", "id")), versionHandler.GetVersion)
+		versionGroup.POST("", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromBody("project_id")), versionHandler.CreateVersion)
+		versionGroup.PUT("/:id", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromObjectParam("versions", "id")), versionHandler.UpdateVersion)
+		versionGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "project:delete", utils.ProjectFromObjectParam("versions", "id")), versionHandler.DeleteVersion)
+		versionGroup.PATCH("/:id/status", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromObjectParam("versions", "id")), versionHandler.UpdateVersionStatus)
+		versionGroup.GET("/:id/release-notes", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("versions", "id")), versionHandler.GetReleaseNotes)
+		versionGroup.POST("/:id/release-notes", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromObjectParam("versions", "id")), versionHandler.GenerateReleaseNotes)
+		versionGroup.GET("/:id/release-notes/diff", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("versions", "id")), versionHandler.DiffReleaseNotes)
+		versionGroup.GET("/:id/gate-check", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("versions", "id")), versionHandler.CheckReleaseGate)
+		versionGroup.POST("/:id/release", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromObjectParam("versions", "id")), versionHandler.ReleaseVersion)
+	}
+	// 构建、部署环境和部署记录
+	deploymentHandler := api.NewDeploymentHandler(db)
+	versionGroup.GET("/:id/builds", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("versions", "id")), deploymentHandler.GetVersionBuilds)
+	versionGroup.POST("/:id/builds", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromObjectParam("versions", "id")), deploymentHandler.CreateBuild)
+	buildGroup := r.Group("/api/builds", middleware.Auth())
+	{
+		buildGroup.GET("/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("builds", "id")), deploymentHandler.GetBuild)
+		buildGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromObjectParam("builds", "id")), deploymentHandler.DeleteBuild)
 	}
+	projectGroup.GET("/:id/environments", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), deploymentHandler.GetEnvironments)
+	projectGroup.POST("/:id/environments", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), deploymentHandler.CreateEnvironment)
+	projectGroup.GET("/:id/deployments", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), deploymentHandler.GetDeployments)
+	environmentGroup := r.Group("/api/environments", middleware.Auth())
+	{
+		environmentGroup.PUT("/:id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("environments", "id")), deploymentHandler.UpdateEnvironment)
+		environmentGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromObjectParam("environments", "id")), deploymentHandler.DeleteEnvironment)
+		environmentGroup.POST("/:id/deployments", middleware.RequireProjectPermission(db, "project:update", utils.ProjectFromObjectParam("environments", "id")), deploymentHandler.CreateDeployment)
+	}
+	bugGroup.GET("/:id/environments", middleware.RequireProjectPermission(db, "bug:read", utils.ProjectFromObjectParam("bugs", "id")), deploymentHandler.GetBugEnvironments)
+
+	// Git Webhook（提交和合并请求关联到工作项）
+	gitWebhookHandler := api.NewGitWebhookHandler(db)
+	projectGroup.GET("/:id/webhooks", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), gitWebhookHandler.GetProjectWebhooks)
+	projectGroup.POST("/:id/webhooks", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), gitWebhookHandler.CreateProjectWebhook)
+	projectGroup.DELETE("/:id/webhooks/:webhook_id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), gitWebhookHandler.DeleteProjectWebhook)
+	projectGroup.GET("/:id/code-links", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), gitWebhookHandler.GetCodeLinks)
+	r.POST("/api/webhooks/git/:id", gitWebhookHandler.ReceiveGitWebhook)
+
+	// 项目变更日志和发布门禁配置
+	projectGroup.GET("/:id/changelog", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), versionHandler.GetProjectChangelog)
+	projectGroup.GET("/:id/release-gate", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromParam("id")), versionHandler.GetReleaseGate)
+	projectGroup.PUT("/:id/release-gate", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), versionHandler.UpdateReleaseGate)
 
 	// 测试单管理路由（测试用例属于项目的一部分）
 	testCaseHandler := api.NewTestCaseHandler(db)
@@ -997,11 +1362,39 @@ func main() {
 	{
 		testCaseGroup.GET("/statistics", middleware.RequirePermission(db, "project:read"), testCaseHandler.GetTestCaseStatistics)
 		testCaseGroup.GET("", middleware.RequirePermission(db, "project:read"), testCaseHandler.GetTestCases)
-		testCaseGroup.GET("/:id", middleware.RequirePermission(db, "project:read"), testCaseHandler.GetTestCase)
-		testCaseGroup.POST("", middleware.RequirePermission(db, "test-case:create"), testCaseHandler.CreateTestCase)
-		testCaseGroup.PUT("/:id", middleware.RequirePermission(db, "test-case:update"), testCaseHandler.UpdateTestCase)
-		testCaseGroup.DELETE("/:id", middleware.RequirePermission(db, "test-case:delete"), testCaseHandler.DeleteTestCase)
-		testCaseGroup.PATCH("/:id/status", middleware.RequirePermission(db, "test-case:update"), testCaseHandler.UpdateTestCaseStatus)
+		testCaseGroup.GET("/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("test_cases", "id")), testCaseHandler.GetTestCase)
+		testCaseGroup.POST("", middleware.RequireProjectPermission(db, "test-case:create", utils.ProjectFromBody("project_id")), testCaseHandler.CreateTestCase)
+		testCaseGroup.PUT("/:id", middleware.RequireProjectPermission(db, "test-case:update", utils.ProjectFromObjectParam("test_cases", "id")), testCaseHandler.UpdateTestCase)
+		testCaseGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "test-case:delete", utils.ProjectFromObjectParam("test_cases", "id")), testCaseHandler.DeleteTestCase)
+		testCaseGroup.PATCH("/:id/status", middleware.RequireProjectPermission(db, "test-case:update", utils.ProjectFromObjectParam("test_cases", "id")), testCaseHandler.UpdateTestCaseStatus)
+	}
+
+	// 测试套件和测试执行路由
+	testRunHandler := api.NewTestRunHandler(db)
+	testSuiteGroup := r.Group("/api/test-suites", middleware.Auth())
+	{
+		testSuiteGroup.GET("", middleware.RequirePermission(db, "project:read"), testRunHandler.GetTestSuites)
+		testSuiteGroup.GET("/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("test_suites", "id")), testRunHandler.GetTestSuite)
+		testSuiteGroup.POST("", middleware.RequireProjectPermission(db, "test-case:create", utils.ProjectFromBody("project_id")), testRunHandler.CreateTestSuite)
+		testSuiteGroup.PUT("/:id", middleware.RequireProjectPermission(db, "test-case:update", utils.ProjectFromObjectParam("test_suites", "id")), testRunHandler.UpdateTestSuite)
+		testSuiteGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "test-case:delete", utils.ProjectFromObjectParam("test_suites", "id")), testRunHandler.DeleteTestSuite)
+	}
+	testRunGroup := r.Group("/api/test-runs", middleware.Auth())
+	{
+		testRunGroup.GET("", middleware.RequirePermission(db, "project:read"), testRunHandler.GetTestRuns)
+		testRunGroup.GET("/:id", middleware.RequireProjectPermission(db, "project:read", utils.ProjectFromObjectParam("test_runs", "id")), testRunHandler.GetTestRun)
+		testRunGroup.POST("", middleware.RequireProjectPermission(db, "test-case:create", utils.ProjectFromBody("project_id")), testRunHandler.CreateTestRun)
+		testRunGroup.DELETE("/:id", middleware.RequireProjectPermission(db, "test-case:delete", utils.ProjectFromObjectParam("test_runs", "id")), testRunHandler.DeleteTestRun)
+		testRunGroup.POST("/:id/cases/:case_id/results", middleware.RequireProjectPermission(db, "test-case:update", utils.ProjectFromObjectParam("test_runs", "id")), testRunHandler.RecordTestResult)
+		testRunGroup.POST("/:id/cases/:case_id/bugs", middleware.RequireProjectPermission(db, "bug:create", utils.ProjectFromObjectParam("test_runs", "id")), testRunHandler.CreateBugFromStep)
+	}
+
+	// CI开放接口（使用项目API令牌认证）
+	ciGroup := r.Group("/api/ci")
+	{
+		ciGroup.POST("/test-results", middleware.APITokenAuth(db, model.APITokenScopeTestResults), testRunHandler.UploadTestReport)
+		ciGroup.POST("/builds", middleware.APITokenAuth(db, model.APITokenScopeDeployments), deploymentHandler.CIRegisterBuild)
+		ciGroup.POST("/deployments", middleware.APITokenAuth(db, model.APITokenScopeDeployments), deploymentHandler.CIRecordDeployment)
 	}
 
 	// 资源管理路由 (统计、冲突检测、利用率分析)
@@ -1036,6 +1429,9 @@ func main() {
 	reportGroup := r.Group("/api/reports", middleware.Auth())
 	{
 		reportGroup.GET("/work-summary", reportHandler.GetWorkSummary) // 获取工作内容汇总
+		reportGroup.GET("/compliance", middleware.RequirePermission(db, "department:read"), reportHandler.GetReportCompliance) // 日报提交情况
+		reportGroup.GET("/rollup/departments/:id", middleware.RequirePermission(db, "department:read"), reportHandler.GetDepartmentRollup) // 部门工作汇总
+		reportGroup.GET("/rollup/projects/:id", middleware.RequireProjectPermission(db, "project:manage", utils.ProjectFromParam("id")), reportHandler.GetProjectRollup) // 项目工作汇总
 	}
 	dailyReportGroup := r.Group("/api/daily-reports", middleware.Auth())
 	{
@@ -1057,6 +1453,26 @@ func main() {
 		weeklyReportGroup.PATCH("/:id/status", reportHandler.UpdateWeeklyReportStatus)
 		weeklyReportGroup.POST("/:id/approve", reportHandler.ApproveWeeklyReport)
 	}
+	// 站内通知
+	notificationHandler := api.NewNotificationHandler(db)
+	notificationGroup := r.Group("/api/notifications", middleware.Auth())
+	{
+		notificationGroup.GET("", notificationHandler.GetNotifications)
+		notificationGroup.PUT("/read-all", notificationHandler.MarkAllNotificationsRead)
+		notificationGroup.PUT("/:id/read", notificationHandler.MarkNotificationRead)
+	}
+	// 报告审批：待我审批、审批委托和审批链配置
+	reportApprovalGroup := r.Group("/api/report-approvals", middleware.Auth())
+	{
+		reportApprovalGroup.GET("/pending", reportHandler.GetPendingApprovals)
+		reportApprovalGroup.GET("/delegations", reportHandler.GetApprovalDelegations)
+		reportApprovalGroup.POST("/delegations", reportHandler.CreateApprovalDelegation)
+		reportApprovalGroup.DELETE("/delegations/:id", reportHandler.DeleteApprovalDelegation)
+		reportApprovalGroup.GET("/chains", reportHandler.GetApprovalChains)
+		reportApprovalGroup.POST("/chains", middleware.RequirePermission(db, "department:update"), reportHandler.CreateApprovalChain)
+		reportApprovalGroup.PUT("/chains/:id", middleware.RequirePermission(db, "department:update"), reportHandler.UpdateApprovalChain)
+		reportApprovalGroup.DELETE("/chains/:id", middleware.RequirePermission(db, "department:update"), reportHandler.DeleteApprovalChain)
+	}
 
 	// 附件管理路由
 	attachmentHandler := api.NewAttachmentHandler(db)
@@ -1064,11 +1480,15 @@ func main() {
 	{
 		attachmentGroup.POST("/upload", middleware.RequirePermission(db, "attachment:upload"), attachmentHandler.UploadFile)
 		attachmentGroup.GET("/:id", attachmentHandler.GetAttachment)
+		attachmentGroup.GET("/:id/download-url", attachmentHandler.GetDownloadURL)
 		attachmentGroup.GET("/:id/download", attachmentHandler.DownloadFile)
 		attachmentGroup.GET("/:id/preview", attachmentHandler.PreviewFile)
 		attachmentGroup.DELETE("/:id", middleware.RequirePermission(db, "attachment:delete"), attachmentHandler.DeleteAttachment)
 		attachmentGroup.GET("", attachmentHandler.GetAttachments)
 		attachmentGroup.POST("/:id/attach", attachmentHandler.AttachToEntity)
+		attachmentGroup.GET("/:id/versions", attachmentHandler.GetAttachmentVersions)
+		attachmentGroup.POST("/:id/versions", middleware.RequirePermission(db, "attachment:upload"), attachmentHandler.UploadAttachmentVersion)
+		attachmentGroup.GET("/:id/versions/:version/download", attachmentHandler.DownloadAttachmentVersion)
 	}
 
 	// 静态文件服务（上传的文件）
@@ -1097,6 +1517,16 @@ func main() {
 		systemGroup.GET("/backup-config", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.GetBackupConfig)
 		systemGroup.POST("/backup-config", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.SaveBackupConfig)
 		systemGroup.POST("/backup/trigger", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.TriggerBackup)
+		// 项目指标快照回填
+		systemGroup.POST("/metrics/backfill", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.BackfillMetrics)
+		// 报告自动化（自动生成草稿和未提交提醒）
+		systemGroup.GET("/report-automation", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.GetReportAutomationConfig)
+		systemGroup.POST("/report-automation", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.SaveReportAutomationConfig)
+		systemGroup.POST("/report-automation/run", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.RunReportAutomation)
+		// 审计日志保留和归档
+		systemGroup.GET("/audit-retention", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.GetAuditRetention)
+		systemGroup.POST("/audit-retention", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.SaveAuditRetention)
+		systemGroup.POST("/audit-retention/archive", middleware.RequirePermissionOptional(db, "system:settings"), systemHandler.ArchiveAuditLogs)
 		// 日志管理路由
 		systemGroup.GET("/log-level", systemHandler.GetLogLevel)
 		systemGroup.POST("/log-level", middleware.RequirePermissionOptional(db, "log:settings"), systemHandler.SetLogLevel)
@@ -1104,11 +1534,24 @@ func main() {
 		systemGroup.GET("/log-files/:filename", middleware.RequirePermissionOptional(db, "log:settings"), systemHandler.DownloadLogFile)
 	}
 
+	// 备份文件管理路由（下载、删除、恢复）：备份包含完整数据库，系统未初始化时也必须登录并有系统设置权限
+	backupGroup := r.Group("/api/system", middleware.Auth(), middleware.RequirePermission(db, "system:settings"))
+	{
+		backupGroup.DELETE("/backup/restore", systemHandler.CancelRestore)
+		backupGroup.GET("/backups", systemHandler.GetBackups)
+		backupGroup.GET("/backups/:name/download", systemHandler.DownloadBackup)
+		backupGroup.POST("/backups/:name/verify", systemHandler.VerifyBackup)
+		backupGroup.POST("/backups/:name/restore", systemHandler.StageRestore)
+		backupGroup.DELETE("/backups/:name", systemHandler.DeleteBackup)
+	}
+
 	// 审计日志路由
 	auditLogHandler := api.NewAuditLogHandler(db)
 	auditLogGroup := r.Group("/api/audit-logs", middleware.Auth())
 	{
 		auditLogGroup.GET("", middleware.RequirePermission(db, "audit:read"), auditLogHandler.GetAuditLogs)
+		auditLogGroup.GET("/export", middleware.RequirePermission(db, "audit:read"), auditLogHandler.ExportAuditLogs)
+		auditLogGroup.GET("/verify", middleware.RequirePermission(db, "audit:read"), auditLogHandler.VerifyAuditLogs)
 		auditLogGroup.GET("/:id", middleware.RequirePermission(db, "audit:read"), auditLogHandler.GetAuditLog)
 	}
 
@@ -1231,6 +1674,24 @@ func main() {
 		log.Println("Backup scheduler started")
 	}
 
+	// 启动项目指标快照定时任务
+	metricsScheduler := utils.GetMetricsScheduler(db)
+	metricsScheduler.Start()
+	if utils.Logger != nil {
+		utils.Logger.Info("Metrics scheduler started")
+	} else {
+		log.Println("Metrics scheduler started")
+	}
+
+	// 启动报告自动化定时任务
+	reportScheduler := utils.GetReportScheduler(db)
+	reportScheduler.Start()
+	if utils.Logger != nil {
+		utils.Logger.Info("Report scheduler started")
+	} else {
+		log.Println("Report scheduler started")
+	}
+
 	// 启动服务器（异步）
 	go func() {
 		if utils.Logger != nil {
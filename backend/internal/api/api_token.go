@@ -0,0 +1,124 @@
+package api
+
+import (
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 可以授予API令牌的权限范围
+var validAPITokenScopes = map[string]bool{
+	model.APITokenScopeTestResults: true,
+	model.APITokenScopeDeployments: true,
+}
+
+type APITokenHandler struct {
+	db *gorm.DB
+}
+
+func NewAPITokenHandler(db *gorm.DB) *APITokenHandler {
+	return &APITokenHandler{db: db}
+}
+
+// GetProjectAPITokens 获取项目的API令牌列表（不包含令牌明文）
+func (h *APITokenHandler) GetProjectAPITokens(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	var tokens []model.APIToken
+	h.db.Preload("Creator").Where("project_id = ?", project.ID).Order("created_at DESC").Find(&tokens)
+	utils.Success(c, tokens)
+}
+
+// CreateProjectAPIToken 创建项目API令牌，令牌明文只在创建时返回一次
+func (h *APITokenHandler) CreateProjectAPIToken(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	var req struct {
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes"`
+		ExpiresAt *string  `json:"expires_at"` // 过期日期（YYYY-MM-DD），为空表示不过期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{model.APITokenScopeTestResults}
+	}
+	for _, scope := range req.Scopes {
+		if !validAPITokenScopes[scope] {
+			utils.Error(c, 400, "无效的权限范围："+scope)
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		date, err := time.ParseInLocation("2006-01-02", *req.ExpiresAt, time.Local)
+		if err != nil {
+			utils.Error(c, 400, "过期日期格式错误，应为 YYYY-MM-DD")
+			return
+		}
+		end := date.AddDate(0, 0, 1).Add(-time.Second)
+		if end.Before(time.Now()) {
+			utils.Error(c, 400, "过期日期不能早于今天")
+			return
+		}
+		expiresAt = &end
+	}
+
+	token, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		utils.Error(c, utils.CodeError, "生成令牌失败")
+		return
+	}
+
+	apiToken := model.APIToken{
+		Name:      req.Name,
+		TokenHash: hash,
+		Prefix:    utils.APITokenDisplayPrefix(token),
+		Scopes:    model.StringArray(req.Scopes),
+		ProjectID: project.ID,
+		CreatorID: utils.GetUserID(c),
+		ExpiresAt: expiresAt,
+	}
+	if err := h.db.Create(&apiToken).Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建令牌失败")
+		return
+	}
+
+	utils.RecordAction(h.db, "project", project.ID, "api_token_created", apiToken.CreatorID, apiToken.Name, gin.H{"api_token_id": apiToken.ID})
+
+	utils.SuccessWithMessage(c, "令牌只显示一次，请妥善保存", gin.H{
+		"token":     token,
+		"api_token": apiToken,
+	})
+}
+
+// DeleteProjectAPIToken 删除（吊销）项目API令牌
+func (h *APITokenHandler) DeleteProjectAPIToken(c *gin.Context) {
+	var apiToken model.APIToken
+	if err := h.db.Where("id = ? AND project_id = ?", c.Param("token_id"), c.Param("id")).First(&apiToken).Error; err != nil {
+		utils.Error(c, 404, "令牌不存在")
+		return
+	}
+	if err := h.db.Delete(&apiToken).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+
+	utils.RecordAction(h.db, "project", apiToken.ProjectID, "api_token_revoked", utils.GetUserID(c), apiToken.Name, gin.H{"api_token_id": apiToken.ID})
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
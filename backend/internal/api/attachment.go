@@ -1,18 +1,21 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
-	"time"
 
 	"prjflow/internal/config"
 	"prjflow/internal/model"
 	"prjflow/internal/utils"
+	"prjflow/pkg/storage"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -50,28 +53,52 @@ func (h *AttachmentHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 获取项目ID（必须）
-	projectIDStr := c.PostForm("project_id")
-	if projectIDStr == "" {
-		utils.Error(c, 400, "项目ID不能为空")
-		return
-	}
-
-	var projectID uint
-	if _, err := fmt.Sscanf(projectIDStr, "%d", &projectID); err != nil {
-		utils.Error(c, 400, "无效的项目ID")
-		return
-	}
+	// 获取关联对象：项目ID，或者日报、周报ID（报告不属于项目，只有报告作者可以上传）
+	var project *model.Project
+	var report interface{}
+	reportAssociation := ""
+	if projectIDStr := c.PostForm("project_id"); projectIDStr != "" {
+		var projectID uint
+		if _, err := fmt.Sscanf(projectIDStr, "%d", &projectID); err != nil {
+			utils.Error(c, 400, "无效的项目ID")
+			return
+		}
 
-	// 验证项目是否存在并检查项目成员权限
-	var project model.Project
-	if err := h.db.First(&project, projectID).Error; err != nil {
-		utils.Error(c, 404, "项目不存在")
-		return
-	}
+		// 验证项目是否存在并检查项目成员权限
+		project = &model.Project{}
+		if err := h.db.First(project, projectID).Error; err != nil {
+			utils.Error(c, 404, "项目不存在")
+			return
+		}
 
-	if !utils.CheckProjectAccess(h.db, c, projectID) {
-		utils.Error(c, 403, "没有权限访问该项目")
+		if !utils.CheckProjectAccess(h.db, c, projectID) {
+			utils.Error(c, 403, "没有权限访问该项目")
+			return
+		}
+	} else if reportID := c.PostForm("daily_report_id"); reportID != "" {
+		var dailyReport model.DailyReport
+		if err := h.db.First(&dailyReport, reportID).Error; err != nil {
+			utils.Error(c, 404, "日报不存在")
+			return
+		}
+		if !utils.IsAdmin(c) && dailyReport.UserID != utils.GetUserID(c) {
+			utils.Error(c, 403, "只能为自己的日报上传附件")
+			return
+		}
+		report, reportAssociation = &dailyReport, "DailyReports"
+	} else if reportID := c.PostForm("weekly_report_id"); reportID != "" {
+		var weeklyReport model.WeeklyReport
+		if err := h.db.First(&weeklyReport, reportID).Error; err != nil {
+			utils.Error(c, 404, "周报不存在")
+			return
+		}
+		if !utils.IsAdmin(c) && weeklyReport.UserID != utils.GetUserID(c) {
+			utils.Error(c, 403, "只能为自己的周报上传附件")
+			return
+		}
+		report, reportAssociation = &weeklyReport, "WeeklyReports"
+	} else {
+		utils.Error(c, 400, "项目ID不能为空")
 		return
 	}
 
@@ -82,10 +109,30 @@ func (h *AttachmentHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
+	attachment, code, err := saveUploadedAttachment(h.db, c, file, project)
+	if err != nil {
+		utils.Error(c, code, err.Error())
+		return
+	}
+	if report != nil {
+		if err := h.db.Model(attachment).Association(reportAssociation).Append(report); err != nil {
+			h.deleteAttachment(attachment)
+			utils.Error(c, utils.CodeError, "关联附件到报告失败: "+err.Error())
+			return
+		}
+	}
+
+	// 预加载创建人信息
+	h.db.Preload("Creator").First(attachment, attachment.ID)
+
+	utils.Success(c, attachment)
+}
+
+// validateUploadedFile 校验上传文件的大小和类型，失败时返回错误码和错误信息
+func validateUploadedFile(file *multipart.FileHeader) (int, error) {
 	// 验证文件大小
 	if file.Size > config.AppConfig.Upload.MaxFileSize {
-		utils.Error(c, 400, fmt.Sprintf("文件大小超过限制（最大 %d MB）", config.AppConfig.Upload.MaxFileSize/(1024*1024)))
-		return
+		return 400, fmt.Errorf("文件大小超过限制（最大 %d MB）", config.AppConfig.Upload.MaxFileSize/(1024*1024))
 	}
 
 	// 验证文件类型（如果配置了允许的类型）
@@ -98,39 +145,44 @@ func (h *AttachmentHandler) UploadFile(c *gin.Context) {
 			}
 		}
 		if !allowed {
-			utils.Error(c, 400, "不支持的文件类型")
-			return
+			return 400, fmt.Errorf("不支持的文件类型")
 		}
 	}
+	return 0, nil
+}
 
-	// 生成文件存储路径（按日期组织：YYYY/MM/DD/）
-	now := time.Now()
-	datePath := now.Format("2006/01/02")
-
-	// 生成唯一文件名（UUID + 原始扩展名）
-	ext := filepath.Ext(file.Filename)
-	fileName := uuid.New().String() + ext
-
-	// 构建完整存储路径
-	storagePath := config.AppConfig.Upload.StoragePath
-	if !filepath.IsAbs(storagePath) {
-		// 相对路径：相对于程序运行目录
-		storagePath = filepath.Join(".", storagePath)
+// storeUploadedFile 保存上传文件的内容（相同内容只保存一份），返回的内容记录持有一次引用
+func storeUploadedFile(db *gorm.DB, file *multipart.FileHeader) (*model.AttachmentBlob, int, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, utils.CodeError, fmt.Errorf("读取上传文件失败: %w", err)
 	}
+	defer src.Close()
+	blob, _, err := utils.StoreAttachmentContent(db, src, file.Filename, file.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, utils.CodeError, err
+	}
+	return blob, 0, nil
+}
 
-	fullDir := filepath.Join(storagePath, datePath)
-	fullPath := filepath.Join(fullDir, fileName)
+// saveUploadedAttachment 校验并保存上传的文件，创建附件记录并关联到项目（project 为空时不关联项目）
+// 失败时返回错误码和错误信息，已保存的文件会被清理
+func saveUploadedAttachment(db *gorm.DB, c *gin.Context, file *multipart.FileHeader, project *model.Project) (*model.Attachment, int, error) {
+	if code, err := validateUploadedFile(file); err != nil {
+		return nil, code, err
+	}
 
-	// 创建目录
-	if err := os.MkdirAll(fullDir, 0755); err != nil {
-		utils.Error(c, utils.CodeError, "创建存储目录失败: "+err.Error())
-		return
+	// 检查项目存储配额
+	if project != nil {
+		if err := utils.CheckProjectStorageQuota(db, project, file.Size); err != nil {
+			return nil, 400, err
+		}
 	}
 
 	// 保存文件
-	if err := c.SaveUploadedFile(file, fullPath); err != nil {
-		utils.Error(c, utils.CodeError, "保存文件失败: "+err.Error())
-		return
+	blob, code, err := storeUploadedFile(db, file)
+	if err != nil {
+		return nil, code, err
 	}
 
 	// 获取MIME类型
@@ -142,33 +194,32 @@ func (h *AttachmentHandler) UploadFile(c *gin.Context) {
 	// 创建附件记录
 	userID := utils.GetUserID(c)
 	attachment := model.Attachment{
-		FileName:  file.Filename,
-		FilePath:  filepath.Join(datePath, fileName), // 存储相对路径
-		FileSize:  file.Size,
-		MimeType:  mimeType,
-		CreatorID: userID,
+		FileName:    file.Filename,
+		FilePath:    blob.FilePath, // 存储中的对象键（相对路径）
+		FileSize:    blob.FileSize,
+		MimeType:    mimeType,
+		ContentHash: blob.Hash,
+		Version:     1,
+		CreatorID:   userID,
 	}
 
-	if err := h.db.Create(&attachment).Error; err != nil {
-		// 如果创建失败，删除已上传的文件
-		os.Remove(fullPath)
-		utils.Error(c, utils.CodeError, "创建附件记录失败: "+err.Error())
-		return
+	if err := db.Create(&attachment).Error; err != nil {
+		// 如果创建失败，释放文件引用
+		utils.ReleaseAttachmentContent(db, blob.Hash, blob.FilePath)
+		return nil, utils.CodeError, fmt.Errorf("创建附件记录失败: %w", err)
 	}
 
 	// 关联到项目
-	if err := h.db.Model(&attachment).Association("Projects").Append(&project); err != nil {
-		// 如果关联失败，删除附件记录和文件
-		h.db.Delete(&attachment)
-		os.Remove(fullPath)
-		utils.Error(c, utils.CodeError, "关联附件到项目失败: "+err.Error())
-		return
+	if project != nil {
+		if err := db.Model(&attachment).Association("Projects").Append(project); err != nil {
+			// 如果关联失败，删除附件记录和文件
+			db.Delete(&attachment)
+			utils.ReleaseAttachmentContent(db, blob.Hash, blob.FilePath)
+			return nil, utils.CodeError, fmt.Errorf("关联附件到项目失败: %w", err)
+		}
 	}
 
-	// 预加载创建人信息
-	h.db.Preload("Creator").First(&attachment, attachment.ID)
-
-	utils.Success(c, attachment)
+	return &attachment, 0, nil
 }
 
 // GetAttachment 获取附件信息
@@ -182,26 +233,20 @@ func (h *AttachmentHandler) GetAttachment(c *gin.Context) {
 		return
 	}
 
-	// 检查权限：用户必须是附件关联的任意一个项目的成员
-	hasAccess := false
-
-	// 管理员可以访问所有附件
-	if utils.IsAdmin(c) {
-		hasAccess = true
-	} else {
-		// 检查用户是否是附件关联的任意一个项目的成员
-		for _, project := range attachment.Projects {
-			if utils.CheckProjectAccess(h.db, c, project.ID) {
-				hasAccess = true
-				break
-			}
-		}
+	// 检查权限：用户必须能访问附件关联的项目或报告
+	if !h.canAccessAttachment(c, &attachment) {
+		utils.Error(c, 403, "没有权限访问该附件")
+		return
 	}
 
-	if !hasAccess {
+	// 关联到受限对象的附件只对能查看该对象的用户可见，查看时记录审计日志
+	if !utils.CanViewAttachment(h.db, c, attachment.ID) {
 		utils.Error(c, 403, "没有权限访问该附件")
 		return
 	}
+	for _, object := range utils.RestrictedAttachmentObjects(h.db, attachment.ID) {
+		utils.RecordRestrictedView(h.db, c, object.ObjectType, object.ObjectID, "查看附件："+attachment.FileName)
+	}
 
 	utils.Success(c, attachment)
 }
@@ -217,47 +262,66 @@ func (h *AttachmentHandler) DownloadFile(c *gin.Context) {
 		return
 	}
 
-	// 检查权限：用户必须是附件关联的任意一个项目的成员
-	hasAccess := false
-
-	// 管理员可以访问所有附件
-	if utils.IsAdmin(c) {
-		hasAccess = true
-	} else {
-		// 检查用户是否是附件关联的任意一个项目的成员
-		for _, project := range attachment.Projects {
-			if utils.CheckProjectAccess(h.db, c, project.ID) {
-				hasAccess = true
-				break
-			}
-		}
+	// 检查权限：用户必须能访问附件关联的项目或报告
+	if !h.canAccessAttachment(c, &attachment) {
+		utils.Error(c, 403, "没有权限访问该附件")
+		return
 	}
 
-	if !hasAccess {
+	// 关联到受限对象的附件只对能查看该对象的用户可见，查看时记录审计日志
+	if !utils.CanViewAttachment(h.db, c, attachment.ID) {
 		utils.Error(c, 403, "没有权限访问该附件")
 		return
 	}
+	for _, object := range utils.RestrictedAttachmentObjects(h.db, attachment.ID) {
+		utils.RecordRestrictedView(h.db, c, object.ObjectType, object.ObjectID, "查看附件："+attachment.FileName)
+	}
+
+	serveAttachmentFile(c, attachment.FilePath, attachment.FileName, attachment.MimeType, "attachment")
+}
+
+// GetDownloadURL 获取附件的下载地址
+// 对象存储开启临时下载地址时返回带签名的存储服务地址（浏览器直接下载，不经过服务器），否则返回下载接口地址
+// 权限要求：登录 + 项目成员验证
+func (h *AttachmentHandler) GetDownloadURL(c *gin.Context) {
+	id := c.Param("id")
 
-	// 构建文件完整路径
-	storagePath := config.AppConfig.Upload.StoragePath
-	if !filepath.IsAbs(storagePath) {
-		storagePath = filepath.Join(".", storagePath)
+	var attachment model.Attachment
+	if err := h.db.Preload("Projects").First(&attachment, id).Error; err != nil {
+		utils.Error(c, 404, "附件不存在")
+		return
 	}
-	fullPath := filepath.Join(storagePath, attachment.FilePath)
 
-	// 检查文件是否存在
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		utils.Error(c, 404, "文件不存在")
+	// 检查权限：用户必须能访问附件关联的项目或报告
+	if !h.canAccessAttachment(c, &attachment) || !utils.CanViewAttachment(h.db, c, attachment.ID) {
+		utils.Error(c, 403, "没有权限访问该附件")
 		return
 	}
+	for _, object := range utils.RestrictedAttachmentObjects(h.db, attachment.ID) {
+		utils.RecordRestrictedView(h.db, c, object.ObjectType, object.ObjectID, "查看附件："+attachment.FileName)
+	}
 
-	// 设置响应头
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", attachment.FileName))
-	c.Header("Content-Type", attachment.MimeType)
-	c.File(fullPath)
+	dispositionType := "attachment"
+	if c.Query("inline") == "true" {
+		dispositionType = "inline"
+	}
+	store, err := utils.AttachmentStorage()
+	if err != nil {
+		utils.Error(c, utils.CodeError, "附件存储不可用: "+err.Error())
+		return
+	}
+	if presigned := utils.PresignDownloadURL(store, attachment.FilePath, attachmentDisposition(attachment.FileName, dispositionType)); presigned != "" {
+		utils.Success(c, gin.H{"url": presigned, "presigned": true, "expires_in": config.AppConfig.Storage.PresignExpire})
+		return
+	}
+	endpoint := "download"
+	if dispositionType == "inline" {
+		endpoint = "preview"
+	}
+	utils.Success(c, gin.H{"url": fmt.Sprintf("/api/attachments/%d/%s", attachment.ID, endpoint), "presigned": false})
 }
 
-// PreviewFile 预览文件（内联显示，不强制下载）
+// PreviewFile 预览文件（内联显示，不强制下载），thumbnail=true 时返回图片缩略图
 // 权限要求：登录 + 项目成员验证
 func (h *AttachmentHandler) PreviewFile(c *gin.Context) {
 	id := c.Param("id")
@@ -268,44 +332,32 @@ func (h *AttachmentHandler) PreviewFile(c *gin.Context) {
 		return
 	}
 
-	// 检查权限：用户必须是附件关联的任意一个项目的成员
-	hasAccess := false
-
-	// 管理员可以访问所有附件
-	if utils.IsAdmin(c) {
-		hasAccess = true
-	} else {
-		// 检查用户是否是附件关联的任意一个项目的成员
-		for _, project := range attachment.Projects {
-			if utils.CheckProjectAccess(h.db, c, project.ID) {
-				hasAccess = true
-				break
-			}
-		}
+	// 检查权限：用户必须能访问附件关联的项目或报告
+	if !h.canAccessAttachment(c, &attachment) {
+		utils.Error(c, 403, "没有权限访问该附件")
+		return
 	}
 
-	if !hasAccess {
+	// 关联到受限对象的附件只对能查看该对象的用户可见，查看时记录审计日志
+	if !utils.CanViewAttachment(h.db, c, attachment.ID) {
 		utils.Error(c, 403, "没有权限访问该附件")
 		return
 	}
-
-	// 构建文件完整路径
-	storagePath := config.AppConfig.Upload.StoragePath
-	if !filepath.IsAbs(storagePath) {
-		storagePath = filepath.Join(".", storagePath)
+	for _, object := range utils.RestrictedAttachmentObjects(h.db, attachment.ID) {
+		utils.RecordRestrictedView(h.db, c, object.ObjectType, object.ObjectID, "查看附件："+attachment.FileName)
 	}
-	fullPath := filepath.Join(storagePath, attachment.FilePath)
 
-	// 检查文件是否存在
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		utils.Error(c, 404, "文件不存在")
-		return
+	// 图片附件可以只获取缩略图（用于列表预览），没有缩略图时返回原文件
+	if c.Query("thumbnail") == "true" {
+		if store, err := utils.AttachmentStorage(); err == nil {
+			if key := utils.AttachmentThumbnail(h.db, store, attachment.ContentHash); key != "" {
+				serveAttachmentFile(c, key, attachment.FileName, utils.ThumbnailContentType(path.Ext(key)), "inline")
+				return
+			}
+		}
 	}
 
-	// 设置响应头（内联显示，不强制下载）
-	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", attachment.FileName))
-	c.Header("Content-Type", attachment.MimeType)
-	c.File(fullPath)
+	serveAttachmentFile(c, attachment.FilePath, attachment.FileName, attachment.MimeType, "inline")
 }
 
 // DeleteAttachment 删除附件
@@ -342,45 +394,17 @@ func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
 		return
 	}
 
-	// 检查权限：用户必须是附件关联的任意一个项目的成员
-	hasAccess := false
-
-	// 管理员可以删除所有附件
-	if utils.IsAdmin(c) {
-		hasAccess = true
-	} else {
-		// 检查用户是否是附件关联的任意一个项目的成员
-		for _, project := range attachment.Projects {
-			if utils.CheckProjectAccess(h.db, c, project.ID) {
-				hasAccess = true
-				break
-			}
-		}
-	}
-
-	if !hasAccess {
+	// 检查权限：用户必须能访问附件关联的项目或报告
+	if !h.canAccessAttachment(c, &attachment) {
 		utils.Error(c, 403, "没有权限删除该附件")
 		return
 	}
 
-	// 构建文件完整路径
-	storagePath := config.AppConfig.Upload.StoragePath
-	if !filepath.IsAbs(storagePath) {
-		storagePath = filepath.Join(".", storagePath)
-	}
-	fullPath := filepath.Join(storagePath, attachment.FilePath)
-
-	// 先删除附件记录（硬删除，彻底删除）
-	if err := h.db.Unscoped().Delete(&attachment).Error; err != nil {
+	if err := h.deleteAttachment(&attachment); err != nil {
 		utils.Error(c, utils.CodeError, "删除附件记录失败: "+err.Error())
 		return
 	}
 
-	// 删除文件（如果存在）
-	if _, err := os.Stat(fullPath); err == nil {
-		os.Remove(fullPath)
-	}
-
 	utils.Success(c, gin.H{"message": "删除成功"})
 }
 
@@ -389,7 +413,7 @@ func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
 func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
 	query := h.db.Model(&model.Attachment{}).Preload("Creator")
 
-	// 支持按项目、需求、任务、Bug过滤
+	// 支持按项目、需求、任务、Bug、版本、测试单、日报、周报过滤
 	if projectID := c.Query("project_id"); projectID != "" {
 		var pid uint
 		if _, err := fmt.Sscanf(projectID, "%d", &pid); err == nil {
@@ -460,6 +484,54 @@ func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
 		}
 	}
 
+	if testCaseID := c.Query("test_case_id"); testCaseID != "" {
+		var tcid uint
+		if _, err := fmt.Sscanf(testCaseID, "%d", &tcid); err == nil {
+			// 验证测试单访问权限（通过测试单的项目ID）
+			var testCase model.TestCase
+			if err := h.db.First(&testCase, tcid).Error; err != nil {
+				utils.Error(c, 404, "测试单不存在")
+				return
+			}
+			if !utils.CheckProjectAccess(h.db, c, testCase.ProjectID) {
+				utils.Error(c, 403, "没有权限访问该测试单")
+				return
+			}
+			query = query.Joins("JOIN test_case_attachments ON test_case_attachments.attachment_id = attachments.id").
+				Where("test_case_attachments.test_case_id = ?", tcid)
+		}
+	}
+
+	reportHandler := NewReportHandler(h.db)
+	if dailyReportID := c.Query("daily_report_id"); dailyReportID != "" {
+		var drid uint
+		if _, err := fmt.Sscanf(dailyReportID, "%d", &drid); err == nil {
+			// 验证日报查看权限（作者、审批人或管理员）
+			if !reportHandler.canViewReport(c, dailyReportApproval, drid) {
+				utils.Error(c, 403, "没有权限访问该日报")
+				return
+			}
+			query = query.Joins("JOIN daily_report_attachments ON daily_report_attachments.attachment_id = attachments.id").
+				Where("daily_report_attachments.daily_report_id = ?", drid)
+		}
+	}
+
+	if weeklyReportID := c.Query("weekly_report_id"); weeklyReportID != "" {
+		var wrid uint
+		if _, err := fmt.Sscanf(weeklyReportID, "%d", &wrid); err == nil {
+			// 验证周报查看权限（作者、审批人或管理员）
+			if !reportHandler.canViewReport(c, weeklyReportApproval, wrid) {
+				utils.Error(c, 403, "没有权限访问该周报")
+				return
+			}
+			query = query.Joins("JOIN weekly_report_attachments ON weekly_report_attachments.attachment_id = attachments.id").
+				Where("weekly_report_attachments.weekly_report_id = ?", wrid)
+		}
+	}
+
+	// 过滤关联到当前用户不可见的受限对象的附件
+	query = utils.FilterAttachmentsByRestriction(c, query)
+
 	var attachments []model.Attachment
 	if err := query.Find(&attachments).Error; err != nil {
 		utils.Error(c, utils.CodeError, "查询失败: "+err.Error())
@@ -469,7 +541,7 @@ func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
 	utils.Success(c, attachments)
 }
 
-// AttachToEntity 关联附件到实体（项目/需求/任务/Bug）
+// AttachToEntity 关联附件到实体（项目/需求/任务/Bug/版本/测试单/日报/周报）
 // 权限要求：登录 + 项目成员验证
 func (h *AttachmentHandler) AttachToEntity(c *gin.Context) {
 	attachmentID := c.Param("id")
@@ -481,11 +553,14 @@ func (h *AttachmentHandler) AttachToEntity(c *gin.Context) {
 	}
 
 	var req struct {
-		ProjectID     *uint `json:"project_id"`
-		RequirementID *uint `json:"requirement_id"`
-		TaskID        *uint `json:"task_id"`
-		BugID         *uint `json:"bug_id"`
-		VersionID     *uint `json:"version_id"`
+		ProjectID      *uint `json:"project_id"`
+		RequirementID  *uint `json:"requirement_id"`
+		TaskID         *uint `json:"task_id"`
+		BugID          *uint `json:"bug_id"`
+		VersionID      *uint `json:"version_id"`
+		TestCaseID     *uint `json:"test_case_id"`
+		DailyReportID  *uint `json:"daily_report_id"`
+		WeeklyReportID *uint `json:"weekly_report_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -493,20 +568,8 @@ func (h *AttachmentHandler) AttachToEntity(c *gin.Context) {
 		return
 	}
 
-	// 验证权限：用户必须是附件关联的任意一个项目的成员
-	hasAccess := false
-	if utils.IsAdmin(c) {
-		hasAccess = true
-	} else {
-		for _, project := range attachment.Projects {
-			if utils.CheckProjectAccess(h.db, c, project.ID) {
-				hasAccess = true
-				break
-			}
-		}
-	}
-
-	if !hasAccess {
+	// 验证权限：用户必须能访问附件关联的项目或报告
+	if !h.canAccessAttachment(c, &attachment) {
 		utils.Error(c, 403, "没有权限操作该附件")
 		return
 	}
@@ -522,6 +585,19 @@ func (h *AttachmentHandler) AttachToEntity(c *gin.Context) {
 			utils.Error(c, 404, "项目不存在")
 			return
 		}
+		alreadyLinked := false
+		for _, linked := range attachment.Projects {
+			if linked.ID == project.ID {
+				alreadyLinked = true
+				break
+			}
+		}
+		if !alreadyLinked {
+			if err := utils.CheckProjectStorageQuota(h.db, &project, attachment.FileSize); err != nil {
+				utils.Error(c, 400, err.Error())
+				return
+			}
+		}
 		if err := h.db.Model(&attachment).Association("Projects").Append(&project); err != nil {
 			utils.Error(c, utils.CodeError, "关联失败: "+err.Error())
 			return
@@ -597,8 +673,156 @@ func (h *AttachmentHandler) AttachToEntity(c *gin.Context) {
 		}
 	}
 
+	// 关联到测试单
+	if req.TestCaseID != nil {
+		var testCase model.TestCase
+		if err := h.db.First(&testCase, *req.TestCaseID).Error; err != nil {
+			utils.Error(c, 404, "测试单不存在")
+			return
+		}
+		// 验证测试单访问权限（通过测试单的项目ID）
+		if !utils.CheckProjectAccess(h.db, c, testCase.ProjectID) {
+			utils.Error(c, 403, "没有权限访问该测试单")
+			return
+		}
+		if err := h.db.Model(&attachment).Association("TestCases").Append(&testCase); err != nil {
+			utils.Error(c, utils.CodeError, "关联失败: "+err.Error())
+			return
+		}
+	}
+
+	// 关联到日报（只能关联到自己的日报）
+	if req.DailyReportID != nil {
+		var report model.DailyReport
+		if err := h.db.First(&report, *req.DailyReportID).Error; err != nil {
+			utils.Error(c, 404, "日报不存在")
+			return
+		}
+		if !utils.IsAdmin(c) && report.UserID != utils.GetUserID(c) {
+			utils.Error(c, 403, "只能为自己的日报添加附件")
+			return
+		}
+		if err := h.db.Model(&attachment).Association("DailyReports").Append(&report); err != nil {
+			utils.Error(c, utils.CodeError, "关联失败: "+err.Error())
+			return
+		}
+	}
+
+	// 关联到周报（只能关联到自己的周报）
+	if req.WeeklyReportID != nil {
+		var report model.WeeklyReport
+		if err := h.db.First(&report, *req.WeeklyReportID).Error; err != nil {
+			utils.Error(c, 404, "周报不存在")
+			return
+		}
+		if !utils.IsAdmin(c) && report.UserID != utils.GetUserID(c) {
+			utils.Error(c, 403, "只能为自己的周报添加附件")
+			return
+		}
+		if err := h.db.Model(&attachment).Association("WeeklyReports").Append(&report); err != nil {
+			utils.Error(c, utils.CodeError, "关联失败: "+err.Error())
+			return
+		}
+	}
+
 	// 重新加载附件信息
-	h.db.Preload("Projects").Preload("Requirements").Preload("Tasks").Preload("Bugs").Preload("Versions").First(&attachment, attachment.ID)
+	h.db.Preload("Projects").Preload("Requirements", utils.RestrictedPreload(c, "requirement")).Preload("Tasks", utils.RestrictedPreload(c, "task")).Preload("Bugs", utils.RestrictedPreload(c, "bug")).Preload("Versions").
+		Preload("TestCases").Preload("DailyReports").Preload("WeeklyReports").First(&attachment, attachment.ID)
 
 	utils.Success(c, attachment)
 }
+
+// canAccessAttachment 用户是否可以访问附件：管理员、附件关联项目的成员，或可以查看附件关联的日报/周报的用户
+// attachment 需要预加载 Projects
+func (h *AttachmentHandler) canAccessAttachment(c *gin.Context, attachment *model.Attachment) bool {
+	if utils.IsAdmin(c) {
+		return true
+	}
+	for _, project := range attachment.Projects {
+		if utils.CheckProjectAccess(h.db, c, project.ID) {
+			return true
+		}
+	}
+
+	reportHandler := NewReportHandler(h.db)
+	for _, kind := range []reportApprovalKind{dailyReportApproval, weeklyReportApproval} {
+		var reportIDs []uint
+		h.db.Table(kind.reportType+"_report_attachments").Where("attachment_id = ?", attachment.ID).Pluck(kind.reportColumn, &reportIDs)
+		for _, reportID := range reportIDs {
+			if reportHandler.canViewReport(c, kind, reportID) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deleteAttachment 彻底删除附件记录和历史版本记录，并释放它们引用的文件
+func (h *AttachmentHandler) deleteAttachment(attachment *model.Attachment) error {
+	var versions []model.AttachmentVersion
+	h.db.Where("attachment_id = ?", attachment.ID).Find(&versions)
+
+	if err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attachment_id = ?", attachment.ID).Delete(&model.AttachmentVersion{}).Error; err != nil {
+			return err
+		}
+		// 硬删除，彻底删除
+		return tx.Unscoped().Delete(attachment).Error
+	}); err != nil {
+		return err
+	}
+
+	// 释放文件引用（没有其他附件引用时删除文件），失败不影响删除结果
+	release := func(hash, filePath string) {
+		if err := utils.ReleaseAttachmentContent(h.db, hash, filePath); err != nil && utils.Logger != nil {
+			utils.Logger.Warnf("删除附件文件失败: %s, %v", filePath, err)
+		}
+	}
+	release(attachment.ContentHash, attachment.FilePath)
+	for _, version := range versions {
+		release(version.ContentHash, version.FilePath)
+	}
+	return nil
+}
+
+// attachmentDisposition 附件响应的 Content-Disposition（filename* 支持中文文件名）
+func attachmentDisposition(fileName, dispositionType string) string {
+	return fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", dispositionType, fileName, url.PathEscape(fileName))
+}
+
+// serveAttachmentFile 由服务器读取存储中的文件返回；dispositionType 为 attachment（下载）或 inline（预览）
+func serveAttachmentFile(c *gin.Context, key, fileName, mimeType, dispositionType string) {
+	store, err := utils.AttachmentStorage()
+	if err != nil {
+		utils.Error(c, utils.CodeError, "附件存储不可用: "+err.Error())
+		return
+	}
+	disposition := attachmentDisposition(fileName, dispositionType)
+
+	// 本地存储直接返回文件（支持断点续传和范围请求）
+	if local, ok := store.(*storage.LocalStorage); ok {
+		fullPath := local.Path(key)
+		if info, err := os.Stat(fullPath); err != nil || info.IsDir() {
+			utils.Error(c, 404, "文件不存在")
+			return
+		}
+		c.Header("Content-Disposition", disposition)
+		c.Header("Content-Type", mimeType)
+		c.File(fullPath)
+		return
+	}
+
+	reader, info, err := store.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			utils.Error(c, 404, "文件不存在")
+		} else {
+			utils.Error(c, utils.CodeError, "读取文件失败: "+err.Error())
+		}
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(http.StatusOK, info.Size, mimeType, reader, map[string]string{
+		"Content-Disposition": disposition,
+	})
+}
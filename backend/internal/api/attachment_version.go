@@ -0,0 +1,213 @@
+package api
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 附件版本：上传新版本时附件记录更新为新文件，每个版本的文件记录在附件版本表中。
+// 附件第一次上传新版本前没有版本记录，此时附件本身就是唯一的版本
+
+// attachmentForVersion 获取附件并检查访问权限，失败时已返回错误
+func (h *AttachmentHandler) attachmentForVersion(c *gin.Context) (*model.Attachment, bool) {
+	var attachment model.Attachment
+	if err := h.db.Preload("Creator").Preload("Projects").First(&attachment, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "附件不存在")
+		return nil, false
+	}
+	if !h.canAccessAttachment(c, &attachment) || !utils.CanViewAttachment(h.db, c, attachment.ID) {
+		utils.Error(c, 403, "没有权限访问该附件")
+		return nil, false
+	}
+	return &attachment, true
+}
+
+// currentAttachmentVersion 由附件记录生成当前版本（附件还没有版本记录时使用）
+func currentAttachmentVersion(attachment *model.Attachment) model.AttachmentVersion {
+	version := attachment.Version
+	if version < 1 {
+		version = 1
+	}
+	return model.AttachmentVersion{
+		CreatedAt:    attachment.CreatedAt,
+		AttachmentID: attachment.ID,
+		Version:      version,
+		FileName:     attachment.FileName,
+		FilePath:     attachment.FilePath,
+		FileSize:     attachment.FileSize,
+		MimeType:     attachment.MimeType,
+		ContentHash:  attachment.ContentHash,
+		CreatorID:    attachment.CreatorID,
+		Creator:      attachment.Creator,
+	}
+}
+
+// UploadAttachmentVersion 上传附件的新版本
+// 权限要求：登录 + 项目成员验证 + attachment:upload 权限；只关联报告的附件只有上传者可以上传新版本
+func (h *AttachmentHandler) UploadAttachmentVersion(c *gin.Context) {
+	attachment, ok := h.attachmentForVersion(c)
+	if !ok {
+		return
+	}
+	userID := utils.GetUserID(c)
+	if len(attachment.Projects) == 0 && !utils.IsAdmin(c) && attachment.CreatorID != userID {
+		utils.Error(c, 403, "没有权限上传该附件的新版本")
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.Error(c, 400, "请选择要上传的文件")
+		return
+	}
+	if code, err := validateUploadedFile(file); err != nil {
+		utils.Error(c, code, err.Error())
+		return
+	}
+	comment := c.PostForm("comment")
+	if utf8.RuneCountInString(comment) > 500 {
+		utils.Error(c, 400, "版本说明不能超过500个字符")
+		return
+	}
+
+	// 新版本比当前版本大时，检查附件关联的每个项目的存储配额
+	if delta := file.Size - attachment.FileSize; delta > 0 {
+		for i := range attachment.Projects {
+			if err := utils.CheckProjectStorageQuota(h.db, &attachment.Projects[i], delta); err != nil {
+				utils.Error(c, 400, err.Error())
+				return
+			}
+		}
+	}
+
+	// 保存文件，返回的引用由附件记录持有
+	blob, code, err := storeUploadedFile(h.db, file)
+	if err != nil {
+		utils.Error(c, code, err.Error())
+		return
+	}
+	mimeType := file.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	old := currentAttachmentVersion(attachment)
+	hasHistory := false
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var latest model.AttachmentVersion
+		err := tx.Where("attachment_id = ?", attachment.ID).Order("version DESC").First(&latest).Error
+		switch {
+		case err == nil:
+			hasHistory = true
+		case err == gorm.ErrRecordNotFound:
+			// 第一次上传新版本：把当前文件记录为第一个历史版本，沿用附件持有的文件引用
+			latest = old
+			if err := tx.Omit("Creator").Create(&latest).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		version := model.AttachmentVersion{
+			AttachmentID: attachment.ID,
+			Version:      latest.Version + 1,
+			FileName:     file.Filename,
+			FilePath:     blob.FilePath,
+			FileSize:     blob.FileSize,
+			MimeType:     mimeType,
+			ContentHash:  blob.Hash,
+			Comment:      comment,
+			CreatorID:    userID,
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		// 版本记录持有一次文件引用
+		if err := utils.AcquireAttachmentContent(tx, blob.Hash); err != nil {
+			return err
+		}
+		return tx.Model(attachment).Updates(map[string]interface{}{
+			"file_name":    version.FileName,
+			"file_path":    version.FilePath,
+			"file_size":    version.FileSize,
+			"mime_type":    version.MimeType,
+			"content_hash": version.ContentHash,
+			"version":      version.Version,
+		}).Error
+	})
+	if err != nil {
+		utils.ReleaseAttachmentContent(h.db, blob.Hash, blob.FilePath)
+		utils.Error(c, utils.CodeError, "上传新版本失败: "+err.Error())
+		return
+	}
+
+	// 旧文件已由历史版本记录引用，释放附件记录持有的引用
+	if hasHistory {
+		if err := utils.ReleaseAttachmentContent(h.db, old.ContentHash, old.FilePath); err != nil && utils.Logger != nil {
+			utils.Logger.Warnf("释放附件旧版本文件失败: %s, %v", old.FilePath, err)
+		}
+	}
+
+	h.db.Preload("Creator").Preload("Projects").First(attachment, attachment.ID)
+	utils.Success(c, attachment)
+}
+
+// GetAttachmentVersions 获取附件的版本历史（按版本号倒序）
+// 权限要求：登录 + 项目成员验证
+func (h *AttachmentHandler) GetAttachmentVersions(c *gin.Context) {
+	attachment, ok := h.attachmentForVersion(c)
+	if !ok {
+		return
+	}
+
+	var versions []model.AttachmentVersion
+	if err := h.db.Preload("Creator").Where("attachment_id = ?", attachment.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败: "+err.Error())
+		return
+	}
+	if len(versions) == 0 {
+		versions = append(versions, currentAttachmentVersion(attachment))
+	}
+
+	utils.Success(c, gin.H{
+		"attachment_id":   attachment.ID,
+		"current_version": attachment.Version,
+		"list":            versions,
+	})
+}
+
+// DownloadAttachmentVersion 下载附件的指定版本
+// 权限要求：登录 + 项目成员验证
+func (h *AttachmentHandler) DownloadAttachmentVersion(c *gin.Context) {
+	attachment, ok := h.attachmentForVersion(c)
+	if !ok {
+		return
+	}
+	var versionNumber int
+	if _, err := fmt.Sscanf(c.Param("version"), "%d", &versionNumber); err != nil {
+		utils.Error(c, 400, "无效的版本号")
+		return
+	}
+
+	version := currentAttachmentVersion(attachment)
+	if versionNumber != version.Version {
+		var history model.AttachmentVersion
+		if err := h.db.Where("attachment_id = ? AND version = ?", attachment.ID, versionNumber).First(&history).Error; err != nil {
+			utils.Error(c, 404, "版本不存在")
+			return
+		}
+		version = history
+	}
+
+	for _, object := range utils.RestrictedAttachmentObjects(h.db, attachment.ID) {
+		utils.RecordRestrictedView(h.db, c, object.ObjectType, object.ObjectID, fmt.Sprintf("查看附件：%s（版本 %d）", version.FileName, version.Version))
+	}
+	serveAttachmentFile(c, version.FilePath, version.FileName, version.MimeType, "attachment")
+}
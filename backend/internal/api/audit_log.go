@@ -1,6 +1,10 @@
 package api
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,11 +26,8 @@ func NewAuditLogHandler(db *gorm.DB) *AuditLogHandler {
 	return &AuditLogHandler{db: auditDB}
 }
 
-// GetAuditLogs 获取审计日志列表
-func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
-	var auditLogs []model.AuditLog
-	query := h.db.Model(&model.AuditLog{})
-
+// applyAuditLogFilters 应用审计日志的筛选条件（列表和导出共用）
+func applyAuditLogFilters(c *gin.Context, query *gorm.DB) *gorm.DB {
 	// 用户筛选
 	if userID := c.Query("user_id"); userID != "" {
 		query = query.Where("user_id = ?", userID)
@@ -70,6 +71,13 @@ func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
 		query = query.Where("username LIKE ? OR resource_type LIKE ? OR action_type LIKE ?",
 			"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
 	}
+	return query
+}
+
+// GetAuditLogs 获取审计日志列表
+func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
+	var auditLogs []model.AuditLog
+	query := applyAuditLogFilters(c, h.db.Model(&model.AuditLog{}))
 
 	// 分页
 	page := utils.GetPage(c)
@@ -78,42 +86,7 @@ func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
 
 	var total int64
 	// 计算总数时需要应用与查询相同的筛选条件
-	countQuery := h.db.Model(&model.AuditLog{})
-
-	// 应用相同的筛选条件
-	if userID := c.Query("user_id"); userID != "" {
-		countQuery = countQuery.Where("user_id = ?", userID)
-	}
-	if actionType := c.Query("action_type"); actionType != "" {
-		countQuery = countQuery.Where("action_type = ?", actionType)
-	}
-	if resourceType := c.Query("resource_type"); resourceType != "" {
-		countQuery = countQuery.Where("resource_type = ?", resourceType)
-	}
-	if success := c.Query("success"); success != "" {
-		if success == "true" {
-			countQuery = countQuery.Where("success = ?", true)
-		} else if success == "false" {
-			countQuery = countQuery.Where("success = ?", false)
-		}
-	}
-	if startDate := c.Query("start_date"); startDate != "" {
-		if startTime, err := time.Parse("2006-01-02", startDate); err == nil {
-			countQuery = countQuery.Where("created_at >= ?", startTime)
-		}
-	}
-	if endDate := c.Query("end_date"); endDate != "" {
-		if endTime, err := time.Parse("2006-01-02", endDate); err == nil {
-			endTime = endTime.AddDate(0, 0, 1)
-			countQuery = countQuery.Where("created_at < ?", endTime)
-		}
-	}
-	if keyword := c.Query("keyword"); keyword != "" {
-		countQuery = countQuery.Where("username LIKE ? OR resource_type LIKE ? OR action_type LIKE ?",
-			"%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
-	}
-
-	countQuery.Count(&total)
+	applyAuditLogFilters(c, h.db.Model(&model.AuditLog{})).Count(&total)
 
 	// 排序：按时间倒序
 	query = query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&auditLogs)
@@ -141,4 +114,83 @@ func (h *AuditLogHandler) GetAuditLog(c *gin.Context) {
 	utils.Success(c, auditLog)
 }
 
+// 单次导出的最大记录数
+const maxAuditLogExport = 100000
+
+// ExportAuditLogs 按筛选条件导出审计日志（format：csv 或 json，按时间正序）
+func (h *AuditLogHandler) ExportAuditLogs(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		utils.Error(c, 400, "无效的导出格式，有效值：csv, json")
+		return
+	}
+
+	var total int64
+	applyAuditLogFilters(c, h.db.Model(&model.AuditLog{})).Count(&total)
+	if total > maxAuditLogExport {
+		utils.Error(c, 400, fmt.Sprintf("导出记录数超过 %d 条，请缩小筛选范围", maxAuditLogExport))
+		return
+	}
+
+	filename := fmt.Sprintf("audit_logs_%s.%s", time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Writer.WriteString("\xEF\xBB\xBF") // UTF-8 BOM，便于 Excel 正确识别中文
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+	}
+	c.Status(200)
+
+	writer := csv.NewWriter(c.Writer)
+	if format == "csv" {
+		writer.Write([]string{"id", "created_at", "user_id", "username", "action_type", "resource_type", "resource_id",
+			"ip_address", "method", "path", "params", "success", "error_msg", "comment", "prev_hash", "hash"})
+	} else {
+		c.Writer.WriteString("[")
+	}
+
+	first := true
+	var batch []model.AuditLog
+	err := applyAuditLogFilters(c, h.db.Model(&model.AuditLog{})).Order("id ASC").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			log := &batch[i]
+			if format == "json" {
+				data, err := json.Marshal(log)
+				if err != nil {
+					return err
+				}
+				if !first {
+					c.Writer.WriteString(",")
+				}
+				first = false
+				c.Writer.Write(data)
+				continue
+			}
+			writer.Write([]string{
+				strconv.FormatUint(uint64(log.ID), 10), log.CreatedAt.Format(time.RFC3339), strconv.FormatUint(uint64(log.UserID), 10),
+				log.Username, log.ActionType, log.ResourceType, strconv.FormatUint(uint64(log.ResourceID), 10),
+				log.IPAddress, log.Method, log.Path, log.Params, strconv.FormatBool(log.Success), log.ErrorMsg, log.Comment,
+				log.PrevHash, log.Hash,
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	}).Error
+	if format == "json" {
+		c.Writer.WriteString("]")
+	}
+	if err != nil && utils.Logger != nil {
+		utils.Logger.Errorf("导出审计日志失败: %v", err)
+	}
+}
 
+// VerifyAuditLogs 校验审计日志哈希链，发现被修改、删除或绕过系统写入的记录
+func (h *AuditLogHandler) VerifyAuditLogs(c *gin.Context) {
+	report, err := utils.VerifyAuditChain(h.db)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "校验失败")
+		return
+	}
+	utils.Success(c, report)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"prjflow/internal/utils"
+	"prjflow/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// backupName 校验备份文件名并确认备份存在
+func backupName(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if !utils.ValidBackupName(name) {
+		utils.Error(c, 400, "无效的备份文件名")
+		return "", false
+	}
+	if _, err := utils.StatStoredBackup(name); err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			utils.Error(c, 404, "备份不存在")
+		} else {
+			utils.Error(c, utils.CodeError, "读取备份失败: "+err.Error())
+		}
+		return "", false
+	}
+	return name, true
+}
+
+// backupPath 校验备份文件名并返回备份在本地备份目录中的路径（对象存储中的备份先下载到本地）
+func backupPath(c *gin.Context) (string, bool) {
+	name, ok := backupName(c)
+	if !ok {
+		return "", false
+	}
+	path, err := utils.FetchBackup(name)
+	if err != nil {
+		utils.Error(c, utils.CodeError, err.Error())
+		return "", false
+	}
+	return path, true
+}
+
+// GetBackups 获取备份列表、保留天数、待执行的恢复和最近一次恢复结果
+func (h *SystemHandler) GetBackups(c *gin.Context) {
+	backupDir := utils.BackupDir()
+	backups, err := utils.ListStoredBackups()
+	if err != nil {
+		utils.Error(c, utils.CodeError, "读取备份列表失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{
+		"list":            backups,
+		"total":           len(backups),
+		"retention_days":  utils.LoadBackupRetentionDays(h.db),
+		"pending_restore": utils.GetPendingRestore(backupDir),
+		"last_restore":    utils.GetLastRestoreResult(backupDir),
+	})
+}
+
+// DownloadBackup 下载备份文件
+func (h *SystemHandler) DownloadBackup(c *gin.Context) {
+	name, ok := backupName(c)
+	if !ok {
+		return
+	}
+	reader, info, err := utils.OpenStoredBackup(name)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "读取备份失败: "+err.Error())
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(http.StatusOK, info.Size, "application/zip", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%s", name),
+	})
+}
+
+// DeleteBackup 删除备份文件（已暂存恢复的备份不能删除）
+func (h *SystemHandler) DeleteBackup(c *gin.Context) {
+	name, ok := backupName(c)
+	if !ok {
+		return
+	}
+	if pending := utils.GetPendingRestore(utils.BackupDir()); pending != nil && pending.Name == name {
+		utils.Error(c, 400, "该备份已暂存恢复，请先取消恢复")
+		return
+	}
+	if err := utils.DeleteStoredBackup(name); err != nil {
+		utils.Error(c, utils.CodeError, "删除备份失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// VerifyBackup 校验备份文件的完整性
+func (h *SystemHandler) VerifyBackup(c *gin.Context) {
+	path, ok := backupPath(c)
+	if !ok {
+		return
+	}
+	manifest, err := utils.VerifyBackup(path)
+	if err != nil {
+		utils.Error(c, 400, "备份校验失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"valid": true, "manifest": manifest})
+}
+
+// StageRestore 校验备份并暂存恢复，下次启动服务时执行（会覆盖当前数据和上传文件）
+func (h *SystemHandler) StageRestore(c *gin.Context) {
+	path, ok := backupPath(c)
+	if !ok {
+		return
+	}
+	requestedBy := fmt.Sprintf("%d", utils.GetUserID(c))
+	if username, exists := c.Get("username"); exists {
+		requestedBy = fmt.Sprintf("%v", username)
+	}
+	pending, err := utils.StageRestore(utils.BackupDir(), filepath.Base(path), requestedBy)
+	if err != nil {
+		utils.Error(c, 400, "无法恢复该备份: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{
+		"message":         "备份校验通过，将在下次启动服务时恢复",
+		"pending_restore": pending,
+	})
+}
+
+// CancelRestore 取消暂存的恢复
+func (h *SystemHandler) CancelRestore(c *gin.Context) {
+	backupDir := utils.BackupDir()
+	if utils.GetPendingRestore(backupDir) == nil {
+		utils.Error(c, 404, "没有待执行的恢复")
+		return
+	}
+	if err := utils.CancelPendingRestore(backupDir); err != nil {
+		utils.Error(c, utils.CodeError, "取消恢复失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"message": "已取消恢复"})
+}
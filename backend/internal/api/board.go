@@ -61,12 +61,9 @@ func (h *BoardHandler) CreateBoard(c *gin.Context) {
 	var req struct {
 		Name        string `json:"name" binding:"required"`
 		Description string `json:"description"`
-		Columns     []struct {
-			Name   string `json:"name" binding:"required"`
-			Color  string `json:"color"`
-			Status string `json:"status" binding:"required"`
-			Sort   int    `json:"sort"`
-		} `json:"columns"`
+		CardTypes   string `json:"card_types"`  // 卡片类型（逗号分隔），默认 task
+		SwimlaneBy  string `json:"swimlane_by"` // 泳道分组方式
+		Columns     []boardColumnRequest `json:"columns"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -74,6 +71,22 @@ func (h *BoardHandler) CreateBoard(c *gin.Context) {
 		return
 	}
 
+	cardTypes, ok := parseBoardCardTypes(req.CardTypes)
+	if !ok {
+		utils.Error(c, 400, "卡片类型无效，有效值：task, bug, requirement")
+		return
+	}
+	if req.SwimlaneBy != "" && !boardSwimlanes[req.SwimlaneBy] {
+		utils.Error(c, 400, "泳道分组方式无效，有效值：assignee, priority, requirement")
+		return
+	}
+	for _, col := range req.Columns {
+		if msg := col.validate(); msg != "" {
+			utils.Error(c, 400, msg)
+			return
+		}
+	}
+
 	// 验证项目是否存在
 	var project model.Project
 	if err := h.db.First(&project, projectID).Error; err != nil {
@@ -85,6 +98,8 @@ func (h *BoardHandler) CreateBoard(c *gin.Context) {
 		Name:        req.Name,
 		Description: req.Description,
 		ProjectID:   project.ID,
+		CardTypes:   cardTypes,
+		SwimlaneBy:  req.SwimlaneBy,
 	}
 
 	if err := h.db.Create(&board).Error; err != nil {
@@ -95,13 +110,7 @@ func (h *BoardHandler) CreateBoard(c *gin.Context) {
 	// 创建列
 	if len(req.Columns) > 0 {
 		for i, col := range req.Columns {
-			column := model.BoardColumn{
-				Name:   col.Name,
-				Color:  col.Color,
-				Status: col.Status,
-				Sort:   col.Sort,
-				BoardID: board.ID,
-			}
+			column := col.toColumn(board.ID)
 			if column.Sort == 0 {
 				column.Sort = i
 			}
@@ -132,6 +141,8 @@ func (h *BoardHandler) UpdateBoard(c *gin.Context) {
 	var req struct {
 		Name        *string `json:"name"`
 		Description *string `json:"description"`
+		CardTypes   *string `json:"card_types"`
+		SwimlaneBy  *string `json:"swimlane_by"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -145,6 +156,21 @@ func (h *BoardHandler) UpdateBoard(c *gin.Context) {
 	if req.Description != nil {
 		board.Description = *req.Description
 	}
+	if req.CardTypes != nil {
+		cardTypes, ok := parseBoardCardTypes(*req.CardTypes)
+		if !ok {
+			utils.Error(c, 400, "卡片类型无效，有效值：task, bug, requirement")
+			return
+		}
+		board.CardTypes = cardTypes
+	}
+	if req.SwimlaneBy != nil {
+		if *req.SwimlaneBy != "" && !boardSwimlanes[*req.SwimlaneBy] {
+			utils.Error(c, 400, "泳道分组方式无效，有效值：assignee, priority, requirement")
+			return
+		}
+		board.SwimlaneBy = *req.SwimlaneBy
+	}
 
 	if err := h.db.Save(&board).Error; err != nil {
 		utils.Error(c, utils.CodeError, "更新失败")
@@ -168,6 +194,9 @@ func (h *BoardHandler) DeleteBoard(c *gin.Context) {
 		return
 	}
 
+	// 清理卡片排序
+	h.db.Where("board_id = ?", id).Delete(&model.BoardCard{})
+
 	utils.Success(c, gin.H{"message": "删除成功"})
 }
 
@@ -180,17 +209,15 @@ func (h *BoardHandler) CreateBoardColumn(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Name   string `json:"name" binding:"required"`
-		Color  string `json:"color"`
-		Status string `json:"status" binding:"required"`
-		Sort   int    `json:"sort"`
-	}
-
+	var req boardColumnRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.Error(c, 400, "参数错误")
 		return
 	}
+	if msg := req.validate(); msg != "" {
+		utils.Error(c, 400, msg)
+		return
+	}
 
 	// 如果未指定排序，自动设置为最大值+1
 	if req.Sort == 0 {
@@ -199,13 +226,7 @@ func (h *BoardHandler) CreateBoardColumn(c *gin.Context) {
 		req.Sort = maxSort + 1
 	}
 
-	column := model.BoardColumn{
-		Name:   req.Name,
-		Color:  req.Color,
-		Status: req.Status,
-		Sort:   req.Sort,
-		BoardID: board.ID,
-	}
+	column := req.toColumn(board.ID)
 
 	if err := h.db.Create(&column).Error; err != nil {
 		utils.Error(c, utils.CodeError, "创建失败")
@@ -225,10 +246,14 @@ func (h *BoardHandler) UpdateBoardColumn(c *gin.Context) {
 	}
 
 	var req struct {
-		Name   *string `json:"name"`
-		Color  *string `json:"color"`
-		Status *string `json:"status"`
-		Sort   *int    `json:"sort"`
+		Name              *string `json:"name"`
+		Color             *string `json:"color"`
+		Status            *string `json:"status"`
+		BugStatus         *string `json:"bug_status"`
+		RequirementStatus *string `json:"requirement_status"`
+		Sort              *int    `json:"sort"`
+		WIPLimit          *int    `json:"wip_limit"`
+		WIPPolicy         *string `json:"wip_policy"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -245,9 +270,29 @@ func (h *BoardHandler) UpdateBoardColumn(c *gin.Context) {
 	if req.Status != nil {
 		column.Status = *req.Status
 	}
+	if req.BugStatus != nil {
+		column.BugStatus = *req.BugStatus
+	}
+	if req.RequirementStatus != nil {
+		column.RequirementStatus = *req.RequirementStatus
+	}
 	if req.Sort != nil {
 		column.Sort = *req.Sort
 	}
+	if req.WIPLimit != nil {
+		if *req.WIPLimit < 0 {
+			utils.Error(c, 400, "WIP上限不能为负数")
+			return
+		}
+		column.WIPLimit = *req.WIPLimit
+	}
+	if req.WIPPolicy != nil {
+		if !isValidWIPPolicy(*req.WIPPolicy) {
+			utils.Error(c, 400, "WIP策略无效，有效值：block, warn")
+			return
+		}
+		column.WIPPolicy = *req.WIPPolicy
+	}
 
 	if err := h.db.Save(&column).Error; err != nil {
 		utils.Error(c, utils.CodeError, "更新失败")
@@ -271,67 +316,21 @@ func (h *BoardHandler) DeleteBoardColumn(c *gin.Context) {
 
 // MoveTask 移动任务到不同列（拖拽排序）
 func (h *BoardHandler) MoveTask(c *gin.Context) {
-	boardID := c.Param("id")
-	taskID := c.Param("task_id")
-
-	var req struct {
-		ColumnID string `json:"column_id" binding:"required"`
-		Position int    `json:"position"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.Error(c, 400, "参数错误")
+	result, ok := h.moveCard(c, "task", c.Param("task_id"))
+	if !ok {
 		return
 	}
 
-	// 获取看板
-	var board model.Board
-	if err := h.db.First(&board, boardID).Error; err != nil {
-		utils.Error(c, 404, "看板不存在")
+	if result.Warning != "" {
+		utils.SuccessWithMessage(c, result.Warning, result.Object)
 		return
 	}
-
-	// 获取列
-	var column model.BoardColumn
-	if err := h.db.Where("board_id = ? AND id = ?", boardID, req.ColumnID).First(&column).Error; err != nil {
-		utils.Error(c, 404, "列不存在")
-		return
-	}
-
-	// 获取任务
-	var task model.Task
-	if err := h.db.First(&task, taskID).Error; err != nil {
-		utils.Error(c, 404, "任务不存在")
-		return
-	}
-
-	// 验证任务是否属于该看板的项目
-	if task.ProjectID != board.ProjectID {
-		utils.Error(c, 400, "任务不属于该看板的项目")
-		return
-	}
-
-	// 更新任务状态（根据列的状态）
-	if column.Status != "" {
-		task.Status = column.Status
-		// 如果状态为done，自动设置进度为100
-		if column.Status == "done" {
-			task.Progress = 100
-		}
-	}
-
-	if err := h.db.Save(&task).Error; err != nil {
-		utils.Error(c, utils.CodeError, "移动失败")
-		return
-	}
-
-	// 重新加载任务数据
-	h.db.Preload("Project").Preload("Creator").Preload("Assignee").Preload("Dependencies").First(&task, task.ID)
-
-	utils.Success(c, task)
+	utils.Success(c, result.Object)
 }
 
-// GetBoardTasks 获取看板的任务（按列分组）
+// GetBoardTasks 获取看板的卡片（按列分组）
+// tasks_by_column 只包含任务（兼容原有接口），cards_by_column 包含看板显示的所有类型的卡片
+// 看板设置了泳道或请求指定了 swimlane 参数时，返回按泳道分组的卡片
 func (h *BoardHandler) GetBoardTasks(c *gin.Context) {
 	boardID := c.Param("id")
 	var board model.Board
@@ -342,28 +341,86 @@ func (h *BoardHandler) GetBoardTasks(c *gin.Context) {
 		return
 	}
 
-	// 获取项目的所有任务
-	var allTasks []model.Task
-	h.db.Where("project_id = ?", board.ProjectID).
-		Preload("Creator").Preload("Assignee").
-		Order("created_at DESC").
-		Find(&allTasks)
+	swimlaneBy := board.SwimlaneBy
+	if swimlane, ok := c.GetQuery("swimlane"); ok {
+		if swimlane != "" && !boardSwimlanes[swimlane] {
+			utils.Error(c, 400, "泳道分组方式无效，有效值：assignee, priority, requirement")
+			return
+		}
+		swimlaneBy = swimlane
+	}
+
+	cards := h.loadBoardCards(c, &board)
+	cardsByColumn := groupCardsByColumn(&board, cards)
 
 	// 按列分组任务
-	result := make(map[string]interface{})
-	result["board"] = board
-	result["tasks_by_column"] = make(map[uint][]model.Task)
-
-	tasksByColumn := result["tasks_by_column"].(map[uint][]model.Task)
-	for _, column := range board.Columns {
-		tasksByColumn[column.ID] = []model.Task{}
-		for _, task := range allTasks {
-			if column.Status != "" && task.Status == column.Status {
-				tasksByColumn[column.ID] = append(tasksByColumn[column.ID], task)
+	tasksByColumn := make(map[uint][]model.Task, len(board.Columns))
+	for columnID, columnCards := range cardsByColumn {
+		tasksByColumn[columnID] = []model.Task{}
+		for _, card := range columnCards {
+			if task, ok := card.Object.(model.Task); ok {
+				tasksByColumn[columnID] = append(tasksByColumn[columnID], task)
 			}
 		}
 	}
 
+	result := gin.H{
+		"board":           board,
+		"tasks_by_column": tasksByColumn,
+		"cards_by_column": cardsByColumn,
+		"swimlane_by":     swimlaneBy,
+	}
+	if swimlaneBy != "" {
+		result["swimlanes"] = h.buildSwimlanes(&board, cards, swimlaneBy)
+	}
+
 	utils.Success(c, result)
 }
 
+// boardColumnRequest 创建看板列的请求参数
+type boardColumnRequest struct {
+	Name              string `json:"name" binding:"required"`
+	Color             string `json:"color"`
+	Status            string `json:"status"`             // 关联的任务状态
+	BugStatus         string `json:"bug_status"`         // 关联的Bug状态
+	RequirementStatus string `json:"requirement_status"` // 关联的需求状态
+	Sort              int    `json:"sort"`
+	WIPLimit          int    `json:"wip_limit"`  // WIP上限，0表示不限制
+	WIPPolicy         string `json:"wip_policy"` // block(默认) 或 warn
+}
+
+// validate 校验列参数，返回错误信息
+func (r *boardColumnRequest) validate() string {
+	if r.Status == "" && r.BugStatus == "" && r.RequirementStatus == "" {
+		return "列至少需要关联一种状态"
+	}
+	if r.WIPLimit < 0 {
+		return "WIP上限不能为负数"
+	}
+	if r.WIPPolicy != "" && !isValidWIPPolicy(r.WIPPolicy) {
+		return "WIP策略无效，有效值：block, warn"
+	}
+	return ""
+}
+
+func (r *boardColumnRequest) toColumn(boardID uint) model.BoardColumn {
+	policy := r.WIPPolicy
+	if policy == "" {
+		policy = "block"
+	}
+	return model.BoardColumn{
+		Name:              r.Name,
+		Color:             r.Color,
+		Status:            r.Status,
+		BugStatus:         r.BugStatus,
+		RequirementStatus: r.RequirementStatus,
+		Sort:              r.Sort,
+		WIPLimit:          r.WIPLimit,
+		WIPPolicy:         policy,
+		BoardID:           boardID,
+	}
+}
+
+func isValidWIPPolicy(policy string) bool {
+	return policy == "block" || policy == "warn"
+}
@@ -0,0 +1,643 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 看板支持的卡片类型
+var boardCardTypes = []string{"task", "bug", "requirement"}
+
+// 看板支持的泳道分组方式
+var boardSwimlanes = map[string]bool{"assignee": true, "priority": true, "requirement": true}
+
+// 优先级泳道的顺序和名称
+var priorityLanes = []struct{ key, name string }{
+	{"urgent", "紧急"},
+	{"high", "高"},
+	{"medium", "中"},
+	{"low", "低"},
+}
+
+// boardCard 看板卡片（任务、Bug或需求）
+type boardCard struct {
+	Type     string      `json:"type"`
+	ID       uint        `json:"id"`
+	Title    string      `json:"title"`
+	Status   string      `json:"status"`
+	Priority string      `json:"priority"`
+	Rank     string      `json:"rank"`
+	Version  int         `json:"version"`
+	Object   interface{} `json:"object"`
+
+	createdAt     time.Time
+	assignee      *model.User
+	requirementID *uint
+}
+
+// parseBoardCardTypes 解析并校验卡片类型配置，返回规范化后的配置
+func parseBoardCardTypes(value string) (string, bool) {
+	valid := make(map[string]bool, len(boardCardTypes))
+	for _, t := range boardCardTypes {
+		valid[t] = true
+	}
+
+	seen := make(map[string]bool)
+	types := make([]string, 0, len(boardCardTypes))
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || seen[part] {
+			continue
+		}
+		if !valid[part] {
+			return "", false
+		}
+		seen[part] = true
+		types = append(types, part)
+	}
+	if len(types) == 0 {
+		return "task", true
+	}
+	return strings.Join(types, ","), true
+}
+
+// boardHasCardType 看板是否显示该类型的卡片
+func boardHasCardType(board *model.Board, objectType string) bool {
+	types := board.CardTypes
+	if types == "" {
+		types = "task"
+	}
+	for _, t := range strings.Split(types, ",") {
+		if strings.TrimSpace(t) == objectType {
+			return true
+		}
+	}
+	return false
+}
+
+// columnStatusFor 列对应的对象状态
+func columnStatusFor(column *model.BoardColumn, objectType string) string {
+	switch objectType {
+	case "bug":
+		return column.BugStatus
+	case "requirement":
+		return column.RequirementStatus
+	default:
+		return column.Status
+	}
+}
+
+func boardCardKey(objectType string, objectID uint) string {
+	return fmt.Sprintf("%s:%d", objectType, objectID)
+}
+
+// loadBoardCards 加载看板上当前用户可见的卡片，并附带排序键
+func (h *BoardHandler) loadBoardCards(c *gin.Context, board *model.Board) []boardCard {
+	var cards []boardCard
+
+	if boardHasCardType(board, "task") {
+		var tasks []model.Task
+		utils.FilterRestrictedItems(c, "task", h.db.Where("project_id = ?", board.ProjectID)).
+			Preload("Creator").Preload("Assignee").
+			Find(&tasks)
+		for i := range tasks {
+			task := tasks[i]
+			cards = append(cards, boardCard{
+				Type: "task", ID: task.ID, Title: task.Title, Status: task.Status, Priority: task.Priority, Object: task,
+				createdAt: task.CreatedAt, assignee: task.Assignee, requirementID: task.RequirementID,
+			})
+		}
+	}
+
+	if boardHasCardType(board, "bug") {
+		var bugs []model.Bug
+		utils.FilterRestrictedItems(c, "bug", h.db.Where("project_id = ?", board.ProjectID)).
+			Preload("Creator").Preload("Assignees").
+			Find(&bugs)
+		for i := range bugs {
+			bug := bugs[i]
+			card := boardCard{
+				Type: "bug", ID: bug.ID, Title: bug.Title, Status: bug.Status, Priority: bug.Priority, Object: bug,
+				createdAt: bug.CreatedAt, requirementID: bug.RequirementID,
+			}
+			if len(bug.Assignees) > 0 {
+				card.assignee = &bug.Assignees[0]
+			}
+			cards = append(cards, card)
+		}
+	}
+
+	if boardHasCardType(board, "requirement") {
+		var requirements []model.Requirement
+		utils.FilterRestrictedItems(c, "requirement", h.db.Where("project_id = ?", board.ProjectID)).
+			Preload("Creator").Preload("Assignee").
+			Find(&requirements)
+		for i := range requirements {
+			requirement := requirements[i]
+			requirementID := requirement.ID
+			cards = append(cards, boardCard{
+				Type: "requirement", ID: requirement.ID, Title: requirement.Title, Status: requirement.Status, Priority: requirement.Priority, Object: requirement,
+				createdAt: requirement.CreatedAt, assignee: requirement.Assignee, requirementID: &requirementID,
+			})
+		}
+	}
+
+	// 附加排序键和版本号
+	var ranks []model.BoardCard
+	h.db.Where("board_id = ?", board.ID).Find(&ranks)
+	rankByKey := make(map[string]string, len(ranks))
+	for _, r := range ranks {
+		rankByKey[boardCardKey(r.ObjectType, r.ObjectID)] = r.Rank
+	}
+	versions := loadCardVersions(h.db, board.ProjectID)
+	for i := range cards {
+		key := boardCardKey(cards[i].Type, cards[i].ID)
+		cards[i].Rank = rankByKey[key]
+		cards[i].Version = versions[key]
+	}
+
+	sortBoardCards(cards)
+	return cards
+}
+
+// sortBoardCards 有排序键的卡片按排序键在前，没有排序键的卡片按创建时间倒序在后
+func sortBoardCards(cards []boardCard) {
+	sort.SliceStable(cards, func(i, j int) bool {
+		a, b := cards[i], cards[j]
+		if (a.Rank == "") != (b.Rank == "") {
+			return a.Rank != ""
+		}
+		if a.Rank != b.Rank {
+			return a.Rank < b.Rank
+		}
+		if !a.createdAt.Equal(b.createdAt) {
+			return a.createdAt.After(b.createdAt)
+		}
+		return boardCardKey(a.Type, a.ID) < boardCardKey(b.Type, b.ID)
+	})
+}
+
+// columnCards 筛选属于某列的卡片（卡片状态与列对应类型的状态一致）
+func columnCards(column *model.BoardColumn, cards []boardCard) []boardCard {
+	result := []boardCard{}
+	for _, card := range cards {
+		if status := columnStatusFor(column, card.Type); status != "" && card.Status == status {
+			result = append(result, card)
+		}
+	}
+	return result
+}
+
+// groupCardsByColumn 按列分组卡片
+func groupCardsByColumn(board *model.Board, cards []boardCard) map[uint][]boardCard {
+	result := make(map[uint][]boardCard, len(board.Columns))
+	for i := range board.Columns {
+		result[board.Columns[i].ID] = columnCards(&board.Columns[i], cards)
+	}
+	return result
+}
+
+// buildSwimlanes 按泳道分组方式将卡片分组，每个泳道内再按列分组
+func (h *BoardHandler) buildSwimlanes(board *model.Board, cards []boardCard, swimlaneBy string) []gin.H {
+	type lane struct {
+		key, name string
+		cards     []boardCard
+	}
+	lanes := make(map[string]*lane)
+	keys := make([]string, 0)
+	add := func(key, name string, card boardCard) {
+		l, ok := lanes[key]
+		if !ok {
+			l = &lane{key: key, name: name}
+			lanes[key] = l
+			keys = append(keys, key)
+		}
+		l.cards = append(l.cards, card)
+	}
+
+	switch swimlaneBy {
+	case "assignee":
+		for _, card := range cards {
+			if card.assignee == nil {
+				add("none", "未分配", card)
+				continue
+			}
+			name := card.assignee.Nickname
+			if name == "" {
+				name = card.assignee.Username
+			}
+			add(strconv.FormatUint(uint64(card.assignee.ID), 10), name, card)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			if (keys[i] == "none") != (keys[j] == "none") {
+				return keys[j] == "none"
+			}
+			return lanes[keys[i]].name < lanes[keys[j]].name
+		})
+	case "priority":
+		names := make(map[string]string, len(priorityLanes))
+		order := make(map[string]int, len(priorityLanes))
+		for i, p := range priorityLanes {
+			names[p.key] = p.name
+			order[p.key] = i
+		}
+		for _, card := range cards {
+			name, ok := names[card.Priority]
+			if !ok {
+				name = card.Priority
+			}
+			add(card.Priority, name, card)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			oi, ok := order[keys[i]]
+			if !ok {
+				oi = len(order)
+			}
+			oj, ok := order[keys[j]]
+			if !ok {
+				oj = len(order)
+			}
+			return oi < oj
+		})
+	case "requirement":
+		requirementIDs := make([]uint, 0)
+		for _, card := range cards {
+			if card.requirementID != nil {
+				requirementIDs = append(requirementIDs, *card.requirementID)
+			}
+		}
+		titles := make(map[uint]string)
+		if len(requirementIDs) > 0 {
+			var requirements []model.Requirement
+			h.db.Select("id", "title").Where("id IN ?", requirementIDs).Find(&requirements)
+			for _, r := range requirements {
+				titles[r.ID] = r.Title
+			}
+		}
+		for _, card := range cards {
+			if card.requirementID == nil {
+				add("none", "无关联需求", card)
+				continue
+			}
+			add(strconv.FormatUint(uint64(*card.requirementID), 10), titles[*card.requirementID], card)
+		}
+		sort.SliceStable(keys, func(i, j int) bool {
+			if (keys[i] == "none") != (keys[j] == "none") {
+				return keys[j] == "none"
+			}
+			a, _ := strconv.Atoi(keys[i])
+			b, _ := strconv.Atoi(keys[j])
+			return a < b
+		})
+	}
+
+	result := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		l := lanes[key]
+		result = append(result, gin.H{
+			"key":             l.key,
+			"name":            l.name,
+			"cards_by_column": groupCardsByColumn(board, l.cards),
+		})
+	}
+	return result
+}
+
+// countColumnCards 统计列中的卡片数量（用于WIP限制，统计所有卡片，不区分当前用户是否可见）
+func (h *BoardHandler) countColumnCards(db *gorm.DB, board *model.Board, column *model.BoardColumn, excludeType string, excludeID uint) int64 {
+	var total int64
+	for _, item := range []struct {
+		objectType string
+		model      interface{}
+	}{
+		{"task", &model.Task{}},
+		{"bug", &model.Bug{}},
+		{"requirement", &model.Requirement{}},
+	} {
+		status := columnStatusFor(column, item.objectType)
+		if status == "" || !boardHasCardType(board, item.objectType) {
+			continue
+		}
+		var count int64
+		query := db.Model(item.model).Where("project_id = ? AND status = ?", board.ProjectID, status)
+		if item.objectType == excludeType {
+			query = query.Where("id <> ?", excludeID)
+		}
+		query.Count(&count)
+		total += count
+	}
+	return total
+}
+
+// boardMoveResult 卡片移动结果
+type boardMoveResult struct {
+	Object   interface{}
+	ColumnID uint
+	Rank     string
+	Version  int
+	Warning  string // 超出WIP上限（warn 策略）时的提示
+}
+
+// moveCard 移动卡片到指定列的指定位置，失败时已写入错误响应
+// 列有WIP限制时：block 策略拒绝移入，warn 策略允许移入并返回提示
+// 提交了卡片版本号时，版本号与当前不一致（卡片已被其他人移动或修改）返回冲突，由客户端刷新后重试
+func (h *BoardHandler) moveCard(c *gin.Context, objectType, objectID string) (*boardMoveResult, bool) {
+	var req struct {
+		ColumnID string `json:"column_id" binding:"required"`
+		Position *int   `json:"position"` // 在目标列中的位置（从0开始），为空表示放到最后
+		Version  *int   `json:"version"`  // 客户端看到的卡片版本号，为空表示不检查冲突
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return nil, false
+	}
+
+	var board model.Board
+	if err := h.db.First(&board, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "看板不存在")
+		return nil, false
+	}
+	if !boardHasCardType(&board, objectType) {
+		utils.Error(c, 400, "看板不显示该类型的卡片")
+		return nil, false
+	}
+
+	var column model.BoardColumn
+	if err := h.db.Where("board_id = ? AND id = ?", board.ID, req.ColumnID).First(&column).Error; err != nil {
+		utils.Error(c, 404, "列不存在")
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(objectID, 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "无效的ID")
+		return nil, false
+	}
+
+	// 加载卡片对象
+	var target interface{}
+	var projectID uint
+	var oldStatus string
+	switch objectType {
+	case "task":
+		var task model.Task
+		if err := h.db.First(&task, id).Error; err != nil {
+			utils.Error(c, 404, "任务不存在")
+			return nil, false
+		}
+		target, projectID, oldStatus = &task, task.ProjectID, task.Status
+	case "bug":
+		var bug model.Bug
+		if err := h.db.First(&bug, id).Error; err != nil {
+			utils.Error(c, 404, "Bug不存在")
+			return nil, false
+		}
+		target, projectID, oldStatus = &bug, bug.ProjectID, bug.Status
+	case "requirement":
+		var requirement model.Requirement
+		if err := h.db.First(&requirement, id).Error; err != nil {
+			utils.Error(c, 404, "需求不存在")
+			return nil, false
+		}
+		target, projectID, oldStatus = &requirement, requirement.ProjectID, requirement.Status
+	default:
+		utils.Error(c, 400, "不支持的卡片类型")
+		return nil, false
+	}
+
+	if projectID != board.ProjectID {
+		utils.Error(c, 400, "卡片不属于该看板的项目")
+		return nil, false
+	}
+	// 项目权限由路由中间件检查，这里只检查受限对象的可见性
+	if !utils.CanViewRestrictedItem(h.db, c, objectType, uint(id)) {
+		utils.Error(c, 403, "没有权限访问该卡片")
+		return nil, false
+	}
+
+	newStatus := columnStatusFor(&column, objectType)
+	if newStatus == "" {
+		newStatus = oldStatus
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	// WIP 限制：卡片已在该列时不受限制
+	// 在事务中先更新列记录加行锁，再统计列中卡片数量，避免并发移入同时通过 block 限制
+	warning := ""
+	if column.WIPLimit > 0 && newStatus != oldStatus {
+		if err := tx.Model(&column).Update("updated_at", time.Now()).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "移动失败")
+			return nil, false
+		}
+		count := h.countColumnCards(tx, &board, &column, objectType, uint(id))
+		if int(count) >= column.WIPLimit {
+			if column.WIPPolicy == "warn" {
+				warning = fmt.Sprintf("列「%s」已超出在制品（WIP）上限 %d", column.Name, column.WIPLimit)
+			} else {
+				tx.Rollback()
+				utils.ErrorWithData(c, 400, fmt.Sprintf("列「%s」已达到在制品（WIP）上限 %d", column.Name, column.WIPLimit), gin.H{
+					"wip_limit": column.WIPLimit,
+					"count":     count,
+				})
+				return nil, false
+			}
+		}
+	}
+
+	// 检查并递增卡片版本号
+	version, err := bumpCardVersion(tx, board.ProjectID, objectType, uint(id), req.Version)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, errCardVersionConflict) {
+			utils.ErrorWithData(c, 409, "卡片已被其他人移动或修改，请刷新后重试", gin.H{
+				"type":    objectType,
+				"id":      uint(id),
+				"status":  oldStatus,
+				"version": version,
+			})
+			return nil, false
+		}
+		utils.Error(c, utils.CodeError, "移动失败")
+		return nil, false
+	}
+
+	// 更新状态（根据列对应的状态）
+	if newStatus != oldStatus {
+		updates := map[string]interface{}{"status": newStatus}
+		// 如果任务状态为done，自动设置进度为100
+		if objectType == "task" && newStatus == "done" {
+			updates["progress"] = 100
+		}
+		if err := tx.Model(target).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "移动失败")
+			return nil, false
+		}
+		if userID := utils.GetUserID(c); userID > 0 {
+			actionID, _ := utils.RecordAction(tx, objectType, uint(id), "edited", userID, "", nil)
+			utils.RecordHistory(tx, actionID, []utils.HistoryChange{{Field: "status", Old: oldStatus, New: newStatus}})
+		}
+	}
+
+	// 计算排序键（未指定位置时放到目标列最后）
+	rank, err := h.placeCard(tx, c, &board, &column, objectType, uint(id), req.Position)
+	if err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "保存排序失败")
+		return nil, false
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "移动失败")
+		return nil, false
+	}
+
+	// 重新加载卡片数据
+	switch t := target.(type) {
+	case *model.Task:
+		h.db.Preload("Project").Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task")).First(t, t.ID)
+	case *model.Bug:
+		h.db.Preload("Project").Preload("Creator").Preload("Assignees").First(t, t.ID)
+	case *model.Requirement:
+		h.db.Preload("Project").Preload("Creator").Preload("Assignee").First(t, t.ID)
+	}
+
+	publishCardEvent(h.db, c, cardEventMoved, objectType, uint(id), board.ProjectID, newStatus, version)
+
+	return &boardMoveResult{Object: target, ColumnID: column.ID, Rank: rank, Version: version, Warning: warning}, true
+}
+
+// columnRankedCards 查询列中已有排序键的卡片记录（不含正在移动的卡片），由调用方按 board_cards.rank 排序
+// rank 在 MySQL 8 中是保留字，使用带表名的列名
+// 只查询 board_cards 和对象ID子查询，不加载任务、Bug、需求本身；当前用户不可见的受限对象不参与定位
+func columnRankedCards(tx *gorm.DB, c *gin.Context, board *model.Board, column *model.BoardColumn, objectType string, objectID uint) *gorm.DB {
+	conditions := tx.Where("1 = 0")
+	for _, item := range []struct {
+		objectType string
+		model      interface{}
+	}{
+		{"task", &model.Task{}},
+		{"bug", &model.Bug{}},
+		{"requirement", &model.Requirement{}},
+	} {
+		status := columnStatusFor(column, item.objectType)
+		if status == "" || !boardHasCardType(board, item.objectType) {
+			continue
+		}
+		ids := utils.FilterRestrictedItems(c, item.objectType,
+			tx.Model(item.model).Select("id").Where("project_id = ? AND status = ?", board.ProjectID, status))
+		conditions = conditions.Or("object_type = ? AND object_id IN (?)", item.objectType, ids)
+	}
+	return tx.Model(&model.BoardCard{}).
+		Where("board_id = ? AND board_cards.rank <> ''", board.ID).
+		Where("NOT (object_type = ? AND object_id = ?)", objectType, objectID).
+		Where(conditions)
+}
+
+// placeCard 将卡片放到列中的指定位置并保存排序键，返回新的排序键
+// 只查询目标位置前后相邻的两张卡片；没有排序键的卡片显示在有排序键的卡片之后，位置超出时放到有排序键的卡片最后
+// 排序键过长时重排整列
+func (h *BoardHandler) placeCard(tx *gorm.DB, c *gin.Context, board *model.Board, column *model.BoardColumn, objectType string, objectID uint, position *int) (string, error) {
+	// prev、next 为目标位置前后的卡片，为空表示没有
+	var prev, next model.BoardCard
+	placed := false
+	if position != nil && *position >= 0 {
+		var neighbours []model.BoardCard
+		query := columnRankedCards(tx, c, board, column, objectType, objectID).Order("board_cards.rank")
+		if *position == 0 {
+			query = query.Limit(1)
+		} else {
+			query = query.Offset(*position - 1).Limit(2)
+		}
+		if err := query.Find(&neighbours).Error; err != nil {
+			return "", err
+		}
+		switch {
+		case *position == 0:
+			placed = true
+			if len(neighbours) > 0 {
+				next = neighbours[0]
+			}
+		case len(neighbours) == 2:
+			placed = true
+			prev, next = neighbours[0], neighbours[1]
+		}
+	}
+	if !placed {
+		// 未指定位置或位置超出时放到最后
+		if err := columnRankedCards(tx, c, board, column, objectType, objectID).Order("board_cards.rank DESC").Limit(1).Find(&prev).Error; err != nil {
+			return "", err
+		}
+	}
+
+	rank := utils.RankBetween(prev.Rank, next.Rank)
+	if len(rank) > utils.MaxRankLength {
+		// 排序键过长，重新生成整列的排序键
+		var cards []model.BoardCard
+		if err := columnRankedCards(tx, c, board, column, objectType, objectID).Order("board_cards.rank").Find(&cards).Error; err != nil {
+			return "", err
+		}
+		pos := len(cards)
+		for i := range cards {
+			if cards[i].ID == next.ID {
+				pos = i
+				break
+			}
+		}
+		ranks := utils.RankSequence(len(cards) + 1)
+		for i, j := 0, 0; i < len(ranks); i++ {
+			if i == pos {
+				rank = ranks[i]
+				continue
+			}
+			if err := tx.Model(&cards[j]).Update("rank", ranks[i]).Error; err != nil {
+				return "", err
+			}
+			j++
+		}
+	}
+
+	return rank, saveBoardCardRank(tx, board.ID, objectType, objectID, rank)
+}
+
+// saveBoardCardRank 保存卡片的排序键
+func saveBoardCardRank(db *gorm.DB, boardID uint, objectType string, objectID uint, rank string) error {
+	var card model.BoardCard
+	return db.Where(model.BoardCard{BoardID: boardID, ObjectType: objectType, ObjectID: objectID}).
+		Assign(model.BoardCard{Rank: rank}).
+		FirstOrCreate(&card).Error
+}
+
+// MoveCard 移动看板卡片（任务、Bug或需求）到指定列的指定位置
+func (h *BoardHandler) MoveCard(c *gin.Context) {
+	objectType := c.Param("type")
+	result, ok := h.moveCard(c, objectType, c.Param("card_id"))
+	if !ok {
+		return
+	}
+
+	data := gin.H{"type": objectType, "column_id": result.ColumnID, "rank": result.Rank, "version": result.Version, "object": result.Object}
+	if result.Warning != "" {
+		utils.SuccessWithMessage(c, result.Warning, data)
+		return
+	}
+	utils.Success(c, data)
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"errors"
+	"sync"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+	"prjflow/internal/websocket"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 看板卡片变更事件类型
+const (
+	cardEventCreated = "card.created"
+	cardEventUpdated = "card.updated"
+	cardEventMoved   = "card.moved"
+	cardEventDeleted = "card.deleted"
+)
+
+// errCardVersionConflict 卡片版本号与客户端提交的版本号不一致（卡片已被其他人移动或修改）
+var errCardVersionConflict = errors.New("card version conflict")
+
+// projectBoards 项目下显示该类型卡片的看板
+func projectBoards(db *gorm.DB, projectID uint, objectType string) []model.Board {
+	var boards []model.Board
+	db.Preload("Columns", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort ASC")
+	}).Where("project_id = ?", projectID).Order("id ASC").Find(&boards)
+
+	result := make([]model.Board, 0, len(boards))
+	for _, board := range boards {
+		if boardHasCardType(&board, objectType) {
+			result = append(result, board)
+		}
+	}
+	return result
+}
+
+// currentCardVersion 获取卡片当前的版本号（同一对象在各看板中的版本号相同，取最大值以兼容后来创建的看板）
+func currentCardVersion(db *gorm.DB, objectType string, objectID uint) int {
+	var version int
+	db.Model(&model.BoardCard{}).
+		Where("object_type = ? AND object_id = ?", objectType, objectID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version)
+	return version
+}
+
+// bumpCardVersion 递增卡片的版本号并返回新版本号
+// expected 不为空时先检查客户端提交的版本号，不一致返回 errCardVersionConflict；
+// 更新时以读取到的版本号为条件，并发修改同一卡片时后提交的一方同样返回冲突
+func bumpCardVersion(tx *gorm.DB, projectID uint, objectType string, objectID uint, expected *int) (int, error) {
+	current := currentCardVersion(tx, objectType, objectID)
+	if expected != nil && *expected != current {
+		return current, errCardVersionConflict
+	}
+
+	// 为显示该类型卡片的看板补齐卡片记录
+	for _, board := range projectBoards(tx, projectID, objectType) {
+		var card model.BoardCard
+		if err := tx.Where(model.BoardCard{BoardID: board.ID, ObjectType: objectType, ObjectID: objectID}).
+			Attrs(model.BoardCard{Version: current}).
+			FirstOrCreate(&card).Error; err != nil {
+			return current, err
+		}
+	}
+
+	result := tx.Model(&model.BoardCard{}).
+		Where("object_type = ? AND object_id = ? AND version <= ?", objectType, objectID, current).
+		Update("version", current+1)
+	if result.Error != nil {
+		return current, result.Error
+	}
+	if result.RowsAffected == 0 && currentCardVersion(tx, objectType, objectID) > current {
+		return current, errCardVersionConflict
+	}
+	return current + 1, nil
+}
+
+// loadCardVersions 加载项目中卡片的版本号，key为 boardCardKey
+func loadCardVersions(db *gorm.DB, projectID uint) map[string]int {
+	var rows []struct {
+		ObjectType string
+		ObjectID   uint
+		Version    int
+	}
+	db.Model(&model.BoardCard{}).
+		Select("object_type, object_id, MAX(version) AS version").
+		Where("board_id IN (?)", db.Model(&model.Board{}).Select("id").Where("project_id = ?", projectID)).
+		Group("object_type, object_id").
+		Scan(&rows)
+
+	versions := make(map[string]int, len(rows))
+	for _, row := range rows {
+		versions[boardCardKey(row.ObjectType, row.ObjectID)] = row.Version
+	}
+	return versions
+}
+
+// publishCardEvent 向项目订阅者和显示该卡片的看板订阅者广播卡片变更事件
+func publishCardEvent(db *gorm.DB, c *gin.Context, event, objectType string, objectID, projectID uint, status string, version int) {
+	hub := websocket.GetHub()
+	if hub.SubscriberCount(websocket.ProjectTopic(projectID)) == 0 && !hasBoardSubscribers(hub, db, projectID) {
+		return
+	}
+
+	// 删除事件不包含对象内容，对所有订阅者广播（已删除的对象无法再判断可见性）
+	// 受限对象的可见范围只加载一次，各订阅者在内存中判断
+	var access *utils.RestrictedItemAccess
+	if event != cardEventDeleted {
+		access = utils.LoadRestrictedItemAccess(db, objectType, objectID)
+	}
+	restricted := access != nil
+	actorID := utils.GetUserID(c)
+
+	hub.Publish(websocket.ProjectTopic(projectID), &websocket.BoardEvent{
+		Event: event, ProjectID: projectID, ObjectType: objectType, ObjectID: objectID,
+		Status: status, Version: version, ActorID: actorID, Restricted: restricted, Access: access,
+	})
+
+	for _, board := range projectBoards(db, projectID, objectType) {
+		topic := websocket.BoardTopic(board.ID)
+		if hub.SubscriberCount(topic) == 0 {
+			continue
+		}
+		boardEvent := &websocket.BoardEvent{
+			Event: event, BoardID: board.ID, ProjectID: projectID, ObjectType: objectType, ObjectID: objectID,
+			Status: status, Version: version, ActorID: actorID, Restricted: restricted, Access: access,
+		}
+		if event != cardEventDeleted {
+			for i := range board.Columns {
+				if s := columnStatusFor(&board.Columns[i], objectType); s != "" && s == status {
+					boardEvent.ColumnID = board.Columns[i].ID
+					break
+				}
+			}
+			var card model.BoardCard
+			if db.Where("board_id = ? AND object_type = ? AND object_id = ?", board.ID, objectType, objectID).First(&card).Error == nil {
+				boardEvent.Rank = card.Rank
+			}
+		}
+		hub.Publish(topic, boardEvent)
+	}
+}
+
+// hasBoardSubscribers 项目下是否有看板被订阅
+func hasBoardSubscribers(hub *websocket.Hub, db *gorm.DB, projectID uint) bool {
+	var boardIDs []uint
+	db.Model(&model.Board{}).Where("project_id = ?", projectID).Pluck("id", &boardIDs)
+	for _, id := range boardIDs {
+		if hub.SubscriberCount(websocket.BoardTopic(id)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyCardChange 任务、Bug或需求创建、修改或删除后递增卡片版本号并广播变更事件
+// 广播失败不影响业务操作，只记录日志
+func notifyCardChange(db *gorm.DB, c *gin.Context, event, objectType string, objectID, projectID uint, status string) {
+	var version int
+	if event == cardEventDeleted {
+		version = currentCardVersion(db, objectType, objectID) + 1
+		db.Where("object_type = ? AND object_id = ?", objectType, objectID).Delete(&model.BoardCard{})
+	} else {
+		var err error
+		if version, err = bumpCardVersion(db, projectID, objectType, objectID, nil); err != nil && utils.Logger != nil {
+			utils.Logger.Warnf("更新卡片版本号失败: %s %d: %v", objectType, objectID, err)
+		}
+	}
+	publishCardEvent(db, c, event, objectType, objectID, projectID, status, version)
+}
+
+// liveViewerGenerations 实时订阅者可见性信息的版本：项目成员或项目角色变更时递增，订阅者发现版本变化后重新加载
+var liveViewerGenerations = struct {
+	sync.Mutex
+	all       int          // 项目角色变更（影响所有项目）
+	byProject map[uint]int // 项目成员变更
+}{byProject: make(map[uint]int)}
+
+// liveViewerGeneration 获取项目订阅者可见性信息的当前版本
+func liveViewerGeneration(projectID uint) int {
+	liveViewerGenerations.Lock()
+	defer liveViewerGenerations.Unlock()
+	return liveViewerGenerations.all + liveViewerGenerations.byProject[projectID]
+}
+
+// invalidateLiveViewers 项目成员变更后使项目订阅者的可见性信息失效
+func invalidateLiveViewers(projectID uint) {
+	liveViewerGenerations.Lock()
+	liveViewerGenerations.byProject[projectID]++
+	liveViewerGenerations.Unlock()
+}
+
+// invalidateAllLiveViewers 项目角色变更后使所有订阅者的可见性信息失效
+func invalidateAllLiveViewers() {
+	liveViewerGenerations.Lock()
+	liveViewerGenerations.all++
+	liveViewerGenerations.Unlock()
+}
+
+// liveViewer 实时订阅者的受限对象可见性信息，订阅时加载，失效后在下一个受限事件时重新加载
+type liveViewer struct {
+	mu         sync.Mutex
+	db         *gorm.DB
+	ctx        *gin.Context // 订阅请求的用户和角色
+	projectID  uint
+	generation int
+	viewer     *utils.RestrictedViewer
+}
+
+// newLiveViewer 加载订阅用户在项目中的可见性信息
+func newLiveViewer(db *gorm.DB, c *gin.Context, projectID uint) *liveViewer {
+	ctx := &gin.Context{}
+	ctx.Set("user_id", utils.GetUserID(c))
+	if roles, exists := c.Get("roles"); exists {
+		ctx.Set("roles", roles)
+	}
+	v := &liveViewer{db: db, ctx: ctx, projectID: projectID}
+	v.load()
+	return v
+}
+
+func (v *liveViewer) load() {
+	v.generation = liveViewerGeneration(v.projectID)
+	v.viewer = utils.LoadRestrictedViewer(v.db, v.ctx, v.projectID)
+}
+
+// canView 判断订阅用户是否可以接收事件
+func (v *liveViewer) canView(event *websocket.BoardEvent) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.generation != liveViewerGeneration(v.projectID) {
+		v.load()
+	}
+	return v.viewer.CanView(event.Access)
+}
+
+// subscribe 升级为WebSocket连接并订阅主题，受限对象的事件只发送给可以查看该对象的用户
+func (h *BoardHandler) subscribe(c *gin.Context, topic string, projectID uint) {
+	viewer := newLiveViewer(h.db, c, projectID)
+
+	conn, err := websocket.Upgrade(c)
+	if err != nil {
+		return
+	}
+	websocket.GetHub().Subscribe(conn, utils.GetUserID(c), []string{topic}, viewer.canView)
+}
+
+// SubscribeBoard 订阅看板的实时卡片变更（WebSocket）
+func (h *BoardHandler) SubscribeBoard(c *gin.Context) {
+	var board model.Board
+	if err := h.db.First(&board, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "看板不存在")
+		return
+	}
+	h.subscribe(c, websocket.BoardTopic(board.ID), board.ProjectID)
+}
+
+// SubscribeProject 订阅项目下所有卡片的实时变更（WebSocket）
+func (h *BoardHandler) SubscribeProject(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+	h.subscribe(c, websocket.ProjectTopic(project.ID), project.ID)
+}
@@ -24,7 +24,7 @@ func NewBugHandler(db *gorm.DB) *BugHandler {
 // GetBugs 获取Bug列表
 func (h *BugHandler) GetBugs(c *gin.Context) {
 	var bugs []model.Bug
-	query := h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement").Preload("Module").Preload("Versions")
+	query := h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Module").Preload("Versions")
 
 	// 权限过滤：普通用户只能看到自己创建或参与的Bug
 	query = utils.FilterBugsByUser(h.db, c, query)
@@ -337,7 +337,7 @@ func (h *BugHandler) GetBug(c *gin.Context) {
 	id := c.Param("id")
 	var bug model.Bug
 
-	if err := h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement").Preload("Module").Preload("Versions").Preload("Attachments").Preload("Attachments.Creator").First(&bug, id).Error; err != nil {
+	if err := h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Module").Preload("Versions").Preload("Attachments").Preload("Attachments.Creator").First(&bug, id).Error; err != nil {
 		utils.Error(c, 404, "Bug不存在")
 		return
 	}
@@ -348,6 +348,11 @@ func (h *BugHandler) GetBug(c *gin.Context) {
 		return
 	}
 
+	// 查看受限对象时记录审计日志
+	if bug.Confidential {
+		utils.RecordRestrictedView(h.db, c, "bug", bug.ID, "查看Bug详情")
+	}
+
 	utils.Success(c, bug)
 }
 
@@ -365,6 +370,7 @@ func (h *BugHandler) CreateBug(c *gin.Context) {
 		AssigneeIDs    []uint   `json:"assignee_ids"`
 		EstimatedHours *float64 `json:"estimated_hours"`
 		VersionIDs     []uint   `json:"version_ids" binding:"required,min=1"` // 所属版本ID列表（必填，至少一个）
+		Confidential   bool     `json:"confidential"`                         // 是否保密（如安全漏洞）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -489,6 +495,7 @@ func (h *BugHandler) CreateBug(c *gin.Context) {
 		ModuleID:       req.ModuleID,
 		CreatorID:      userID.(uint),
 		EstimatedHours: req.EstimatedHours,
+		Confidential:   req.Confidential,
 	}
 
 	if err := h.db.Create(&bug).Error; err != nil {
@@ -515,7 +522,7 @@ func (h *BugHandler) CreateBug(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement").Preload("Module").Preload("ResolvedVersion").Preload("Versions").First(&bug, bug.ID)
+	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Module").Preload("ResolvedVersion").Preload("Versions").First(&bug, bug.ID)
 
 	// 记录创建操作
 	dbValue, _ := c.Get("db")
@@ -537,6 +544,7 @@ func (h *BugHandler) CreateBug(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventCreated, "bug", bug.ID, bug.ProjectID, bug.Status)
 	utils.Success(c, bug)
 }
 
@@ -885,7 +893,7 @@ func (h *BugHandler) UpdateBug(c *gin.Context) {
 	// 注意：必须在 Replace 之后重新查询，才能获取到最新的附件关联
 	// 使用 Session 确保使用新的查询上下文，避免缓存问题
 	// 注意：Preload 会自动过滤软删除的记录（DeletedAt IS NULL）
-	if err := h.db.Session(&gorm.Session{}).Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement").Preload("Module").Preload("ResolvedVersion").Preload("Versions").Preload("Attachments").Preload("Attachments.Creator").First(&bug, bug.ID).Error; err != nil {
+	if err := h.db.Session(&gorm.Session{}).Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Module").Preload("ResolvedVersion").Preload("Versions").Preload("Attachments").Preload("Attachments.Creator").First(&bug, bug.ID).Error; err != nil {
 		utils.Error(c, utils.CodeError, "重新加载Bug数据失败: "+err.Error())
 		return
 	}
@@ -900,6 +908,7 @@ func (h *BugHandler) UpdateBug(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "bug", bug.ID, bug.ProjectID, bug.Status)
 	utils.Success(c, bug)
 }
 
@@ -925,6 +934,7 @@ func (h *BugHandler) DeleteBug(c *gin.Context) {
 		return
 	}
 
+	notifyCardChange(h.db, c, cardEventDeleted, "bug", bug.ID, bug.ProjectID, "")
 	utils.Success(c, gin.H{"message": "删除成功"})
 }
 
@@ -1148,7 +1158,7 @@ func (h *BugHandler) UpdateBugStatus(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement").Preload("Module").Preload("ResolvedVersion").First(&bug, bug.ID)
+	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Module").Preload("ResolvedVersion").First(&bug, bug.ID)
 
 	// 记录解决/关闭操作和字段变更
 	userID, exists := c.Get("user_id")
@@ -1206,6 +1216,7 @@ func (h *BugHandler) UpdateBugStatus(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "bug", bug.ID, bug.ProjectID, bug.Status)
 	utils.Success(c, bug)
 }
 
@@ -1326,7 +1337,7 @@ func (h *BugHandler) AssignBug(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement").Preload("Module").Preload("ResolvedVersion").First(&bug, bug.ID)
+	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Module").Preload("ResolvedVersion").First(&bug, bug.ID)
 
 	// 记录分配操作
 	userID, exists := c.Get("user_id")
@@ -1356,6 +1367,7 @@ func (h *BugHandler) AssignBug(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "bug", bug.ID, bug.ProjectID, bug.Status)
 	utils.Success(c, bug)
 }
 
@@ -1517,7 +1529,7 @@ func (h *BugHandler) ConfirmBug(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement").Preload("Module").Preload("ResolvedVersion").First(&bug, bug.ID)
+	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Module").Preload("ResolvedVersion").First(&bug, bug.ID)
 
 	// 记录确认操作
 	userID, exists := c.Get("user_id")
@@ -1528,6 +1540,7 @@ func (h *BugHandler) ConfirmBug(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "bug", bug.ID, bug.ProjectID, bug.Status)
 	utils.Success(c, bug)
 }
 
@@ -1636,6 +1649,11 @@ func (h *BugHandler) GetBugHistory(c *gin.Context) {
 		return
 	}
 
+	// 查看受限对象时记录审计日志
+	if bug.Confidential {
+		utils.RecordRestrictedView(h.db, c, "bug", bug.ID, "查看Bug历史记录")
+	}
+
 	// 查询操作记录
 	var actions []model.Action
 	if err := h.db.Where("object_type = ? AND object_id = ?", "bug", id).
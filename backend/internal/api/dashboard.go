@@ -12,11 +12,12 @@ import (
 )
 
 type DashboardHandler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *widgetCache
 }
 
 func NewDashboardHandler(db *gorm.DB) *DashboardHandler {
-	return &DashboardHandler{db: db}
+	return &DashboardHandler{db: db, cache: newWidgetCache()}
 }
 
 // GetDashboard 获取个人工作台数据
@@ -29,6 +30,12 @@ func (h *DashboardHandler) GetDashboard(c *gin.Context) {
 
 	uid := userID.(uint)
 
+	// 配置了组件时只计算配置的组件
+	if instances := h.userWidgetInstances(uid); len(instances) > 0 {
+		utils.Success(c, gin.H{"widgets": h.renderWidgets(c, instances)})
+		return
+	}
+
 	// 获取我的任务统计
 	taskStats := h.getTaskStats(uid)
 
@@ -47,6 +54,9 @@ func (h *DashboardHandler) GetDashboard(c *gin.Context) {
 	// 获取资源分配统计（本周、本月工时）
 	resourceStats := h.getResourceStats(uid)
 
+	// 获取我参与的项目最近14天的趋势（来自项目指标快照）
+	trends := h.getTrends(c, uid)
+
 	// 汇总统计
 	statistics := gin.H{
 		"total_tasks":        taskStats["todo"].(int) + taskStats["in_progress"].(int) + taskStats["done"].(int),
@@ -64,9 +74,25 @@ func (h *DashboardHandler) GetDashboard(c *gin.Context) {
 		"projects":     projects,
 		"reports":      reportStats,
 		"statistics":   statistics,
+		"trends":       trends,
 	})
 }
 
+// userWidgetInstances 用户工作台配置中的组件实例
+func (h *DashboardHandler) userWidgetInstances(userID uint) []dashboardWidgetInstance {
+	var dashboard model.UserDashboard
+	if err := h.db.Where("user_id = ?", userID).First(&dashboard).Error; err != nil {
+		return nil
+	}
+	var config struct {
+		Widgets []dashboardWidgetInstance `json:"widgets"`
+	}
+	if err := json.Unmarshal([]byte(dashboard.Config), &config); err != nil {
+		return nil
+	}
+	return config.Widgets
+}
+
 // getTaskStats 获取任务统计
 func (h *DashboardHandler) getTaskStats(userID uint) gin.H {
 	var todoCount, inProgressCount, doneCount int64
@@ -156,6 +182,31 @@ func (h *DashboardHandler) getMyProjects(userID uint) []gin.H {
 	return projects
 }
 
+// getTrends 获取用户参与项目的每日趋势：未完成任务、未解决Bug、未关闭需求数量和剩余工时（不含用户不可见的受限对象）
+func (h *DashboardHandler) getTrends(c *gin.Context, userID uint) []gin.H {
+	now := time.Now()
+	series, err := utils.ProjectMetricSeries(h.db, c, utils.GetUserProjectIDs(h.db, userID), now.AddDate(0, 0, -13), now)
+	if err != nil {
+		if utils.Logger != nil {
+			utils.Logger.Warnf("获取工作台趋势失败: %v", err)
+		}
+		return []gin.H{}
+	}
+
+	result := make([]gin.H, 0, len(series))
+	for _, day := range series {
+		result = append(result, gin.H{
+			"date":              day.Date,
+			"open_tasks":        day.OpenCount("task"),
+			"open_bugs":         day.OpenCount("bug"),
+			"open_requirements": day.OpenCount("requirement"),
+			"remaining_hours":   day.Get("task", "hours", "remaining"),
+			"completed_hours":   day.Get("task", "hours", "completed"),
+		})
+	}
+	return result
+}
+
 // getReportStats 获取工作报告统计
 func (h *DashboardHandler) getReportStats(userID uint) gin.H {
 	var pendingCount, submittedCount int64
@@ -316,6 +367,16 @@ func (h *DashboardHandler) SaveDashboardConfig(c *gin.Context) {
 		return
 	}
 
+	// 组件配置需要是已注册的组件
+	if widgets, ok := req["widgets"]; ok && widgets != nil {
+		instances, err := parseWidgetInstances(widgets)
+		if err != nil {
+			utils.Error(c, 400, err.Error())
+			return
+		}
+		req["widgets"] = instances
+	}
+
 	// 将配置转换为JSON字符串
 	configJSON, err := json.Marshal(req)
 	if err != nil {
@@ -0,0 +1,469 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// dashboardWidgetFilter 组件的筛选条件
+type dashboardWidgetFilter struct {
+	Type     string   `json:"type"`               // task, bug, requirement
+	Status   []string `json:"status,omitempty"`   // 状态
+	Priority []string `json:"priority,omitempty"` // 优先级
+	Assignee string   `json:"assignee,omitempty"` // me：分配给我的
+	Creator  string   `json:"creator,omitempty"`  // me：我创建的
+	Keyword  string   `json:"keyword,omitempty"`  // 标题关键字
+}
+
+// dashboardWidgetParams 组件实例的参数
+type dashboardWidgetParams struct {
+	ProjectID *uint                  `json:"project_id,omitempty"` // 项目，为空时为用户参与的所有项目
+	Filter    *dashboardWidgetFilter `json:"filter,omitempty"`     // 筛选条件
+	Range     string                 `json:"range,omitempty"`      // 时间范围：7d, 14d, 30d, 90d, week, month
+	Limit     int                    `json:"limit,omitempty"`      // 列表条数
+}
+
+// dashboardWidgetInstance 仪表板中的组件实例
+type dashboardWidgetInstance struct {
+	ID     string                 `json:"id"`
+	Widget string                 `json:"widget"`
+	Title  string                 `json:"title,omitempty"`
+	Params dashboardWidgetParams  `json:"params"`
+	Layout map[string]interface{} `json:"layout,omitempty"` // 前端布局（位置、尺寸），服务端不解析
+}
+
+// dashboardWidgetRequest 组件计算时的上下文
+type dashboardWidgetRequest struct {
+	UserID     uint
+	Params     dashboardWidgetParams
+	ProjectIDs []uint // 指定项目时只有该项目；否则为用户参与的项目，管理员为空表示不限
+	Start      time.Time
+	End        time.Time // 不包含
+}
+
+// dashboardWidget 组件定义：一个有名称的数据提供者
+type dashboardWidget struct {
+	Name         string        `json:"name"`
+	Title        string        `json:"title"`
+	Description  string        `json:"description"`
+	Params       []string      `json:"params"`                  // 支持的参数
+	DefaultRange string        `json:"default_range,omitempty"` // 默认时间范围
+	CacheTTL     time.Duration `json:"-"`
+
+	provider func(h *DashboardHandler, c *gin.Context, req *dashboardWidgetRequest) (interface{}, error)
+}
+
+// dashboardWidgetRegistry 服务端组件注册表
+var dashboardWidgetRegistry = map[string]*dashboardWidget{}
+
+func registerDashboardWidget(widget *dashboardWidget) {
+	dashboardWidgetRegistry[widget.Name] = widget
+}
+
+func init() {
+	registerDashboardWidget(&dashboardWidget{
+		Name: "my_tasks", Title: "我的任务", Description: "分配给我的任务按状态统计", CacheTTL: 30 * time.Second,
+		provider: func(h *DashboardHandler, c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+			return h.getTaskStats(req.UserID), nil
+		},
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "my_bugs", Title: "我的Bug", Description: "分配给我的Bug按状态统计", CacheTTL: 30 * time.Second,
+		provider: func(h *DashboardHandler, c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+			return h.getBugStats(req.UserID), nil
+		},
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "my_requirements", Title: "我的需求", Description: "分配给我的需求按状态统计", CacheTTL: 30 * time.Second,
+		provider: func(h *DashboardHandler, c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+			return h.getRequirementStats(req.UserID), nil
+		},
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "my_projects", Title: "我的项目", Description: "我参与的项目", CacheTTL: time.Minute,
+		provider: func(h *DashboardHandler, c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+			return h.getMyProjects(req.UserID), nil
+		},
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "my_reports", Title: "我的工作报告", Description: "日报周报的草稿、已提交和待我审批数量", CacheTTL: 30 * time.Second,
+		provider: func(h *DashboardHandler, c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+			return h.getReportStats(req.UserID), nil
+		},
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "my_hours", Title: "我的工时", Description: "本周和本月工时", CacheTTL: time.Minute,
+		provider: func(h *DashboardHandler, c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+			return h.getResourceStats(req.UserID), nil
+		},
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "trends", Title: "趋势", Description: "项目每日未完成任务、未解决Bug、未关闭需求和剩余工时",
+		Params: []string{"project_id", "range"}, DefaultRange: "14d", CacheTTL: 5 * time.Minute,
+		provider: (*DashboardHandler).widgetTrends,
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "item_count", Title: "工作项统计", Description: "符合筛选条件的任务、Bug或需求按状态统计",
+		Params: []string{"project_id", "filter"}, CacheTTL: time.Minute,
+		provider: (*DashboardHandler).widgetItemCount,
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "item_list", Title: "工作项列表", Description: "符合筛选条件的任务、Bug或需求，按更新时间倒序",
+		Params: []string{"project_id", "filter", "limit"}, CacheTTL: 30 * time.Second,
+		provider: (*DashboardHandler).widgetItemList,
+	})
+	registerDashboardWidget(&dashboardWidget{
+		Name: "hours", Title: "工时统计", Description: "时间范围内按成员统计的工时",
+		Params: []string{"project_id", "range"}, DefaultRange: "week", CacheTTL: 5 * time.Minute,
+		provider: (*DashboardHandler).widgetHours,
+	})
+}
+
+// widgetRange 解析时间范围，返回 [start, end)
+func widgetRange(value string, now time.Time) (time.Time, time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := today.AddDate(0, 0, 1)
+	switch value {
+	case "7d":
+		return today.AddDate(0, 0, -6), end, true
+	case "14d":
+		return today.AddDate(0, 0, -13), end, true
+	case "30d":
+		return today.AddDate(0, 0, -29), end, true
+	case "90d":
+		return today.AddDate(0, 0, -89), end, true
+	case "week":
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday, monday.AddDate(0, 0, 7), true
+	case "month":
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return first, first.AddDate(0, 1, 0), true
+	}
+	return today, end, false
+}
+
+// errWidgetForbidden 无权访问组件参数中的项目
+var errWidgetForbidden = errors.New("无权访问该项目")
+
+// validateWidgetInstances 校验组件实例并为缺少ID的实例分配ID
+func validateWidgetInstances(instances []dashboardWidgetInstance) error {
+	used := make(map[string]bool, len(instances))
+	for i := range instances {
+		widget := dashboardWidgetRegistry[instances[i].Widget]
+		if widget == nil {
+			return fmt.Errorf("未知的组件：%s", instances[i].Widget)
+		}
+		params := instances[i].Params
+		if params.Range != "" {
+			if _, _, ok := widgetRange(params.Range, time.Now()); !ok {
+				return fmt.Errorf("无效的时间范围：%s", params.Range)
+			}
+		}
+		if params.Filter != nil && params.Filter.Type != "task" && params.Filter.Type != "bug" && params.Filter.Type != "requirement" {
+			return errors.New("筛选条件的类型必须是 task、bug 或 requirement")
+		}
+		if (widget.Name == "item_count" || widget.Name == "item_list") && params.Filter == nil {
+			return fmt.Errorf("组件 %s 需要筛选条件", widget.Name)
+		}
+		if instances[i].ID == "" || used[instances[i].ID] {
+			for n := i + 1; ; n++ {
+				if id := fmt.Sprintf("w%d", n); !used[id] {
+					instances[i].ID = id
+					break
+				}
+			}
+		}
+		used[instances[i].ID] = true
+	}
+	return nil
+}
+
+// parseWidgetInstances 从 JSON 解析组件实例
+func parseWidgetInstances(value interface{}) ([]dashboardWidgetInstance, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var instances []dashboardWidgetInstance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return nil, errors.New("组件配置格式错误")
+	}
+	return instances, validateWidgetInstances(instances)
+}
+
+// widgetCacheEntry 组件缓存
+type widgetCacheEntry struct {
+	data       interface{}
+	computedAt time.Time
+	expiresAt  time.Time
+}
+
+// widgetCache 按组件、用户和参数缓存组件数据
+type widgetCache struct {
+	mu      sync.Mutex
+	entries map[string]widgetCacheEntry
+}
+
+func newWidgetCache() *widgetCache {
+	return &widgetCache{entries: make(map[string]widgetCacheEntry)}
+}
+
+func (c *widgetCache) get(key string, now time.Time) (widgetCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		return entry, false
+	}
+	return entry, true
+}
+
+func (c *widgetCache) set(key string, entry widgetCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 写入时顺带清理过期的缓存
+	if len(c.entries) >= 1000 {
+		for k, e := range c.entries {
+			if entry.computedAt.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = entry
+}
+
+// renderWidget 计算组件实例的数据；refresh 为 true 时忽略缓存
+func (h *DashboardHandler) renderWidget(c *gin.Context, instance dashboardWidgetInstance, refresh bool) gin.H {
+	result := gin.H{"id": instance.ID, "widget": instance.Widget, "title": instance.Title, "params": instance.Params, "layout": instance.Layout}
+	widget := dashboardWidgetRegistry[instance.Widget]
+	if widget == nil {
+		result["error"] = "未知的组件"
+		return result
+	}
+	if instance.Title == "" {
+		result["title"] = widget.Title
+	}
+
+	userID := utils.GetUserID(c)
+	req := &dashboardWidgetRequest{UserID: userID, Params: instance.Params}
+	if req.Params.ProjectID != nil {
+		if !utils.CheckProjectAccess(h.db, c, *req.Params.ProjectID) {
+			result["error"] = errWidgetForbidden.Error()
+			return result
+		}
+		req.ProjectIDs = []uint{*req.Params.ProjectID}
+	} else if !utils.IsAdmin(c) {
+		req.ProjectIDs = utils.GetUserProjectIDs(h.db, userID)
+	}
+	rangeValue := req.Params.Range
+	if rangeValue == "" {
+		rangeValue = widget.DefaultRange
+	}
+	now := time.Now()
+	req.Start, req.End, _ = widgetRange(rangeValue, now)
+
+	params, _ := json.Marshal(req.Params)
+	key := fmt.Sprintf("%s|%d|%t|%s", widget.Name, userID, utils.IsAdmin(c), params)
+	if !refresh {
+		if entry, ok := h.cache.get(key, now); ok {
+			result["data"], result["cached"], result["computed_at"] = entry.data, true, entry.computedAt
+			return result
+		}
+	}
+
+	data, err := widget.provider(h, c, req)
+	if err != nil {
+		result["error"] = err.Error()
+		return result
+	}
+	h.cache.set(key, widgetCacheEntry{data: data, computedAt: now, expiresAt: now.Add(widget.CacheTTL)})
+	result["data"], result["cached"], result["computed_at"] = data, false, now
+	return result
+}
+
+// renderWidgets 计算仪表板的所有组件
+func (h *DashboardHandler) renderWidgets(c *gin.Context, instances []dashboardWidgetInstance) []gin.H {
+	refresh := c.Query("refresh") == "1"
+	results := make([]gin.H, 0, len(instances))
+	for _, instance := range instances {
+		results = append(results, h.renderWidget(c, instance, refresh))
+	}
+	return results
+}
+
+// widgetItemQuery 按筛选条件构造任务、Bug或需求的查询（已按用户可见范围过滤）
+func (h *DashboardHandler) widgetItemQuery(c *gin.Context, req *dashboardWidgetRequest) (*gorm.DB, string) {
+	filter := req.Params.Filter
+	var query *gorm.DB
+	table := ""
+	switch filter.Type {
+	case "task":
+		table = "tasks"
+		query = utils.FilterTasksByUser(h.db, c, h.db.Model(&model.Task{}))
+	case "bug":
+		table = "bugs"
+		query = utils.FilterBugsByUser(h.db, c, h.db.Model(&model.Bug{}))
+	default:
+		table = "requirements"
+		query = utils.FilterRequirementsByUser(h.db, c, h.db.Model(&model.Requirement{}))
+	}
+	if req.Params.ProjectID != nil {
+		query = query.Where(table+".project_id = ?", *req.Params.ProjectID)
+	}
+	if len(filter.Status) > 0 {
+		query = query.Where(table+".status IN ?", filter.Status)
+	}
+	if len(filter.Priority) > 0 {
+		query = query.Where(table+".priority IN ?", filter.Priority)
+	}
+	if filter.Assignee == "me" {
+		if filter.Type == "bug" {
+			query = query.Where("EXISTS (SELECT 1 FROM bug_assignees WHERE bug_assignees.bug_id = bugs.id AND bug_assignees.user_id = ?)", req.UserID)
+		} else {
+			query = query.Where(table+".assignee_id = ?", req.UserID)
+		}
+	}
+	if filter.Creator == "me" {
+		query = query.Where(table+".creator_id = ?", req.UserID)
+	}
+	if filter.Keyword != "" {
+		query = query.Where(table+".title LIKE ?", "%"+filter.Keyword+"%")
+	}
+	return query, table
+}
+
+// widgetItemCount 符合筛选条件的工作项按状态统计
+func (h *DashboardHandler) widgetItemCount(c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+	query, table := h.widgetItemQuery(c, req)
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	if err := query.Select(table + ".status AS status, COUNT(*) AS count").Group(table + ".status").Scan(&rows).Error; err != nil {
+		return nil, errors.New("统计失败")
+	}
+	byStatus := make(map[string]int, len(rows))
+	total := 0
+	for _, row := range rows {
+		byStatus[row.Status] = row.Count
+		total += row.Count
+	}
+	return gin.H{"type": req.Params.Filter.Type, "total": total, "by_status": byStatus}, nil
+}
+
+// widgetItemList 符合筛选条件的工作项列表
+func (h *DashboardHandler) widgetItemList(c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+	limit := req.Params.Limit
+	if limit <= 0 {
+		limit = 10
+	} else if limit > 50 {
+		limit = 50
+	}
+	query, table := h.widgetItemQuery(c, req)
+	var total int64
+	query.Count(&total)
+	items := make([]struct {
+		ID        uint      `json:"id"`
+		Title     string    `json:"title"`
+		Status    string    `json:"status"`
+		Priority  string    `json:"priority"`
+		ProjectID uint      `json:"project_id"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}, 0)
+	if err := query.Select(table + ".id, " + table + ".title, " + table + ".status, " + table + ".priority, " + table + ".project_id, " + table + ".updated_at").
+		Order(table + ".updated_at DESC").Limit(limit).Scan(&items).Error; err != nil {
+		return nil, errors.New("查询失败")
+	}
+	return gin.H{"type": req.Params.Filter.Type, "total": total, "list": items}, nil
+}
+
+// widgetTrends 项目每日趋势
+func (h *DashboardHandler) widgetTrends(c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+	projectIDs := req.ProjectIDs
+	if projectIDs == nil {
+		projectIDs = utils.GetUserProjectIDs(h.db, req.UserID)
+	}
+	series, err := utils.ProjectMetricSeries(h.db, c, projectIDs, req.Start, req.End.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, errors.New("获取趋势失败")
+	}
+	result := make([]gin.H, 0, len(series))
+	for _, day := range series {
+		result = append(result, gin.H{
+			"date":              day.Date,
+			"open_tasks":        day.OpenCount("task"),
+			"open_bugs":         day.OpenCount("bug"),
+			"open_requirements": day.OpenCount("requirement"),
+			"remaining_hours":   day.Get("task", "hours", "remaining"),
+			"completed_hours":   day.Get("task", "hours", "completed"),
+		})
+	}
+	return result, nil
+}
+
+// widgetHours 时间范围内按成员统计的工时
+func (h *DashboardHandler) widgetHours(c *gin.Context, req *dashboardWidgetRequest) (interface{}, error) {
+	query := h.db.Model(&model.ResourceAllocation{}).
+		Joins("JOIN resources ON resource_allocations.resource_id = resources.id").
+		Joins("JOIN users ON resources.user_id = users.id").
+		Where("resource_allocations.date >= ? AND resource_allocations.date < ?", req.Start, req.End)
+	if req.ProjectIDs != nil {
+		query = query.Where("resources.project_id IN ?", req.ProjectIDs)
+	}
+	members := make([]struct {
+		UserID   uint    `json:"user_id"`
+		Username string  `json:"username"`
+		Nickname string  `json:"nickname"`
+		Hours    float64 `json:"hours"`
+	}, 0)
+	if err := query.Select("users.id AS user_id, users.username, users.nickname, SUM(resource_allocations.hours) AS hours").
+		Group("users.id, users.username, users.nickname").Scan(&members).Error; err != nil {
+		return nil, errors.New("统计工时失败")
+	}
+	sort.SliceStable(members, func(i, j int) bool { return members[i].Hours > members[j].Hours })
+	total := 0.0
+	for _, member := range members {
+		total += member.Hours
+	}
+	return gin.H{
+		"start_date": req.Start.Format("2006-01-02"),
+		"end_date":   req.End.AddDate(0, 0, -1).Format("2006-01-02"),
+		"total":      total,
+		"members":    members,
+	}, nil
+}
+
+// GetDashboardWidgets 获取可用的组件列表
+func (h *DashboardHandler) GetDashboardWidgets(c *gin.Context) {
+	widgets := make([]*dashboardWidget, 0, len(dashboardWidgetRegistry))
+	for _, widget := range dashboardWidgetRegistry {
+		widgets = append(widgets, widget)
+	}
+	sort.Slice(widgets, func(i, j int) bool { return widgets[i].Name < widgets[j].Name })
+	utils.Success(c, widgets)
+}
+
+// PreviewDashboardWidget 计算单个组件实例的数据（用于配置时预览和单独刷新）
+func (h *DashboardHandler) PreviewDashboardWidget(c *gin.Context) {
+	var instance dashboardWidgetInstance
+	if err := c.ShouldBindJSON(&instance); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	instances := []dashboardWidgetInstance{instance}
+	if err := validateWidgetInstances(instances); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	utils.Success(c, h.renderWidget(c, instances[0], c.Query("refresh") == "1"))
+}
@@ -105,7 +105,7 @@ func (h *DepartmentHandler) buildDepartmentTree(departments []model.Department,
 func (h *DepartmentHandler) GetDepartment(c *gin.Context) {
 	id := c.Param("id")
 	var department model.Department
-	if err := h.db.Preload("Parent").Preload("Children").First(&department, id).Error; err != nil {
+	if err := h.db.Preload("Parent").Preload("Children").Preload("Leader").First(&department, id).Error; err != nil {
 		utils.Error(c, 404, "部门不存在")
 		return
 	}
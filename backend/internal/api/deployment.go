@@ -0,0 +1,639 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// defaultEnvironments 初始化项目时创建的默认部署环境
+var defaultEnvironments = []model.Environment{
+	{Name: "开发环境", Code: "dev", Sort: 1},
+	{Name: "测试环境", Code: "test", Sort: 2},
+	{Name: "预发布环境", Code: "staging", Sort: 3},
+	{Name: "生产环境", Code: "prod", Sort: 4},
+}
+
+type DeploymentHandler struct {
+	db *gorm.DB
+}
+
+func NewDeploymentHandler(db *gorm.DB) *DeploymentHandler {
+	return &DeploymentHandler{db: db}
+}
+
+// ciProject 获取API令牌所属的项目，并检查令牌创建人是否仍可访问该项目
+func ciProject(db *gorm.DB, c *gin.Context) (*model.Project, bool) {
+	projectID, _ := c.Get("api_token_project_id")
+	var project model.Project
+	if err := db.First(&project, projectID).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return nil, false
+	}
+	if !utils.CheckProjectAccess(db, c, project.ID) {
+		utils.Error(c, 403, "令牌创建人没有权限访问该项目")
+		return nil, false
+	}
+	return &project, true
+}
+
+// currentDeployments 获取各环境当前（最近一次成功）的部署记录，环境ID -> 部署记录
+func currentDeployments(db *gorm.DB, environmentIDs []uint) map[uint]*model.Deployment {
+	current := make(map[uint]*model.Deployment)
+	if len(environmentIDs) == 0 {
+		return current
+	}
+	var deployments []model.Deployment
+	db.Preload("Build").Preload("Build.Version").Preload("Deployer").
+		Where("environment_id IN ? AND status = ?", environmentIDs, "success").
+		Order("deployed_at DESC, id DESC").Find(&deployments)
+	for i := range deployments {
+		if _, exists := current[deployments[i].EnvironmentID]; !exists {
+			current[deployments[i].EnvironmentID] = &deployments[i]
+		}
+	}
+	return current
+}
+
+// createBuild 在版本下登记构建，同一版本内构建号不能重复
+func (h *DeploymentHandler) createBuild(tx *gorm.DB, version *model.Version, build *model.Build) error {
+	var count int64
+	tx.Model(&model.Build{}).Where("version_id = ? AND build_number = ?", version.ID, build.BuildNumber).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("版本 %s 中已存在构建 %s", version.VersionNumber, build.BuildNumber)
+	}
+	build.VersionID = version.ID
+	build.ProjectID = version.ProjectID
+	return tx.Create(build).Error
+}
+
+// recordDeployment 创建部署记录并记录到版本的操作历史
+func (h *DeploymentHandler) recordDeployment(c *gin.Context, environment *model.Environment, build *model.Build, status, comment string, deployedAt time.Time) (*model.Deployment, error) {
+	deployment := model.Deployment{
+		Status:        status,
+		DeployedAt:    deployedAt,
+		Comment:       comment,
+		ProjectID:     environment.ProjectID,
+		EnvironmentID: environment.ID,
+		BuildID:       build.ID,
+		VersionID:     build.VersionID,
+		DeployerID:    utils.GetUserID(c),
+	}
+	if err := h.db.Create(&deployment).Error; err != nil {
+		return nil, err
+	}
+	action := "deployed"
+	if status == "failed" {
+		action = "deploy_failed"
+	}
+	utils.RecordAction(h.db, "version", build.VersionID, action, deployment.DeployerID,
+		fmt.Sprintf("构建 %s 部署到%s", build.BuildNumber, environment.Name),
+		gin.H{"deployment_id": deployment.ID, "environment_id": environment.ID, "build_id": build.ID})
+
+	h.db.Preload("Environment").Preload("Build").Preload("Deployer").First(&deployment, deployment.ID)
+	return &deployment, nil
+}
+
+// isValidDeploymentStatus 检查部署结果是否合法
+func isValidDeploymentStatus(status string) bool {
+	return status == "success" || status == "failed"
+}
+
+// GetVersionBuilds 获取版本的构建列表
+func (h *DeploymentHandler) GetVersionBuilds(c *gin.Context) {
+	var version model.Version
+	if err := h.db.First(&version, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "版本不存在")
+		return
+	}
+	var builds []model.Build
+	h.db.Preload("Creator").Preload("Attachments").Where("version_id = ?", version.ID).Order("created_at DESC, id DESC").Find(&builds)
+	utils.Success(c, builds)
+}
+
+// CreateBuild 在版本下手动登记构建
+func (h *DeploymentHandler) CreateBuild(c *gin.Context) {
+	var version model.Version
+	if err := h.db.First(&version, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "版本不存在")
+		return
+	}
+
+	var req struct {
+		BuildNumber   string `json:"build_number" binding:"required"`
+		CommitSHA     string `json:"commit_sha"`
+		Branch        string `json:"branch"`
+		Notes         string `json:"notes"`
+		AttachmentIDs []uint `json:"attachment_ids"` // 构建产物附件ID列表
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	var attachments []model.Attachment
+	if len(req.AttachmentIDs) > 0 {
+		h.db.Where("id IN ?", req.AttachmentIDs).Find(&attachments)
+		if len(attachments) != len(req.AttachmentIDs) {
+			utils.Error(c, 400, "部分附件不存在")
+			return
+		}
+	}
+
+	build := model.Build{
+		BuildNumber: strings.TrimSpace(req.BuildNumber),
+		CommitSHA:   strings.TrimSpace(req.CommitSHA),
+		Branch:      strings.TrimSpace(req.Branch),
+		Notes:       req.Notes,
+		Source:      "manual",
+		CreatorID:   utils.GetUserID(c),
+		Attachments: attachments,
+	}
+	if err := h.createBuild(h.db, &version, &build); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	h.db.Preload("Creator").Preload("Attachments").First(&build, build.ID)
+	utils.Success(c, build)
+}
+
+// GetBuild 获取构建详情（包含部署记录）
+func (h *DeploymentHandler) GetBuild(c *gin.Context) {
+	var build model.Build
+	if err := h.db.Preload("Version").Preload("Creator").Preload("Attachments").First(&build, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "构建不存在")
+		return
+	}
+	var deployments []model.Deployment
+	h.db.Preload("Environment").Preload("Deployer").Where("build_id = ?", build.ID).Order("deployed_at DESC, id DESC").Find(&deployments)
+	utils.Success(c, gin.H{"build": build, "deployments": deployments})
+}
+
+// DeleteBuild 删除构建（已有部署记录的构建不能删除）
+func (h *DeploymentHandler) DeleteBuild(c *gin.Context) {
+	var build model.Build
+	if err := h.db.First(&build, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "构建不存在")
+		return
+	}
+	var count int64
+	h.db.Model(&model.Deployment{}).Where("build_id = ?", build.ID).Count(&count)
+	if count > 0 {
+		utils.Error(c, 400, "构建已有部署记录，不能删除")
+		return
+	}
+	if err := h.db.Delete(&build).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// GetEnvironments 获取项目的部署环境列表及各环境当前部署的构建
+func (h *DeploymentHandler) GetEnvironments(c *gin.Context) {
+	var environments []model.Environment
+	h.db.Where("project_id = ?", c.Param("id")).Order("sort ASC, id ASC").Find(&environments)
+
+	ids := make([]uint, 0, len(environments))
+	for _, environment := range environments {
+		ids = append(ids, environment.ID)
+	}
+	current := currentDeployments(h.db, ids)
+
+	list := make([]gin.H, 0, len(environments))
+	for _, environment := range environments {
+		list = append(list, gin.H{"environment": environment, "current_deployment": current[environment.ID]})
+	}
+	utils.Success(c, list)
+}
+
+// CreateEnvironment 创建部署环境；init 为 true 时创建默认环境（dev, test, staging, prod），已存在的编码跳过
+func (h *DeploymentHandler) CreateEnvironment(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	var req struct {
+		Init        bool   `json:"init"`
+		Name        string `json:"name"`
+		Code        string `json:"code"`
+		Description string `json:"description"`
+		Sort        int    `json:"sort"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	environments := []model.Environment{{Name: strings.TrimSpace(req.Name), Code: strings.TrimSpace(req.Code), Description: req.Description, Sort: req.Sort}}
+	if req.Init {
+		environments = defaultEnvironments
+	} else if environments[0].Name == "" || environments[0].Code == "" {
+		utils.Error(c, 400, "环境名称和编码不能为空")
+		return
+	}
+
+	created := make([]model.Environment, 0, len(environments))
+	for _, environment := range environments {
+		var count int64
+		h.db.Model(&model.Environment{}).Where("project_id = ? AND code = ?", project.ID, environment.Code).Count(&count)
+		if count > 0 {
+			if req.Init {
+				continue
+			}
+			utils.Error(c, 400, "环境编码已存在")
+			return
+		}
+		environment.ProjectID = project.ID
+		if err := h.db.Create(&environment).Error; err != nil {
+			utils.Error(c, utils.CodeError, "创建环境失败")
+			return
+		}
+		created = append(created, environment)
+	}
+	utils.Success(c, created)
+}
+
+// UpdateEnvironment 更新部署环境
+func (h *DeploymentHandler) UpdateEnvironment(c *gin.Context) {
+	var environment model.Environment
+	if err := h.db.First(&environment, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "环境不存在")
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name"`
+		Code        *string `json:"code"`
+		Description *string `json:"description"`
+		Sort        *int    `json:"sort"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		environment.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Code != nil && strings.TrimSpace(*req.Code) != "" && strings.TrimSpace(*req.Code) != environment.Code {
+		var count int64
+		h.db.Model(&model.Environment{}).Where("project_id = ? AND code = ? AND id <> ?", environment.ProjectID, strings.TrimSpace(*req.Code), environment.ID).Count(&count)
+		if count > 0 {
+			utils.Error(c, 400, "环境编码已存在")
+			return
+		}
+		environment.Code = strings.TrimSpace(*req.Code)
+	}
+	if req.Description != nil {
+		environment.Description = *req.Description
+	}
+	if req.Sort != nil {
+		environment.Sort = *req.Sort
+	}
+
+	if err := h.db.Save(&environment).Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新环境失败")
+		return
+	}
+	utils.Success(c, environment)
+}
+
+// DeleteEnvironment 删除部署环境（部署记录保留）
+func (h *DeploymentHandler) DeleteEnvironment(c *gin.Context) {
+	if err := h.db.Delete(&model.Environment{}, c.Param("id")).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// CreateDeployment 记录构建部署到环境
+func (h *DeploymentHandler) CreateDeployment(c *gin.Context) {
+	var environment model.Environment
+	if err := h.db.First(&environment, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "环境不存在")
+		return
+	}
+
+	var req struct {
+		BuildID    uint    `json:"build_id" binding:"required"`
+		Status     string  `json:"status"`
+		Comment    string  `json:"comment"`
+		DeployedAt *string `json:"deployed_at"` // 部署时间（RFC3339），为空表示当前时间
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if req.Status == "" {
+		req.Status = "success"
+	}
+	if !isValidDeploymentStatus(req.Status) {
+		utils.Error(c, 400, "无效的部署结果，有效值：success, failed")
+		return
+	}
+	deployedAt := time.Now()
+	if req.DeployedAt != nil && *req.DeployedAt != "" {
+		parsed, err := time.Parse(time.RFC3339, *req.DeployedAt)
+		if err != nil {
+			utils.Error(c, 400, "部署时间格式错误，应为RFC3339格式")
+			return
+		}
+		deployedAt = parsed
+	}
+
+	var build model.Build
+	if err := h.db.Where("project_id = ?", environment.ProjectID).First(&build, req.BuildID).Error; err != nil {
+		utils.Error(c, 404, "构建不存在或不属于该项目")
+		return
+	}
+
+	deployment, err := h.recordDeployment(c, &environment, &build, req.Status, req.Comment, deployedAt)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "记录部署失败")
+		return
+	}
+	utils.Success(c, deployment)
+}
+
+// GetDeployments 获取项目的部署记录，可按环境、版本、构建筛选
+func (h *DeploymentHandler) GetDeployments(c *gin.Context) {
+	query := h.db.Model(&model.Deployment{}).Where("project_id = ?", c.Param("id"))
+	if environmentID := c.Query("environment_id"); environmentID != "" {
+		query = query.Where("environment_id = ?", environmentID)
+	}
+	if versionID := c.Query("version_id"); versionID != "" {
+		query = query.Where("version_id = ?", versionID)
+	}
+	if buildID := c.Query("build_id"); buildID != "" {
+		query = query.Where("build_id = ?", buildID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	page := utils.GetPage(c)
+	pageSize := utils.GetPageSize(c)
+	var deployments []model.Deployment
+	query.Preload("Environment").Preload("Build").Preload("Build.Version").Preload("Deployer").
+		Order("deployed_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&deployments)
+
+	utils.Success(c, gin.H{
+		"list":      deployments,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// parseVersionNumber 解析版本号开头的数字段（忽略前缀 v 和后缀，如 v1.2.3-hotfix -> [1 2 3]），无法解析时返回 false
+func parseVersionNumber(number string) ([]int, bool) {
+	number = strings.TrimLeft(strings.TrimSpace(number), "vV")
+	var segments []int
+	for _, part := range strings.Split(number, ".") {
+		digits := 0
+		for digits < len(part) && part[digits] >= '0' && part[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 {
+			break
+		}
+		value, _ := strconv.Atoi(part[:digits])
+		segments = append(segments, value)
+		if digits < len(part) {
+			break
+		}
+	}
+	return segments, len(segments) > 0
+}
+
+// compareVersionNumbers 按数字段比较版本号，缺少的段按 0 处理
+func compareVersionNumbers(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionContainsFix 判断部署的版本是否包含在解决版本中完成的修复：
+// 部署的就是解决版本，或者部署的版本号不低于解决版本号（如 1.0.1 不包含 2.0 中的修复）。
+// Bug 关联的版本是发现问题的版本，不表示包含修复；版本号无法解析时只按第一种情况判断
+func versionContainsFix(deployed *model.Version, resolved *model.Version) bool {
+	if deployed == nil || resolved == nil {
+		return false
+	}
+	if deployed.ID == resolved.ID {
+		return true
+	}
+	deployedNumber, ok := parseVersionNumber(deployed.VersionNumber)
+	if !ok {
+		return false
+	}
+	resolvedNumber, ok := parseVersionNumber(resolved.VersionNumber)
+	if !ok {
+		return false
+	}
+	return compareVersionNumbers(deployedNumber, resolvedNumber) >= 0
+}
+
+// GetBugEnvironments 查询哪些环境已部署了包含Bug修复的构建
+// 是否包含修复按部署的版本判断（见 versionContainsFix），不依赖版本的创建顺序
+func (h *DeploymentHandler) GetBugEnvironments(c *gin.Context) {
+	var bug model.Bug
+	if err := h.db.Preload("ResolvedVersion").First(&bug, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "Bug不存在")
+		return
+	}
+	if !utils.CheckBugAccess(h.db, c, bug.ID) {
+		utils.Error(c, 403, "没有权限访问该Bug")
+		return
+	}
+
+	var environments []model.Environment
+	h.db.Where("project_id = ?", bug.ProjectID).Order("sort ASC, id ASC").Find(&environments)
+	ids := make([]uint, 0, len(environments))
+	for _, environment := range environments {
+		ids = append(ids, environment.ID)
+	}
+	current := currentDeployments(h.db, ids)
+
+	// 各环境的成功部署按时间正序排列，用于查找包含修复的构建首次部署的时间
+	history := make(map[uint][]model.Deployment)
+	if bug.ResolvedVersion != nil && len(ids) > 0 {
+		var deployments []model.Deployment
+		h.db.Preload("Build").Preload("Build.Version").
+			Where("environment_id IN ? AND status = ?", ids, "success").
+			Order("deployed_at ASC, id ASC").Find(&deployments)
+		for _, deployment := range deployments {
+			history[deployment.EnvironmentID] = append(history[deployment.EnvironmentID], deployment)
+		}
+	}
+
+	list := make([]gin.H, 0, len(environments))
+	for _, environment := range environments {
+		item := gin.H{"environment": environment, "current_deployment": current[environment.ID], "contains_fix": false, "fix_deployed_at": nil}
+		if bug.ResolvedVersion != nil {
+			if deployment := current[environment.ID]; deployment != nil {
+				item["contains_fix"] = versionContainsFix(&deployment.Build.Version, bug.ResolvedVersion)
+			}
+			for _, deployment := range history[environment.ID] {
+				if versionContainsFix(&deployment.Build.Version, bug.ResolvedVersion) {
+					item["fix_deployed_at"] = deployment.DeployedAt
+					break
+				}
+			}
+		}
+		list = append(list, item)
+	}
+
+	utils.Success(c, gin.H{
+		"bug_id":           bug.ID,
+		"status":           bug.Status,
+		"resolved_version": bug.ResolvedVersion,
+		"environments":     list,
+	})
+}
+
+// CIRegisterBuild CI登记构建：version_id 或 version（不存在时创建）指定版本，
+// 同一版本中构建号已存在时更新提交信息并追加上传的构建产物（multipart 的 artifacts 字段）
+func (h *DeploymentHandler) CIRegisterBuild(c *gin.Context) {
+	project, ok := ciProject(h.db, c)
+	if !ok {
+		return
+	}
+	param := func(key string) string {
+		if value := c.Query(key); value != "" {
+			return value
+		}
+		return strings.TrimSpace(c.PostForm(key))
+	}
+	buildNumber := param("build_number")
+	if buildNumber == "" {
+		utils.Error(c, 400, "构建号不能为空")
+		return
+	}
+
+	version, versionCreated, err := resolveCIVersion(h.db, project.ID, param("version_id"), param("version"), true)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	var build model.Build
+	created := false
+	err = h.db.Where("version_id = ? AND build_number = ?", version.ID, buildNumber).First(&build).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		build = model.Build{BuildNumber: buildNumber, CommitSHA: param("commit_sha"), Branch: param("branch"), Notes: param("notes"), Source: "ci", CreatorID: utils.GetUserID(c)}
+		if err := h.createBuild(h.db, version, &build); err != nil {
+			utils.Error(c, utils.CodeError, "登记构建失败")
+			return
+		}
+		created = true
+	case err != nil:
+		utils.Error(c, utils.CodeError, "查询构建失败")
+		return
+	default:
+		updates := map[string]interface{}{}
+		for _, key := range []string{"commit_sha", "branch", "notes"} {
+			if value := param(key); value != "" {
+				updates[key] = value
+			}
+		}
+		if len(updates) > 0 {
+			h.db.Model(&build).Updates(updates)
+		}
+	}
+
+	// 构建产物
+	if form, err := c.MultipartForm(); err == nil {
+		for _, file := range form.File["artifacts"] {
+			attachment, code, err := saveUploadedAttachment(h.db, c, file, project)
+			if err != nil {
+				utils.Error(c, code, err.Error())
+				return
+			}
+			if err := h.db.Model(&build).Association("Attachments").Append(attachment); err != nil {
+				utils.Error(c, utils.CodeError, "关联构建产物失败")
+				return
+			}
+		}
+	}
+
+	h.db.Preload("Attachments").First(&build, build.ID)
+	utils.Success(c, gin.H{"build": build, "created": created, "version_id": version.ID, "version_created": versionCreated})
+}
+
+// CIRecordDeployment CI记录部署：environment 为环境编码，version_id 或 version 与 build_number 指定已登记的构建
+func (h *DeploymentHandler) CIRecordDeployment(c *gin.Context) {
+	project, ok := ciProject(h.db, c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Environment string `json:"environment" binding:"required"`
+		VersionID   string `json:"version_id"`
+		Version     string `json:"version"`
+		BuildNumber string `json:"build_number" binding:"required"`
+		Status      string `json:"status"`
+		Comment     string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if req.Status == "" {
+		req.Status = "success"
+	}
+	if !isValidDeploymentStatus(req.Status) {
+		utils.Error(c, 400, "无效的部署结果，有效值：success, failed")
+		return
+	}
+
+	var environment model.Environment
+	if err := h.db.Where("project_id = ? AND code = ?", project.ID, req.Environment).First(&environment).Error; err != nil {
+		utils.Error(c, 404, "环境不存在："+req.Environment)
+		return
+	}
+	version, _, err := resolveCIVersion(h.db, project.ID, req.VersionID, req.Version, false)
+	if err != nil {
+		utils.Error(c, 404, err.Error())
+		return
+	}
+	var build model.Build
+	if err := h.db.Where("version_id = ? AND build_number = ?", version.ID, req.BuildNumber).First(&build).Error; err != nil {
+		utils.Error(c, 404, "构建不存在："+req.BuildNumber)
+		return
+	}
+
+	deployment, err := h.recordDeployment(c, &environment, &build, req.Status, req.Comment, time.Now())
+	if err != nil {
+		utils.Error(c, utils.CodeError, "记录部署失败")
+		return
+	}
+	utils.Success(c, deployment)
+}
@@ -0,0 +1,334 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+const maxGitWebhookPayload = 5 * 1024 * 1024
+
+type GitWebhookHandler struct {
+	db *gorm.DB
+}
+
+func NewGitWebhookHandler(db *gorm.DB) *GitWebhookHandler {
+	return &GitWebhookHandler{db: db}
+}
+
+// gitLinkedItem Webhook 事件中引用到的工作项
+type gitLinkedItem struct {
+	ObjectType   string `json:"object_type"`
+	ObjectID     uint   `json:"object_id"`
+	Kind         string `json:"kind"`
+	Ref          string `json:"ref"`
+	Transitioned string `json:"transitioned,omitempty"` // 自动变更后的状态
+}
+
+// GetProjectWebhooks 获取项目的 Git Webhook 列表
+func (h *GitWebhookHandler) GetProjectWebhooks(c *gin.Context) {
+	var webhooks []model.GitWebhook
+	h.db.Preload("Creator").Where("project_id = ?", c.Param("id")).Order("id ASC").Find(&webhooks)
+	utils.Success(c, webhooks)
+}
+
+// CreateProjectWebhook 创建 Git Webhook，返回接收地址和密钥（密钥只返回这一次）
+func (h *GitWebhookHandler) CreateProjectWebhook(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	var req struct {
+		Name           string `json:"name" binding:"required"`
+		Provider       string `json:"provider"`
+		Secret         string `json:"secret"` // 为空时自动生成
+		AutoTransition *bool  `json:"auto_transition"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	switch req.Provider {
+	case "", utils.GitProviderGitHub, utils.GitProviderGitLab, utils.GitProviderGitea:
+	default:
+		utils.Error(c, 400, "无效的托管平台，有效值：github, gitlab, gitea")
+		return
+	}
+
+	secret := strings.TrimSpace(req.Secret)
+	if secret == "" {
+		buf := make([]byte, 20)
+		if _, err := rand.Read(buf); err != nil {
+			utils.Error(c, utils.CodeError, "生成密钥失败")
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	webhook := model.GitWebhook{
+		Name:           strings.TrimSpace(req.Name),
+		Provider:       req.Provider,
+		Secret:         secret,
+		AutoTransition: req.AutoTransition == nil || *req.AutoTransition,
+		ProjectID:      project.ID,
+		CreatorID:      utils.GetUserID(c),
+	}
+	if err := h.db.Create(&webhook).Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建Webhook失败")
+		return
+	}
+	// AutoTransition 默认值为 true，显式关闭时需要单独更新
+	if !webhook.AutoTransition {
+		h.db.Model(&webhook).Update("auto_transition", false)
+	}
+
+	utils.SuccessWithMessage(c, "请在代码仓库中配置Webhook地址和密钥，密钥只显示这一次", gin.H{
+		"webhook": webhook,
+		"url":     fmt.Sprintf("/api/webhooks/git/%d", webhook.ID),
+		"secret":  secret,
+	})
+}
+
+// DeleteProjectWebhook 删除 Git Webhook
+func (h *GitWebhookHandler) DeleteProjectWebhook(c *gin.Context) {
+	result := h.db.Where("id = ? AND project_id = ?", c.Param("webhook_id"), c.Param("id")).Delete(&model.GitWebhook{})
+	if result.Error != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.Error(c, 404, "Webhook不存在")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// GetCodeLinks 获取项目中工作项关联的提交和合并请求，可按 object_type 和 object_id 筛选
+func (h *GitWebhookHandler) GetCodeLinks(c *gin.Context) {
+	query := h.db.Model(&model.CodeLink{}).Where("project_id = ?", c.Param("id"))
+	if objectType := c.Query("object_type"); objectType != "" {
+		query = query.Where("object_type = ?", objectType)
+	}
+	if objectID := c.Query("object_id"); objectID != "" {
+		query = query.Where("object_id = ?", objectID)
+	}
+	var links []model.CodeLink
+	query.Order("created_at DESC, id DESC").Find(&links)
+	utils.Success(c, links)
+}
+
+// ReceiveGitWebhook 接收 GitHub/GitLab/Gitea 的推送和合并请求事件（无需登录，通过密钥校验）
+// 提交信息、合并请求标题和描述中的 bug#123、task#45、story#6 等引用会关联到工作项并记录到操作历史；
+// 带有 fix/close/resolve/修复/解决/关闭 关键字时，推送到默认分支或合并请求已合并后自动解决Bug、完成任务
+func (h *GitWebhookHandler) ReceiveGitWebhook(c *gin.Context) {
+	var webhook model.GitWebhook
+	if err := h.db.First(&webhook, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "Webhook不存在")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGitWebhookPayload+1))
+	if err != nil || len(body) > maxGitWebhookPayload {
+		utils.Error(c, 400, "读取请求失败")
+		return
+	}
+
+	provider := utils.DetectGitProvider(c.Request.Header)
+	if provider == "" || (webhook.Provider != "" && webhook.Provider != provider) {
+		utils.Error(c, 400, "不支持的Webhook来源")
+		return
+	}
+	if !utils.VerifyGitWebhook(provider, c.Request.Header, body, webhook.Secret) {
+		utils.Error(c, 401, "Webhook签名校验失败")
+		return
+	}
+
+	eventHeader := map[string]string{
+		utils.GitProviderGitHub: "X-GitHub-Event",
+		utils.GitProviderGitLab: "X-Gitlab-Event",
+		utils.GitProviderGitea:  "X-Gitea-Event",
+	}[provider]
+	event, err := utils.ParseGitWebhook(provider, c.GetHeader(eventHeader), body)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	now := time.Now()
+	h.db.Model(&webhook).Update("last_delivery_at", &now)
+
+	linked := make([]gitLinkedItem, 0)
+	switch event.Kind {
+	case utils.GitEventPush:
+		allowClose := webhook.AutoTransition && event.PushToDefaultBranch()
+		for _, commit := range event.Commits {
+			title := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+			link := model.CodeLink{
+				Kind: "commit", Ref: commit.ID, Title: truncateRunes(title, 500), URL: commit.URL, Author: commit.AuthorName,
+			}
+			comment := fmt.Sprintf("提交 %s：%s", shortSHA(commit.ID), title)
+			items := h.linkReferences(c, &webhook, event, &link, commit.AuthorEmail, utils.ParseGitReferences(commit.Message), allowClose, comment)
+			linked = append(linked, items...)
+		}
+	case utils.GitEventMergeRequest:
+		mr := event.MergeRequest
+		link := model.CodeLink{
+			Kind: "merge_request", Ref: fmt.Sprintf("%d", mr.Number), Title: truncateRunes(mr.Title, 500), URL: mr.URL, Author: mr.AuthorName, State: mr.State,
+		}
+		comment := fmt.Sprintf("合并请求 !%d：%s（%s）", mr.Number, mr.Title, mergeRequestStateName(mr.State))
+		refs := utils.ParseGitReferences(mr.Title + "\n" + mr.Description + "\n" + mr.SourceBranch)
+		linked = h.linkReferences(c, &webhook, event, &link, mr.AuthorEmail, refs, webhook.AutoTransition && mr.State == "merged", comment)
+	}
+
+	utils.Success(c, gin.H{
+		"provider": provider,
+		"event":    event.Kind,
+		"linked":   linked,
+	})
+}
+
+// linkReferences 将提交或合并请求关联到引用的工作项；已关联过的（重复推送）不再重复记录
+func (h *GitWebhookHandler) linkReferences(c *gin.Context, webhook *model.GitWebhook, event *utils.GitEvent, template *model.CodeLink,
+	authorEmail string, refs []utils.GitReference, allowClose bool, comment string) []gitLinkedItem {
+	linked := make([]gitLinkedItem, 0)
+	if len(refs) == 0 {
+		return linked
+	}
+	actorID := h.gitActor(webhook, authorEmail)
+
+	for _, ref := range refs {
+		table := workItemTable(ref.ObjectType)
+		var projectIDs []uint
+		h.db.Table(table).Where("id = ? AND deleted_at IS NULL", ref.ObjectID).Pluck("project_id", &projectIDs)
+		if len(projectIDs) == 0 || projectIDs[0] != webhook.ProjectID {
+			continue // 只关联 Webhook 所属项目的工作项
+		}
+
+		link := *template
+		link.ProjectID = webhook.ProjectID
+		link.ObjectType = ref.ObjectType
+		link.ObjectID = ref.ObjectID
+		link.Repository = event.Repository
+		link.Provider = event.Provider
+		link.WebhookID = webhook.ID
+
+		var existing model.CodeLink
+		err := h.db.Where("object_type = ? AND object_id = ? AND kind = ? AND repository = ? AND ref = ?",
+			link.ObjectType, link.ObjectID, link.Kind, link.Repository, link.Ref).First(&existing).Error
+		if err == nil {
+			// 重复推送的提交忽略；合并请求只在状态变化时记录
+			if link.Kind != "merge_request" || existing.State == link.State {
+				continue
+			}
+			h.db.Model(&existing).Updates(map[string]interface{}{"state": link.State, "title": link.Title})
+		} else if err := h.db.Create(&link).Error; err != nil {
+			if utils.Logger != nil {
+				utils.Logger.Warnf("保存代码关联失败: %s#%d: %v", link.ObjectType, link.ObjectID, err)
+			}
+			continue
+		}
+
+		action := "commit_linked"
+		if link.Kind == "merge_request" {
+			action = "merge_request_linked"
+		}
+		utils.RecordAction(h.db, ref.ObjectType, ref.ObjectID, action, actorID, comment, gin.H{
+			"provider": link.Provider, "repository": link.Repository, "kind": link.Kind, "ref": link.Ref, "url": link.URL, "author": link.Author, "state": link.State,
+		})
+
+		item := gitLinkedItem{ObjectType: ref.ObjectType, ObjectID: ref.ObjectID, Kind: link.Kind, Ref: link.Ref}
+		if ref.Close && allowClose {
+			item.Transitioned = h.closeReferencedItem(c, ref, actorID, comment)
+		}
+		linked = append(linked, item)
+	}
+	return linked
+}
+
+// closeReferencedItem 根据提交或合并请求自动解决Bug、完成任务，返回变更后的状态（未变更返回空）
+// 需求的状态由评审流程控制，不自动变更
+func (h *GitWebhookHandler) closeReferencedItem(c *gin.Context, ref utils.GitReference, actorID uint, comment string) string {
+	switch ref.ObjectType {
+	case "bug":
+		var bug model.Bug
+		if err := h.db.First(&bug, ref.ObjectID).Error; err != nil || bug.Status != "active" {
+			return ""
+		}
+		if err := h.db.Model(&bug).Updates(map[string]interface{}{
+			"status": "resolved", "solution": "已解决", "solution_note": comment,
+		}).Error; err != nil {
+			return ""
+		}
+		actionID, _ := utils.RecordAction(h.db, "bug", bug.ID, "resolved", actorID, comment, nil)
+		utils.RecordHistory(h.db, actionID, []utils.HistoryChange{{Field: "status", Old: "active", New: "resolved"}})
+		notifyCardChange(h.db, c, cardEventUpdated, "bug", bug.ID, bug.ProjectID, "resolved")
+		return "resolved"
+	case "task":
+		var task model.Task
+		if err := h.db.First(&task, ref.ObjectID).Error; err != nil {
+			return ""
+		}
+		switch task.Status {
+		case "done", "closed", "cancel":
+			return ""
+		}
+		oldStatus := task.Status
+		if err := h.db.Model(&task).Updates(map[string]interface{}{"status": "done", "progress": 100}).Error; err != nil {
+			return ""
+		}
+		utils.RecordStatusChange(h.db, "task", task.ID, actorID, oldStatus, "done")
+		notifyCardChange(h.db, c, cardEventUpdated, "task", task.ID, task.ProjectID, "done")
+		return "done"
+	}
+	return ""
+}
+
+// gitActor 按提交作者邮箱匹配系统用户，匹配不到时使用 Webhook 创建人
+func (h *GitWebhookHandler) gitActor(webhook *model.GitWebhook, email string) uint {
+	if email != "" {
+		var user model.User
+		if err := h.db.Where("email = ? AND status = ?", email, 1).First(&user).Error; err == nil {
+			return user.ID
+		}
+	}
+	return webhook.CreatorID
+}
+
+// workItemTable 工作项类型对应的数据表
+func workItemTable(objectType string) string {
+	switch objectType {
+	case "bug":
+		return "bugs"
+	case "task":
+		return "tasks"
+	}
+	return "requirements"
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+func mergeRequestStateName(state string) string {
+	switch state {
+	case "merged":
+		return "已合并"
+	case "closed":
+		return "已关闭"
+	}
+	return "已创建"
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ItemAccessHandler 受限对象访问控制（保密Bug、受限需求和任务）
+type ItemAccessHandler struct {
+	db *gorm.DB
+}
+
+func NewItemAccessHandler(db *gorm.DB) *ItemAccessHandler {
+	return &ItemAccessHandler{db: db}
+}
+
+// GetBugAccess 获取Bug的保密设置和访问名单
+func (h *ItemAccessHandler) GetBugAccess(c *gin.Context) {
+	h.getAccess(c, "bug")
+}
+
+// UpdateBugAccess 更新Bug的保密设置和访问名单
+func (h *ItemAccessHandler) UpdateBugAccess(c *gin.Context) {
+	h.updateAccess(c, "bug")
+}
+
+// GetRequirementAccess 获取需求的访问限制和访问名单
+func (h *ItemAccessHandler) GetRequirementAccess(c *gin.Context) {
+	h.getAccess(c, "requirement")
+}
+
+// UpdateRequirementAccess 更新需求的访问限制和访问名单
+func (h *ItemAccessHandler) UpdateRequirementAccess(c *gin.Context) {
+	h.updateAccess(c, "requirement")
+}
+
+// GetTaskAccess 获取任务的访问限制和访问名单
+func (h *ItemAccessHandler) GetTaskAccess(c *gin.Context) {
+	h.getAccess(c, "task")
+}
+
+// UpdateTaskAccess 更新任务的访问限制和访问名单
+func (h *ItemAccessHandler) UpdateTaskAccess(c *gin.Context) {
+	h.updateAccess(c, "task")
+}
+
+// itemAccessTarget 受限对象的基本信息
+type itemAccessTarget struct {
+	ID           uint
+	Confidential bool
+}
+
+// loadTarget 加载对象并检查访问权限，失败时已写入错误响应
+func (h *ItemAccessHandler) loadTarget(c *gin.Context, objectType string) (*itemAccessTarget, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "无效的ID")
+		return nil, false
+	}
+
+	var target itemAccessTarget
+	var found, hasAccess bool
+	switch objectType {
+	case "bug":
+		var bug model.Bug
+		if found = h.db.First(&bug, id).Error == nil; found {
+			target = itemAccessTarget{ID: bug.ID, Confidential: bug.Confidential}
+			hasAccess = utils.CheckBugAccess(h.db, c, bug.ID)
+		}
+	case "requirement":
+		var requirement model.Requirement
+		if found = h.db.First(&requirement, id).Error == nil; found {
+			target = itemAccessTarget{ID: requirement.ID, Confidential: requirement.Confidential}
+			hasAccess = utils.CheckRequirementAccess(h.db, c, requirement.ID)
+		}
+	case "task":
+		var task model.Task
+		if found = h.db.First(&task, id).Error == nil; found {
+			target = itemAccessTarget{ID: task.ID, Confidential: task.Confidential}
+			hasAccess = utils.CheckTaskAccess(h.db, c, task.ID)
+		}
+	}
+
+	if !found {
+		utils.Error(c, 404, "对象不存在")
+		return nil, false
+	}
+	if !hasAccess {
+		utils.Error(c, 403, "没有权限访问该对象")
+		return nil, false
+	}
+	return &target, true
+}
+
+func (h *ItemAccessHandler) getAccess(c *gin.Context, objectType string) {
+	target, ok := h.loadTarget(c, objectType)
+	if !ok {
+		return
+	}
+
+	h.respondAccess(c, objectType, target.ID, target.Confidential)
+}
+
+// respondAccess 返回对象的访问限制和访问名单
+func (h *ItemAccessHandler) respondAccess(c *gin.Context, objectType string, objectID uint, confidential bool) {
+	var accesses []model.ItemAccess
+	if err := h.db.Preload("User").Where("object_type = ? AND object_id = ?", objectType, objectID).Order("id ASC").Find(&accesses).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	users := make([]model.User, 0)
+	roles := make([]string, 0)
+	for _, access := range accesses {
+		if access.User != nil {
+			users = append(users, *access.User)
+		}
+		if access.RoleCode != "" {
+			roles = append(roles, access.RoleCode)
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"confidential": confidential,
+		"users":        users,
+		"role_codes":   roles,
+	})
+}
+
+func (h *ItemAccessHandler) updateAccess(c *gin.Context, objectType string) {
+	target, ok := h.loadTarget(c, objectType)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Confidential *bool    `json:"confidential"` // 为空时保持原有的受限标记，只更新访问名单
+		UserIDs      []uint   `json:"user_ids"`
+		RoleCodes    []string `json:"role_codes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	if len(req.UserIDs) > 0 {
+		var count int64
+		h.db.Model(&model.User{}).Where("id IN ?", req.UserIDs).Count(&count)
+		if int(count) != len(uniqueUints(req.UserIDs)) {
+			utils.Error(c, 400, "用户不存在")
+			return
+		}
+	}
+
+	userID := utils.GetUserID(c)
+	var oldUserIDs []uint
+	h.db.Model(&model.ItemAccess{}).Where("object_type = ? AND object_id = ? AND user_id IS NOT NULL", objectType, target.ID).Pluck("user_id", &oldUserIDs)
+	var oldRoleCodes []string
+	h.db.Model(&model.ItemAccess{}).Where("object_type = ? AND object_id = ? AND role_code <> ''", objectType, target.ID).Pluck("role_code", &oldRoleCodes)
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	confidential := target.Confidential
+	if req.Confidential != nil {
+		confidential = *req.Confidential
+		table := map[string]string{"bug": "bugs", "requirement": "requirements", "task": "tasks"}[objectType]
+		if err := tx.Table(table).Where("id = ?", target.ID).Update("confidential", confidential).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "更新失败")
+			return
+		}
+	}
+
+	if err := tx.Where("object_type = ? AND object_id = ?", objectType, target.ID).Delete(&model.ItemAccess{}).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "更新访问名单失败")
+		return
+	}
+	for _, uid := range uniqueUints(req.UserIDs) {
+		grantee := uid
+		if err := tx.Create(&model.ItemAccess{ObjectType: objectType, ObjectID: target.ID, UserID: &grantee, CreatorID: userID}).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "更新访问名单失败")
+			return
+		}
+	}
+	roleCodes := make([]string, 0, len(req.RoleCodes))
+	seen := make(map[string]bool)
+	for _, code := range req.RoleCodes {
+		code = strings.TrimSpace(code)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		roleCodes = append(roleCodes, code)
+		if err := tx.Create(&model.ItemAccess{ObjectType: objectType, ObjectID: target.ID, RoleCode: code, CreatorID: userID}).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "更新访问名单失败")
+			return
+		}
+	}
+
+	// 记录操作历史
+	actionID, _ := utils.RecordAction(tx, objectType, target.ID, "edited", userID, "", nil)
+	changes := []utils.HistoryChange{}
+	if target.Confidential != confidential {
+		changes = append(changes, utils.HistoryChange{Field: "confidential", Old: fmt.Sprintf("%t", target.Confidential), New: fmt.Sprintf("%t", confidential)})
+	}
+	if oldUsers, newUsers := formatUintSlice(oldUserIDs), formatUintSlice(uniqueUints(req.UserIDs)); oldUsers != newUsers {
+		changes = append(changes, utils.HistoryChange{Field: "access_user_ids", Old: oldUsers, New: newUsers})
+	}
+	if oldRoles, newRoles := strings.Join(oldRoleCodes, ","), strings.Join(roleCodes, ","); oldRoles != newRoles {
+		changes = append(changes, utils.HistoryChange{Field: "access_role_codes", Old: oldRoles, New: newRoles})
+	}
+	utils.RecordHistory(tx, actionID, changes)
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+
+	// 直接返回更新结果（更新人不在访问名单中时也能看到本次设置）
+	h.respondAccess(c, objectType, target.ID, confidential)
+}
+
+// uniqueUints 去重并保持原有顺序
+func uniqueUints(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
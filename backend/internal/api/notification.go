@@ -0,0 +1,76 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+type NotificationHandler struct {
+	db *gorm.DB
+}
+
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{db: db}
+}
+
+// GetNotifications 获取当前用户的站内通知（unread=true 时只返回未读），同时返回未读数量
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	uid := utils.GetUserID(c)
+	query := h.db.Model(&model.Notification{}).Where("user_id = ?", uid)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	var total int64
+	query.Count(&total)
+	var unread int64
+	h.db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", uid).Count(&unread)
+
+	page := utils.GetPage(c)
+	pageSize := utils.GetPageSize(c)
+	var notifications []model.Notification
+	if err := query.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&notifications).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      notifications,
+		"total":     total,
+		"unread":    unread,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// MarkNotificationRead 标记通知为已读
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	result := h.db.Model(&model.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", c.Param("id"), utils.GetUserID(c)).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		utils.Error(c, utils.CodeError, "操作失败")
+		return
+	}
+	utils.Success(c, gin.H{"updated": result.RowsAffected})
+}
+
+// MarkAllNotificationsRead 将当前用户的所有通知标记为已读
+func (h *NotificationHandler) MarkAllNotificationsRead(c *gin.Context) {
+	result := h.db.Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", utils.GetUserID(c)).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		utils.Error(c, utils.CodeError, "操作失败")
+		return
+	}
+	utils.Success(c, gin.H{"updated": result.RowsAffected})
+}
@@ -0,0 +1,869 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ProgramHandler 项目集（产品线）管理
+type ProgramHandler struct {
+	db       *gorm.DB
+	projects *ProjectHandler
+}
+
+func NewProgramHandler(db *gorm.DB) *ProgramHandler {
+	return &ProgramHandler{db: db, projects: NewProjectHandler(db)}
+}
+
+// 健康度等级
+const (
+	healthGreen   = "green"   // 正常
+	healthYellow  = "yellow"  // 有风险
+	healthRed     = "red"     // 严重
+	healthUnknown = "unknown" // 数据不足，无法判断
+)
+
+// GetPrograms 获取项目集列表
+func (h *ProgramHandler) GetPrograms(c *gin.Context) {
+	query := utils.FilterProgramsByUser(h.db, c, h.db.Model(&model.Program{}))
+
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("name LIKE ? OR code LIKE ? OR description LIKE ?", "%"+keyword+"%", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page := utils.GetPage(c)
+	pageSize := utils.GetPageSize(c)
+	offset := (page - 1) * pageSize
+
+	var total int64
+	query.Count(&total)
+
+	var programs []model.Program
+	if err := query.Preload("Projects").Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&programs).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":  programs,
+		"total": total,
+		"page":  page,
+		"size":  pageSize,
+	})
+}
+
+// GetProgram 获取项目集详情（包含汇总统计）
+func (h *ProgramHandler) GetProgram(c *gin.Context) {
+	program, ok := h.loadProgram(c, false)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Preload("Members.User").Preload("Projects", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(program, program.ID).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"program":    program,
+		"statistics": h.getProgramStatistics(c, program.ID),
+	})
+}
+
+// GetProgramStatistics 获取项目集汇总统计
+func (h *ProgramHandler) GetProgramStatistics(c *gin.Context) {
+	program, ok := h.loadProgram(c, false)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, h.getProgramStatistics(c, program.ID))
+}
+
+// getProgramStatistics 汇总项目集内各项目的统计信息（复用项目统计，保证口径一致）
+func (h *ProgramHandler) getProgramStatistics(c *gin.Context, programID uint) gin.H {
+	var projectIDs []uint
+	h.db.Model(&model.Project{}).Where("program_id = ?", programID).Pluck("id", &projectIDs)
+
+	result := gin.H{"total_projects": len(projectIDs)}
+	for _, projectID := range projectIDs {
+		for key, value := range h.projects.getProjectStatistics(c, projectID) {
+			count, _ := value.(int)
+			current, _ := result[key].(int)
+			result[key] = current + count
+		}
+	}
+
+	// 项目集级需求
+	var programRequirementCount int64
+	utils.FilterRestrictedItems(c, "requirement", h.db.Model(&model.Requirement{})).
+		Where("program_id = ?", programID).
+		Count(&programRequirementCount)
+	result["program_requirements"] = int(programRequirementCount)
+
+	return result
+}
+
+// GetProgramPortfolio 获取项目集组合看板：各项目的进度、预算和质量健康度
+func (h *ProgramHandler) GetProgramPortfolio(c *gin.Context) {
+	program, ok := h.loadProgram(c, false)
+	if !ok {
+		return
+	}
+
+	var projects []model.Project
+	if err := h.db.Where("program_id = ?", program.ID).Order("id ASC").Find(&projects).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	now := time.Now()
+	items := make([]gin.H, 0, len(projects))
+	summary := gin.H{healthGreen: 0, healthYellow: 0, healthRed: 0, healthUnknown: 0}
+	for _, project := range projects {
+		health := h.getProjectHealth(c, project, now)
+		summary[health["overall"].(string)] = summary[health["overall"].(string)].(int) + 1
+		items = append(items, gin.H{
+			"project":    project,
+			"statistics": h.projects.getProjectStatistics(c, project.ID),
+			"health":     health,
+		})
+	}
+
+	utils.Success(c, gin.H{
+		"program":  program,
+		"projects": items,
+		"summary":  summary,
+	})
+}
+
+// getProjectHealth 计算项目的进度、预算和质量健康度
+// 进度：按时间推算的预期进度与实际完成率的差距；预算：挣值与实际工时之比；质量：未解决的高严重程度Bug
+func (h *ProgramHandler) getProjectHealth(c *gin.Context, project model.Project, now time.Time) gin.H {
+	var tasks struct {
+		Total     int64
+		Done      int64
+		Estimated float64
+		Actual    float64
+		Earned    float64
+	}
+	utils.FilterRestrictedItems(c, "task", h.db.Model(&model.Task{})).
+		Select(`COUNT(*) AS total,
+			COALESCE(SUM(CASE WHEN status IN ('done', 'closed') THEN 1 ELSE 0 END), 0) AS done,
+			COALESCE(SUM(COALESCE(estimated_hours, 0)), 0) AS estimated,
+			COALESCE(SUM(COALESCE(actual_hours, 0)), 0) AS actual,
+			COALESCE(SUM(COALESCE(estimated_hours, 0) * CASE WHEN status IN ('done', 'closed') THEN 100 ELSE progress END / 100.0), 0) AS earned`).
+		Where("project_id = ? AND status <> ?", project.ID, "cancel").
+		Scan(&tasks)
+
+	// 进度健康度
+	progress := 0.0
+	if tasks.Total > 0 {
+		progress = float64(tasks.Done) / float64(tasks.Total)
+	}
+	schedule := healthUnknown
+	expected := 0.0
+	switch {
+	case project.Status == "done" || project.Status == "closed":
+		schedule = healthGreen
+	case project.StartDate != nil && project.EndDate != nil && project.EndDate.After(*project.StartDate):
+		expected = now.Sub(*project.StartDate).Hours() / project.EndDate.Sub(*project.StartDate).Hours()
+		if expected < 0 {
+			expected = 0
+		} else if expected > 1 {
+			expected = 1
+		}
+		lag := expected - progress
+		switch {
+		case now.After(*project.EndDate) && progress < 1, lag > 0.25:
+			schedule = healthRed
+		case lag > 0.1:
+			schedule = healthYellow
+		default:
+			schedule = healthGreen
+		}
+	}
+
+	// 预算健康度（按工时）
+	budget := healthUnknown
+	costPerformance := 0.0
+	if tasks.Actual > 0 {
+		costPerformance = tasks.Earned / tasks.Actual
+		switch {
+		case costPerformance < 0.8:
+			budget = healthRed
+		case costPerformance < 0.95:
+			budget = healthYellow
+		default:
+			budget = healthGreen
+		}
+	} else if tasks.Estimated > 0 {
+		budget = healthGreen
+	}
+
+	// 质量健康度
+	var activeBugs, criticalBugs, highBugs int64
+	bugs := func() *gorm.DB {
+		return utils.FilterRestrictedItems(c, "bug", h.db.Model(&model.Bug{})).Where("project_id = ? AND status = ?", project.ID, "active")
+	}
+	bugs().Count(&activeBugs)
+	bugs().Where("severity = ?", "critical").Count(&criticalBugs)
+	bugs().Where("severity = ?", "high").Count(&highBugs)
+	quality := healthGreen
+	if criticalBugs > 0 {
+		quality = healthRed
+	} else if highBugs > 0 {
+		quality = healthYellow
+	}
+
+	return gin.H{
+		"overall":  worstHealth(schedule, budget, quality),
+		"schedule": schedule,
+		"budget":   budget,
+		"quality":  quality,
+		"metrics": gin.H{
+			"progress":         progress,
+			"expected":         expected,
+			"estimated_hours":  tasks.Estimated,
+			"actual_hours":     tasks.Actual,
+			"earned_hours":     tasks.Earned,
+			"cost_performance": costPerformance,
+			"active_bugs":      int(activeBugs),
+			"critical_bugs":    int(criticalBugs),
+			"high_bugs":        int(highBugs),
+		},
+	}
+}
+
+// worstHealth 取最差的健康度（忽略无法判断的维度）
+func worstHealth(levels ...string) string {
+	rank := map[string]int{healthGreen: 1, healthYellow: 2, healthRed: 3}
+	result := healthUnknown
+	for _, level := range levels {
+		if rank[level] > rank[result] {
+			result = level
+		}
+	}
+	return result
+}
+
+// CreateProgram 创建项目集
+func (h *ProgramHandler) CreateProgram(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Code        string `json:"code" binding:"required"`
+		Description string `json:"description"`
+		Status      string `json:"status"`
+		ProjectIDs  []uint `json:"project_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	if req.Status == "" {
+		req.Status = "active"
+	}
+	if !isValidProgramStatus(req.Status) {
+		utils.Error(c, 400, "状态值无效，有效值：active, closed")
+		return
+	}
+
+	var count int64
+	h.db.Model(&model.Program{}).Where("code = ?", req.Code).Count(&count)
+	if count > 0 {
+		utils.Error(c, 400, "项目集编码已存在")
+		return
+	}
+
+	if !h.checkProjectsAssignable(c, 0, req.ProjectIDs) {
+		return
+	}
+
+	userID := utils.GetUserID(c)
+	program := model.Program{
+		Name:        req.Name,
+		Code:        req.Code,
+		Description: req.Description,
+		Status:      req.Status,
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := tx.Create(&program).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "创建失败: "+err.Error())
+		return
+	}
+
+	// 创建者默认为项目集负责人
+	if userID > 0 {
+		if err := tx.Create(&model.ProgramMember{ProgramID: program.ID, UserID: userID, Role: "owner"}).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "添加项目集成员失败")
+			return
+		}
+	}
+
+	if len(req.ProjectIDs) > 0 {
+		if err := tx.Model(&model.Project{}).Where("id IN ?", req.ProjectIDs).Update("program_id", program.ID).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "关联项目失败")
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建失败")
+		return
+	}
+
+	if userID > 0 {
+		utils.RecordAction(h.db, "program", program.ID, "created", userID, "", nil)
+	}
+
+	h.db.Preload("Members.User").Preload("Projects").First(&program, program.ID)
+	utils.Success(c, program)
+}
+
+// UpdateProgram 更新项目集
+func (h *ProgramHandler) UpdateProgram(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name"`
+		Code        *string `json:"code"`
+		Description *string `json:"description"`
+		Status      *string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	oldProgram := *program
+	if req.Name != nil {
+		program.Name = *req.Name
+	}
+	if req.Code != nil {
+		var count int64
+		h.db.Model(&model.Program{}).Where("code = ? AND id != ?", *req.Code, program.ID).Count(&count)
+		if count > 0 {
+			utils.Error(c, 400, "项目集编码已存在")
+			return
+		}
+		program.Code = *req.Code
+	}
+	if req.Description != nil {
+		program.Description = *req.Description
+	}
+	if req.Status != nil {
+		if !isValidProgramStatus(*req.Status) {
+			utils.Error(c, 400, "状态值无效，有效值：active, closed")
+			return
+		}
+		program.Status = *req.Status
+	}
+
+	if err := h.db.Omit("Projects", "Members").Save(program).Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+
+	// 记录变更历史
+	changes := []utils.HistoryChange{}
+	for _, field := range []struct{ name, old, new string }{
+		{"name", oldProgram.Name, program.Name},
+		{"code", oldProgram.Code, program.Code},
+		{"description", oldProgram.Description, program.Description},
+		{"status", oldProgram.Status, program.Status},
+	} {
+		if field.old != field.new {
+			changes = append(changes, utils.HistoryChange{Field: field.name, Old: field.old, New: field.new})
+		}
+	}
+	if len(changes) > 0 {
+		actionID, _ := utils.RecordAction(h.db, "program", program.ID, "edited", utils.GetUserID(c), "", nil)
+		utils.RecordHistory(h.db, actionID, changes)
+	}
+
+	utils.Success(c, program)
+}
+
+// DeleteProgram 删除项目集（项目和需求解除关联，不会被删除）
+func (h *ProgramHandler) DeleteProgram(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	steps := []func() error{
+		func() error {
+			return tx.Model(&model.Project{}).Where("program_id = ?", program.ID).Update("program_id", nil).Error
+		},
+		func() error {
+			return tx.Model(&model.Requirement{}).Where("program_id = ?", program.ID).Update("program_id", nil).Error
+		},
+		func() error {
+			return tx.Where("program_id = ?", program.ID).Delete(&model.ProgramMember{}).Error
+		},
+		func() error { return tx.Delete(program).Error },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "删除失败")
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// AddProgramProjects 将项目加入项目集
+func (h *ProgramHandler) AddProgramProjects(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ProjectIDs []uint `json:"project_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	if !h.checkProjectsAssignable(c, program.ID, req.ProjectIDs) {
+		return
+	}
+
+	if err := h.db.Model(&model.Project{}).Where("id IN ?", req.ProjectIDs).Update("program_id", program.ID).Error; err != nil {
+		utils.Error(c, utils.CodeError, "关联项目失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "添加成功"})
+}
+
+// RemoveProgramProject 将项目移出项目集
+func (h *ProgramHandler) RemoveProgramProject(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var project model.Project
+	if err := h.db.Where("id = ? AND program_id = ?", c.Param("project_id"), program.ID).First(&project).Error; err != nil {
+		utils.Error(c, 404, "项目不在该项目集中")
+		return
+	}
+
+	// 以该项目为主项目的项目集级需求不再属于项目集
+	var requirementIDs []uint
+	h.db.Model(&model.Requirement{}).Where("program_id = ? AND project_id = ?", program.ID, project.ID).Pluck("id", &requirementIDs)
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := tx.Model(&project).Update("program_id", nil).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "移除失败")
+		return
+	}
+	if len(requirementIDs) > 0 {
+		if err := tx.Model(&model.Requirement{}).Where("id IN ?", requirementIDs).Update("program_id", nil).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "移除失败")
+			return
+		}
+	}
+	// 项目不再参与实现项目集内其他项目的需求
+	if err := tx.Exec("DELETE FROM requirement_projects WHERE project_id = ? AND requirement_id IN (SELECT id FROM requirements WHERE program_id = ?)", project.ID, program.ID).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "移除失败")
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "移除失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "移除成功"})
+}
+
+// checkProjectsAssignable 检查项目是否可以加入项目集：项目存在、未属于其他项目集，且当前用户可以管理该项目
+func (h *ProgramHandler) checkProjectsAssignable(c *gin.Context, programID uint, projectIDs []uint) bool {
+	if len(projectIDs) == 0 {
+		return true
+	}
+
+	var projects []model.Project
+	if err := h.db.Where("id IN ?", projectIDs).Find(&projects).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询项目失败")
+		return false
+	}
+	if len(projects) != len(uniqueUints(projectIDs)) {
+		utils.Error(c, 400, "部分项目不存在")
+		return false
+	}
+
+	for _, project := range projects {
+		if project.ProgramID != nil && *project.ProgramID != programID {
+			utils.Error(c, 400, "项目 "+project.Name+" 已属于其他项目集")
+			return false
+		}
+		if !utils.CheckProjectAccess(h.db, c, project.ID) || !utils.HasProjectPermission(h.db, c, project.ID, "project:manage") {
+			utils.Error(c, 403, "没有权限管理项目 "+project.Name)
+			return false
+		}
+	}
+	return true
+}
+
+// GetProgramMembers 获取项目集成员
+func (h *ProgramHandler) GetProgramMembers(c *gin.Context) {
+	program, ok := h.loadProgram(c, false)
+	if !ok {
+		return
+	}
+
+	var members []model.ProgramMember
+	if err := h.db.Preload("User").Where("program_id = ?", program.ID).Order("id ASC").Find(&members).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, members)
+}
+
+// AddProgramMembers 添加项目集成员（已是成员时更新角色）
+func (h *ProgramHandler) AddProgramMembers(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserIDs []uint `json:"user_ids" binding:"required"`
+		Role    string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	userIDs := uniqueUints(req.UserIDs)
+	var count int64
+	h.db.Model(&model.User{}).Where("id IN ?", userIDs).Count(&count)
+	if int(count) != len(userIDs) {
+		utils.Error(c, 400, "部分用户不存在")
+		return
+	}
+
+	for _, userID := range userIDs {
+		var existingMember model.ProgramMember
+		if err := h.db.Where("program_id = ? AND user_id = ?", program.ID, userID).First(&existingMember).Error; err == nil {
+			existingMember.Role = req.Role
+			h.db.Save(&existingMember)
+			continue
+		}
+		if err := h.db.Create(&model.ProgramMember{ProgramID: program.ID, UserID: userID, Role: req.Role}).Error; err != nil {
+			utils.Error(c, utils.CodeError, "添加成员失败")
+			return
+		}
+	}
+
+	utils.Success(c, gin.H{"message": "添加成功"})
+}
+
+// UpdateProgramMember 更新项目集成员角色
+func (h *ProgramHandler) UpdateProgramMember(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var member model.ProgramMember
+	if err := h.db.Where("program_id = ? AND id = ?", program.ID, c.Param("member_id")).First(&member).Error; err != nil {
+		utils.Error(c, 404, "项目集成员不存在")
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	member.Role = req.Role
+	if err := h.db.Save(&member).Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+
+	h.db.Preload("User").First(&member, member.ID)
+	utils.Success(c, member)
+}
+
+// RemoveProgramMember 移除项目集成员
+func (h *ProgramHandler) RemoveProgramMember(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var member model.ProgramMember
+	if err := h.db.Where("program_id = ? AND id = ?", program.ID, c.Param("member_id")).First(&member).Error; err != nil {
+		utils.Error(c, 404, "项目集成员不存在")
+		return
+	}
+
+	if err := h.db.Delete(&member).Error; err != nil {
+		utils.Error(c, utils.CodeError, "移除失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "移除成功"})
+}
+
+// GetProgramRequirements 获取项目集级需求（由项目集负责、跨项目实现的需求）
+func (h *ProgramHandler) GetProgramRequirements(c *gin.Context) {
+	program, ok := h.loadProgram(c, false)
+	if !ok {
+		return
+	}
+
+	query := utils.FilterRequirementsByUser(h.db, c, h.db.Model(&model.Requirement{})).Where("program_id = ?", program.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page := utils.GetPage(c)
+	pageSize := utils.GetPageSize(c)
+	offset := (page - 1) * pageSize
+
+	var total int64
+	query.Count(&total)
+
+	var requirements []model.Requirement
+	if err := query.Preload("Project").Preload("LinkedProjects").Preload("Assignee").
+		Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&requirements).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":  requirements,
+		"total": total,
+		"page":  page,
+		"size":  pageSize,
+	})
+}
+
+// SetProgramRequirement 将需求设为项目集级需求，并指定共同实现的项目
+// 需求的所属项目和共同实现的项目都必须在项目集内
+func (h *ProgramHandler) SetProgramRequirement(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var requirement model.Requirement
+	if err := h.db.Preload("LinkedProjects").First(&requirement, c.Param("requirement_id")).Error; err != nil {
+		utils.Error(c, 404, "需求不存在")
+		return
+	}
+	if !utils.CheckRequirementAccess(h.db, c, requirement.ID) {
+		utils.Error(c, 403, "没有权限访问该需求")
+		return
+	}
+
+	var req struct {
+		ProjectIDs []uint `json:"project_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	// 所属项目不重复记录为共同实现的项目
+	linkedIDs := make([]uint, 0, len(req.ProjectIDs))
+	for _, id := range uniqueUints(req.ProjectIDs) {
+		if id != requirement.ProjectID {
+			linkedIDs = append(linkedIDs, id)
+		}
+	}
+
+	var count int64
+	h.db.Model(&model.Project{}).Where("id IN ? AND program_id = ?", append([]uint{requirement.ProjectID}, linkedIDs...), program.ID).Count(&count)
+	if int(count) != len(linkedIDs)+1 {
+		utils.Error(c, 400, "需求所属项目和共同实现的项目必须属于该项目集")
+		return
+	}
+
+	var linkedProjects []model.Project
+	if len(linkedIDs) > 0 {
+		h.db.Where("id IN ?", linkedIDs).Find(&linkedProjects)
+	}
+
+	oldProgram := ""
+	if requirement.ProgramID != nil {
+		oldProgram = strconv.FormatUint(uint64(*requirement.ProgramID), 10)
+	}
+	oldLinked := make([]uint, 0, len(requirement.LinkedProjects))
+	for _, project := range requirement.LinkedProjects {
+		oldLinked = append(oldLinked, project.ID)
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := tx.Model(&requirement).Update("program_id", program.ID).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+	if err := tx.Model(&requirement).Association("LinkedProjects").Replace(linkedProjects); err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "更新共同实现的项目失败")
+		return
+	}
+
+	changes := []utils.HistoryChange{}
+	if newProgram := strconv.FormatUint(uint64(program.ID), 10); oldProgram != newProgram {
+		changes = append(changes, utils.HistoryChange{Field: "program_id", Old: oldProgram, New: newProgram})
+	}
+	if oldIDs, newIDs := formatUintSlice(oldLinked), formatUintSlice(linkedIDs); oldIDs != newIDs {
+		changes = append(changes, utils.HistoryChange{Field: "linked_project_ids", Old: oldIDs, New: newIDs})
+	}
+	if len(changes) > 0 {
+		actionID, _ := utils.RecordAction(tx, "requirement", requirement.ID, "edited", utils.GetUserID(c), "", nil)
+		utils.RecordHistory(tx, actionID, changes)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+
+	h.db.Preload("Project").Preload("LinkedProjects").First(&requirement, requirement.ID)
+	utils.Success(c, requirement)
+}
+
+// RemoveProgramRequirement 取消需求的项目集归属（需求保留在所属项目中）
+func (h *ProgramHandler) RemoveProgramRequirement(c *gin.Context) {
+	program, ok := h.loadProgram(c, true)
+	if !ok {
+		return
+	}
+
+	var requirement model.Requirement
+	if err := h.db.Where("id = ? AND program_id = ?", c.Param("requirement_id"), program.ID).First(&requirement).Error; err != nil {
+		utils.Error(c, 404, "需求不属于该项目集")
+		return
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := tx.Model(&requirement).Update("program_id", nil).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+	if err := tx.Model(&requirement).Association("LinkedProjects").Clear(); err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+
+	actionID, _ := utils.RecordAction(tx, "requirement", requirement.ID, "edited", utils.GetUserID(c), "", nil)
+	utils.RecordHistory(tx, actionID, []utils.HistoryChange{
+		{Field: "program_id", Old: strconv.FormatUint(uint64(program.ID), 10), New: ""},
+	})
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "移除成功"})
+}
+
+// loadProgram 加载路径参数中的项目集并检查权限，失败时已写入错误响应
+// manage 为 true 时要求当前用户可以管理该项目集
+func (h *ProgramHandler) loadProgram(c *gin.Context, manage bool) (*model.Program, bool) {
+	var program model.Program
+	if err := h.db.First(&program, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目集不存在")
+		return nil, false
+	}
+
+	if manage {
+		if !utils.CanManageProgram(h.db, c, program.ID) {
+			utils.Error(c, 403, "没有权限管理该项目集")
+			return nil, false
+		}
+	} else if !utils.CheckProgramAccess(h.db, c, program.ID) {
+		utils.Error(c, 403, "没有权限访问该项目集")
+		return nil, false
+	}
+	return &program, true
+}
+
+func isValidProgramStatus(status string) bool {
+	return status == "active" || status == "closed"
+}
@@ -55,6 +55,11 @@ func (h *ProjectHandler) GetProjects(c *gin.Context) {
 		query = query.Where("id = ?", projectID)
 	}
 
+	// 项目集筛选
+	if programID := c.Query("program_id"); programID != "" {
+		query = query.Where("program_id = ?", programID)
+	}
+
 	// 分页
 	page := utils.GetPage(c)
 	pageSize := utils.GetPageSize(c)
@@ -83,6 +88,9 @@ func (h *ProjectHandler) GetProjects(c *gin.Context) {
 	if projectID := c.Query("project_id"); projectID != "" {
 		countQuery = countQuery.Where("id = ?", projectID)
 	}
+	if programID := c.Query("program_id"); programID != "" {
+		countQuery = countQuery.Where("program_id = ?", programID)
+	}
 	countQuery.Count(&total)
 
 	if err := query.Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&projects).Error; err != nil {
@@ -117,7 +125,7 @@ func (h *ProjectHandler) GetProject(c *gin.Context) {
 	}
 
 	// 获取项目统计信息
-	statistics := h.getProjectStatistics(project.ID)
+	statistics := h.getProjectStatistics(c, project.ID)
 
 	utils.Success(c, gin.H{
 		"project":    project,
@@ -142,33 +150,40 @@ func (h *ProjectHandler) GetProjectStatistics(c *gin.Context) {
 		return
 	}
 
-	statistics := h.getProjectStatistics(project.ID)
+	statistics := h.getProjectStatistics(c, project.ID)
 	utils.Success(c, statistics)
 }
 
 // getProjectStatistics 获取项目统计信息（内部方法）
-func (h *ProjectHandler) getProjectStatistics(projectID uint) gin.H {
+// 统计只包含当前用户可见的对象（受限的需求、任务和Bug不计入无权查看的用户的统计）
+func (h *ProjectHandler) getProjectStatistics(c *gin.Context, projectID uint) gin.H {
+	tasks := func() *gorm.DB { return utils.FilterRestrictedItems(c, "task", h.db.Model(&model.Task{})) }
+	bugs := func() *gorm.DB { return utils.FilterRestrictedItems(c, "bug", h.db.Model(&model.Bug{})) }
+	requirements := func() *gorm.DB {
+		return utils.FilterRestrictedItems(c, "requirement", h.db.Model(&model.Requirement{}))
+	}
+
 	var taskCount, bugCount, requirementCount, memberCount int64
 	var todoTaskCount, inProgressTaskCount, doneTaskCount int64
 	var openBugCount, inProgressBugCount, resolvedBugCount int64
 	var inProgressRequirementCount, completedRequirementCount int64
 
 	// 任务统计
-	h.db.Model(&model.Task{}).Where("project_id = ?", projectID).Count(&taskCount)
-	h.db.Model(&model.Task{}).Where("project_id = ? AND status = ?", projectID, "wait").Count(&todoTaskCount)
-	h.db.Model(&model.Task{}).Where("project_id = ? AND status = ?", projectID, "doing").Count(&inProgressTaskCount)
-	h.db.Model(&model.Task{}).Where("project_id = ? AND status = ?", projectID, "done").Count(&doneTaskCount)
+	tasks().Where("project_id = ?", projectID).Count(&taskCount)
+	tasks().Where("project_id = ? AND status = ?", projectID, "wait").Count(&todoTaskCount)
+	tasks().Where("project_id = ? AND status = ?", projectID, "doing").Count(&inProgressTaskCount)
+	tasks().Where("project_id = ? AND status = ?", projectID, "done").Count(&doneTaskCount)
 
 	// Bug统计
-	h.db.Model(&model.Bug{}).Where("project_id = ?", projectID).Count(&bugCount)
-	h.db.Model(&model.Bug{}).Where("project_id = ? AND status = ?", projectID, "active").Count(&openBugCount)
-	h.db.Model(&model.Bug{}).Where("project_id = ? AND status = ?", projectID, "resolved").Count(&inProgressBugCount)
-	h.db.Model(&model.Bug{}).Where("project_id = ? AND status = ?", projectID, "resolved").Count(&resolvedBugCount)
+	bugs().Where("project_id = ?", projectID).Count(&bugCount)
+	bugs().Where("project_id = ? AND status = ?", projectID, "active").Count(&openBugCount)
+	bugs().Where("project_id = ? AND status = ?", projectID, "resolved").Count(&inProgressBugCount)
+	bugs().Where("project_id = ? AND status = ?", projectID, "resolved").Count(&resolvedBugCount)
 
 	// 需求统计
-	h.db.Model(&model.Requirement{}).Where("project_id = ?", projectID).Count(&requirementCount)
-	h.db.Model(&model.Requirement{}).Where("project_id = ? AND status = ?", projectID, "active").Count(&inProgressRequirementCount)
-	h.db.Model(&model.Requirement{}).Where("project_id = ? AND status = ?", projectID, "closed").Count(&completedRequirementCount)
+	requirements().Where("project_id = ?", projectID).Count(&requirementCount)
+	requirements().Where("project_id = ? AND status = ?", projectID, "active").Count(&inProgressRequirementCount)
+	requirements().Where("project_id = ? AND status = ?", projectID, "closed").Count(&completedRequirementCount)
 
 	// 成员统计
 	h.db.Model(&model.ProjectMember{}).Where("project_id = ?", projectID).Count(&memberCount)
@@ -492,8 +507,8 @@ func (h *ProjectHandler) GetProjectGantt(c *gin.Context) {
 
 	// 获取项目的所有任务（包含依赖关系）
 	var tasks []model.Task
-	if err := h.db.Where("project_id = ?", projectID).
-		Preload("Dependencies").
+	if err := utils.FilterRestrictedItems(c, "task", h.db.Where("project_id = ?", projectID)).
+		Preload("Dependencies", utils.RestrictedPreload(c, "task")).
 		Preload("Assignee").
 		Order("created_at ASC").
 		Find(&tasks).Error; err != nil {
@@ -582,7 +597,7 @@ func (h *ProjectHandler) GetProjectProgress(c *gin.Context) {
 	}
 
 	// 获取基础统计
-	statistics := h.getProjectStatistics(project.ID)
+	statistics := h.getProjectStatistics(c, project.ID)
 
 	// 获取任务进度趋势（最近30天）
 	taskProgressTrend := h.getTaskProgressTrend(project.ID, 30)
@@ -599,11 +614,16 @@ func (h *ProjectHandler) GetProjectProgress(c *gin.Context) {
 	// 获取成员工作量统计
 	memberWorkload := h.getMemberWorkload(project.ID)
 
-	// 获取Bug趋势（最近30天）
-	bugTrend := h.getBugTrend(project.ID, 30)
-
-	// 获取需求完成趋势
-	requirementTrend := h.getRequirementTrend(project.ID, 30)
+	// 最近30天的每日指标快照（Bug趋势、需求趋势和工时趋势），不含当前用户不可见的受限对象
+	now := time.Now()
+	series, err := utils.ProjectMetricSeries(h.db, c, []uint{project.ID}, now.AddDate(0, 0, -29), now)
+	if err != nil {
+		utils.Error(c, 500, "获取项目指标失败")
+		return
+	}
+	bugTrend := h.getBugTrend(series)
+	requirementTrend := h.getRequirementTrend(series)
+	hoursTrend := h.getHoursTrend(series)
 
 	utils.Success(c, gin.H{
 		"statistics":                 statistics,
@@ -614,6 +634,7 @@ func (h *ProjectHandler) GetProjectProgress(c *gin.Context) {
 		"member_workload":            memberWorkload,
 		"bug_trend":                  bugTrend,
 		"requirement_trend":          requirementTrend,
+		"hours_trend":                hoursTrend,
 	})
 }
 
@@ -763,51 +784,45 @@ func (h *ProjectHandler) getMemberWorkload(projectID uint) []gin.H {
 	return result
 }
 
-// getBugTrend 获取Bug趋势
-func (h *ProjectHandler) getBugTrend(projectID uint, days int) []gin.H {
-	var bugs []model.Bug
-	h.db.Where("project_id = ? AND created_at >= ?", projectID, time.Now().AddDate(0, 0, -days)).
-		Order("created_at ASC").
-		Find(&bugs)
-
-	bugByDate := make(map[string]int)
-	for _, bug := range bugs {
-		date := bug.CreatedAt.Format("2006-01-02")
-		bugByDate[date]++
-	}
-
-	result := make([]gin.H, 0)
-	for date, count := range bugByDate {
+// getBugTrend 获取Bug趋势：每天新建数量、未解决数量以及按状态和严重程度的分布
+func (h *ProjectHandler) getBugTrend(series []*utils.MetricDay) []gin.H {
+	result := make([]gin.H, 0, len(series))
+	for _, day := range series {
 		result = append(result, gin.H{
-			"date":  date,
-			"count": count,
+			"date":        day.Date,
+			"count":       day.Get("bug", "created", "total"),
+			"open":        day.OpenCount("bug"),
+			"by_status":   day.Group("bug", "status"),
+			"by_severity": day.Group("bug", "severity"),
 		})
 	}
-
 	return result
 }
 
-// getRequirementTrend 获取需求趋势
-func (h *ProjectHandler) getRequirementTrend(projectID uint, days int) []gin.H {
-	var requirements []model.Requirement
-	h.db.Where("project_id = ? AND created_at >= ?", projectID, time.Now().AddDate(0, 0, -days)).
-		Order("created_at ASC").
-		Find(&requirements)
-
-	reqByDate := make(map[string]int)
-	for _, req := range requirements {
-		date := req.CreatedAt.Format("2006-01-02")
-		reqByDate[date]++
+// getRequirementTrend 获取需求趋势：每天新建数量、未关闭数量以及按状态的分布
+func (h *ProjectHandler) getRequirementTrend(series []*utils.MetricDay) []gin.H {
+	result := make([]gin.H, 0, len(series))
+	for _, day := range series {
+		result = append(result, gin.H{
+			"date":      day.Date,
+			"count":     day.Get("requirement", "created", "total"),
+			"open":      day.OpenCount("requirement"),
+			"by_status": day.Group("requirement", "status"),
+		})
 	}
+	return result
+}
 
-	result := make([]gin.H, 0)
-	for date, count := range reqByDate {
+// getHoursTrend 获取工时趋势：每天结束时的剩余工时和累计完成工时（燃尽图）
+func (h *ProjectHandler) getHoursTrend(series []*utils.MetricDay) []gin.H {
+	result := make([]gin.H, 0, len(series))
+	for _, day := range series {
 		result = append(result, gin.H{
-			"date":  date,
-			"count": count,
+			"date":            day.Date,
+			"remaining_hours": day.Get("task", "hours", "remaining"),
+			"completed_hours": day.Get("task", "hours", "completed"),
 		})
 	}
-
 	return result
 }
 
@@ -875,6 +890,7 @@ func (h *ProjectHandler) AddProjectMembers(c *gin.Context) {
 			return
 		}
 	}
+	invalidateLiveViewers(project.ID)
 
 	utils.Success(c, gin.H{"message": "添加成功"})
 }
@@ -910,6 +926,7 @@ func (h *ProjectHandler) UpdateProjectMember(c *gin.Context) {
 		utils.Error(c, utils.CodeError, "更新失败")
 		return
 	}
+	invalidateLiveViewers(member.ProjectID)
 
 	utils.Success(c, member)
 }
@@ -936,6 +953,7 @@ func (h *ProjectHandler) RemoveProjectMember(c *gin.Context) {
 		utils.Error(c, utils.CodeError, "删除失败")
 		return
 	}
+	invalidateLiveViewers(member.ProjectID)
 
 	utils.Success(c, gin.H{"message": "删除成功"})
 }
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxProjectBundleSize 项目数据包最大大小（500MB）
+const maxProjectBundleSize = 500 << 20
+
+type ProjectBundleHandler struct {
+	db *gorm.DB
+}
+
+func NewProjectBundleHandler(db *gorm.DB) *ProjectBundleHandler {
+	return &ProjectBundleHandler{db: db}
+}
+
+// ExportProject 导出项目数据包（zip，包含项目数据和附件文件）
+func (h *ProjectBundleHandler) ExportProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "无效的项目ID")
+		return
+	}
+
+	var project model.Project
+	if err := h.db.First(&project, id).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	if !utils.CheckProjectAccess(h.db, c, project.ID) {
+		utils.Error(c, 403, "没有权限访问该项目")
+		return
+	}
+
+	// 先写入内存，保证出错时仍能返回错误信息
+	var buf bytes.Buffer
+	if err := utils.WriteProjectBundle(h.db, c, project.ID, &buf); err != nil {
+		utils.Error(c, utils.CodeError, "导出失败: "+err.Error())
+		return
+	}
+
+	name := project.Code
+	if name == "" {
+		name = fmt.Sprintf("project-%d", project.ID)
+	}
+	filename := fmt.Sprintf("%s-%s.zip", name, time.Now().Format("20060102-150405"))
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Data(200, "application/zip", buf.Bytes())
+}
+
+// ImportProject 导入项目数据包
+// 同一数据包重复导入时更新之前导入的对象，不会重复创建；更新之前导入的项目需要该项目的 project:manage 权限
+func (h *ProjectBundleHandler) ImportProject(c *gin.Context) {
+	userID := utils.GetUserID(c)
+	if userID == 0 {
+		utils.Error(c, 401, "未登录")
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.Error(c, 400, "请选择要导入的数据包")
+		return
+	}
+	if fileHeader.Size > maxProjectBundleSize {
+		utils.Error(c, 400, "数据包过大")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.Error(c, utils.CodeError, "读取数据包失败")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "读取数据包失败")
+		return
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		utils.Error(c, 400, "数据包格式错误")
+		return
+	}
+
+	result, err := utils.ImportProjectBundle(h.db, zr, userID, func(projectID uint) bool {
+		return utils.CheckProjectAccess(h.db, c, projectID) && utils.HasProjectPermission(h.db, c, projectID, "project:manage")
+	})
+	if errors.Is(err, utils.ErrProjectImportForbidden) {
+		utils.Error(c, 403, "该数据包之前已导入，没有权限更新已导入的项目")
+		return
+	}
+	if err != nil {
+		utils.Error(c, 400, "导入失败: "+err.Error())
+		return
+	}
+
+	// 新建项目时导入人自动成为项目成员，保证导入后可以访问；更新已有项目时不改变成员
+	if result.ProjectCreated {
+		var count int64
+		h.db.Model(&model.ProjectMember{}).Where("project_id = ? AND user_id = ?", result.ProjectID, userID).Count(&count)
+		if count == 0 {
+			h.db.Create(&model.ProjectMember{ProjectID: result.ProjectID, UserID: userID, Role: "项目经理"})
+		}
+	}
+
+	utils.Success(c, result)
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 流动分析支持的对象类型及对应表名
+var flowObjectTables = map[string]string{
+	"task":        "tasks",
+	"bug":         "bugs",
+	"requirement": "requirements",
+}
+
+// 流动分析的最大统计周期（天）
+const maxFlowPeriodDays = 366
+
+// loadFlowItems 加载项目中在统计周期结束前创建的对象及其状态变更记录
+func (h *ProjectHandler) loadFlowItems(c *gin.Context, projectID uint, objectType string, periodEnd time.Time) []*utils.FlowItem {
+	table := flowObjectTables[objectType]
+	itemQuery := func() *gorm.DB {
+		return utils.FilterRestrictedItems(c, objectType, h.db.Table(table).
+			Where(table+".project_id = ? AND "+table+".deleted_at IS NULL AND "+table+".created_at < ?", projectID, periodEnd))
+	}
+
+	var rows []struct {
+		ID        uint
+		Title     string
+		Status    string
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	itemQuery().Select(table + ".id, " + table + ".title, " + table + ".status, " + table + ".created_at, " + table + ".updated_at").
+		Order(table + ".id ASC").Scan(&rows)
+
+	items := make([]*utils.FlowItem, 0, len(rows))
+	byID := make(map[uint]*utils.FlowItem, len(rows))
+	for _, row := range rows {
+		item := &utils.FlowItem{ID: row.ID, Title: row.Title, Status: row.Status, CreatedAt: row.CreatedAt, UpdatedAt: row.UpdatedAt}
+		items = append(items, item)
+		byID[row.ID] = item
+	}
+	if len(items) == 0 {
+		return items
+	}
+
+	var transitions []struct {
+		ObjectID uint
+		Date     time.Time
+		Old      string
+		New      string
+	}
+	h.db.Table("histories").
+		Select("actions.object_id, actions.date, histories.old, histories.new").
+		Joins("JOIN actions ON actions.id = histories.action_id").
+		Where("actions.object_type = ? AND histories.field = ?", objectType, "status").
+		Where("actions.object_id IN (?)", itemQuery().Select(table+".id")).
+		Order("actions.date ASC, histories.id ASC").
+		Scan(&transitions)
+	for _, t := range transitions {
+		if item, ok := byID[t.ObjectID]; ok {
+			item.Transitions = append(item.Transitions, utils.StatusTransition{At: t.Date, From: t.Old, To: t.New})
+		}
+	}
+	return items
+}
+
+// parseFlowPeriod 解析统计周期（start_date、end_date，格式 YYYY-MM-DD，默认最近30天）
+// 返回周期开始日期的零点和周期结束日期次日的零点
+func parseFlowPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	end := today
+	if value := c.Query("end_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			utils.Error(c, 400, "结束日期格式错误，应为 YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, -29)
+	if value := c.Query("start_date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			utils.Error(c, 400, "开始日期格式错误，应为 YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		start = parsed
+	}
+
+	if start.After(end) {
+		utils.Error(c, 400, "开始日期不能晚于结束日期")
+		return time.Time{}, time.Time{}, false
+	}
+	if end.Sub(start) > maxFlowPeriodDays*24*time.Hour {
+		utils.Error(c, 400, "统计周期不能超过一年")
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end.AddDate(0, 0, 1), true
+}
+
+// GetProjectFlow 获取项目的流动分析：状态停留时长、前置时间和周期时间分布、累积流图、每周吞吐量和在制品老化
+// 数据来自操作记录中的状态变更历史，支持任务、Bug和需求
+func (h *ProjectHandler) GetProjectFlow(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	// 权限检查：普通用户只能查看自己参与的项目
+	if !utils.CheckProjectAccess(h.db, c, project.ID) {
+		utils.Error(c, 403, "没有权限访问该项目")
+		return
+	}
+
+	objectType := c.DefaultQuery("object_type", "task")
+	workflow, ok := utils.FlowWorkflows[objectType]
+	if !ok {
+		utils.Error(c, 400, "不支持的对象类型，有效值：task, bug, requirement")
+		return
+	}
+
+	periodStart, periodEnd, ok := parseFlowPeriod(c)
+	if !ok {
+		return
+	}
+	// 统计截止时间：周期结束时间和当前时间中较早的一个
+	asOf := time.Now()
+	if periodEnd.Before(asOf) {
+		asOf = periodEnd
+	}
+
+	items := h.loadFlowItems(c, project.ID, objectType, periodEnd)
+
+	var leadTimes, cycleTimes []float64
+	statusHours := make(map[string]float64)
+	statusItems := make(map[string]int)
+	throughputByWeek := make(map[string]int)
+	itemList := make([]gin.H, 0)
+	aging := make([]gin.H, 0)
+	spansByItem := make([][]utils.StatusSpan, len(items))
+
+	for i, item := range items {
+		stats := workflow.ItemStats(item, asOf)
+		spansByItem[i] = stats.Spans
+
+		completed := stats.DoneAt != nil && !stats.DoneAt.Before(periodStart) && stats.DoneAt.Before(periodEnd)
+		inProgress := stats.DoneAt == nil && stats.StartedAt != nil
+
+		entry := gin.H{
+			"id":             item.ID,
+			"title":          item.Title,
+			"status":         utils.StatusAt(stats.Spans, asOf),
+			"created_at":     item.CreatedAt,
+			"started_at":     stats.StartedAt,
+			"done_at":        stats.DoneAt,
+			"time_in_status": roundHours(stats.TimeInStatus),
+		}
+
+		if completed {
+			lead := stats.DoneAt.Sub(item.CreatedAt).Hours() / 24
+			leadTimes = append(leadTimes, lead)
+			entry["lead_time_days"] = utils.RoundFlow(lead)
+			if stats.StartedAt != nil {
+				cycle := stats.DoneAt.Sub(*stats.StartedAt).Hours() / 24
+				cycleTimes = append(cycleTimes, cycle)
+				entry["cycle_time_days"] = utils.RoundFlow(cycle)
+			}
+			throughputByWeek[utils.WeekStart(*stats.DoneAt).Format("2006-01-02")]++
+
+			// 状态停留时长按周期内完成的对象统计
+			for status, hours := range stats.TimeInStatus {
+				statusHours[status] += hours
+				statusItems[status]++
+			}
+		}
+
+		if inProgress {
+			current := stats.Spans[len(stats.Spans)-1]
+			for j := len(stats.Spans) - 1; j >= 0; j-- {
+				if !stats.Spans[j].Start.After(asOf) {
+					current = stats.Spans[j]
+					break
+				}
+			}
+			aging = append(aging, gin.H{
+				"id":              item.ID,
+				"title":           item.Title,
+				"status":          current.Status,
+				"started_at":      stats.StartedAt,
+				"age_days":        utils.RoundFlow(asOf.Sub(*stats.StartedAt).Hours() / 24),
+				"status_age_days": utils.RoundFlow(asOf.Sub(current.Start).Hours() / 24),
+			})
+		}
+
+		if completed || inProgress {
+			itemList = append(itemList, entry)
+		}
+	}
+
+	// 在制品按已处理时长倒序
+	sort.SliceStable(aging, func(i, j int) bool {
+		return aging[i]["age_days"].(float64) > aging[j]["age_days"].(float64)
+	})
+
+	// 状态停留时长（按工作流状态顺序）
+	timeInStatus := make([]gin.H, 0, len(workflow.Statuses))
+	for _, status := range flowStatuses(workflow, statusHours) {
+		timeInStatus = append(timeInStatus, gin.H{
+			"status":        status,
+			"total_hours":   utils.RoundFlow(statusHours[status]),
+			"average_hours": utils.RoundFlow(statusHours[status] / float64(statusItems[status])),
+			"items":         statusItems[status],
+		})
+	}
+
+	// 累积流图：每天结束时各状态的对象数量（不包含今天之后的日期）
+	cumulativeFlow := make([]gin.H, 0)
+	for day := periodStart; day.Before(periodEnd) && !day.After(asOf); day = day.AddDate(0, 0, 1) {
+		at := day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		if at.After(asOf) {
+			at = asOf
+		}
+		counts := make(map[string]int, len(workflow.Statuses))
+		for _, status := range workflow.Statuses {
+			counts[status] = 0
+		}
+		for _, spans := range spansByItem {
+			if status := utils.StatusAt(spans, at); status != "" {
+				counts[status]++
+			}
+		}
+		cumulativeFlow = append(cumulativeFlow, gin.H{"date": day.Format("2006-01-02"), "counts": counts})
+	}
+
+	// 每周吞吐量（周一为一周的开始）
+	throughput := make([]gin.H, 0)
+	for week := utils.WeekStart(periodStart); week.Before(periodEnd); week = week.AddDate(0, 0, 7) {
+		key := week.Format("2006-01-02")
+		throughput = append(throughput, gin.H{"week_start": key, "count": throughputByWeek[key]})
+	}
+
+	utils.Success(c, gin.H{
+		"object_type":             objectType,
+		"start_date":              periodStart.Format("2006-01-02"),
+		"end_date":                periodEnd.AddDate(0, 0, -1).Format("2006-01-02"),
+		"statuses":                workflow.Statuses,
+		"lead_time":               utils.NewDurationStats(leadTimes),
+		"cycle_time":              utils.NewDurationStats(cycleTimes),
+		"lead_time_distribution":  durationHistogram(leadTimes),
+		"cycle_time_distribution": durationHistogram(cycleTimes),
+		"time_in_status":          timeInStatus,
+		"cumulative_flow":         cumulativeFlow,
+		"throughput":              throughput,
+		"aging_wip":               aging,
+		"items":                   itemList,
+	})
+}
+
+// flowStatuses 按工作流顺序列出状态，工作流之外的状态排在最后
+func flowStatuses(workflow utils.FlowWorkflow, hours map[string]float64) []string {
+	statuses := make([]string, 0, len(hours))
+	known := make(map[string]bool, len(workflow.Statuses))
+	for _, status := range workflow.Statuses {
+		known[status] = true
+		if _, ok := hours[status]; ok {
+			statuses = append(statuses, status)
+		}
+	}
+	extra := make([]string, 0)
+	for status := range hours {
+		if !known[status] {
+			extra = append(extra, status)
+		}
+	}
+	sort.Strings(extra)
+	return append(statuses, extra...)
+}
+
+// durationHistogram 按天数分组的时长分布（不足1天计为第1天）
+func durationHistogram(values []float64) []gin.H {
+	counts := make(map[int]int)
+	maxDays := 0
+	for _, v := range values {
+		days := int(math.Ceil(v))
+		if days < 1 {
+			days = 1
+		}
+		counts[days]++
+		if days > maxDays {
+			maxDays = days
+		}
+	}
+
+	result := make([]gin.H, 0, maxDays)
+	for days := 1; days <= maxDays; days++ {
+		result = append(result, gin.H{"days": days, "count": counts[days]})
+	}
+	return result
+}
+
+// roundHours 停留时长保留两位小数
+func roundHours(hours map[string]float64) map[string]float64 {
+	result := make(map[string]float64, len(hours))
+	for status, h := range hours {
+		result[status] = utils.RoundFlow(h)
+	}
+	return result
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProjectRoles 获取项目角色列表
+func (h *PermissionHandler) GetProjectRoles(c *gin.Context) {
+	var roles []model.ProjectRole
+	if err := h.db.Preload("Permissions").Order("id ASC").Find(&roles).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, roles)
+}
+
+// GetProjectRole 获取项目角色详情
+func (h *PermissionHandler) GetProjectRole(c *gin.Context) {
+	id := c.Param("id")
+	var role model.ProjectRole
+	if err := h.db.Preload("Permissions").First(&role, id).Error; err != nil {
+		utils.Error(c, 404, "项目角色不存在")
+		return
+	}
+
+	utils.Success(c, role)
+}
+
+// CreateProjectRole 创建项目角色
+func (h *PermissionHandler) CreateProjectRole(c *gin.Context) {
+	var req struct {
+		Name          string `json:"name" binding:"required"`
+		Code          string `json:"code" binding:"required"`
+		Description   string `json:"description"`
+		Status        int    `json:"status"`
+		PermissionIDs []uint `json:"permission_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	// 检查角色代码是否已存在
+	var existingRole model.ProjectRole
+	if err := h.db.Where("code = ?", req.Code).First(&existingRole).Error; err == nil {
+		utils.Error(c, 400, "项目角色代码已存在")
+		return
+	}
+
+	// 设置默认状态
+	if req.Status == 0 {
+		req.Status = 1
+	}
+
+	role := model.ProjectRole{
+		Name:        req.Name,
+		Code:        req.Code,
+		Description: req.Description,
+		Status:      req.Status,
+	}
+
+	if err := h.db.Create(&role).Error; err != nil {
+		if utils.IsUniqueConstraintError(err) {
+			utils.Error(c, 400, "项目角色代码或名称已存在")
+			return
+		}
+		utils.Error(c, utils.CodeError, "创建失败: "+err.Error())
+		return
+	}
+
+	if len(req.PermissionIDs) > 0 {
+		var permissions []model.Permission
+		h.db.Where("id IN ?", req.PermissionIDs).Find(&permissions)
+		if err := h.db.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+			utils.Error(c, utils.CodeError, "分配权限失败")
+			return
+		}
+	}
+
+	// 记录审计日志
+	userID, _ := c.Get("user_id")
+	username, _ := c.Get("username")
+	utils.RecordAuditLog(h.db, userID.(uint), username.(string), "create", "project_role", role.ID, c, true, "", "")
+
+	// 成员角色按名称匹配新角色代码，受限对象的可见范围可能变化
+	invalidateAllLiveViewers()
+
+	h.db.Preload("Permissions").First(&role, role.ID)
+	utils.Success(c, role)
+}
+
+// UpdateProjectRole 更新项目角色
+func (h *PermissionHandler) UpdateProjectRole(c *gin.Context) {
+	id := c.Param("id")
+	var role model.ProjectRole
+	if err := h.db.First(&role, id).Error; err != nil {
+		utils.Error(c, 404, "项目角色不存在")
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name"`
+		Code        *string `json:"code"`
+		Description *string `json:"description"`
+		Status      *int    `json:"status"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		role.Name = *req.Name
+	}
+	if req.Code != nil {
+		// 检查角色代码是否已被其他项目角色使用
+		var existingRole model.ProjectRole
+		if err := h.db.Where("code = ? AND id != ?", *req.Code, id).First(&existingRole).Error; err == nil {
+			utils.Error(c, 400, "项目角色代码已存在")
+			return
+		}
+		role.Code = *req.Code
+	}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Status != nil {
+		role.Status = *req.Status
+	}
+
+	if err := h.db.Omit("Permissions").Save(&role).Error; err != nil {
+		if utils.IsUniqueConstraintError(err) {
+			utils.Error(c, 400, "项目角色代码或名称已存在")
+			return
+		}
+		utils.Error(c, utils.CodeError, "更新失败: "+err.Error())
+		return
+	}
+
+	// 记录审计日志
+	userID, _ := c.Get("user_id")
+	username, _ := c.Get("username")
+	utils.RecordAuditLog(h.db, userID.(uint), username.(string), "update", "project_role", role.ID, c, true, "", "")
+
+	// 角色名称或代码变更后，受限对象的可见范围可能变化
+	invalidateAllLiveViewers()
+
+	utils.Success(c, role)
+}
+
+// DeleteProjectRole 删除项目角色
+func (h *PermissionHandler) DeleteProjectRole(c *gin.Context) {
+	id := c.Param("id")
+	var role model.ProjectRole
+	if err := h.db.First(&role, id).Error; err != nil {
+		utils.Error(c, 404, "项目角色不存在")
+		return
+	}
+
+	// 检查是否有项目成员使用此角色
+	var count int64
+	h.db.Model(&model.ProjectMember{}).Where("role = ? OR role = ?", role.Code, role.Name).Count(&count)
+	if count > 0 {
+		utils.Error(c, 400, "该项目角色正在被项目成员使用，无法删除")
+		return
+	}
+
+	// 记录审计日志（在删除前记录）
+	userID, _ := c.Get("user_id")
+	username, _ := c.Get("username")
+	utils.RecordAuditLog(h.db, userID.(uint), username.(string), "delete", "project_role", role.ID, c, true, "", "")
+
+	h.db.Model(&role).Association("Permissions").Clear()
+	if err := h.db.Delete(&role).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// AssignProjectRolePermissions 分配项目角色权限
+func (h *PermissionHandler) AssignProjectRolePermissions(c *gin.Context) {
+	roleID := c.Param("id")
+	var req struct {
+		PermissionIDs []uint `json:"permission_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	var role model.ProjectRole
+	if err := h.db.First(&role, roleID).Error; err != nil {
+		utils.Error(c, 404, "项目角色不存在")
+		return
+	}
+
+	var permissions []model.Permission
+	if err := h.db.Where("id IN ?", req.PermissionIDs).Find(&permissions).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询权限失败")
+		return
+	}
+
+	if err := h.db.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+		utils.Error(c, utils.CodeError, "分配权限失败")
+		return
+	}
+
+	// 记录审计日志（备注为分配后的权限代码）
+	codes := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		codes = append(codes, permission.Code)
+	}
+	userID, _ := c.Get("user_id")
+	username, _ := c.Get("username")
+	utils.RecordAuditLog(h.db, userID.(uint), username.(string), "assign_permissions", "project_role", role.ID, c, true, "", strings.Join(codes, ","))
+
+	utils.Success(c, gin.H{"message": "分配成功"})
+}
+
+// GetProjectUserPermissions 获取当前用户在项目内的有效权限
+// scope 为 project 表示权限来自项目角色，global 表示使用全局角色权限，admin 表示管理员
+func (h *PermissionHandler) GetProjectUserPermissions(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "无效的项目ID")
+		return
+	}
+
+	if utils.IsAdmin(c) {
+		utils.Success(c, gin.H{"scope": "admin", "member": true, "permissions": []string{}})
+		return
+	}
+
+	if !utils.CheckProjectAccess(h.db, c, uint(projectID)) {
+		utils.Error(c, 403, "没有权限访问该项目")
+		return
+	}
+
+	perms, member, scoped := utils.GetProjectPermissions(h.db, uint(projectID), utils.GetUserID(c))
+	scope := "project"
+	if !scoped {
+		scope = "global"
+		if list, ok := c.Get("permissions"); ok {
+			perms, _ = list.([]string)
+		}
+	}
+	if perms == nil {
+		perms = []string{}
+	}
+	sort.Strings(perms)
+
+	utils.Success(c, gin.H{"scope": scope, "member": member, "permissions": perms})
+}
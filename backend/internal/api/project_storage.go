@@ -0,0 +1,66 @@
+package api
+
+import (
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProjectStorage 获取项目附件存储使用情况和配额
+func (h *ProjectHandler) GetProjectStorage(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+	if !utils.CheckProjectAccess(h.db, c, project.ID) {
+		utils.Error(c, 403, "没有权限访问该项目")
+		return
+	}
+
+	used, files, err := utils.ProjectStorageUsage(h.db, project.ID)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "统计存储空间失败: "+err.Error())
+		return
+	}
+	storageType := "local"
+	if store, err := utils.AttachmentStorage(); err == nil {
+		storageType = store.Type()
+	}
+	utils.Success(c, gin.H{
+		"project_id": project.ID,
+		"used":       used,
+		"files":      files,
+		"quota":      project.StorageQuota,
+		"storage":    storageType,
+	})
+}
+
+// UpdateProjectStorageQuota 设置项目附件存储配额（字节，0 表示不限制）
+// 配额低于已使用空间时不影响已有附件，只阻止新的上传
+func (h *ProjectHandler) UpdateProjectStorageQuota(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	var req struct {
+		Quota *int64 `json:"quota" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if *req.Quota < 0 {
+		utils.Error(c, 400, "存储配额不能为负数")
+		return
+	}
+
+	if err := h.db.Model(&project).Update("storage_quota", *req.Quota).Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新存储配额失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"project_id": project.ID, "quota": project.StorageQuota})
+}
@@ -0,0 +1,902 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProjectTemplateHandler struct {
+	db *gorm.DB
+}
+
+func NewProjectTemplateHandler(db *gorm.DB) *ProjectTemplateHandler {
+	return &ProjectTemplateHandler{db: db}
+}
+
+// GetProjectTemplates 获取项目模板列表
+func (h *ProjectTemplateHandler) GetProjectTemplates(c *gin.Context) {
+	var templates []model.ProjectTemplate
+	query := h.db.Model(&model.ProjectTemplate{}).Preload("Creator")
+
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("name LIKE ? OR description LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	page := utils.GetPage(c)
+	pageSize := utils.GetPageSize(c)
+	offset := (page - 1) * pageSize
+
+	var total int64
+	query.Count(&total)
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(pageSize).Find(&templates).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":  templates,
+		"total": total,
+		"page":  page,
+		"size":  pageSize,
+	})
+}
+
+// GetProjectTemplate 获取项目模板详情
+func (h *ProjectTemplateHandler) GetProjectTemplate(c *gin.Context) {
+	id := c.Param("id")
+	var template model.ProjectTemplate
+	if err := h.db.Preload("Creator").First(&template, id).Error; err != nil {
+		utils.Error(c, 404, "项目模板不存在")
+		return
+	}
+
+	utils.Success(c, template)
+}
+
+// CreateProjectTemplate 创建项目模板
+func (h *ProjectTemplateHandler) CreateProjectTemplate(c *gin.Context) {
+	var req struct {
+		Name        string                       `json:"name" binding:"required"`
+		Description string                       `json:"description"`
+		Content     model.ProjectTemplateContent `json:"content"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	if err := validateTemplateContent(&req.Content); err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	template := model.ProjectTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatorID:   utils.GetUserID(c),
+		Content:     req.Content,
+	}
+
+	if err := h.db.Create(&template).Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建失败")
+		return
+	}
+
+	utils.Success(c, template)
+}
+
+// UpdateProjectTemplate 更新项目模板
+func (h *ProjectTemplateHandler) UpdateProjectTemplate(c *gin.Context) {
+	id := c.Param("id")
+	var template model.ProjectTemplate
+	if err := h.db.First(&template, id).Error; err != nil {
+		utils.Error(c, 404, "项目模板不存在")
+		return
+	}
+
+	var req struct {
+		Name        *string                       `json:"name"`
+		Description *string                       `json:"description"`
+		Content     *model.ProjectTemplateContent `json:"content"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	if req.Name != nil {
+		template.Name = *req.Name
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.Content != nil {
+		if err := validateTemplateContent(req.Content); err != nil {
+			utils.Error(c, 400, err.Error())
+			return
+		}
+		template.Content = *req.Content
+	}
+
+	if err := h.db.Save(&template).Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+
+	utils.Success(c, template)
+}
+
+// DeleteProjectTemplate 删除项目模板
+func (h *ProjectTemplateHandler) DeleteProjectTemplate(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.db.Delete(&model.ProjectTemplate{}, id).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// SaveProjectAsTemplate 将现有项目保存为模板
+func (h *ProjectTemplateHandler) SaveProjectAsTemplate(c *gin.Context) {
+	projectID := c.Param("id")
+	var project model.Project
+	if err := h.db.First(&project, projectID).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	// 权限检查：普通用户只能将自己参与的项目保存为模板
+	if !utils.CheckProjectAccess(h.db, c, project.ID) {
+		utils.Error(c, 403, "没有权限访问该项目")
+		return
+	}
+
+	var req struct {
+		Name                string `json:"name" binding:"required"`
+		Description         string `json:"description"`
+		IncludeRequirements bool   `json:"include_requirements"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	// 模板对所有用户可见，不包含受限的需求和任务
+	content, err := buildTemplateContentFromProject(h.db, c, &project, req.IncludeRequirements, true)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "读取项目数据失败")
+		return
+	}
+
+	template := model.ProjectTemplate{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatorID:   utils.GetUserID(c),
+		Content:     *content,
+	}
+
+	if err := h.db.Create(&template).Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建失败")
+		return
+	}
+
+	utils.Success(c, template)
+}
+
+// CreateProjectFromTemplate 从模板创建项目
+func (h *ProjectTemplateHandler) CreateProjectFromTemplate(c *gin.Context) {
+	id := c.Param("id")
+	var template model.ProjectTemplate
+	if err := h.db.First(&template, id).Error; err != nil {
+		utils.Error(c, 404, "项目模板不存在")
+		return
+	}
+
+	var req struct {
+		Name        string  `json:"name" binding:"required"`
+		Code        string  `json:"code"`
+		Description string  `json:"description"`
+		StartDate   *string `json:"start_date"` // 接收字符串格式的日期
+		EndDate     *string `json:"end_date"`   // 接收字符串格式的日期
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	if err := validateTemplateContent(&template.Content); err != nil {
+		utils.Error(c, 400, "模板内容无效: "+err.Error())
+		return
+	}
+
+	startDate, err := parseTime(stringValue(req.StartDate))
+	if err != nil {
+		utils.Error(c, 400, "开始日期格式错误")
+		return
+	}
+	endDate, err := parseTime(stringValue(req.EndDate))
+	if err != nil {
+		utils.Error(c, 400, "结束日期格式错误")
+		return
+	}
+
+	project := model.Project{
+		Name:        req.Name,
+		Code:        req.Code,
+		Description: req.Description,
+		Status:      "wait",
+		StartDate:   startDate,
+		EndDate:     endDate,
+	}
+
+	userID := utils.GetUserID(c)
+	summary, err := h.createProjectWithContent(&project, &template.Content, userID)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "创建失败: "+err.Error())
+		return
+	}
+
+	if userID > 0 {
+		utils.RecordAction(h.db, "project", project.ID, "created", userID, fmt.Sprintf("从模板「%s」创建", template.Name), gin.H{"template_id": template.ID})
+	}
+
+	h.db.Preload("Members.User").Preload("Tags").Preload("Modules").First(&project, project.ID)
+
+	utils.Success(c, gin.H{
+		"project": project,
+		"summary": summary,
+	})
+}
+
+// CloneProject 克隆现有项目（可选包含未关闭的需求）
+func (h *ProjectTemplateHandler) CloneProject(c *gin.Context) {
+	projectID := c.Param("id")
+	var source model.Project
+	if err := h.db.First(&source, projectID).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	// 权限检查：普通用户只能克隆自己参与的项目
+	if !utils.CheckProjectAccess(h.db, c, source.ID) {
+		utils.Error(c, 403, "没有权限访问该项目")
+		return
+	}
+
+	var req struct {
+		Name                string  `json:"name" binding:"required"`
+		Code                string  `json:"code"`
+		Description         *string `json:"description"`
+		StartDate           *string `json:"start_date"` // 接收字符串格式的日期，不传则沿用原项目
+		EndDate             *string `json:"end_date"`   // 接收字符串格式的日期
+		IncludeRequirements bool    `json:"include_requirements"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return
+	}
+
+	content, err := buildTemplateContentFromProject(h.db, c, &source, req.IncludeRequirements, false)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "读取项目数据失败")
+		return
+	}
+
+	project := model.Project{
+		Name:        req.Name,
+		Code:        req.Code,
+		Description: source.Description,
+		Status:      "wait",
+		StartDate:   source.StartDate,
+		EndDate:     source.EndDate,
+	}
+	if req.Description != nil {
+		project.Description = *req.Description
+	}
+	if req.StartDate != nil {
+		if project.StartDate, err = parseTime(*req.StartDate); err != nil {
+			utils.Error(c, 400, "开始日期格式错误")
+			return
+		}
+	}
+	if req.EndDate != nil {
+		if project.EndDate, err = parseTime(*req.EndDate); err != nil {
+			utils.Error(c, 400, "结束日期格式错误")
+			return
+		}
+	}
+
+	userID := utils.GetUserID(c)
+	summary, err := h.createProjectWithContent(&project, content, userID)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "克隆失败: "+err.Error())
+		return
+	}
+
+	if userID > 0 {
+		utils.RecordAction(h.db, "project", project.ID, "created", userID, fmt.Sprintf("从项目「%s」克隆", source.Name), gin.H{"source_project_id": source.ID})
+	}
+
+	h.db.Preload("Members.User").Preload("Tags").Preload("Modules").First(&project, project.ID)
+
+	utils.Success(c, gin.H{
+		"project": project,
+		"summary": summary,
+	})
+}
+
+// createProjectWithContent 在事务中创建项目并应用模板内容
+func (h *ProjectTemplateHandler) createProjectWithContent(project *model.Project, content *model.ProjectTemplateContent, creatorID uint) (gin.H, error) {
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := tx.Create(project).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	summary, err := applyTemplateContent(tx, project, content, creatorID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// applyTemplateContent 将模板内容应用到新项目，所有模板内引用重新映射为新ID
+func applyTemplateContent(tx *gorm.DB, project *model.Project, content *model.ProjectTemplateContent, creatorID uint) (gin.H, error) {
+	baseDate := templateBaseDate(project)
+
+	// 成员（创建者默认为项目经理）
+	memberRoles := make(map[uint]string)
+	memberOrder := make([]uint, 0, len(content.Members)+1)
+	for _, m := range content.Members {
+		if _, exists := memberRoles[m.UserID]; !exists {
+			memberOrder = append(memberOrder, m.UserID)
+		}
+		memberRoles[m.UserID] = m.Role
+	}
+	if creatorID > 0 {
+		if _, exists := memberRoles[creatorID]; !exists {
+			memberOrder = append(memberOrder, creatorID)
+			memberRoles[creatorID] = "项目经理"
+		}
+	}
+	referencedUserIDs := append([]uint{}, memberOrder...)
+	for _, r := range content.Requirements {
+		if r.AssigneeID != nil {
+			referencedUserIDs = append(referencedUserIDs, *r.AssigneeID)
+		}
+		referencedUserIDs = append(referencedUserIDs, templateAccessUserIDs(r.Access)...)
+	}
+	for _, t := range content.Tasks {
+		if t.AssigneeID != nil {
+			referencedUserIDs = append(referencedUserIDs, *t.AssigneeID)
+		}
+		referencedUserIDs = append(referencedUserIDs, templateAccessUserIDs(t.Access)...)
+	}
+	var existingUserIDs []uint
+	if len(referencedUserIDs) > 0 {
+		if err := tx.Model(&model.User{}).Where("id IN ?", referencedUserIDs).Pluck("id", &existingUserIDs).Error; err != nil {
+			return nil, err
+		}
+	}
+	existingUsers := make(map[uint]bool, len(existingUserIDs))
+	for _, id := range existingUserIDs {
+		existingUsers[id] = true
+	}
+	memberCount := 0
+	for _, uid := range memberOrder {
+		// 跳过已不存在的用户
+		if !existingUsers[uid] {
+			continue
+		}
+		member := model.ProjectMember{ProjectID: project.ID, UserID: uid, Role: memberRoles[uid]}
+		if err := tx.Create(&member).Error; err != nil {
+			return nil, err
+		}
+		memberCount++
+	}
+
+	// 标签
+	if len(content.TagIDs) > 0 {
+		var tags []model.Tag
+		if err := tx.Where("id IN ?", content.TagIDs).Find(&tags).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(project).Association("Tags").Replace(tags); err != nil {
+			return nil, err
+		}
+	}
+
+	// 功能模块
+	if len(content.ModuleIDs) > 0 {
+		var modules []model.Module
+		if err := tx.Where("id IN ?", content.ModuleIDs).Find(&modules).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(project).Association("Modules").Replace(modules); err != nil {
+			return nil, err
+		}
+	}
+
+	// 看板及列
+	for _, tb := range content.Boards {
+		board := model.Board{Name: tb.Name, Description: tb.Description, CardTypes: tb.CardTypes, SwimlaneBy: tb.SwimlaneBy, ProjectID: project.ID}
+		if err := tx.Create(&board).Error; err != nil {
+			return nil, err
+		}
+		for i, tc := range tb.Columns {
+			column := model.BoardColumn{
+				Name:              tc.Name,
+				Color:             tc.Color,
+				Status:            tc.Status,
+				BugStatus:         tc.BugStatus,
+				RequirementStatus: tc.RequirementStatus,
+				WIPLimit:          tc.WIPLimit,
+				WIPPolicy:         tc.WIPPolicy,
+				Sort:              tc.Sort,
+				BoardID:           board.ID,
+			}
+			if column.Sort == 0 {
+				column.Sort = i
+			}
+			if err := tx.Create(&column).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 需求
+	requirementIDs := make(map[string]uint, len(content.Requirements))
+	for _, tr := range content.Requirements {
+		requirement := model.Requirement{
+			Title:          tr.Title,
+			Description:    tr.Description,
+			Status:         tr.Status,
+			Priority:       tr.Priority,
+			ProjectID:      project.ID,
+			CreatorID:      creatorID,
+			AssigneeID:     validAssignee(tr.AssigneeID, existingUsers),
+			EstimatedHours: tr.EstimatedHours,
+			Confidential:   tr.Confidential,
+		}
+		if requirement.Status == "" {
+			requirement.Status = "draft"
+		}
+		if requirement.Priority == "" {
+			requirement.Priority = "medium"
+		}
+		if err := tx.Create(&requirement).Error; err != nil {
+			return nil, err
+		}
+		if err := createTemplateAccess(tx, "requirement", requirement.ID, tr.Access, existingUsers, creatorID); err != nil {
+			return nil, err
+		}
+		requirementIDs[tr.Key] = requirement.ID
+	}
+
+	// 任务
+	taskIDs := make(map[string]uint, len(content.Tasks))
+	for _, tt := range content.Tasks {
+		task := model.Task{
+			Title:          tt.Title,
+			Description:    tt.Description,
+			Status:         "wait",
+			Priority:       tt.Priority,
+			ProjectID:      project.ID,
+			CreatorID:      creatorID,
+			AssigneeID:     validAssignee(tt.AssigneeID, existingUsers),
+			EstimatedHours: tt.EstimatedHours,
+			StartDate:      offsetDate(baseDate, tt.StartOffset),
+			EndDate:        offsetDate(baseDate, tt.EndOffset),
+			DueDate:        offsetDate(baseDate, tt.DueOffset),
+			Confidential:   tt.Confidential,
+		}
+		if task.Priority == "" {
+			task.Priority = "medium"
+		}
+		if tt.RequirementKey != "" {
+			if reqID, ok := requirementIDs[tt.RequirementKey]; ok {
+				task.RequirementID = &reqID
+			}
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		if err := createTemplateAccess(tx, "task", task.ID, tt.Access, existingUsers, creatorID); err != nil {
+			return nil, err
+		}
+		taskIDs[tt.Key] = task.ID
+	}
+
+	// 任务依赖
+	dependencyCount := 0
+	for _, tt := range content.Tasks {
+		for _, depKey := range tt.DependsOn {
+			dependency := model.TaskDependency{
+				TaskID:       taskIDs[tt.Key],
+				DependencyID: taskIDs[depKey],
+				Type:         "finish_to_start",
+			}
+			if err := tx.Create(&dependency).Error; err != nil {
+				return nil, err
+			}
+			dependencyCount++
+		}
+	}
+
+	// 版本
+	for _, tv := range content.Versions {
+		version := model.Version{
+			VersionNumber: tv.VersionNumber,
+			ReleaseNotes:  tv.ReleaseNotes,
+			Status:        "wait",
+			ProjectID:     project.ID,
+			ReleaseDate:   offsetDate(baseDate, tv.ReleaseOffset),
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return gin.H{
+		"members":      memberCount,
+		"boards":       len(content.Boards),
+		"requirements": len(content.Requirements),
+		"tasks":        len(content.Tasks),
+		"dependencies": dependencyCount,
+		"versions":     len(content.Versions),
+	}, nil
+}
+
+// templateAccessUserIDs 访问名单中的用户ID
+func templateAccessUserIDs(access []model.TemplateItemAccess) []uint {
+	ids := make([]uint, 0, len(access))
+	for _, a := range access {
+		if a.UserID != nil {
+			ids = append(ids, *a.UserID)
+		}
+	}
+	return ids
+}
+
+// createTemplateAccess 为新建的受限需求或任务创建访问名单，跳过已不存在的用户
+func createTemplateAccess(tx *gorm.DB, objectType string, objectID uint, access []model.TemplateItemAccess, existingUsers map[uint]bool, creatorID uint) error {
+	for _, a := range access {
+		entry := model.ItemAccess{ObjectType: objectType, ObjectID: objectID, UserID: validAssignee(a.UserID, existingUsers), RoleCode: a.RoleCode, CreatorID: creatorID}
+		if entry.UserID == nil && entry.RoleCode == "" {
+			continue
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildTemplateContentFromProject 从现有项目生成模板内容
+// includeRequirements 为 true 时包含未关闭的需求，任务与需求的关联一并保留
+// shared 为 true 时不包含受限的需求和任务（保存为共享模板）；否则只包含当前用户可见的，并保留访问限制和访问名单（克隆项目）
+func buildTemplateContentFromProject(db *gorm.DB, c *gin.Context, project *model.Project, includeRequirements, shared bool) (*model.ProjectTemplateContent, error) {
+	content := &model.ProjectTemplateContent{}
+	baseDate := templateBaseDate(project)
+	visible := func(objectType string, query *gorm.DB) *gorm.DB {
+		if shared {
+			return query.Where("confidential = ?", false)
+		}
+		return utils.FilterRestrictedItems(c, objectType, query)
+	}
+	// 受限对象的访问名单
+	templateAccess := func(objectType string, ids []uint) (map[uint][]model.TemplateItemAccess, error) {
+		accesses, err := utils.LoadItemAccesses(db, objectType, ids)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[uint][]model.TemplateItemAccess, len(accesses))
+		for id, list := range accesses {
+			for _, a := range list {
+				result[id] = append(result[id], model.TemplateItemAccess{UserID: a.UserID, RoleCode: a.RoleCode})
+			}
+		}
+		return result, nil
+	}
+
+	var members []model.ProjectMember
+	if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		content.Members = append(content.Members, model.TemplateMember{UserID: m.UserID, Role: m.Role})
+	}
+
+	var loaded model.Project
+	if err := db.Preload("Tags").Preload("Modules").First(&loaded, project.ID).Error; err != nil {
+		return nil, err
+	}
+	for _, tag := range loaded.Tags {
+		content.TagIDs = append(content.TagIDs, tag.ID)
+	}
+	for _, module := range loaded.Modules {
+		content.ModuleIDs = append(content.ModuleIDs, module.ID)
+	}
+
+	var boards []model.Board
+	if err := db.Where("project_id = ?", project.ID).Preload("Columns", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort ASC")
+	}).Order("id ASC").Find(&boards).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range boards {
+		tb := model.TemplateBoard{Name: b.Name, Description: b.Description, CardTypes: b.CardTypes, SwimlaneBy: b.SwimlaneBy}
+		for _, col := range b.Columns {
+			tb.Columns = append(tb.Columns, model.TemplateBoardColumn{
+				Name:              col.Name,
+				Color:             col.Color,
+				Status:            col.Status,
+				BugStatus:         col.BugStatus,
+				RequirementStatus: col.RequirementStatus,
+				WIPLimit:          col.WIPLimit,
+				WIPPolicy:         col.WIPPolicy,
+				Sort:              col.Sort,
+			})
+		}
+		content.Boards = append(content.Boards, tb)
+	}
+
+	requirementKeys := make(map[uint]string)
+	if includeRequirements {
+		var requirements []model.Requirement
+		if err := visible("requirement", db.Where("project_id = ? AND status <> ?", project.ID, "closed")).Order("id ASC").Find(&requirements).Error; err != nil {
+			return nil, err
+		}
+		var restricted []uint
+		for _, r := range requirements {
+			if r.Confidential {
+				restricted = append(restricted, r.ID)
+			}
+		}
+		access, err := templateAccess("requirement", restricted)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range requirements {
+			key := fmt.Sprintf("requirement-%d", r.ID)
+			requirementKeys[r.ID] = key
+			content.Requirements = append(content.Requirements, model.TemplateRequirement{
+				Key:            key,
+				Title:          r.Title,
+				Description:    r.Description,
+				Status:         r.Status,
+				Priority:       r.Priority,
+				AssigneeID:     r.AssigneeID,
+				EstimatedHours: r.EstimatedHours,
+				Confidential:   r.Confidential,
+				Access:         access[r.ID],
+			})
+		}
+	}
+
+	var tasks []model.Task
+	if err := visible("task", db.Where("project_id = ?", project.ID)).Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	taskKeys := make(map[uint]string, len(tasks))
+	taskIDs := make([]uint, 0, len(tasks))
+	var restrictedTasks []uint
+	for _, t := range tasks {
+		taskKeys[t.ID] = fmt.Sprintf("task-%d", t.ID)
+		taskIDs = append(taskIDs, t.ID)
+		if t.Confidential {
+			restrictedTasks = append(restrictedTasks, t.ID)
+		}
+	}
+	taskAccess, err := templateAccess("task", restrictedTasks)
+	if err != nil {
+		return nil, err
+	}
+	dependsOn := make(map[uint][]string)
+	if len(taskIDs) > 0 {
+		var dependencies []model.TaskDependency
+		if err := db.Where("task_id IN ?", taskIDs).Find(&dependencies).Error; err != nil {
+			return nil, err
+		}
+		for _, dep := range dependencies {
+			// 只保留项目内部的依赖关系
+			if depKey, ok := taskKeys[dep.DependencyID]; ok {
+				dependsOn[dep.TaskID] = append(dependsOn[dep.TaskID], depKey)
+			}
+		}
+	}
+	for _, t := range tasks {
+		tt := model.TemplateTask{
+			Key:            taskKeys[t.ID],
+			Title:          t.Title,
+			Description:    t.Description,
+			Priority:       t.Priority,
+			AssigneeID:     t.AssigneeID,
+			EstimatedHours: t.EstimatedHours,
+			StartOffset:    dayOffset(baseDate, t.StartDate),
+			EndOffset:      dayOffset(baseDate, t.EndDate),
+			DueOffset:      dayOffset(baseDate, t.DueDate),
+			DependsOn:      dependsOn[t.ID],
+			Confidential:   t.Confidential,
+			Access:         taskAccess[t.ID],
+		}
+		if t.RequirementID != nil {
+			tt.RequirementKey = requirementKeys[*t.RequirementID]
+		}
+		content.Tasks = append(content.Tasks, tt)
+	}
+
+	var versions []model.Version
+	if err := db.Where("project_id = ?", project.ID).Order("id ASC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		content.Versions = append(content.Versions, model.TemplateVersion{
+			VersionNumber: v.VersionNumber,
+			ReleaseOffset: dayOffset(baseDate, v.ReleaseDate),
+		})
+	}
+
+	return content, nil
+}
+
+// validateTemplateContent 校验模板内容：Key 唯一、引用存在、依赖无环
+func validateTemplateContent(content *model.ProjectTemplateContent) error {
+	requirementKeys := make(map[string]bool, len(content.Requirements))
+	for _, r := range content.Requirements {
+		if r.Key == "" {
+			return fmt.Errorf("需求缺少key: %s", r.Title)
+		}
+		if requirementKeys[r.Key] {
+			return fmt.Errorf("需求key重复: %s", r.Key)
+		}
+		if r.Title == "" {
+			return fmt.Errorf("需求标题不能为空: %s", r.Key)
+		}
+		requirementKeys[r.Key] = true
+	}
+
+	taskDeps := make(map[string][]string, len(content.Tasks))
+	for _, t := range content.Tasks {
+		if t.Key == "" {
+			return fmt.Errorf("任务缺少key: %s", t.Title)
+		}
+		if _, exists := taskDeps[t.Key]; exists {
+			return fmt.Errorf("任务key重复: %s", t.Key)
+		}
+		if t.Title == "" {
+			return fmt.Errorf("任务标题不能为空: %s", t.Key)
+		}
+		if t.RequirementKey != "" && !requirementKeys[t.RequirementKey] {
+			return fmt.Errorf("任务 %s 关联的需求不存在: %s", t.Key, t.RequirementKey)
+		}
+		taskDeps[t.Key] = t.DependsOn
+	}
+	for key, deps := range taskDeps {
+		for _, dep := range deps {
+			if dep == key {
+				return fmt.Errorf("任务不能依赖自己: %s", key)
+			}
+			if _, exists := taskDeps[dep]; !exists {
+				return fmt.Errorf("任务 %s 依赖的任务不存在: %s", key, dep)
+			}
+		}
+	}
+
+	// 检查循环依赖（深度优先搜索）
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(taskDeps))
+	var visit func(key string) bool
+	visit = func(key string) bool {
+		switch state[key] {
+		case visiting:
+			return false
+		case visited:
+			return true
+		}
+		state[key] = visiting
+		for _, dep := range taskDeps[key] {
+			if !visit(dep) {
+				return false
+			}
+		}
+		state[key] = visited
+		return true
+	}
+	for _, t := range content.Tasks {
+		if !visit(t.Key) {
+			return fmt.Errorf("任务依赖存在循环: %s", t.Key)
+		}
+	}
+
+	for _, b := range content.Boards {
+		if b.Name == "" {
+			return fmt.Errorf("看板名称不能为空")
+		}
+	}
+	for _, v := range content.Versions {
+		if v.VersionNumber == "" {
+			return fmt.Errorf("版本号不能为空")
+		}
+	}
+
+	return nil
+}
+
+// templateBaseDate 模板相对日期的基准：项目开始日期，未设置时使用创建日期
+func templateBaseDate(project *model.Project) time.Time {
+	base := project.CreatedAt
+	if project.StartDate != nil {
+		base = *project.StartDate
+	}
+	if base.IsZero() {
+		base = time.Now()
+	}
+	return time.Date(base.Year(), base.Month(), base.Day(), 0, 0, 0, 0, base.Location())
+}
+
+// offsetDate 根据天数偏移计算日期
+func offsetDate(base time.Time, offset *int) *time.Time {
+	if offset == nil {
+		return nil
+	}
+	t := base.AddDate(0, 0, *offset)
+	return &t
+}
+
+// dayOffset 计算日期相对基准日期的天数偏移
+func dayOffset(base time.Time, t *time.Time) *int {
+	if t == nil {
+		return nil
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, base.Location())
+	offset := int(day.Sub(base).Hours() / 24)
+	return &offset
+}
+
+// validAssignee 仅保留仍然存在的负责人
+func validAssignee(assigneeID *uint, existingUsers map[uint]bool) *uint {
+	if assigneeID == nil || !existingUsers[*assigneeID] {
+		return nil
+	}
+	id := *assigneeID
+	return &id
+}
+
+// stringValue 获取字符串指针的值
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// 发布门禁检查项
+const (
+	releaseGateBugs         = "blocking_bugs"
+	releaseGateRequirements = "open_requirements"
+	releaseGateTestPassRate = "test_pass_rate"
+)
+
+// releaseGateCheck 单个门禁检查项的结果
+type releaseGateCheck struct {
+	Key     string  `json:"key"`
+	Name    string  `json:"name"`
+	Passed  bool    `json:"passed"`
+	Message string  `json:"message"`
+	Items   []gin.H `json:"items,omitempty"` // 未通过时的明细（阻塞的Bug、未关闭的需求）
+}
+
+// releaseGateResult 版本的发布门禁检查结果
+type releaseGateResult struct {
+	Enabled bool               `json:"enabled"`
+	Passed  bool               `json:"passed"`
+	Checks  []releaseGateCheck `json:"checks"`
+}
+
+// failedKeys 返回未通过的检查项
+func (r *releaseGateResult) failedKeys() []string {
+	keys := make([]string, 0)
+	for _, check := range r.Checks {
+		if !check.Passed {
+			keys = append(keys, check.Key)
+		}
+	}
+	return keys
+}
+
+// loadReleaseGate 读取项目的发布门禁配置，未配置时返回未启用的默认配置
+func loadReleaseGate(db *gorm.DB, projectID uint) *model.ReleaseGate {
+	gate := model.ReleaseGate{ProjectID: projectID, BlockingBugSeverities: model.StringArray{}}
+	db.Where("project_id = ?", projectID).First(&gate)
+	return &gate
+}
+
+// evaluateReleaseGate 按项目的门禁配置检查版本能否发布
+func evaluateReleaseGate(db *gorm.DB, version *model.Version) (*releaseGateResult, error) {
+	gate := loadReleaseGate(db, version.ProjectID)
+	result := &releaseGateResult{Enabled: gate.Enabled, Passed: true, Checks: make([]releaseGateCheck, 0)}
+	if !gate.Enabled {
+		return result, nil
+	}
+
+	// 版本关联的高严重程度Bug必须全部解决
+	if len(gate.BlockingBugSeverities) > 0 {
+		var bugs []model.Bug
+		if err := db.Joins("JOIN version_bugs ON version_bugs.bug_id = bugs.id").
+			Where("version_bugs.version_id = ? AND bugs.status = ? AND bugs.severity IN ?", version.ID, "active", []string(gate.BlockingBugSeverities)).
+			Order("bugs.id ASC").Find(&bugs).Error; err != nil {
+			return nil, err
+		}
+		check := releaseGateCheck{
+			Key:     releaseGateBugs,
+			Name:    "未解决的严重Bug",
+			Passed:  len(bugs) == 0,
+			Message: fmt.Sprintf("严重程度为 %s 的未解决Bug：%d 个", strings.Join(gate.BlockingBugSeverities, "/"), len(bugs)),
+		}
+		for _, bug := range bugs {
+			check.Items = append(check.Items, gin.H{"id": bug.ID, "title": bug.Title, "severity": bug.Severity, "status": bug.Status})
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	// 版本关联的需求必须全部关闭
+	if gate.RequireRequirementsClosed {
+		var requirements []model.Requirement
+		if err := db.Joins("JOIN version_requirements ON version_requirements.requirement_id = requirements.id").
+			Where("version_requirements.version_id = ? AND requirements.status <> ?", version.ID, "closed").
+			Order("requirements.id ASC").Find(&requirements).Error; err != nil {
+			return nil, err
+		}
+		check := releaseGateCheck{
+			Key:     releaseGateRequirements,
+			Name:    "未关闭的需求",
+			Passed:  len(requirements) == 0,
+			Message: fmt.Sprintf("未关闭的需求：%d 个", len(requirements)),
+		}
+		for _, requirement := range requirements {
+			check.Items = append(check.Items, gin.H{"id": requirement.ID, "title": requirement.Title, "status": requirement.Status})
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	// 版本测试单的通过率：同一测试单以该版本中最近一次执行结果为准，未执行的测试单视为未通过
+	if gate.MinTestPassRate > 0 {
+		latest, _ := latestVersionCaseResults(db.Table("test_run_cases").Where("test_runs.version_id = ?", version.ID))
+		total, passed := len(latest[version.ID]), 0
+		for _, r := range latest[version.ID] {
+			if r == testResultPass {
+				passed++
+			}
+		}
+		passRate := 0.0
+		if total > 0 {
+			passRate = utils.RoundFlow(float64(passed) / float64(total) * 100)
+		}
+		check := releaseGateCheck{
+			Key:     releaseGateTestPassRate,
+			Name:    "测试通过率",
+			Passed:  total > 0 && passRate >= gate.MinTestPassRate,
+			Message: fmt.Sprintf("测试通过率 %.2f%%（%d/%d），要求不低于 %.2f%%", passRate, passed, total, gate.MinTestPassRate),
+		}
+		if total == 0 {
+			check.Message = "版本没有测试执行记录"
+		}
+		result.Checks = append(result.Checks, check)
+	}
+
+	for _, check := range result.Checks {
+		if !check.Passed {
+			result.Passed = false
+		}
+	}
+	return result, nil
+}
+
+// releaseOverride 发布时的门禁豁免参数（仅管理员可用，必须填写原因）
+type releaseOverride struct {
+	Override bool   `json:"override"`
+	Reason   string `json:"reason"`
+}
+
+// enforceReleaseGate 发布前检查门禁，未通过且未豁免时写入错误响应并返回 false
+func (h *VersionHandler) enforceReleaseGate(c *gin.Context, version *model.Version, override releaseOverride) (*releaseGateResult, bool) {
+	result, err := evaluateReleaseGate(h.db, version)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "检查发布门禁失败")
+		return nil, false
+	}
+	if result.Passed {
+		return result, true
+	}
+	if !override.Override {
+		utils.ErrorWithData(c, 400, "版本未通过发布门禁检查", result)
+		return nil, false
+	}
+	if !utils.IsAdmin(c) {
+		utils.Error(c, 403, "只有管理员可以跳过发布门禁")
+		return nil, false
+	}
+	if strings.TrimSpace(override.Reason) == "" {
+		utils.Error(c, 400, "跳过发布门禁必须填写原因")
+		return nil, false
+	}
+	return result, true
+}
+
+// recordRelease 记录版本发布操作，跳过门禁时记录原因和未通过的检查项
+func (h *VersionHandler) recordRelease(c *gin.Context, version *model.Version, oldStatus string, result *releaseGateResult, override releaseOverride) {
+	extra := gin.H{"gate_enabled": result.Enabled, "gate_passed": result.Passed}
+	comment := ""
+	if !result.Passed {
+		comment = strings.TrimSpace(override.Reason)
+		extra["override"] = true
+		extra["failed_checks"] = result.failedKeys()
+	}
+	actionID, _ := utils.RecordAction(h.db, "version", version.ID, "released", utils.GetUserID(c), comment, extra)
+	if oldStatus != version.Status {
+		utils.RecordHistory(h.db, actionID, []utils.HistoryChange{{Field: "status", Old: oldStatus, New: version.Status}})
+	}
+}
+
+// CheckReleaseGate 检查版本的发布门禁
+func (h *VersionHandler) CheckReleaseGate(c *gin.Context) {
+	var version model.Version
+	if err := h.db.First(&version, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "版本不存在")
+		return
+	}
+	result, err := evaluateReleaseGate(h.db, &version)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "检查发布门禁失败")
+		return
+	}
+	utils.Success(c, result)
+}
+
+// GetReleaseGate 获取项目的发布门禁配置
+func (h *VersionHandler) GetReleaseGate(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "无效的项目ID")
+		return
+	}
+	utils.Success(c, loadReleaseGate(h.db, uint(projectID)))
+}
+
+// UpdateReleaseGate 更新项目的发布门禁配置
+func (h *VersionHandler) UpdateReleaseGate(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+
+	var req struct {
+		Enabled                   *bool     `json:"enabled"`
+		BlockingBugSeverities     *[]string `json:"blocking_bug_severities"`
+		RequireRequirementsClosed *bool     `json:"require_requirements_closed"`
+		MinTestPassRate           *float64  `json:"min_test_pass_rate"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	gate := loadReleaseGate(h.db, project.ID)
+	if req.Enabled != nil {
+		gate.Enabled = *req.Enabled
+	}
+	if req.BlockingBugSeverities != nil {
+		severities := model.StringArray{}
+		for _, severity := range *req.BlockingBugSeverities {
+			switch severity {
+			case "low", "medium", "high", "critical":
+				severities = append(severities, severity)
+			default:
+				utils.Error(c, 400, "无效的严重程度，有效值：low, medium, high, critical")
+				return
+			}
+		}
+		gate.BlockingBugSeverities = severities
+	}
+	if req.RequireRequirementsClosed != nil {
+		gate.RequireRequirementsClosed = *req.RequireRequirementsClosed
+	}
+	if req.MinTestPassRate != nil {
+		if *req.MinTestPassRate < 0 || *req.MinTestPassRate > 100 {
+			utils.Error(c, 400, "测试通过率必须在0到100之间")
+			return
+		}
+		gate.MinTestPassRate = *req.MinTestPassRate
+	}
+
+	if err := h.db.Save(gate).Error; err != nil {
+		utils.Error(c, utils.CodeError, "保存发布门禁失败")
+		return
+	}
+	utils.Success(c, gate)
+}
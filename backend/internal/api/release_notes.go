@@ -0,0 +1,195 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// releaseNotesRenderer 可以渲染为 Markdown 和 HTML 的发布文档
+type releaseNotesRenderer interface {
+	Markdown() string
+	HTML() string
+}
+
+// writeReleaseDocument 按 format 参数输出发布文档：json（默认）、markdown、html，download=1 时作为附件下载
+func writeReleaseDocument(c *gin.Context, filename string, data interface{}, renderer releaseNotesRenderer) {
+	disposition := "inline"
+	if c.Query("download") == "1" {
+		disposition = "attachment"
+	}
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		utils.Success(c, data)
+	case "markdown", "md":
+		c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s.md\"", disposition, filename))
+		c.Data(200, "text/markdown; charset=utf-8", []byte(renderer.Markdown()))
+	case "html":
+		c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s.html\"", disposition, filename))
+		c.Data(200, "text/html; charset=utf-8", []byte(renderer.HTML()))
+	default:
+		utils.Error(c, 400, "无效的导出格式，有效值：json, markdown, html")
+	}
+}
+
+// releaseNotesScope 过滤当前用户不可见的受限需求和Bug
+func releaseNotesScope(c *gin.Context) func(objectType string, query *gorm.DB) *gorm.DB {
+	return func(objectType string, query *gorm.DB) *gorm.DB {
+		return utils.FilterRestrictedItems(c, objectType, query)
+	}
+}
+
+// publicReleaseNotesScope 排除所有受限需求和Bug，用于保存到版本上供项目成员查看的发布说明
+func publicReleaseNotesScope(objectType string, query *gorm.DB) *gorm.DB {
+	if objectType == "bug" {
+		return query.Where("bugs.confidential = ?", false)
+	}
+	return query.Where("requirements.confidential = ?", false)
+}
+
+// releaseNotesTemplate 读取并校验发布说明模板参数
+func releaseNotesTemplate(c *gin.Context, template string) (string, bool) {
+	if template == "" {
+		return utils.ReleaseNotesStandard, true
+	}
+	if !utils.IsValidReleaseNotesTemplate(template) {
+		utils.Error(c, 400, "无效的发布说明模板，有效值：standard, severity, brief")
+		return "", false
+	}
+	return template, true
+}
+
+// GetReleaseNotes 根据版本关联的需求和Bug生成发布说明
+func (h *VersionHandler) GetReleaseNotes(c *gin.Context) {
+	var version model.Version
+	if err := h.db.First(&version, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "版本不存在")
+		return
+	}
+	template, ok := releaseNotesTemplate(c, c.Query("template"))
+	if !ok {
+		return
+	}
+
+	notes, err := utils.BuildReleaseNotes(h.db, &version, template, releaseNotesScope(c))
+	if err != nil {
+		utils.Error(c, utils.CodeError, "生成发布说明失败")
+		return
+	}
+	writeReleaseDocument(c, "release-notes-"+version.VersionNumber, notes, notes)
+}
+
+// GenerateReleaseNotes 生成发布说明并保存到版本（不包含受限的需求和Bug）
+func (h *VersionHandler) GenerateReleaseNotes(c *gin.Context) {
+	var version model.Version
+	if err := h.db.First(&version, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "版本不存在")
+		return
+	}
+
+	var req struct {
+		Template string `json:"template"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	template, ok := releaseNotesTemplate(c, req.Template)
+	if !ok {
+		return
+	}
+
+	notes, err := utils.BuildReleaseNotes(h.db, &version, template, publicReleaseNotesScope)
+	if err != nil {
+		utils.Error(c, utils.CodeError, "生成发布说明失败")
+		return
+	}
+	version.ReleaseNotes = notes.Markdown()
+	if err := h.db.Model(&version).Update("release_notes", version.ReleaseNotes).Error; err != nil {
+		utils.Error(c, utils.CodeError, "保存发布说明失败")
+		return
+	}
+	utils.Success(c, version)
+}
+
+// DiffReleaseNotes 比较两个版本的发布说明（base 为基准版本ID，必须属于同一项目）
+func (h *VersionHandler) DiffReleaseNotes(c *gin.Context) {
+	var target, base model.Version
+	if err := h.db.First(&target, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "版本不存在")
+		return
+	}
+	if err := h.db.Where("project_id = ?", target.ProjectID).First(&base, c.Query("base")).Error; err != nil {
+		utils.Error(c, 404, "基准版本不存在或不属于同一项目")
+		return
+	}
+	template, ok := releaseNotesTemplate(c, c.Query("template"))
+	if !ok {
+		return
+	}
+
+	baseNotes, err := utils.BuildReleaseNotes(h.db, &base, template, releaseNotesScope(c))
+	if err != nil {
+		utils.Error(c, utils.CodeError, "生成发布说明失败")
+		return
+	}
+	targetNotes, err := utils.BuildReleaseNotes(h.db, &target, template, releaseNotesScope(c))
+	if err != nil {
+		utils.Error(c, utils.CodeError, "生成发布说明失败")
+		return
+	}
+	diff := utils.DiffReleaseNotes(baseNotes, targetNotes)
+	writeReleaseDocument(c, fmt.Sprintf("release-diff-%s-%s", base.VersionNumber, target.VersionNumber), diff, diff)
+}
+
+// projectChangelog 项目变更日志
+type projectChangelog struct {
+	ProjectID   uint                  `json:"project_id"`
+	ProjectName string                `json:"project_name"`
+	Releases    []*utils.ReleaseNotes `json:"releases"`
+}
+
+func (l *projectChangelog) Markdown() string {
+	return utils.ChangelogMarkdown(l.ProjectName, l.Releases)
+}
+
+func (l *projectChangelog) HTML() string {
+	return utils.ChangelogHTML(l.ProjectName, l.Releases)
+}
+
+// GetProjectChangelog 获取项目的变更日志：所有已发布版本的发布说明，按发布日期倒序
+func (h *VersionHandler) GetProjectChangelog(c *gin.Context) {
+	var project model.Project
+	if err := h.db.First(&project, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+	template, ok := releaseNotesTemplate(c, c.DefaultQuery("template", utils.ReleaseNotesBrief))
+	if !ok {
+		return
+	}
+
+	var versions []model.Version
+	if err := h.db.Where("project_id = ? AND status IN ?", project.ID, []string{"normal", "terminate"}).
+		Order("release_date DESC, id DESC").Find(&versions).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询版本失败")
+		return
+	}
+
+	changelog := &projectChangelog{ProjectID: project.ID, ProjectName: project.Name, Releases: make([]*utils.ReleaseNotes, 0, len(versions))}
+	for i := range versions {
+		notes, err := utils.BuildReleaseNotes(h.db, &versions[i], template, releaseNotesScope(c))
+		if err != nil {
+			utils.Error(c, utils.CodeError, "生成变更日志失败")
+			return
+		}
+		changelog.Releases = append(changelog.Releases, notes)
+	}
+	writeReleaseDocument(c, "changelog-"+project.Code, changelog, changelog)
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"fmt"
 	"time"
 
 	"prjflow/internal/model"
@@ -19,293 +18,6 @@ func NewReportHandler(db *gorm.DB) *ReportHandler {
 	return &ReportHandler{db: db}
 }
 
-// summarizeWorkContent 汇总用户的工作内容
-// userID: 用户ID
-// startDate: 开始日期
-// endDate: 结束日期（对于日报，startDate和endDate相同）
-// 返回：Markdown格式的工作内容摘要和总工时
-func (h *ReportHandler) summarizeWorkContent(userID uint, startDate, endDate time.Time) (string, float64) {
-	// 使用 JOIN 查询，直接通过 user_id 查询资源分配记录
-	// 这样可以确保查询到所有相关的资源分配记录，即使没有Resource记录也能查询到
-	var allocations []model.ResourceAllocation
-
-	// 将日期转换为只包含日期的格式（去掉时间部分）
-	// date字段是date类型，直接比较日期部分即可
-	// 注意：使用与工作台相同的日期范围逻辑：date >= startDate AND date < endDate+1
-	startDateOnly := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
-	endDateOnly := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, endDate.Location())
-	// 结束日期加1天，使用 < 比较（与工作台逻辑一致）
-	endDateExclusive := endDateOnly.AddDate(0, 0, 1)
-
-	// 使用 JOIN 查询，通过 resources 表关联 user_id
-	// GORM 会自动处理软删除（deleted_at IS NULL）
-	// 注意：如果用户没有 Resource 记录，JOIN 查询可能返回空结果，这是正常的
-	err := h.db.Model(&model.ResourceAllocation{}).
-		Joins("JOIN resources ON resource_allocations.resource_id = resources.id").
-		Where("resources.user_id = ?", userID).
-		Where("resource_allocations.date >= ? AND resource_allocations.date < ?", startDateOnly, endDateExclusive).
-		Preload("Task").Preload("Bug").Preload("Requirement").Preload("Project").
-		Find(&allocations).Error
-
-	// 如果查询出错，返回空内容（不返回错误信息，避免前端显示错误）
-	if err != nil {
-		// 记录错误但不返回给前端，避免影响用户体验
-		// log.Printf("汇总工作内容查询失败: userID=%d, startDate=%s, endDate=%s, error=%v",
-		// 	userID, startDateOnly.Format("2006-01-02"), endDateOnly.Format("2006-01-02"), err)
-		// 即使资源分配查询失败，也继续查询创建的bug
-		allocations = []model.ResourceAllocation{}
-	}
-
-	// 查询用户在指定日期范围内创建的Bug（即使没有资源分配记录）
-	// 使用时间范围比较：created_at >= startDateOnly AND created_at < endDateExclusive
-	// 与资源分配的日期范围逻辑保持一致
-	var createdBugs []model.Bug
-	bugErr := h.db.Where("creator_id = ?", userID).
-		Where("created_at >= ? AND created_at < ?", startDateOnly, endDateExclusive).
-		Preload("Project").
-		Find(&createdBugs).Error
-	if bugErr != nil {
-		// 查询失败不影响其他汇总，继续处理
-		createdBugs = []model.Bug{}
-	}
-
-	// 查询用户在指定日期范围内完成的任务（即使没有资源分配记录）
-	// 使用时间范围比较：updated_at >= startDateOnly AND updated_at < endDateExclusive AND status = 'done'
-	// 与资源分配的日期范围逻辑保持一致
-	var completedTasks []model.Task
-	taskErr := h.db.Where("assignee_id = ? AND status = ?", userID, "done").
-		Where("updated_at >= ? AND updated_at < ?", startDateOnly, endDateExclusive).
-		Preload("Project").
-		Find(&completedTasks).Error
-	if taskErr != nil {
-		// 查询失败不影响其他汇总，继续处理
-		completedTasks = []model.Task{}
-	}
-
-	// 查询用户在指定日期范围内解决的Bug（通过Action表）
-	// 因为Bug解决后会自动指派回创建者，所以需要通过Action表找到真正的解决者
-	var resolvedBugActions []model.Action
-	actionErr := h.db.Where("object_type = ? AND action IN ? AND actor_id = ?", "bug", []string{"resolved", "closed"}, userID).
-		Where("date >= ? AND date < ?", startDateOnly, endDateExclusive).
-		Find(&resolvedBugActions).Error
-	if actionErr != nil {
-		// 查询失败不影响其他汇总，继续处理
-		resolvedBugActions = []model.Action{}
-	}
-
-	// 获取解决的Bug ID列表
-	var resolvedBugIDs []uint
-	for _, action := range resolvedBugActions {
-		resolvedBugIDs = append(resolvedBugIDs, action.ObjectID)
-	}
-
-	// 查询解决的Bug详情
-	var resolvedBugs []model.Bug
-	if len(resolvedBugIDs) > 0 {
-		bugQueryErr := h.db.Where("id IN ?", resolvedBugIDs).
-			Preload("Project").
-			Find(&resolvedBugs).Error
-		if bugQueryErr != nil {
-			// 查询失败不影响其他汇总，继续处理
-			resolvedBugs = []model.Bug{}
-		}
-	}
-
-	// 如果既没有资源分配记录，也没有创建的bug，也没有完成的任务，也没有解决的bug，返回空内容
-	if len(allocations) == 0 && len(createdBugs) == 0 && len(completedTasks) == 0 && len(resolvedBugs) == 0 {
-		return "暂无工作记录", 0
-	}
-
-	// 按工作类型分组汇总
-	type WorkItem struct {
-		ID          uint
-		Title       string
-		ProjectName string
-		Hours       float64
-	}
-
-	var requirements []WorkItem
-	var tasks []WorkItem
-	var bugs []WorkItem
-	var totalHours float64
-
-	for _, alloc := range allocations {
-		totalHours += alloc.Hours
-
-		projectName := "未知项目"
-		if alloc.Project != nil {
-			projectName = alloc.Project.Name
-		}
-
-		if alloc.RequirementID != nil && alloc.Requirement != nil {
-			requirements = append(requirements, WorkItem{
-				ID:          *alloc.RequirementID,
-				Title:       alloc.Requirement.Title,
-				ProjectName: projectName,
-				Hours:       alloc.Hours,
-			})
-		} else if alloc.TaskID != nil && alloc.Task != nil {
-			tasks = append(tasks, WorkItem{
-				ID:          *alloc.TaskID,
-				Title:       alloc.Task.Title,
-				ProjectName: projectName,
-				Hours:       alloc.Hours,
-			})
-		} else if alloc.BugID != nil && alloc.Bug != nil {
-			bugs = append(bugs, WorkItem{
-				ID:          *alloc.BugID,
-				Title:       alloc.Bug.Title,
-				ProjectName: projectName,
-				Hours:       alloc.Hours,
-			})
-		}
-	}
-
-	// 添加用户创建的Bug（去重，避免与资源分配中的Bug重复）
-	bugIDMap := make(map[uint]bool)
-	for _, bug := range bugs {
-		bugIDMap[bug.ID] = true
-	}
-
-	for _, bug := range createdBugs {
-		// 如果这个bug已经在资源分配中，跳过（避免重复）
-		if bugIDMap[bug.ID] {
-			continue
-		}
-
-		projectName := "未知项目"
-		if bug.Project.ID > 0 {
-			projectName = bug.Project.Name
-		}
-
-		bugs = append(bugs, WorkItem{
-			ID:          bug.ID,
-			Title:       bug.Title,
-			ProjectName: projectName,
-			Hours:       0, // 创建的bug如果没有资源分配，工时为0
-		})
-		bugIDMap[bug.ID] = true // 更新map，用于后续去重
-	}
-
-	// 添加用户解决的Bug（去重，避免与资源分配和创建的Bug重复）
-	for _, bug := range resolvedBugs {
-		// 如果这个bug已经在资源分配或创建的bug中，跳过（避免重复）
-		if bugIDMap[bug.ID] {
-			continue
-		}
-
-		projectName := "未知项目"
-		if bug.Project.ID > 0 {
-			projectName = bug.Project.Name
-		}
-
-		// 如果Bug有实际工时，使用实际工时；否则使用0
-		hours := 0.0
-		if bug.ActualHours != nil {
-			hours = *bug.ActualHours
-		}
-
-		bugs = append(bugs, WorkItem{
-			ID:          bug.ID,
-			Title:       bug.Title,
-			ProjectName: projectName,
-			Hours:       hours,
-		})
-		// 如果Bug有实际工时，也需要加到总工时中
-		if hours > 0 {
-			totalHours += hours
-		}
-	}
-
-	// 添加用户完成的任务（去重，避免与资源分配中的任务重复）
-	taskIDMap := make(map[uint]bool)
-	for _, task := range tasks {
-		taskIDMap[task.ID] = true
-	}
-
-	for _, task := range completedTasks {
-		// 如果这个任务已经在资源分配中，跳过（避免重复）
-		if taskIDMap[task.ID] {
-			continue
-		}
-
-		projectName := "未知项目"
-		if task.Project.ID > 0 {
-			projectName = task.Project.Name
-		}
-
-		// 如果任务有实际工时，使用实际工时；否则使用0
-		hours := 0.0
-		if task.ActualHours != nil {
-			hours = *task.ActualHours
-		}
-
-		tasks = append(tasks, WorkItem{
-			ID:          task.ID,
-			Title:       task.Title,
-			ProjectName: projectName,
-			Hours:       hours,
-		})
-		// 如果任务有实际工时，也需要加到总工时中
-		if hours > 0 {
-			totalHours += hours
-		}
-	}
-
-	// 生成Markdown格式的工作内容摘要（使用表格格式）
-	content := ""
-
-	if len(requirements) > 0 {
-		content += "## 需求\n\n"
-		content += "| 标题 | 项目 | 工时(小时) |\n"
-		content += "| --- | --- | ---: |\n"
-		var reqHours float64
-		for _, req := range requirements {
-			content += fmt.Sprintf("| %s | %s | %.2f |\n", req.Title, req.ProjectName, req.Hours)
-			reqHours += req.Hours
-		}
-		content += fmt.Sprintf("| **合计** | | **%.2f** |\n\n", reqHours)
-	}
-
-	if len(tasks) > 0 {
-		content += "## 任务\n\n"
-		content += "| 标题 | 项目 | 工时(小时) |\n"
-		content += "| --- | --- | ---: |\n"
-		var taskHours float64
-		for _, task := range tasks {
-			content += fmt.Sprintf("| %s | %s | %.2f |\n", task.Title, task.ProjectName, task.Hours)
-			taskHours += task.Hours
-		}
-		content += fmt.Sprintf("| **合计** | | **%.2f** |\n\n", taskHours)
-	}
-
-	if len(bugs) > 0 {
-		content += "## Bug\n\n"
-		content += "| 标题 | 项目 | 工时(小时) |\n"
-		content += "| --- | --- | ---: |\n"
-		var bugHours float64
-		for _, bug := range bugs {
-			content += fmt.Sprintf("| %s | %s | %.2f |\n", bug.Title, bug.ProjectName, bug.Hours)
-			bugHours += bug.Hours
-		}
-		content += fmt.Sprintf("| **合计** | | **%.2f** |\n\n", bugHours)
-	}
-
-	content += fmt.Sprintf("**总工时**: %.2f小时\n\n", totalHours)
-
-	// 添加工作计划表格模板（日报显示"明日工作计划"，周报显示"下周工作计划"）
-	planTitle := "明日工作计划"
-	if startDate != endDate {
-		planTitle = "下周工作计划"
-	}
-	content += fmt.Sprintf("## %s\n\n", planTitle)
-	content += "| 工作内容 | 预计工时(小时) |\n"
-	content += "| --- | ---: |\n"
-	content += "| | |\n"
-
-	return content, totalHours
-}
-
 // GetWorkSummary 获取工作内容汇总（不创建报告，只返回汇总内容）
 // 用于前端在新增界面时自动填充工作内容
 func (h *ReportHandler) GetWorkSummary(c *gin.Context) {
@@ -340,7 +52,7 @@ func (h *ReportHandler) GetWorkSummary(c *gin.Context) {
 		return
 	}
 
-	content, hours := h.summarizeWorkContent(userID.(uint), startDate, endDate)
+	content, hours := utils.SummarizeWorkContent(h.db, userID.(uint), startDate, endDate)
 
 	// 调试信息：记录查询参数和结果
 	// fmt.Printf("汇总查询 - 用户ID: %d, 开始日期: %s, 结束日期: %s, 结果: 内容长度=%d, 工时=%.2f\n",
@@ -366,10 +78,11 @@ func (h *ReportHandler) GetDailyReports(c *gin.Context) {
 	// 检查是否是获取审批列表
 	forApproval := c.Query("for_approval") == "true"
 	uid := userID.(uint)
+	approverIDs := h.approverIdentities(uid)
 	if !utils.IsAdmin(c) {
 		if forApproval {
-			// 获取需要当前用户审批的报告
-			query = query.Where("id IN (SELECT daily_report_id FROM daily_report_approvers WHERE user_id = ?)", uid)
+			// 获取需要当前用户审批的报告（包括委托给当前用户代为审批的，后续阶段在前一阶段通过后才可见）
+			query = query.Where(dailyReportApproval.visibleCondition(), approverIDs, approverIDs)
 		} else {
 			// 只查询自己创建的
 			query = query.Where("user_id = ?", uid)
@@ -403,8 +116,8 @@ func (h *ReportHandler) GetDailyReports(c *gin.Context) {
 	countQuery := h.db.Model(&model.DailyReport{})
 	if !utils.IsAdmin(c) {
 		if forApproval {
-			// 获取需要当前用户审批的报告
-			countQuery = countQuery.Where("id IN (SELECT daily_report_id FROM daily_report_approvers WHERE user_id = ?)", uid)
+			// 获取需要当前用户审批的报告（包括委托给当前用户代为审批的，后续阶段在前一阶段通过后才可见）
+			countQuery = countQuery.Where(dailyReportApproval.visibleCondition(), approverIDs, approverIDs)
 		} else {
 			// 只查询自己创建的
 			countQuery = countQuery.Where("user_id = ?", uid)
@@ -448,15 +161,8 @@ func (h *ReportHandler) GetDailyReport(c *gin.Context) {
 	if !utils.IsAdmin(c) {
 		// 检查是否是报告创建者
 		if report.UserID != uid {
-			// 检查是否是审批人
-			isApprover := false
-			for _, approver := range report.Approvers {
-				if approver.ID == uid {
-					isApprover = true
-					break
-				}
-			}
-			if !isApprover {
+			// 检查是否是审批人（或代理人）且已轮到其审批
+			if !h.canViewReportApproval(dailyReportApproval, report.ID, uid) {
 				utils.Error(c, 403, "没有权限访问该报告")
 				return
 			}
@@ -508,7 +214,7 @@ func (h *ReportHandler) CreateDailyReport(c *gin.Context) {
 	// 自动汇总工作内容（如果用户未提供Content）
 	var content string
 	if req.Content == "" {
-		content, _ = h.summarizeWorkContent(userID.(uint), date, date)
+		content, _ = utils.SummarizeWorkContent(h.db, userID.(uint), date, date)
 	} else {
 		content = req.Content
 	}
@@ -531,15 +237,14 @@ func (h *ReportHandler) CreateDailyReport(c *gin.Context) {
 		var approvers []model.User
 		if err := h.db.Where("id IN ?", req.ApproverIDs).Find(&approvers).Error; err == nil {
 			h.db.Model(&report).Association("Approvers").Replace(approvers)
-			// 为每个审批人创建待审批记录
-			for _, approver := range approvers {
-				approval := model.DailyReportApproval{
-					DailyReportID: report.ID,
-					ApproverID:    approver.ID,
-					Status:        "pending",
-				}
-				h.db.Create(&approval)
-			}
+		}
+	}
+
+	// 提交时创建审批记录：指定了审批人时并行审批，否则按审批链逐级审批
+	if report.Status == "submitted" {
+		if err := h.startReportApproval(dailyReportApproval, report.ID, report.UserID); err != nil {
+			utils.Error(c, utils.CodeError, "创建审批流程失败")
+			return
 		}
 	}
 
@@ -595,6 +300,7 @@ func (h *ReportHandler) UpdateDailyReport(c *gin.Context) {
 	if req.Content != nil {
 		report.Content = *req.Content
 	}
+	oldStatus := report.Status
 	if req.Status != nil {
 		report.Status = *req.Status
 	}
@@ -605,22 +311,10 @@ func (h *ReportHandler) UpdateDailyReport(c *gin.Context) {
 		if len(req.ApproverIDs) > 0 {
 			if err := h.db.Where("id IN ?", req.ApproverIDs).Find(&approvers).Error; err == nil {
 				h.db.Model(&report).Association("Approvers").Replace(approvers)
-				// 删除旧的审批记录
-				h.db.Where("daily_report_id = ?", report.ID).Delete(&model.DailyReportApproval{})
-				// 为每个审批人创建待审批记录
-				for _, approver := range approvers {
-					approval := model.DailyReportApproval{
-						DailyReportID: report.ID,
-						ApproverID:    approver.ID,
-						Status:        "pending",
-					}
-					h.db.Create(&approval)
-				}
 			}
 		} else {
-			// 如果传入空数组，清空所有审批人关联和审批记录
+			// 如果传入空数组，清空审批人关联，提交时按审批链审批
 			h.db.Model(&report).Association("Approvers").Clear()
-			h.db.Where("daily_report_id = ?", report.ID).Delete(&model.DailyReportApproval{})
 		}
 	}
 
@@ -629,6 +323,14 @@ func (h *ReportHandler) UpdateDailyReport(c *gin.Context) {
 		return
 	}
 
+	// 提交或已提交的报告修改审批人时开始新一轮审批
+	if report.Status == "submitted" && (oldStatus != "submitted" || req.ApproverIDs != nil) {
+		if err := h.startReportApproval(dailyReportApproval, report.ID, report.UserID); err != nil {
+			utils.Error(c, utils.CodeError, "创建审批流程失败")
+			return
+		}
+	}
+
 	h.db.Preload("User").Preload("Approvers").Preload("ApprovalRecords.Approver").First(&report, report.ID)
 	utils.Success(c, report)
 }
@@ -697,17 +399,25 @@ func (h *ReportHandler) UpdateDailyReportStatus(c *gin.Context) {
 		return
 	}
 
+	oldStatus := report.Status
 	report.Status = req.Status
 	if err := h.db.Save(&report).Error; err != nil {
 		utils.Error(c, utils.CodeError, "更新失败")
 		return
 	}
 
+	if report.Status == "submitted" && oldStatus != "submitted" {
+		if err := h.startReportApproval(dailyReportApproval, report.ID, report.UserID); err != nil {
+			utils.Error(c, utils.CodeError, "创建审批流程失败")
+			return
+		}
+	}
+
 	h.db.Preload("User").Preload("Approvers").Preload("ApprovalRecords.Approver").First(&report, report.ID)
 	utils.Success(c, report)
 }
 
-// ApproveDailyReport 审批日报
+// ApproveDailyReport 审批日报：按审批阶段依次审批，驳回后退回草稿
 func (h *ReportHandler) ApproveDailyReport(c *gin.Context) {
 	id := c.Param("id")
 	var report model.DailyReport
@@ -716,78 +426,11 @@ func (h *ReportHandler) ApproveDailyReport(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	uid := userID.(uint)
-
-	// 检查当前用户是否是审批人
-	isApprover := false
-	for _, approver := range report.Approvers {
-		if approver.ID == uid {
-			isApprover = true
-			break
-		}
-	}
-
-	if !isApprover && !utils.IsAdmin(c) {
-		utils.Error(c, 403, "您不是该报告的审批人")
-		return
-	}
-
-	var req struct {
-		Status  string `json:"status" binding:"required"` // approved 或 rejected
-		Comment string `json:"comment"`                   // 批注
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.Error(c, 400, "参数错误")
+	if !h.reviewReport(c, dailyReportApproval, report.ID, report.Status, report.Approvers) {
 		return
 	}
 
-	if req.Status != "approved" && req.Status != "rejected" {
-		utils.Error(c, 400, "状态必须是 approved 或 rejected")
-		return
-	}
-
-	// 查找或创建审批记录
-	var approval model.DailyReportApproval
-	if err := h.db.Where("daily_report_id = ? AND approver_id = ?", report.ID, uid).First(&approval).Error; err != nil {
-		// 如果不存在，创建新的审批记录
-		approval = model.DailyReportApproval{
-			DailyReportID: report.ID,
-			ApproverID:    uid,
-			Status:        req.Status,
-			Comment:       req.Comment,
-		}
-		if err := h.db.Create(&approval).Error; err != nil {
-			utils.Error(c, utils.CodeError, "创建审批记录失败")
-			return
-		}
-	} else {
-		// 更新现有审批记录
-		approval.Status = req.Status
-		approval.Comment = req.Comment
-		if err := h.db.Save(&approval).Error; err != nil {
-			utils.Error(c, utils.CodeError, "更新审批记录失败")
-			return
-		}
-	}
-
-	// 检查是否所有审批人都已审批
-	var pendingCount int64
-	h.db.Model(&model.DailyReportApproval{}).Where("daily_report_id = ? AND status = ?", report.ID, "pending").Count(&pendingCount)
-	if pendingCount == 0 {
-		// 所有审批人都已审批，检查是否有拒绝的
-		var rejectedCount int64
-		h.db.Model(&model.DailyReportApproval{}).Where("daily_report_id = ? AND status = ?", report.ID, "rejected").Count(&rejectedCount)
-		if rejectedCount > 0 {
-			report.Status = "rejected"
-		} else {
-			report.Status = "approved"
-		}
-		h.db.Save(&report)
-	}
-
-	h.db.Preload("User").Preload("Approvers").Preload("ApprovalRecords.Approver").First(&report, report.ID)
+	h.db.Preload("User").Preload("Approvers").Preload("ApprovalRecords.Approver").Preload("ApprovalRecords.ActedBy").First(&report, report.ID)
 	utils.Success(c, report)
 }
 
@@ -805,10 +448,11 @@ func (h *ReportHandler) GetWeeklyReports(c *gin.Context) {
 	// 检查是否是获取审批列表
 	forApproval := c.Query("for_approval") == "true"
 	uid := userID.(uint)
+	approverIDs := h.approverIdentities(uid)
 	if !utils.IsAdmin(c) {
 		if forApproval {
-			// 获取需要当前用户审批的报告
-			query = query.Where("id IN (SELECT weekly_report_id FROM weekly_report_approvers WHERE user_id = ?)", uid)
+			// 获取需要当前用户审批的报告（包括委托给当前用户代为审批的，后续阶段在前一阶段通过后才可见）
+			query = query.Where(weeklyReportApproval.visibleCondition(), approverIDs, approverIDs)
 		} else {
 			// 只查询自己创建的
 			query = query.Where("user_id = ?", uid)
@@ -842,8 +486,8 @@ func (h *ReportHandler) GetWeeklyReports(c *gin.Context) {
 	countQuery := h.db.Model(&model.WeeklyReport{})
 	if !utils.IsAdmin(c) {
 		if forApproval {
-			// 获取需要当前用户审批的报告
-			countQuery = countQuery.Where("id IN (SELECT weekly_report_id FROM weekly_report_approvers WHERE user_id = ?)", uid)
+			// 获取需要当前用户审批的报告（包括委托给当前用户代为审批的，后续阶段在前一阶段通过后才可见）
+			countQuery = countQuery.Where(weeklyReportApproval.visibleCondition(), approverIDs, approverIDs)
 		} else {
 			// 只查询自己创建的
 			countQuery = countQuery.Where("user_id = ?", uid)
@@ -887,15 +531,8 @@ func (h *ReportHandler) GetWeeklyReport(c *gin.Context) {
 	if !utils.IsAdmin(c) {
 		// 检查是否是报告创建者
 		if report.UserID != uid {
-			// 检查是否是审批人
-			isApprover := false
-			for _, approver := range report.Approvers {
-				if approver.ID == uid {
-					isApprover = true
-					break
-				}
-			}
-			if !isApprover {
+			// 检查是否是审批人（或代理人）且已轮到其审批
+			if !h.canViewReportApproval(weeklyReportApproval, report.ID, uid) {
 				utils.Error(c, 403, "没有权限访问该报告")
 				return
 			}
@@ -952,7 +589,7 @@ func (h *ReportHandler) CreateWeeklyReport(c *gin.Context) {
 	// 自动汇总工作内容（如果用户未提供Summary）
 	var summary string
 	if req.Summary == "" {
-		summary, _ = h.summarizeWorkContent(userID.(uint), weekStart, weekEnd)
+		summary, _ = utils.SummarizeWorkContent(h.db, userID.(uint), weekStart, weekEnd)
 	} else {
 		summary = req.Summary
 	}
@@ -977,15 +614,14 @@ func (h *ReportHandler) CreateWeeklyReport(c *gin.Context) {
 		var approvers []model.User
 		if err := h.db.Where("id IN ?", req.ApproverIDs).Find(&approvers).Error; err == nil {
 			h.db.Model(&report).Association("Approvers").Replace(approvers)
-			// 为每个审批人创建待审批记录
-			for _, approver := range approvers {
-				approval := model.WeeklyReportApproval{
-					WeeklyReportID: report.ID,
-					ApproverID:     approver.ID,
-					Status:         "pending",
-				}
-				h.db.Create(&approval)
-			}
+		}
+	}
+
+	// 提交时创建审批记录：指定了审批人时并行审批，否则按审批链逐级审批
+	if report.Status == "submitted" {
+		if err := h.startReportApproval(weeklyReportApproval, report.ID, report.UserID); err != nil {
+			utils.Error(c, utils.CodeError, "创建审批流程失败")
+			return
 		}
 	}
 
@@ -1054,6 +690,7 @@ func (h *ReportHandler) UpdateWeeklyReport(c *gin.Context) {
 	if req.NextWeekPlan != nil {
 		report.NextWeekPlan = *req.NextWeekPlan
 	}
+	oldStatus := report.Status
 	if req.Status != nil {
 		report.Status = *req.Status
 	}
@@ -1064,22 +701,10 @@ func (h *ReportHandler) UpdateWeeklyReport(c *gin.Context) {
 		if len(req.ApproverIDs) > 0 {
 			if err := h.db.Where("id IN ?", req.ApproverIDs).Find(&approvers).Error; err == nil {
 				h.db.Model(&report).Association("Approvers").Replace(approvers)
-				// 删除旧的审批记录
-				h.db.Where("weekly_report_id = ?", report.ID).Delete(&model.WeeklyReportApproval{})
-				// 为每个审批人创建待审批记录
-				for _, approver := range approvers {
-					approval := model.WeeklyReportApproval{
-						WeeklyReportID: report.ID,
-						ApproverID:     approver.ID,
-						Status:         "pending",
-					}
-					h.db.Create(&approval)
-				}
 			}
 		} else {
-			// 如果传入空数组，清空所有审批人关联和审批记录
+			// 如果传入空数组，清空审批人关联，提交时按审批链审批
 			h.db.Model(&report).Association("Approvers").Clear()
-			h.db.Where("weekly_report_id = ?", report.ID).Delete(&model.WeeklyReportApproval{})
 		}
 	}
 
@@ -1088,11 +713,19 @@ func (h *ReportHandler) UpdateWeeklyReport(c *gin.Context) {
 		return
 	}
 
+	// 提交或已提交的报告修改审批人时开始新一轮审批
+	if report.Status == "submitted" && (oldStatus != "submitted" || req.ApproverIDs != nil) {
+		if err := h.startReportApproval(weeklyReportApproval, report.ID, report.UserID); err != nil {
+			utils.Error(c, utils.CodeError, "创建审批流程失败")
+			return
+		}
+	}
+
 	h.db.Preload("User").Preload("Approvers").Preload("ApprovalRecords.Approver").First(&report, report.ID)
 	utils.Success(c, report)
 }
 
-// ApproveWeeklyReport 审批周报
+// ApproveWeeklyReport 审批周报：按审批阶段依次审批，驳回后退回草稿
 func (h *ReportHandler) ApproveWeeklyReport(c *gin.Context) {
 	id := c.Param("id")
 	var report model.WeeklyReport
@@ -1101,78 +734,11 @@ func (h *ReportHandler) ApproveWeeklyReport(c *gin.Context) {
 		return
 	}
 
-	userID, _ := c.Get("user_id")
-	uid := userID.(uint)
-
-	// 检查当前用户是否是审批人
-	isApprover := false
-	for _, approver := range report.Approvers {
-		if approver.ID == uid {
-			isApprover = true
-			break
-		}
-	}
-
-	if !isApprover && !utils.IsAdmin(c) {
-		utils.Error(c, 403, "您不是该报告的审批人")
-		return
-	}
-
-	var req struct {
-		Status  string `json:"status" binding:"required"` // approved 或 rejected
-		Comment string `json:"comment"`                   // 批注
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.Error(c, 400, "参数错误")
-		return
-	}
-
-	if req.Status != "approved" && req.Status != "rejected" {
-		utils.Error(c, 400, "状态必须是 approved 或 rejected")
+	if !h.reviewReport(c, weeklyReportApproval, report.ID, report.Status, report.Approvers) {
 		return
 	}
 
-	// 查找或创建审批记录
-	var approval model.WeeklyReportApproval
-	if err := h.db.Where("weekly_report_id = ? AND approver_id = ?", report.ID, uid).First(&approval).Error; err != nil {
-		// 如果不存在，创建新的审批记录
-		approval = model.WeeklyReportApproval{
-			WeeklyReportID: report.ID,
-			ApproverID:     uid,
-			Status:         req.Status,
-			Comment:        req.Comment,
-		}
-		if err := h.db.Create(&approval).Error; err != nil {
-			utils.Error(c, utils.CodeError, "创建审批记录失败")
-			return
-		}
-	} else {
-		// 更新现有审批记录
-		approval.Status = req.Status
-		approval.Comment = req.Comment
-		if err := h.db.Save(&approval).Error; err != nil {
-			utils.Error(c, utils.CodeError, "更新审批记录失败")
-			return
-		}
-	}
-
-	// 检查是否所有审批人都已审批
-	var pendingCount int64
-	h.db.Model(&model.WeeklyReportApproval{}).Where("weekly_report_id = ? AND status = ?", report.ID, "pending").Count(&pendingCount)
-	if pendingCount == 0 {
-		// 所有审批人都已审批，检查是否有拒绝的
-		var rejectedCount int64
-		h.db.Model(&model.WeeklyReportApproval{}).Where("weekly_report_id = ? AND status = ?", report.ID, "rejected").Count(&rejectedCount)
-		if rejectedCount > 0 {
-			report.Status = "rejected"
-		} else {
-			report.Status = "approved"
-		}
-		h.db.Save(&report)
-	}
-
-	h.db.Preload("User").Preload("Approvers").Preload("ApprovalRecords.Approver").First(&report, report.ID)
+	h.db.Preload("User").Preload("Approvers").Preload("ApprovalRecords.Approver").Preload("ApprovalRecords.ActedBy").First(&report, report.ID)
 	utils.Success(c, report)
 }
 
@@ -1240,12 +806,20 @@ func (h *ReportHandler) UpdateWeeklyReportStatus(c *gin.Context) {
 		return
 	}
 
+	oldStatus := report.Status
 	report.Status = req.Status
 	if err := h.db.Save(&report).Error; err != nil {
 		utils.Error(c, utils.CodeError, "更新失败")
 		return
 	}
 
+	if report.Status == "submitted" && oldStatus != "submitted" {
+		if err := h.startReportApproval(weeklyReportApproval, report.ID, report.UserID); err != nil {
+			utils.Error(c, utils.CodeError, "创建审批流程失败")
+			return
+		}
+	}
+
 	h.db.Preload("User").First(&report, report.ID)
 	utils.Success(c, report)
 }
@@ -0,0 +1,631 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// reportApprovalKind 日报和周报审批共用的表信息
+type reportApprovalKind struct {
+	reportType    string // daily, weekly
+	reportTable   string
+	approvalTable string
+	approverTable string
+	reportColumn  string // 审批记录表和审批人关联表中的报告ID列
+	newRecord     func(reportID, approverID uint, round, stage int) interface{}
+}
+
+var dailyReportApproval = reportApprovalKind{
+	reportType:    "daily",
+	reportTable:   "daily_reports",
+	approvalTable: "daily_report_approvals",
+	approverTable: "daily_report_approvers",
+	reportColumn:  "daily_report_id",
+	newRecord: func(reportID, approverID uint, round, stage int) interface{} {
+		return &model.DailyReportApproval{DailyReportID: reportID, ApproverID: approverID, Status: "pending", Round: round, Stage: stage}
+	},
+}
+
+var weeklyReportApproval = reportApprovalKind{
+	reportType:    "weekly",
+	reportTable:   "weekly_reports",
+	approvalTable: "weekly_report_approvals",
+	approverTable: "weekly_report_approvers",
+	reportColumn:  "weekly_report_id",
+	newRecord: func(reportID, approverID uint, round, stage int) interface{} {
+		return &model.WeeklyReportApproval{WeeklyReportID: reportID, ApproverID: approverID, Status: "pending", Round: round, Stage: stage}
+	},
+}
+
+// reportApprovalRecord 审批记录中审批流转用到的字段
+type reportApprovalRecord struct {
+	ID         uint
+	ApproverID uint
+	Status     string
+	Round      int
+	Stage      int
+}
+
+// visibleCondition 审批人可见的报告：已轮到其审批或已审批过的报告，以及手动指定审批人的报告
+// 后续阶段的审批人在前一阶段全部通过前看不到报告；参数为两次审批人ID列表
+func (k reportApprovalKind) visibleCondition() string {
+	return fmt.Sprintf(`(%[3]s.id IN (SELECT a.%[1]s FROM %[2]s a WHERE a.approver_id IN ? AND (a.status IN ('approved', 'rejected') OR (a.status = 'pending' AND NOT EXISTS (SELECT 1 FROM %[2]s b WHERE b.%[1]s = a.%[1]s AND b.round = a.round AND b.status = 'pending' AND b.stage < a.stage)))) OR %[3]s.id IN (SELECT %[1]s FROM %[4]s WHERE user_id IN ?))`,
+		k.reportColumn, k.approvalTable, k.reportTable, k.approverTable)
+}
+
+// pendingCondition 已提交且当前阶段等待该审批人审批的报告；参数为审批人ID列表
+func (k reportApprovalKind) pendingCondition() string {
+	return fmt.Sprintf(`%[3]s.status = 'submitted' AND %[3]s.id IN (SELECT a.%[1]s FROM %[2]s a WHERE a.approver_id IN ? AND a.status = 'pending' AND NOT EXISTS (SELECT 1 FROM %[2]s b WHERE b.%[1]s = a.%[1]s AND b.round = a.round AND b.status = 'pending' AND b.stage < a.stage))`,
+		k.reportColumn, k.approvalTable, k.reportTable)
+}
+
+// approvalDate 审批委托按日期比较
+func approvalDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// delegatorsOf 当前委托给该用户代为审批的审批人
+func (h *ReportHandler) delegatorsOf(userID uint) []uint {
+	today := approvalDate(time.Now())
+	var ids []uint
+	h.db.Model(&model.ApprovalDelegation{}).
+		Where("delegate_id = ? AND start_date <= ? AND end_date >= ?", userID, today, today).
+		Pluck("user_id", &ids)
+	return ids
+}
+
+// approverIdentities 用户本人及委托其审批的审批人
+func (h *ReportHandler) approverIdentities(userID uint) []uint {
+	return append([]uint{userID}, h.delegatorsOf(userID)...)
+}
+
+// canViewReportApproval 用户是否可以作为审批人（或代理人）查看报告
+func (h *ReportHandler) canViewReportApproval(kind reportApprovalKind, reportID, userID uint) bool {
+	ids := h.approverIdentities(userID)
+	var count int64
+	h.db.Table(kind.reportTable).Where(kind.reportTable+".id = ?", reportID).Where(kind.visibleCondition(), ids, ids).Count(&count)
+	return count > 0
+}
+
+// canViewReport 用户是否可以查看报告：管理员、报告作者或审批人（含代理人）
+func (h *ReportHandler) canViewReport(c *gin.Context, kind reportApprovalKind, reportID uint) bool {
+	if utils.IsAdmin(c) {
+		return true
+	}
+	uid := utils.GetUserID(c)
+	var count int64
+	h.db.Table(kind.reportTable).Where("id = ? AND user_id = ?", reportID, uid).Count(&count)
+	return count > 0 || h.canViewReportApproval(kind, reportID, uid)
+}
+
+// currentApprovalStage 报告当前的审批轮次和阶段（没有待审批记录时阶段为0）
+func (h *ReportHandler) currentApprovalStage(kind reportApprovalKind, reportID uint) (int, int) {
+	var round, stage int
+	h.db.Table(kind.approvalTable).Where(kind.reportColumn+" = ?", reportID).Select("COALESCE(MAX(round), 0)").Scan(&round)
+	h.db.Table(kind.approvalTable).Where(kind.reportColumn+" = ? AND round = ? AND status = ?", reportID, round, "pending").
+		Select("COALESCE(MIN(stage), 0)").Scan(&stage)
+	return round, stage
+}
+
+// startReportApproval 报告提交时开始新一轮审批：
+// 手动指定了审批人时所有审批人并行审批，否则按作者所在部门的审批链逐级审批
+func (h *ReportHandler) startReportApproval(kind reportApprovalKind, reportID, authorID uint) error {
+	var approverIDs []uint
+	h.db.Table(kind.approverTable).Where(kind.reportColumn+" = ?", reportID).Order("user_id ASC").Pluck("user_id", &approverIDs)
+
+	stages := [][]uint{approverIDs}
+	if len(approverIDs) == 0 {
+		var err error
+		if stages, err = h.resolveApprovalChain(kind.reportType, authorID); err != nil {
+			return err
+		}
+	}
+
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		// 未审批的记录没有保留价值，已审批的记录作为历史保留
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND status = ?", kind.approvalTable, kind.reportColumn), reportID, "pending").Error; err != nil {
+			return err
+		}
+		var round int
+		tx.Table(kind.approvalTable).Where(kind.reportColumn+" = ?", reportID).Select("COALESCE(MAX(round), 0)").Scan(&round)
+		for i, approvers := range stages {
+			for _, approverID := range approvers {
+				if err := tx.Create(kind.newRecord(reportID, approverID, round+1, i+1)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// resolveApprovalChain 根据作者所在部门查找审批链（本部门、上级部门、默认审批链依次查找），返回各阶段的审批人
+// 部门负责人为作者本人或未设置时由更上级的部门负责人审批；已在前面阶段出现的审批人不再重复审批
+func (h *ReportHandler) resolveApprovalChain(reportType string, authorID uint) ([][]uint, error) {
+	var author model.User
+	if err := h.db.First(&author, authorID).Error; err != nil {
+		return nil, err
+	}
+
+	// 作者所在部门及其所有上级部门
+	var departments []model.Department
+	visited := make(map[uint]bool)
+	for deptID := author.DepartmentID; deptID != nil && !visited[*deptID]; {
+		var dept model.Department
+		if err := h.db.First(&dept, *deptID).Error; err != nil {
+			break
+		}
+		visited[dept.ID] = true
+		departments = append(departments, dept)
+		deptID = dept.ParentID
+	}
+
+	var chain model.ReportApprovalChain
+	found := false
+	scopes := make([]*uint, 0, len(departments)+1)
+	for i := range departments {
+		scopes = append(scopes, &departments[i].ID)
+	}
+	scopes = append(scopes, nil)
+	for _, deptID := range scopes {
+		query := h.db.Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("stage ASC, id ASC") }).
+			Where("enabled = ? AND report_type IN ?", true, []string{reportType, ""})
+		if deptID == nil {
+			query = query.Where("department_id IS NULL")
+		} else {
+			query = query.Where("department_id = ?", *deptID)
+		}
+		// 指定报告类型的审批链优先于通用审批链
+		err := query.Order("report_type DESC, id ASC").First(&chain).Error
+		if err == nil {
+			found = true
+			break
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	leaderAt := func(level int) uint {
+		for i := level; i < len(departments); i++ {
+			if departments[i].LeaderID != nil && *departments[i].LeaderID != authorID {
+				return *departments[i].LeaderID
+			}
+		}
+		return 0
+	}
+
+	var stages [][]uint
+	used := map[uint]bool{authorID: true}
+	stageIndex := make(map[int]int)
+	for _, step := range chain.Steps {
+		var approverID uint
+		switch step.ApproverType {
+		case "user":
+			if step.UserID != nil {
+				approverID = *step.UserID
+			}
+		case "leader":
+			approverID = leaderAt(step.Level)
+		}
+		if approverID == 0 || used[approverID] {
+			continue
+		}
+		var active int64
+		h.db.Model(&model.User{}).Where("id = ? AND status = ?", approverID, 1).Count(&active)
+		if active == 0 {
+			continue
+		}
+		used[approverID] = true
+		idx, ok := stageIndex[step.Stage]
+		if !ok {
+			idx = len(stages)
+			stageIndex[step.Stage] = idx
+			stages = append(stages, nil)
+		}
+		stages[idx] = append(stages[idx], approverID)
+	}
+	return stages, nil
+}
+
+// reviewReport 审批人（或其代理人）审批报告当前阶段：
+// 通过后进入下一阶段，所有阶段通过后报告变为已审批；驳回后报告退回草稿，批注保留在审批记录中
+func (h *ReportHandler) reviewReport(c *gin.Context, kind reportApprovalKind, reportID uint, reportStatus string, approvers []model.User) bool {
+	uid := utils.GetUserID(c)
+	identities := h.approverIdentities(uid)
+	round, stage := h.currentApprovalStage(kind, reportID)
+
+	var record reportApprovalRecord
+	err := h.db.Table(kind.approvalTable).
+		Where(kind.reportColumn+" = ? AND round = ? AND stage = ? AND status = ? AND approver_id IN ?", reportID, round, stage, "pending", identities).
+		Order(fmt.Sprintf("CASE WHEN approver_id = %d THEN 0 ELSE 1 END, id ASC", uid)).
+		First(&record).Error
+	if err != nil {
+		// 手动指定的审批人（没有审批记录的旧数据）和管理员在当前阶段补充审批记录
+		isApprover := false
+		for _, approver := range approvers {
+			if approver.ID == uid {
+				isApprover = true
+				break
+			}
+		}
+		var later int64
+		h.db.Table(kind.approvalTable).Where(kind.reportColumn+" = ? AND round = ? AND status = ? AND approver_id IN ?", reportID, round, "pending", identities).Count(&later)
+		switch {
+		case later > 0:
+			utils.Error(c, 403, "前一审批阶段尚未完成，暂不能审批")
+			return false
+		case !isApprover && !utils.IsAdmin(c):
+			utils.Error(c, 403, "您不是该报告的审批人")
+			return false
+		}
+		record = reportApprovalRecord{ApproverID: uid, Status: "pending", Round: max(round, 1), Stage: max(stage, 1)}
+	}
+
+	var req struct {
+		Status  string `json:"status" binding:"required"` // approved 或 rejected
+		Comment string `json:"comment"`                   // 批注
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误")
+		return false
+	}
+	if req.Status != "approved" && req.Status != "rejected" {
+		utils.Error(c, 400, "状态必须是 approved 或 rejected")
+		return false
+	}
+	if reportStatus != "submitted" {
+		utils.Error(c, 400, "报告未提交或审批已完成")
+		return false
+	}
+	if req.Status == "rejected" && strings.TrimSpace(req.Comment) == "" {
+		utils.Error(c, 400, "驳回时请填写批注")
+		return false
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": req.Status, "comment": req.Comment, "updated_at": time.Now()}
+		if record.ApproverID != uid {
+			updates["acted_by_id"] = uid
+		}
+		if record.ID == 0 {
+			created := kind.newRecord(reportID, record.ApproverID, record.Round, record.Stage)
+			if err := tx.Create(created).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(created).Updates(updates).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Table(kind.approvalTable).Where("id = ?", record.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		newStatus := ""
+		if req.Status == "rejected" {
+			// 驳回后退回草稿，本轮其他未审批的记录跳过，重新提交后开始新一轮审批
+			if err := tx.Table(kind.approvalTable).Where(kind.reportColumn+" = ? AND round = ? AND status = ?", reportID, record.Round, "pending").
+				Updates(map[string]interface{}{"status": "skipped", "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+			newStatus = "draft"
+		} else {
+			var pending int64
+			tx.Table(kind.approvalTable).Where(kind.reportColumn+" = ? AND round = ? AND status = ?", reportID, record.Round, "pending").Count(&pending)
+			if pending == 0 {
+				newStatus = "approved"
+			}
+		}
+		if newStatus != "" {
+			return tx.Table(kind.reportTable).Where("id = ?", reportID).Updates(map[string]interface{}{"status": newStatus, "updated_at": time.Now()}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Error(c, utils.CodeError, "审批失败")
+		return false
+	}
+	return true
+}
+
+// GetPendingApprovals 获取待我审批的日报和周报（包括委托给我代为审批的）
+func (h *ReportHandler) GetPendingApprovals(c *gin.Context) {
+	ids := h.approverIdentities(utils.GetUserID(c))
+
+	var daily []model.DailyReport
+	if err := h.db.Preload("User").Preload("ApprovalRecords.Approver").
+		Where(dailyReportApproval.pendingCondition(), ids).Order("date DESC").Find(&daily).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+	var weekly []model.WeeklyReport
+	if err := h.db.Preload("User").Preload("ApprovalRecords.Approver").
+		Where(weeklyReportApproval.pendingCondition(), ids).Order("week_start DESC").Find(&weekly).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"daily_reports":  daily,
+		"weekly_reports": weekly,
+		"total":          len(daily) + len(weekly),
+	})
+}
+
+// approvalChainRequest 审批链请求参数
+type approvalChainRequest struct {
+	Name         string `json:"name" binding:"required"`
+	ReportType   string `json:"report_type"`
+	DepartmentID *uint  `json:"department_id"`
+	Enabled      *bool  `json:"enabled"`
+	Steps        []struct {
+		Stage        int    `json:"stage"`
+		ApproverType string `json:"approver_type"`
+		Level        int    `json:"level"`
+		UserID       *uint  `json:"user_id"`
+	} `json:"steps"`
+}
+
+// validate 校验审批链参数并转换为审批步骤
+func (r *approvalChainRequest) validate(db *gorm.DB) ([]model.ReportApprovalStep, error) {
+	if r.ReportType != "" && r.ReportType != "daily" && r.ReportType != "weekly" {
+		return nil, errors.New("无效的报告类型，有效值：daily, weekly")
+	}
+	if r.DepartmentID != nil {
+		var count int64
+		db.Model(&model.Department{}).Where("id = ?", *r.DepartmentID).Count(&count)
+		if count == 0 {
+			return nil, errors.New("部门不存在")
+		}
+	}
+	if len(r.Steps) == 0 {
+		return nil, errors.New("请至少配置一个审批人")
+	}
+	steps := make([]model.ReportApprovalStep, 0, len(r.Steps))
+	for _, s := range r.Steps {
+		if s.Stage < 1 {
+			return nil, errors.New("审批阶段必须从1开始")
+		}
+		step := model.ReportApprovalStep{Stage: s.Stage, ApproverType: s.ApproverType}
+		switch s.ApproverType {
+		case "user":
+			if s.UserID == nil {
+				return nil, errors.New("指定用户审批时必须选择用户")
+			}
+			var count int64
+			db.Model(&model.User{}).Where("id = ?", *s.UserID).Count(&count)
+			if count == 0 {
+				return nil, errors.New("审批人不存在")
+			}
+			step.UserID = s.UserID
+		case "leader":
+			if s.Level < 0 {
+				return nil, errors.New("部门负责人层级不能为负数")
+			}
+			step.Level = s.Level
+		default:
+			return nil, errors.New("无效的审批人类型，有效值：user, leader")
+		}
+		steps = append(steps, step)
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Stage < steps[j].Stage })
+	return steps, nil
+}
+
+// GetApprovalChains 获取报告审批链列表
+func (h *ReportHandler) GetApprovalChains(c *gin.Context) {
+	var chains []model.ReportApprovalChain
+	h.db.Preload("Department").Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("stage ASC, id ASC") }).Preload("Steps.User").
+		Order("id ASC").Find(&chains)
+	utils.Success(c, chains)
+}
+
+// CreateApprovalChain 创建报告审批链
+func (h *ReportHandler) CreateApprovalChain(c *gin.Context) {
+	var req approvalChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	steps, err := req.validate(h.db)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	chain := model.ReportApprovalChain{
+		Name:         strings.TrimSpace(req.Name),
+		ReportType:   req.ReportType,
+		DepartmentID: req.DepartmentID,
+		Enabled:      req.Enabled == nil || *req.Enabled,
+		Steps:        steps,
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&chain).Error; err != nil {
+			return err
+		}
+		// Enabled 默认值为 true，显式禁用时需要单独更新
+		if !chain.Enabled {
+			return tx.Model(&chain).Update("enabled", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Error(c, utils.CodeError, "创建审批链失败")
+		return
+	}
+	h.db.Preload("Department").Preload("Steps").Preload("Steps.User").First(&chain, chain.ID)
+	utils.Success(c, chain)
+}
+
+// UpdateApprovalChain 更新报告审批链（审批人整体替换，已提交的报告不受影响）
+func (h *ReportHandler) UpdateApprovalChain(c *gin.Context) {
+	var chain model.ReportApprovalChain
+	if err := h.db.First(&chain, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "审批链不存在")
+		return
+	}
+	var req approvalChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	steps, err := req.validate(h.db)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{
+		"name":          strings.TrimSpace(req.Name),
+		"report_type":   req.ReportType,
+		"department_id": req.DepartmentID,
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&chain).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("chain_id = ?", chain.ID).Delete(&model.ReportApprovalStep{}).Error; err != nil {
+			return err
+		}
+		for i := range steps {
+			steps[i].ChainID = chain.ID
+		}
+		return tx.Create(&steps).Error
+	})
+	if err != nil {
+		utils.Error(c, utils.CodeError, "更新审批链失败")
+		return
+	}
+	h.db.Preload("Department").Preload("Steps").Preload("Steps.User").First(&chain, chain.ID)
+	utils.Success(c, chain)
+}
+
+// DeleteApprovalChain 删除报告审批链
+func (h *ReportHandler) DeleteApprovalChain(c *gin.Context) {
+	var chain model.ReportApprovalChain
+	if err := h.db.First(&chain, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "审批链不存在")
+		return
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chain_id = ?", chain.ID).Delete(&model.ReportApprovalStep{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&chain).Error
+	})
+	if err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// GetApprovalDelegations 获取我委托他人的和他人委托给我的审批委托
+func (h *ReportHandler) GetApprovalDelegations(c *gin.Context) {
+	uid := utils.GetUserID(c)
+	var delegations []model.ApprovalDelegation
+	h.db.Preload("User").Preload("Delegate").Where("user_id = ? OR delegate_id = ?", uid, uid).
+		Order("start_date DESC, id DESC").Find(&delegations)
+	utils.Success(c, delegations)
+}
+
+// CreateApprovalDelegation 创建审批委托：请假期间由代理人审批（管理员可以为其他用户设置）
+func (h *ReportHandler) CreateApprovalDelegation(c *gin.Context) {
+	var req struct {
+		UserID     uint   `json:"user_id"`
+		DelegateID uint   `json:"delegate_id" binding:"required"`
+		StartDate  string `json:"start_date" binding:"required"`
+		EndDate    string `json:"end_date" binding:"required"`
+		Reason     string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	uid := utils.GetUserID(c)
+	if req.UserID == 0 {
+		req.UserID = uid
+	} else if req.UserID != uid && !utils.IsAdmin(c) {
+		utils.Error(c, 403, "只能为自己设置审批委托")
+		return
+	}
+	if req.DelegateID == req.UserID {
+		utils.Error(c, 400, "不能委托给自己")
+		return
+	}
+	var delegate model.User
+	if err := h.db.Where("id = ? AND status = ?", req.DelegateID, 1).First(&delegate).Error; err != nil {
+		utils.Error(c, 404, "代理人不存在或已禁用")
+		return
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		utils.Error(c, 400, "开始日期格式错误")
+		return
+	}
+	endDate, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		utils.Error(c, 400, "结束日期格式错误")
+		return
+	}
+	if startDate.After(endDate) {
+		utils.Error(c, 400, "开始日期不能晚于结束日期")
+		return
+	}
+
+	var overlap int64
+	h.db.Model(&model.ApprovalDelegation{}).Where("user_id = ? AND start_date <= ? AND end_date >= ?", req.UserID, endDate, startDate).Count(&overlap)
+	if overlap > 0 {
+		utils.Error(c, 400, "该时间段已存在审批委托")
+		return
+	}
+
+	delegation := model.ApprovalDelegation{
+		UserID:     req.UserID,
+		DelegateID: req.DelegateID,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Reason:     strings.TrimSpace(req.Reason),
+	}
+	if err := h.db.Create(&delegation).Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建审批委托失败")
+		return
+	}
+	h.db.Preload("User").Preload("Delegate").First(&delegation, delegation.ID)
+	utils.Success(c, delegation)
+}
+
+// DeleteApprovalDelegation 取消审批委托
+func (h *ReportHandler) DeleteApprovalDelegation(c *gin.Context) {
+	var delegation model.ApprovalDelegation
+	if err := h.db.First(&delegation, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "审批委托不存在")
+		return
+	}
+	if delegation.UserID != utils.GetUserID(c) && !utils.IsAdmin(c) {
+		utils.Error(c, 403, "没有权限取消该审批委托")
+		return
+	}
+	if err := h.db.Delete(&delegation).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"prjflow/internal/utils"
+)
+
+// 日报提交情况最多统计的天数
+const maxComplianceDays = 62
+
+// GetReportCompliance 日报提交情况：按天、按部门列出未提交日报的用户
+// 参数：start_date、end_date（默认今天），department_id（包括下级部门），include_weekends
+func (h *ReportHandler) GetReportCompliance(c *gin.Context) {
+	today := time.Now().Format("2006-01-02")
+	start, err := time.Parse("2006-01-02", c.DefaultQuery("start_date", today))
+	if err != nil {
+		utils.Error(c, 400, "开始日期格式错误")
+		return
+	}
+	end, err := time.Parse("2006-01-02", c.DefaultQuery("end_date", start.Format("2006-01-02")))
+	if err != nil {
+		utils.Error(c, 400, "结束日期格式错误")
+		return
+	}
+	if start.After(end) {
+		utils.Error(c, 400, "开始日期不能晚于结束日期")
+		return
+	}
+	if end.Sub(start) > maxComplianceDays*24*time.Hour {
+		utils.Error(c, 400, "统计范围不能超过62天")
+		return
+	}
+
+	var departmentID *uint
+	if value := c.Query("department_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.Error(c, 400, "部门ID格式错误")
+			return
+		}
+		deptID := uint(id)
+		departmentID = &deptID
+	}
+
+	days, err := utils.ReportCompliance(h.db, start, end, departmentID, c.Query("include_weekends") == "true")
+	if err != nil {
+		utils.Error(c, utils.CodeError, "统计失败")
+		return
+	}
+	utils.Success(c, days)
+}
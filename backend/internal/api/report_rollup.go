@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"prjflow/internal/utils"
+)
+
+// 工作汇总最多统计的天数
+const maxRollupDays = 92
+
+// rollupPeriod 读取汇总时间范围，默认本周（周一至周日）
+func rollupPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	now := time.Now()
+	monday := now.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+	start, err := time.Parse("2006-01-02", c.DefaultQuery("start_date", monday.Format("2006-01-02")))
+	if err != nil {
+		utils.Error(c, 400, "开始日期格式错误")
+		return start, start, false
+	}
+	end, err := time.Parse("2006-01-02", c.DefaultQuery("end_date", start.AddDate(0, 0, 6).Format("2006-01-02")))
+	if err != nil {
+		utils.Error(c, 400, "结束日期格式错误")
+		return start, end, false
+	}
+	if start.After(end) {
+		utils.Error(c, 400, "开始日期不能晚于结束日期")
+		return start, end, false
+	}
+	if end.Sub(start) > maxRollupDays*24*time.Hour {
+		utils.Error(c, 400, "汇总范围不能超过92天")
+		return start, end, false
+	}
+	return start, end, true
+}
+
+// writeReportRollup 输出汇总结果；member_id 只输出该成员的明细
+// format：json（默认）、markdown、csv（按成员的汇总表）；download=1 时作为附件下载
+func writeReportRollup(c *gin.Context, rollup *utils.ReportRollup) {
+	filename := fmt.Sprintf("rollup-%s-%d-%s-%s", rollup.Scope, rollup.ScopeID, rollup.StartDate, rollup.EndDate)
+	var data interface{} = rollup
+	markdown := rollup.Markdown
+	if value := c.Query("member_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.Error(c, 400, "成员ID格式错误")
+			return
+		}
+		member := rollup.Member(uint(id))
+		if member == nil {
+			utils.Error(c, 404, "成员不在汇总范围内")
+			return
+		}
+		data = member
+		markdown = func() string { return rollup.MemberMarkdown(member) }
+		filename = fmt.Sprintf("%s-%s", filename, member.Username)
+	}
+
+	disposition := "inline"
+	if c.Query("download") == "1" {
+		disposition = "attachment"
+	}
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		utils.Success(c, data)
+	case "markdown", "md":
+		c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s.md\"", disposition, filename))
+		c.Data(200, "text/markdown; charset=utf-8", []byte(markdown()))
+	case "csv":
+		content, err := rollup.CSV()
+		if err != nil {
+			utils.Error(c, utils.CodeError, "导出失败")
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("%s; filename=\"%s.csv\"", disposition, filename))
+		c.Data(200, "text/csv; charset=utf-8", content)
+	default:
+		utils.Error(c, 400, "无效的导出格式，有效值：json, markdown, csv")
+	}
+}
+
+// GetDepartmentRollup 部门（包括下级部门）工作汇总：合并成员已提交的日报和周报、按项目统计工时、
+// 完成和新建的工作项以及阻塞与风险。只有管理员和该部门或上级部门的负责人可以查看
+func (h *ReportHandler) GetDepartmentRollup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "部门ID格式错误")
+		return
+	}
+	if !utils.IsAdmin(c) && !utils.IsDepartmentLeader(h.db, utils.GetUserID(c), uint(id)) {
+		utils.Error(c, 403, "没有权限查看该部门的工作汇总")
+		return
+	}
+	start, end, ok := rollupPeriod(c)
+	if !ok {
+		return
+	}
+	rollup, err := utils.BuildDepartmentRollup(h.db, uint(id), start, end)
+	if err == gorm.ErrRecordNotFound {
+		utils.Error(c, 404, "部门不存在")
+		return
+	}
+	if err != nil {
+		utils.Error(c, utils.CodeError, "生成工作汇总失败")
+		return
+	}
+	writeReportRollup(c, rollup)
+}
+
+// GetProjectRollup 项目工作汇总：工时和工作项只统计该项目，报告为项目成员提交的报告
+func (h *ReportHandler) GetProjectRollup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.Error(c, 400, "项目ID格式错误")
+		return
+	}
+	start, end, ok := rollupPeriod(c)
+	if !ok {
+		return
+	}
+	rollup, err := utils.BuildProjectRollup(h.db, uint(id), start, end)
+	if err == gorm.ErrRecordNotFound {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+	if err != nil {
+		utils.Error(c, utils.CodeError, "生成工作汇总失败")
+		return
+	}
+	writeReportRollup(c, rollup)
+}
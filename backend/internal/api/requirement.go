@@ -127,6 +127,11 @@ func (h *RequirementHandler) GetRequirement(c *gin.Context) {
 		return
 	}
 
+	// 查看受限对象时记录审计日志
+	if requirement.Confidential {
+		utils.RecordRestrictedView(h.db, c, "requirement", requirement.ID, "查看需求详情")
+	}
+
 	utils.Success(c, requirement)
 }
 
@@ -240,6 +245,7 @@ func (h *RequirementHandler) CreateRequirement(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventCreated, "requirement", requirement.ID, requirement.ProjectID, requirement.Status)
 	utils.Success(c, requirement)
 }
 
@@ -454,6 +460,7 @@ func (h *RequirementHandler) UpdateRequirement(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "requirement", requirement.ID, requirement.ProjectID, requirement.Status)
 	utils.Success(c, requirement)
 }
 
@@ -487,6 +494,7 @@ func (h *RequirementHandler) DeleteRequirement(c *gin.Context) {
 		return
 	}
 
+	notifyCardChange(h.db, c, cardEventDeleted, "requirement", requirement.ID, requirement.ProjectID, "")
 	utils.Success(c, gin.H{"message": "删除成功"})
 }
 
@@ -580,15 +588,18 @@ func (h *RequirementHandler) UpdateRequirementStatus(c *gin.Context) {
 		return
 	}
 
+	oldStatus := requirement.Status
 	requirement.Status = req.Status
 	if err := h.db.Save(&requirement).Error; err != nil {
 		utils.Error(c, utils.CodeError, "更新失败")
 		return
 	}
+	utils.RecordStatusChange(h.db, "requirement", requirement.ID, utils.GetUserID(c), oldStatus, requirement.Status)
 
 	// 重新加载关联数据
 	h.db.Preload("Project").Preload("Creator").Preload("Assignee").First(&requirement, requirement.ID)
 
+	notifyCardChange(h.db, c, cardEventUpdated, "requirement", requirement.ID, requirement.ProjectID, requirement.Status)
 	utils.Success(c, requirement)
 }
 
@@ -738,6 +749,11 @@ func (h *RequirementHandler) GetRequirementHistory(c *gin.Context) {
 		return
 	}
 
+	// 查看受限对象时记录审计日志
+	if requirement.Confidential {
+		utils.RecordRestrictedView(h.db, c, "requirement", requirement.ID, "查看需求历史记录")
+	}
+
 	// 查询操作记录
 	var actions []model.Action
 	if err := h.db.Where("object_type = ? AND object_id = ?", "requirement", id).
@@ -926,5 +942,6 @@ func (h *RequirementHandler) AssignRequirement(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "requirement", requirement.ID, requirement.ProjectID, requirement.Status)
 	utils.Success(c, requirement)
 }
@@ -22,7 +22,7 @@ func NewResourceAllocationHandler(db *gorm.DB) *ResourceAllocationHandler {
 func (h *ResourceAllocationHandler) GetResourceAllocations(c *gin.Context) {
 	var allocations []model.ResourceAllocation
 	query := h.db.Model(&model.ResourceAllocation{}).
-		Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task").Preload("Bug").Preload("Project")
+		Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task", utils.RestrictedPreload(c, "task")).Preload("Bug", utils.RestrictedPreload(c, "bug")).Preload("Project")
 
 	// 用户筛选（通过资源）
 	requestUserIDStr := c.Query("user_id")
@@ -204,7 +204,7 @@ func (h *ResourceAllocationHandler) GetResourceAllocations(c *gin.Context) {
 func (h *ResourceAllocationHandler) GetResourceAllocation(c *gin.Context) {
 	id := c.Param("id")
 	var allocation model.ResourceAllocation
-	if err := h.db.Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task").Preload("Bug").Preload("Project").First(&allocation, id).Error; err != nil {
+	if err := h.db.Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task", utils.RestrictedPreload(c, "task")).Preload("Bug", utils.RestrictedPreload(c, "bug")).Preload("Project").First(&allocation, id).Error; err != nil {
 		utils.Error(c, 404, "资源分配不存在")
 		return
 	}
@@ -306,7 +306,7 @@ func (h *ResourceAllocationHandler) CreateResourceAllocation(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task").Preload("Bug").Preload("Project").First(&allocation, allocation.ID)
+	h.db.Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task", utils.RestrictedPreload(c, "task")).Preload("Bug", utils.RestrictedPreload(c, "bug")).Preload("Project").First(&allocation, allocation.ID)
 
 	utils.Success(c, allocation)
 }
@@ -394,7 +394,7 @@ func (h *ResourceAllocationHandler) UpdateResourceAllocation(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task").Preload("Bug").Preload("Project").First(&allocation, allocation.ID)
+	h.db.Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task", utils.RestrictedPreload(c, "task")).Preload("Bug", utils.RestrictedPreload(c, "bug")).Preload("Project").First(&allocation, allocation.ID)
 
 	utils.Success(c, allocation)
 }
@@ -443,7 +443,7 @@ func (h *ResourceAllocationHandler) GetResourceCalendar(c *gin.Context) {
 	}
 
 	query := h.db.Model(&model.ResourceAllocation{}).
-		Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task").Preload("Bug").Preload("Project").
+		Preload("Resource").Preload("Resource.User").Preload("Resource.Project").Preload("Task", utils.RestrictedPreload(c, "task")).Preload("Bug", utils.RestrictedPreload(c, "bug")).Preload("Project").
 		Where("date >= ? AND date <= ?", startDate, endDate)
 
 	// 用户筛选
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"encoding/json"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// dashboardResponse 共享仪表板及其组件配置
+func dashboardResponse(dashboard *model.Dashboard, widgets interface{}) gin.H {
+	return gin.H{
+		"id":          dashboard.ID,
+		"name":        dashboard.Name,
+		"description": dashboard.Description,
+		"scope":       dashboard.Scope,
+		"project_id":  dashboard.ProjectID,
+		"project":     dashboard.Project,
+		"owner_id":    dashboard.OwnerID,
+		"owner":       dashboard.Owner,
+		"widgets":     widgets,
+		"created_at":  dashboard.CreatedAt,
+		"updated_at":  dashboard.UpdatedAt,
+	}
+}
+
+// dashboardInstances 仪表板保存的组件实例；项目仪表板中未指定项目的组件默认使用仪表板所属项目
+func dashboardInstances(dashboard *model.Dashboard) []dashboardWidgetInstance {
+	instances := make([]dashboardWidgetInstance, 0)
+	if dashboard.Widgets != "" {
+		_ = json.Unmarshal([]byte(dashboard.Widgets), &instances)
+	}
+	if dashboard.ProjectID != nil {
+		for i := range instances {
+			if instances[i].Params.ProjectID == nil {
+				instances[i].Params.ProjectID = dashboard.ProjectID
+			}
+		}
+	}
+	return instances
+}
+
+// canViewDashboard 团队仪表板所有用户可见，项目仪表板项目成员可见
+func (h *DashboardHandler) canViewDashboard(c *gin.Context, dashboard *model.Dashboard) bool {
+	if dashboard.Scope == "team" || dashboard.OwnerID == utils.GetUserID(c) {
+		return true
+	}
+	return dashboard.ProjectID != nil && utils.CheckProjectAccess(h.db, c, *dashboard.ProjectID)
+}
+
+// canManageDashboard 创建人和管理员可以修改仪表板，项目仪表板还可以由有项目管理权限的成员修改
+func (h *DashboardHandler) canManageDashboard(c *gin.Context, dashboard *model.Dashboard) bool {
+	if utils.IsAdmin(c) || dashboard.OwnerID == utils.GetUserID(c) {
+		return true
+	}
+	return dashboard.ProjectID != nil && utils.HasProjectPermission(h.db, c, *dashboard.ProjectID, "project:manage")
+}
+
+// GetSharedDashboards 获取可见的共享仪表板列表
+// 参数：scope（team, project）、project_id
+func (h *DashboardHandler) GetSharedDashboards(c *gin.Context) {
+	query := h.db.Model(&model.Dashboard{}).Preload("Project").Preload("Owner")
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+	if !utils.IsAdmin(c) {
+		userID := utils.GetUserID(c)
+		projectIDs := utils.GetUserProjectIDs(h.db, userID)
+		if len(projectIDs) > 0 {
+			query = query.Where("scope = ? OR owner_id = ? OR project_id IN ?", "team", userID, projectIDs)
+		} else {
+			query = query.Where("scope = ? OR owner_id = ?", "team", userID)
+		}
+	}
+
+	var dashboards []model.Dashboard
+	if err := query.Order("scope ASC, id ASC").Find(&dashboards).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询仪表板失败")
+		return
+	}
+	list := make([]gin.H, 0, len(dashboards))
+	for i := range dashboards {
+		if !h.canViewDashboard(c, &dashboards[i]) {
+			continue
+		}
+		list = append(list, dashboardResponse(&dashboards[i], dashboardInstances(&dashboards[i])))
+	}
+	utils.Success(c, list)
+}
+
+// GetSharedDashboard 获取共享仪表板及其组件数据（refresh=1 时忽略缓存）
+func (h *DashboardHandler) GetSharedDashboard(c *gin.Context) {
+	var dashboard model.Dashboard
+	if err := h.db.Preload("Project").Preload("Owner").First(&dashboard, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "仪表板不存在")
+		return
+	}
+	if !h.canViewDashboard(c, &dashboard) {
+		utils.Error(c, 403, "没有权限查看该仪表板")
+		return
+	}
+	utils.Success(c, dashboardResponse(&dashboard, h.renderWidgets(c, dashboardInstances(&dashboard))))
+}
+
+type sharedDashboardRequest struct {
+	Name        string      `json:"name" binding:"required"`
+	Description string      `json:"description"`
+	Scope       string      `json:"scope"`
+	ProjectID   *uint       `json:"project_id"`
+	Widgets     interface{} `json:"widgets"`
+}
+
+// bindDashboardWidgets 校验组件配置并转换为 JSON
+func bindDashboardWidgets(c *gin.Context, widgets interface{}) (string, bool) {
+	if widgets == nil {
+		return "[]", true
+	}
+	instances, err := parseWidgetInstances(widgets)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return "", false
+	}
+	data, _ := json.Marshal(instances)
+	return string(data), true
+}
+
+// CreateSharedDashboard 创建共享仪表板：团队仪表板需要管理员，项目仪表板需要项目管理权限
+func (h *DashboardHandler) CreateSharedDashboard(c *gin.Context) {
+	var req sharedDashboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	dashboard := model.Dashboard{Name: req.Name, Description: req.Description, Scope: req.Scope, OwnerID: utils.GetUserID(c)}
+	switch req.Scope {
+	case "team":
+		if !utils.IsAdmin(c) {
+			utils.Error(c, 403, "只有管理员可以创建团队仪表板")
+			return
+		}
+	case "project":
+		if req.ProjectID == nil {
+			utils.Error(c, 400, "项目仪表板需要指定项目")
+			return
+		}
+		var project model.Project
+		if err := h.db.First(&project, *req.ProjectID).Error; err != nil {
+			utils.Error(c, 404, "项目不存在")
+			return
+		}
+		if !utils.HasProjectPermission(h.db, c, project.ID, "project:manage") {
+			utils.Error(c, 403, "没有权限创建项目仪表板")
+			return
+		}
+		dashboard.ProjectID = &project.ID
+	default:
+		utils.Error(c, 400, "无效的仪表板范围，有效值：team, project")
+		return
+	}
+
+	widgets, ok := bindDashboardWidgets(c, req.Widgets)
+	if !ok {
+		return
+	}
+	dashboard.Widgets = widgets
+	if err := h.db.Create(&dashboard).Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建仪表板失败")
+		return
+	}
+	utils.Success(c, dashboardResponse(&dashboard, dashboardInstances(&dashboard)))
+}
+
+// UpdateSharedDashboard 更新共享仪表板的名称、描述和组件配置（范围和所属项目不可修改）
+func (h *DashboardHandler) UpdateSharedDashboard(c *gin.Context) {
+	var dashboard model.Dashboard
+	if err := h.db.First(&dashboard, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "仪表板不存在")
+		return
+	}
+	if !h.canManageDashboard(c, &dashboard) {
+		utils.Error(c, 403, "没有权限修改该仪表板")
+		return
+	}
+
+	var req struct {
+		Name        *string     `json:"name"`
+		Description *string     `json:"description"`
+		Widgets     interface{} `json:"widgets"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			utils.Error(c, 400, "仪表板名称不能为空")
+			return
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Widgets != nil {
+		widgets, ok := bindDashboardWidgets(c, req.Widgets)
+		if !ok {
+			return
+		}
+		updates["widgets"] = widgets
+	}
+	if len(updates) > 0 {
+		if err := h.db.Model(&dashboard).Updates(updates).Error; err != nil {
+			utils.Error(c, utils.CodeError, "更新仪表板失败")
+			return
+		}
+	}
+
+	h.db.Preload("Project").Preload("Owner").First(&dashboard, dashboard.ID)
+	utils.Success(c, dashboardResponse(&dashboard, dashboardInstances(&dashboard)))
+}
+
+// DeleteSharedDashboard 删除共享仪表板
+func (h *DashboardHandler) DeleteSharedDashboard(c *gin.Context) {
+	var dashboard model.Dashboard
+	if err := h.db.First(&dashboard, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			utils.Error(c, 404, "仪表板不存在")
+			return
+		}
+		utils.Error(c, utils.CodeError, "查询仪表板失败")
+		return
+	}
+	if !h.canManageDashboard(c, &dashboard) {
+		utils.Error(c, 403, "没有权限删除该仪表板")
+		return
+	}
+	if err := h.db.Delete(&dashboard).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除仪表板失败")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
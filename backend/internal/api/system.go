@@ -53,6 +53,7 @@ func (h *SystemHandler) GetBackupConfig(c *gin.Context) {
 		"enabled":         enabled,
 		"backup_time":     backupTime,
 		"last_backup_date": lastBackupDate,
+		"retention_days":  utils.LoadBackupRetentionDays(h.db),
 	})
 }
 
@@ -61,6 +62,8 @@ func (h *SystemHandler) SaveBackupConfig(c *gin.Context) {
 	var req struct {
 		Enabled    bool   `json:"enabled" binding:"required"`
 		BackupTime string `json:"backup_time" binding:"required"`
+		// 备份保留天数（可选，0 表示永久保留）
+		RetentionDays *int `json:"retention_days"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -73,6 +76,10 @@ func (h *SystemHandler) SaveBackupConfig(c *gin.Context) {
 		utils.Error(c, 400, "备份时间格式错误，应为 HH:MM (24小时制)")
 		return
 	}
+	if req.RetentionDays != nil && *req.RetentionDays < 0 {
+		utils.Error(c, 400, "备份保留天数不能为负数")
+		return
+	}
 
 	// 保存备份启用状态
 	enabledValue := "false"
@@ -104,6 +111,14 @@ func (h *SystemHandler) SaveBackupConfig(c *gin.Context) {
 		return
 	}
 
+	// 保存备份保留天数
+	if req.RetentionDays != nil {
+		if err := utils.SaveBackupRetentionDays(h.db, *req.RetentionDays); err != nil {
+			utils.Error(c, utils.CodeError, "保存备份保留天数失败: "+err.Error())
+			return
+		}
+	}
+
 	// 重新加载定时任务配置
 	scheduler := utils.GetBackupScheduler(h.db)
 	scheduler.Reload()
@@ -141,6 +156,180 @@ func (h *SystemHandler) TriggerBackup(c *gin.Context) {
 	})
 }
 
+// GetReportAutomationConfig 获取报告自动化配置（自动生成草稿和未提交提醒）
+func (h *SystemHandler) GetReportAutomationConfig(c *gin.Context) {
+	utils.Success(c, gin.H{
+		"config":       utils.LoadReportAutomationConfig(h.db),
+		"mail_enabled": utils.MailEnabled(),
+	})
+}
+
+// SaveReportAutomationConfig 保存报告自动化配置
+func (h *SystemHandler) SaveReportAutomationConfig(c *gin.Context) {
+	var req utils.ReportAutomationConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if req.DraftTime == "" {
+		req.DraftTime = "17:00"
+	}
+	if req.ReminderTime == "" {
+		req.ReminderTime = "18:30"
+	}
+	for _, value := range []string{req.DraftTime, req.ReminderTime} {
+		if _, err := time.Parse("15:04", value); err != nil {
+			utils.Error(c, 400, "时间格式错误，应为 HH:MM (24小时制)")
+			return
+		}
+	}
+
+	if err := utils.SaveReportAutomationConfig(h.db, req); err != nil {
+		utils.Error(c, utils.CodeError, "保存报告自动化配置失败: "+err.Error())
+		return
+	}
+	utils.GetReportScheduler(h.db).Reload()
+
+	utils.Success(c, gin.H{
+		"message": "报告自动化配置已保存",
+	})
+}
+
+// RunReportAutomation 手动执行报告自动化任务：draft（生成日报草稿，weekly=true 时同时生成周报草稿）或 reminder（提醒未提交的用户）
+func (h *SystemHandler) RunReportAutomation(c *gin.Context) {
+	var req struct {
+		Job    string `json:"job" binding:"required"`
+		Date   string `json:"date"` // 默认今天
+		Weekly bool   `json:"weekly"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	date := time.Now()
+	if req.Date != "" {
+		parsed, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			utils.Error(c, 400, "日期格式错误，应为 YYYY-MM-DD")
+			return
+		}
+		date = parsed
+	}
+
+	switch req.Job {
+	case "draft":
+		daily, err := utils.DraftDailyReports(h.db, date)
+		if err != nil {
+			utils.Error(c, utils.CodeError, "生成日报草稿失败: "+err.Error())
+			return
+		}
+		weekly := 0
+		if req.Weekly {
+			if weekly, err = utils.DraftWeeklyReports(h.db, date); err != nil {
+				utils.Error(c, utils.CodeError, "生成周报草稿失败: "+err.Error())
+				return
+			}
+		}
+		utils.Success(c, gin.H{"daily": daily, "weekly": weekly})
+	case "reminder":
+		count, err := utils.RemindMissingDailyReports(h.db, date, utils.LoadReportAutomationConfig(h.db).ReminderEmail)
+		if err != nil {
+			utils.Error(c, utils.CodeError, "发送提醒失败: "+err.Error())
+			return
+		}
+		utils.Success(c, gin.H{"reminded": count})
+	default:
+		utils.Error(c, 400, "无效的任务，有效值：draft, reminder")
+	}
+}
+
+// GetAuditRetention 获取审计日志保留配置（按操作类型的保留天数）
+func (h *SystemHandler) GetAuditRetention(c *gin.Context) {
+	utils.Success(c, gin.H{
+		"config":      utils.LoadAuditRetention(h.db),
+		"archive_dir": utils.DefaultAuditArchiveDir(),
+	})
+}
+
+// SaveAuditRetention 保存审计日志保留配置，0 表示永久保留
+func (h *SystemHandler) SaveAuditRetention(c *gin.Context) {
+	var req utils.AuditRetention
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if err := utils.SaveAuditRetention(h.db, req); err != nil {
+		utils.Error(c, 400, "保存审计日志保留配置失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{
+		"message": "审计日志保留配置已保存",
+	})
+}
+
+// ArchiveAuditLogs 立即归档过期的审计日志（备份时也会自动归档）
+func (h *SystemHandler) ArchiveAuditLogs(c *gin.Context) {
+	auditDB := h.db
+	if utils.AuditDB != nil {
+		auditDB = utils.AuditDB
+	}
+	count, file, err := utils.ArchiveExpiredAuditLogs(auditDB, utils.LoadAuditRetention(h.db), utils.DefaultAuditArchiveDir())
+	if err != nil {
+		utils.Error(c, utils.CodeError, "归档审计日志失败: "+err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"archived": count, "file": file})
+}
+
+// BackfillMetrics 根据历史记录回填项目指标快照（不指定项目时回填所有项目）
+func (h *SystemHandler) BackfillMetrics(c *gin.Context) {
+	var req struct {
+		ProjectID *uint  `json:"project_id"`
+		StartDate string `json:"start_date" binding:"required"`
+		EndDate   string `json:"end_date" binding:"required"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	start, err := time.ParseInLocation(utils.MetricDateLayout, req.StartDate, time.Local)
+	if err != nil {
+		utils.Error(c, 400, "开始日期格式错误，应为 YYYY-MM-DD")
+		return
+	}
+	end, err := time.ParseInLocation(utils.MetricDateLayout, req.EndDate, time.Local)
+	if err != nil {
+		utils.Error(c, 400, "结束日期格式错误，应为 YYYY-MM-DD")
+		return
+	}
+	if start.After(end) {
+		utils.Error(c, 400, "开始日期不能晚于结束日期")
+		return
+	}
+
+	var count int
+	if req.ProjectID != nil {
+		var project model.Project
+		if err := h.db.First(&project, *req.ProjectID).Error; err != nil {
+			utils.Error(c, 404, "项目不存在")
+			return
+		}
+		count, err = utils.BackfillProjectMetrics(h.db, project.ID, start, end, req.Overwrite)
+	} else {
+		count, err = utils.BackfillAllProjectMetrics(h.db, start, end, req.Overwrite)
+	}
+	if err != nil {
+		utils.Error(c, utils.CodeError, "回填指标快照失败: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"days": count,
+	})
+}
+
 // GetLogLevel 获取当前日志级别
 func (h *SystemHandler) GetLogLevel(c *gin.Context) {
 	level := utils.GetLogLevel()
@@ -21,7 +21,7 @@ func NewTaskHandler(db *gorm.DB) *TaskHandler {
 // GetTasks 获取任务列表
 func (h *TaskHandler) GetTasks(c *gin.Context) {
 	var tasks []model.Task
-	query := h.db.Preload("Project").Preload("Requirement").Preload("Creator").Preload("Assignee").Preload("Dependencies")
+	query := h.db.Preload("Project").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task"))
 
 	// 权限过滤：普通用户只能看到自己创建或参与的任务
 	query = utils.FilterTasksByUser(h.db, c, query)
@@ -114,7 +114,7 @@ func (h *TaskHandler) GetTasks(c *gin.Context) {
 func (h *TaskHandler) GetTask(c *gin.Context) {
 	id := c.Param("id")
 	var task model.Task
-	if err := h.db.Preload("Project").Preload("Requirement").Preload("Creator").Preload("Assignee").Preload("Dependencies").
+	if err := h.db.Preload("Project").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task")).
 		Preload("Attachments").Preload("Attachments.Creator").
 		First(&task, id).Error; err != nil {
 		utils.Error(c, 404, "任务不存在")
@@ -127,6 +127,11 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 		return
 	}
 
+	// 查看受限对象时记录审计日志
+	if task.Confidential {
+		utils.RecordRestrictedView(h.db, c, "task", task.ID, "查看任务详情")
+	}
+
 	utils.Success(c, task)
 }
 
@@ -293,7 +298,7 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Requirement").Preload("Creator").Preload("Assignee").Preload("Dependencies").First(&task, task.ID)
+	h.db.Preload("Project").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task")).First(&task, task.ID)
 
 	// 记录创建操作
 	if userID, exists := c.Get("user_id"); exists {
@@ -303,6 +308,7 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventCreated, "task", task.ID, task.ProjectID, task.Status)
 	utils.Success(c, task)
 }
 
@@ -579,7 +585,7 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	}
 
 	// 重新加载关联数据（包含附件）
-	h.db.Session(&gorm.Session{}).Preload("Project").Preload("Requirement").Preload("Creator").Preload("Assignee").Preload("Dependencies").
+	h.db.Session(&gorm.Session{}).Preload("Project").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task")).
 		Preload("Attachments").Preload("Attachments.Creator").
 		First(&task, task.ID)
 
@@ -593,6 +599,7 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "task", task.ID, task.ProjectID, task.Status)
 	utils.Success(c, task)
 }
 
@@ -626,6 +633,7 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
+	notifyCardChange(h.db, c, cardEventDeleted, "task", task.ID, task.ProjectID, "")
 	utils.Success(c, gin.H{"message": "删除成功"})
 }
 
@@ -667,6 +675,7 @@ func (h *TaskHandler) UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
+	oldStatus := task.Status
 	task.Status = req.Status
 	// 如果状态为done，自动设置进度为100
 	if req.Status == "done" {
@@ -679,10 +688,12 @@ func (h *TaskHandler) UpdateTaskStatus(c *gin.Context) {
 		utils.Error(c, utils.CodeError, "更新失败")
 		return
 	}
+	utils.RecordStatusChange(h.db, "task", task.ID, utils.GetUserID(c), oldStatus, task.Status)
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Requirement").Preload("Creator").Preload("Assignee").Preload("Dependencies").First(&task, task.ID)
+	h.db.Preload("Project").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task")).First(&task, task.ID)
 
+	notifyCardChange(h.db, c, cardEventUpdated, "task", task.ID, task.ProjectID, task.Status)
 	utils.Success(c, task)
 }
 
@@ -713,6 +724,8 @@ func (h *TaskHandler) UpdateTaskProgress(c *gin.Context) {
 		return
 	}
 
+	oldStatus := task.Status
+
 	// 更新进度
 	if req.Progress != nil {
 		// 验证进度
@@ -795,10 +808,12 @@ func (h *TaskHandler) UpdateTaskProgress(c *gin.Context) {
 		}
 	}
 	// 如果 req.Progress != nil，说明用户手动设置了进度，已经在上面的代码中设置了，不需要再计算
+	utils.RecordStatusChange(h.db, "task", task.ID, utils.GetUserID(c), oldStatus, task.Status)
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Requirement").Preload("Creator").Preload("Assignee").Preload("Dependencies").First(&task, task.ID)
+	h.db.Preload("Project").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task")).First(&task, task.ID)
 
+	notifyCardChange(h.db, c, cardEventUpdated, "task", task.ID, task.ProjectID, task.Status)
 	utils.Success(c, task)
 }
 
@@ -911,18 +926,8 @@ func (h *TaskHandler) calculateProgressFromHours(task *model.Task) {
 		actualHours = *task.ActualHours
 	}
 
-	// 计算进度：实际工时 / 预估工时 * 100
-	progress := int((actualHours / *task.EstimatedHours) * 100)
-	
-	// 进度不能超过100%
-	if progress > 100 {
-		progress = 100
-	}
-	
-	// 如果进度小于0，设为0
-	if progress < 0 {
-		progress = 0
-	}
+	// 计算进度：实际工时 / 预估工时 * 100，范围 0-100
+	progress := utils.TaskProgressFromHours(actualHours, *task.EstimatedHours)
 
 	// 更新进度
 	task.Progress = progress
@@ -958,6 +963,11 @@ func (h *TaskHandler) GetTaskHistory(c *gin.Context) {
 		return
 	}
 
+	// 查看受限对象时记录审计日志
+	if task.Confidential {
+		utils.RecordRestrictedView(h.db, c, "task", task.ID, "查看任务历史记录")
+	}
+
 	// 查询操作记录
 	var actions []model.Action
 	if err := h.db.Where("object_type = ? AND object_id = ?", "task", id).
@@ -1096,7 +1106,7 @@ func (h *TaskHandler) AssignTask(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Requirement").Preload("Creator").Preload("Assignee").Preload("Dependencies").First(&task, task.ID)
+	h.db.Preload("Project").Preload("Requirement", utils.RestrictedPreload(c, "requirement")).Preload("Creator").Preload("Assignee").Preload("Dependencies", utils.RestrictedPreload(c, "task")).First(&task, task.ID)
 
 	// 记录分配操作
 	userID, exists := c.Get("user_id")
@@ -1147,5 +1157,6 @@ func (h *TaskHandler) AssignTask(c *gin.Context) {
 		}
 	}
 
+	notifyCardChange(h.db, c, cardEventUpdated, "task", task.ID, task.ProjectID, task.Status)
 	utils.Success(c, task)
 }
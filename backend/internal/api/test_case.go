@@ -18,7 +18,7 @@ func NewTestCaseHandler(db *gorm.DB) *TestCaseHandler {
 // GetTestCases 获取测试单列表
 func (h *TestCaseHandler) GetTestCases(c *gin.Context) {
 	var testCases []model.TestCase
-	query := h.db.Preload("Project").Preload("Creator").Preload("Bugs")
+	query := h.db.Preload("Project").Preload("Creator").Preload("Bugs", utils.RestrictedPreload(c, "bug"))
 
 	// 搜索
 	if keyword := c.Query("keyword"); keyword != "" {
@@ -100,7 +100,7 @@ func (h *TestCaseHandler) GetTestCases(c *gin.Context) {
 func (h *TestCaseHandler) GetTestCase(c *gin.Context) {
 	id := c.Param("id")
 	var testCase model.TestCase
-	if err := h.db.Preload("Project").Preload("Creator").Preload("Bugs").First(&testCase, id).Error; err != nil {
+	if err := h.db.Preload("Project").Preload("Creator").Preload("Bugs", utils.RestrictedPreload(c, "bug")).Preload("Steps", orderTestCaseSteps).First(&testCase, id).Error; err != nil {
 		utils.Error(c, 404, "测试单不存在")
 		return
 	}
@@ -120,6 +120,7 @@ func (h *TestCaseHandler) CreateTestCase(c *gin.Context) {
 		Summary     string   `json:"summary"`     // 测试摘要（合并自TestReport）
 		ProjectID   uint     `json:"project_id" binding:"required"`
 		BugIDs      []uint   `json:"bug_ids"` // 关联的Bug ID列表
+		Steps       []testCaseStepRequest `json:"steps"` // 结构化测试步骤
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -174,6 +175,14 @@ func (h *TestCaseHandler) CreateTestCase(c *gin.Context) {
 		return
 	}
 
+	// 保存测试步骤
+	if len(req.Steps) > 0 {
+		if err := replaceTestCaseSteps(h.db, testCase.ID, req.Steps); err != nil {
+			utils.Error(c, utils.CodeError, "保存测试步骤失败")
+			return
+		}
+	}
+
 	// 关联Bug
 	if len(req.BugIDs) > 0 {
 		var bugs []model.Bug
@@ -183,7 +192,7 @@ func (h *TestCaseHandler) CreateTestCase(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Creator").Preload("Bugs").First(&testCase, testCase.ID)
+	h.db.Preload("Project").Preload("Creator").Preload("Bugs", utils.RestrictedPreload(c, "bug")).Preload("Steps", orderTestCaseSteps).First(&testCase, testCase.ID)
 
 	utils.Success(c, testCase)
 }
@@ -206,6 +215,7 @@ func (h *TestCaseHandler) UpdateTestCase(c *gin.Context) {
 		Result      *string  `json:"result"`      // 测试结果：passed, failed, blocked（合并自TestReport）
 		Summary     *string  `json:"summary"`     // 测试摘要（合并自TestReport）
 		BugIDs      []uint   `json:"bug_ids"` // 关联的Bug ID列表
+		Steps       []testCaseStepRequest `json:"steps"` // 结构化测试步骤（传入时整体替换）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -244,6 +254,14 @@ func (h *TestCaseHandler) UpdateTestCase(c *gin.Context) {
 		return
 	}
 
+	// 更新测试步骤
+	if req.Steps != nil {
+		if err := replaceTestCaseSteps(h.db, testCase.ID, req.Steps); err != nil {
+			utils.Error(c, utils.CodeError, "保存测试步骤失败")
+			return
+		}
+	}
+
 	// 更新关联Bug
 	if req.BugIDs != nil {
 		var bugs []model.Bug
@@ -254,7 +272,7 @@ func (h *TestCaseHandler) UpdateTestCase(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Creator").Preload("Bugs").First(&testCase, testCase.ID)
+	h.db.Preload("Project").Preload("Creator").Preload("Bugs", utils.RestrictedPreload(c, "bug")).Preload("Steps", orderTestCaseSteps).First(&testCase, testCase.ID)
 
 	utils.Success(c, testCase)
 }
@@ -299,7 +317,7 @@ func (h *TestCaseHandler) UpdateTestCaseStatus(c *gin.Context) {
 	}
 
 	// 重新加载关联数据
-	h.db.Preload("Project").Preload("Creator").Preload("Bugs").First(&testCase, testCase.ID)
+	h.db.Preload("Project").Preload("Creator").Preload("Bugs", utils.RestrictedPreload(c, "bug")).Preload("Steps", orderTestCaseSteps).First(&testCase, testCase.ID)
 
 	utils.Success(c, testCase)
 }
@@ -439,6 +457,52 @@ func (h *TestCaseHandler) GetTestCaseStatistics(c *gin.Context) {
 		"fail_rate":     failRate,
 		"project_stats": projectStats,
 		"type_stats":    typeStats,
+		"version_stats": versionTestStats(h.db, c.Query("project_id")),
+	})
+}
+
+// testCaseStepRequest 测试步骤请求
+type testCaseStepRequest struct {
+	Action   string `json:"action"`
+	Expected string `json:"expected"`
+}
+
+// orderTestCaseSteps 测试步骤按序号排序
+func orderTestCaseSteps(db *gorm.DB) *gorm.DB {
+	return db.Order("sort ASC, id ASC")
+}
+
+// replaceTestCaseSteps 替换测试单的全部步骤（按请求顺序编号）
+// 按位置复用已有的步骤记录，以免已有的步骤执行结果失去对应的步骤
+func replaceTestCaseSteps(db *gorm.DB, testCaseID uint, steps []testCaseStepRequest) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing []model.TestCaseStep
+		if err := tx.Where("test_case_id = ?", testCaseID).Order("sort ASC, id ASC").Find(&existing).Error; err != nil {
+			return err
+		}
+		for i, step := range steps {
+			if i < len(existing) {
+				if err := tx.Model(&existing[i]).Updates(map[string]interface{}{
+					"sort": i + 1, "action": step.Action, "expected": step.Expected,
+				}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Create(&model.TestCaseStep{
+				TestCaseID: testCaseID, Sort: i + 1, Action: step.Action, Expected: step.Expected,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if len(existing) > len(steps) {
+			var removed []uint
+			for _, step := range existing[len(steps):] {
+				removed = append(removed, step.ID)
+			}
+			return tx.Delete(&model.TestCaseStep{}, removed).Error
+		}
+		return nil
 	})
 }
 
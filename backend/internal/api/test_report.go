@@ -0,0 +1,370 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 测试报告的最大大小
+const maxTestReportSize = 20 << 20
+
+// testReportBugChange 上传测试报告时Bug的变更，提交事务后记录操作并广播
+type testReportBugChange struct {
+	bugID     uint
+	action    string // created, activated, resolved
+	oldStatus string
+	newStatus string
+	comment   string
+}
+
+// readTestReport 读取测试报告内容：multipart 表单的 file 字段，或者请求体
+func readTestReport(c *gin.Context) ([]byte, error) {
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > maxTestReportSize {
+			return nil, fmt.Errorf("测试报告不能超过20MB")
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTestReportSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTestReportSize {
+		return nil, fmt.Errorf("测试报告不能超过20MB")
+	}
+	return data, nil
+}
+
+// resolveCIVersion 获取CI上报数据对应的版本：version_id 指定已有版本，version 按版本号查找，create 为 true 时不存在则创建
+func resolveCIVersion(tx *gorm.DB, projectID uint, versionID, number string, create bool) (*model.Version, bool, error) {
+	var version model.Version
+	if versionID != "" {
+		if err := tx.Where("id = ? AND project_id = ?", versionID, projectID).First(&version).Error; err != nil {
+			return nil, false, fmt.Errorf("版本不存在或不属于当前项目")
+		}
+		return &version, false, nil
+	}
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return nil, false, fmt.Errorf("请指定版本（version_id 或 version）")
+	}
+	err := tx.Where("project_id = ? AND version_number = ?", projectID, number).First(&version).Error
+	if err == nil {
+		return &version, false, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, false, err
+	}
+	if !create {
+		return nil, false, fmt.Errorf("版本 %s 不存在", number)
+	}
+	version = model.Version{VersionNumber: number, Status: "wait", ProjectID: projectID}
+	if err := tx.Create(&version).Error; err != nil {
+		return nil, false, err
+	}
+	return &version, true, nil
+}
+
+// matchReportTestCase 按外部标识或名称匹配项目中的测试单，不存在时创建
+func matchReportTestCase(tx *gorm.DB, projectID, creatorID uint, rc *utils.TestReportCase) (*model.TestCase, bool, error) {
+	var testCase model.TestCase
+	err := tx.Where("project_id = ? AND external_key = ?", projectID, rc.Key).Order("id ASC").First(&testCase).Error
+	if err == nil {
+		return &testCase, false, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, false, err
+	}
+
+	// 按名称匹配尚未关联外部标识的测试单，匹配后记录外部标识
+	err = tx.Where("project_id = ? AND (external_key = '' OR external_key IS NULL) AND name IN ?", projectID, []string{rc.Key, rc.Name}).
+		Order("id ASC").First(&testCase).Error
+	if err == nil {
+		if err := tx.Model(&testCase).Update("external_key", rc.Key).Error; err != nil {
+			return nil, false, err
+		}
+		return &testCase, false, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, false, err
+	}
+
+	testCase = model.TestCase{
+		Name:        truncateRunes(rc.Name, 200),
+		Description: fmt.Sprintf("由测试报告自动创建（%s）", rc.Key),
+		Types:       model.StringArray{"automated"},
+		Status:      "normal",
+		ExternalKey: rc.Key,
+		ProjectID:   projectID,
+		CreatorID:   creatorID,
+	}
+	if err := tx.Create(&testCase).Error; err != nil {
+		return nil, false, err
+	}
+	return &testCase, true, nil
+}
+
+// lastReportBug 测试单最近一次自动化测试失败时创建或重新激活的Bug（已删除的Bug不计）
+func lastReportBug(tx *gorm.DB, testCaseID uint) *model.Bug {
+	var bugID uint
+	tx.Model(&model.TestRunCase{}).
+		Joins("JOIN test_runs ON test_runs.id = test_run_cases.test_run_id").
+		Where("test_run_cases.test_case_id = ? AND test_run_cases.bug_id IS NOT NULL AND test_runs.source = ?", testCaseID, "ci").
+		Order("test_run_cases.id DESC").
+		Limit(1).
+		Pluck("test_run_cases.bug_id", &bugID)
+	if bugID == 0 {
+		return nil
+	}
+	var bug model.Bug
+	if err := tx.First(&bug, bugID).Error; err != nil {
+		return nil
+	}
+	return &bug
+}
+
+// UploadTestReport 上传 JUnit/xUnit/TAP 测试报告（API令牌认证）
+// 测试结果记录为针对指定版本的一次测试执行；新失败的测试创建Bug，已解决的Bug再次失败时重新激活，
+// 仍在失败的测试不重复创建Bug；之前失败的测试通过后自动解决对应的Bug
+func (h *TestRunHandler) UploadTestReport(c *gin.Context) {
+	project, ok := ciProject(h.db, c)
+	if !ok {
+		return
+	}
+
+	data, err := readTestReport(c)
+	if err != nil {
+		utils.Error(c, 400, "读取测试报告失败: "+err.Error())
+		return
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		utils.Error(c, 400, "测试报告不能为空")
+		return
+	}
+	reportCases, format, err := utils.ParseTestReport(strings.ToLower(c.Query("format")), data)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	if len(reportCases) == 0 {
+		utils.Error(c, 400, "测试报告中没有测试结果")
+		return
+	}
+
+	userID := utils.GetUserID(c)
+	now := time.Now()
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	fail := func(code int, message string) {
+		tx.Rollback()
+		utils.Error(c, code, message)
+	}
+
+	version, versionCreated, err := resolveCIVersion(tx, project.ID, c.Query("version_id"), c.Query("version"), true)
+	if err != nil {
+		fail(400, err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name == "" {
+		name = fmt.Sprintf("%s 自动化测试 %s", version.VersionNumber, now.Format("2006-01-02 15:04"))
+	}
+	run := model.TestRun{
+		Name:       truncateRunes(name, 200),
+		Status:     "done",
+		Source:     "ci",
+		ProjectID:  project.ID,
+		VersionID:  version.ID,
+		CreatorID:  userID,
+		StartedAt:  &now,
+		FinishedAt: &now,
+	}
+	if err := tx.Create(&run).Error; err != nil {
+		fail(utils.CodeError, "创建测试执行失败")
+		return
+	}
+
+	summary := &testRunSummary{}
+	createdCases := 0
+	var changes []testReportBugChange
+	for i := range reportCases {
+		rc := &reportCases[i]
+		testCase, created, err := matchReportTestCase(tx, project.ID, userID, rc)
+		if err != nil {
+			fail(utils.CodeError, "匹配测试单失败")
+			return
+		}
+		if created {
+			createdCases++
+		}
+
+		runCase := model.TestRunCase{
+			TestRunID:  run.ID,
+			TestCaseID: testCase.ID,
+			Result:     rc.Result,
+			Comment:    rc.Message,
+			TesterID:   &userID,
+			ExecutedAt: &now,
+			Duration:   rc.Duration,
+		}
+
+		previous := lastReportBug(tx, testCase.ID)
+		switch rc.Result {
+		case testResultFail:
+			change, err := h.openReportBug(tx, project, version, &run, testCase, rc, previous, userID)
+			if err != nil {
+				fail(utils.CodeError, "创建Bug失败")
+				return
+			}
+			runCase.BugID = &change.bugID
+			if change.action != "" {
+				changes = append(changes, *change)
+			}
+		case testResultPass:
+			if previous != nil && previous.Status == "active" {
+				if err := tx.Model(previous).Updates(map[string]interface{}{
+					"status":              "resolved",
+					"solution":            "已解决",
+					"solution_note":       fmt.Sprintf("自动化测试在版本 %s 中通过", version.VersionNumber),
+					"resolved_version_id": version.ID,
+				}).Error; err != nil {
+					fail(utils.CodeError, "解决Bug失败")
+					return
+				}
+				changes = append(changes, testReportBugChange{
+					bugID: previous.ID, action: "resolved", oldStatus: "active", newStatus: "resolved",
+					comment: fmt.Sprintf("测试执行「%s」中测试通过，自动解决", run.Name),
+				})
+			}
+		}
+
+		if err := tx.Create(&runCase).Error; err != nil {
+			fail(utils.CodeError, "保存测试结果失败")
+			return
+		}
+		if rc.Result == testResultPass || rc.Result == testResultFail {
+			if err := tx.Model(testCase).Update("result", rc.Result).Error; err != nil {
+				fail(utils.CodeError, "保存测试结果失败")
+				return
+			}
+		}
+		summary.add(rc.Result)
+	}
+	summary.finish()
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "保存测试结果失败")
+		return
+	}
+
+	// 记录Bug的操作历史并广播看板变更
+	opened, reopened, resolved := make([]uint, 0), make([]uint, 0), make([]uint, 0)
+	for _, change := range changes {
+		actionID, _ := utils.RecordAction(h.db, "bug", change.bugID, change.action, userID, change.comment, gin.H{"test_run_id": run.ID})
+		event := cardEventUpdated
+		switch change.action {
+		case "created":
+			event = cardEventCreated
+			opened = append(opened, change.bugID)
+		case "activated":
+			reopened = append(reopened, change.bugID)
+		case "resolved":
+			resolved = append(resolved, change.bugID)
+		}
+		if change.oldStatus != "" && actionID > 0 {
+			utils.RecordHistory(h.db, actionID, []utils.HistoryChange{{Field: "status", Old: change.oldStatus, New: change.newStatus}})
+		}
+		notifyCardChange(h.db, c, event, "bug", change.bugID, project.ID, change.newStatus)
+	}
+
+	utils.Success(c, gin.H{
+		"test_run_id":     run.ID,
+		"version_id":      version.ID,
+		"version_created": versionCreated,
+		"format":          format,
+		"summary":         summary,
+		"created_cases":   createdCases,
+		"bugs_opened":     opened,
+		"bugs_reopened":   reopened,
+		"bugs_resolved":   resolved,
+	})
+}
+
+// openReportBug 为失败的测试创建或重新激活Bug；Bug仍处于激活状态时不做变更（去重）
+func (h *TestRunHandler) openReportBug(tx *gorm.DB, project *model.Project, version *model.Version, run *model.TestRun,
+	testCase *model.TestCase, rc *utils.TestReportCase, previous *model.Bug, userID uint) (*testReportBugChange, error) {
+	if previous != nil {
+		if previous.Status == "active" {
+			return &testReportBugChange{bugID: previous.ID}, nil
+		}
+		oldStatus := previous.Status
+		if err := tx.Model(previous).Updates(map[string]interface{}{
+			"status": "active", "solution": "", "solution_note": "", "resolved_version_id": nil,
+		}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(previous).Association("Versions").Append(version); err != nil {
+			return nil, err
+		}
+		return &testReportBugChange{
+			bugID: previous.ID, action: "activated", oldStatus: oldStatus, newStatus: "active",
+			comment: fmt.Sprintf("测试执行「%s」中测试再次失败，自动激活", run.Name),
+		}, nil
+	}
+
+	description := fmt.Sprintf("自动化测试 `%s` 在版本 %s 中失败（测试执行：%s）。", rc.Key, version.VersionNumber, run.Name)
+	if rc.Message != "" {
+		description += "\n\n```\n" + rc.Message + "\n```"
+	}
+	bug := model.Bug{
+		Title:       truncateRunes("[自动化测试] "+rc.Name+" 失败", 200),
+		Description: description,
+		Status:      "active",
+		Priority:    "medium",
+		Severity:    "medium",
+		ProjectID:   project.ID,
+		CreatorID:   userID,
+	}
+	if err := tx.Create(&bug).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&bug).Association("Versions").Append(version); err != nil {
+		return nil, err
+	}
+	if err := tx.Model(testCase).Association("Bugs").Append(&bug); err != nil {
+		return nil, err
+	}
+	return &testReportBugChange{
+		bugID: bug.ID, action: "created", newStatus: "active",
+		comment: fmt.Sprintf("由测试执行「%s」中失败的自动化测试创建", run.Name),
+	}, nil
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,814 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 测试执行结果
+const (
+	testResultUntested = "untested"
+	testResultPass     = "pass"
+	testResultFail     = "fail"
+	testResultBlocked  = "blocked"
+	testResultSkip     = "skip"
+)
+
+type TestRunHandler struct {
+	db *gorm.DB
+}
+
+func NewTestRunHandler(db *gorm.DB) *TestRunHandler {
+	return &TestRunHandler{db: db}
+}
+
+// testRunSummary 测试执行结果汇总
+type testRunSummary struct {
+	Total         int     `json:"total"`
+	Untested      int     `json:"untested"`
+	Pass          int     `json:"pass"`
+	Fail          int     `json:"fail"`
+	Blocked       int     `json:"blocked"`
+	Skip          int     `json:"skip"`
+	ExecutionRate float64 `json:"execution_rate"` // 执行率：已执行数 / 总数 * 100
+	PassRate      float64 `json:"pass_rate"`      // 通过率：通过数 / 已执行数 * 100
+}
+
+// add 累计一条执行结果
+func (s *testRunSummary) add(result string) {
+	s.Total++
+	switch result {
+	case testResultPass:
+		s.Pass++
+	case testResultFail:
+		s.Fail++
+	case testResultBlocked:
+		s.Blocked++
+	case testResultSkip:
+		s.Skip++
+	default:
+		s.Untested++
+	}
+}
+
+// finish 计算执行率和通过率
+func (s *testRunSummary) finish() {
+	executed := s.Total - s.Untested
+	if s.Total > 0 {
+		s.ExecutionRate = utils.RoundFlow(float64(executed) / float64(s.Total) * 100)
+	}
+	if executed > 0 {
+		s.PassRate = utils.RoundFlow(float64(s.Pass) / float64(executed) * 100)
+	}
+}
+
+// isValidTestResult 检查执行结果是否合法（步骤结果不允许未执行）
+func isValidTestResult(result string, allowUntested bool) bool {
+	switch result {
+	case testResultPass, testResultFail, testResultBlocked, testResultSkip:
+		return true
+	case testResultUntested:
+		return allowUntested
+	}
+	return false
+}
+
+// projectTestCaseIDs 校验测试单都属于项目，返回去重后的测试单ID
+func projectTestCaseIDs(db *gorm.DB, projectID uint, ids []uint) ([]uint, error) {
+	if len(ids) == 0 {
+		return []uint{}, nil
+	}
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	var count int64
+	db.Model(&model.TestCase{}).Where("id IN ? AND project_id = ?", unique, projectID).Count(&count)
+	if int(count) != len(unique) {
+		return nil, fmt.Errorf("测试单不存在或不属于当前项目")
+	}
+	return unique, nil
+}
+
+// GetTestSuites 获取测试套件列表
+func (h *TestRunHandler) GetTestSuites(c *gin.Context) {
+	query := h.db.Model(&model.TestSuite{})
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("name LIKE ? OR description LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+
+	page := utils.GetPage(c)
+	pageSize := utils.GetPageSize(c)
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	var suites []model.TestSuite
+	if err := query.Preload("Project").Preload("Creator").
+		Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&suites).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"list":      suites,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetTestSuite 获取测试套件详情
+func (h *TestRunHandler) GetTestSuite(c *gin.Context) {
+	var suite model.TestSuite
+	if err := h.db.Preload("Project").Preload("Creator").Preload("TestCases").First(&suite, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "测试套件不存在")
+		return
+	}
+	utils.Success(c, suite)
+}
+
+// CreateTestSuite 创建测试套件
+func (h *TestRunHandler) CreateTestSuite(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		ProjectID   uint   `json:"project_id" binding:"required"`
+		TestCaseIDs []uint `json:"test_case_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	var project model.Project
+	if err := h.db.First(&project, req.ProjectID).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+	caseIDs, err := projectTestCaseIDs(h.db, project.ID, req.TestCaseIDs)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+
+	suite := model.TestSuite{
+		Name:        req.Name,
+		Description: req.Description,
+		ProjectID:   project.ID,
+		CreatorID:   utils.GetUserID(c),
+	}
+	if err := h.db.Create(&suite).Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建失败")
+		return
+	}
+	if err := h.replaceSuiteCases(&suite, caseIDs); err != nil {
+		utils.Error(c, utils.CodeError, "关联测试单失败")
+		return
+	}
+
+	h.db.Preload("Project").Preload("Creator").Preload("TestCases").First(&suite, suite.ID)
+	utils.Success(c, suite)
+}
+
+// UpdateTestSuite 更新测试套件
+func (h *TestRunHandler) UpdateTestSuite(c *gin.Context) {
+	var suite model.TestSuite
+	if err := h.db.First(&suite, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "测试套件不存在")
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		TestCaseIDs []uint  `json:"test_case_ids"` // 传入时整体替换
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			utils.Error(c, 400, "套件名称不能为空")
+			return
+		}
+		suite.Name = *req.Name
+	}
+	if req.Description != nil {
+		suite.Description = *req.Description
+	}
+	var caseIDs []uint
+	if req.TestCaseIDs != nil {
+		var err error
+		if caseIDs, err = projectTestCaseIDs(h.db, suite.ProjectID, req.TestCaseIDs); err != nil {
+			utils.Error(c, 400, err.Error())
+			return
+		}
+	}
+
+	if err := h.db.Omit("TestCases").Save(&suite).Error; err != nil {
+		utils.Error(c, utils.CodeError, "更新失败")
+		return
+	}
+	if req.TestCaseIDs != nil {
+		if err := h.replaceSuiteCases(&suite, caseIDs); err != nil {
+			utils.Error(c, utils.CodeError, "关联测试单失败")
+			return
+		}
+	}
+
+	h.db.Preload("Project").Preload("Creator").Preload("TestCases").First(&suite, suite.ID)
+	utils.Success(c, suite)
+}
+
+// DeleteTestSuite 删除测试套件（已创建的测试执行不受影响）
+func (h *TestRunHandler) DeleteTestSuite(c *gin.Context) {
+	if err := h.db.Delete(&model.TestSuite{}, c.Param("id")).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// replaceSuiteCases 替换测试套件包含的测试单
+func (h *TestRunHandler) replaceSuiteCases(suite *model.TestSuite, caseIDs []uint) error {
+	cases := make([]model.TestCase, 0, len(caseIDs))
+	for _, id := range caseIDs {
+		cases = append(cases, model.TestCase{ID: id})
+	}
+	return h.db.Model(suite).Association("TestCases").Replace(cases)
+}
+
+// GetTestRuns 获取测试执行列表，summaries 为各测试执行的结果汇总（key为测试执行ID）
+func (h *TestRunHandler) GetTestRuns(c *gin.Context) {
+	query := h.db.Model(&model.TestRun{})
+	if projectID := c.Query("project_id"); projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+	if versionID := c.Query("version_id"); versionID != "" {
+		query = query.Where("version_id = ?", versionID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	page := utils.GetPage(c)
+	pageSize := utils.GetPageSize(c)
+
+	var total int64
+	query.Session(&gorm.Session{}).Count(&total)
+
+	var runs []model.TestRun
+	if err := query.Preload("Project").Preload("Version").Preload("TestSuite").Preload("Creator").
+		Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC").Find(&runs).Error; err != nil {
+		utils.Error(c, utils.CodeError, "查询失败")
+		return
+	}
+
+	summaries := make(map[uint]*testRunSummary, len(runs))
+	runIDs := make([]uint, 0, len(runs))
+	for _, run := range runs {
+		summaries[run.ID] = &testRunSummary{}
+		runIDs = append(runIDs, run.ID)
+	}
+	if len(runIDs) > 0 {
+		var rows []struct {
+			TestRunID uint
+			Result    string
+		}
+		h.db.Model(&model.TestRunCase{}).Select("test_run_id, result").Where("test_run_id IN ?", runIDs).Scan(&rows)
+		for _, row := range rows {
+			summaries[row.TestRunID].add(row.Result)
+		}
+		for _, summary := range summaries {
+			summary.finish()
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"list":      runs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"summaries": summaries,
+	})
+}
+
+// loadTestRun 加载测试执行及其测试单、步骤和步骤执行结果
+func (h *TestRunHandler) loadTestRun(id interface{}) (*model.TestRun, error) {
+	var run model.TestRun
+	err := h.db.Preload("Project").Preload("Version").Preload("TestSuite").Preload("Creator").
+		Preload("Cases", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Cases.TestCase").
+		Preload("Cases.TestCase.Steps", orderTestCaseSteps).
+		Preload("Cases.Tester").
+		Preload("Cases.StepResults").
+		First(&run, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetTestRun 获取测试执行详情
+func (h *TestRunHandler) GetTestRun(c *gin.Context) {
+	run, err := h.loadTestRun(c.Param("id"))
+	if err != nil {
+		utils.Error(c, 404, "测试执行不存在")
+		return
+	}
+
+	summary := &testRunSummary{}
+	for _, rc := range run.Cases {
+		summary.add(rc.Result)
+	}
+	summary.finish()
+
+	utils.Success(c, gin.H{
+		"test_run": run,
+		"summary":  summary,
+	})
+}
+
+// CreateTestRun 创建测试执行：针对某个版本执行测试套件和/或指定的测试单
+func (h *TestRunHandler) CreateTestRun(c *gin.Context) {
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		ProjectID   uint   `json:"project_id" binding:"required"`
+		VersionID   uint   `json:"version_id" binding:"required"`
+		TestSuiteID *uint  `json:"test_suite_id"`
+		TestCaseIDs []uint `json:"test_case_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+
+	var project model.Project
+	if err := h.db.First(&project, req.ProjectID).Error; err != nil {
+		utils.Error(c, 404, "项目不存在")
+		return
+	}
+	var version model.Version
+	if err := h.db.Where("id = ? AND project_id = ?", req.VersionID, project.ID).First(&version).Error; err != nil {
+		utils.Error(c, 400, "版本不存在或不属于当前项目")
+		return
+	}
+
+	caseIDs := append([]uint{}, req.TestCaseIDs...)
+	if req.TestSuiteID != nil {
+		var suite model.TestSuite
+		if err := h.db.Preload("TestCases").Where("id = ? AND project_id = ?", *req.TestSuiteID, project.ID).First(&suite).Error; err != nil {
+			utils.Error(c, 400, "测试套件不存在或不属于当前项目")
+			return
+		}
+		for _, tc := range suite.TestCases {
+			caseIDs = append(caseIDs, tc.ID)
+		}
+	}
+	caseIDs, err := projectTestCaseIDs(h.db, project.ID, caseIDs)
+	if err != nil {
+		utils.Error(c, 400, err.Error())
+		return
+	}
+	if len(caseIDs) == 0 {
+		utils.Error(c, 400, "测试执行至少需要包含一个测试单")
+		return
+	}
+
+	run := model.TestRun{
+		Name:        req.Name,
+		Status:      "wait",
+		ProjectID:   project.ID,
+		VersionID:   version.ID,
+		TestSuiteID: req.TestSuiteID,
+		CreatorID:   utils.GetUserID(c),
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := tx.Create(&run).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "创建失败")
+		return
+	}
+	for _, caseID := range caseIDs {
+		if err := tx.Create(&model.TestRunCase{TestRunID: run.ID, TestCaseID: caseID, Result: testResultUntested}).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "创建失败")
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建失败")
+		return
+	}
+
+	loaded, _ := h.loadTestRun(run.ID)
+	utils.Success(c, loaded)
+}
+
+// DeleteTestRun 删除测试执行
+func (h *TestRunHandler) DeleteTestRun(c *gin.Context) {
+	if err := h.db.Delete(&model.TestRun{}, c.Param("id")).Error; err != nil {
+		utils.Error(c, utils.CodeError, "删除失败")
+		return
+	}
+	utils.Success(c, gin.H{"message": "删除成功"})
+}
+
+// loadRunCase 加载测试执行中的测试单（校验属于该测试执行）
+func (h *TestRunHandler) loadRunCase(c *gin.Context) (*model.TestRun, *model.TestRunCase, bool) {
+	var run model.TestRun
+	if err := h.db.First(&run, c.Param("id")).Error; err != nil {
+		utils.Error(c, 404, "测试执行不存在")
+		return nil, nil, false
+	}
+	var runCase model.TestRunCase
+	if err := h.db.Preload("TestCase").Preload("TestCase.Steps", orderTestCaseSteps).
+		Where("id = ? AND test_run_id = ?", c.Param("case_id"), run.ID).First(&runCase).Error; err != nil {
+		utils.Error(c, 404, "测试执行中不存在该测试单")
+		return nil, nil, false
+	}
+	return &run, &runCase, true
+}
+
+// RecordTestResult 记录测试单的执行结果（可包含各步骤的执行结果）
+// 未指定测试单结果时根据步骤结果推断：有失败为失败，有阻塞为阻塞，全部步骤通过为通过
+func (h *TestRunHandler) RecordTestResult(c *gin.Context) {
+	run, runCase, ok := h.loadRunCase(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Result  string `json:"result"`
+		Comment string `json:"comment"`
+		Steps   []struct {
+			StepID uint   `json:"step_id" binding:"required"`
+			Result string `json:"result" binding:"required"`
+			Actual string `json:"actual"`
+		} `json:"steps"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if req.Result != "" && !isValidTestResult(req.Result, true) {
+		utils.Error(c, 400, "无效的执行结果，有效值：untested, pass, fail, blocked, skip")
+		return
+	}
+
+	steps := make(map[uint]bool, len(runCase.TestCase.Steps))
+	for _, step := range runCase.TestCase.Steps {
+		steps[step.ID] = true
+	}
+	for _, s := range req.Steps {
+		if !steps[s.StepID] {
+			utils.Error(c, 400, fmt.Sprintf("步骤 %d 不属于该测试单", s.StepID))
+			return
+		}
+		if !isValidTestResult(s.Result, false) {
+			utils.Error(c, 400, "无效的步骤执行结果，有效值：pass, fail, blocked, skip")
+			return
+		}
+	}
+	if req.Result == "" && len(req.Steps) == 0 {
+		utils.Error(c, 400, "请填写执行结果")
+		return
+	}
+
+	userID := utils.GetUserID(c)
+	now := time.Now()
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	for _, s := range req.Steps {
+		var stepResult model.TestStepResult
+		if err := tx.Where(model.TestStepResult{TestRunCaseID: runCase.ID, StepID: s.StepID}).
+			Assign(model.TestStepResult{Result: s.Result, Actual: s.Actual, TesterID: userID}).
+			FirstOrCreate(&stepResult).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "保存步骤执行结果失败")
+			return
+		}
+	}
+
+	result := req.Result
+	if result == "" {
+		var stepResults []model.TestStepResult
+		tx.Where("test_run_case_id = ?", runCase.ID).Find(&stepResults)
+		result = summarizeStepResults(stepResults, len(runCase.TestCase.Steps))
+	}
+
+	updates := map[string]interface{}{
+		"result":    result,
+		"comment":   req.Comment,
+		"tester_id": userID,
+	}
+	if result != testResultUntested {
+		updates["executed_at"] = now
+	} else {
+		updates["executed_at"] = nil
+	}
+	if err := tx.Model(runCase).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "保存执行结果失败")
+		return
+	}
+
+	// 测试单的结果记录最近一次执行结果
+	if result == testResultPass || result == testResultFail || result == testResultBlocked {
+		if err := tx.Model(&model.TestCase{}).Where("id = ?", runCase.TestCaseID).Update("result", result).Error; err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "保存执行结果失败")
+			return
+		}
+	}
+
+	// 更新测试执行的状态：开始记录结果即为执行中，全部测试单执行完成即为已完成
+	var untested int64
+	tx.Model(&model.TestRunCase{}).Where("test_run_id = ? AND result = ?", run.ID, testResultUntested).Count(&untested)
+	runUpdates := map[string]interface{}{"status": "doing", "finished_at": nil}
+	if run.StartedAt == nil {
+		runUpdates["started_at"] = now
+	}
+	if untested == 0 {
+		runUpdates["status"] = "done"
+		runUpdates["finished_at"] = now
+	}
+	if err := tx.Model(run).Updates(runUpdates).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "保存执行结果失败")
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "保存执行结果失败")
+		return
+	}
+
+	var updated model.TestRunCase
+	h.db.Preload("TestCase").Preload("TestCase.Steps", orderTestCaseSteps).Preload("Tester").Preload("StepResults").First(&updated, runCase.ID)
+	utils.Success(c, updated)
+}
+
+// summarizeStepResults 根据步骤执行结果推断测试单的执行结果
+func summarizeStepResults(results []model.TestStepResult, stepCount int) string {
+	passed := 0
+	blocked := false
+	for _, r := range results {
+		switch r.Result {
+		case testResultFail:
+			return testResultFail
+		case testResultBlocked:
+			blocked = true
+		case testResultPass, testResultSkip:
+			passed++
+		}
+	}
+	if blocked {
+		return testResultBlocked
+	}
+	if stepCount > 0 && passed >= stepCount {
+		return testResultPass
+	}
+	return testResultUntested
+}
+
+// CreateBugFromStep 根据失败的测试步骤创建Bug，自动关联测试单和被测版本
+func (h *TestRunHandler) CreateBugFromStep(c *gin.Context) {
+	run, runCase, ok := h.loadRunCase(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		StepID      uint   `json:"step_id" binding:"required"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Priority    string `json:"priority"`
+		Severity    string `json:"severity"`
+		AssigneeIDs []uint `json:"assignee_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	if req.Priority == "" {
+		req.Priority = "medium"
+	}
+	if req.Severity == "" {
+		req.Severity = "medium"
+	}
+	if !map[string]bool{"low": true, "medium": true, "high": true, "urgent": true}[req.Priority] {
+		utils.Error(c, 400, "优先级值无效")
+		return
+	}
+	if !map[string]bool{"low": true, "medium": true, "high": true, "critical": true}[req.Severity] {
+		utils.Error(c, 400, "严重程度值无效")
+		return
+	}
+
+	var step *model.TestCaseStep
+	for i := range runCase.TestCase.Steps {
+		if runCase.TestCase.Steps[i].ID == req.StepID {
+			step = &runCase.TestCase.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		utils.Error(c, 400, "步骤不属于该测试单")
+		return
+	}
+	var stepResult model.TestStepResult
+	if err := h.db.Where("test_run_case_id = ? AND step_id = ?", runCase.ID, step.ID).First(&stepResult).Error; err != nil || stepResult.Result != testResultFail {
+		utils.Error(c, 400, "只能根据执行失败的步骤创建Bug")
+		return
+	}
+	if stepResult.BugID != nil {
+		utils.Error(c, 400, "该步骤已创建Bug")
+		return
+	}
+
+	var assignees []model.User
+	if len(req.AssigneeIDs) > 0 {
+		if err := h.db.Where("id IN ?", req.AssigneeIDs).Find(&assignees).Error; err != nil || len(assignees) != len(req.AssigneeIDs) {
+			utils.Error(c, 400, "分配人不存在")
+			return
+		}
+	}
+
+	var version model.Version
+	if err := h.db.First(&version, run.VersionID).Error; err != nil {
+		utils.Error(c, 400, "被测版本不存在")
+		return
+	}
+
+	if req.Title == "" {
+		req.Title = fmt.Sprintf("[%s] 步骤%d执行失败", runCase.TestCase.Name, step.Sort)
+	}
+	if req.Description == "" {
+		req.Description = fmt.Sprintf("测试执行：%s（版本 %s）\n\n**操作步骤**\n\n%s\n\n**预期结果**\n\n%s\n\n**实际结果**\n\n%s",
+			run.Name, version.VersionNumber, step.Action, step.Expected, stepResult.Actual)
+	}
+
+	userID := utils.GetUserID(c)
+	bug := model.Bug{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      "active",
+		Priority:    req.Priority,
+		Severity:    req.Severity,
+		ProjectID:   run.ProjectID,
+		CreatorID:   userID,
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+	if err := tx.Create(&bug).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "创建Bug失败")
+		return
+	}
+	if len(assignees) > 0 {
+		if err := tx.Model(&bug).Association("Assignees").Replace(assignees); err != nil {
+			tx.Rollback()
+			utils.Error(c, utils.CodeError, "分配失败")
+			return
+		}
+	}
+	if err := tx.Model(&bug).Association("Versions").Append(&version); err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "关联版本失败")
+		return
+	}
+	if err := tx.Model(&runCase.TestCase).Association("Bugs").Append(&bug); err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "关联测试单失败")
+		return
+	}
+	if err := tx.Model(&stepResult).Update("bug_id", bug.ID).Error; err != nil {
+		tx.Rollback()
+		utils.Error(c, utils.CodeError, "创建Bug失败")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.Error(c, utils.CodeError, "创建Bug失败")
+		return
+	}
+
+	utils.RecordAction(h.db, "bug", bug.ID, "created", userID,
+		fmt.Sprintf("由测试执行「%s」中测试单「%s」的失败步骤创建", run.Name, runCase.TestCase.Name),
+		gin.H{"test_run_id": run.ID, "test_case_id": runCase.TestCaseID, "step_id": step.ID})
+	notifyCardChange(h.db, c, cardEventCreated, "bug", bug.ID, bug.ProjectID, bug.Status)
+
+	h.db.Preload("Project").Preload("Creator").Preload("Assignees").Preload("Versions").First(&bug, bug.ID)
+	utils.Success(c, bug)
+}
+
+// versionTestStats 按版本统计测试执行结果：同一版本中多次执行的测试单以最近一次执行结果为准
+func versionTestStats(db *gorm.DB, projectID string) []gin.H {
+	query := db.Table("test_run_cases")
+	if projectID != "" {
+		query = query.Where("test_runs.project_id = ?", projectID)
+	}
+	latest, versionIDs := latestVersionCaseResults(query)
+	if len(versionIDs) == 0 {
+		return []gin.H{}
+	}
+
+	var versions []model.Version
+	db.Where("id IN ?", versionIDs).Order("id ASC").Find(&versions)
+
+	stats := make([]gin.H, 0, len(versions))
+	for _, version := range versions {
+		summary := &testRunSummary{}
+		for _, result := range latest[version.ID] {
+			summary.add(result)
+		}
+		summary.finish()
+
+		var runs int64
+		db.Model(&model.TestRun{}).Where("version_id = ?", version.ID).Count(&runs)
+		stats = append(stats, gin.H{
+			"version_id":     version.ID,
+			"version_number": version.VersionNumber,
+			"project_id":     version.ProjectID,
+			"runs":           runs,
+			"total":          summary.Total,
+			"untested":       summary.Untested,
+			"passed":         summary.Pass,
+			"failed":         summary.Fail,
+			"blocked":        summary.Blocked,
+			"skipped":        summary.Skip,
+			"execution_rate": summary.ExecutionRate,
+			"pass_rate":      summary.PassRate,
+		})
+	}
+	return stats
+}
+
+// latestVersionCaseResults 读取每个版本中各测试单最近一次的执行结果（版本ID -> 测试单ID -> 结果），
+// query 为基于 test_run_cases 表、可引用 test_runs 表字段的查询条件
+func latestVersionCaseResults(query *gorm.DB) (map[uint]map[uint]string, []uint) {
+	var rows []struct {
+		VersionID  uint
+		TestCaseID uint
+		Result     string
+		ExecutedAt *time.Time
+	}
+	query.Select("test_runs.version_id, test_run_cases.test_case_id, test_run_cases.result, test_run_cases.executed_at").
+		Joins("JOIN test_runs ON test_runs.id = test_run_cases.test_run_id AND test_runs.deleted_at IS NULL").
+		Order("test_run_cases.id ASC").
+		Scan(&rows)
+
+	latest := make(map[uint]map[uint]string)
+	executedAt := make(map[uint]map[uint]*time.Time)
+	versionIDs := make([]uint, 0)
+	for _, row := range rows {
+		if latest[row.VersionID] == nil {
+			latest[row.VersionID] = make(map[uint]string)
+			executedAt[row.VersionID] = make(map[uint]*time.Time)
+			versionIDs = append(versionIDs, row.VersionID)
+		}
+		current, exists := executedAt[row.VersionID][row.TestCaseID]
+		if !exists || (row.ExecutedAt != nil && (current == nil || !row.ExecutedAt.Before(*current))) {
+			latest[row.VersionID][row.TestCaseID] = row.Result
+			executedAt[row.VersionID][row.TestCaseID] = row.ExecutedAt
+		}
+	}
+	return latest, versionIDs
+}
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,7 +23,7 @@ func NewVersionHandler(db *gorm.DB) *VersionHandler {
 // GetVersions 获取版本列表
 func (h *VersionHandler) GetVersions(c *gin.Context) {
 	var versions []model.Version
-	query := h.db.Preload("Project").Preload("Requirements").Preload("Bugs")
+	query := h.db.Preload("Project").Preload("Requirements", utils.RestrictedPreload(c, "requirement")).Preload("Bugs", utils.RestrictedPreload(c, "bug"))
 
 	// 搜索
 	if keyword := c.Query("keyword"); keyword != "" {
@@ -82,7 +84,7 @@ func (h *VersionHandler) GetVersion(c *gin.Context) {
 	id := c.Param("id")
 	var version model.Version
 	if err := h.db.Preload("Project").
-		Preload("Requirements").Preload("Bugs").
+		Preload("Requirements", utils.RestrictedPreload(c, "requirement")).Preload("Bugs", utils.RestrictedPreload(c, "bug")).
 		Preload("Attachments").Preload("Attachments.Creator").
 		First(&version, id).Error; err != nil {
 		utils.Error(c, 404, "版本不存在")
@@ -118,6 +120,11 @@ func (h *VersionHandler) CreateVersion(c *gin.Context) {
 		utils.Error(c, 400, "无效的版本状态，有效值：wait, normal, fail, terminate")
 		return
 	}
+	// 发布需要经过发布门禁，不能创建已发布的版本
+	if req.Status == "normal" {
+		utils.Error(c, 400, "不能直接创建已发布的版本，请创建后通过发布操作发布")
+		return
+	}
 
 	// 验证项目是否存在
 	var project model.Project
@@ -206,7 +213,7 @@ func (h *VersionHandler) CreateVersion(c *gin.Context) {
 
 	// 重新加载关联数据（包含附件）
 	h.db.Session(&gorm.Session{}).Preload("Project").
-		Preload("Requirements").Preload("Bugs").
+		Preload("Requirements", utils.RestrictedPreload(c, "requirement")).Preload("Bugs", utils.RestrictedPreload(c, "bug")).
 		Preload("Attachments").Preload("Attachments.Creator").
 		First(&version, version.ID)
 
@@ -230,6 +237,7 @@ func (h *VersionHandler) UpdateVersion(c *gin.Context) {
 		RequirementIDs []uint  `json:"requirement_ids"` // 关联的需求ID列表
 		BugIDs         []uint  `json:"bug_ids"`         // 关联的Bug ID列表
 		AttachmentIDs  *[]uint  `json:"attachment_ids"` // 附件ID列表
+		releaseOverride
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -248,6 +256,18 @@ func (h *VersionHandler) UpdateVersion(c *gin.Context) {
 		utils.Error(c, 400, "无效的版本状态，有效值：wait, normal, fail, terminate")
 		return
 	}
+	}
+	// 发布（状态改为 normal）与 UpdateVersionStatus 一样需要通过发布门禁
+	var gateResult *releaseGateResult
+	oldStatus := version.Status
+	if req.Status != nil && *req.Status == "normal" && version.Status != "normal" {
+		result, ok := h.enforceReleaseGate(c, &version, req.releaseOverride)
+		if !ok {
+			return
+		}
+		gateResult = result
+	}
+	if req.Status != nil {
 		version.Status = *req.Status
 		// 如果状态为 normal，自动设置发布日期
 		if *req.Status == "normal" && version.ReleaseDate == nil {
@@ -270,6 +290,9 @@ func (h *VersionHandler) UpdateVersion(c *gin.Context) {
 		utils.Error(c, utils.CodeError, "更新失败")
 		return
 	}
+	if gateResult != nil {
+		h.recordRelease(c, &version, oldStatus, gateResult, req.releaseOverride)
+	}
 
 	// 更新关联需求和Bug（如果提供了这些字段）
 	// 注意：空数组 [] 不是 nil，所以会执行更新（清空关联）
@@ -281,6 +304,10 @@ func (h *VersionHandler) UpdateVersion(c *gin.Context) {
 				return
 			}
 		}
+		// 保留当前用户不可见的受限需求关联
+		var hiddenRequirements []model.Requirement
+		utils.FilterHiddenRestrictedItems(c, "requirement", h.db.Model(&version)).Association("Requirements").Find(&hiddenRequirements)
+		requirements = append(requirements, hiddenRequirements...)
 		if err := h.db.Model(&version).Association("Requirements").Replace(requirements); err != nil {
 			utils.Error(c, utils.CodeError, "更新关联需求失败")
 			return
@@ -294,6 +321,10 @@ func (h *VersionHandler) UpdateVersion(c *gin.Context) {
 				return
 			}
 		}
+		// 保留当前用户不可见的保密Bug关联
+		var hiddenBugs []model.Bug
+		utils.FilterHiddenRestrictedItems(c, "bug", h.db.Model(&version)).Association("Bugs").Find(&hiddenBugs)
+		bugs = append(bugs, hiddenBugs...)
 		if err := h.db.Model(&version).Association("Bugs").Replace(bugs); err != nil {
 			utils.Error(c, utils.CodeError, "更新关联Bug失败")
 			return
@@ -345,7 +376,7 @@ func (h *VersionHandler) UpdateVersion(c *gin.Context) {
 
 	// 重新加载关联数据（包含附件）
 	h.db.Session(&gorm.Session{}).Preload("Project").
-		Preload("Requirements").Preload("Bugs").
+		Preload("Requirements", utils.RestrictedPreload(c, "requirement")).Preload("Bugs", utils.RestrictedPreload(c, "bug")).
 		Preload("Attachments").Preload("Attachments.Creator").
 		First(&version, version.ID)
 
@@ -374,6 +405,7 @@ func (h *VersionHandler) UpdateVersionStatus(c *gin.Context) {
 
 	var req struct {
 		Status string `json:"status" binding:"required"`
+		releaseOverride
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.Error(c, 400, "参数错误: "+err.Error())
@@ -385,6 +417,17 @@ func (h *VersionHandler) UpdateVersionStatus(c *gin.Context) {
 		return
 	}
 
+	// 发布（状态改为 normal）前检查发布门禁
+	var gateResult *releaseGateResult
+	if req.Status == "normal" && version.Status != "normal" {
+		result, ok := h.enforceReleaseGate(c, &version, req.releaseOverride)
+		if !ok {
+			return
+		}
+		gateResult = result
+	}
+
+	oldStatus := version.Status
 	version.Status = req.Status
 	// 如果状态为 normal，自动设置发布日期
 	if req.Status == "normal" && version.ReleaseDate == nil {
@@ -396,15 +439,18 @@ func (h *VersionHandler) UpdateVersionStatus(c *gin.Context) {
 		utils.Error(c, utils.CodeError, "更新状态失败")
 		return
 	}
+	if gateResult != nil {
+		h.recordRelease(c, &version, oldStatus, gateResult, req.releaseOverride)
+	}
 
 	// 重新加载关联数据
 	h.db.Preload("Project").
-		Preload("Requirements").Preload("Bugs").First(&version, version.ID)
+		Preload("Requirements", utils.RestrictedPreload(c, "requirement")).Preload("Bugs", utils.RestrictedPreload(c, "bug")).First(&version, version.ID)
 
 	utils.Success(c, version)
 }
 
-// ReleaseVersion 发布版本
+// ReleaseVersion 发布版本：项目启用发布门禁时必须通过门禁检查，管理员可填写原因跳过
 func (h *VersionHandler) ReleaseVersion(c *gin.Context) {
 	id := c.Param("id")
 	var version model.Version
@@ -413,6 +459,17 @@ func (h *VersionHandler) ReleaseVersion(c *gin.Context) {
 		return
 	}
 
+	var req releaseOverride
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.Error(c, 400, "参数错误: "+err.Error())
+		return
+	}
+	gateResult, ok := h.enforceReleaseGate(c, &version, req)
+	if !ok {
+		return
+	}
+
+	oldStatus := version.Status
 	version.Status = "normal"
 	if version.ReleaseDate == nil {
 		now := time.Now()
@@ -423,10 +480,11 @@ func (h *VersionHandler) ReleaseVersion(c *gin.Context) {
 		utils.Error(c, utils.CodeError, "发布失败")
 		return
 	}
+	h.recordRelease(c, &version, oldStatus, gateResult, req)
 
 	// 重新加载关联数据
 	h.db.Preload("Project").
-		Preload("Requirements").Preload("Bugs").First(&version, version.ID)
+		Preload("Requirements", utils.RestrictedPreload(c, "requirement")).Preload("Bugs", utils.RestrictedPreload(c, "bug")).First(&version, version.ID)
 
 	utils.Success(c, version)
 }
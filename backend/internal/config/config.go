@@ -14,6 +14,8 @@ type Config struct {
 	JWT           JWTConfig      `mapstructure:"jwt"`
 	WeChat        WeChatConfig   `mapstructure:"wechat"`
 	Upload        UploadConfig   `mapstructure:"upload"`
+	Storage       StorageConfig  `mapstructure:"storage"` // 附件和备份的存储后端
+	Mail          MailConfig     `mapstructure:"mail"`
 }
 
 type ServerConfig struct {
@@ -60,6 +62,36 @@ type UploadConfig struct {
 	AllowedTypes []string `mapstructure:"allowed_types"` // 允许的文件类型（MIME类型），空数组表示允许所有类型
 }
 
+// StorageConfig 附件和备份的存储后端：local（本地目录，默认）或 s3（S3 兼容的对象存储）
+type StorageConfig struct {
+	Type string   `mapstructure:"type"` // local, s3
+	S3   S3Config `mapstructure:"s3"`
+	// PresignDownload S3 存储时下载重定向到带签名的临时地址，关闭时由服务器转发文件内容
+	PresignDownload bool `mapstructure:"presign_download"`
+	PresignExpire   int  `mapstructure:"presign_expire"` // 临时地址有效期（秒）
+}
+
+// S3Config S3 兼容对象存储配置（AWS S3、MinIO 等）
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"` // 如 http://127.0.0.1:9000，为空时使用 AWS S3
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Prefix    string `mapstructure:"prefix"`     // 对象键前缀
+	PathStyle bool   `mapstructure:"path_style"` // 使用路径风格访问（MinIO 需要开启）
+}
+
+// MailConfig 邮件发送配置（SMTP），未启用时只发送站内通知
+type MailConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"` // 发件人地址，为空时使用 username
+}
+
 var AppConfig *Config
 
 func LoadConfig(configPath string) error {
@@ -83,6 +115,11 @@ func LoadConfig(configPath string) error {
 	viper.BindEnv("server.port", "SERVER_PORT")
 	viper.BindEnv("jwt.secret", "JWT_SECRET")
 	viper.BindEnv("upload.storage_path", "UPLOAD_STORAGE_PATH")
+	viper.BindEnv("storage.type", "STORAGE_TYPE")
+	viper.BindEnv("storage.s3.endpoint", "STORAGE_S3_ENDPOINT")
+	viper.BindEnv("storage.s3.bucket", "STORAGE_S3_BUCKET")
+	viper.BindEnv("storage.s3.access_key", "STORAGE_S3_ACCESS_KEY")
+	viper.BindEnv("storage.s3.secret_key", "STORAGE_S3_SECRET_KEY")
 
 	if err := viper.ReadInConfig(); err != nil {
 		// 配置加载时日志系统可能还未初始化，使用标准库log
@@ -119,4 +156,15 @@ func setDefaults() {
 	viper.SetDefault("upload.storage_path", "uploads")      // 默认存储路径
 	viper.SetDefault("upload.max_file_size", 100*1024*1024) // 默认 100MB (104857600 字节)
 	viper.SetDefault("upload.allowed_types", []string{})    // 空数组表示允许所有类型
+
+	// 存储配置
+	viper.SetDefault("storage.type", "local")
+	viper.SetDefault("storage.presign_download", true)
+	viper.SetDefault("storage.presign_expire", 300)
+	viper.SetDefault("storage.s3.region", "us-east-1")
+	viper.SetDefault("storage.s3.path_style", true)
+
+	// 邮件配置
+	viper.SetDefault("mail.enabled", false)
+	viper.SetDefault("mail.port", 25)
 }
@@ -169,4 +169,70 @@ func AuthOptional(db *gorm.DB) gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+// TokenFromQuery 从查询参数 token 读取认证Token（浏览器建立WebSocket连接时无法设置请求头）
+// 请求头中没有 Authorization 时将其设置为 Bearer Token，之后由 Auth 中间件统一校验
+func TokenFromQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
+
+// APITokenAuth API令牌认证中间件（供CI等非交互式客户端使用）
+// 令牌通过 X-API-Token 请求头或 Authorization: Token <令牌> 传递，需要具有指定的权限范围；
+// 认证后以令牌创建人的身份（角色和权限）执行，令牌所属项目保存在 api_token_project_id 中
+func APITokenAuth(db *gorm.DB, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-API-Token")
+		if token == "" {
+			parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+			if len(parts) == 2 && (parts[0] == "Token" || parts[0] == "Bearer") {
+				token = parts[1]
+			}
+		}
+
+		apiToken, err := utils.AuthenticateAPIToken(db, token)
+		if err != nil {
+			if err == utils.ErrExpiredAPIToken {
+				utils.Error(c, 401, "API令牌已过期")
+			} else {
+				utils.Error(c, 401, "无效的API令牌")
+			}
+			c.Abort()
+			return
+		}
+		if !utils.HasAPITokenScope(apiToken, scope) {
+			utils.Error(c, 403, "API令牌没有该操作的权限")
+			c.Abort()
+			return
+		}
+
+		var user model.User
+		if err := db.Preload("Roles").First(&user, apiToken.CreatorID).Error; err != nil || user.Status != 1 {
+			utils.Error(c, 401, "API令牌的创建人不存在或已禁用")
+			c.Abort()
+			return
+		}
+		roles := make([]string, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			roles = append(roles, role.Code)
+		}
+		permCodes, err := permission.GetRolePermissions(db, roles)
+		if err != nil {
+			permCodes = []string{}
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("roles", roles)
+		c.Set("permissions", permCodes)
+		c.Set("api_token_id", apiToken.ID)
+		c.Set("api_token_project_id", apiToken.ProjectID)
+		c.Next()
+	}
+}
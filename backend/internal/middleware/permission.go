@@ -80,6 +80,33 @@ func RequirePermission(db *gorm.DB, permCode string) gin.HandlerFunc {
 	}
 }
 
+// RequireProjectPermission 要求目标对象所属项目内的权限
+// 通过 resolver 解析请求对应的项目：项目成员的角色有对应的项目角色时以项目角色为准，否则使用全局角色权限；
+// 无法解析项目时（如对象不存在）按全局权限检查，由处理函数返回具体错误
+func RequireProjectPermission(db *gorm.DB, permCode string, resolver utils.ProjectResolver) gin.HandlerFunc {
+	globalCheck := RequirePermission(db, permCode)
+	return func(c *gin.Context) {
+		if utils.IsAdmin(c) {
+			c.Next()
+			return
+		}
+
+		projectID, ok := resolver(db, c)
+		if !ok {
+			globalCheck(c)
+			return
+		}
+
+		if !utils.HasProjectPermission(db, c, projectID, permCode) {
+			utils.Error(c, 403, "没有权限")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // RequireRole 要求特定角色
 func RequireRole(roleCode string) gin.HandlerFunc {
 	return func(c *gin.Context) {
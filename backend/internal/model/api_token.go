@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API令牌权限范围
+const (
+	APITokenScopeTestResults = "test-results:write" // 上传测试报告
+	APITokenScopeDeployments = "deployments:write"  // 登记构建和部署记录
+)
+
+// APIToken 项目API令牌表：供CI等非交互式客户端调用开放接口
+// 令牌只在创建时返回一次，数据库中只保存哈希值；调用时以创建人的身份记录操作
+type APIToken struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name      string      `gorm:"size:100;not null" json:"name"`         // 令牌名称（用途说明）
+	TokenHash string      `gorm:"size:64;not null;uniqueIndex" json:"-"` // 令牌的SHA-256哈希
+	Prefix    string      `gorm:"size:16" json:"prefix"`                 // 令牌前缀（用于识别令牌）
+	Scopes    StringArray `gorm:"type:text" json:"scopes"`               // 权限范围
+
+	ProjectID uint    `gorm:"index;not null" json:"project_id"`
+	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+
+	CreatorID uint `gorm:"index" json:"creator_id"`
+	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+
+	ExpiresAt  *time.Time `json:"expires_at"`   // 过期时间（为空表示不过期）
+	LastUsedAt *time.Time `json:"last_used_at"` // 最近使用时间
+}
@@ -13,20 +13,59 @@ type Attachment struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	FileName string `gorm:"size:255;not null" json:"file_name"` // 原始文件名
-	FilePath string `gorm:"size:500;not null" json:"file_path"` // 文件存储路径（相对路径）
-	FileSize int64  `gorm:"not null" json:"file_size"`          // 文件大小（字节）
-	MimeType string `gorm:"size:100" json:"mime_type"`          // MIME类型
+	FileName    string `gorm:"size:255;not null" json:"file_name"` // 原始文件名
+	FilePath    string `gorm:"size:500;not null" json:"file_path"` // 文件存储路径（相对路径）
+	FileSize    int64  `gorm:"not null" json:"file_size"`          // 文件大小（字节）
+	MimeType    string `gorm:"size:100" json:"mime_type"`          // MIME类型
+	ContentHash string `gorm:"size:64;index" json:"content_hash"`  // 文件内容 SHA-256（相同内容共用一个文件），旧附件为空
+	Version     int    `gorm:"default:1" json:"version"`           // 当前版本号（上传新版本时递增）
 
 	CreatorID uint `gorm:"index;not null" json:"creator_id"` // 创建人ID
 	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
 
-	// 多对多关联：项目、需求、任务、Bug、版本
-	Projects     []Project     `gorm:"many2many:project_attachments;" json:"projects,omitempty"`
-	Requirements []Requirement `gorm:"many2many:requirement_attachments;" json:"requirements,omitempty"`
-	Tasks        []Task        `gorm:"many2many:task_attachments;" json:"tasks,omitempty"`
-	Bugs         []Bug         `gorm:"many2many:bug_attachments;" json:"bugs,omitempty"`
-	Versions     []Version     `gorm:"many2many:version_attachments;" json:"versions,omitempty"`
+	// 多对多关联：项目、需求、任务、Bug、版本、测试单、日报、周报
+	Projects      []Project      `gorm:"many2many:project_attachments;" json:"projects,omitempty"`
+	Requirements  []Requirement  `gorm:"many2many:requirement_attachments;" json:"requirements,omitempty"`
+	Tasks         []Task         `gorm:"many2many:task_attachments;" json:"tasks,omitempty"`
+	Bugs          []Bug          `gorm:"many2many:bug_attachments;" json:"bugs,omitempty"`
+	Versions      []Version      `gorm:"many2many:version_attachments;" json:"versions,omitempty"`
+	TestCases     []TestCase     `gorm:"many2many:test_case_attachments;" json:"test_cases,omitempty"`
+	DailyReports  []DailyReport  `gorm:"many2many:daily_report_attachments;" json:"daily_reports,omitempty"`
+	WeeklyReports []WeeklyReport `gorm:"many2many:weekly_report_attachments;" json:"weekly_reports,omitempty"`
+}
+
+// AttachmentBlob 附件文件内容（按 SHA-256 去重）：多个附件或附件版本引用同一内容时共用一个存储文件，
+// 引用数减为 0 时删除文件和缩略图
+type AttachmentBlob struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Hash          string `gorm:"size:64;uniqueIndex;not null" json:"hash"` // 内容 SHA-256
+	FilePath      string `gorm:"size:500;not null" json:"file_path"`       // 存储中的对象键
+	FileSize      int64  `gorm:"not null" json:"file_size"`                // 文件大小（字节）
+	MimeType      string `gorm:"size:100" json:"mime_type"`                // 首次上传时的MIME类型
+	RefCount      int    `gorm:"not null;default:0" json:"ref_count"`      // 引用数（附件和附件版本）
+	ThumbnailPath string `gorm:"size:500" json:"thumbnail_path"`           // 图片缩略图的对象键，为空表示没有缩略图
+}
+
+// AttachmentVersion 附件版本历史：附件上传新版本后记录每个版本的文件，当前版本与附件记录一致
+type AttachmentVersion struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"` // 版本上传时间
+
+	AttachmentID uint `gorm:"index;not null;uniqueIndex:idx_attachment_version" json:"attachment_id"`
+	Version      int  `gorm:"not null;uniqueIndex:idx_attachment_version" json:"version"`
+
+	FileName    string `gorm:"size:255;not null" json:"file_name"`
+	FilePath    string `gorm:"size:500;not null" json:"file_path"`
+	FileSize    int64  `gorm:"not null" json:"file_size"`
+	MimeType    string `gorm:"size:100" json:"mime_type"`
+	ContentHash string `gorm:"size:64;index" json:"content_hash"`
+	Comment     string `gorm:"size:500" json:"comment"` // 版本说明
+
+	CreatorID uint `gorm:"index" json:"creator_id"` // 上传人ID
+	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
 }
 
 // ProjectAttachment 项目附件关联表
@@ -63,3 +102,24 @@ type VersionAttachment struct {
 	AttachmentID uint      `gorm:"primaryKey" json:"attachment_id"`
 	CreatedAt    time.Time `json:"created_at"`
 }
+
+// TestCaseAttachment 测试单附件关联表
+type TestCaseAttachment struct {
+	TestCaseID   uint      `gorm:"primaryKey" json:"test_case_id"`
+	AttachmentID uint      `gorm:"primaryKey" json:"attachment_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DailyReportAttachment 日报附件关联表
+type DailyReportAttachment struct {
+	DailyReportID uint      `gorm:"primaryKey" json:"daily_report_id"`
+	AttachmentID  uint      `gorm:"primaryKey" json:"attachment_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WeeklyReportAttachment 周报附件关联表
+type WeeklyReportAttachment struct {
+	WeeklyReportID uint      `gorm:"primaryKey" json:"weekly_report_id"`
+	AttachmentID   uint      `gorm:"primaryKey" json:"attachment_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -32,5 +32,27 @@ type AuditLog struct {
 	Success   bool   `gorm:"default:true" json:"success"`  // 操作是否成功
 	ErrorMsg  string `gorm:"type:text" json:"error_msg"`  // 错误信息（如果失败）
 	Comment   string `gorm:"type:text" json:"comment"`    // 备注信息
+
+	// 哈希链：每条记录包含上一条记录的哈希，修改或删除记录都会导致校验失败
+	PrevHash string `gorm:"size:64" json:"prev_hash"` // 上一条记录的哈希
+	Hash     string `gorm:"size:64;index" json:"hash"` // 本条记录的哈希（SHA-256）
+}
+
+// ArchivedAuditLog 已归档的审计日志：记录保留哈希链信息，日志内容写入压缩归档文件
+type ArchivedAuditLog struct {
+	ID          uint      `gorm:"primarykey;autoIncrement:false" json:"id"` // 原审计日志ID
+	ActionType  string    `gorm:"size:50;index" json:"action_type"`
+	LoggedAt    time.Time `json:"logged_at"` // 原审计日志的记录时间
+	PrevHash    string    `gorm:"size:64" json:"prev_hash"`
+	Hash        string    `gorm:"size:64" json:"hash"`
+	ArchiveFile string    `gorm:"size:255" json:"archive_file"` // 归档文件名
+	ArchivedAt  time.Time `json:"archived_at"`
 }
 
+
+// AuditChainState 哈希链状态：记录哈希链的第一条记录，之后没有哈希的记录都视为异常
+type AuditChainState struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	GenesisID uint      `json:"genesis_id"` // 哈希链第一条记录的ID
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GitWebhook 项目的 Git Webhook 接收配置（GitHub/GitLab/Gitea 推送和合并请求事件）
+type GitWebhook struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name           string     `gorm:"size:100;not null" json:"name"`       // 名称（如仓库名）
+	Provider       string     `gorm:"size:20" json:"provider"`             // 托管平台：github, gitlab, gitea，为空表示根据请求头自动识别
+	Secret         string     `gorm:"size:100;not null" json:"-"`          // 签名密钥（GitLab 为令牌），只在创建时返回
+	AutoTransition bool       `gorm:"default:true" json:"auto_transition"` // 是否根据 fix/close 等关键字自动解决Bug、完成任务
+	LastDeliveryAt *time.Time `json:"last_delivery_at"`                    // 最近一次收到有效请求的时间
+
+	ProjectID uint `gorm:"index;not null" json:"project_id"`
+
+	CreatorID uint `gorm:"index" json:"creator_id"` // 创建人（提交作者无法匹配到用户时以创建人身份记录操作）
+	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+}
+
+// CodeLink 工作项关联的提交或合并请求
+type CodeLink struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID  uint   `gorm:"index;not null" json:"project_id"`
+	ObjectType string `gorm:"size:20;not null;uniqueIndex:idx_code_link" json:"object_type"` // 工作项类型：bug, task, requirement
+	ObjectID   uint   `gorm:"not null;uniqueIndex:idx_code_link" json:"object_id"`
+	Kind       string `gorm:"size:20;not null;uniqueIndex:idx_code_link" json:"kind"` // commit, merge_request
+	Repository string `gorm:"size:200;uniqueIndex:idx_code_link" json:"repository"`   // 仓库（owner/name）
+	Ref        string `gorm:"size:100;not null;uniqueIndex:idx_code_link" json:"ref"` // 提交SHA或合并请求编号
+
+	Provider string `gorm:"size:20" json:"provider"`
+	Title    string `gorm:"size:500" json:"title"` // 提交信息首行或合并请求标题
+	URL      string `gorm:"size:500" json:"url"`
+	Author   string `gorm:"size:100" json:"author"`
+	State    string `gorm:"size:20" json:"state"` // 合并请求状态：opened, merged, closed
+
+	WebhookID uint `gorm:"index" json:"webhook_id"`
+}
@@ -19,3 +19,22 @@ type UserDashboard struct {
 	Config string `gorm:"type:text" json:"config"` // JSON配置：卡片排序、显示/隐藏等
 }
 
+// Dashboard 共享仪表板：团队仪表板所有用户可见，项目仪表板项目成员可见
+type Dashboard struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"size:100;not null" json:"name"`
+	Description string `gorm:"type:text" json:"description"`
+	Scope       string `gorm:"size:20;index;not null" json:"scope"` // team（团队）, project（项目）
+
+	ProjectID *uint    `gorm:"index" json:"project_id"` // 项目仪表板所属项目
+	Project   *Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+
+	OwnerID uint `gorm:"index" json:"owner_id"`
+	Owner   User `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
+
+	Widgets string `gorm:"type:text" json:"-"` // JSON：组件实例列表
+}
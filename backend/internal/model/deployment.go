@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Build 构建表（版本下的一次构建产物）
+type Build struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	BuildNumber string `gorm:"size:100;not null" json:"build_number"`  // 构建号（同一版本内唯一）
+	CommitSHA   string `gorm:"size:64;index" json:"commit_sha"`        // 构建对应的提交
+	Branch      string `gorm:"size:200" json:"branch"`                 // 构建分支
+	Source      string `gorm:"size:20;default:'manual'" json:"source"` // 来源：manual(手动登记), ci(CI上报)
+	Notes       string `gorm:"type:text" json:"notes"`                 // 构建说明
+
+	VersionID uint    `gorm:"index;not null" json:"version_id"`
+	Version   Version `gorm:"foreignKey:VersionID" json:"version,omitempty"`
+
+	ProjectID uint `gorm:"index;not null" json:"project_id"`
+
+	CreatorID uint `gorm:"index" json:"creator_id"`
+	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+
+	// 构建产物（多对多关系）
+	Attachments []Attachment `gorm:"many2many:build_attachments;" json:"attachments"`
+}
+
+// Environment 部署环境表（每个项目独立配置，如 dev, test, staging, prod）
+type Environment struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"size:100;not null" json:"name"` // 环境名称
+	Code        string `gorm:"size:50;not null" json:"code"`  // 环境编码（项目内唯一），如 dev, test, staging, prod
+	Description string `gorm:"type:text" json:"description"`  // 描述
+	Sort        int    `gorm:"default:0" json:"sort"`         // 排序（按发布流程从开发到生产）
+
+	ProjectID uint `gorm:"index;not null" json:"project_id"`
+}
+
+// Deployment 部署记录表（哪个构建在什么时间部署到了哪个环境）
+type Deployment struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Status     string    `gorm:"size:20;default:'success'" json:"status"` // 部署结果：success(成功), failed(失败)
+	DeployedAt time.Time `gorm:"index" json:"deployed_at"`                // 部署时间
+	Comment    string    `gorm:"type:text" json:"comment"`                // 备注
+
+	ProjectID uint `gorm:"index;not null" json:"project_id"`
+
+	EnvironmentID uint        `gorm:"index;not null" json:"environment_id"`
+	Environment   Environment `gorm:"foreignKey:EnvironmentID" json:"environment,omitempty"`
+
+	BuildID uint  `gorm:"index;not null" json:"build_id"`
+	Build   Build `gorm:"foreignKey:BuildID" json:"build,omitempty"`
+
+	VersionID uint `gorm:"index;not null" json:"version_id"` // 构建所属版本（冗余，便于按版本查询）
+
+	DeployerID uint `gorm:"index" json:"deployer_id"`
+	Deployer   User `gorm:"foreignKey:DeployerID" json:"deployer,omitempty"`
+}
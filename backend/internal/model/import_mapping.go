@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+)
+
+// ImportMapping 导入对象ID映射表（用于项目数据包的幂等导入）
+// 记录来源实例中的对象ID与本实例中新对象ID的对应关系，重复导入同一数据包时更新已有对象而不是重复创建
+type ImportMapping struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	SourceInstance string `gorm:"size:64;not null;uniqueIndex:idx_import_source" json:"source_instance"` // 来源实例标识
+	ObjectType     string `gorm:"size:30;not null;uniqueIndex:idx_import_source" json:"object_type"`     // 对象类型：project, task, bug等
+	SourceID       uint   `gorm:"not null;uniqueIndex:idx_import_source" json:"source_id"`               // 来源实例中的对象ID
+	TargetID       uint   `gorm:"not null;index" json:"target_id"`                                       // 本实例中的对象ID
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+// ItemAccess 受限对象访问名单（保密Bug、受限需求和任务）
+// 按用户或角色授权：RoleCode 可以是项目角色代码（匹配成员在该项目中的角色）或全局角色代码
+type ItemAccess struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ObjectType string `gorm:"size:20;not null;index:idx_item_access_object" json:"object_type"` // 对象类型：bug, requirement, task
+	ObjectID   uint   `gorm:"not null;index:idx_item_access_object" json:"object_id"`           // 对象ID
+
+	UserID   *uint  `gorm:"index" json:"user_id"` // 授权用户
+	User     *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	RoleCode string `gorm:"size:50" json:"role_code"` // 授权角色代码
+
+	CreatorID uint `gorm:"index" json:"creator_id"` // 授权人
+}
@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// ProjectMetricSnapshot 项目每日指标快照表：记录项目每天结束时的统计数据，用于历史趋势图
+// 每条记录是一个指标值，例如 (bug, status, active) 表示当天结束时处于激活状态的Bug数量
+type ProjectMetricSnapshot struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID  uint    `gorm:"not null;uniqueIndex:idx_project_metric" json:"project_id"`
+	Date       string  `gorm:"size:10;not null;index;uniqueIndex:idx_project_metric" json:"date"`  // 日期（YYYY-MM-DD）
+	ObjectType string  `gorm:"size:20;not null;uniqueIndex:idx_project_metric" json:"object_type"` // 对象类型：task, bug, requirement
+	Dimension  string  `gorm:"size:20;not null;uniqueIndex:idx_project_metric" json:"dimension"`   // 统计维度：status(状态), priority(未完成对象的优先级), severity(未完成Bug的严重程度), created(当天新建), hours(工时)
+	Name       string  `gorm:"size:30;not null;uniqueIndex:idx_project_metric" json:"name"`        // 维度取值，如 active、high；工时维度为 remaining(剩余工时)、completed(累计完成工时)
+	Value      float64 `json:"value"`
+}
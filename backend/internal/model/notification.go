@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// Notification 站内通知
+type Notification struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `gorm:"index;not null" json:"user_id"` // 接收人
+
+	Type    string     `gorm:"size:50;index" json:"type"`      // 通知类型，如 report_reminder
+	Title   string     `gorm:"size:200;not null" json:"title"` // 标题
+	Content string     `gorm:"type:text" json:"content"`       // 内容
+	Link    string     `gorm:"size:500" json:"link"`           // 前端跳转地址
+	ReadAt  *time.Time `json:"read_at"`                        // 阅读时间，为空表示未读
+}
@@ -20,6 +20,11 @@ type Project struct {
 	StartDate   *time.Time `json:"start_date"`                      // 开始日期
 	EndDate     *time.Time `json:"end_date"`                        // 结束日期
 
+	StorageQuota int64 `gorm:"default:0" json:"storage_quota"` // 附件存储配额（字节），0 表示不限制
+
+	ProgramID *uint    `gorm:"index" json:"program_id"` // 所属项目集（产品线），为空表示独立项目
+	Program   *Program `gorm:"foreignKey:ProgramID" json:"program,omitempty"`
+
 	Members      []ProjectMember `gorm:"foreignKey:ProjectID" json:"members,omitempty"`
 	Tasks        []Task          `gorm:"foreignKey:ProjectID" json:"tasks,omitempty"`
 	Bugs         []Bug           `gorm:"foreignKey:ProjectID" json:"bugs,omitempty"`
@@ -27,6 +32,7 @@ type Project struct {
 	TestCases    []TestCase      `gorm:"foreignKey:ProjectID" json:"test_cases,omitempty"`
 	Boards       []Board         `gorm:"foreignKey:ProjectID" json:"boards,omitempty"`
 	Tags         []Tag           `gorm:"many2many:project_tags;" json:"tags,omitempty"` // 标签（多对多关联）
+	Modules      []Module        `gorm:"many2many:project_modules;" json:"modules,omitempty"` // 启用的功能模块（多对多关联）
 }
 
 // ProjectMember 项目成员表
@@ -44,3 +50,36 @@ type ProjectMember struct {
 
 	Role string `gorm:"size:50" json:"role"` // 项目角色：owner, member, viewer
 }
+
+// Program 项目集（产品线）：将多个项目组织在一起，用于汇总统计和组合管理
+type Program struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"size:100;not null" json:"name"`          // 项目集名称
+	Code        string `gorm:"size:50;uniqueIndex" json:"code"`        // 项目集编码
+	Description string `gorm:"type:text" json:"description"`           // 描述
+	Status      string `gorm:"size:20;default:'active'" json:"status"` // 状态：active(进行中), closed(已关闭)
+
+	Projects []Project       `gorm:"foreignKey:ProgramID" json:"projects,omitempty"`
+	Members  []ProgramMember `gorm:"foreignKey:ProgramID" json:"members,omitempty"`
+}
+
+// ProgramMember 项目集成员表
+// 项目集成员按其角色访问项目集内的所有项目（项目成员身份优先）
+type ProgramMember struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	ProgramID uint    `gorm:"index" json:"program_id"`
+	Program   Program `gorm:"foreignKey:ProgramID" json:"program,omitempty"`
+
+	UserID uint `gorm:"index" json:"user_id"`
+	User   User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+
+	Role string `gorm:"size:50" json:"role"` // 项目角色代码，作用于项目集内的所有项目：owner(项目集负责人), member, viewer
+}
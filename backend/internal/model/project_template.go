@@ -0,0 +1,141 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectTemplate 项目模板表
+type ProjectTemplate struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"size:100;not null" json:"name"` // 模板名称
+	Description string `gorm:"type:text" json:"description"`  // 模板描述
+
+	CreatorID uint `gorm:"index" json:"creator_id"`
+	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+
+	Content ProjectTemplateContent `gorm:"type:text" json:"content"` // 模板内容（JSON）
+}
+
+// ProjectTemplateContent 项目模板内容
+// 模板内的对象使用 Key 相互引用（如任务依赖、任务关联需求），实例化时重新映射为新ID
+type ProjectTemplateContent struct {
+	Members      []TemplateMember      `json:"members"`      // 成员及项目角色
+	TagIDs       []uint                `json:"tag_ids"`      // 标签
+	ModuleIDs    []uint                `json:"module_ids"`   // 功能模块
+	Boards       []TemplateBoard       `json:"boards"`       // 看板及列
+	Requirements []TemplateRequirement `json:"requirements"` // 需求（克隆项目时使用）
+	Tasks        []TemplateTask        `json:"tasks"`        // 标准任务清单
+	Versions     []TemplateVersion     `json:"versions"`     // 默认版本
+}
+
+// TemplateMember 模板成员
+type TemplateMember struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// TemplateBoard 模板看板
+type TemplateBoard struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	CardTypes   string                `json:"card_types,omitempty"`
+	SwimlaneBy  string                `json:"swimlane_by,omitempty"`
+	Columns     []TemplateBoardColumn `json:"columns"`
+}
+
+// TemplateBoardColumn 模板看板列
+type TemplateBoardColumn struct {
+	Name              string `json:"name"`
+	Color             string `json:"color"`
+	Status            string `json:"status"`
+	BugStatus         string `json:"bug_status,omitempty"`
+	RequirementStatus string `json:"requirement_status,omitempty"`
+	WIPLimit          int    `json:"wip_limit,omitempty"`
+	WIPPolicy         string `json:"wip_policy,omitempty"`
+	Sort              int    `json:"sort"`
+}
+
+// TemplateRequirement 模板需求
+type TemplateRequirement struct {
+	Key            string   `json:"key"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Status         string   `json:"status"`
+	Priority       string   `json:"priority"`
+	AssigneeID     *uint    `json:"assignee_id"`
+	EstimatedHours *float64 `json:"estimated_hours"`
+
+	Confidential bool                 `json:"confidential,omitempty"` // 是否受限（克隆项目时保留）
+	Access       []TemplateItemAccess `json:"access,omitempty"`       // 受限需求的访问名单
+}
+
+// TemplateTask 模板任务
+// 日期使用相对项目开始日期的天数偏移
+type TemplateTask struct {
+	Key            string   `json:"key"`
+	Title          string   `json:"title"`
+	Description    string   `json:"description"`
+	Priority       string   `json:"priority"`
+	AssigneeID     *uint    `json:"assignee_id"`
+	RequirementKey string   `json:"requirement_key"`
+	EstimatedHours *float64 `json:"estimated_hours"`
+	StartOffset    *int     `json:"start_offset"` // 开始日期偏移（天）
+	EndOffset      *int     `json:"end_offset"`   // 结束日期偏移（天）
+	DueOffset      *int     `json:"due_offset"`   // 截止日期偏移（天）
+	DependsOn      []string `json:"depends_on"`   // 依赖的任务Key
+
+	Confidential bool                 `json:"confidential,omitempty"` // 是否受限（克隆项目时保留）
+	Access       []TemplateItemAccess `json:"access,omitempty"`       // 受限任务的访问名单
+}
+
+// TemplateItemAccess 受限需求和任务的访问名单（用户或角色）
+type TemplateItemAccess struct {
+	UserID   *uint  `json:"user_id,omitempty"`
+	RoleCode string `json:"role_code,omitempty"`
+}
+
+// TemplateVersion 模板版本
+type TemplateVersion struct {
+	VersionNumber string `json:"version_number"`
+	ReleaseNotes  string `json:"release_notes"`
+	ReleaseOffset *int   `json:"release_offset"` // 发布日期偏移（天）
+}
+
+// Value 实现 driver.Valuer 接口
+func (c ProjectTemplateContent) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner 接口
+func (c *ProjectTemplateContent) Scan(value interface{}) error {
+	if value == nil {
+		*c = ProjectTemplateContent{}
+		return nil
+	}
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return nil
+	}
+	if len(bytes) == 0 {
+		*c = ProjectTemplateContent{}
+		return nil
+	}
+	return json.Unmarshal(bytes, c)
+}
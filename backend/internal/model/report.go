@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // DailyReport 日报表
@@ -43,8 +45,13 @@ type DailyReportApproval struct {
 	ApproverID uint `gorm:"index;not null" json:"approver_id"`
 	Approver   User `gorm:"foreignKey:ApproverID" json:"approver,omitempty"`
 
-	Status  string `gorm:"size:20;default:'pending'" json:"status"` // 审批状态：pending, approved, rejected
+	Status  string `gorm:"size:20;default:'pending'" json:"status"` // 审批状态：pending, approved, rejected, skipped（驳回后未审批的跳过）
 	Comment string `gorm:"type:text" json:"comment"`                // 批注
+
+	Round     int   `gorm:"default:1" json:"round"`            // 审批轮次：驳回后重新提交开始新一轮
+	Stage     int   `gorm:"default:1" json:"stage"`            // 审批阶段：同一阶段并行审批，前一阶段全部通过后才进入下一阶段
+	ActedByID *uint `gorm:"index" json:"acted_by_id"`          // 代理审批人ID（审批人委托他人审批时）
+	ActedBy   *User `gorm:"foreignKey:ActedByID" json:"acted_by,omitempty"`
 }
 
 // WeeklyReport 周报表
@@ -88,7 +95,60 @@ type WeeklyReportApproval struct {
 	ApproverID uint `gorm:"index;not null" json:"approver_id"`
 	Approver   User `gorm:"foreignKey:ApproverID" json:"approver,omitempty"`
 
-	Status  string `gorm:"size:20;default:'pending'" json:"status"` // 审批状态：pending, approved, rejected
+	Status  string `gorm:"size:20;default:'pending'" json:"status"` // 审批状态：pending, approved, rejected, skipped（驳回后未审批的跳过）
 	Comment string `gorm:"type:text" json:"comment"`                  // 批注
+
+	Round     int   `gorm:"default:1" json:"round"`            // 审批轮次：驳回后重新提交开始新一轮
+	Stage     int   `gorm:"default:1" json:"stage"`            // 审批阶段：同一阶段并行审批，前一阶段全部通过后才进入下一阶段
+	ActedByID *uint `gorm:"index" json:"acted_by_id"`          // 代理审批人ID（审批人委托他人审批时）
+	ActedBy   *User `gorm:"foreignKey:ActedByID" json:"acted_by,omitempty"`
+}
+
+
+// ReportApprovalChain 报告审批链：未指定审批人的日报、周报提交后按审批链逐级审批
+type ReportApprovalChain struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name       string `gorm:"size:100;not null" json:"name"` // 名称
+	ReportType string `gorm:"size:20" json:"report_type"`    // 适用的报告类型：daily, weekly，为空表示都适用
+	Enabled    bool   `gorm:"default:true" json:"enabled"`   // 是否启用
+
+	DepartmentID *uint       `gorm:"index" json:"department_id"` // 适用部门（包括下级部门），为空表示默认审批链
+	Department   *Department `gorm:"foreignKey:DepartmentID" json:"department,omitempty"`
+
+	Steps []ReportApprovalStep `gorm:"foreignKey:ChainID" json:"steps,omitempty"`
+}
+
+// ReportApprovalStep 审批链中的审批人
+type ReportApprovalStep struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ChainID      uint   `gorm:"index;not null" json:"chain_id"`
+	Stage        int    `gorm:"not null" json:"stage"`                 // 审批阶段，从1开始；同一阶段的多个审批人并行审批
+	ApproverType string `gorm:"size:20;not null" json:"approver_type"` // 审批人类型：user（指定用户）, leader（部门负责人）
+	Level        int    `gorm:"default:0" json:"level"`                // 部门负责人层级：0-本部门（如组长），1-上级部门（如部门负责人），依此类推
+
+	UserID *uint `gorm:"index" json:"user_id"` // 指定用户（approver_type 为 user 时）
+	User   *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
+// ApprovalDelegation 审批委托：审批人请假期间由代理人审批其报告
+type ApprovalDelegation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint `gorm:"index;not null" json:"user_id"` // 委托人
+	User       User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	DelegateID uint `gorm:"index;not null" json:"delegate_id"` // 代理人
+	Delegate   User `gorm:"foreignKey:DelegateID" json:"delegate,omitempty"`
+
+	StartDate time.Time `gorm:"type:date;not null" json:"start_date"` // 开始日期
+	EndDate   time.Time `gorm:"type:date;not null" json:"end_date"`   // 结束日期（包含）
+	Reason    string    `gorm:"size:255" json:"reason"`               // 原因，如请假
+}
@@ -18,9 +18,16 @@ type Requirement struct {
 	Status      string `gorm:"size:20;default:'draft'" json:"status"`    // 状态：draft(草稿), reviewing(评审中), active(激活), changing(变更中), closed(已关闭)
 	Priority    string `gorm:"size:20;default:'medium'" json:"priority"` // 优先级：low, medium, high, urgent
 
+	Confidential bool `gorm:"default:false;index" json:"confidential"` // 是否受限：受限需求只对创建人、负责人和访问名单可见
+
 	ProjectID uint    `gorm:"index;not null" json:"project_id"` // 必填关联项目
 	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 
+	// 项目集级需求：由项目集负责，可以由项目集内的多个项目共同实现
+	ProgramID      *uint     `gorm:"index" json:"program_id"`
+	Program        *Program  `gorm:"foreignKey:ProgramID" json:"program,omitempty"`
+	LinkedProjects []Project `gorm:"many2many:requirement_projects;" json:"linked_projects,omitempty"` // 参与实现的其他项目
+
 	CreatorID uint `gorm:"index" json:"creator_id"`
 	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
 
@@ -48,6 +55,8 @@ type Bug struct {
 	Severity    string `gorm:"size:20;default:'medium'" json:"severity"` // 严重程度：low, medium, high, critical
 	Confirmed   bool   `gorm:"default:false" json:"confirmed"`           // 是否确认：false(未确认), true(已确认)
 
+	Confidential bool `gorm:"default:false;index" json:"confidential"` // 是否保密（如安全漏洞）：保密Bug只对创建人、分配人和访问名单可见
+
 	ProjectID uint    `gorm:"index;not null" json:"project_id"`
 	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 
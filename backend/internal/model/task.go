@@ -18,6 +18,8 @@ type Task struct {
 	Status      string `gorm:"size:20;default:'wait'" json:"status"`  // 状态：wait(未开始), doing(进行中), done(已完成), pause(已暂停), cancel(已取消), closed(已关闭)
 	Priority    string `gorm:"size:20;default:'medium'" json:"priority"` // 优先级：low, medium, high, urgent
 
+	Confidential bool `gorm:"default:false;index" json:"confidential"` // 是否受限：受限任务只对创建人、负责人和访问名单可见
+
 	ProjectID uint    `gorm:"index;not null" json:"project_id"`
 	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 
@@ -66,6 +68,9 @@ type Board struct {
 	ProjectID uint    `gorm:"index;not null" json:"project_id"`
 	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 
+	CardTypes  string `gorm:"size:50;default:'task'" json:"card_types"` // 看板显示的卡片类型（逗号分隔）：task, bug, requirement
+	SwimlaneBy string `gorm:"size:20" json:"swimlane_by"`               // 泳道分组方式：assignee(负责人), priority(优先级), requirement(需求)，为空表示不分泳道
+
 	Columns []BoardColumn `gorm:"foreignKey:BoardID" json:"columns,omitempty"`
 }
 
@@ -83,6 +88,25 @@ type BoardColumn struct {
 	BoardID uint   `gorm:"index;not null" json:"board_id"`
 	Board   Board  `gorm:"foreignKey:BoardID" json:"board,omitempty"`
 
-	Status string `gorm:"size:20" json:"status"` // 关联的任务状态
+	Status            string `gorm:"size:20" json:"status"`             // 关联的任务状态
+	BugStatus         string `gorm:"size:20" json:"bug_status"`         // 关联的Bug状态
+	RequirementStatus string `gorm:"size:20" json:"requirement_status"` // 关联的需求状态
+
+	WIPLimit  int    `gorm:"default:0" json:"wip_limit"`                // 在制品（WIP）上限，0表示不限制
+	WIPPolicy string `gorm:"size:10;default:'block'" json:"wip_policy"` // 超出上限时的处理方式：block(禁止移入), warn(允许移入并提示)
+}
+
+// BoardCard 看板卡片表：记录卡片（任务、Bug、需求）在看板中的排序键和版本号
+// 排序键按字典序比较，插入时取相邻卡片排序键的中间值，不需要重排其他卡片
+type BoardCard struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	BoardID    uint   `gorm:"not null;uniqueIndex:idx_board_card" json:"board_id"`
+	ObjectType string `gorm:"size:20;not null;uniqueIndex:idx_board_card" json:"object_type"` // 卡片类型：task, bug, requirement
+	ObjectID   uint   `gorm:"not null;uniqueIndex:idx_board_card" json:"object_id"`
+	Rank       string `gorm:"size:64;index" json:"rank"` // 排序键
+	Version    int    `gorm:"default:0" json:"version"`  // 卡片版本号：卡片每次移动或修改时递增，用于检测并发移动冲突
 }
 
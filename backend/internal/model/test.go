@@ -51,6 +51,7 @@ type TestCase struct {
 	Status      string       `gorm:"size:20;default:'wait'" json:"status"` // 状态：wait(待评审), normal(正常), blocked(被阻塞), investigate(研究中)
 	Result      string       `gorm:"size:20" json:"result"`                 // 测试结果：passed, failed, blocked（合并自TestReport）
 	Summary     string       `gorm:"type:text" json:"summary"`              // 测试摘要（合并自TestReport）
+	ExternalKey string       `gorm:"size:255;index" json:"external_key"`    // 外部标识（自动化测试的测试全名，用于匹配上传的测试报告）
 
 	ProjectID uint    `gorm:"index;not null" json:"project_id"`
 	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
@@ -59,6 +60,19 @@ type TestCase struct {
 	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
 
 	Bugs    []Bug        `gorm:"many2many:test_case_bugs;" json:"bugs,omitempty"`
+	Steps   []TestCaseStep `gorm:"foreignKey:TestCaseID" json:"steps,omitempty"` // 结构化测试步骤
+}
+
+// TestCaseStep 测试单步骤表（操作步骤及预期结果）
+type TestCaseStep struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TestCaseID uint   `gorm:"index;not null" json:"test_case_id"`
+	Sort       int    `gorm:"default:0" json:"sort"`         // 步骤序号
+	Action     string `gorm:"type:text" json:"action"`       // 操作步骤
+	Expected   string `gorm:"type:text" json:"expected"`     // 预期结果
 }
 
 // TestCaseBug 测试单-Bug关联表
@@ -68,3 +82,87 @@ type TestCaseBug struct {
 	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 }
 
+
+// TestSuite 测试套件表（测试单的集合，用于按版本批量执行）
+type TestSuite struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"size:200;not null" json:"name"` // 套件名称
+	Description string `gorm:"type:text" json:"description"`  // 套件描述
+
+	ProjectID uint    `gorm:"index;not null" json:"project_id"`
+	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+
+	CreatorID uint `gorm:"index" json:"creator_id"`
+	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+
+	TestCases []TestCase `gorm:"many2many:test_suite_cases;" json:"test_cases,omitempty"`
+}
+
+// TestRun 测试执行表（针对某个版本执行一组测试单）
+type TestRun struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name   string `gorm:"size:200;not null" json:"name"`        // 执行名称
+	Status string `gorm:"size:20;default:'wait'" json:"status"` // 状态：wait(未开始), doing(执行中), done(已完成)
+	Source string `gorm:"size:20;default:'manual'" json:"source"` // 来源：manual(手工执行), ci(上传测试报告)
+
+	ProjectID uint    `gorm:"index;not null" json:"project_id"`
+	Project   Project `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+
+	VersionID uint    `gorm:"index;not null" json:"version_id"` // 被测版本
+	Version   Version `gorm:"foreignKey:VersionID" json:"version,omitempty"`
+
+	TestSuiteID *uint      `gorm:"index" json:"test_suite_id"` // 来源测试套件（可选）
+	TestSuite   *TestSuite `gorm:"foreignKey:TestSuiteID" json:"test_suite,omitempty"`
+
+	CreatorID uint `gorm:"index" json:"creator_id"`
+	Creator   User `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+
+	StartedAt  *time.Time `json:"started_at"`  // 第一次记录执行结果的时间
+	FinishedAt *time.Time `json:"finished_at"` // 全部测试单执行完成的时间
+
+	Cases []TestRunCase `gorm:"foreignKey:TestRunID" json:"cases,omitempty"`
+}
+
+// TestRunCase 测试执行中的测试单及其执行结果
+type TestRunCase struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TestRunID  uint     `gorm:"index;not null" json:"test_run_id"`
+	TestCaseID uint     `gorm:"index;not null" json:"test_case_id"`
+	TestCase   TestCase `gorm:"foreignKey:TestCaseID" json:"test_case,omitempty"`
+
+	Result     string     `gorm:"size:20;default:'untested'" json:"result"` // 执行结果：untested(未执行), pass(通过), fail(失败), blocked(阻塞), skip(跳过)
+	Comment    string     `gorm:"type:text" json:"comment"`                 // 执行备注
+	TesterID   *uint      `gorm:"index" json:"tester_id"`                   // 执行人
+	Tester     *User      `gorm:"foreignKey:TesterID" json:"tester,omitempty"`
+	ExecutedAt *time.Time `json:"executed_at"` // 执行时间
+	Duration   float64    `json:"duration"`    // 耗时（秒，来自测试报告）
+	BugID      *uint      `gorm:"index" json:"bug_id"` // 自动化测试失败时创建或重新激活的Bug
+
+	StepResults []TestStepResult `gorm:"foreignKey:TestRunCaseID" json:"step_results,omitempty"`
+}
+
+// TestStepResult 测试步骤的执行结果
+type TestStepResult struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TestRunCaseID uint `gorm:"index;not null" json:"test_run_case_id"`
+	StepID        uint `gorm:"index;not null" json:"step_id"` // 测试单步骤ID
+
+	Result   string `gorm:"size:20;not null" json:"result"` // 执行结果：pass, fail, blocked, skip
+	Actual   string `gorm:"type:text" json:"actual"`        // 实际结果
+	TesterID uint   `gorm:"index" json:"tester_id"`         // 执行人
+	BugID    *uint  `gorm:"index" json:"bug_id"`            // 根据失败步骤创建的Bug
+}
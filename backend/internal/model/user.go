@@ -44,6 +44,8 @@ type Department struct {
 	Level    int    `gorm:"default:1" json:"level"`               // 层级
 	Sort     int    `gorm:"default:0" json:"sort"`                // 排序
 	Status   int    `gorm:"default:1" json:"status"`             // 状态：1-正常，0-禁用
+	LeaderID *uint  `gorm:"index" json:"leader_id"`               // 部门负责人ID，用于报告审批链
+	Leader   *User  `gorm:"foreignKey:LeaderID" json:"leader,omitempty"`
 }
 
 // Role 角色表
@@ -62,6 +64,22 @@ type Role struct {
 	Users       []User       `gorm:"many2many:user_roles;" json:"users,omitempty"`
 }
 
+// ProjectRole 项目角色表（项目内的角色定义）
+// ProjectMember.Role 按 Code 或 Name 匹配项目角色，匹配到的项目角色决定成员在该项目内的权限
+type ProjectRole struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Name        string `gorm:"size:50;not null;uniqueIndex" json:"name"` // 角色名称
+	Code        string `gorm:"size:50;not null;uniqueIndex" json:"code"` // 角色代码
+	Description string `gorm:"size:255" json:"description"`               // 描述
+	Status      int    `gorm:"default:1" json:"status"`                   // 状态：1-正常，0-禁用
+
+	Permissions []Permission `gorm:"many2many:project_role_permissions;" json:"permissions,omitempty"`
+}
+
 // Permission 权限表
 type Permission struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
@@ -30,3 +30,17 @@ type Version struct {
 	Attachments []Attachment `gorm:"many2many:version_attachments;" json:"attachments"`
 }
 
+
+// ReleaseGate 项目发布门禁配置（每个项目一条，未配置时不检查）
+type ReleaseGate struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ProjectID uint `gorm:"uniqueIndex;not null" json:"project_id"`
+
+	Enabled                   bool        `gorm:"default:false" json:"enabled"`                     // 是否启用发布门禁
+	BlockingBugSeverities     StringArray `gorm:"type:text" json:"blocking_bug_severities"`         // 阻止发布的未关闭Bug严重程度（如 critical, high），为空表示不检查
+	RequireRequirementsClosed bool        `gorm:"default:false" json:"require_requirements_closed"` // 版本关联的需求必须全部关闭
+	MinTestPassRate           float64     `gorm:"default:0" json:"min_test_pass_rate"`              // 版本测试单的最低通过率（%），0表示不检查
+}
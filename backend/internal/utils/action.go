@@ -23,12 +23,17 @@ func RecordAction(db *gorm.DB, objectType string, objectID uint, actionType stri
 		Comment:    comment,
 	}
 
-	// 获取项目ID（如果是Bug，从Bug表获取）
+	// 获取项目ID（如果是Bug或版本，从对应的表获取）
 	if objectType == "bug" {
 		var bug model.Bug
 		if err := db.First(&bug, objectID).Error; err == nil {
 			action.ProjectID = bug.ProjectID
 		}
+	} else if objectType == "version" {
+		var version model.Version
+		if err := db.First(&version, objectID).Error; err == nil {
+			action.ProjectID = version.ProjectID
+		}
 	}
 
 	// 处理extra字段（JSON格式）
@@ -170,6 +175,22 @@ func CompareAndRecord(db *gorm.DB, oldObj, newObj interface{}, objectType string
 	return actionID, nil
 }
 
+// RecordStatusChange 记录状态变更（状态未变化或没有操作人时不记录），用于流动分析
+func RecordStatusChange(db *gorm.DB, objectType string, objectID uint, actorID uint, oldStatus, newStatus string) (uint, error) {
+	if actorID == 0 || oldStatus == newStatus {
+		return 0, nil
+	}
+
+	actionID, err := RecordAction(db, objectType, objectID, "edited", actorID, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := RecordHistory(db, actionID, []HistoryChange{{Field: "status", Old: oldStatus, New: newStatus}}); err != nil {
+		return 0, err
+	}
+	return actionID, nil
+}
+
 // CompareObjects 比较两个对象，返回变更列表
 func CompareObjects(oldObj, newObj interface{}) []HistoryChange {
 	var changes []HistoryChange
@@ -0,0 +1,313 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+)
+
+// 离线管理（prjflow-admin）使用的用户、角色和系统配置操作，不依赖 HTTP 请求上下文
+
+// AdminUserInput 创建用户的参数
+type AdminUserInput struct {
+	Username string
+	Nickname string
+	Password string
+	Email    string
+	Roles    []string // 角色代码
+}
+
+// FindUserByRef 按用户ID或用户名查找用户
+func FindUserByRef(db *gorm.DB, ref string) (*model.User, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, fmt.Errorf("请指定用户（用户名或ID）")
+	}
+	var user model.User
+	err := db.Preload("Roles").Where("username = ?", ref).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if id, convErr := strconv.ParseUint(ref, 10, 64); convErr == nil {
+			err = db.Preload("Roles").First(&user, id).Error
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("用户不存在: %s", ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindRolesByCode 按角色代码查找角色，有不存在的代码时返回错误
+func FindRolesByCode(db *gorm.DB, codes []string) ([]model.Role, error) {
+	var wanted []string
+	for _, code := range codes {
+		if code = strings.TrimSpace(code); code != "" {
+			wanted = append(wanted, code)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+	var roles []model.Role
+	if err := db.Where("code IN ?", wanted).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Code] = true
+	}
+	for _, code := range wanted {
+		if !found[code] {
+			return nil, fmt.Errorf("角色不存在: %s", code)
+		}
+	}
+	return roles, nil
+}
+
+// AdminCreateUser 创建用户并分配角色；用户名被软删除的用户占用时先硬删除该用户（与创建用户接口一致）
+func AdminCreateUser(db *gorm.DB, input AdminUserInput) (*model.User, error) {
+	input.Username = strings.TrimSpace(input.Username)
+	input.Nickname = strings.TrimSpace(input.Nickname)
+	if input.Username == "" {
+		return nil, fmt.Errorf("用户名不能为空")
+	}
+	if input.Nickname == "" {
+		input.Nickname = input.Username
+	}
+	if err := ValidatePasswordStrength(input.Password); err != nil {
+		return nil, err
+	}
+	roles, err := FindRolesByCode(db, input.Roles)
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := HashPassword(input.Password)
+	if err != nil {
+		return nil, fmt.Errorf("加密密码失败: %w", err)
+	}
+
+	user := model.User{
+		Username: input.Username,
+		Nickname: input.Nickname,
+		Email:    input.Email,
+		Password: hashed,
+		Status:   1,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing model.User
+		if err := tx.Unscoped().Where("username = ?", input.Username).First(&existing).Error; err == nil {
+			if !existing.DeletedAt.Valid {
+				return fmt.Errorf("用户名已存在: %s", input.Username)
+			}
+			if err := tx.Model(&existing).Association("Roles").Clear(); err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&existing).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if len(roles) > 0 {
+			return tx.Model(&user).Association("Roles").Replace(roles)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FindUserByRef(db, user.Username)
+}
+
+// AdminResetPassword 重置用户密码
+func AdminResetPassword(db *gorm.DB, ref, password string) (*model.User, error) {
+	user, err := FindUserByRef(db, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePasswordStrength(password); err != nil {
+		return nil, err
+	}
+	hashed, err := HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("加密密码失败: %w", err)
+	}
+	if err := db.Model(user).Update("password", hashed).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// AdminSetUserStatus 启用或禁用用户
+func AdminSetUserStatus(db *gorm.DB, ref string, enabled bool) (*model.User, error) {
+	user, err := FindUserByRef(db, ref)
+	if err != nil {
+		return nil, err
+	}
+	status := 0
+	if enabled {
+		status = 1
+	}
+	if err := db.Model(user).Update("status", status).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// 分配角色的方式
+const (
+	RoleAssignSet    = "set"    // 替换为指定角色
+	RoleAssignAdd    = "add"    // 增加指定角色
+	RoleAssignRemove = "remove" // 移除指定角色
+)
+
+// AdminAssignRoles 按 mode（set/add/remove）修改用户的角色
+func AdminAssignRoles(db *gorm.DB, ref string, codes []string, mode string) (*model.User, error) {
+	user, err := FindUserByRef(db, ref)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := FindRolesByCode(db, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	association := db.Model(user).Association("Roles")
+	switch mode {
+	case RoleAssignSet, "":
+		err = association.Replace(roles)
+	case RoleAssignAdd:
+		if len(roles) > 0 {
+			err = association.Append(roles)
+		}
+	case RoleAssignRemove:
+		if len(roles) > 0 {
+			err = association.Delete(roles)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的角色分配方式: %s（支持 set、add、remove）", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return FindUserByRef(db, user.Username)
+}
+
+// ListPermissions 列出权限（按代码排序）；roleCode 不为空时只列出该角色的权限
+func ListPermissions(db *gorm.DB, roleCode string) ([]model.Permission, error) {
+	var permissions []model.Permission
+	if roleCode == "" {
+		err := db.Order("code").Find(&permissions).Error
+		return permissions, err
+	}
+	roles, err := FindRolesByCode(db, []string{roleCode})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Model(&roles[0]).Order("code").Association("Permissions").Find(&permissions)
+	return permissions, err
+}
+
+// SystemConfigKey 可以通过管理工具修改的系统配置项
+type SystemConfigKey struct {
+	Key         string
+	Type        string // string, number, boolean
+	Description string
+	Secret      bool                     // 敏感配置，默认不显示值
+	Validate    func(value string) error // 为空时不校验
+}
+
+// oneOf 校验值是否为给定值之一
+func oneOf(values ...string) func(string) error {
+	return func(value string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("无效的值: %s，支持的值: %s", value, strings.Join(values, ", "))
+	}
+}
+
+// systemConfigKeys 支持的系统配置项
+var systemConfigKeys = []SystemConfigKey{
+	{Key: "wechat_app_id", Type: "string", Description: "微信 AppID"},
+	{Key: "wechat_app_secret", Type: "string", Description: "微信 AppSecret", Secret: true},
+	{Key: "wechat_account_type", Type: "string", Description: "微信账号类型", Validate: oneOf("open_platform", "official_account")},
+	{Key: "wechat_scope", Type: "string", Description: "微信授权作用域", Validate: oneOf("snsapi_base", "snsapi_userinfo")},
+	{Key: "backup_enabled", Type: "boolean", Description: "是否开启自动备份", Validate: oneOf("true", "false")},
+	{Key: "backup_time", Type: "string", Description: "自动备份时间（HH:MM）", Validate: func(value string) error {
+		if _, err := time.Parse("15:04", value); err != nil {
+			return fmt.Errorf("备份时间格式错误，应为 HH:MM (24小时制)")
+		}
+		return nil
+	}},
+	{Key: BackupRetentionKey, Type: "number", Description: "备份保留天数（0 表示永久保留）", Validate: func(value string) error {
+		if days, err := strconv.Atoi(value); err != nil || days < 0 {
+			return fmt.Errorf("备份保留天数应为非负整数")
+		}
+		return nil
+	}},
+	{Key: "log_level", Type: "string", Description: "日志级别", Validate: oneOf("debug", "info", "warn", "error")},
+	{Key: "initialized", Type: "boolean", Description: "系统是否已完成初始化", Validate: oneOf("true", "false")},
+}
+
+// SystemConfigKeys 支持通过管理工具修改的系统配置项
+func SystemConfigKeys() []SystemConfigKey {
+	return systemConfigKeys
+}
+
+// LookupSystemConfigKey 查找系统配置项，不存在时返回 false
+func LookupSystemConfigKey(key string) (SystemConfigKey, bool) {
+	for _, item := range systemConfigKeys {
+		if item.Key == key {
+			return item, true
+		}
+	}
+	return SystemConfigKey{}, false
+}
+
+// GetSystemConfig 读取系统配置值，未设置时返回 false
+func GetSystemConfig(db *gorm.DB, key string) (string, bool, error) {
+	var item model.SystemConfig
+	err := db.Where("key = ?", key).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return item.Value, true, nil
+}
+
+// SetSystemConfig 校验并保存系统配置值，只支持 SystemConfigKeys 中的配置项
+func SetSystemConfig(db *gorm.DB, key, value string) error {
+	item, ok := LookupSystemConfigKey(key)
+	if !ok {
+		var keys []string
+		for _, k := range systemConfigKeys {
+			keys = append(keys, k.Key)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("不支持的配置项: %s，支持的配置项: %s", key, strings.Join(keys, ", "))
+	}
+	if item.Validate != nil {
+		if err := item.Validate(value); err != nil {
+			return err
+		}
+	}
+	config := model.SystemConfig{Key: key, Value: value, Type: item.Type}
+	return db.Where("key = ?", key).
+		Assign(model.SystemConfig{Value: value, Type: item.Type}).
+		FirstOrCreate(&config).Error
+}
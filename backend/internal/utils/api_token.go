@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"prjflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// API令牌前缀，便于识别和扫描泄露的令牌
+const apiTokenPrefix = "pft_"
+
+var (
+	// ErrInvalidAPIToken 令牌不存在或已删除
+	ErrInvalidAPIToken = errors.New("invalid api token")
+	// ErrExpiredAPIToken 令牌已过期
+	ErrExpiredAPIToken = errors.New("api token expired")
+)
+
+// GenerateAPIToken 生成新的API令牌，返回明文令牌和哈希值
+func GenerateAPIToken() (string, string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(buf)
+	return token, HashAPIToken(token), nil
+}
+
+// HashAPIToken 计算令牌的哈希值（数据库中只保存哈希值）
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APITokenDisplayPrefix 令牌的显示前缀（用于在列表中识别令牌）
+func APITokenDisplayPrefix(token string) string {
+	if len(token) > 12 {
+		return token[:12]
+	}
+	return token
+}
+
+// AuthenticateAPIToken 校验API令牌并记录最近使用时间
+func AuthenticateAPIToken(db *gorm.DB, token string) (*model.APIToken, error) {
+	if token == "" {
+		return nil, ErrInvalidAPIToken
+	}
+	var apiToken model.APIToken
+	if err := db.Where("token_hash = ?", HashAPIToken(token)).First(&apiToken).Error; err != nil {
+		return nil, ErrInvalidAPIToken
+	}
+	now := time.Now()
+	if apiToken.ExpiresAt != nil && apiToken.ExpiresAt.Before(now) {
+		return nil, ErrExpiredAPIToken
+	}
+	db.Model(&apiToken).UpdateColumn("last_used_at", now)
+	return &apiToken, nil
+}
+
+// HasAPITokenScope 令牌是否具有指定的权限范围
+func HasAPITokenScope(token *model.APIToken, scope string) bool {
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+	"prjflow/pkg/storage"
+)
+
+// 附件文件按内容 SHA-256 去重保存：对象键为 blobs/<哈希前两位>/<哈希><扩展名>，
+// 每个附件记录和附件版本记录持有一次引用，引用数减为 0 时删除文件和缩略图
+const attachmentBlobPrefix = "blobs"
+
+// attachmentBlobKey 内容对应的对象键
+func attachmentBlobKey(hash, fileName string) string {
+	return path.Join(attachmentBlobPrefix, hash[:2], hash+strings.ToLower(filepath.Ext(fileName)))
+}
+
+// StoreAttachmentContent 保存附件内容并持有一次引用：先写入临时文件计算哈希，
+// 已有相同内容时只增加引用数，否则写入附件存储并生成图片缩略图
+// 返回的 created 表示是否新写入了文件（调用方回滚时只需清理新写入的文件）
+func StoreAttachmentContent(db *gorm.DB, r io.Reader, fileName, mimeType string) (blob *model.AttachmentBlob, created bool, err error) {
+	tmp, err := os.CreateTemp("", "prjflow-upload-*")
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err != nil {
+		return nil, false, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	// 客户端没有提供类型时按内容识别（用于判断能否生成缩略图）
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		head := make([]byte, 512)
+		n, _ := tmp.ReadAt(head, 0)
+		mimeType = http.DetectContentType(head[:n])
+	}
+
+	if blob, err := acquireAttachmentBlob(db, hash); err == nil {
+		return blob, false, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	store, err := AttachmentStorage()
+	if err != nil {
+		return nil, false, err
+	}
+	blob = &model.AttachmentBlob{
+		Hash:     hash,
+		FilePath: attachmentBlobKey(hash, fileName),
+		FileSize: size,
+		MimeType: mimeType,
+		RefCount: 1,
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	if err := store.Put(blob.FilePath, tmp, size, mimeType); err != nil {
+		return nil, false, fmt.Errorf("保存文件失败: %w", err)
+	}
+	if err := db.Create(blob).Error; err != nil {
+		// 并发上传了相同内容：使用已保存的记录（对象键由内容决定，文件内容相同，不需要删除）
+		if existing, acquireErr := acquireAttachmentBlob(db, hash); acquireErr == nil {
+			return existing, false, nil
+		}
+		store.Delete(blob.FilePath)
+		return nil, false, fmt.Errorf("保存附件内容记录失败: %w", err)
+	}
+
+	// 生成缩略图失败不影响上传
+	if _, err := tmp.Seek(0, io.SeekStart); err == nil {
+		if err := saveAttachmentThumbnail(db, store, blob, tmp); err != nil && Logger != nil {
+			Logger.Warnf("[Attachment] Failed to generate thumbnail for %s: %v", blob.Hash, err)
+		}
+	}
+	return blob, true, nil
+}
+
+// acquireAttachmentBlob 增加已有内容的引用数
+func acquireAttachmentBlob(db *gorm.DB, hash string) (*model.AttachmentBlob, error) {
+	result := db.Model(&model.AttachmentBlob{}).Where("hash = ?", hash).UpdateColumn("ref_count", gorm.Expr("ref_count + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var blob model.AttachmentBlob
+	if err := db.Where("hash = ?", hash).First(&blob).Error; err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// AcquireAttachmentContent 为新的附件或版本记录增加一次内容引用（旧附件没有哈希时不处理）
+func AcquireAttachmentContent(db *gorm.DB, hash string) error {
+	if hash == "" {
+		return nil
+	}
+	_, err := acquireAttachmentBlob(db, hash)
+	return err
+}
+
+// ReleaseAttachmentContent 释放附件或版本记录持有的内容引用，引用数为 0 时删除文件和缩略图；
+// 没有哈希的旧附件独占文件，直接删除
+func ReleaseAttachmentContent(db *gorm.DB, hash, filePath string) error {
+	store, err := AttachmentStorage()
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return store.Delete(filePath)
+	}
+
+	if err := db.Model(&model.AttachmentBlob{}).Where("hash = ? AND ref_count > 0", hash).
+		UpdateColumn("ref_count", gorm.Expr("ref_count - 1")).Error; err != nil {
+		return err
+	}
+	var blob model.AttachmentBlob
+	if err := db.Where("hash = ?", hash).First(&blob).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if blob.RefCount > 0 {
+		return nil
+	}
+	// 只删除仍然没有引用的记录（避免与同时上传相同内容的请求冲突）
+	result := db.Where("id = ? AND ref_count <= 0", blob.ID).Delete(&model.AttachmentBlob{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	if err := store.Delete(blob.FilePath); err != nil {
+		return err
+	}
+	if blob.ThumbnailPath != "" {
+		return store.Delete(blob.ThumbnailPath)
+	}
+	return nil
+}
+
+// AttachmentThumbnail 获取附件内容的缩略图对象键，没有缩略图（非图片或旧附件）时返回空字符串
+// 支持生成缩略图但尚未生成的（如迁移前上传的图片）在首次访问时生成
+func AttachmentThumbnail(db *gorm.DB, store storage.Storage, hash string) string {
+	if hash == "" {
+		return ""
+	}
+	var blob model.AttachmentBlob
+	if err := db.Where("hash = ?", hash).First(&blob).Error; err != nil {
+		return ""
+	}
+	if blob.ThumbnailPath != "" || !CanMakeThumbnail(blob.MimeType) {
+		return blob.ThumbnailPath
+	}
+
+	reader, _, err := store.Get(blob.FilePath)
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	tmp, err := os.CreateTemp("", "prjflow-thumbnail-*")
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	if _, err := io.Copy(tmp, reader); err != nil {
+		return ""
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	if err := saveAttachmentThumbnail(db, store, &blob, tmp); err != nil {
+		if Logger != nil {
+			Logger.Warnf("[Attachment] Failed to generate thumbnail for %s: %v", blob.Hash, err)
+		}
+		return ""
+	}
+	return blob.ThumbnailPath
+}
+
+// saveAttachmentThumbnail 为图片生成缩略图保存到附件存储，并记录到内容记录中（非图片不处理）
+func saveAttachmentThumbnail(db *gorm.DB, store storage.Storage, blob *model.AttachmentBlob, src io.ReadSeeker) error {
+	if !CanMakeThumbnail(blob.MimeType) {
+		return nil
+	}
+	thumbnail, ext, err := MakeThumbnail(src)
+	if err != nil {
+		return err
+	}
+	key := path.Join("thumbnails", blob.Hash[:2], blob.Hash+ext)
+	if err := store.Put(key, bytes.NewReader(thumbnail), int64(len(thumbnail)), ThumbnailContentType(ext)); err != nil {
+		return err
+	}
+	blob.ThumbnailPath = key
+	return db.Model(blob).UpdateColumn("thumbnail_path", key).Error
+}
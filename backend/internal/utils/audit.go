@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"prjflow/internal/model"
@@ -13,6 +16,9 @@ import (
 // AuditDB 审计日志数据库连接（如果配置了独立数据库则使用，否则为 nil 表示使用主数据库）
 var AuditDB *gorm.DB
 
+// auditChainMu 串行写入审计日志，保证哈希链按写入顺序连接（单进程部署）
+var auditChainMu sync.Mutex
+
 // RecordAuditLog 同步记录审计日志（直接写入，简单可靠）
 // 如果配置了独立审计日志数据库（AuditDB），则使用独立数据库；否则使用传入的 db
 func RecordAuditLog(db *gorm.DB, userID uint, username, actionType, resourceType string, resourceID uint, c *gin.Context, success bool, errorMsg, comment string) {
@@ -53,7 +59,8 @@ func recordAuditLogSync(db *gorm.DB, userID uint, username, actionType, resource
 		Success:      success,
 		ErrorMsg:     errorMsg,
 		Comment:      comment,
-		CreatedAt:    time.Now(),
+		// 截断到毫秒，保证不同数据库读回的时间与计算哈希时一致
+		CreatedAt: time.Now().Truncate(time.Millisecond),
 	}
 
 	// 从gin.Context获取请求信息
@@ -71,13 +78,67 @@ func recordAuditLogSync(db *gorm.DB, userID uint, username, actionType, resource
 		}
 	}
 
+	auditChainMu.Lock()
+	defer auditChainMu.Unlock()
+
+	auditLog.PrevHash = lastAuditHash(db)
+	auditLog.Hash = AuditLogHash(&auditLog)
 	if err := db.Create(&auditLog).Error; err != nil {
 		return err
 	}
+	// 哈希链的第一条记录：记录链的起点
+	if auditLog.PrevHash == "" {
+		if err := ensureAuditChainGenesis(db); err != nil && Logger != nil {
+			Logger.Warnf("记录审计日志哈希链起点失败: %v", err)
+		}
+	}
 
 	return nil
 }
 
+// AuditLogHash 计算审计日志的哈希（包含上一条记录的哈希）
+func AuditLogHash(log *model.AuditLog) string {
+	payload, _ := json.Marshal([]interface{}{
+		log.PrevHash, log.UserID, log.Username, log.ActionType, log.ResourceType, log.ResourceID,
+		log.IPAddress, log.Path, log.Method, log.Params, log.Success, log.ErrorMsg, log.Comment,
+		log.CreatedAt.UnixMilli(),
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// lastAuditHash 哈希链中最后一条记录的哈希（包括已归档的记录）
+func lastAuditHash(db *gorm.DB) string {
+	var last model.AuditLog
+	var archived model.ArchivedAuditLog
+	db.Select("id", "hash").Where("hash <> ?", "").Order("id DESC").Limit(1).Find(&last)
+	db.Select("id", "hash").Where("hash <> ?", "").Order("id DESC").Limit(1).Find(&archived)
+	if archived.ID > last.ID {
+		return archived.Hash
+	}
+	return last.Hash
+}
+
+// ensureAuditChainGenesis 记录哈希链起点（第一条上一条哈希为空的链上记录，包括已归档的记录），已记录时跳过
+func ensureAuditChainGenesis(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&model.AuditChainState{}).Count(&count).Error; err != nil || count > 0 {
+		return err
+	}
+	var first model.AuditLog
+	var archived model.ArchivedAuditLog
+	db.Select("id").Where("hash <> ? AND prev_hash = ?", "", "").Order("id ASC").Limit(1).Find(&first)
+	db.Select("id").Where("hash <> ? AND prev_hash = ?", "", "").Order("id ASC").Limit(1).Find(&archived)
+	genesisID := first.ID
+	if archived.ID != 0 && (genesisID == 0 || archived.ID < genesisID) {
+		genesisID = archived.ID
+	}
+	if genesisID == 0 {
+		return nil
+	}
+	return db.Create(&model.AuditChainState{GenesisID: genesisID}).Error
+}
+
 // getRequestParams 获取请求参数（仅记录关键参数）
 func getRequestParams(c *gin.Context) map[string]interface{} {
 	params := make(map[string]interface{})
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// AuditRetentionKey 审计日志保留天数的系统配置项（JSON：操作类型 -> 天数，default 为默认值）
+const AuditRetentionKey = "audit_retention"
+
+// DefaultAuditRetentionDays 未配置时审计日志的默认保留天数
+const DefaultAuditRetentionDays = 30
+
+// AuditArchiveDir 审计日志归档目录（数据目录下的 audit-archives）
+func AuditArchiveDir(dataDir string) string {
+	return filepath.Join(dataDir, "audit-archives")
+}
+
+// DefaultAuditArchiveDir 默认的审计日志归档目录：SQLite 数据库文件所在目录，其他数据库为当前目录
+func DefaultAuditArchiveDir() string {
+	if config.AppConfig != nil && config.AppConfig.Database.Type == "sqlite" && config.AppConfig.Database.DSN != "" {
+		return AuditArchiveDir(filepath.Dir(config.AppConfig.Database.DSN))
+	}
+	return AuditArchiveDir(".")
+}
+
+// AuditRetention 审计日志保留配置：按操作类型配置保留天数，0 表示永久保留
+type AuditRetention struct {
+	DefaultDays int            `json:"default_days"`
+	ActionDays  map[string]int `json:"action_days"`
+}
+
+// Days 操作类型的保留天数
+func (r AuditRetention) Days(actionType string) int {
+	if days, ok := r.ActionDays[actionType]; ok {
+		return days
+	}
+	return r.DefaultDays
+}
+
+// LoadAuditRetention 读取审计日志保留配置
+func LoadAuditRetention(db *gorm.DB) AuditRetention {
+	retention := AuditRetention{DefaultDays: DefaultAuditRetentionDays, ActionDays: map[string]int{}}
+	var item model.SystemConfig
+	if err := db.Where("key = ?", AuditRetentionKey).First(&item).Error; err != nil {
+		return retention
+	}
+	if err := json.Unmarshal([]byte(item.Value), &retention); err != nil && Logger != nil {
+		Logger.Warnf("解析审计日志保留配置失败: %v", err)
+	}
+	if retention.ActionDays == nil {
+		retention.ActionDays = map[string]int{}
+	}
+	return retention
+}
+
+// SaveAuditRetention 保存审计日志保留配置
+func SaveAuditRetention(db *gorm.DB, retention AuditRetention) error {
+	if retention.DefaultDays < 0 {
+		return fmt.Errorf("保留天数不能为负数")
+	}
+	for action, days := range retention.ActionDays {
+		if days < 0 {
+			return fmt.Errorf("操作类型 %s 的保留天数不能为负数", action)
+		}
+	}
+	value, err := json.Marshal(retention)
+	if err != nil {
+		return err
+	}
+	item := model.SystemConfig{Key: AuditRetentionKey}
+	return db.Where("key = ?", AuditRetentionKey).
+		Assign(model.SystemConfig{Value: string(value), Type: "json"}).
+		FirstOrCreate(&item).Error
+}
+
+// ArchiveExpiredAuditLogs 将过期的审计日志写入压缩归档文件（JSON Lines + gzip）后从数据库中移除，
+// 并保留哈希链信息以便继续校验；返回归档的记录数和归档文件路径（没有过期记录时为空）
+func ArchiveExpiredAuditLogs(auditDB *gorm.DB, retention AuditRetention, archiveDir string) (int, string, error) {
+	now := time.Now()
+	var actionTypes []string
+	if err := auditDB.Model(&model.AuditLog{}).Distinct("action_type").Pluck("action_type", &actionTypes).Error; err != nil {
+		return 0, "", err
+	}
+
+	var expired []model.AuditLog
+	for _, actionType := range actionTypes {
+		days := retention.Days(actionType)
+		if days <= 0 {
+			continue
+		}
+		var logs []model.AuditLog
+		if err := auditDB.Where("action_type = ? AND created_at < ?", actionType, now.AddDate(0, 0, -days)).Find(&logs).Error; err != nil {
+			return 0, "", err
+		}
+		expired = append(expired, logs...)
+	}
+	if len(expired) == 0 {
+		return 0, "", nil
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].ID < expired[j].ID })
+
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return 0, "", fmt.Errorf("failed to create audit archive directory: %w", err)
+	}
+	fileName := fmt.Sprintf("audit_%s.jsonl.gz", now.Format("20060102_150405"))
+	archivePath := filepath.Join(archiveDir, fileName)
+	if err := writeAuditArchive(archivePath, expired); err != nil {
+		os.Remove(archivePath)
+		return 0, "", err
+	}
+
+	err := auditDB.Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint, 0, len(expired))
+		for _, log := range expired {
+			archived := model.ArchivedAuditLog{
+				ID: log.ID, ActionType: log.ActionType, LoggedAt: log.CreatedAt,
+				PrevHash: log.PrevHash, Hash: log.Hash, ArchiveFile: fileName, ArchivedAt: now,
+			}
+			if err := tx.Create(&archived).Error; err != nil {
+				return err
+			}
+			ids = append(ids, log.ID)
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.AuditLog{}).Error
+	})
+	if err != nil {
+		os.Remove(archivePath)
+		return 0, "", err
+	}
+
+	if Logger != nil {
+		Logger.Infof("归档审计日志: %d 条记录写入 %s", len(expired), archivePath)
+	}
+	return len(expired), archivePath, nil
+}
+
+func writeAuditArchive(path string, logs []model.AuditLog) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create audit archive: %w", err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	encoder := json.NewEncoder(gz)
+	for i := range logs {
+		if err := encoder.Encode(&logs[i]); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// ReadAuditArchive 读取归档文件中的审计日志
+func ReadAuditArchive(path string) ([]model.AuditLog, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var logs []model.AuditLog
+	decoder := json.NewDecoder(gz)
+	for decoder.More() {
+		var log model.AuditLog
+		if err := decoder.Decode(&log); err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+// AuditChainIssue 哈希链校验发现的问题
+type AuditChainIssue struct {
+	ID      uint   `json:"id"`
+	Type    string `json:"type"` // modified（内容被修改）, gap（前面的记录被删除或插入）, unsealed（链中出现没有哈希的记录）
+	Message string `json:"message"`
+}
+
+// AuditChainReport 哈希链校验结果
+type AuditChainReport struct {
+	Valid      bool              `json:"valid"`
+	Total      int               `json:"total"`    // 数据库中的审计日志数
+	Verified   int               `json:"verified"` // 通过校验的记录数
+	Archived   int               `json:"archived"` // 已归档的记录数
+	Legacy     int               `json:"legacy"`   // 启用哈希链之前的历史记录数（无法校验）
+	Issues     []AuditChainIssue `json:"issues"`   // 最多返回前 100 个问题
+	IssueCount int               `json:"issue_count"`
+	VerifiedAt time.Time         `json:"verified_at"`
+}
+
+const maxAuditChainIssues = 100
+
+func (r *AuditChainReport) addIssue(id uint, issueType, message string) {
+	r.IssueCount++
+	if len(r.Issues) < maxAuditChainIssues {
+		r.Issues = append(r.Issues, AuditChainIssue{ID: id, Type: issueType, Message: message})
+	}
+}
+
+// VerifyAuditChain 按ID顺序校验审计日志哈希链（包括已归档记录保留的链信息）
+// 哈希链起点之后没有哈希的记录、上一条哈希与前一条链上记录不一致的记录（包括第一条链上记录）都视为异常
+// 说明：删除链末尾的记录无法通过哈希链本身发现
+func VerifyAuditChain(auditDB *gorm.DB) (*AuditChainReport, error) {
+	report := &AuditChainReport{Issues: make([]AuditChainIssue, 0), VerifiedAt: time.Now()}
+
+	// 哈希链起点（没有记录时只能以第一条链上记录为起点）
+	var state model.AuditChainState
+	auditDB.Order("id ASC").Limit(1).Find(&state)
+
+	var archived []model.ArchivedAuditLog
+	if err := auditDB.Select("id", "prev_hash", "hash").Order("id ASC").Find(&archived).Error; err != nil {
+		return nil, err
+	}
+	report.Archived = len(archived)
+
+	prev := ""
+	started := false
+	next := 0
+	// 归档记录按ID插入到链中
+	consumeArchived := func(before uint) {
+		for ; next < len(archived) && archived[next].ID < before; next++ {
+			entry := archived[next]
+			if (started || entry.Hash != "") && entry.PrevHash != prev {
+				report.addIssue(entry.ID, "gap", fmt.Sprintf("归档记录 #%d 与上一条记录不连续", entry.ID))
+			}
+			if entry.Hash != "" {
+				prev, started = entry.Hash, true
+			}
+		}
+	}
+
+	var batch []model.AuditLog
+	err := auditDB.Model(&model.AuditLog{}).Order("id ASC").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			log := &batch[i]
+			report.Total++
+			consumeArchived(log.ID)
+			if log.Hash == "" {
+				if started || (state.GenesisID != 0 && log.ID >= state.GenesisID) {
+					report.addIssue(log.ID, "unsealed", fmt.Sprintf("记录 #%d 没有哈希，可能是绕过系统直接写入或哈希被清除", log.ID))
+				} else {
+					report.Legacy++
+				}
+				continue
+			}
+			ok := true
+			if AuditLogHash(log) != log.Hash {
+				report.addIssue(log.ID, "modified", fmt.Sprintf("记录 #%d 的内容与哈希不一致，可能被修改", log.ID))
+				ok = false
+			}
+			if log.PrevHash != prev {
+				report.addIssue(log.ID, "gap", fmt.Sprintf("记录 #%d 与上一条记录不连续，之前的记录可能被删除或插入", log.ID))
+				ok = false
+			}
+			if ok {
+				report.Verified++
+			}
+			prev, started = log.Hash, true
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	consumeArchived(^uint(0))
+
+	report.Valid = report.IssueCount == 0
+	return report, nil
+}
@@ -3,413 +3,475 @@ package utils
 import (
 	"archive/zip"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/pkg/storage"
+
 	"gorm.io/gorm"
 )
 
-// BackupDatabase 备份数据库（改进版，使用 VACUUM INTO 确保数据一致性）
-// 同时备份审计数据库，并在备份前清理过期的审计日志
-func BackupDatabase(db *gorm.DB) error {
-	// 加载配置
-	if err := config.LoadConfig(""); err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
-	}
+// 备份文件格式：ZIP 压缩包，包含清单、主数据库、审计数据库和上传文件
+const (
+	BackupFormatVersion = 1
+	BackupManifestName  = "manifest.json"
+	backupUploadsPrefix = "uploads/"
+)
 
-	// 检查数据库类型，只支持 SQLite 备份
-	if config.AppConfig.Database.Type != "sqlite" {
-		return fmt.Errorf("backup only supports SQLite database, current type: %s", config.AppConfig.Database.Type)
-	}
+// BackupRetentionKey 备份保留天数的系统配置项（0 表示永久保留）
+const BackupRetentionKey = "backup_retention_days"
+
+// DefaultBackupRetentionDays 未配置时备份的默认保留天数
+const DefaultBackupRetentionDays = 7
+
+// BackupManifest 备份清单：记录备份内容及每个文件的校验和
+type BackupManifest struct {
+	FormatVersion     int               `json:"format_version"`
+	CreatedAt         time.Time         `json:"created_at"`
+	DatabaseType      string            `json:"database_type"`
+	AuditDatabaseType string            `json:"audit_database_type,omitempty"` // 为空表示审计日志保存在主数据库中
+	UploadStorage     string            `json:"upload_storage,omitempty"` // 附件存储类型，对象存储中的附件不包含在备份中
+	UploadFiles       int               `json:"upload_files"`
+	UploadSize        int64             `json:"upload_size"`
+	Files             []BackupFileEntry `json:"files"`
+}
 
-	// 备份前清理过期的审计日志（保留30天）
-	// 使用审计日志数据库（如果配置了）或主数据库
-	auditDB := db
-	if AuditDB != nil {
-		auditDB = AuditDB
-	}
-	if Logger != nil {
-		Logger.Info("[Backup] Cleaning up old audit logs before backup (retention: 30 days)")
-	}
-	if err := CleanupOldAuditLogs(auditDB, 30); err != nil {
-		if Logger != nil {
-			Logger.Warnf("[Backup] Warning: Failed to cleanup old audit logs: %v", err)
-		}
-		// 清理失败不影响备份，继续执行
-	}
+// BackupFileEntry 备份中的文件及其 SHA-256 校验和
+type BackupFileEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
 
-	// 获取数据库文件路径
-	dbPath := config.AppConfig.Database.DSN
-	if !filepath.IsAbs(dbPath) {
-		// 相对路径，从当前工作目录或可执行文件目录查找
-		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-			// 尝试从可执行文件目录查找
-			exePath, err := os.Executable()
-			if err == nil {
-				exeDir := filepath.Dir(exePath)
-				absPath := filepath.Join(exeDir, dbPath)
-				if _, err := os.Stat(absPath); err == nil {
-					dbPath = absPath
-				}
-			}
+// File 按名称查找备份中的文件
+func (m *BackupManifest) File(name string) *BackupFileEntry {
+	for i := range m.Files {
+		if m.Files[i].Name == name {
+			return &m.Files[i]
 		}
 	}
+	return nil
+}
 
-	// 检查数据库文件是否存在
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		return fmt.Errorf("database file not found: %s", dbPath)
-	}
+// BackupInfo 备份文件信息
+type BackupInfo struct {
+	Name      string          `json:"name"`
+	Size      int64           `json:"size"`
+	CreatedAt time.Time       `json:"created_at"`
+	Manifest  *BackupManifest `json:"manifest,omitempty"` // 旧版本备份没有清单
+}
 
-	// 创建备份目录（在数据库文件同目录下）
-	dbDir := filepath.Dir(dbPath)
-	backupDir := filepath.Join(dbDir, "backups")
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+// backupDumpName 数据库在备份中的文件名：SQLite 为数据库文件，其他数据库为 SQL 逻辑导出
+func backupDumpName(dbType, base string) string {
+	if dbType == "sqlite" {
+		return base + ".db"
 	}
+	return base + ".sql"
+}
 
-	// 生成备份文件名（带时间戳）
-	timestamp := time.Now().Format("20060102_150405")
-	backupFileName := fmt.Sprintf("data_%s.db", timestamp)
-	backupPath := filepath.Join(backupDir, backupFileName)
-
-	// 获取绝对路径（VACUUM INTO 需要绝对路径）
-	absBackupPath, err := filepath.Abs(backupPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+// ResolveSQLitePath 解析 SQLite DSN 对应的数据库文件路径（去掉连接参数，相对路径依次从当前目录和可执行文件目录查找）
+// 内存数据库返回空字符串
+func ResolveSQLitePath(dsn string) string {
+	path := strings.TrimPrefix(dsn, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
 	}
-
-	// 转义路径中的单引号
-	escapedPath := strings.ReplaceAll(absBackupPath, "'", "''")
-
-	startTime := time.Now()
-	if Logger != nil {
-		Logger.Infof("[Backup] Starting database backup at %s: %s -> %s", 
-			startTime.Format("2006-01-02 15:04:05"), dbPath, absBackupPath)
+	if path == "" || path == ":memory:" {
+		return ""
 	}
-
-	// 尝试使用 VACUUM INTO 命令创建备份（SQLite 3.27.0+）
-	// 这会创建一个一致的数据库快照，支持在线备份
-	sql := fmt.Sprintf("VACUUM INTO '%s'", escapedPath)
-	if err := db.Exec(sql).Error; err != nil {
-		// 如果 VACUUM INTO 失败，回退到文件复制方式（带警告）
-		if Logger != nil {
-			Logger.Warnf("[Backup] Warning: VACUUM INTO failed: %v, falling back to file copy", err)
-		}
-		if err := copyDatabaseFile(dbPath, absBackupPath); err != nil {
-			return fmt.Errorf("failed to backup database: %w", err)
-		}
-		if Logger != nil {
-			Logger.Info("[Backup] Backup created using file copy method")
+	if !filepath.IsAbs(path) {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			// 尝试从可执行文件目录查找
+			if exePath, err := os.Executable(); err == nil {
+				absPath := filepath.Join(filepath.Dir(exePath), path)
+				if _, err := os.Stat(absPath); err == nil {
+					path = absPath
+				}
+			}
 		}
-	} else {
-		if Logger != nil {
-			Logger.Info("[Backup] Backup created using VACUUM INTO method")
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
 		}
 	}
+	return path
+}
 
-	// 验证备份文件是否存在
-	if _, err := os.Stat(absBackupPath); os.IsNotExist(err) {
-		return fmt.Errorf("backup file was not created: %s", absBackupPath)
+// sqliteAuditDBPath 审计数据库文件路径：未配置时使用与主数据库同一目录下的 audit.db（与 InitAuditDB 一致）
+func sqliteAuditDBPath() string {
+	if dsn := config.AppConfig.AuditDatabase.DSN; dsn != "" {
+		return ResolveSQLitePath(dsn)
 	}
-
-	// 获取文件大小
-	fileInfo, err := os.Stat(absBackupPath)
-	if err != nil {
-		return fmt.Errorf("failed to get backup file info: %w", err)
+	if mainPath := ResolveSQLitePath(config.AppConfig.Database.DSN); mainPath != "" {
+		return filepath.Join(filepath.Dir(mainPath), "audit.db")
 	}
+	return ResolveSQLitePath("audit.db")
+}
 
-	// 验证备份文件大小（不应该为0）
-	if fileInfo.Size() == 0 {
-		os.Remove(absBackupPath)
-		return fmt.Errorf("backup file is empty: %s", absBackupPath)
+// auditDatabaseType 审计数据库类型（未配置时与主数据库相同）
+func auditDatabaseType() string {
+	if config.AppConfig.AuditDatabase.Type != "" {
+		return config.AppConfig.AuditDatabase.Type
 	}
+	return config.AppConfig.Database.Type
+}
 
-	// 验证备份文件完整性（尝试打开SQLite文件）
-	if err := verifyBackupFile(absBackupPath); err != nil {
-		if Logger != nil {
-			Logger.Warnf("[Backup] Warning: Backup file verification failed: %v", err)
-		}
-		// 不返回错误，但记录警告（因为某些情况下验证可能失败但不影响备份可用性）
-	} else {
-		if Logger != nil {
-			Logger.Info("[Backup] ✓ Backup file verified successfully")
+// BackupDir 备份目录：SQLite 为数据库文件所在目录下的 backups，其他数据库为当前目录下的 backups
+func BackupDir() string {
+	if config.AppConfig != nil && config.AppConfig.Database.Type == "sqlite" {
+		if dbPath := ResolveSQLitePath(config.AppConfig.Database.DSN); dbPath != "" {
+			return filepath.Join(filepath.Dir(dbPath), "backups")
 		}
 	}
+	return "backups"
+}
 
-	fileSize := float64(fileInfo.Size())
-	unit := "B"
-	if fileSize >= 1024*1024 {
-		fileSize /= 1024 * 1024
-		unit = "MB"
-	} else if fileSize >= 1024 {
-		fileSize /= 1024
-		unit = "KB"
+// LoadBackupRetentionDays 读取备份保留天数
+func LoadBackupRetentionDays(db *gorm.DB) int {
+	var item model.SystemConfig
+	if err := db.Where("key = ?", BackupRetentionKey).First(&item).Error; err != nil {
+		return DefaultBackupRetentionDays
 	}
-
-	duration := time.Since(startTime)
-	if Logger != nil {
-		Logger.Infof("[Backup] ✓ Main database backup created: %s (%.2f %s) in %v", absBackupPath, fileSize, unit, duration)
+	days, err := strconv.Atoi(item.Value)
+	if err != nil || days < 0 {
+		return DefaultBackupRetentionDays
 	}
+	return days
+}
 
-	// 备份审计数据库（如果配置了独立审计数据库）
-	var auditBackupPath string
-	if AuditDB != nil && config.AppConfig.AuditDatabase.Type == "sqlite" {
-		auditBackupPath, err = backupAuditDatabaseToFile(AuditDB, backupDir, timestamp)
-		if err != nil {
-			if Logger != nil {
-				Logger.Warnf("[Backup] Warning: Failed to backup audit database: %v", err)
-			}
-			// 审计数据库备份失败不影响主数据库备份，继续执行
-			auditBackupPath = "" // 标记为失败
-		}
+// SaveBackupRetentionDays 保存备份保留天数
+func SaveBackupRetentionDays(db *gorm.DB, days int) error {
+	if days < 0 {
+		return fmt.Errorf("保留天数不能为负数")
 	}
+	value := strconv.Itoa(days)
+	item := model.SystemConfig{Key: BackupRetentionKey}
+	return db.Where("key = ?", BackupRetentionKey).
+		Assign(model.SystemConfig{Value: value, Type: "number"}).
+		FirstOrCreate(&item).Error
+}
 
-	// 计算总原始大小（在删除文件前）
-	totalOriginalSize := float64(fileInfo.Size())
-	var auditFileSize int64
-	if auditBackupPath != "" {
-		if auditInfo, err := os.Stat(auditBackupPath); err == nil {
-			auditFileSize = auditInfo.Size()
-			totalOriginalSize += float64(auditFileSize)
+// BackupDatabase 创建完整备份（主数据库、审计数据库和上传文件），并按配置的保留天数清理旧备份
+func BackupDatabase(db *gorm.DB) error {
+	// 加载配置（命令行备份时可能尚未加载）
+	if config.AppConfig == nil {
+		if err := config.LoadConfig(""); err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
 		}
 	}
 
-	// 将两个数据库文件打包成一个ZIP文件
-	zipFileName := fmt.Sprintf("backup_%s.zip", timestamp)
-	zipPath := filepath.Join(backupDir, zipFileName)
-	if err := createBackupZip(absBackupPath, auditBackupPath, zipPath); err != nil {
+	startTime := time.Now()
+	backupDir := BackupDir()
+	info, err := CreateBackup(db, AuditDB, backupDir)
+	if err != nil {
 		if Logger != nil {
-			Logger.Errorf("[Backup] Failed to create backup ZIP: %v", err)
+			Logger.Errorf("[Backup] Failed to create backup: %v", err)
 		}
-		return fmt.Errorf("failed to create backup ZIP: %w", err)
+		return err
 	}
-
-	// 删除临时的.db文件
-	os.Remove(absBackupPath)
-	if auditBackupPath != "" {
-		os.Remove(auditBackupPath)
+	if Logger != nil {
+		Logger.Infof("[Backup] ✓ Backup created: %s (%s, %d upload files)",
+			filepath.Join(backupDir, info.Name), formatSize(info.Size), info.Manifest.UploadFiles)
 	}
 
-	// 获取ZIP文件大小并计算压缩比例
-	zipInfo, err := os.Stat(zipPath)
-	if err == nil {
-		zipSize := float64(zipInfo.Size())
-		zipUnit := "B"
-		if zipSize >= 1024*1024 {
-			zipSize /= 1024 * 1024
-			zipUnit = "MB"
-		} else if zipSize >= 1024 {
-			zipSize /= 1024
-			zipUnit = "KB"
-		}
-		compressionRatio := float64(zipInfo.Size()) / totalOriginalSize * 100
+	// 配置了对象存储时上传备份
+	if err := StoreBackup(backupDir, info.Name); err != nil {
 		if Logger != nil {
-			Logger.Infof("[Backup] ✓ Backup ZIP created: %s (%.2f %s, %.1f%% of original size)", 
-				zipPath, zipSize, zipUnit, compressionRatio)
+			Logger.Errorf("[Backup] Failed to store backup: %v", err)
 		}
+		return err
+	}
+	store, err := BackupStorage()
+	if err != nil {
+		return err
 	}
 
-	// 清理旧备份（保留最近7天）
-	if err := cleanupOldBackups(backupDir, 7); err != nil {
+	// 清理超过保留天数的旧备份
+	if err := cleanupOldBackups(store, LoadBackupRetentionDays(db)); err != nil {
 		if Logger != nil {
 			Logger.Warnf("[Backup] Warning: Failed to cleanup old backups: %v", err)
 		}
 	}
 
 	// 显示备份统计
-	backupCount, totalSize, err := getBackupStats(backupDir)
+	backupCount, totalSize, err := getBackupStats(store)
 	if err == nil {
 		if Logger != nil {
 			Logger.Infof("[Backup] Statistics: %d backups, total size: %s", backupCount, formatSize(totalSize))
 		}
 	}
 
-	totalDuration := time.Since(startTime)
 	if Logger != nil {
-		Logger.Infof("[Backup] ✓ Backup completed successfully in %v", totalDuration)
+		Logger.Infof("[Backup] ✓ Backup completed successfully in %v", time.Since(startTime))
 	}
-
 	return nil
 }
 
-// backupAuditDatabaseToFile 备份审计数据库到文件，返回备份文件路径
-func backupAuditDatabaseToFile(auditDB *gorm.DB, backupDir string, timestamp string) (string, error) {
-	// 获取审计数据库文件路径
-	auditDBPath := config.AppConfig.AuditDatabase.DSN
-	if auditDBPath == "" {
-		// 如果未配置，使用与主数据库同一目录下的 audit.db
-		mainDBPath := config.AppConfig.Database.DSN
-		if mainDBPath != "" {
-			// 获取主数据库的目录
-			mainDir := filepath.Dir(mainDBPath)
-			// 如果主数据库路径是相对路径，需要处理
-			if !filepath.IsAbs(mainDBPath) {
-				// 尝试从当前工作目录或可执行文件目录查找
-				if _, err := os.Stat(mainDBPath); os.IsNotExist(err) {
-					// 尝试从可执行文件目录查找
-					exePath, err := os.Executable()
-					if err == nil {
-						exeDir := filepath.Dir(exePath)
-						absPath := filepath.Join(exeDir, mainDBPath)
-						if _, err := os.Stat(absPath); err == nil {
-							mainDBPath = absPath
-						}
-					}
-				}
-				// 获取绝对路径
-				if absPath, err := filepath.Abs(mainDBPath); err == nil {
-					mainDBPath = absPath
-				}
-			}
-			mainDir = filepath.Dir(mainDBPath)
-			auditDBPath = filepath.Join(mainDir, "audit.db")
-		} else {
-			// 如果主数据库也没有配置，使用默认的 audit.db
-			auditDBPath = "audit.db"
-		}
-	}
+// backupSource 需要写入备份的文件
+type backupSource struct {
+	name string
+	path string
+}
 
-	// 处理相对路径
-	if !filepath.IsAbs(auditDBPath) {
-		if _, err := os.Stat(auditDBPath); os.IsNotExist(err) {
-			// 尝试从可执行文件目录查找
-			exePath, err := os.Executable()
-			if err == nil {
-				exeDir := filepath.Dir(exePath)
-				absPath := filepath.Join(exeDir, auditDBPath)
-				if _, err := os.Stat(absPath); err == nil {
-					auditDBPath = absPath
-				}
-			}
-		}
-		// 获取绝对路径
-		if absPath, err := filepath.Abs(auditDBPath); err == nil {
-			auditDBPath = absPath
-		}
+// CreateBackup 在备份目录中创建完整备份 backup_<时间>.zip：主数据库（SQLite 快照或 MySQL 逻辑导出）、
+// 独立的审计数据库、上传文件目录，以及记录校验和的清单；备份前归档过期的审计日志
+// auditDB 为空或与 db 相同时审计日志随主数据库备份
+func CreateBackup(db, auditDB *gorm.DB, backupDir string) (*BackupInfo, error) {
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if auditDB == db {
+		auditDB = nil
 	}
 
-	// 检查审计数据库文件是否存在
-	if _, err := os.Stat(auditDBPath); os.IsNotExist(err) {
+	// 备份前归档过期的审计日志（按系统配置中各操作类型的保留天数）
+	logDB := db
+	if auditDB != nil {
+		logDB = auditDB
+	}
+	if _, _, err := ArchiveExpiredAuditLogs(logDB, LoadAuditRetention(db), AuditArchiveDir(filepath.Dir(backupDir))); err != nil {
 		if Logger != nil {
-			Logger.Warnf("[Backup] Audit database file not found: %s, skipping audit backup", auditDBPath)
+			Logger.Warnf("[Backup] Warning: Failed to archive expired audit logs: %v", err)
 		}
-		return "", nil // 文件不存在不算错误，返回空路径
+		// 归档失败不影响备份，继续执行
 	}
 
-	// 生成备份文件名
-	backupFileName := fmt.Sprintf("audit_%s.db", timestamp)
-	backupPath := filepath.Join(backupDir, backupFileName)
+	now := time.Now()
+	timestamp := now.Format("20060102_150405")
+	name := fmt.Sprintf("backup_%s.zip", timestamp)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(backupDir, name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("backup_%s_%d.zip", timestamp, i)
+	}
 
-	// 获取绝对路径（VACUUM INTO 需要绝对路径）
-	absBackupPath, err := filepath.Abs(backupPath)
+	workDir, err := os.MkdirTemp(backupDir, ".tmp_")
 	if err != nil {
-		return "", fmt.Errorf("failed to get absolute path: %w", err)
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
 	}
+	defer os.RemoveAll(workDir)
 
-	// 转义路径中的单引号
-	escapedPath := strings.ReplaceAll(absBackupPath, "'", "''")
+	manifest := &BackupManifest{
+		FormatVersion: BackupFormatVersion,
+		CreatedAt:     now,
+		DatabaseType:  db.Dialector.Name(),
+		Files:         make([]BackupFileEntry, 0),
+	}
+	var sources []backupSource
 
-	auditStartTime := time.Now()
-	if Logger != nil {
-		Logger.Infof("[Backup] Starting audit database backup at %s: %s -> %s",
-			auditStartTime.Format("2006-01-02 15:04:05"), auditDBPath, absBackupPath)
+	mainDBPath := ""
+	if manifest.DatabaseType == "sqlite" {
+		mainDBPath = ResolveSQLitePath(config.AppConfig.Database.DSN)
 	}
+	dumpPath, err := dumpDatabase(db, workDir, "data", mainDBPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to backup database: %w", err)
+	}
+	sources = append(sources, backupSource{name: backupDumpName(manifest.DatabaseType, "data"), path: dumpPath})
 
-	// 尝试使用 VACUUM INTO 命令创建备份
-	sql := fmt.Sprintf("VACUUM INTO '%s'", escapedPath)
-	if err := auditDB.Exec(sql).Error; err != nil {
-		// 如果 VACUUM INTO 失败，回退到文件复制方式
-		if Logger != nil {
-			Logger.Warnf("[Backup] Warning: VACUUM INTO failed for audit database: %v, falling back to file copy", err)
-		}
-		if err := copyDatabaseFile(auditDBPath, absBackupPath); err != nil {
-			return "", fmt.Errorf("failed to backup audit database: %w", err)
-		}
-		if Logger != nil {
-			Logger.Info("[Backup] Audit database backup created using file copy method")
+	if auditDB != nil {
+		manifest.AuditDatabaseType = auditDB.Dialector.Name()
+		auditDBPath := ""
+		if manifest.AuditDatabaseType == "sqlite" {
+			auditDBPath = sqliteAuditDBPath()
 		}
-	} else {
-		if Logger != nil {
-			Logger.Info("[Backup] Audit database backup created using VACUUM INTO method")
+		dumpPath, err := dumpDatabase(auditDB, workDir, "audit", auditDBPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to backup audit database: %w", err)
 		}
+		sources = append(sources, backupSource{name: backupDumpName(manifest.AuditDatabaseType, "audit"), path: dumpPath})
 	}
 
-	// 验证备份文件是否存在
-	if _, err := os.Stat(absBackupPath); os.IsNotExist(err) {
-		return "", fmt.Errorf("audit database backup file was not created: %s", absBackupPath)
+	// 对象存储中的附件由存储服务保证持久性，不包含在备份中
+	manifest.UploadStorage = storageType()
+	uploadDir := ""
+	if manifest.UploadStorage == "local" {
+		uploadDir = UploadStoragePath()
 	}
 
-	// 获取文件大小
-	fileInfo, err := os.Stat(absBackupPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to get audit backup file info: %w", err)
+	zipPath := filepath.Join(backupDir, name)
+	tmpZipPath := zipPath + ".tmp"
+	if err := writeBackupArchive(tmpZipPath, manifest, sources, uploadDir, backupDir); err != nil {
+		os.Remove(tmpZipPath)
+		return nil, fmt.Errorf("failed to create backup archive: %w", err)
+	}
+	if err := os.Rename(tmpZipPath, zipPath); err != nil {
+		os.Remove(tmpZipPath)
+		return nil, fmt.Errorf("failed to create backup archive: %w", err)
 	}
 
-	// 验证备份文件大小
-	if fileInfo.Size() == 0 {
-		os.Remove(absBackupPath)
-		return "", fmt.Errorf("audit database backup file is empty: %s", absBackupPath)
+	info, err := os.Stat(zipPath)
+	if err != nil {
+		return nil, err
 	}
+	return &BackupInfo{Name: name, Size: info.Size(), CreatedAt: now, Manifest: manifest}, nil
+}
 
-	// 验证备份文件完整性
-	if err := verifyBackupFile(absBackupPath); err != nil {
-		if Logger != nil {
-			Logger.Warnf("[Backup] Warning: Audit database backup file verification failed: %v", err)
+// dumpDatabase 将数据库导出到工作目录：SQLite 生成一致的快照，MySQL 生成逻辑导出（SQL）
+// sqlitePath 为 SQLite 数据库文件路径，用于 VACUUM INTO 失败时回退到文件复制
+func dumpDatabase(db *gorm.DB, workDir, base, sqlitePath string) (string, error) {
+	dbType := db.Dialector.Name()
+	dumpPath := filepath.Join(workDir, backupDumpName(dbType, base))
+	switch dbType {
+	case "sqlite":
+		return dumpPath, snapshotSQLite(db, sqlitePath, dumpPath)
+	case "mysql":
+		file, err := os.Create(dumpPath)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		if err := DumpMySQL(db, file); err != nil {
+			return "", err
 		}
+		return dumpPath, file.Sync()
+	default:
+		return "", fmt.Errorf("backup does not support database type: %s", dbType)
 	}
+}
 
-	fileSize := float64(fileInfo.Size())
-	unit := "B"
-	if fileSize >= 1024*1024 {
-		fileSize /= 1024 * 1024
-		unit = "MB"
-	} else if fileSize >= 1024 {
-		fileSize /= 1024
-		unit = "KB"
+// snapshotSQLite 使用 VACUUM INTO 创建 SQLite 数据库的一致快照（SQLite 3.27.0+，支持在线备份），失败时回退到文件复制
+func snapshotSQLite(db *gorm.DB, srcPath, dstPath string) error {
+	// VACUUM INTO 需要绝对路径
+	absPath, err := filepath.Abs(dstPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
+	escapedPath := strings.ReplaceAll(absPath, "'", "''")
 
-	auditDuration := time.Since(auditStartTime)
-	if Logger != nil {
-		Logger.Infof("[Backup] ✓ Audit database backup created: %s (%.2f %s) in %v",
-			absBackupPath, fileSize, unit, auditDuration)
+	if err := db.Exec(fmt.Sprintf("VACUUM INTO '%s'", escapedPath)).Error; err != nil {
+		if srcPath == "" {
+			return err
+		}
+		if Logger != nil {
+			Logger.Warnf("[Backup] Warning: VACUUM INTO failed: %v, falling back to file copy", err)
+		}
+		if err := copyDatabaseFile(srcPath, absPath); err != nil {
+			return err
+		}
 	}
 
-	return absBackupPath, nil
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("backup file was not created: %s", absPath)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("backup file is empty: %s", absPath)
+	}
+	if err := verifyBackupFile(absPath); err != nil {
+		if Logger != nil {
+			Logger.Warnf("[Backup] Warning: Backup file verification failed: %v", err)
+		}
+	}
+	return nil
 }
 
-// createBackupZip 将主数据库和审计数据库备份文件打包成一个ZIP文件
-func createBackupZip(mainDBPath, auditDBPath, zipPath string) error {
-	zipFile, err := os.Create(zipPath)
+// writeBackupArchive 写入备份压缩包：数据库文件、上传目录（uploads/ 前缀）和清单
+// uploadDir 为空时不备份上传文件；skipDir 为上传目录中需要跳过的目录（上传目录包含备份目录时避免备份自身）
+func writeBackupArchive(zipPath string, manifest *BackupManifest, sources []backupSource, uploadDir, skipDir string) error {
+	file, err := os.Create(zipPath)
 	if err != nil {
-		return fmt.Errorf("failed to create ZIP file: %w", err)
+		return err
 	}
-	defer zipFile.Close()
-
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	defer file.Close()
 
-	// 添加主数据库文件
-	if err := addFileToZip(zipWriter, mainDBPath, "data.db"); err != nil {
-		return fmt.Errorf("failed to add main database to ZIP: %w", err)
+	zipWriter := zip.NewWriter(file)
+	for _, source := range sources {
+		entry, err := addFileToZipWithChecksum(zipWriter, source.path, source.name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", source.name, err)
+		}
+		manifest.Files = append(manifest.Files, entry)
 	}
 
-	// 添加审计数据库文件（如果存在）
-	if auditDBPath != "" {
-		if err := addFileToZip(zipWriter, auditDBPath, "audit.db"); err != nil {
-			return fmt.Errorf("failed to add audit database to ZIP: %w", err)
+	if info, err := os.Stat(uploadDir); uploadDir != "" && err == nil && info.IsDir() {
+		uploadDir, _ = filepath.Abs(uploadDir)
+		skipDir, _ = filepath.Abs(skipDir)
+		err := filepath.WalkDir(uploadDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path == skipDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(uploadDir, path)
+			if err != nil {
+				return err
+			}
+			entry, err := addFileToZipWithChecksum(zipWriter, path, backupUploadsPrefix+filepath.ToSlash(rel))
+			if err != nil {
+				return fmt.Errorf("failed to add upload file %s: %w", rel, err)
+			}
+			manifest.Files = append(manifest.Files, entry)
+			manifest.UploadFiles++
+			manifest.UploadSize += entry.Size
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	if Logger != nil {
-		Logger.Info("[Backup] ✓ Backup ZIP file created successfully")
+	writer, err := zipWriter.Create(BackupManifestName)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
 	}
+	if err := zipWriter.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
 
-	return nil
+// addFileToZipWithChecksum 将文件添加到ZIP压缩包中，同时计算大小和 SHA-256 校验和
+func addFileToZipWithChecksum(zipWriter *zip.Writer, filePath, zipName string) (BackupFileEntry, error) {
+	entry := BackupFileEntry{Name: zipName}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return entry, err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return entry, err
+	}
+	header.Name = zipName
+	header.Method = zip.Deflate
+
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return entry, err
+	}
+	hash := sha256.New()
+	entry.Size, err = io.Copy(io.MultiWriter(writer, hash), file)
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return entry, err
 }
 
 // addFileToZip 将文件添加到ZIP压缩包中
@@ -547,37 +609,33 @@ func compressFile(srcPath, dstPath string) error {
 	return err
 }
 
-// cleanupOldBackups 清理旧备份
-func cleanupOldBackups(backupDir string, keepDays int) error {
-	entries, err := os.ReadDir(backupDir)
-	if err != nil {
-		return err
+// cleanupOldBackups 清理旧备份（keepDays 为 0 时永久保留）
+func cleanupOldBackups(store storage.Storage, keepDays int) error {
+	if keepDays <= 0 {
+		return nil
 	}
-
 	cutoffTime := time.Now().AddDate(0, 0, -keepDays)
-	removedCount := 0
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
 
+	// 先列出再删除，避免遍历过程中修改存储
+	var expired []string
+	err := store.List("", func(object storage.ObjectInfo) error {
 		// 只处理备份文件（ZIP格式）
-		if !strings.HasPrefix(entry.Name(), "backup_") || !strings.HasSuffix(entry.Name(), ".zip") {
-			continue
+		if ValidBackupName(object.Key) && object.ModTime.Before(cutoffTime) {
+			expired = append(expired, object.Key)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		info, err := entry.Info()
-		if err != nil {
+	removedCount := 0
+	for _, name := range expired {
+		if pending := GetPendingRestore(BackupDir()); pending != nil && pending.Name == name {
 			continue
 		}
-
-		// 如果文件修改时间早于保留期限，删除
-		if info.ModTime().Before(cutoffTime) {
-			filePath := filepath.Join(backupDir, entry.Name())
-			if err := os.Remove(filePath); err == nil {
-				removedCount++
-			}
+		if err := store.Delete(name); err == nil {
+			removedCount++
 		}
 	}
 
@@ -591,35 +649,17 @@ func cleanupOldBackups(backupDir string, keepDays int) error {
 }
 
 // getBackupStats 获取备份统计信息
-func getBackupStats(backupDir string) (int, int64, error) {
-	entries, err := os.ReadDir(backupDir)
-	if err != nil {
-		return 0, 0, err
-	}
-
+func getBackupStats(store storage.Storage) (int, int64, error) {
 	count := 0
 	totalSize := int64(0)
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		// 统计ZIP备份文件
-		if !strings.HasPrefix(entry.Name(), "backup_") || !strings.HasSuffix(entry.Name(), ".zip") {
-			continue
-		}
-
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-
-		count++
-		totalSize += info.Size()
-	}
-
-	return count, totalSize, nil
+	err := store.List("", func(object storage.ObjectInfo) error {
+		if ValidBackupName(object.Key) {
+			count++
+			totalSize += object.Size
+		}
+		return nil
+	})
+	return count, totalSize, err
 }
 
 // formatSize 格式化文件大小
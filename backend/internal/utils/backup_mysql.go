@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// mysqlDumpStatementSize 单条 INSERT 语句的目标长度（字节），超过后开始新的语句
+const mysqlDumpStatementSize = 1 << 20
+
+// mysqlDumpMaxLine 恢复时单行语句的最大长度
+const mysqlDumpMaxLine = 256 << 20
+
+var mysqlStringEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"'", "\\'",
+	"\n", "\\n",
+	"\r", "\\r",
+	"\x00", "\\0",
+	"\x1a", "\\Z",
+)
+
+// DumpMySQL 将 MySQL 数据库导出为 SQL（表结构和数据），在一致性快照中读取，每条语句占一行
+func DumpMySQL(db *gorm.DB, w io.Writer) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "ROLLBACK")
+
+	tables, err := mysqlTables(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "-- prjflow MySQL dump %s\n", time.Now().Format(time.RFC3339))
+	writer.WriteString("SET NAMES utf8mb4;\n")
+	writer.WriteString("SET FOREIGN_KEY_CHECKS=0;\n")
+	for _, table := range tables {
+		if err := dumpMySQLTable(ctx, conn, writer, table); err != nil {
+			return fmt.Errorf("failed to dump table %s: %w", table, err)
+		}
+	}
+	writer.WriteString("SET FOREIGN_KEY_CHECKS=1;\n")
+	return writer.Flush()
+}
+
+// LoadMySQLDump 执行 DumpMySQL 导出的 SQL（每行一条语句，-- 开头的行为注释）
+func LoadMySQLDump(db *gorm.DB, r io.Reader) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS=1")
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), mysqlDumpMaxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		statement := strings.TrimSpace(scanner.Text())
+		if statement == "" || strings.HasPrefix(statement, "--") {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func mysqlTables(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SHOW FULL TABLES WHERE Table_type = 'BASE TABLE'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+func dumpMySQLTable(ctx context.Context, conn *sql.Conn, w *bufio.Writer, table string) error {
+	quoted := quoteMySQLIdentifier(table)
+	var name, createSQL string
+	if err := conn.QueryRowContext(ctx, "SHOW CREATE TABLE "+quoted).Scan(&name, &createSQL); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "DROP TABLE IF EXISTS %s;\n", quoted)
+	fmt.Fprintf(w, "%s;\n", strings.ReplaceAll(createSQL, "\n", " "))
+
+	rows, err := conn.QueryContext(ctx, "SELECT * FROM "+quoted)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteMySQLIdentifier(column.Name())
+	}
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", quoted, strings.Join(names, ","))
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var statement strings.Builder
+	flush := func() error {
+		if statement.Len() == 0 {
+			return nil
+		}
+		statement.WriteString(";\n")
+		_, err := w.WriteString(statement.String())
+		statement.Reset()
+		return err
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if statement.Len() == 0 {
+			statement.WriteString(prefix)
+		} else {
+			statement.WriteByte(',')
+		}
+		statement.WriteByte('(')
+		for i, value := range values {
+			if i > 0 {
+				statement.WriteByte(',')
+			}
+			writeMySQLValue(&statement, value, columns[i].DatabaseTypeName())
+		}
+		statement.WriteByte(')')
+		if statement.Len() >= mysqlDumpStatementSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// writeMySQLValue 将查询到的值写为 SQL 字面量
+func writeMySQLValue(b *strings.Builder, value interface{}, typeName string) {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("NULL")
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		b.WriteString(strconv.FormatUint(v, 10))
+	case float64:
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case float32:
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case bool:
+		if v {
+			b.WriteString("1")
+		} else {
+			b.WriteString("0")
+		}
+	case time.Time:
+		b.WriteString("'" + v.Format("2006-01-02 15:04:05.999999") + "'")
+	case []byte:
+		switch strings.TrimPrefix(typeName, "UNSIGNED ") {
+		case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+			b.Write(v)
+		case "BINARY", "VARBINARY", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BIT", "GEOMETRY":
+			if len(v) == 0 {
+				b.WriteString("''")
+			} else {
+				b.WriteString("0x" + hex.EncodeToString(v))
+			}
+		default:
+			b.WriteString("'" + mysqlStringEscaper.Replace(string(v)) + "'")
+		}
+	default:
+		b.WriteString("'" + mysqlStringEscaper.Replace(fmt.Sprint(v)) + "'")
+	}
+}
+
+func quoteMySQLIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"prjflow/internal/config"
+
+	"gorm.io/gorm"
+)
+
+// 暂存的恢复请求和最近一次恢复结果（保存在备份目录中）
+const (
+	pendingRestoreFile = "restore_pending.json"
+	lastRestoreFile    = "restore_last.json"
+)
+
+var backupNamePattern = regexp.MustCompile(`^backup_\d{8}_\d{6}(_\d+)?\.zip$`)
+
+// ValidBackupName 检查备份文件名（防止路径遍历）
+func ValidBackupName(name string) bool {
+	return backupNamePattern.MatchString(name)
+}
+
+// ListBackups 列出备份目录中的备份（按时间倒序）
+func ListBackups(backupDir string) ([]BackupInfo, error) {
+	backups := make([]BackupInfo, 0)
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return backups, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !ValidBackupName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backup := BackupInfo{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()}
+		if manifest, err := ReadBackupManifest(filepath.Join(backupDir, entry.Name())); err == nil {
+			backup.Manifest = manifest
+			backup.CreatedAt = manifest.CreatedAt
+		}
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// ReadBackupManifest 读取备份清单（不校验文件内容）
+func ReadBackupManifest(backupPath string) (*BackupManifest, error) {
+	reader, err := zip.OpenReader(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readBackupManifest(&reader.Reader)
+}
+
+func readBackupManifest(reader *zip.Reader) (*BackupManifest, error) {
+	for _, file := range reader.File {
+		if file.Name != BackupManifestName {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		var manifest BackupManifest
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			return nil, fmt.Errorf("备份清单格式错误: %w", err)
+		}
+		return &manifest, nil
+	}
+	return nil, fmt.Errorf("备份中没有清单文件（旧版本备份无法校验）")
+}
+
+// safeBackupEntryName 检查备份中的文件名（防止解压到目标目录之外）
+func safeBackupEntryName(name string) bool {
+	return name != "" && !strings.Contains(name, "\\") && !path.IsAbs(name) &&
+		path.Clean(name) == name && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// VerifyBackup 校验备份：清单中的每个文件都存在，且大小和 SHA-256 校验和一致
+func VerifyBackup(backupPath string) (*BackupManifest, error) {
+	reader, err := zip.OpenReader(backupPath)
+	if err != nil {
+		return nil, fmt.Errorf("无法打开备份文件: %w", err)
+	}
+	defer reader.Close()
+
+	manifest, err := readBackupManifest(&reader.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.FormatVersion > BackupFormatVersion {
+		return nil, fmt.Errorf("不支持的备份格式版本: %d", manifest.FormatVersion)
+	}
+	if manifest.File(backupDumpName(manifest.DatabaseType, "data")) == nil {
+		return nil, fmt.Errorf("备份中没有主数据库")
+	}
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+	for _, entry := range manifest.Files {
+		if !safeBackupEntryName(entry.Name) {
+			return nil, fmt.Errorf("备份中的文件名无效: %s", entry.Name)
+		}
+		file, ok := files[entry.Name]
+		if !ok {
+			return nil, fmt.Errorf("备份中缺少文件: %s", entry.Name)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("读取备份文件 %s 失败: %w", entry.Name, err)
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取备份文件 %s 失败: %w", entry.Name, err)
+		}
+		if size != entry.Size || hex.EncodeToString(hash.Sum(nil)) != entry.SHA256 {
+			return nil, fmt.Errorf("备份文件 %s 校验和不一致，备份可能已损坏", entry.Name)
+		}
+	}
+	return manifest, nil
+}
+
+// checkRestoreTarget 检查备份能否恢复到当前配置的数据库
+func checkRestoreTarget(manifest *BackupManifest) error {
+	if manifest.DatabaseType != config.AppConfig.Database.Type {
+		return fmt.Errorf("备份的数据库类型（%s）与当前配置（%s）不一致", manifest.DatabaseType, config.AppConfig.Database.Type)
+	}
+	if manifest.AuditDatabaseType != "" && manifest.AuditDatabaseType != auditDatabaseType() {
+		return fmt.Errorf("备份的审计数据库类型（%s）与当前配置（%s）不一致", manifest.AuditDatabaseType, auditDatabaseType())
+	}
+	return nil
+}
+
+// RestoreBackup 校验并恢复备份：替换主数据库、审计数据库和上传文件目录，必须在服务停止时执行
+// SQLite 数据库文件和上传目录的原有内容重命名为 *.before-restore-<时间> 保留；MySQL 按导出的 SQL 重建所有表
+func RestoreBackup(backupPath string) (*BackupManifest, error) {
+	manifest, err := VerifyBackup(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRestoreTarget(manifest); err != nil {
+		return nil, err
+	}
+
+	reader, err := zip.OpenReader(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	suffix := ".before-restore-" + time.Now().Format("20060102_150405")
+	mainEntry := files[backupDumpName(manifest.DatabaseType, "data")]
+	if manifest.DatabaseType == "sqlite" {
+		err = restoreSQLiteFile(mainEntry, ResolveSQLitePath(config.AppConfig.Database.DSN), suffix)
+	} else {
+		err = restoreMySQLDump(mainEntry, InitDB)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("恢复主数据库失败: %w", err)
+	}
+
+	if manifest.AuditDatabaseType != "" {
+		auditEntry := files[backupDumpName(manifest.AuditDatabaseType, "audit")]
+		if manifest.AuditDatabaseType == "sqlite" {
+			err = restoreSQLiteFile(auditEntry, sqliteAuditDBPath(), suffix)
+		} else {
+			err = restoreMySQLDump(auditEntry, InitAuditDB)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("恢复审计数据库失败: %w", err)
+		}
+	}
+
+	// 附件保存在对象存储中时备份不包含上传文件，保留当前的上传目录
+	if manifest.UploadStorage == "" || manifest.UploadStorage == "local" {
+		if err := restoreUploads(manifest, files, filepath.Clean(UploadStoragePath()), suffix); err != nil {
+			return nil, fmt.Errorf("恢复上传文件失败: %w", err)
+		}
+	}
+
+	if Logger != nil {
+		Logger.Infof("[Backup] ✓ Restored backup %s (%d upload files)", backupPath, manifest.UploadFiles)
+	}
+	return manifest, nil
+}
+
+// restoreSQLiteFile 先解压到临时文件并校验，再替换数据库文件（原文件及 WAL 文件重命名保留）
+func restoreSQLiteFile(entry *zip.File, target, suffix string) error {
+	if target == "" {
+		return fmt.Errorf("无法确定数据库文件路径")
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmpPath := target + ".restore-tmp"
+	if err := extractZipFile(entry, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := verifyBackupFile(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	for _, ext := range []string{"", "-wal", "-shm"} {
+		if _, err := os.Stat(target + ext); err == nil {
+			if err := os.Rename(target+ext, target+suffix+ext); err != nil {
+				os.Remove(tmpPath)
+				return err
+			}
+		}
+	}
+	return os.Rename(tmpPath, target)
+}
+
+// restoreMySQLDump 连接数据库并执行备份中的 SQL
+func restoreMySQLDump(entry *zip.File, open func() (*gorm.DB, error)) error {
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	rc, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return LoadMySQLDump(db, rc)
+}
+
+// restoreUploads 将备份中的上传文件解压到临时目录，再替换上传目录
+func restoreUploads(manifest *BackupManifest, files map[string]*zip.File, storageDir, suffix string) error {
+	tmpDir := storageDir + ".restore-tmp"
+	os.RemoveAll(tmpDir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return err
+	}
+	for _, entry := range manifest.Files {
+		if !strings.HasPrefix(entry.Name, backupUploadsPrefix) {
+			continue
+		}
+		target := filepath.Join(tmpDir, filepath.FromSlash(strings.TrimPrefix(entry.Name, backupUploadsPrefix)))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+		if err := extractZipFile(files[entry.Name], target); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+	}
+	if _, err := os.Stat(storageDir); err == nil {
+		if err := os.Rename(storageDir, storageDir+suffix); err != nil {
+			os.RemoveAll(tmpDir)
+			return err
+		}
+	}
+	return os.Rename(tmpDir, storageDir)
+}
+
+func extractZipFile(file *zip.File, target string) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, rc); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// PendingRestore 管理员暂存的恢复请求，在下次启动服务时执行
+type PendingRestore struct {
+	Name        string    `json:"name"`
+	RequestedBy string    `json:"requested_by"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// RestoreResult 启动时执行暂存恢复的结果
+type RestoreResult struct {
+	Name       string    `json:"name"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// StageRestore 校验备份并暂存恢复请求，下次启动服务时执行
+func StageRestore(backupDir, name, requestedBy string) (*PendingRestore, error) {
+	if !ValidBackupName(name) {
+		return nil, fmt.Errorf("无效的备份文件名")
+	}
+	manifest, err := VerifyBackup(filepath.Join(backupDir, name))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRestoreTarget(manifest); err != nil {
+		return nil, err
+	}
+	pending := &PendingRestore{Name: name, RequestedBy: requestedBy, RequestedAt: time.Now()}
+	if err := writeJSONFile(filepath.Join(backupDir, pendingRestoreFile), pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// GetPendingRestore 读取暂存的恢复请求，没有时返回 nil
+func GetPendingRestore(backupDir string) *PendingRestore {
+	var pending PendingRestore
+	if err := readJSONFile(filepath.Join(backupDir, pendingRestoreFile), &pending); err != nil {
+		return nil
+	}
+	return &pending
+}
+
+// CancelPendingRestore 取消暂存的恢复请求
+func CancelPendingRestore(backupDir string) error {
+	err := os.Remove(filepath.Join(backupDir, pendingRestoreFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// GetLastRestoreResult 读取最近一次启动时恢复的结果，没有时返回 nil
+func GetLastRestoreResult(backupDir string) *RestoreResult {
+	var result RestoreResult
+	if err := readJSONFile(filepath.Join(backupDir, lastRestoreFile), &result); err != nil {
+		return nil
+	}
+	return &result
+}
+
+// ApplyPendingRestore 执行暂存的恢复，必须在打开数据库之前调用；没有暂存的恢复时返回 nil, nil
+// 恢复请求在执行前移除，恢复失败时不会在每次启动时重复执行
+func ApplyPendingRestore() (*RestoreResult, error) {
+	backupDir := BackupDir()
+	pending := GetPendingRestore(backupDir)
+	if pending == nil {
+		return nil, nil
+	}
+
+	result := &RestoreResult{Name: pending.Name}
+	err := CancelPendingRestore(backupDir)
+	if err == nil {
+		_, err = RestoreBackup(filepath.Join(backupDir, pending.Name))
+	}
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.FinishedAt = time.Now()
+	if writeErr := writeJSONFile(filepath.Join(backupDir, lastRestoreFile), result); writeErr != nil && Logger != nil {
+		Logger.Warnf("[Backup] Failed to record restore result: %v", writeErr)
+	}
+	return result, err
+}
+
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func readJSONFile(path string, value interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
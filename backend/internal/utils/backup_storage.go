@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"prjflow/pkg/storage"
+)
+
+// 备份总是先在本地备份目录中生成；配置了对象存储时上传到存储后删除本地文件，
+// 校验和恢复前再下载到本地备份目录（暂存恢复的状态文件也保存在本地备份目录中）
+
+// remoteBackupStorage 备份使用的对象存储，备份保存在本地备份目录时返回 nil
+func remoteBackupStorage() (storage.Storage, error) {
+	store, err := BackupStorage()
+	if err != nil {
+		return nil, err
+	}
+	if store.Type() == "local" {
+		return nil, nil
+	}
+	return store, nil
+}
+
+// StoreBackup 将本地备份目录中新生成的备份上传到对象存储（未配置对象存储时不处理）
+func StoreBackup(backupDir, name string) error {
+	store, err := remoteBackupStorage()
+	if err != nil || store == nil {
+		return err
+	}
+	localPath := filepath.Join(backupDir, name)
+	if err := copyFileToStorage(store, localPath, name, "application/zip"); err != nil {
+		return fmt.Errorf("上传备份到对象存储失败: %w", err)
+	}
+	return os.Remove(localPath)
+}
+
+// ListStoredBackups 列出备份（按时间倒序）；对象存储中的备份不读取清单，时间取自备份文件名
+func ListStoredBackups() ([]BackupInfo, error) {
+	store, err := remoteBackupStorage()
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return ListBackups(BackupDir())
+	}
+
+	backups := make([]BackupInfo, 0)
+	err = store.List("", func(object storage.ObjectInfo) error {
+		if !ValidBackupName(object.Key) {
+			return nil
+		}
+		backup := BackupInfo{Name: object.Key, Size: object.Size, CreatedAt: object.ModTime}
+		if createdAt, err := time.ParseInLocation("20060102_150405", object.Key[len("backup_"):len("backup_")+15], time.Local); err == nil {
+			backup.CreatedAt = createdAt
+		}
+		backups = append(backups, backup)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// StatStoredBackup 获取备份文件信息
+func StatStoredBackup(name string) (*storage.ObjectInfo, error) {
+	store, err := BackupStorage()
+	if err != nil {
+		return nil, err
+	}
+	return store.Stat(name)
+}
+
+// OpenStoredBackup 打开备份文件，调用方负责关闭
+func OpenStoredBackup(name string) (io.ReadCloser, *storage.ObjectInfo, error) {
+	store, err := BackupStorage()
+	if err != nil {
+		return nil, nil, err
+	}
+	return store.Get(name)
+}
+
+// FetchBackup 返回备份在本地备份目录中的路径，备份保存在对象存储中时先下载到本地
+func FetchBackup(name string) (string, error) {
+	if !ValidBackupName(name) {
+		return "", fmt.Errorf("无效的备份文件名")
+	}
+	localPath := filepath.Join(BackupDir(), name)
+	if _, err := os.Stat(localPath); err == nil {
+		return localPath, nil
+	}
+	store, err := remoteBackupStorage()
+	if err != nil {
+		return "", err
+	}
+	if store == nil {
+		return "", fmt.Errorf("备份不存在: %s", name)
+	}
+	if err := os.MkdirAll(BackupDir(), 0755); err != nil {
+		return "", err
+	}
+	if err := copyStorageObjectToFile(store, name, localPath); err != nil {
+		return "", fmt.Errorf("从对象存储下载备份失败: %w", err)
+	}
+	return localPath, nil
+}
+
+// DeleteStoredBackup 删除备份（包括下载到本地备份目录的副本）
+func DeleteStoredBackup(name string) error {
+	if !ValidBackupName(name) {
+		return fmt.Errorf("无效的备份文件名")
+	}
+	if store, err := remoteBackupStorage(); err != nil {
+		return err
+	} else if store != nil {
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+	}
+	err := os.Remove(filepath.Join(BackupDir(), name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// copyFileToStorage 将本地文件写入存储
+func copyFileToStorage(store storage.Storage, path, key, contentType string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return store.Put(key, file, info.Size(), contentType)
+}
+
+// copyStorageObjectToFile 将存储中的对象下载到本地文件（先写临时文件再重命名）
+func copyStorageObjectToFile(store storage.Storage, key, path string) error {
+	reader, _, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	tmpPath := path + ".download"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
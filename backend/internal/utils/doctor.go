@@ -0,0 +1,716 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"prjflow/internal/model"
+	"prjflow/pkg/storage"
+)
+
+// 数据一致性检查（doctor）：每个检查只读地查找问题，可修复的问题在同一个事务中修复；
+// dry-run 模式执行修复后回滚事务，只报告将要修复的内容。附件存储中的文件在事务提交后才删除
+
+// doctorGracePeriod 最近创建的附件和文件可能还在上传过程中，不作为孤立数据处理
+const doctorGracePeriod = time.Hour
+
+// DoctorIssue 检查发现的问题
+type DoctorIssue struct {
+	Check   string `json:"check"`   // 检查名称
+	Object  string `json:"object"`  // 问题对象，如 tasks#12、project_attachments.project_id
+	Message string `json:"message"` // 问题说明
+	Fixable bool   `json:"fixable"` // 是否可以自动修复（不能修复的需要人工处理）
+
+	fix func(r *DoctorRepair) error
+}
+
+// NewDoctorIssue 创建问题，fix 为 nil 表示不能自动修复
+func NewDoctorIssue(object, message string, fix func(r *DoctorRepair) error) DoctorIssue {
+	return DoctorIssue{Object: object, Message: message, Fixable: fix != nil, fix: fix}
+}
+
+// DoctorRepair 修复上下文：Tx 为修复事务，DeleteFile 登记事务提交后要删除的附件存储文件
+type DoctorRepair struct {
+	Tx    *gorm.DB
+	files []string
+}
+
+// DeleteFile 登记事务提交后删除的附件存储文件（dry-run 时不删除）
+func (r *DoctorRepair) DeleteFile(key string) {
+	if key != "" {
+		r.files = append(r.files, key)
+	}
+}
+
+// DoctorCheck 一致性检查：Run 只读取数据，返回发现的问题
+type DoctorCheck struct {
+	Name        string
+	Description string
+	Run         func(db *gorm.DB) ([]DoctorIssue, error)
+}
+
+var (
+	doctorMu     sync.RWMutex
+	doctorChecks = []DoctorCheck{
+		{Name: "actual-hours", Description: "任务、Bug、需求的实际工时与资源分配汇总一致", Run: checkActualHours},
+		{Name: "task-progress", Description: "未完成任务的进度与工时计算的进度一致", Run: checkTaskProgress},
+		{Name: "project-members", Description: "同一用户在项目中只有一条成员记录", Run: checkProjectMembers},
+		{Name: "resources", Description: "人员资源对应的用户是项目成员", Run: checkResources},
+		{Name: "many2many", Description: "多对多关联表中没有指向不存在记录的关联", Run: checkManyToMany},
+		{Name: "attachment-rows", Description: "附件至少关联到一个对象", Run: checkAttachmentRows},
+		{Name: "attachment-refs", Description: "附件文件内容的引用数与附件和版本记录一致", Run: checkAttachmentRefs},
+		{Name: "attachment-files", Description: "附件记录的文件存在，存储中没有未被引用的文件", Run: checkAttachmentFiles},
+	}
+)
+
+// RegisterDoctorCheck 注册一致性检查，名称相同的检查会被替换
+func RegisterDoctorCheck(check DoctorCheck) {
+	doctorMu.Lock()
+	defer doctorMu.Unlock()
+	for i := range doctorChecks {
+		if doctorChecks[i].Name == check.Name {
+			doctorChecks[i] = check
+			return
+		}
+	}
+	doctorChecks = append(doctorChecks, check)
+}
+
+// DoctorChecks 已注册的检查（按注册顺序）
+func DoctorChecks() []DoctorCheck {
+	doctorMu.RLock()
+	defer doctorMu.RUnlock()
+	return append([]DoctorCheck(nil), doctorChecks...)
+}
+
+// DoctorOptions 检查选项
+type DoctorOptions struct {
+	Checks []string // 要执行的检查名称，为空时执行全部检查
+	Repair bool     // 修复可以自动修复的问题
+	DryRun bool     // 与 Repair 一起使用：执行修复后回滚事务
+}
+
+// DoctorCheckResult 一个检查的结果
+type DoctorCheckResult struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Issues      []DoctorIssue `json:"issues"`
+	Error       string        `json:"error,omitempty"` // 检查执行失败的原因
+}
+
+// DoctorReport 检查报告
+type DoctorReport struct {
+	Results      []DoctorCheckResult `json:"results"`
+	Issues       int                 `json:"issues"`        // 发现的问题数
+	Fixable      int                 `json:"fixable"`       // 可以自动修复的问题数
+	Fixed        int                 `json:"fixed"`         // 已修复（dry-run 时为将要修复）的问题数
+	DeletedFiles int                 `json:"deleted_files"` // 删除的附件存储文件数（dry-run 时为将要删除）
+	Repaired     bool                `json:"repaired"`      // 修复事务已提交
+	DryRun       bool                `json:"dry_run"`
+}
+
+// Unresolved 仍需处理的问题数（检查失败也算作未解决）
+func (r *DoctorReport) Unresolved() int {
+	unresolved := r.Issues
+	if r.Repaired {
+		unresolved -= r.Fixed
+	}
+	for _, result := range r.Results {
+		if result.Error != "" {
+			unresolved++
+		}
+	}
+	return unresolved
+}
+
+// RunDoctor 执行一致性检查，opts.Repair 为 true 时在一个事务中修复可以自动修复的问题
+// 任何一个问题修复失败时回滚整个事务
+func RunDoctor(db *gorm.DB, opts DoctorOptions) (*DoctorReport, error) {
+	checks, err := selectDoctorChecks(opts.Checks)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DoctorReport{}
+	for _, check := range checks {
+		result := DoctorCheckResult{Name: check.Name, Description: check.Description, Issues: []DoctorIssue{}}
+		issues, err := check.Run(db)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		for _, issue := range issues {
+			issue.Check = check.Name
+			result.Issues = append(result.Issues, issue)
+			report.Issues++
+			if issue.Fixable {
+				report.Fixable++
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	if !opts.Repair || report.Fixable == 0 {
+		return report, nil
+	}
+
+	repair := &DoctorRepair{Tx: db.Begin()}
+	if repair.Tx.Error != nil {
+		return report, repair.Tx.Error
+	}
+	for _, result := range report.Results {
+		for _, issue := range result.Issues {
+			if !issue.Fixable {
+				continue
+			}
+			if err := issue.fix(repair); err != nil {
+				repair.Tx.Rollback()
+				return report, fmt.Errorf("修复 [%s] %s 失败: %w", issue.Check, issue.Object, err)
+			}
+			report.Fixed++
+		}
+	}
+	report.DeletedFiles = len(repair.files)
+	if opts.DryRun {
+		report.DryRun = true
+		return report, repair.Tx.Rollback().Error
+	}
+	if err := repair.Tx.Commit().Error; err != nil {
+		return report, err
+	}
+	report.Repaired = true
+
+	// 事务提交后删除文件，删除失败只记录日志（下次检查会作为孤立文件再次发现）
+	if len(repair.files) > 0 {
+		store, err := AttachmentStorage()
+		if err != nil {
+			return report, err
+		}
+		for _, key := range repair.files {
+			if err := store.Delete(key); err != nil {
+				report.DeletedFiles--
+				if Logger != nil {
+					Logger.Warnf("[Doctor] Failed to delete file %s: %v", key, err)
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// selectDoctorChecks 按名称选择检查
+func selectDoctorChecks(names []string) ([]DoctorCheck, error) {
+	all := DoctorChecks()
+	if len(names) == 0 {
+		return all, nil
+	}
+	byName := make(map[string]DoctorCheck, len(all))
+	available := make([]string, 0, len(all))
+	for _, check := range all {
+		byName[check.Name] = check
+		available = append(available, check.Name)
+	}
+	checks := make([]DoctorCheck, 0, len(names))
+	for _, name := range names {
+		check, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("未知的检查: %s（可用的检查: %s）", name, strings.Join(available, ", "))
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// checkActualHours 实际工时应等于关联的资源分配工时之和（与各接口的 calculateAndUpdateActualHours 相同）
+func checkActualHours(db *gorm.DB) ([]DoctorIssue, error) {
+	var issues []DoctorIssue
+	for _, item := range []struct{ table, column string }{
+		{"tasks", "task_id"},
+		{"bugs", "bug_id"},
+		{"requirements", "requirement_id"},
+	} {
+		var rows []struct {
+			ID     uint
+			Actual float64
+			Total  float64
+		}
+		if err := db.Raw(fmt.Sprintf(`SELECT t.id AS id, COALESCE(t.actual_hours, 0) AS actual, COALESCE(SUM(a.hours), 0) AS total
+			FROM %[1]s t LEFT JOIN resource_allocations a ON a.%[2]s = t.id AND a.deleted_at IS NULL
+			WHERE t.deleted_at IS NULL GROUP BY t.id, t.actual_hours ORDER BY t.id`, item.table, item.column)).Scan(&rows).Error; err != nil {
+			return issues, err
+		}
+		for _, row := range rows {
+			if math.Abs(row.Actual-row.Total) < 0.001 {
+				continue
+			}
+			table, id, total := item.table, row.ID, row.Total
+			issues = append(issues, NewDoctorIssue(fmt.Sprintf("%s#%d", table, id),
+				fmt.Sprintf("实际工时 %.2f 与资源分配汇总 %.2f 不一致", row.Actual, row.Total),
+				func(r *DoctorRepair) error {
+					return r.Tx.Table(table).Where("id = ?", id).UpdateColumn("actual_hours", total).Error
+				}))
+		}
+	}
+	return issues, nil
+}
+
+// TaskProgressFromHours 根据实际工时和预估工时计算任务进度（0-100）
+func TaskProgressFromHours(actualHours, estimatedHours float64) int {
+	if estimatedHours <= 0 {
+		return 0
+	}
+	progress := int((actualHours / estimatedHours) * 100)
+	if progress > 100 {
+		progress = 100
+	}
+	if progress < 0 {
+		progress = 0
+	}
+	return progress
+}
+
+// checkTaskProgress 未完成的任务（有预估工时）进度应等于工时计算的进度，所有任务的进度应在 0-100 之间
+// 修复时与任务接口自动计算进度的规则相同：进度为 100 时完成任务，进度大于 0 的未开始任务改为进行中
+func checkTaskProgress(db *gorm.DB) ([]DoctorIssue, error) {
+	var issues []DoctorIssue
+
+	var rows []struct {
+		ID        uint
+		Progress  int
+		Status    string
+		Estimated float64
+		Total     float64
+	}
+	if err := db.Raw(`SELECT t.id AS id, t.progress AS progress, t.status AS status, t.estimated_hours AS estimated, COALESCE(SUM(a.hours), 0) AS total
+		FROM tasks t LEFT JOIN resource_allocations a ON a.task_id = t.id AND a.deleted_at IS NULL
+		WHERE t.deleted_at IS NULL AND t.estimated_hours > 0 AND t.status NOT IN ('done', 'cancel', 'closed')
+		GROUP BY t.id, t.progress, t.status, t.estimated_hours ORDER BY t.id`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	checked := make(map[uint]bool, len(rows))
+	for _, row := range rows {
+		checked[row.ID] = true
+		progress := TaskProgressFromHours(row.Total, row.Estimated)
+		if progress == row.Progress {
+			continue
+		}
+		id, status := row.ID, row.Status
+		if progress == 100 {
+			status = "done"
+		} else if progress > 0 && status == "wait" {
+			status = "doing"
+		}
+		issues = append(issues, NewDoctorIssue(fmt.Sprintf("tasks#%d", id),
+			fmt.Sprintf("进度 %d%% 与工时计算的进度 %d%%（%.2f / %.2f 小时）不一致", row.Progress, progress, row.Total, row.Estimated),
+			func(r *DoctorRepair) error {
+				return r.Tx.Model(&model.Task{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{"progress": progress, "status": status}).Error
+			}))
+	}
+
+	var tasks []model.Task
+	if err := db.Select("id", "progress").Where("progress < 0 OR progress > 100").Order("id").Find(&tasks).Error; err != nil {
+		return issues, err
+	}
+	for _, task := range tasks {
+		if checked[task.ID] {
+			continue
+		}
+		id, progress := task.ID, task.Progress
+		if progress < 0 {
+			progress = 0
+		} else {
+			progress = 100
+		}
+		issues = append(issues, NewDoctorIssue(fmt.Sprintf("tasks#%d", id), fmt.Sprintf("进度 %d%% 超出 0-100 范围", task.Progress),
+			func(r *DoctorRepair) error {
+				return r.Tx.Model(&model.Task{}).Where("id = ?", id).UpdateColumn("progress", progress).Error
+			}))
+	}
+	return issues, nil
+}
+
+// checkProjectMembers 同一用户在项目中有多条成员记录时保留一条（优先保留项目负责人，其次是最早加入的）
+func checkProjectMembers(db *gorm.DB) ([]DoctorIssue, error) {
+	var duplicates []struct {
+		ProjectID uint
+		UserID    uint
+	}
+	if err := db.Model(&model.ProjectMember{}).Select("project_id, user_id").
+		Group("project_id, user_id").Having("COUNT(*) > 1").Order("project_id, user_id").Scan(&duplicates).Error; err != nil {
+		return nil, err
+	}
+
+	var issues []DoctorIssue
+	for _, duplicate := range duplicates {
+		var members []model.ProjectMember
+		if err := db.Where("project_id = ? AND user_id = ?", duplicate.ProjectID, duplicate.UserID).Order("id").Find(&members).Error; err != nil {
+			return issues, err
+		}
+		keep := members[0]
+		for _, member := range members {
+			if member.Role == "owner" {
+				keep = member
+				break
+			}
+		}
+		var remove []uint
+		for _, member := range members {
+			if member.ID != keep.ID {
+				remove = append(remove, member.ID)
+			}
+		}
+		issues = append(issues, NewDoctorIssue(fmt.Sprintf("projects#%d users#%d", duplicate.ProjectID, duplicate.UserID),
+			fmt.Sprintf("有 %d 条成员记录，保留 project_members#%d（%s）", len(members), keep.ID, keep.Role),
+			func(r *DoctorRepair) error {
+				return r.Tx.Where("id IN ?", remove).Delete(&model.ProjectMember{}).Error
+			}))
+	}
+	return issues, nil
+}
+
+// checkResources 人员资源的用户应是项目成员；没有资源分配记录的直接删除，有记录的需要人工处理
+func checkResources(db *gorm.DB) ([]DoctorIssue, error) {
+	var resources []model.Resource
+	if err := db.Where(`NOT EXISTS (SELECT 1 FROM project_members m
+		WHERE m.project_id = resources.project_id AND m.user_id = resources.user_id AND m.deleted_at IS NULL)`).
+		Order("id").Find(&resources).Error; err != nil {
+		return nil, err
+	}
+
+	var issues []DoctorIssue
+	for _, resource := range resources {
+		var allocations int64
+		if err := db.Model(&model.ResourceAllocation{}).Where("resource_id = ?", resource.ID).Count(&allocations).Error; err != nil {
+			return issues, err
+		}
+		object := fmt.Sprintf("resources#%d", resource.ID)
+		if allocations > 0 {
+			issues = append(issues, NewDoctorIssue(object,
+				fmt.Sprintf("用户 %d 不是项目 %d 的成员，但有 %d 条资源分配记录，请将用户重新加入项目或手动处理", resource.UserID, resource.ProjectID, allocations), nil))
+			continue
+		}
+		id := resource.ID
+		issues = append(issues, NewDoctorIssue(object,
+			fmt.Sprintf("用户 %d 不是项目 %d 的成员，且没有资源分配记录", resource.UserID, resource.ProjectID),
+			func(r *DoctorRepair) error {
+				return r.Tx.Delete(&model.Resource{}, id).Error
+			}))
+	}
+	return issues, nil
+}
+
+// joinTableReference 多对多关联表中的一个外键列
+type joinTableReference struct {
+	Table     string // 关联表
+	Column    string // 关联表中的外键列
+	RefTable  string // 外键指向的表
+	RefColumn string // 外键指向的列
+}
+
+// joinTableReferences 从模型定义中收集所有多对多关联表的外键列
+func joinTableReferences(db *gorm.DB) ([]joinTableReference, error) {
+	cache := &sync.Map{}
+	seen := make(map[string]bool)
+	var refs []joinTableReference
+	for _, m := range Models() {
+		s, err := schema.Parse(m, cache, db.NamingStrategy)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range s.Relationships.Relations {
+			if rel.JoinTable == nil {
+				continue
+			}
+			for _, ref := range rel.References {
+				if ref.PrimaryKey == nil || ref.ForeignKey == nil {
+					continue
+				}
+				target := rel.FieldSchema
+				if ref.OwnPrimaryKey {
+					target = rel.Schema
+				}
+				key := rel.JoinTable.Table + "." + ref.ForeignKey.DBName
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				refs = append(refs, joinTableReference{
+					Table:     rel.JoinTable.Table,
+					Column:    ref.ForeignKey.DBName,
+					RefTable:  target.Table,
+					RefColumn: ref.PrimaryKey.DBName,
+				})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Table != refs[j].Table {
+			return refs[i].Table < refs[j].Table
+		}
+		return refs[i].Column < refs[j].Column
+	})
+	return refs, nil
+}
+
+// checkManyToMany 多对多关联表中指向不存在记录（已被硬删除）的关联
+// 指向软删除记录的关联保留（恢复记录时仍然有效）
+func checkManyToMany(db *gorm.DB) ([]DoctorIssue, error) {
+	refs, err := joinTableReferences(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []DoctorIssue
+	for _, ref := range refs {
+		if !db.Migrator().HasTable(ref.Table) {
+			continue
+		}
+		condition := fmt.Sprintf("%s NOT IN (SELECT %s FROM %s)", ref.Column, ref.RefColumn, ref.RefTable)
+		var count int64
+		if err := db.Table(ref.Table).Where(condition).Count(&count).Error; err != nil {
+			return issues, err
+		}
+		if count == 0 {
+			continue
+		}
+		table := ref.Table
+		issues = append(issues, NewDoctorIssue(ref.Table+"."+ref.Column,
+			fmt.Sprintf("%d 条关联指向不存在的 %s 记录", count, ref.RefTable),
+			func(r *DoctorRepair) error {
+				return r.Tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", table, condition)).Error
+			}))
+	}
+	return issues, nil
+}
+
+// checkAttachmentRows 没有关联到任何对象的附件（关联的对象被删除后遗留），修复时删除附件、历史版本并释放文件
+func checkAttachmentRows(db *gorm.DB) ([]DoctorIssue, error) {
+	refs, err := joinTableReferences(db)
+	if err != nil {
+		return nil, err
+	}
+	conditions := []string{"created_at < ?"}
+	for _, ref := range refs {
+		if ref.RefTable == "attachments" && db.Migrator().HasTable(ref.Table) {
+			conditions = append(conditions, fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s.%s = attachments.id)", ref.Table, ref.Table, ref.Column))
+		}
+	}
+
+	var attachments []model.Attachment
+	if err := db.Unscoped().Where(strings.Join(conditions, " AND "), time.Now().Add(-doctorGracePeriod)).Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+
+	var issues []DoctorIssue
+	for _, attachment := range attachments {
+		issues = append(issues, NewDoctorIssue(fmt.Sprintf("attachments#%d", attachment.ID),
+			fmt.Sprintf("附件 %s 没有关联到任何对象", attachment.FileName),
+			func(r *DoctorRepair) error {
+				return doctorRemoveAttachment(r, &attachment)
+			}))
+	}
+	return issues, nil
+}
+
+// doctorRemoveAttachment 在修复事务中删除附件和历史版本，并释放它们引用的文件
+func doctorRemoveAttachment(r *DoctorRepair, attachment *model.Attachment) error {
+	var versions []model.AttachmentVersion
+	if err := r.Tx.Where("attachment_id = ?", attachment.ID).Find(&versions).Error; err != nil {
+		return err
+	}
+	if err := r.Tx.Where("attachment_id = ?", attachment.ID).Delete(&model.AttachmentVersion{}).Error; err != nil {
+		return err
+	}
+	if err := r.Tx.Unscoped().Delete(attachment).Error; err != nil {
+		return err
+	}
+
+	release := func(hash, filePath string) error {
+		if hash == "" {
+			r.DeleteFile(filePath) // 没有哈希的旧附件独占文件
+			return nil
+		}
+		return doctorAdjustBlobRefs(r, hash, -1)
+	}
+	if err := release(attachment.ContentHash, attachment.FilePath); err != nil {
+		return err
+	}
+	for _, version := range versions {
+		if err := release(version.ContentHash, version.FilePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doctorAdjustBlobRefs 在修复事务中调整内容引用数，减为 0 时删除内容记录并登记删除文件和缩略图
+// 按增量调整，多个检查修复同一内容时结果可以叠加
+func doctorAdjustBlobRefs(r *DoctorRepair, hash string, delta int) error {
+	var blob model.AttachmentBlob
+	if err := r.Tx.Where("hash = ?", hash).First(&blob).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	blob.RefCount += delta
+	if blob.RefCount > 0 {
+		return r.Tx.Model(&blob).UpdateColumn("ref_count", blob.RefCount).Error
+	}
+	if err := r.Tx.Delete(&blob).Error; err != nil {
+		return err
+	}
+	r.DeleteFile(blob.FilePath)
+	r.DeleteFile(blob.ThumbnailPath)
+	return nil
+}
+
+// checkAttachmentRefs 内容记录的引用数应等于引用该内容的附件和附件版本记录数；
+// 附件引用了不存在的内容记录时按附件的文件重建内容记录
+func checkAttachmentRefs(db *gorm.DB) ([]DoctorIssue, error) {
+	refCounts := make(map[string]int)
+	for _, table := range []*gorm.DB{db.Unscoped().Model(&model.Attachment{}), db.Model(&model.AttachmentVersion{})} {
+		var rows []struct {
+			ContentHash string
+			Count       int
+		}
+		if err := table.Select("content_hash, COUNT(*) AS count").Where("content_hash <> ''").Group("content_hash").Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			refCounts[row.ContentHash] += row.Count
+		}
+	}
+
+	var blobs []model.AttachmentBlob
+	if err := db.Order("id").Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	var issues []DoctorIssue
+	for _, blob := range blobs {
+		actual := refCounts[blob.Hash]
+		delete(refCounts, blob.Hash)
+		if actual == blob.RefCount {
+			continue
+		}
+		hash, delta := blob.Hash, actual-blob.RefCount
+		message := fmt.Sprintf("引用数 %d 与实际引用 %d 不一致", blob.RefCount, actual)
+		if actual == 0 {
+			message += "，将删除文件 " + blob.FilePath
+		}
+		issues = append(issues, NewDoctorIssue("attachment_blobs#"+blob.Hash, message, func(r *DoctorRepair) error {
+			return doctorAdjustBlobRefs(r, hash, delta)
+		}))
+	}
+
+	// 剩下的是没有内容记录的哈希
+	missing := make([]string, 0, len(refCounts))
+	for hash := range refCounts {
+		missing = append(missing, hash)
+	}
+	sort.Strings(missing)
+	for _, hash := range missing {
+		var attachment model.Attachment
+		if err := db.Unscoped().Where("content_hash = ?", hash).First(&attachment).Error; err != nil {
+			var version model.AttachmentVersion
+			if err := db.Where("content_hash = ?", hash).First(&version).Error; err != nil {
+				return issues, err
+			}
+			attachment = model.Attachment{FilePath: version.FilePath, FileSize: version.FileSize, MimeType: version.MimeType}
+		}
+		blob := model.AttachmentBlob{Hash: hash, FilePath: attachment.FilePath, FileSize: attachment.FileSize, MimeType: attachment.MimeType, RefCount: refCounts[hash]}
+		issues = append(issues, NewDoctorIssue("attachment_blobs#"+hash,
+			fmt.Sprintf("%d 条附件记录引用的内容记录不存在，将按文件 %s 重建", blob.RefCount, blob.FilePath),
+			func(r *DoctorRepair) error {
+				return r.Tx.Create(&blob).Error
+			}))
+	}
+	return issues, nil
+}
+
+// checkAttachmentFiles 附件、附件版本和内容记录引用的文件应存在于附件存储中；
+// 存储中没有被引用的文件（超过一小时的）作为孤立文件删除，缺失的缩略图清除后在访问时重新生成
+func checkAttachmentFiles(db *gorm.DB) ([]DoctorIssue, error) {
+	store, err := AttachmentStorage()
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[string]storage.ObjectInfo)
+	if err := store.List("", func(object storage.ObjectInfo) error {
+		objects[object.Key] = object
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var issues []DoctorIssue
+	referenced := make(map[string]bool)
+	reference := func(object, key string) {
+		if key == "" || referenced[key] {
+			return
+		}
+		referenced[key] = true
+		if _, ok := objects[key]; !ok {
+			issues = append(issues, NewDoctorIssue(object, "文件不存在: "+key, nil))
+		}
+	}
+
+	var attachments []model.Attachment
+	if err := db.Unscoped().Select("id", "file_path").Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		reference(fmt.Sprintf("attachments#%d", attachment.ID), attachment.FilePath)
+	}
+	var versions []model.AttachmentVersion
+	if err := db.Select("id", "attachment_id", "version", "file_path").Order("id").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		reference(fmt.Sprintf("attachments#%d version %d", version.AttachmentID, version.Version), version.FilePath)
+	}
+	var blobs []model.AttachmentBlob
+	if err := db.Order("id").Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		reference("attachment_blobs#"+blob.Hash, blob.FilePath)
+		if blob.ThumbnailPath == "" {
+			continue
+		}
+		if _, ok := objects[blob.ThumbnailPath]; ok {
+			referenced[blob.ThumbnailPath] = true
+			continue
+		}
+		id := blob.ID
+		issues = append(issues, NewDoctorIssue("attachment_blobs#"+blob.Hash, "缩略图不存在: "+blob.ThumbnailPath,
+			func(r *DoctorRepair) error {
+				return r.Tx.Model(&model.AttachmentBlob{}).Where("id = ?", id).UpdateColumn("thumbnail_path", "").Error
+			}))
+	}
+
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cutoff := time.Now().Add(-doctorGracePeriod)
+	for _, key := range keys {
+		object := objects[key]
+		if referenced[key] || object.ModTime.After(cutoff) {
+			continue
+		}
+		issues = append(issues, NewDoctorIssue(key, fmt.Sprintf("没有附件记录引用的文件（%s）", formatSize(object.Size)),
+			func(r *DoctorRepair) error {
+				r.DeleteFile(key)
+				return nil
+			}))
+	}
+	return issues, nil
+}
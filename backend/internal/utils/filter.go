@@ -46,11 +46,31 @@ func GetUserID(c *gin.Context) uint {
 }
 
 // GetUserProjectIDs 获取用户参与的项目ID列表（优化：一次查询，可缓存）
+// 包括直接参与的项目和用户所在项目集内的项目
 func GetUserProjectIDs(db *gorm.DB, userID uint) []uint {
 	var projectIDs []uint
 	db.Model(&model.ProjectMember{}).
 		Where("user_id = ?", userID).
 		Pluck("project_id", &projectIDs)
+
+	var programProjectIDs []uint
+	db.Model(&model.Project{}).
+		Where("program_id IN (?)", db.Model(&model.ProgramMember{}).Select("program_id").Where("user_id = ?", userID)).
+		Pluck("id", &programProjectIDs)
+	if len(programProjectIDs) == 0 {
+		return projectIDs
+	}
+
+	seen := make(map[uint]bool, len(projectIDs))
+	for _, id := range projectIDs {
+		seen[id] = true
+	}
+	for _, id := range programProjectIDs {
+		if !seen[id] {
+			seen[id] = true
+			projectIDs = append(projectIDs, id)
+		}
+	}
 	return projectIDs
 }
 
@@ -67,11 +87,12 @@ func FilterProjectsByUser(db *gorm.DB, c *gin.Context, query *gorm.DB) *gorm.DB
 		return query.Where("1 = 0")
 	}
 
-	// 普通用户只能看到自己参与的项目
+	// 普通用户只能看到自己参与的项目和所在项目集内的项目
 	// 使用 EXISTS 子查询优化性能
 	return query.Where(
-		"EXISTS (SELECT 1 FROM project_members WHERE project_members.project_id = projects.id AND project_members.user_id = ? AND project_members.deleted_at IS NULL)",
-		userID,
+		"EXISTS (SELECT 1 FROM project_members WHERE project_members.project_id = projects.id AND project_members.user_id = ? AND project_members.deleted_at IS NULL) OR "+
+			"EXISTS (SELECT 1 FROM program_members WHERE program_members.program_id = projects.program_id AND program_members.user_id = ? AND program_members.deleted_at IS NULL)",
+		userID, userID,
 	)
 }
 
@@ -91,15 +112,19 @@ func FilterRequirementsByUser(db *gorm.DB, c *gin.Context, query *gorm.DB) *gorm
 	// 先获取用户参与的项目ID列表（优化：一次查询）
 	projectIDs := GetUserProjectIDs(db, userID)
 
+	// 受限需求只对创建人、负责人和访问名单可见
+	query = FilterRestrictedItems(c, "requirement", query)
+
 	// 普通用户只能看到：
 	// 1. 自己创建的需求（creator_id = userID）
 	// 2. 自己负责的需求（assignee_id = userID）
 	// 3. 自己参与的项目中的需求（project_id IN projectIDs）
+	// 4. 自己参与的项目共同实现的项目集级需求（requirement_projects）
 	// 使用 OR 条件组合，注意：如果 projectIDs 为空，只检查前两个条件
 	if len(projectIDs) > 0 {
 		return query.Where(
-			"creator_id = ? OR assignee_id = ? OR project_id IN ?",
-			userID, userID, projectIDs,
+			"creator_id = ? OR assignee_id = ? OR project_id IN ? OR EXISTS (SELECT 1 FROM requirement_projects WHERE requirement_projects.requirement_id = requirements.id AND requirement_projects.project_id IN ?)",
+			userID, userID, projectIDs, projectIDs,
 		)
 	} else {
 		// 如果用户没有参与任何项目，只检查创建者和负责人
@@ -126,6 +151,9 @@ func FilterTasksByUser(db *gorm.DB, c *gin.Context, query *gorm.DB) *gorm.DB {
 	// 先获取用户参与的项目ID列表（优化：一次查询）
 	projectIDs := GetUserProjectIDs(db, userID)
 
+	// 受限任务只对创建人、负责人和访问名单可见
+	query = FilterRestrictedItems(c, "task", query)
+
 	// 普通用户只能看到：
 	// 1. 自己创建的任务（creator_id = userID）
 	// 2. 自己负责的任务（assignee_id = userID）
@@ -160,6 +188,9 @@ func FilterBugsByUser(db *gorm.DB, c *gin.Context, query *gorm.DB) *gorm.DB {
 	// 先获取用户参与的项目ID列表（优化：一次查询）
 	projectIDs := GetUserProjectIDs(db, userID)
 
+	// 受限Bug只对创建人、负责人和访问名单可见
+	query = FilterRestrictedItems(c, "bug", query)
+
 	// 普通用户只能看到：
 	// 1. 自己创建的Bug（creator_id = userID）
 	// 2. 自己分配的Bug（通过 bug_assignees 表）
@@ -182,24 +213,18 @@ func FilterBugsByUser(db *gorm.DB, c *gin.Context, query *gorm.DB) *gorm.DB {
 }
 
 // CheckProjectAccess 检查用户是否有权限访问项目
+// 项目成员可以访问项目；成员角色有对应的项目角色时，还需要项目角色包含 project:read
 func CheckProjectAccess(db *gorm.DB, c *gin.Context, projectID uint) bool {
 	// 管理员可以访问所有项目
 	if IsAdmin(c) {
 		return true
 	}
 
-	userID := GetUserID(c)
-	if userID == 0 {
+	if GetUserID(c) == 0 {
 		return false
 	}
 
-	// 检查用户是否是项目成员（GORM会自动处理软删除，但为了明确性，显式检查deleted_at）
-	var count int64
-	db.Model(&model.ProjectMember{}).
-		Where("project_id = ? AND user_id = ? AND deleted_at IS NULL", projectID, userID).
-		Count(&count)
-
-	return count > 0
+	return checkProjectObjectAccess(db, c, projectID, "project:read", false)
 }
 
 // CheckRequirementAccess 检查用户是否有权限访问需求
@@ -220,13 +245,26 @@ func CheckRequirementAccess(db *gorm.DB, c *gin.Context, requirementID uint) boo
 		return false
 	}
 
-	// 检查是否是创建者或负责人
-	if requirement.CreatorID == userID || (requirement.AssigneeID != nil && *requirement.AssigneeID == userID) {
+	// 受限需求只按访问限制判断
+	if requirement.Confidential {
+		return CanViewRestrictedItem(db, c, "requirement", requirement.ID)
+	}
+
+	// 创建者或负责人可以访问，否则按项目角色检查
+	involved := requirement.CreatorID == userID || (requirement.AssigneeID != nil && *requirement.AssigneeID == userID)
+	if checkProjectObjectAccess(db, c, requirement.ProjectID, "requirement:read", involved) {
 		return true
 	}
 
-	// 检查是否是项目成员
-	return CheckProjectAccess(db, c, requirement.ProjectID)
+	// 项目集级需求：共同实现的项目的成员也可以访问
+	var linkedProjectIDs []uint
+	db.Table("requirement_projects").Where("requirement_id = ?", requirement.ID).Pluck("project_id", &linkedProjectIDs)
+	for _, projectID := range linkedProjectIDs {
+		if checkProjectObjectAccess(db, c, projectID, "requirement:read", false) {
+			return true
+		}
+	}
+	return false
 }
 
 // CheckTaskAccess 检查用户是否有权限访问任务
@@ -247,13 +285,14 @@ func CheckTaskAccess(db *gorm.DB, c *gin.Context, taskID uint) bool {
 		return false
 	}
 
-	// 检查是否是创建者或负责人
-	if task.CreatorID == userID || (task.AssigneeID != nil && *task.AssigneeID == userID) {
-		return true
+	// 受限任务只按访问限制判断
+	if task.Confidential {
+		return CanViewRestrictedItem(db, c, "task", task.ID)
 	}
 
-	// 检查是否是项目成员
-	return CheckProjectAccess(db, c, task.ProjectID)
+	// 创建者或负责人可以访问，否则按项目角色检查
+	involved := task.CreatorID == userID || (task.AssigneeID != nil && *task.AssigneeID == userID)
+	return checkProjectObjectAccess(db, c, task.ProjectID, "task:read", involved)
 }
 
 // CheckBugAccess 检查用户是否有权限访问Bug
@@ -274,20 +313,20 @@ func CheckBugAccess(db *gorm.DB, c *gin.Context, bugID uint) bool {
 		return false
 	}
 
-	// 检查是否是创建者
-	if bug.CreatorID == userID {
-		return true
+	// 保密Bug只按访问限制判断
+	if bug.Confidential {
+		return CanViewRestrictedItem(db, c, "bug", bug.ID)
 	}
 
-	// 检查是否是分配人
+	// 创建者或分配人可以访问，否则按项目角色检查
+	involved := bug.CreatorID == userID
 	for _, assignee := range bug.Assignees {
 		if assignee.ID == userID {
-			return true
+			involved = true
+			break
 		}
 	}
-
-	// 检查是否是项目成员
-	return CheckProjectAccess(db, c, bug.ProjectID)
+	return checkProjectObjectAccess(db, c, bug.ProjectID, "bug:read", involved)
 }
 
 
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// FlowWorkflow 对象类型的流动定义，用于计算前置时间、周期时间和累积流图
+type FlowWorkflow struct {
+	Statuses []string        // 状态顺序（累积流图的层次顺序）
+	Waiting  map[string]bool // 等待状态：尚未开始处理，离开等待状态即开始周期时间
+	Done     map[string]bool // 完成状态：进入完成状态即结束前置时间和周期时间
+}
+
+// FlowWorkflows 支持流动分析的对象类型
+var FlowWorkflows = map[string]FlowWorkflow{
+	"task": {
+		Statuses: []string{"wait", "doing", "pause", "done", "cancel", "closed"},
+		Waiting:  map[string]bool{"wait": true},
+		Done:     map[string]bool{"done": true, "cancel": true, "closed": true},
+	},
+	"bug": {
+		// Bug 创建即进入处理，周期时间与前置时间相同
+		Statuses: []string{"active", "resolved", "closed"},
+		Waiting:  map[string]bool{},
+		Done:     map[string]bool{"resolved": true, "closed": true},
+	},
+	"requirement": {
+		Statuses: []string{"draft", "reviewing", "active", "changing", "closed"},
+		Waiting:  map[string]bool{"draft": true, "reviewing": true},
+		Done:     map[string]bool{"closed": true},
+	},
+}
+
+// StatusTransition 状态变更记录
+type StatusTransition struct {
+	At   time.Time
+	From string
+	To   string
+}
+
+// FlowItem 参与流动分析的对象（任务、Bug或需求）
+type FlowItem struct {
+	ID          uint
+	Title       string
+	Status      string // 当前状态
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Transitions []StatusTransition // 按时间排序的状态变更
+}
+
+// StatusSpan 对象处于某个状态的时间段，End 为零值表示仍处于该状态
+type StatusSpan struct {
+	Status string
+	Start  time.Time
+	End    time.Time
+}
+
+// FlowItemStats 单个对象的流动数据
+type FlowItemStats struct {
+	Spans        []StatusSpan
+	TimeInStatus map[string]float64 // 各状态的停留时长（小时）
+	StartedAt    *time.Time         // 开始处理时间（首次离开等待状态）
+	DoneAt       *time.Time         // 完成时间（当前处于完成状态时，最近一次进入完成状态的时间）
+}
+
+// DurationStats 时长分布统计（单位：天）
+type DurationStats struct {
+	Count   int     `json:"count"`
+	Average float64 `json:"average"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	P50     float64 `json:"p50"`
+	P85     float64 `json:"p85"`
+	P95     float64 `json:"p95"`
+}
+
+// BuildStatusSpans 根据状态变更记录构建对象的状态时间线
+// 历史记录缺失时（例如直接修改数据库）以更新时间补齐到当前状态
+func BuildStatusSpans(item *FlowItem) []StatusSpan {
+	transitions := make([]StatusTransition, len(item.Transitions))
+	copy(transitions, item.Transitions)
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+
+	status := item.Status
+	if len(transitions) > 0 && transitions[0].From != "" {
+		status = transitions[0].From
+	}
+
+	spans := []StatusSpan{{Status: status, Start: item.CreatedAt}}
+	addTransition := func(at time.Time, to string) {
+		current := &spans[len(spans)-1]
+		if to == current.Status {
+			return
+		}
+		if at.Before(current.Start) {
+			at = current.Start
+		}
+		current.End = at
+		spans = append(spans, StatusSpan{Status: to, Start: at})
+	}
+	for _, t := range transitions {
+		addTransition(t.At, t.To)
+	}
+	if last := spans[len(spans)-1]; last.Status != item.Status {
+		at := item.UpdatedAt
+		if at.Before(last.Start) {
+			at = last.Start
+		}
+		addTransition(at, item.Status)
+	}
+	return spans
+}
+
+// ItemStats 计算对象截至 asOf 的流动数据
+func (w FlowWorkflow) ItemStats(item *FlowItem, asOf time.Time) FlowItemStats {
+	stats := FlowItemStats{
+		Spans:        BuildStatusSpans(item),
+		TimeInStatus: make(map[string]float64),
+	}
+
+	for _, span := range stats.Spans {
+		if span.Start.After(asOf) {
+			break
+		}
+		end := span.End
+		if end.IsZero() || end.After(asOf) {
+			end = asOf
+		}
+		stats.TimeInStatus[span.Status] += end.Sub(span.Start).Hours()
+
+		if stats.StartedAt == nil && !w.Waiting[span.Status] {
+			start := span.Start
+			stats.StartedAt = &start
+		}
+		// 最近一次连续处于完成状态的起点（如 resolved -> closed 从 resolved 算起）
+		if w.Done[span.Status] {
+			if stats.DoneAt == nil {
+				start := span.Start
+				stats.DoneAt = &start
+			}
+		} else {
+			stats.DoneAt = nil
+		}
+	}
+	return stats
+}
+
+// StatusAt 获取对象在某一时刻的状态，对象尚未创建时返回空字符串
+func StatusAt(spans []StatusSpan, at time.Time) string {
+	status := ""
+	for _, span := range spans {
+		if span.Start.After(at) {
+			break
+		}
+		status = span.Status
+	}
+	return status
+}
+
+// NewDurationStats 计算时长分布（百分位数使用线性插值）
+func NewDurationStats(values []float64) DurationStats {
+	if len(values) == 0 {
+		return DurationStats{}
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	return DurationStats{
+		Count:   len(sorted),
+		Average: RoundFlow(sum / float64(len(sorted))),
+		Min:     RoundFlow(sorted[0]),
+		Max:     RoundFlow(sorted[len(sorted)-1]),
+		P50:     RoundFlow(Percentile(sorted, 50)),
+		P85:     RoundFlow(Percentile(sorted, 85)),
+		P95:     RoundFlow(Percentile(sorted, 95)),
+	}
+}
+
+// Percentile 计算已排序数据的百分位数（线性插值）
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// RoundFlow 保留两位小数
+func RoundFlow(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// WeekStart 获取日期所在周的周一（零点）
+func WeekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git 托管平台
+const (
+	GitProviderGitHub = "github"
+	GitProviderGitLab = "gitlab"
+	GitProviderGitea  = "gitea"
+)
+
+// Git 事件类型
+const (
+	GitEventPush         = "push"
+	GitEventMergeRequest = "merge_request"
+)
+
+// GitCommit 推送中的一个提交
+type GitCommit struct {
+	ID          string
+	Message     string
+	URL         string
+	AuthorName  string
+	AuthorEmail string
+	Timestamp   *time.Time
+}
+
+// GitMergeRequest 合并请求（GitHub/Gitea 为 Pull Request）
+type GitMergeRequest struct {
+	Number       int
+	Title        string
+	Description  string
+	URL          string
+	State        string // opened, merged, closed
+	SourceBranch string
+	TargetBranch string
+	AuthorName   string
+	AuthorEmail  string
+}
+
+// GitEvent 统一格式的 Git Webhook 事件
+type GitEvent struct {
+	Provider      string
+	Kind          string // push, merge_request；其他事件为空
+	Repository    string
+	DefaultBranch string
+	Ref           string // 推送的分支（refs/heads/...）
+	Commits       []GitCommit
+	MergeRequest  *GitMergeRequest
+}
+
+// PushToDefaultBranch 是否推送到默认分支（无法确定默认分支时视为是）
+func (e *GitEvent) PushToDefaultBranch() bool {
+	if e.DefaultBranch == "" {
+		return true
+	}
+	return e.Ref == "refs/heads/"+e.DefaultBranch
+}
+
+// DetectGitProvider 根据请求头识别 Git 托管平台
+// Gitea 同时发送 X-GitHub-Event 和 X-Gitea-Event 头，需优先判断
+func DetectGitProvider(header http.Header) string {
+	switch {
+	case header.Get("X-Gitea-Event") != "":
+		return GitProviderGitea
+	case header.Get("X-Gitlab-Event") != "":
+		return GitProviderGitLab
+	case header.Get("X-GitHub-Event") != "":
+		return GitProviderGitHub
+	}
+	return ""
+}
+
+// VerifyGitWebhook 校验 Webhook 请求：GitHub/Gitea 校验 HMAC-SHA256 签名，GitLab 校验令牌
+func VerifyGitWebhook(provider string, header http.Header, body []byte, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	switch provider {
+	case GitProviderGitLab:
+		token := header.Get("X-Gitlab-Token")
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	case GitProviderGitHub, GitProviderGitea:
+		signature := header.Get("X-Hub-Signature-256")
+		if provider == GitProviderGitea && header.Get("X-Gitea-Signature") != "" {
+			signature = header.Get("X-Gitea-Signature")
+		}
+		signature = strings.TrimPrefix(signature, "sha256=")
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected))
+	}
+	return false
+}
+
+// 各平台 Webhook 载荷中用到的字段
+type gitPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		FullName      string `json:"full_name"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	Project struct { // GitLab
+		PathWithNamespace string `json:"path_with_namespace"`
+		DefaultBranch     string `json:"default_branch"`
+	} `json:"project"`
+	Commits []struct {
+		ID        string `json:"id"`
+		Message   string `json:"message"`
+		URL       string `json:"url"`
+		Timestamp string `json:"timestamp"`
+		Author    struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"author"`
+	} `json:"commits"`
+
+	// GitHub / Gitea
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest *struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+		State   string `json:"state"`
+		Merged  bool   `json:"merged"`
+		Head    struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		User struct {
+			Login string `json:"login"`
+			Email string `json:"email"`
+		} `json:"user"`
+	} `json:"pull_request"`
+
+	// GitLab
+	ObjectKind       string `json:"object_kind"`
+	ObjectAttributes *struct {
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		URL          string `json:"url"`
+		State        string `json:"state"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+	} `json:"object_attributes"`
+	User struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"user"`
+}
+
+// ParseGitWebhook 解析 Webhook 载荷，event 为平台的事件请求头；不关心的事件返回 Kind 为空的事件
+func ParseGitWebhook(provider, event string, body []byte) (*GitEvent, error) {
+	var payload gitPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("Webhook 载荷解析失败: %w", err)
+	}
+
+	result := &GitEvent{Provider: provider, Ref: payload.Ref}
+	if provider == GitProviderGitLab {
+		result.Repository = payload.Project.PathWithNamespace
+		result.DefaultBranch = payload.Project.DefaultBranch
+	} else {
+		result.Repository = payload.Repository.FullName
+		result.DefaultBranch = payload.Repository.DefaultBranch
+	}
+
+	switch {
+	case event == "push" || event == "Push Hook":
+		result.Kind = GitEventPush
+		for _, commit := range payload.Commits {
+			gc := GitCommit{ID: commit.ID, Message: commit.Message, URL: commit.URL, AuthorName: commit.Author.Name, AuthorEmail: commit.Author.Email}
+			if ts, err := time.Parse(time.RFC3339, commit.Timestamp); err == nil {
+				gc.Timestamp = &ts
+			}
+			result.Commits = append(result.Commits, gc)
+		}
+	case (event == "pull_request") && payload.PullRequest != nil:
+		pr := payload.PullRequest
+		state := "opened"
+		if pr.Merged {
+			state = "merged"
+		} else if pr.State == "closed" {
+			state = "closed"
+		}
+		result.Kind = GitEventMergeRequest
+		result.MergeRequest = &GitMergeRequest{
+			Number: pr.Number, Title: pr.Title, Description: pr.Body, URL: pr.HTMLURL, State: state,
+			SourceBranch: pr.Head.Ref, TargetBranch: pr.Base.Ref, AuthorName: pr.User.Login, AuthorEmail: pr.User.Email,
+		}
+	case event == "Merge Request Hook" && payload.ObjectAttributes != nil:
+		mr := payload.ObjectAttributes
+		state := mr.State
+		if state != "merged" && state != "closed" {
+			state = "opened"
+		}
+		result.Kind = GitEventMergeRequest
+		result.MergeRequest = &GitMergeRequest{
+			Number: mr.IID, Title: mr.Title, Description: mr.Description, URL: mr.URL, State: state,
+			SourceBranch: mr.SourceBranch, TargetBranch: mr.TargetBranch, AuthorName: payload.User.Name, AuthorEmail: payload.User.Email,
+		}
+	}
+	return result, nil
+}
+
+// GitReference 提交信息或合并请求中引用的工作项
+type GitReference struct {
+	ObjectType string // bug, task, requirement
+	ObjectID   uint
+	Close      bool // 带有 fix/close/resolve/修复/解决/关闭 等关键字，表示完成该工作项
+}
+
+var gitReferencePattern = regexp.MustCompile(`(?i)(?:\b(fix|fixes|fixed|close|closes|closed|resolve|resolves|resolved)\s*[:：]?\s*|(修复|解决|关闭)\s*[:：]?\s*)?\b(bug|task|story|req|requirement)\s*#(\d+)`)
+
+var gitReferenceTypes = map[string]string{"bug": "bug", "task": "task", "story": "requirement", "req": "requirement", "requirement": "requirement"}
+
+// ParseGitReferences 解析文本中的工作项引用，如 "fix bug#123"、"task #45"、"修复 bug#7"；同一工作项只返回一次
+func ParseGitReferences(text string) []GitReference {
+	var refs []GitReference
+	index := make(map[string]int)
+	for _, match := range gitReferencePattern.FindAllStringSubmatch(text, -1) {
+		id, err := strconv.ParseUint(match[4], 10, 32)
+		if err != nil || id == 0 {
+			continue
+		}
+		ref := GitReference{
+			ObjectType: gitReferenceTypes[strings.ToLower(match[3])],
+			ObjectID:   uint(id),
+			Close:      match[1] != "" || match[2] != "",
+		}
+		key := fmt.Sprintf("%s#%d", ref.ObjectType, ref.ObjectID)
+		if i, ok := index[key]; ok {
+			refs[i].Close = refs[i].Close || ref.Close
+			continue
+		}
+		index[key] = len(refs)
+		refs = append(refs, ref)
+	}
+	return refs
+}
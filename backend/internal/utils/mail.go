@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"prjflow/internal/config"
+)
+
+// SendMail 发送纯文本邮件，未启用邮件配置时返回 ErrMailDisabled
+// 定义为变量以便测试时替换
+var SendMail = sendSMTPMail
+
+// ErrMailDisabled 未启用邮件发送
+var ErrMailDisabled = errors.New("邮件发送未启用")
+
+// MailEnabled 是否启用了邮件发送
+func MailEnabled() bool {
+	return config.AppConfig != nil && config.AppConfig.Mail.Enabled && config.AppConfig.Mail.Host != ""
+}
+
+func sendSMTPMail(to []string, subject, body string) error {
+	if !MailEnabled() {
+		return ErrMailDisabled
+	}
+	cfg := config.AppConfig.Mail
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 25
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + from + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return smtp.SendMail(fmt.Sprintf("%s:%d", cfg.Host, port), auth, from, to, []byte(msg.String()))
+}
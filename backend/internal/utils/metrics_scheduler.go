@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"prjflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	metricsScheduler     *MetricsScheduler
+	metricsSchedulerOnce sync.Once
+)
+
+// MetricsScheduler 项目指标快照定时任务调度器：每天凌晨保存前一天的项目指标快照
+type MetricsScheduler struct {
+	db    *gorm.DB
+	timer *time.Timer
+	mu    sync.Mutex
+}
+
+// GetMetricsScheduler 获取指标快照调度器单例
+func GetMetricsScheduler(db *gorm.DB) *MetricsScheduler {
+	metricsSchedulerOnce.Do(func() {
+		metricsScheduler = &MetricsScheduler{db: db}
+	})
+	return metricsScheduler
+}
+
+// Start 启动定时任务，并在后台回填各项目创建以来缺少的快照
+// 读取指标时不回填，缺少的历史快照只在这里补齐；已有快照的日期直接跳过，只有首次启动需要计算
+func (s *MetricsScheduler) Start() {
+	go func() {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		total, err := BackfillAllProjectHistory(s.db, today.AddDate(0, 0, -1))
+		if Logger == nil {
+			return
+		}
+		if err != nil {
+			Logger.Errorf("[Scheduler] Metrics history backfill failed: %v", err)
+			return
+		}
+		Logger.Infof("[Scheduler] Metrics history backfill completed: %d project-days saved", total)
+	}()
+	s.schedule()
+}
+
+// schedule 调度下次执行（每天 00:05）
+func (s *MetricsScheduler) schedule() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+
+	now := time.Now()
+	nextTime := time.Date(now.Year(), now.Month(), now.Day(), 0, 5, 0, 0, now.Location())
+	if !nextTime.After(now) {
+		nextTime = nextTime.AddDate(0, 0, 1)
+	}
+	if Logger != nil {
+		Logger.Infof("[Scheduler] Next metrics snapshot scheduled at: %s", nextTime.Format("2006-01-02 15:04:05"))
+	}
+
+	s.timer = time.AfterFunc(time.Until(nextTime), func() {
+		yesterday := time.Now().AddDate(0, 0, -1)
+		s.snapshotAll(yesterday, yesterday, true)
+		s.schedule()
+	})
+}
+
+// Stop 停止定时任务
+func (s *MetricsScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+		if Logger != nil {
+			Logger.Info("[Scheduler] Metrics scheduler stopped")
+		}
+	}
+}
+
+// snapshotAll 为所有项目保存日期范围内的指标快照
+func (s *MetricsScheduler) snapshotAll(start, end time.Time, overwrite bool) {
+	total, err := BackfillAllProjectMetrics(s.db, start, end, overwrite)
+	if Logger == nil {
+		return
+	}
+	if err != nil {
+		Logger.Errorf("[Scheduler] Metrics snapshot failed: %v", err)
+		return
+	}
+	Logger.Infof("[Scheduler] Metrics snapshot completed: %d project-days saved", total)
+}
+
+// BackfillAllProjectMetrics 为所有项目回填日期范围内的指标快照，返回回填的项目天数
+// 单个项目失败不影响其他项目，返回遇到的第一个错误
+func BackfillAllProjectMetrics(db *gorm.DB, start, end time.Time, overwrite bool) (int, error) {
+	var projectIDs []uint
+	if err := db.Model(&model.Project{}).Pluck("id", &projectIDs).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0
+	var firstErr error
+	for _, projectID := range projectIDs {
+		count, err := BackfillProjectMetrics(db, projectID, start, end, overwrite)
+		total += count
+		if err != nil {
+			if Logger != nil {
+				Logger.Warnf("[Scheduler] Failed to snapshot metrics of project %d: %v", projectID, err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return total, firstErr
+}
+
+// BackfillAllProjectHistory 为所有项目回填从项目创建日到 end 缺少的指标快照，返回回填的项目天数
+// 单个项目失败不影响其他项目，返回遇到的第一个错误
+func BackfillAllProjectHistory(db *gorm.DB, end time.Time) (int, error) {
+	var projects []model.Project
+	if err := db.Select("id, created_at").Find(&projects).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0
+	var firstErr error
+	for _, project := range projects {
+		count, err := BackfillProjectMetrics(db, project.ID, project.CreatedAt, end, false)
+		total += count
+		if err != nil {
+			if Logger != nil {
+				Logger.Warnf("[Scheduler] Failed to backfill metrics of project %d: %v", project.ID, err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return total, firstErr
+}
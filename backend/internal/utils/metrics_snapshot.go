@@ -0,0 +1,419 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"prjflow/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 指标快照的日期格式
+const MetricDateLayout = "2006-01-02"
+
+// 指标快照记录的对象类型及对应表名
+var metricObjectTables = []struct {
+	objectType string
+	table      string
+	fields     []string // 需要按历史记录还原的字段
+}{
+	{"task", "tasks", []string{"status", "priority"}},
+	{"bug", "bugs", []string{"status", "priority", "severity"}},
+	{"requirement", "requirements", []string{"status", "priority"}},
+}
+
+// metricItem 计算指标所需的对象数据
+type metricItem struct {
+	id        uint
+	createdAt time.Time
+	deletedAt *time.Time
+	fields    map[string][]StatusSpan // 字段取值的时间线
+	estimated float64                 // 预估工时（仅任务）
+}
+
+// existsAt 对象在某一时刻是否存在（已创建且未删除）
+func (i *metricItem) existsAt(at time.Time) bool {
+	return !i.createdAt.After(at) && (i.deletedAt == nil || i.deletedAt.After(at))
+}
+
+// metricAllocation 任务的工时记录
+type metricAllocation struct {
+	taskID uint
+	date   time.Time
+	hours  float64
+}
+
+// ProjectMetricSource 项目指标的数据源：一次加载项目的对象、字段变更历史和工时记录，
+// 之后可以计算任意一天的指标，回填多天时不需要重复查询
+type ProjectMetricSource struct {
+	projectID   uint
+	items       map[string][]*metricItem
+	allocations []metricAllocation
+}
+
+// LoadProjectMetricSource 加载项目指标的数据源（包含已删除的对象，用于还原删除前的历史数据）
+func LoadProjectMetricSource(db *gorm.DB, projectID uint) (*ProjectMetricSource, error) {
+	return loadProjectMetricSource(db, projectID, func(objectType string, query *gorm.DB) *gorm.DB { return query })
+}
+
+// loadHiddenMetricSource 加载项目中当前用户不可见的受限对象的指标数据源，用于从项目整体的指标中扣除
+func loadHiddenMetricSource(db *gorm.DB, c *gin.Context, projectID uint) (*ProjectMetricSource, error) {
+	return loadProjectMetricSource(db, projectID, func(objectType string, query *gorm.DB) *gorm.DB {
+		return FilterHiddenRestrictedItems(c, objectType, query)
+	})
+}
+
+// loadProjectMetricSource 加载项目指标的数据源，scope 用于筛选对象
+func loadProjectMetricSource(db *gorm.DB, projectID uint, scope func(objectType string, query *gorm.DB) *gorm.DB) (*ProjectMetricSource, error) {
+	source := &ProjectMetricSource{projectID: projectID, items: make(map[string][]*metricItem)}
+
+	for _, object := range metricObjectTables {
+		var rows []struct {
+			ID             uint
+			CreatedAt      time.Time
+			UpdatedAt      time.Time
+			DeletedAt      *time.Time
+			Status         string
+			Priority       string
+			Severity       string
+			EstimatedHours *float64
+		}
+		columns := "id, created_at, updated_at, deleted_at, status, priority"
+		if object.objectType == "bug" {
+			columns += ", severity"
+		}
+		if object.objectType == "task" {
+			columns += ", estimated_hours"
+		}
+		if err := scope(object.objectType, db.Table(object.table).Select(columns).Where("project_id = ?", projectID)).Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("加载%s失败: %w", object.objectType, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		// 字段变更历史
+		var histories []struct {
+			ObjectID uint
+			Date     time.Time
+			Field    string
+			Old      string
+			New      string
+		}
+		if err := db.Table("histories").
+			Select("actions.object_id, actions.date, histories.field, histories.old, histories.new").
+			Joins("JOIN actions ON actions.id = histories.action_id").
+			Where("actions.object_type = ? AND histories.field IN ?", object.objectType, object.fields).
+			Where("actions.object_id IN (?)", scope(object.objectType, db.Table(object.table).Select("id").Where("project_id = ?", projectID))).
+			Order("actions.date ASC, histories.id ASC").
+			Scan(&histories).Error; err != nil {
+			return nil, fmt.Errorf("加载%s历史记录失败: %w", object.objectType, err)
+		}
+		transitions := make(map[uint]map[string][]StatusTransition)
+		for _, h := range histories {
+			if transitions[h.ObjectID] == nil {
+				transitions[h.ObjectID] = make(map[string][]StatusTransition)
+			}
+			transitions[h.ObjectID][h.Field] = append(transitions[h.ObjectID][h.Field], StatusTransition{At: h.Date, From: h.Old, To: h.New})
+		}
+
+		for _, row := range rows {
+			item := &metricItem{
+				id:        row.ID,
+				createdAt: row.CreatedAt,
+				deletedAt: row.DeletedAt,
+				fields:    make(map[string][]StatusSpan),
+			}
+			if row.EstimatedHours != nil {
+				item.estimated = *row.EstimatedHours
+			}
+			current := map[string]string{"status": row.Status, "priority": row.Priority, "severity": row.Severity}
+			for _, field := range object.fields {
+				item.fields[field] = BuildStatusSpans(&FlowItem{
+					Status:      current[field],
+					CreatedAt:   row.CreatedAt,
+					UpdatedAt:   row.UpdatedAt,
+					Transitions: transitions[item.id][field],
+				})
+			}
+			source.items[object.objectType] = append(source.items[object.objectType], item)
+		}
+	}
+
+	// 任务工时记录
+	if len(source.items["task"]) == 0 {
+		return source, nil
+	}
+	var allocations []struct {
+		TaskID uint
+		Date   time.Time
+		Hours  float64
+	}
+	if err := db.Model(&model.ResourceAllocation{}).
+		Select("task_id, date, hours").
+		Where("task_id IN (?)", scope("task", db.Table("tasks").Select("id").Where("project_id = ?", projectID))).
+		Scan(&allocations).Error; err != nil {
+		return nil, fmt.Errorf("加载工时记录失败: %w", err)
+	}
+	for _, a := range allocations {
+		source.allocations = append(source.allocations, metricAllocation{taskID: a.TaskID, date: a.Date, hours: a.Hours})
+	}
+
+	return source, nil
+}
+
+// empty 数据源中是否没有对象
+func (s *ProjectMetricSource) empty() bool {
+	for _, items := range s.items {
+		if len(items) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MetricsAt 计算某一天结束时（或 asOf 时刻，取较早者）的项目指标
+func (s *ProjectMetricSource) MetricsAt(day time.Time, asOf time.Time) []model.ProjectMetricSnapshot {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	at := dayStart.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	if at.After(asOf) {
+		at = asOf
+	}
+	date := dayStart.Format(MetricDateLayout)
+
+	values := make(map[[3]string]float64)
+	add := func(objectType, dimension, name string, value float64) {
+		values[[3]string{objectType, dimension, name}] += value
+	}
+
+	openTasks := make(map[uint]float64) // 当天结束时未完成的任务及其预估工时
+	for _, object := range metricObjectTables {
+		workflow := FlowWorkflows[object.objectType]
+		add(object.objectType, "created", "total", 0)
+		for _, item := range s.items[object.objectType] {
+			if !item.createdAt.Before(dayStart) && !item.createdAt.After(at) {
+				add(object.objectType, "created", "total", 1)
+			}
+			if !item.existsAt(at) {
+				continue
+			}
+			status := StatusAt(item.fields["status"], at)
+			add(object.objectType, "status", status, 1)
+			if workflow.Done[status] {
+				continue
+			}
+			// 优先级和严重程度只统计未完成的对象
+			for _, field := range object.fields {
+				if field == "status" {
+					continue
+				}
+				if value := StatusAt(item.fields[field], at); value != "" {
+					add(object.objectType, field, value, 1)
+				}
+			}
+			if object.objectType == "task" {
+				openTasks[item.id] = item.estimated
+			}
+		}
+	}
+
+	// 工时：累计完成工时和未完成任务的剩余工时（预估工时减去已记录工时）
+	logged := make(map[uint]float64)
+	completed := 0.0
+	for _, a := range s.allocations {
+		if a.date.After(at) {
+			continue
+		}
+		logged[a.taskID] += a.hours
+		completed += a.hours
+	}
+	remaining := 0.0
+	for taskID, estimated := range openTasks {
+		if left := estimated - logged[taskID]; left > 0 {
+			remaining += left
+		}
+	}
+	add("task", "hours", "completed", RoundFlow(completed))
+	add("task", "hours", "remaining", RoundFlow(remaining))
+
+	snapshots := make([]model.ProjectMetricSnapshot, 0, len(values))
+	for key, value := range values {
+		snapshots = append(snapshots, model.ProjectMetricSnapshot{
+			ProjectID:  s.projectID,
+			Date:       date,
+			ObjectType: key[0],
+			Dimension:  key[1],
+			Name:       key[2],
+			Value:      value,
+		})
+	}
+	return snapshots
+}
+
+// SaveProjectMetrics 保存项目某一天的指标快照（替换当天已有的快照）
+func SaveProjectMetrics(db *gorm.DB, projectID uint, date string, snapshots []model.ProjectMetricSnapshot) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ? AND date = ?", projectID, date).Delete(&model.ProjectMetricSnapshot{}).Error; err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(snapshots, 100).Error
+	})
+}
+
+// BackfillProjectMetrics 根据历史记录回填项目在日期范围内（包含起止日期）的指标快照
+// 只回填今天之前的日期（今天的数据尚未结束），overwrite 为 false 时跳过已有快照的日期，返回回填的天数
+// 每天的快照至少包含各类对象的新建数量和工时（可能为0），作为该日已处理的标记，没有数据的日期也不会重复计算
+func BackfillProjectMetrics(db *gorm.DB, projectID uint, start, end time.Time, overwrite bool) (int, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, now.Location())
+	end = time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, now.Location())
+	if !end.Before(today) {
+		end = today.AddDate(0, 0, -1)
+	}
+	if start.After(end) {
+		return 0, nil
+	}
+
+	existing := make(map[string]bool)
+	if !overwrite {
+		var dates []string
+		db.Model(&model.ProjectMetricSnapshot{}).
+			Where("project_id = ? AND date >= ? AND date <= ?", projectID, start.Format(MetricDateLayout), end.Format(MetricDateLayout)).
+			Distinct("date").Pluck("date", &dates)
+		for _, d := range dates {
+			existing[d] = true
+		}
+	}
+
+	var source *ProjectMetricSource
+	count := 0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(MetricDateLayout)
+		if existing[date] {
+			continue
+		}
+		if source == nil {
+			var err error
+			if source, err = LoadProjectMetricSource(db, projectID); err != nil {
+				return count, err
+			}
+		}
+		if err := SaveProjectMetrics(db, projectID, date, source.MetricsAt(day, now)); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MetricDay 某一天的指标，多个项目时为各项目之和
+type MetricDay struct {
+	Date   string
+	values map[[3]string]float64
+}
+
+// Get 获取指标值
+func (d *MetricDay) Get(objectType, dimension, name string) float64 {
+	return d.values[[3]string{objectType, dimension, name}]
+}
+
+// Group 获取某个维度下的全部指标值，例如 Bug 各状态的数量
+func (d *MetricDay) Group(objectType, dimension string) map[string]float64 {
+	result := make(map[string]float64)
+	for key, value := range d.values {
+		if key[0] == objectType && key[1] == dimension {
+			result[key[2]] = value
+		}
+	}
+	return result
+}
+
+// OpenCount 未完成对象的数量（按流动分析工作流的完成状态判断）
+func (d *MetricDay) OpenCount(objectType string) float64 {
+	done := FlowWorkflows[objectType].Done
+	count := 0.0
+	for status, value := range d.Group(objectType, "status") {
+		if !done[status] {
+			count += value
+		}
+	}
+	return count
+}
+
+// ProjectMetricSeries 获取项目在日期范围内（包含起止日期）每天的指标，多个项目时按天汇总
+// 今天之前的指标读取快照（由指标调度器保存，读取时不回填，缺少快照的日期为0），今天的指标实时计算（不保存）
+// 快照为项目整体的数量统计，包含受限对象；c 不为空时扣除当前用户不可见的受限对象（管理员不扣除）
+func ProjectMetricSeries(db *gorm.DB, c *gin.Context, projectIDs []uint, start, end time.Time) ([]*MetricDay, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, now.Location())
+	if end.After(today) {
+		end = today
+	}
+
+	days := make([]*MetricDay, 0)
+	byDate := make(map[string]*MetricDay)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		metricDay := &MetricDay{Date: day.Format(MetricDateLayout), values: make(map[[3]string]float64)}
+		days = append(days, metricDay)
+		byDate[metricDay.Date] = metricDay
+	}
+	if len(days) == 0 || len(projectIDs) == 0 {
+		return days, nil
+	}
+
+	var snapshots []model.ProjectMetricSnapshot
+	if err := db.Where("project_id IN ? AND date >= ? AND date <= ?", projectIDs, start.Format(MetricDateLayout), end.Format(MetricDateLayout)).
+		Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	if !end.Before(today) {
+		for _, projectID := range projectIDs {
+			source, err := LoadProjectMetricSource(db, projectID)
+			if err != nil {
+				return nil, err
+			}
+			snapshots = append(snapshots, source.MetricsAt(today, now)...)
+		}
+	}
+
+	loaded := make(map[uint]map[string]bool) // 有快照的项目和日期
+	for _, snapshot := range snapshots {
+		if day, ok := byDate[snapshot.Date]; ok {
+			day.values[[3]string{snapshot.ObjectType, snapshot.Dimension, snapshot.Name}] += snapshot.Value
+			if loaded[snapshot.ProjectID] == nil {
+				loaded[snapshot.ProjectID] = make(map[string]bool)
+			}
+			loaded[snapshot.ProjectID][snapshot.Date] = true
+		}
+	}
+
+	// 扣除当前用户不可见的受限对象（只扣除有快照的日期）
+	if c != nil && !IsAdmin(c) {
+		for _, projectID := range projectIDs {
+			hidden, err := loadHiddenMetricSource(db, c, projectID)
+			if err != nil {
+				return nil, err
+			}
+			if hidden.empty() {
+				continue
+			}
+			for i, day := 0, start; i < len(days); i, day = i+1, day.AddDate(0, 0, 1) {
+				if !loaded[projectID][days[i].Date] {
+					continue
+				}
+				for _, snapshot := range hidden.MetricsAt(day, now) {
+					key := [3]string{snapshot.ObjectType, snapshot.Dimension, snapshot.Name}
+					days[i].values[key] = RoundFlow(days[i].values[key] - snapshot.Value)
+				}
+			}
+		}
+	}
+	return days, nil
+}
@@ -20,18 +20,39 @@ func AutoMigrate(db *gorm.DB) error {
 	}
 
 	// 执行 AutoMigrate
-	err := db.AutoMigrate(
+	err := db.AutoMigrate(Models()...)
+
+	// AutoMigrate 之后，再次清理可能产生的临时表
+	if config.AppConfig.Database.Type == "sqlite" {
+		cleanupTemporaryTables(db)
+	}
+
+	// 初始化默认权限和角色
+	if err := initDefaultPermissionsAndRoles(db); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// Models 主数据库中的所有模型（多对多关联表由 GORM 随模型一起创建）
+func Models() []interface{} {
+	return []interface{}{
 		// 用户与权限
 		&model.User{},
 		&model.Department{},
 		&model.Role{},
 		&model.Permission{},
+		&model.ProjectRole{},
 
 		// 标签
 		&model.Tag{},
 		// 项目
 		&model.Project{},
 		&model.ProjectMember{},
+		&model.ProjectTemplate{},
+		&model.Program{},
+		&model.ProgramMember{},
 		// 功能模块
 		&model.Module{},
 
@@ -39,6 +60,7 @@ func AutoMigrate(db *gorm.DB) error {
 		&model.Requirement{},
 		&model.Bug{},
 		&model.BugAssignee{},
+		&model.ItemAccess{},
 
 		// 操作记录与历史
 		&model.Action{},
@@ -49,13 +71,28 @@ func AutoMigrate(db *gorm.DB) error {
 		&model.TaskDependency{},
 		&model.Board{},
 		&model.BoardColumn{},
+		&model.BoardCard{},
 
 		// 版本
 		&model.Version{},
+		&model.ReleaseGate{},
+		&model.Build{},
+		&model.Environment{},
+		&model.Deployment{},
 
 		// 测试
 		&model.TestCase{},
 		&model.TestCaseBug{},
+		&model.TestCaseStep{},
+		&model.TestSuite{},
+		&model.TestRun{},
+		&model.TestRunCase{},
+		&model.TestStepResult{},
+		&model.APIToken{},
+
+		// 代码关联
+		&model.GitWebhook{},
+		&model.CodeLink{},
 
 		// 资源管理
 		&model.Resource{},
@@ -66,6 +103,10 @@ func AutoMigrate(db *gorm.DB) error {
 		&model.WeeklyReport{},
 		&model.DailyReportApproval{},
 		&model.WeeklyReportApproval{},
+		&model.ReportApprovalChain{},
+		&model.ReportApprovalStep{},
+		&model.ApprovalDelegation{},
+		&model.Notification{},
 
 		// 插件管理
 		&model.Plugin{},
@@ -78,12 +119,17 @@ func AutoMigrate(db *gorm.DB) error {
 
 		// 工作台
 		&model.UserDashboard{},
+		&model.Dashboard{},
+		// 项目每日指标快照
+		&model.ProjectMetricSnapshot{},
 
 		// 用户表格列设置
 		&model.UserTableColumnSetting{},
 
 		// 系统配置
 		&model.SystemConfig{},
+		// 导入映射
+		&model.ImportMapping{},
 
 		// 附件
 		&model.Attachment{},
@@ -91,20 +137,13 @@ func AutoMigrate(db *gorm.DB) error {
 		&model.RequirementAttachment{},
 		&model.TaskAttachment{},
 		&model.BugAttachment{},
+		&model.TestCaseAttachment{},
+		&model.DailyReportAttachment{},
+		&model.WeeklyReportAttachment{},
+		&model.AttachmentBlob{},
+		&model.AttachmentVersion{},
 		// 注意：审计日志表（AuditLog）不在主数据库中迁移，而是在审计日志数据库中迁移
-	)
-
-	// AutoMigrate 之后，再次清理可能产生的临时表
-	if config.AppConfig.Database.Type == "sqlite" {
-		cleanupTemporaryTables(db)
-	}
-
-	// 初始化默认权限和角色
-	if err := initDefaultPermissionsAndRoles(db); err != nil {
-		return err
 	}
-
-	return err
 }
 
 // MigrateAuditDB 迁移审计日志数据库
@@ -117,11 +156,12 @@ func MigrateAuditDB(db *gorm.DB, auditDB *gorm.DB) error {
 	}
 
 	// 迁移审计日志表
-	if err := targetDB.AutoMigrate(&model.AuditLog{}); err != nil {
+	if err := targetDB.AutoMigrate(&model.AuditLog{}, &model.ArchivedAuditLog{}, &model.AuditChainState{}); err != nil {
 		return err
 	}
 
-	return nil
+	// 已启用哈希链的数据库补充记录链的起点
+	return ensureAuditChainGenesis(targetDB)
 }
 
 // initDefaultPermissionsAndRoles 初始化默认权限和角色
@@ -146,6 +186,12 @@ func initDefaultPermissionsAndRoles(db *gorm.DB) error {
 		{Code: "project:delete", Name: "删除项目", Resource: "project", Action: "delete", Description: "删除项目", Status: 1},
 		{Code: "project:manage", Name: "管理项目", Resource: "project", Action: "manage", Description: "管理项目成员和设置", Status: 1},
 
+		// 项目集权限（操作权限）
+		{Code: "program:read", Name: "查看项目集", Resource: "program", Action: "read", Description: "查看项目集和组合看板", Status: 1},
+		{Code: "program:create", Name: "创建项目集", Resource: "program", Action: "create", Description: "创建新项目集", Status: 1},
+		{Code: "program:update", Name: "更新项目集", Resource: "program", Action: "update", Description: "更新项目集信息、项目和成员", Status: 1},
+		{Code: "program:delete", Name: "删除项目集", Resource: "program", Action: "delete", Description: "删除项目集", Status: 1},
+
 		// 项目管理菜单（父菜单）
 		{Code: "project-management", Name: "项目管理", Resource: "project", Action: "read", Description: "项目管理", Status: 1, IsMenu: true, MenuIcon: "ProjectOutlined", MenuTitle: "项目管理", MenuOrder: 1},
 		// 项目列表（子菜单）
@@ -373,6 +419,7 @@ func initDefaultPermissionsAndRoles(db *gorm.DB) error {
 				"project-management",          // 项目管理菜单
 				"project:list",                // 项目列表
 				"project:read",                // 查看项目
+				"program:read",                // 查看项目集
 				"requirement:menu",            // 需求管理菜单
 				"requirement:read",            // 查看需求
 				"task:read",                   // 任务管理（菜单和查看）
@@ -406,6 +453,9 @@ func initDefaultPermissionsAndRoles(db *gorm.DB) error {
 				"project:read",                // 查看项目
 				"project:update",              // 更新项目
 				"project:manage",              // 管理项目
+				"program:read",                // 查看项目集
+				"program:create",              // 创建项目集
+				"program:update",              // 更新项目集
 				"requirement:menu",            // 需求管理菜单
 				"requirement:read",            // 查看需求
 				"requirement:create",          // 创建需求
@@ -536,6 +586,83 @@ func initDefaultPermissionsAndRoles(db *gorm.DB) error {
 		}
 	}
 
+	// 创建默认项目角色（只在不存在时创建，保留管理员对项目角色权限的调整）
+	defaultProjectRoles := []struct {
+		Role        model.ProjectRole
+		Permissions []string
+	}{
+		{
+			Role: model.ProjectRole{
+				Name:        "项目经理",
+				Code:        "owner",
+				Description: "项目负责人，管理项目和成员（不含删除权限）",
+				Status:      1,
+			},
+			// 项目创建人、克隆人和导入人都会成为项目经理，项目角色又优先于全局角色，
+			// 因此默认不包含删除权限，避免全局角色不能删除的用户在自己的项目中获得删除权限
+			Permissions: []string{
+				"project:read", "project:update", "project:manage",
+				"requirement:read", "requirement:create", "requirement:update",
+				"task:read", "task:create", "task:update",
+				"bug:read", "bug:create", "bug:update", "bug:assign",
+				"test-case:read", "test-case:create", "test-case:update",
+				"version:read",
+				"attachment:upload",
+			},
+		},
+		{
+			Role: model.ProjectRole{
+				Name:        "项目成员",
+				Code:        "member",
+				Description: "项目成员，可以查看项目并处理需求、任务和Bug",
+				Status:      1,
+			},
+			Permissions: []string{
+				"project:read",
+				"requirement:read", "requirement:create", "requirement:update",
+				"task:read", "task:create", "task:update",
+				"bug:read", "bug:create", "bug:update", "bug:assign",
+				"test-case:read", "test-case:create", "test-case:update",
+				"version:read",
+				"attachment:upload",
+			},
+		},
+		{
+			Role: model.ProjectRole{
+				Name:        "只读成员",
+				Code:        "viewer",
+				Description: "只读成员，只能查看项目内容",
+				Status:      1,
+			},
+			Permissions: []string{
+				"project:read", "requirement:read", "task:read", "bug:read", "test-case:read", "version:read",
+			},
+		},
+	}
+
+	for _, roleData := range defaultProjectRoles {
+		role := roleData.Role
+		var count int64
+		db.Unscoped().Model(&model.ProjectRole{}).Where("code = ?", role.Code).Count(&count)
+		if count > 0 {
+			continue
+		}
+		if err := db.Create(&role).Error; err != nil {
+			return err
+		}
+		var rolePermissions []model.Permission
+		for _, permCode := range roleData.Permissions {
+			if perm, ok := permMap[permCode]; ok {
+				rolePermissions = append(rolePermissions, *perm)
+			}
+		}
+		if len(rolePermissions) > 0 {
+			if err := db.Model(&role).Association("Permissions").Replace(rolePermissions); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+
+	"prjflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Notify 发送站内通知，sendMail 为 true 且启用了邮件发送时同时发送邮件（邮件发送失败只记录日志）
+func Notify(db *gorm.DB, userID uint, notificationType, title, content, link string, sendMail bool) error {
+	notification := model.Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Title:   title,
+		Content: content,
+		Link:    link,
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		return err
+	}
+
+	if !sendMail {
+		return nil
+	}
+	var user model.User
+	if err := db.Select("id", "email").First(&user, userID).Error; err != nil || user.Email == "" {
+		return nil
+	}
+	if err := SendMail([]string{user.Email}, title, content); err != nil && !errors.Is(err, ErrMailDisabled) {
+		if Logger != nil {
+			Logger.Warnf("发送通知邮件失败: user=%d, %v", userID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"prjflow/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findProgramMemberForProject 查找用户在项目所属项目集中的成员身份
+func findProgramMemberForProject(db *gorm.DB, projectID, userID uint) (*model.ProgramMember, bool) {
+	var member model.ProgramMember
+	err := db.Where("user_id = ? AND program_id = (?)", userID,
+		db.Model(&model.Project{}).Select("program_id").Where("id = ? AND program_id IS NOT NULL", projectID)).
+		First(&member).Error
+	if err != nil {
+		return nil, false
+	}
+	return &member, true
+}
+
+// GetUserProgramIDs 获取用户参与的项目集ID列表
+func GetUserProgramIDs(db *gorm.DB, userID uint) []uint {
+	var programIDs []uint
+	db.Model(&model.ProgramMember{}).
+		Where("user_id = ?", userID).
+		Pluck("program_id", &programIDs)
+	return programIDs
+}
+
+// FilterProgramsByUser 过滤项目集查询：普通用户只能看到自己参与的项目集
+func FilterProgramsByUser(db *gorm.DB, c *gin.Context, query *gorm.DB) *gorm.DB {
+	if IsAdmin(c) {
+		return query
+	}
+
+	userID := GetUserID(c)
+	if userID == 0 {
+		return query.Where("1 = 0")
+	}
+
+	return query.Where(
+		"EXISTS (SELECT 1 FROM program_members WHERE program_members.program_id = programs.id AND program_members.user_id = ? AND program_members.deleted_at IS NULL)",
+		userID,
+	)
+}
+
+// getProgramMember 获取用户在项目集中的成员身份
+func getProgramMember(db *gorm.DB, programID, userID uint) (*model.ProgramMember, bool) {
+	if programID == 0 || userID == 0 {
+		return nil, false
+	}
+	var member model.ProgramMember
+	if err := db.Where("program_id = ? AND user_id = ?", programID, userID).First(&member).Error; err != nil {
+		return nil, false
+	}
+	return &member, true
+}
+
+// CheckProgramAccess 检查用户是否有权限访问项目集（管理员或项目集成员）
+func CheckProgramAccess(db *gorm.DB, c *gin.Context, programID uint) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	_, ok := getProgramMember(db, programID, GetUserID(c))
+	return ok
+}
+
+// CanManageProgram 检查用户是否可以管理项目集（项目、成员和项目集级需求）
+// 管理员可以管理所有项目集；项目集成员的角色对应的项目角色需要包含 project:manage
+func CanManageProgram(db *gorm.DB, c *gin.Context, programID uint) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	member, ok := getProgramMember(db, programID, GetUserID(c))
+	if !ok || member.Role == "" {
+		return false
+	}
+	role := findProjectRole(db, member.Role)
+	if role == nil {
+		return false
+	}
+	for _, perm := range role.Permissions {
+		if perm.Code == "project:manage" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,1534 @@
+package utils
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"time"
+
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/pkg/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProjectBundleFormatVersion 项目数据包格式版本
+const ProjectBundleFormatVersion = 1
+
+// ProjectBundleManifest 数据包内的清单文件名
+const ProjectBundleManifest = "project.json"
+
+// InstanceIDConfigKey 实例标识在系统配置中的键
+const InstanceIDConfigKey = "instance_id"
+
+// ProjectBundle 项目数据包（用于在不同实例间迁移项目）
+// 所有ID均为来源实例中的ID，用户通过用户名匹配
+type ProjectBundle struct {
+	FormatVersion  int       `json:"format_version"`
+	SourceInstance string    `json:"source_instance"`
+	ExportedAt     time.Time `json:"exported_at"`
+
+	Users            []BundleUser           `json:"users"`
+	Project          BundleProject          `json:"project"`
+	Members          []BundleMember         `json:"members"`
+	Requirements     []BundleRequirement    `json:"requirements"`
+	Tasks            []BundleTask           `json:"tasks"`
+	TaskDependencies []model.TaskDependency `json:"task_dependencies"`
+	Bugs             []BundleBug            `json:"bugs"`
+	TestCases        []BundleTestCase       `json:"test_cases"`
+	Versions         []BundleVersion        `json:"versions"`
+	Boards           []BundleBoard          `json:"boards"`
+	Actions          []BundleAction         `json:"actions"`
+	Attachments      []BundleAttachment     `json:"attachments"`
+}
+
+// BundleUser 数据包中引用的用户
+type BundleUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+}
+
+// BundleProject 数据包中的项目
+type BundleProject struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Code        string     `json:"code"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	StartDate   *time.Time `json:"start_date"`
+	EndDate     *time.Time `json:"end_date"`
+	Tags        []string   `json:"tags"`    // 标签名称
+	Modules     []string   `json:"modules"` // 功能模块名称
+}
+
+// BundleMember 数据包中的项目成员
+type BundleMember struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// BundleRequirement 数据包中的需求
+type BundleRequirement struct {
+	ID             uint      `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	Status         string    `json:"status"`
+	Priority       string    `json:"priority"`
+	CreatorID      uint      `json:"creator_id"`
+	AssigneeID     *uint     `json:"assignee_id"`
+	EstimatedHours *float64  `json:"estimated_hours"`
+	ActualHours    *float64  `json:"actual_hours"`
+
+	Confidential bool               `json:"confidential,omitempty"` // 是否受限
+	Access       []BundleItemAccess `json:"access,omitempty"`       // 受限对象的访问名单
+}
+
+// BundleTask 数据包中的任务
+type BundleTask struct {
+	ID             uint       `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Status         string     `json:"status"`
+	Priority       string     `json:"priority"`
+	RequirementID  *uint      `json:"requirement_id"`
+	CreatorID      uint       `json:"creator_id"`
+	AssigneeID     *uint      `json:"assignee_id"`
+	StartDate      *time.Time `json:"start_date"`
+	EndDate        *time.Time `json:"end_date"`
+	DueDate        *time.Time `json:"due_date"`
+	Progress       int        `json:"progress"`
+	EstimatedHours *float64   `json:"estimated_hours"`
+	ActualHours    *float64   `json:"actual_hours"`
+
+	Confidential bool               `json:"confidential,omitempty"` // 是否受限
+	Access       []BundleItemAccess `json:"access,omitempty"`       // 受限对象的访问名单
+}
+
+// BundleBug 数据包中的Bug
+type BundleBug struct {
+	ID                uint      `json:"id"`
+	CreatedAt         time.Time `json:"created_at"`
+	Title             string    `json:"title"`
+	Description       string    `json:"description"`
+	Status            string    `json:"status"`
+	Priority          string    `json:"priority"`
+	Severity          string    `json:"severity"`
+	Confirmed         bool      `json:"confirmed"`
+	CreatorID         uint      `json:"creator_id"`
+	AssigneeIDs       []uint    `json:"assignee_ids"`
+	RequirementID     *uint     `json:"requirement_id"`
+	Module            string    `json:"module"` // 功能模块名称
+	EstimatedHours    *float64  `json:"estimated_hours"`
+	ActualHours       *float64  `json:"actual_hours"`
+	Solution          string    `json:"solution"`
+	SolutionNote      string    `json:"solution_note"`
+	ResolvedVersionID *uint     `json:"resolved_version_id"`
+	VersionIDs        []uint    `json:"version_ids"`
+
+	Confidential bool               `json:"confidential,omitempty"` // 是否保密
+	Access       []BundleItemAccess `json:"access,omitempty"`       // 保密Bug的访问名单
+}
+
+// BundleItemAccess 数据包中受限对象的访问名单（用户或角色）
+type BundleItemAccess struct {
+	UserID   *uint  `json:"user_id,omitempty"`
+	RoleCode string `json:"role_code,omitempty"`
+}
+
+// BundleTestCase 数据包中的测试单
+type BundleTestCase struct {
+	ID          uint              `json:"id"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	TestSteps   string            `json:"test_steps"`
+	Types       model.StringArray `json:"types"`
+	Status      string            `json:"status"`
+	Result      string            `json:"result"`
+	Summary     string            `json:"summary"`
+	CreatorID   uint              `json:"creator_id"`
+	BugIDs      []uint            `json:"bug_ids"`
+	Steps       []BundleTestStep  `json:"steps"`
+}
+
+// BundleTestStep 数据包中的测试步骤
+type BundleTestStep struct {
+	Sort     int    `json:"sort"`
+	Action   string `json:"action"`
+	Expected string `json:"expected"`
+}
+
+// BundleVersion 数据包中的版本
+type BundleVersion struct {
+	ID             uint       `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	VersionNumber  string     `json:"version_number"`
+	ReleaseNotes   string     `json:"release_notes"`
+	Status         string     `json:"status"`
+	ReleaseDate    *time.Time `json:"release_date"`
+	RequirementIDs []uint     `json:"requirement_ids"`
+}
+
+// BundleBoard 数据包中的看板
+type BundleBoard struct {
+	ID          uint                `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	CardTypes   string              `json:"card_types,omitempty"`
+	SwimlaneBy  string              `json:"swimlane_by,omitempty"`
+	Columns     []BundleBoardColumn `json:"columns"`
+}
+
+// BundleBoardColumn 数据包中的看板列
+type BundleBoardColumn struct {
+	ID                uint   `json:"id"`
+	Name              string `json:"name"`
+	Color             string `json:"color"`
+	Sort              int    `json:"sort"`
+	Status            string `json:"status"`
+	BugStatus         string `json:"bug_status,omitempty"`
+	RequirementStatus string `json:"requirement_status,omitempty"`
+	WIPLimit          int    `json:"wip_limit,omitempty"`
+	WIPPolicy         string `json:"wip_policy,omitempty"`
+}
+
+// BundleAction 数据包中的操作记录
+type BundleAction struct {
+	ID         uint            `json:"id"`
+	ObjectType string          `json:"object_type"`
+	ObjectID   uint            `json:"object_id"`
+	ActorID    uint            `json:"actor_id"`
+	Action     string          `json:"action"`
+	Date       time.Time       `json:"date"`
+	Comment    string          `json:"comment"`
+	Extra      string          `json:"extra"`
+	Histories  []model.History `json:"histories"`
+}
+
+// BundleAttachment 数据包中的附件
+type BundleAttachment struct {
+	ID        uint              `json:"id"`
+	FileName  string            `json:"file_name"`
+	FileSize  int64             `json:"file_size"`
+	MimeType  string            `json:"mime_type"`
+	CreatorID uint              `json:"creator_id"`
+	File      string            `json:"file"`  // 数据包内的文件路径，为空表示源文件缺失
+	Links     []BundleObjectRef `json:"links"` // 附件关联的对象
+}
+
+// BundleObjectRef 数据包中的对象引用
+type BundleObjectRef struct {
+	ObjectType string `json:"object_type"`
+	ObjectID   uint   `json:"object_id"`
+}
+
+// ProjectImportResult 项目数据包导入结果
+type ProjectImportResult struct {
+	ProjectID      uint           `json:"project_id"`
+	ProjectCreated bool           `json:"project_created"` // 本次导入新建了项目（false 表示更新之前导入的项目）
+	Created        map[string]int `json:"created"`
+	Updated        map[string]int `json:"updated"`
+	Skipped        map[string]int `json:"skipped"`
+	Conflicts      []string       `json:"conflicts"`
+}
+
+// GetInstanceID 获取当前实例标识（首次调用时生成并保存到系统配置）
+func GetInstanceID(db *gorm.DB) (string, error) {
+	var cfg model.SystemConfig
+	if err := db.Where("key = ?", InstanceIDConfigKey).First(&cfg).Error; err == nil && cfg.Value != "" {
+		return cfg.Value, nil
+	}
+
+	cfg = model.SystemConfig{Key: InstanceIDConfigKey, Value: uuid.New().String(), Type: "string"}
+	if err := db.Create(&cfg).Error; err != nil {
+		// 并发创建时以已存在的值为准
+		var existing model.SystemConfig
+		if err2 := db.Where("key = ?", InstanceIDConfigKey).First(&existing).Error; err2 == nil {
+			return existing.Value, nil
+		}
+		return "", err
+	}
+	return cfg.Value, nil
+}
+
+// UploadStoragePath 获取上传文件存储根目录
+func UploadStoragePath() string {
+	storagePath := config.AppConfig.Upload.StoragePath
+	if !filepath.IsAbs(storagePath) {
+		storagePath = filepath.Join(".", storagePath)
+	}
+	return storagePath
+}
+
+// BuildProjectBundle 读取项目数据生成数据包（不包含附件文件内容）
+// 只导出导出人可以查看的受限需求、任务和Bug（c 为 nil 时导出全部），受限对象保留访问限制和访问名单
+func BuildProjectBundle(db *gorm.DB, c *gin.Context, projectID uint) (*ProjectBundle, error) {
+	visible := func(objectType string, query *gorm.DB) *gorm.DB {
+		if c == nil {
+			return query
+		}
+		return FilterRestrictedItems(c, objectType, query)
+	}
+
+	var project model.Project
+	if err := db.Preload("Tags").Preload("Modules").First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+
+	instanceID, err := GetInstanceID(db)
+	if err != nil {
+		return nil, fmt.Errorf("获取实例标识失败: %w", err)
+	}
+
+	bundle := &ProjectBundle{
+		FormatVersion:  ProjectBundleFormatVersion,
+		SourceInstance: instanceID,
+		ExportedAt:     time.Now(),
+		Project: BundleProject{
+			ID:          project.ID,
+			Name:        project.Name,
+			Code:        project.Code,
+			Description: project.Description,
+			Status:      project.Status,
+			StartDate:   project.StartDate,
+			EndDate:     project.EndDate,
+		},
+	}
+	for _, tag := range project.Tags {
+		bundle.Project.Tags = append(bundle.Project.Tags, tag.Name)
+	}
+	for _, module := range project.Modules {
+		bundle.Project.Modules = append(bundle.Project.Modules, module.Name)
+	}
+
+	userIDs := make(map[uint]bool)
+	addUser := func(id *uint) {
+		if id != nil && *id > 0 {
+			userIDs[*id] = true
+		}
+	}
+
+	var members []model.ProjectMember
+	if err := db.Where("project_id = ?", projectID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		bundle.Members = append(bundle.Members, BundleMember{UserID: m.UserID, Role: m.Role})
+		addUser(&m.UserID)
+	}
+
+	// 受限对象的访问名单
+	bundleAccess := func(objectType string, ids []uint) (map[uint][]BundleItemAccess, error) {
+		accesses, err := LoadItemAccesses(db, objectType, ids)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[uint][]BundleItemAccess, len(accesses))
+		for id, list := range accesses {
+			for _, a := range list {
+				result[id] = append(result[id], BundleItemAccess{UserID: a.UserID, RoleCode: a.RoleCode})
+				addUser(a.UserID)
+			}
+		}
+		return result, nil
+	}
+	restrictedIDs := func(confidential bool, id uint, ids []uint) []uint {
+		if confidential {
+			return append(ids, id)
+		}
+		return ids
+	}
+
+	var requirements []model.Requirement
+	if err := visible("requirement", db.Where("project_id = ?", projectID)).Order("id ASC").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+	exportedRequirements := make(map[uint]bool, len(requirements))
+	var restrictedRequirements []uint
+	for _, r := range requirements {
+		exportedRequirements[r.ID] = true
+		restrictedRequirements = restrictedIDs(r.Confidential, r.ID, restrictedRequirements)
+	}
+	requirementAccess, err := bundleAccess("requirement", restrictedRequirements)
+	if err != nil {
+		return nil, err
+	}
+	// exportedRef 只保留对导出需求的引用
+	exportedRef := func(id *uint) *uint {
+		if id != nil && exportedRequirements[*id] {
+			return id
+		}
+		return nil
+	}
+	for _, r := range requirements {
+		bundle.Requirements = append(bundle.Requirements, BundleRequirement{
+			ID:             r.ID,
+			CreatedAt:      r.CreatedAt,
+			Title:          r.Title,
+			Description:    r.Description,
+			Status:         r.Status,
+			Priority:       r.Priority,
+			CreatorID:      r.CreatorID,
+			AssigneeID:     r.AssigneeID,
+			EstimatedHours: r.EstimatedHours,
+			ActualHours:    r.ActualHours,
+			Confidential:   r.Confidential,
+			Access:         requirementAccess[r.ID],
+		})
+		addUser(&r.CreatorID)
+		addUser(r.AssigneeID)
+	}
+
+	var tasks []model.Task
+	if err := visible("task", db.Where("project_id = ?", projectID)).Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	var restrictedTasks []uint
+	for _, t := range tasks {
+		restrictedTasks = restrictedIDs(t.Confidential, t.ID, restrictedTasks)
+	}
+	taskAccess, err := bundleAccess("task", restrictedTasks)
+	if err != nil {
+		return nil, err
+	}
+	taskIDs := make([]uint, 0, len(tasks))
+	for _, t := range tasks {
+		taskIDs = append(taskIDs, t.ID)
+		bundle.Tasks = append(bundle.Tasks, BundleTask{
+			ID:             t.ID,
+			CreatedAt:      t.CreatedAt,
+			Title:          t.Title,
+			Description:    t.Description,
+			Status:         t.Status,
+			Priority:       t.Priority,
+			RequirementID:  exportedRef(t.RequirementID),
+			CreatorID:      t.CreatorID,
+			AssigneeID:     t.AssigneeID,
+			StartDate:      t.StartDate,
+			EndDate:        t.EndDate,
+			DueDate:        t.DueDate,
+			Progress:       t.Progress,
+			EstimatedHours: t.EstimatedHours,
+			ActualHours:    t.ActualHours,
+			Confidential:   t.Confidential,
+			Access:         taskAccess[t.ID],
+		})
+		addUser(&t.CreatorID)
+		addUser(t.AssigneeID)
+	}
+	if len(taskIDs) > 0 {
+		if err := db.Where("task_id IN ? AND dependency_id IN ?", taskIDs, taskIDs).Find(&bundle.TaskDependencies).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var versions []model.Version
+	if err := db.Where("project_id = ?", projectID).Preload("Requirements", func(db *gorm.DB) *gorm.DB {
+		return visible("requirement", db)
+	}).Order("id ASC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		bv := BundleVersion{
+			ID:            v.ID,
+			CreatedAt:     v.CreatedAt,
+			VersionNumber: v.VersionNumber,
+			ReleaseNotes:  v.ReleaseNotes,
+			Status:        v.Status,
+			ReleaseDate:   v.ReleaseDate,
+		}
+		for _, r := range v.Requirements {
+			bv.RequirementIDs = append(bv.RequirementIDs, r.ID)
+		}
+		bundle.Versions = append(bundle.Versions, bv)
+	}
+
+	var bugs []model.Bug
+	if err := visible("bug", db.Where("project_id = ?", projectID)).Preload("Assignees").Preload("Module").Preload("Versions").Order("id ASC").Find(&bugs).Error; err != nil {
+		return nil, err
+	}
+	var restrictedBugs []uint
+	for _, b := range bugs {
+		restrictedBugs = restrictedIDs(b.Confidential, b.ID, restrictedBugs)
+	}
+	bugAccess, err := bundleAccess("bug", restrictedBugs)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bugs {
+		bb := BundleBug{
+			ID:                b.ID,
+			CreatedAt:         b.CreatedAt,
+			Title:             b.Title,
+			Description:       b.Description,
+			Status:            b.Status,
+			Priority:          b.Priority,
+			Severity:          b.Severity,
+			Confirmed:         b.Confirmed,
+			CreatorID:         b.CreatorID,
+			RequirementID:     exportedRef(b.RequirementID),
+			EstimatedHours:    b.EstimatedHours,
+			ActualHours:       b.ActualHours,
+			Solution:          b.Solution,
+			SolutionNote:      b.SolutionNote,
+			ResolvedVersionID: b.ResolvedVersionID,
+			Confidential:      b.Confidential,
+			Access:            bugAccess[b.ID],
+		}
+		if b.Module != nil {
+			bb.Module = b.Module.Name
+		}
+		for _, u := range b.Assignees {
+			bb.AssigneeIDs = append(bb.AssigneeIDs, u.ID)
+			addUser(&u.ID)
+		}
+		for _, v := range b.Versions {
+			bb.VersionIDs = append(bb.VersionIDs, v.ID)
+		}
+		bundle.Bugs = append(bundle.Bugs, bb)
+		addUser(&b.CreatorID)
+	}
+
+	var testCases []model.TestCase
+	if err := db.Where("project_id = ?", projectID).Preload("Bugs", func(db *gorm.DB) *gorm.DB {
+		return visible("bug", db)
+	}).Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort ASC, id ASC")
+	}).Order("id ASC").Find(&testCases).Error; err != nil {
+		return nil, err
+	}
+	for _, tc := range testCases {
+		btc := BundleTestCase{
+			ID:          tc.ID,
+			CreatedAt:   tc.CreatedAt,
+			Name:        tc.Name,
+			Description: tc.Description,
+			TestSteps:   tc.TestSteps,
+			Types:       tc.Types,
+			Status:      tc.Status,
+			Result:      tc.Result,
+			Summary:     tc.Summary,
+			CreatorID:   tc.CreatorID,
+		}
+		for _, b := range tc.Bugs {
+			btc.BugIDs = append(btc.BugIDs, b.ID)
+		}
+		for _, step := range tc.Steps {
+			btc.Steps = append(btc.Steps, BundleTestStep{Sort: step.Sort, Action: step.Action, Expected: step.Expected})
+		}
+		bundle.TestCases = append(bundle.TestCases, btc)
+		addUser(&tc.CreatorID)
+	}
+
+	var boards []model.Board
+	if err := db.Where("project_id = ?", projectID).Preload("Columns", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort ASC")
+	}).Order("id ASC").Find(&boards).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range boards {
+		bb := BundleBoard{ID: b.ID, Name: b.Name, Description: b.Description, CardTypes: b.CardTypes, SwimlaneBy: b.SwimlaneBy}
+		for _, col := range b.Columns {
+			bb.Columns = append(bb.Columns, BundleBoardColumn{
+				ID: col.ID, Name: col.Name, Color: col.Color, Sort: col.Sort, Status: col.Status,
+				BugStatus: col.BugStatus, RequirementStatus: col.RequirementStatus, WIPLimit: col.WIPLimit, WIPPolicy: col.WIPPolicy,
+			})
+		}
+		bundle.Boards = append(bundle.Boards, bb)
+	}
+
+	// 操作记录：项目本身及项目下所有对象
+	objectIDs := map[string][]uint{"project": {projectID}, "task": taskIDs}
+	for _, r := range requirements {
+		objectIDs["requirement"] = append(objectIDs["requirement"], r.ID)
+	}
+	for _, b := range bugs {
+		objectIDs["bug"] = append(objectIDs["bug"], b.ID)
+	}
+	for _, v := range versions {
+		objectIDs["version"] = append(objectIDs["version"], v.ID)
+	}
+	for _, tc := range testCases {
+		objectIDs["test_case"] = append(objectIDs["test_case"], tc.ID)
+	}
+	for _, objectType := range []string{"project", "requirement", "task", "bug", "version", "test_case"} {
+		ids := objectIDs[objectType]
+		if len(ids) == 0 {
+			continue
+		}
+		var actions []model.Action
+		if err := db.Where("object_type = ? AND object_id IN ?", objectType, ids).Preload("Histories").Order("id ASC").Find(&actions).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range actions {
+			bundle.Actions = append(bundle.Actions, BundleAction{
+				ID:         a.ID,
+				ObjectType: a.ObjectType,
+				ObjectID:   a.ObjectID,
+				ActorID:    a.ActorID,
+				Action:     a.Action,
+				Date:       a.Date,
+				Comment:    a.Comment,
+				Extra:      a.Extra,
+				Histories:  a.Histories,
+			})
+			addUser(&a.ActorID)
+		}
+	}
+
+	// 附件：通过各关联表汇总
+	attachmentLinks := make(map[uint][]BundleObjectRef)
+	linkTables := []struct {
+		objectType string
+		table      string
+		column     string
+		ids        []uint
+	}{
+		{"project", "project_attachments", "project_id", objectIDs["project"]},
+		{"requirement", "requirement_attachments", "requirement_id", objectIDs["requirement"]},
+		{"task", "task_attachments", "task_id", objectIDs["task"]},
+		{"bug", "bug_attachments", "bug_id", objectIDs["bug"]},
+		{"version", "version_attachments", "version_id", objectIDs["version"]},
+	}
+	for _, lt := range linkTables {
+		if len(lt.ids) == 0 {
+			continue
+		}
+		var rows []struct {
+			ObjectID     uint
+			AttachmentID uint
+		}
+		if err := db.Table(lt.table).Select(lt.column+" AS object_id, attachment_id").Where(lt.column+" IN ?", lt.ids).Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			attachmentLinks[row.AttachmentID] = append(attachmentLinks[row.AttachmentID], BundleObjectRef{ObjectType: lt.objectType, ObjectID: row.ObjectID})
+		}
+	}
+	if len(attachmentLinks) > 0 {
+		ids := make([]uint, 0, len(attachmentLinks))
+		for id := range attachmentLinks {
+			ids = append(ids, id)
+		}
+		// 同时关联到项目和不可见受限对象的附件不导出
+		var attachments []model.Attachment
+		query := db.Where("id IN ?", ids)
+		if c != nil {
+			query = FilterAttachmentsByRestriction(c, query)
+		}
+		if err := query.Order("id ASC").Find(&attachments).Error; err != nil {
+			return nil, err
+		}
+		for _, a := range attachments {
+			bundle.Attachments = append(bundle.Attachments, BundleAttachment{
+				ID:        a.ID,
+				FileName:  a.FileName,
+				FileSize:  a.FileSize,
+				MimeType:  a.MimeType,
+				CreatorID: a.CreatorID,
+				File:      path.Join("attachments", fmt.Sprintf("%d", a.ID), filepath.Base(a.FileName)),
+				Links:     attachmentLinks[a.ID],
+			})
+			addUser(&a.CreatorID)
+		}
+	}
+
+	if len(userIDs) > 0 {
+		ids := make([]uint, 0, len(userIDs))
+		for id := range userIDs {
+			ids = append(ids, id)
+		}
+		var users []model.User
+		if err := db.Unscoped().Where("id IN ?", ids).Order("id ASC").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			bundle.Users = append(bundle.Users, BundleUser{ID: u.ID, Username: u.Username, Nickname: u.Nickname})
+		}
+	}
+
+	return bundle, nil
+}
+
+// WriteProjectBundle 导出项目数据包（zip：project.json + 附件文件），c 为导出人（参见 BuildProjectBundle）
+func WriteProjectBundle(db *gorm.DB, c *gin.Context, projectID uint, w io.Writer) error {
+	bundle, err := BuildProjectBundle(db, c, projectID)
+	if err != nil {
+		return err
+	}
+
+	store, err := AttachmentStorage()
+	if err != nil {
+		return err
+	}
+
+	// 先确认附件源文件，缺失的文件在清单中标记为空
+	var attachmentFiles []string
+	for i := range bundle.Attachments {
+		var attachment model.Attachment
+		if err := db.First(&attachment, bundle.Attachments[i].ID).Error; err != nil {
+			bundle.Attachments[i].File = ""
+			attachmentFiles = append(attachmentFiles, "")
+			continue
+		}
+		if _, err := store.Stat(attachment.FilePath); err != nil {
+			bundle.Attachments[i].File = ""
+			attachmentFiles = append(attachmentFiles, "")
+			continue
+		}
+		attachmentFiles = append(attachmentFiles, attachment.FilePath)
+	}
+
+	zipWriter := zip.NewWriter(w)
+
+	manifest, err := zipWriter.Create(ProjectBundleManifest)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifest)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return err
+	}
+
+	for i, a := range bundle.Attachments {
+		if a.File == "" {
+			continue
+		}
+		if err := addStorageObjectToZip(zipWriter, store, attachmentFiles[i], a.File); err != nil {
+			return fmt.Errorf("写入附件 %s 失败: %w", a.FileName, err)
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// addStorageObjectToZip 将存储中的对象添加到ZIP压缩包中
+func addStorageObjectToZip(zipWriter *zip.Writer, store storage.Storage, key, zipName string) error {
+	reader, info, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	header := &zip.FileHeader{Name: zipName, Method: zip.Deflate}
+	header.Modified = info.ModTime
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, reader)
+	return err
+}
+
+// ReadProjectBundle 读取数据包清单
+func ReadProjectBundle(zr *zip.Reader) (*ProjectBundle, error) {
+	file, err := zr.Open(ProjectBundleManifest)
+	if err != nil {
+		return nil, fmt.Errorf("数据包缺少 %s", ProjectBundleManifest)
+	}
+	defer file.Close()
+
+	var bundle ProjectBundle
+	if err := json.NewDecoder(file).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("解析数据包失败: %w", err)
+	}
+	if bundle.FormatVersion != ProjectBundleFormatVersion {
+		return nil, fmt.Errorf("不支持的数据包格式版本: %d", bundle.FormatVersion)
+	}
+	if bundle.SourceInstance == "" {
+		return nil, fmt.Errorf("数据包缺少来源实例标识")
+	}
+	return &bundle, nil
+}
+
+// ErrProjectImportForbidden 数据包之前导入的项目不允许导入人更新
+var ErrProjectImportForbidden = errors.New("没有权限更新之前导入的项目")
+
+// ImportProjectBundle 导入项目数据包
+// 通过 ImportMapping 记录来源ID与新ID的对应关系：同一数据包重复导入时更新已导入的对象，不会重复创建。
+// canUpdate 判断导入人能否更新之前导入的项目，返回 false 时导入失败并返回 ErrProjectImportForbidden；为 nil 时不检查
+func ImportProjectBundle(db *gorm.DB, zr *zip.Reader, importerID uint, canUpdate func(projectID uint) bool) (*ProjectImportResult, error) {
+	bundle, err := ReadProjectBundle(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	localInstance, err := GetInstanceID(db)
+	if err != nil {
+		return nil, fmt.Errorf("获取实例标识失败: %w", err)
+	}
+
+	imp := &bundleImporter{
+		bundle:     bundle,
+		zr:         zr,
+		importerID: importerID,
+		canUpdate:  canUpdate,
+		result: &ProjectImportResult{
+			Created:   make(map[string]int),
+			Updated:   make(map[string]int),
+			Skipped:   make(map[string]int),
+			Conflicts: []string{},
+		},
+		userIDs:      make(map[uint]uint),
+		writtenFiles: []string{},
+	}
+	if bundle.SourceInstance == localInstance {
+		imp.conflict("数据包来自本实例，将按映射更新已导入的数据或创建新项目")
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			imp.removeWrittenFiles()
+			panic(r)
+		}
+	}()
+	imp.tx = tx
+
+	if err := imp.run(); err != nil {
+		tx.Rollback()
+		imp.removeWrittenFiles()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		imp.removeWrittenFiles()
+		return nil, err
+	}
+
+	return imp.result, nil
+}
+
+// bundleImporter 项目数据包导入器
+type bundleImporter struct {
+	tx           *gorm.DB
+	bundle       *ProjectBundle
+	zr           *zip.Reader
+	importerID   uint
+	canUpdate    func(projectID uint) bool
+	result       *ProjectImportResult
+	userIDs      map[uint]uint // 来源用户ID -> 本地用户ID
+	projectID    uint
+	store        storage.Storage // 附件存储
+	writtenFiles []string        // 本次导入写入的附件对象键（失败时清理）
+}
+
+func (imp *bundleImporter) conflict(format string, args ...interface{}) {
+	imp.result.Conflicts = append(imp.result.Conflicts, fmt.Sprintf(format, args...))
+}
+
+// lookup 查询来源对象对应的本地对象ID
+// 映射到其他项目中对象的视为未导入（数据包可能伪造了其他导入的来源实例和来源ID）
+func (imp *bundleImporter) lookup(objectType string, sourceID uint) (uint, bool) {
+	var mapping model.ImportMapping
+	if err := imp.tx.Where("source_instance = ? AND object_type = ? AND source_id = ?", imp.bundle.SourceInstance, objectType, sourceID).First(&mapping).Error; err != nil {
+		return 0, false
+	}
+	if !imp.inProject(objectType, mapping.TargetID) {
+		imp.conflict("%s #%d 之前导入到了其他项目，将作为新对象导入", objectType, sourceID)
+		return 0, false
+	}
+	return mapping.TargetID, true
+}
+
+// bundleProjectModels 按 project_id 归属项目的导入对象
+var bundleProjectModels = map[string]interface{}{
+	"requirement": &model.Requirement{},
+	"version":     &model.Version{},
+	"task":        &model.Task{},
+	"bug":         &model.Bug{},
+	"test_case":   &model.TestCase{},
+	"board":       &model.Board{},
+	"action":      &model.Action{},
+}
+
+// inProject 判断映射的本地对象是否属于正在导入的项目（项目本身由 canUpdate 检查）
+func (imp *bundleImporter) inProject(objectType string, targetID uint) bool {
+	if objectType == "project" {
+		return true
+	}
+	var count int64
+	if m, ok := bundleProjectModels[objectType]; ok {
+		imp.tx.Model(m).Where("id = ? AND project_id = ?", targetID, imp.projectID).Count(&count)
+		return count > 0
+	}
+	switch objectType {
+	case "board_column":
+		imp.tx.Model(&model.BoardColumn{}).Joins("JOIN boards ON boards.id = board_columns.board_id").
+			Where("board_columns.id = ? AND boards.project_id = ? AND boards.deleted_at IS NULL", targetID, imp.projectID).Count(&count)
+	case "attachment":
+		// 附件关联到项目或项目中的需求、任务、Bug、版本
+		links := []struct{ table, column, owner string }{
+			{"project_attachments", "project_id", ""},
+			{"requirement_attachments", "requirement_id", "requirements"},
+			{"task_attachments", "task_id", "tasks"},
+			{"bug_attachments", "bug_id", "bugs"},
+			{"version_attachments", "version_id", "versions"},
+		}
+		for _, link := range links {
+			query := imp.tx.Table(link.table).Where(link.table+".attachment_id = ?", targetID)
+			if link.owner == "" {
+				query = query.Where(link.table+"."+link.column+" = ?", imp.projectID)
+			} else {
+				query = query.Joins(fmt.Sprintf("JOIN %s ON %s.id = %s.%s", link.owner, link.owner, link.table, link.column)).
+					Where(link.owner+".project_id = ?", imp.projectID)
+			}
+			if query.Count(&count); count > 0 {
+				return true
+			}
+		}
+	}
+	return count > 0
+}
+
+// remember 记录来源对象与本地对象的映射
+func (imp *bundleImporter) remember(objectType string, sourceID, targetID uint) error {
+	var mapping model.ImportMapping
+	err := imp.tx.Where("source_instance = ? AND object_type = ? AND source_id = ?", imp.bundle.SourceInstance, objectType, sourceID).First(&mapping).Error
+	if err == nil {
+		if mapping.TargetID == targetID {
+			return nil
+		}
+		return imp.tx.Model(&mapping).Update("target_id", targetID).Error
+	}
+	return imp.tx.Create(&model.ImportMapping{
+		SourceInstance: imp.bundle.SourceInstance,
+		ObjectType:     objectType,
+		SourceID:       sourceID,
+		TargetID:       targetID,
+	}).Error
+}
+
+// load 加载已导入的对象（映射存在且对象未被删除时返回 true）
+func (imp *bundleImporter) load(objectType string, sourceID uint, dest interface{}) bool {
+	targetID, ok := imp.lookup(objectType, sourceID)
+	if !ok {
+		return false
+	}
+	return imp.tx.First(dest, targetID).Error == nil
+}
+
+// saved 保存对象并记录映射和统计
+func (imp *bundleImporter) saved(objectType string, sourceID, targetID uint, existed bool) error {
+	if existed {
+		imp.result.Updated[objectType]++
+	} else {
+		imp.result.Created[objectType]++
+	}
+	return imp.remember(objectType, sourceID, targetID)
+}
+
+// user 映射用户ID，未匹配时返回 nil
+func (imp *bundleImporter) user(sourceID *uint) *uint {
+	if sourceID == nil {
+		return nil
+	}
+	if id, ok := imp.userIDs[*sourceID]; ok {
+		return &id
+	}
+	return nil
+}
+
+// userOrImporter 映射用户ID，未匹配时使用导入人
+func (imp *bundleImporter) userOrImporter(sourceID uint) uint {
+	if id, ok := imp.userIDs[sourceID]; ok {
+		return id
+	}
+	return imp.importerID
+}
+
+// ref 映射对象引用，未匹配时返回 nil
+func (imp *bundleImporter) ref(objectType string, sourceID *uint) *uint {
+	if sourceID == nil {
+		return nil
+	}
+	if id, ok := imp.lookup(objectType, *sourceID); ok {
+		return &id
+	}
+	return nil
+}
+
+// refs 批量映射对象引用，忽略未匹配的引用
+func (imp *bundleImporter) refs(objectType string, sourceIDs []uint) []uint {
+	ids := make([]uint, 0, len(sourceIDs))
+	for _, sid := range sourceIDs {
+		if id, ok := imp.lookup(objectType, sid); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (imp *bundleImporter) removeWrittenFiles() {
+	if imp.store == nil {
+		return
+	}
+	for _, key := range imp.writtenFiles {
+		imp.store.Delete(key)
+	}
+}
+
+func (imp *bundleImporter) run() error {
+	steps := []func() error{
+		imp.importUsers,
+		imp.importProject,
+		imp.importMembers,
+		imp.importRequirements,
+		imp.importVersions,
+		imp.importTasks,
+		imp.importBugs,
+		imp.importTestCases,
+		imp.importBoards,
+		imp.importActions,
+		imp.importAttachments,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	imp.result.ProjectID = imp.projectID
+	return nil
+}
+
+func (imp *bundleImporter) importUsers() error {
+	for _, u := range imp.bundle.Users {
+		var local model.User
+		if err := imp.tx.Where("username = ?", u.Username).First(&local).Error; err != nil {
+			imp.conflict("用户 %s 在本实例中不存在，相关字段将置空或使用导入人", u.Username)
+			continue
+		}
+		imp.userIDs[u.ID] = local.ID
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importProject() error {
+	src := imp.bundle.Project
+	var project model.Project
+	existed := imp.load("project", src.ID, &project)
+	if existed && imp.canUpdate != nil && !imp.canUpdate(project.ID) {
+		return ErrProjectImportForbidden
+	}
+	imp.result.ProjectCreated = !existed
+
+	project.Name = src.Name
+	project.Description = src.Description
+	project.Status = src.Status
+	project.StartDate = src.StartDate
+	project.EndDate = src.EndDate
+
+	if !existed {
+		// 项目编码唯一，冲突时追加后缀
+		project.Code = src.Code
+		if src.Code != "" {
+			for i := 1; ; i++ {
+				var count int64
+				imp.tx.Unscoped().Model(&model.Project{}).Where("code = ?", project.Code).Count(&count)
+				if count == 0 {
+					break
+				}
+				project.Code = fmt.Sprintf("%s-%d", src.Code, i)
+			}
+			if project.Code != src.Code {
+				imp.conflict("项目编码 %s 已存在，已改为 %s", src.Code, project.Code)
+			}
+		}
+	}
+
+	if err := imp.tx.Save(&project).Error; err != nil {
+		return fmt.Errorf("保存项目失败: %w", err)
+	}
+	imp.projectID = project.ID
+
+	if len(src.Tags) > 0 {
+		tags := make([]model.Tag, 0, len(src.Tags))
+		for _, name := range src.Tags {
+			var tag model.Tag
+			if err := imp.tx.Where("name = ?", name).FirstOrCreate(&tag, model.Tag{Name: name}).Error; err != nil {
+				return fmt.Errorf("保存标签失败: %w", err)
+			}
+			tags = append(tags, tag)
+		}
+		if err := imp.tx.Model(&project).Association("Tags").Replace(tags); err != nil {
+			return fmt.Errorf("关联标签失败: %w", err)
+		}
+	}
+
+	if len(src.Modules) > 0 {
+		modules := make([]model.Module, 0, len(src.Modules))
+		for _, name := range src.Modules {
+			var module model.Module
+			if err := imp.tx.Where("name = ?", name).First(&module).Error; err != nil {
+				imp.conflict("功能模块 %s 在本实例中不存在，已跳过", name)
+				continue
+			}
+			modules = append(modules, module)
+		}
+		if err := imp.tx.Model(&project).Association("Modules").Replace(modules); err != nil {
+			return fmt.Errorf("关联功能模块失败: %w", err)
+		}
+	}
+
+	return imp.saved("project", src.ID, project.ID, existed)
+}
+
+func (imp *bundleImporter) importMembers() error {
+	for _, m := range imp.bundle.Members {
+		userID, ok := imp.userIDs[m.UserID]
+		if !ok {
+			imp.result.Skipped["member"]++
+			continue
+		}
+		var member model.ProjectMember
+		err := imp.tx.Where("project_id = ? AND user_id = ?", imp.projectID, userID).First(&member).Error
+		if err == nil {
+			if member.Role != m.Role {
+				if err := imp.tx.Model(&member).Update("role", m.Role).Error; err != nil {
+					return err
+				}
+			}
+			imp.result.Updated["member"]++
+			continue
+		}
+		member = model.ProjectMember{ProjectID: imp.projectID, UserID: userID, Role: m.Role}
+		if err := imp.tx.Create(&member).Error; err != nil {
+			return fmt.Errorf("添加项目成员失败: %w", err)
+		}
+		imp.result.Created["member"]++
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importRequirements() error {
+	for _, src := range imp.bundle.Requirements {
+		var r model.Requirement
+		existed := imp.load("requirement", src.ID, &r)
+		if !existed {
+			r.CreatedAt = src.CreatedAt
+		}
+		r.Title = src.Title
+		r.Description = src.Description
+		r.Status = src.Status
+		r.Priority = src.Priority
+		r.ProjectID = imp.projectID
+		r.CreatorID = imp.userOrImporter(src.CreatorID)
+		r.AssigneeID = imp.user(src.AssigneeID)
+		r.EstimatedHours = src.EstimatedHours
+		r.ActualHours = src.ActualHours
+		r.Confidential = src.Confidential
+		if err := imp.tx.Omit("Attachments").Save(&r).Error; err != nil {
+			return fmt.Errorf("保存需求失败: %w", err)
+		}
+		if err := imp.importAccess("requirement", r.ID, src.Access); err != nil {
+			return err
+		}
+		if err := imp.saved("requirement", src.ID, r.ID, existed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importVersions() error {
+	for _, src := range imp.bundle.Versions {
+		var v model.Version
+		existed := imp.load("version", src.ID, &v)
+		if !existed {
+			v.CreatedAt = src.CreatedAt
+		}
+		v.VersionNumber = src.VersionNumber
+		v.ReleaseNotes = src.ReleaseNotes
+		v.Status = src.Status
+		v.ReleaseDate = src.ReleaseDate
+		v.ProjectID = imp.projectID
+		if err := imp.tx.Omit("Requirements", "Bugs", "Attachments").Save(&v).Error; err != nil {
+			return fmt.Errorf("保存版本失败: %w", err)
+		}
+		if ids := imp.refs("requirement", src.RequirementIDs); len(ids) > 0 {
+			var requirements []model.Requirement
+			imp.tx.Where("id IN ?", ids).Find(&requirements)
+			if err := imp.tx.Model(&v).Association("Requirements").Replace(requirements); err != nil {
+				return fmt.Errorf("关联版本需求失败: %w", err)
+			}
+		}
+		if err := imp.saved("version", src.ID, v.ID, existed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importTasks() error {
+	for _, src := range imp.bundle.Tasks {
+		var t model.Task
+		existed := imp.load("task", src.ID, &t)
+		if !existed {
+			t.CreatedAt = src.CreatedAt
+		}
+		t.Title = src.Title
+		t.Description = src.Description
+		t.Status = src.Status
+		t.Priority = src.Priority
+		t.ProjectID = imp.projectID
+		t.RequirementID = imp.ref("requirement", src.RequirementID)
+		t.CreatorID = imp.userOrImporter(src.CreatorID)
+		t.AssigneeID = imp.user(src.AssigneeID)
+		t.StartDate = src.StartDate
+		t.EndDate = src.EndDate
+		t.DueDate = src.DueDate
+		t.Progress = src.Progress
+		t.EstimatedHours = src.EstimatedHours
+		t.ActualHours = src.ActualHours
+		t.Confidential = src.Confidential
+		if err := imp.tx.Omit("Dependencies", "Attachments").Save(&t).Error; err != nil {
+			return fmt.Errorf("保存任务失败: %w", err)
+		}
+		if err := imp.importAccess("task", t.ID, src.Access); err != nil {
+			return err
+		}
+		if err := imp.saved("task", src.ID, t.ID, existed); err != nil {
+			return err
+		}
+	}
+
+	for _, dep := range imp.bundle.TaskDependencies {
+		taskID, ok1 := imp.lookup("task", dep.TaskID)
+		dependencyID, ok2 := imp.lookup("task", dep.DependencyID)
+		if !ok1 || !ok2 {
+			imp.result.Skipped["task_dependency"]++
+			continue
+		}
+		var count int64
+		imp.tx.Model(&model.TaskDependency{}).Where("task_id = ? AND dependency_id = ?", taskID, dependencyID).Count(&count)
+		if count > 0 {
+			imp.result.Skipped["task_dependency"]++
+			continue
+		}
+		if err := imp.tx.Create(&model.TaskDependency{TaskID: taskID, DependencyID: dependencyID, Type: dep.Type}).Error; err != nil {
+			return fmt.Errorf("保存任务依赖失败: %w", err)
+		}
+		imp.result.Created["task_dependency"]++
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importBugs() error {
+	for _, src := range imp.bundle.Bugs {
+		var b model.Bug
+		existed := imp.load("bug", src.ID, &b)
+		if !existed {
+			b.CreatedAt = src.CreatedAt
+		}
+		b.Title = src.Title
+		b.Description = src.Description
+		b.Status = src.Status
+		b.Priority = src.Priority
+		b.Severity = src.Severity
+		b.Confirmed = src.Confirmed
+		b.ProjectID = imp.projectID
+		b.CreatorID = imp.userOrImporter(src.CreatorID)
+		b.RequirementID = imp.ref("requirement", src.RequirementID)
+		b.EstimatedHours = src.EstimatedHours
+		b.ActualHours = src.ActualHours
+		b.Solution = src.Solution
+		b.SolutionNote = src.SolutionNote
+		b.ResolvedVersionID = imp.ref("version", src.ResolvedVersionID)
+		b.Confidential = src.Confidential
+		b.ModuleID = nil
+		if src.Module != "" {
+			var module model.Module
+			if err := imp.tx.Where("name = ?", src.Module).First(&module).Error; err == nil {
+				b.ModuleID = &module.ID
+			} else {
+				imp.conflict("Bug「%s」的功能模块 %s 在本实例中不存在，已置空", src.Title, src.Module)
+			}
+		}
+		if err := imp.tx.Omit("Assignees", "Versions", "Attachments").Save(&b).Error; err != nil {
+			return fmt.Errorf("保存Bug失败: %w", err)
+		}
+		if err := imp.importAccess("bug", b.ID, src.Access); err != nil {
+			return err
+		}
+
+		assignees := make([]model.User, 0, len(src.AssigneeIDs))
+		for _, sid := range src.AssigneeIDs {
+			if uid, ok := imp.userIDs[sid]; ok {
+				assignees = append(assignees, model.User{ID: uid})
+			}
+		}
+		if err := imp.tx.Model(&b).Association("Assignees").Replace(assignees); err != nil {
+			return fmt.Errorf("关联Bug指派人失败: %w", err)
+		}
+		versions := make([]model.Version, 0, len(src.VersionIDs))
+		for _, vid := range imp.refs("version", src.VersionIDs) {
+			versions = append(versions, model.Version{ID: vid})
+		}
+		if err := imp.tx.Model(&b).Association("Versions").Replace(versions); err != nil {
+			return fmt.Errorf("关联Bug版本失败: %w", err)
+		}
+
+		if err := imp.saved("bug", src.ID, b.ID, existed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importAccess 替换受限对象的访问名单，本实例中不存在的用户跳过
+func (imp *bundleImporter) importAccess(objectType string, objectID uint, access []BundleItemAccess) error {
+	if err := imp.tx.Where("object_type = ? AND object_id = ?", objectType, objectID).Delete(&model.ItemAccess{}).Error; err != nil {
+		return fmt.Errorf("保存访问名单失败: %w", err)
+	}
+	for _, a := range access {
+		entry := model.ItemAccess{ObjectType: objectType, ObjectID: objectID, UserID: imp.user(a.UserID), RoleCode: a.RoleCode, CreatorID: imp.importerID}
+		if entry.UserID == nil && entry.RoleCode == "" {
+			continue
+		}
+		if err := imp.tx.Create(&entry).Error; err != nil {
+			return fmt.Errorf("保存访问名单失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importTestCases() error {
+	for _, src := range imp.bundle.TestCases {
+		var tc model.TestCase
+		existed := imp.load("test_case", src.ID, &tc)
+		if !existed {
+			tc.CreatedAt = src.CreatedAt
+		}
+		tc.Name = src.Name
+		tc.Description = src.Description
+		tc.TestSteps = src.TestSteps
+		tc.Types = src.Types
+		tc.Status = src.Status
+		tc.Result = src.Result
+		tc.Summary = src.Summary
+		tc.ProjectID = imp.projectID
+		tc.CreatorID = imp.userOrImporter(src.CreatorID)
+		if err := imp.tx.Omit("Bugs", "Steps").Save(&tc).Error; err != nil {
+			return fmt.Errorf("保存测试单失败: %w", err)
+		}
+		if err := imp.tx.Where("test_case_id = ?", tc.ID).Delete(&model.TestCaseStep{}).Error; err != nil {
+			return fmt.Errorf("保存测试步骤失败: %w", err)
+		}
+		for _, step := range src.Steps {
+			if err := imp.tx.Create(&model.TestCaseStep{TestCaseID: tc.ID, Sort: step.Sort, Action: step.Action, Expected: step.Expected}).Error; err != nil {
+				return fmt.Errorf("保存测试步骤失败: %w", err)
+			}
+		}
+		bugs := make([]model.Bug, 0, len(src.BugIDs))
+		for _, bid := range imp.refs("bug", src.BugIDs) {
+			bugs = append(bugs, model.Bug{ID: bid})
+		}
+		if err := imp.tx.Model(&tc).Association("Bugs").Replace(bugs); err != nil {
+			return fmt.Errorf("关联测试单Bug失败: %w", err)
+		}
+		if err := imp.saved("test_case", src.ID, tc.ID, existed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importBoards() error {
+	for _, src := range imp.bundle.Boards {
+		var board model.Board
+		existed := imp.load("board", src.ID, &board)
+		board.Name = src.Name
+		board.Description = src.Description
+		board.CardTypes = src.CardTypes
+		if board.CardTypes == "" {
+			board.CardTypes = "task"
+		}
+		board.SwimlaneBy = src.SwimlaneBy
+		board.ProjectID = imp.projectID
+		if err := imp.tx.Omit("Columns").Save(&board).Error; err != nil {
+			return fmt.Errorf("保存看板失败: %w", err)
+		}
+		for _, srcCol := range src.Columns {
+			var column model.BoardColumn
+			colExisted := imp.load("board_column", srcCol.ID, &column)
+			column.Name = srcCol.Name
+			column.Color = srcCol.Color
+			column.Sort = srcCol.Sort
+			column.Status = srcCol.Status
+			column.BugStatus = srcCol.BugStatus
+			column.RequirementStatus = srcCol.RequirementStatus
+			column.WIPLimit = srcCol.WIPLimit
+			column.WIPPolicy = srcCol.WIPPolicy
+			if column.WIPPolicy == "" {
+				column.WIPPolicy = "block"
+			}
+			column.BoardID = board.ID
+			if err := imp.tx.Save(&column).Error; err != nil {
+				return fmt.Errorf("保存看板列失败: %w", err)
+			}
+			if err := imp.saved("board_column", srcCol.ID, column.ID, colExisted); err != nil {
+				return err
+			}
+		}
+		if err := imp.saved("board", src.ID, board.ID, existed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importActions() error {
+	for _, src := range imp.bundle.Actions {
+		// 操作记录不可变，已导入的直接跳过
+		if _, ok := imp.lookup("action", src.ID); ok {
+			imp.result.Skipped["action"]++
+			continue
+		}
+		objectID, ok := imp.lookup(src.ObjectType, src.ObjectID)
+		if !ok {
+			imp.result.Skipped["action"]++
+			continue
+		}
+		action := model.Action{
+			ObjectType: src.ObjectType,
+			ObjectID:   objectID,
+			ProjectID:  imp.projectID,
+			ActorID:    imp.userOrImporter(src.ActorID),
+			Action:     src.Action,
+			Date:       src.Date,
+			Comment:    src.Comment,
+			Extra:      src.Extra,
+		}
+		if err := imp.tx.Create(&action).Error; err != nil {
+			return fmt.Errorf("保存操作记录失败: %w", err)
+		}
+		for _, h := range src.Histories {
+			history := model.History{
+				ActionID: action.ID,
+				Field:    h.Field,
+				Old:      h.Old,
+				OldValue: h.OldValue,
+				New:      h.New,
+				NewValue: h.NewValue,
+				Diff:     h.Diff,
+			}
+			if err := imp.tx.Create(&history).Error; err != nil {
+				return fmt.Errorf("保存历史记录失败: %w", err)
+			}
+		}
+		if err := imp.saved("action", src.ID, action.ID, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *bundleImporter) importAttachments() error {
+	associations := map[string]string{
+		"project":     "Projects",
+		"requirement": "Requirements",
+		"task":        "Tasks",
+		"bug":         "Bugs",
+		"version":     "Versions",
+	}
+
+	for _, src := range imp.bundle.Attachments {
+		var attachment model.Attachment
+		existed := imp.load("attachment", src.ID, &attachment)
+		if !existed {
+			if src.File == "" {
+				imp.conflict("附件 %s 的文件在数据包中缺失，已跳过", src.FileName)
+				imp.result.Skipped["attachment"]++
+				continue
+			}
+			blob, err := imp.extractAttachment(src)
+			if err != nil {
+				return err
+			}
+			attachment = model.Attachment{
+				FileName:    src.FileName,
+				FilePath:    blob.FilePath,
+				FileSize:    blob.FileSize,
+				MimeType:    src.MimeType,
+				ContentHash: blob.Hash,
+				Version:     1,
+				CreatorID:   imp.userOrImporter(src.CreatorID),
+			}
+			if err := imp.tx.Create(&attachment).Error; err != nil {
+				return fmt.Errorf("保存附件失败: %w", err)
+			}
+		}
+
+		for _, link := range src.Links {
+			association, ok := associations[link.ObjectType]
+			if !ok {
+				continue
+			}
+			targetID, ok := imp.lookup(link.ObjectType, link.ObjectID)
+			if !ok {
+				continue
+			}
+			var target interface{}
+			switch link.ObjectType {
+			case "project":
+				target = &model.Project{ID: targetID}
+			case "requirement":
+				target = &model.Requirement{ID: targetID}
+			case "task":
+				target = &model.Task{ID: targetID}
+			case "bug":
+				target = &model.Bug{ID: targetID}
+			case "version":
+				target = &model.Version{ID: targetID}
+			}
+			if err := imp.tx.Model(&attachment).Association(association).Append(target); err != nil {
+				return fmt.Errorf("关联附件失败: %w", err)
+			}
+		}
+
+		if err := imp.saved("attachment", src.ID, attachment.ID, existed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractAttachment 将数据包中的附件文件写入附件存储（相同内容只保存一份），返回的内容记录持有一次引用
+func (imp *bundleImporter) extractAttachment(src BundleAttachment) (*model.AttachmentBlob, error) {
+	file, err := imp.zr.Open(src.File)
+	if err != nil {
+		return nil, fmt.Errorf("数据包缺少附件文件 %s", src.File)
+	}
+	defer file.Close()
+
+	if imp.store == nil {
+		if imp.store, err = AttachmentStorage(); err != nil {
+			return nil, err
+		}
+	}
+
+	blob, created, err := StoreAttachmentContent(imp.tx, file, src.FileName, src.MimeType)
+	if err != nil {
+		return nil, fmt.Errorf("写入附件文件失败: %w", err)
+	}
+	// 只有新写入的文件需要在导入失败时清理（引用数随事务回滚）
+	if created {
+		imp.writtenFiles = append(imp.writtenFiles, blob.FilePath)
+		if blob.ThumbnailPath != "" {
+			imp.writtenFiles = append(imp.writtenFiles, blob.ThumbnailPath)
+		}
+	}
+	return blob, nil
+}
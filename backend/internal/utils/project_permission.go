@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"prjflow/internal/model"
+	"prjflow/pkg/permission"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// projectAccess 用户在某个项目中的成员身份和项目角色（按请求缓存）
+type projectAccess struct {
+	Member      bool
+	Role        *model.ProjectRole // 为 nil 表示成员角色没有对应的项目角色定义
+	Permissions map[string]bool
+}
+
+// getProjectAccess 获取用户在项目中的成员身份和项目角色权限
+func getProjectAccess(db *gorm.DB, c *gin.Context, projectID uint) *projectAccess {
+	userID := GetUserID(c)
+	cacheKey := fmt.Sprintf("project_access:%d:%d", projectID, userID)
+	if cached, exists := c.Get(cacheKey); exists {
+		if access, ok := cached.(*projectAccess); ok {
+			return access
+		}
+	}
+
+	access := loadProjectAccess(db, projectID, userID)
+	c.Set(cacheKey, access)
+	return access
+}
+
+// loadProjectAccess 从数据库加载用户在项目中的成员身份和项目角色权限
+func loadProjectAccess(db *gorm.DB, projectID, userID uint) *projectAccess {
+	access := &projectAccess{}
+	if userID == 0 || projectID == 0 {
+		return access
+	}
+
+	// 项目成员身份优先；不是项目成员时，使用所属项目集的成员身份
+	var roleName string
+	var member model.ProjectMember
+	if err := db.Where("project_id = ? AND user_id = ?", projectID, userID).First(&member).Error; err == nil {
+		roleName = member.Role
+	} else if programMember, ok := findProgramMemberForProject(db, projectID, userID); ok {
+		roleName = programMember.Role
+	} else {
+		return access
+	}
+	access.Member = true
+
+	if roleName == "" {
+		return access
+	}
+
+	role := findProjectRole(db, roleName)
+	if role == nil {
+		return access
+	}
+	access.Role = role
+	access.Permissions = make(map[string]bool, len(role.Permissions))
+	for _, perm := range role.Permissions {
+		access.Permissions[perm.Code] = true
+	}
+	return access
+}
+
+// findProjectRole 按代码或名称匹配项目角色，禁用的项目角色视为未定义
+func findProjectRole(db *gorm.DB, roleName string) *model.ProjectRole {
+	var role model.ProjectRole
+	if err := db.Preload("Permissions").
+		Where("(code = ? OR name = ?) AND status = ?", roleName, roleName, 1).
+		First(&role).Error; err != nil {
+		return nil
+	}
+	return &role
+}
+
+// GetProjectPermissions 获取用户在项目中的权限列表
+// scoped 为 true 表示权限来自项目角色，为 false 表示没有对应的项目角色（使用全局角色权限）
+func GetProjectPermissions(db *gorm.DB, projectID, userID uint) (perms []string, member bool, scoped bool) {
+	access := loadProjectAccess(db, projectID, userID)
+	if access.Role == nil {
+		return nil, access.Member, false
+	}
+	perms = make([]string, 0, len(access.Permissions))
+	for code := range access.Permissions {
+		perms = append(perms, code)
+	}
+	return perms, access.Member, true
+}
+
+// HasGlobalPermission 检查用户的全局角色是否拥有权限
+func HasGlobalPermission(db *gorm.DB, c *gin.Context, permCode string) bool {
+	if IsAdmin(c) {
+		return true
+	}
+
+	// 优先使用认证中间件加载的权限列表
+	if perms, exists := c.Get("permissions"); exists {
+		if permList, ok := perms.([]string); ok {
+			for _, perm := range permList {
+				if perm == permCode {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	roles, exists := c.Get("roles")
+	if !exists {
+		return false
+	}
+	roleList, ok := roles.([]string)
+	if !ok || len(roleList) == 0 {
+		return false
+	}
+	hasPermission, err := permission.CheckPermissionWithDB(db, roleList, permCode)
+	return err == nil && hasPermission
+}
+
+// HasProjectPermission 检查用户在项目内是否拥有权限
+// 判断顺序：管理员拥有全部权限；项目成员的角色有对应的项目角色时以项目角色为准；否则使用全局角色权限
+func HasProjectPermission(db *gorm.DB, c *gin.Context, projectID uint, permCode string) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	if GetUserID(c) == 0 {
+		return false
+	}
+
+	access := getProjectAccess(db, c, projectID)
+	if access.Role != nil {
+		return access.Permissions[permCode]
+	}
+	return HasGlobalPermission(db, c, permCode)
+}
+
+// checkProjectObjectAccess 检查用户是否可以查看项目内的对象
+// involved 表示用户是对象的创建者或负责人，这类用户始终可以查看自己的对象
+func checkProjectObjectAccess(db *gorm.DB, c *gin.Context, projectID uint, readPerm string, involved bool) bool {
+	if involved {
+		return true
+	}
+
+	access := getProjectAccess(db, c, projectID)
+	if !access.Member {
+		return false
+	}
+	// 没有对应项目角色的成员保持原有行为：项目成员即可查看
+	if access.Role == nil {
+		return true
+	}
+	return access.Permissions[readPerm]
+}
+
+// ProjectResolver 从请求中解析目标对象所属的项目ID
+// 返回 false 表示无法解析（例如对象不存在），此时按全局权限处理，由处理函数返回具体错误
+type ProjectResolver func(db *gorm.DB, c *gin.Context) (uint, bool)
+
+// ProjectFromParam 路径参数即为项目ID
+func ProjectFromParam(param string) ProjectResolver {
+	return func(db *gorm.DB, c *gin.Context) (uint, bool) {
+		id, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil || id == 0 {
+			return 0, false
+		}
+		return uint(id), true
+	}
+}
+
+// ProjectFromObjectParam 路径参数为项目内对象的ID，通过对象表的 project_id 字段解析项目
+func ProjectFromObjectParam(table, param string) ProjectResolver {
+	return func(db *gorm.DB, c *gin.Context) (uint, bool) {
+		id, err := strconv.ParseUint(c.Param(param), 10, 32)
+		if err != nil || id == 0 {
+			return 0, false
+		}
+		var projectIDs []uint
+		if err := db.Table(table).Where("id = ? AND deleted_at IS NULL", id).Pluck("project_id", &projectIDs).Error; err != nil || len(projectIDs) == 0 || projectIDs[0] == 0 {
+			return 0, false
+		}
+		return projectIDs[0], true
+	}
+}
+
+// ProjectFromBody 请求体（JSON）中的字段为项目ID，读取后恢复请求体供处理函数继续使用
+func ProjectFromBody(field string) ProjectResolver {
+	return func(db *gorm.DB, c *gin.Context) (uint, bool) {
+		if c.Request == nil || c.Request.Body == nil {
+			return 0, false
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return 0, false
+		}
+
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return 0, false
+		}
+		value, ok := payload[field].(float64)
+		if !ok || value <= 0 {
+			return 0, false
+		}
+		return uint(value), true
+	}
+}
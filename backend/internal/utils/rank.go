@@ -0,0 +1,73 @@
+package utils
+
+import "strings"
+
+// rankDigits 排序键使用的字符（按字典序递增）
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// MaxRankLength 排序键的最大长度，超过后需要重新生成整列的排序键
+const MaxRankLength = 32
+
+// RankBetween 生成位于 prev 和 next 之间的排序键（字典序）
+// prev 为空表示没有下界，next 为空表示没有上界；生成的排序键不会以 '0' 结尾，保证之前总能再插入
+func RankBetween(prev, next string) string {
+	base := len(rankDigits)
+	var result []byte
+	lowerBound, upperBound := true, next != ""
+
+	for i := 0; ; i++ {
+		lo := 0
+		if lowerBound && i < len(prev) {
+			lo = strings.IndexByte(rankDigits, prev[i])
+		}
+		hi := base
+		if upperBound && i < len(next) {
+			hi = strings.IndexByte(rankDigits, next[i])
+		}
+		// prev 大于 next（数据异常）时忽略上界，保证结果大于 prev
+		if hi < lo {
+			upperBound = false
+			hi = base
+		}
+
+		if hi-lo > 1 {
+			return string(append(result, rankDigits[(lo+hi)/2]))
+		}
+
+		result = append(result, rankDigits[lo])
+		if hi-lo == 1 {
+			// 当前位已小于 next，后续位不再受上界限制
+			upperBound = false
+		}
+		if lowerBound && (i >= len(prev) || rankDigits[lo] != prev[i]) {
+			lowerBound = false
+		}
+	}
+}
+
+// RankSequence 生成 n 个等间距的递增排序键（用于初始化或重排整列）
+func RankSequence(n int) []string {
+	if n <= 0 {
+		return []string{}
+	}
+
+	// 选择足够的位数，使相邻排序键之间留有插入空间
+	width, capacity := 1, len(rankDigits)
+	for capacity < (n+1)*len(rankDigits) {
+		width++
+		capacity *= len(rankDigits)
+	}
+	step := capacity / (n + 1)
+
+	ranks := make([]string, n)
+	for i := 0; i < n; i++ {
+		value := (i + 1) * step
+		buf := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = rankDigits[value%len(rankDigits)]
+			value /= len(rankDigits)
+		}
+		ranks[i] = strings.TrimRight(string(buf), "0")
+	}
+	return ranks
+}
@@ -0,0 +1,391 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"prjflow/internal/model"
+)
+
+// 发布说明模板
+const (
+	ReleaseNotesStandard = "standard" // 修复的Bug按功能模块分组
+	ReleaseNotesSeverity = "severity" // 修复的Bug按严重程度分组
+	ReleaseNotesBrief    = "brief"    // 不分组，不包含已知问题
+)
+
+// IsValidReleaseNotesTemplate 检查发布说明模板是否合法
+func IsValidReleaseNotesTemplate(template string) bool {
+	switch template {
+	case ReleaseNotesStandard, ReleaseNotesSeverity, ReleaseNotesBrief:
+		return true
+	}
+	return false
+}
+
+var bugSeverityNames = map[string]string{"critical": "严重", "high": "高", "medium": "中", "low": "低"}
+var bugSeverityRank = map[string]int{"critical": 0, "high": 1, "medium": 2, "low": 3}
+
+// ReleaseNoteItem 发布说明中的一条需求或Bug
+type ReleaseNoteItem struct {
+	ID       uint   `json:"id"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+	Priority string `json:"priority"`
+	Severity string `json:"severity,omitempty"`
+	Module   string `json:"module,omitempty"`
+}
+
+// ReleaseNoteGroup 一组发布说明条目，Name 为空表示不分组
+type ReleaseNoteGroup struct {
+	Name  string            `json:"name"`
+	Items []ReleaseNoteItem `json:"items"`
+}
+
+// ReleaseNotes 版本的发布说明
+// 新需求为版本关联的需求，修复的Bug为解决版本是该版本的Bug，已知问题为影响该版本且仍未解决的Bug
+type ReleaseNotes struct {
+	ProjectID     uint               `json:"project_id"`
+	ProjectName   string             `json:"project_name"`
+	VersionID     uint               `json:"version_id"`
+	VersionNumber string             `json:"version_number"`
+	Status        string             `json:"status"`
+	ReleaseDate   *time.Time         `json:"release_date"`
+	Template      string             `json:"template"`
+	Requirements  []ReleaseNoteItem  `json:"requirements"`
+	FixedBugs     []ReleaseNoteGroup `json:"fixed_bugs"`
+	KnownIssues   []ReleaseNoteItem  `json:"known_issues"`
+}
+
+// fixedBugItems 返回所有修复的Bug（不分组）
+func (n *ReleaseNotes) fixedBugItems() []ReleaseNoteItem {
+	items := make([]ReleaseNoteItem, 0)
+	for _, group := range n.FixedBugs {
+		items = append(items, group.Items...)
+	}
+	return items
+}
+
+// BuildReleaseNotes 生成版本的发布说明，scope 用于过滤当前用户不可见的受限需求和Bug（参数为对象类型和查询）
+func BuildReleaseNotes(db *gorm.DB, version *model.Version, template string, scope func(objectType string, query *gorm.DB) *gorm.DB) (*ReleaseNotes, error) {
+	if template == "" {
+		template = ReleaseNotesStandard
+	}
+	var project model.Project
+	db.Select("id", "name").First(&project, version.ProjectID)
+
+	notes := &ReleaseNotes{
+		ProjectID:     version.ProjectID,
+		ProjectName:   project.Name,
+		VersionID:     version.ID,
+		VersionNumber: version.VersionNumber,
+		Status:        version.Status,
+		ReleaseDate:   version.ReleaseDate,
+		Template:      template,
+		Requirements:  make([]ReleaseNoteItem, 0),
+		FixedBugs:     make([]ReleaseNoteGroup, 0),
+		KnownIssues:   make([]ReleaseNoteItem, 0),
+	}
+
+	var requirements []model.Requirement
+	query := db.Joins("JOIN version_requirements ON version_requirements.requirement_id = requirements.id").
+		Where("version_requirements.version_id = ?", version.ID)
+	if err := scope("requirement", query).Order("requirements.id ASC").Find(&requirements).Error; err != nil {
+		return nil, err
+	}
+	for _, requirement := range requirements {
+		notes.Requirements = append(notes.Requirements, ReleaseNoteItem{
+			ID: requirement.ID, Title: requirement.Title, Status: requirement.Status, Priority: requirement.Priority,
+		})
+	}
+
+	var fixed []model.Bug
+	query = db.Preload("Module").Where("bugs.resolved_version_id = ? AND bugs.status IN ?", version.ID, []string{"resolved", "closed"})
+	if err := scope("bug", query).Order("bugs.id ASC").Find(&fixed).Error; err != nil {
+		return nil, err
+	}
+	notes.FixedBugs = groupReleaseNoteBugs(fixed, template)
+
+	if template != ReleaseNotesBrief {
+		var known []model.Bug
+		query = db.Preload("Module").Joins("JOIN version_bugs ON version_bugs.bug_id = bugs.id").
+			Where("version_bugs.version_id = ? AND bugs.status = ?", version.ID, "active")
+		if err := scope("bug", query).Order("bugs.id ASC").Find(&known).Error; err != nil {
+			return nil, err
+		}
+		notes.KnownIssues = releaseNoteBugItems(known)
+	}
+	return notes, nil
+}
+
+// releaseNoteBugItems 转换Bug为发布说明条目，按严重程度排序
+func releaseNoteBugItems(bugs []model.Bug) []ReleaseNoteItem {
+	items := make([]ReleaseNoteItem, 0, len(bugs))
+	for _, bug := range bugs {
+		item := ReleaseNoteItem{ID: bug.ID, Title: bug.Title, Status: bug.Status, Priority: bug.Priority, Severity: bug.Severity}
+		if bug.Module != nil {
+			item.Module = bug.Module.Name
+		}
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return severityRank(items[i].Severity) < severityRank(items[j].Severity)
+	})
+	return items
+}
+
+// groupReleaseNoteBugs 按模板对修复的Bug分组
+func groupReleaseNoteBugs(bugs []model.Bug, template string) []ReleaseNoteGroup {
+	items := releaseNoteBugItems(bugs)
+	groups := make([]ReleaseNoteGroup, 0)
+	if len(items) == 0 {
+		return groups
+	}
+	if template == ReleaseNotesBrief {
+		return append(groups, ReleaseNoteGroup{Items: items})
+	}
+
+	index := make(map[string]int)
+	for _, item := range items {
+		name := item.Module
+		if template == ReleaseNotesSeverity {
+			name = bugSeverityName(item.Severity)
+		} else if name == "" {
+			name = "其他"
+		}
+		if i, ok := index[name]; ok {
+			groups[i].Items = append(groups[i].Items, item)
+			continue
+		}
+		index[name] = len(groups)
+		groups = append(groups, ReleaseNoteGroup{Name: name, Items: []ReleaseNoteItem{item}})
+	}
+	// 按严重程度分组时组的顺序即严重程度顺序；按模块分组时按模块名称排序，未关联模块的排在最后
+	if template != ReleaseNotesSeverity {
+		sort.SliceStable(groups, func(i, j int) bool {
+			if groups[i].Name == "其他" || groups[j].Name == "其他" {
+				return groups[j].Name == "其他" && groups[i].Name != "其他"
+			}
+			return groups[i].Name < groups[j].Name
+		})
+	}
+	return groups
+}
+
+func severityRank(severity string) int {
+	if rank, ok := bugSeverityRank[severity]; ok {
+		return rank
+	}
+	return len(bugSeverityRank)
+}
+
+func bugSeverityName(severity string) string {
+	if name, ok := bugSeverityNames[severity]; ok {
+		return name
+	}
+	return severity
+}
+
+// ReleaseNotesDiff 两个版本发布说明的差异
+type ReleaseNotesDiff struct {
+	Base                *ReleaseNotes     `json:"base"`
+	Target              *ReleaseNotes     `json:"target"`
+	AddedRequirements   []ReleaseNoteItem `json:"added_requirements"`   // 目标版本新增的需求
+	RemovedRequirements []ReleaseNoteItem `json:"removed_requirements"` // 基准版本有、目标版本没有的需求
+	FixedBugs           []ReleaseNoteItem `json:"fixed_bugs"`           // 目标版本修复、基准版本未修复的Bug
+	ResolvedIssues      []ReleaseNoteItem `json:"resolved_issues"`      // 基准版本的已知问题，在目标版本中不再存在
+	NewIssues           []ReleaseNoteItem `json:"new_issues"`           // 目标版本新出现的已知问题
+}
+
+// DiffReleaseNotes 比较两个版本的发布说明
+func DiffReleaseNotes(base, target *ReleaseNotes) *ReleaseNotesDiff {
+	return &ReleaseNotesDiff{
+		Base:                base,
+		Target:              target,
+		AddedRequirements:   releaseNoteItemsMinus(target.Requirements, base.Requirements),
+		RemovedRequirements: releaseNoteItemsMinus(base.Requirements, target.Requirements),
+		FixedBugs:           releaseNoteItemsMinus(target.fixedBugItems(), base.fixedBugItems()),
+		ResolvedIssues:      releaseNoteItemsMinus(base.KnownIssues, target.KnownIssues),
+		NewIssues:           releaseNoteItemsMinus(target.KnownIssues, base.KnownIssues),
+	}
+}
+
+// releaseNoteItemsMinus 返回 a 中不在 b 中的条目
+func releaseNoteItemsMinus(a, b []ReleaseNoteItem) []ReleaseNoteItem {
+	exists := make(map[uint]bool, len(b))
+	for _, item := range b {
+		exists[item.ID] = true
+	}
+	result := make([]ReleaseNoteItem, 0)
+	for _, item := range a {
+		if !exists[item.ID] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// releaseNoteSection 渲染用的章节
+type releaseNoteSection struct {
+	title  string
+	groups []ReleaseNoteGroup
+}
+
+func (n *ReleaseNotes) title() string {
+	title := fmt.Sprintf("%s %s", n.ProjectName, n.VersionNumber)
+	if n.ReleaseDate != nil {
+		title += fmt.Sprintf("（%s）", n.ReleaseDate.Format("2006-01-02"))
+	}
+	return strings.TrimSpace(title)
+}
+
+func (n *ReleaseNotes) sections() []releaseNoteSection {
+	sections := []releaseNoteSection{
+		{title: "新需求", groups: []ReleaseNoteGroup{{Items: n.Requirements}}},
+		{title: "修复的Bug", groups: n.FixedBugs},
+	}
+	if n.Template != ReleaseNotesBrief {
+		sections = append(sections, releaseNoteSection{title: "已知问题", groups: []ReleaseNoteGroup{{Items: n.KnownIssues}}})
+	}
+	return sections
+}
+
+func (d *ReleaseNotesDiff) title() string {
+	return fmt.Sprintf("%s %s → %s", d.Target.ProjectName, d.Base.VersionNumber, d.Target.VersionNumber)
+}
+
+func (d *ReleaseNotesDiff) sections() []releaseNoteSection {
+	section := func(title string, items []ReleaseNoteItem) releaseNoteSection {
+		return releaseNoteSection{title: title, groups: []ReleaseNoteGroup{{Items: items}}}
+	}
+	return []releaseNoteSection{
+		section("新增需求", d.AddedRequirements),
+		section("移除的需求", d.RemovedRequirements),
+		section("新修复的Bug", d.FixedBugs),
+		section("已解决的已知问题", d.ResolvedIssues),
+		section("新增的已知问题", d.NewIssues),
+	}
+}
+
+// releaseNoteItemText 条目的文本描述
+func releaseNoteItemText(item ReleaseNoteItem) string {
+	text := fmt.Sprintf("#%d %s", item.ID, item.Title)
+	if item.Severity != "" {
+		text += fmt.Sprintf("（严重程度：%s）", bugSeverityName(item.Severity))
+	}
+	return text
+}
+
+// writeMarkdown 以 level 级标题写入一个文档，章节和分组的标题依次降级
+func writeMarkdown(b *strings.Builder, title string, sections []releaseNoteSection, level int) {
+	heading := func(offset int) string { return strings.Repeat("#", level+offset) }
+	fmt.Fprintf(b, "%s %s\n", heading(0), title)
+	for _, section := range sections {
+		fmt.Fprintf(b, "\n%s %s\n\n", heading(1), section.title)
+		empty := true
+		for _, group := range section.groups {
+			if len(group.Items) == 0 {
+				continue
+			}
+			if group.Name != "" {
+				fmt.Fprintf(b, "%s %s\n\n", heading(2), group.Name)
+			}
+			for _, item := range group.Items {
+				fmt.Fprintf(b, "- %s\n", releaseNoteItemText(item))
+			}
+			if group.Name != "" {
+				b.WriteString("\n")
+			}
+			empty = false
+		}
+		if empty {
+			b.WriteString("无\n")
+		}
+	}
+}
+
+// writeHTML 以 level 级标题写入一个文档的 HTML 片段
+func writeHTML(b *strings.Builder, title string, sections []releaseNoteSection, level int) {
+	fmt.Fprintf(b, "<h%[1]d>%[2]s</h%[1]d>\n", level, html.EscapeString(title))
+	for _, section := range sections {
+		fmt.Fprintf(b, "<h%[1]d>%[2]s</h%[1]d>\n", level+1, html.EscapeString(section.title))
+		empty := true
+		for _, group := range section.groups {
+			if len(group.Items) == 0 {
+				continue
+			}
+			if group.Name != "" {
+				fmt.Fprintf(b, "<h%[1]d>%[2]s</h%[1]d>\n", level+2, html.EscapeString(group.Name))
+			}
+			b.WriteString("<ul>\n")
+			for _, item := range group.Items {
+				fmt.Fprintf(b, "<li>%s</li>\n", html.EscapeString(releaseNoteItemText(item)))
+			}
+			b.WriteString("</ul>\n")
+			empty = false
+		}
+		if empty {
+			b.WriteString("<p>无</p>\n")
+		}
+	}
+}
+
+// htmlDocument 包装为完整的 HTML 文档
+func htmlDocument(title string, body func(b *strings.Builder)) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n", html.EscapeString(title))
+	body(&b)
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// Markdown 渲染发布说明为 Markdown
+func (n *ReleaseNotes) Markdown() string {
+	var b strings.Builder
+	writeMarkdown(&b, n.title(), n.sections(), 1)
+	return b.String()
+}
+
+// HTML 渲染发布说明为 HTML 文档
+func (n *ReleaseNotes) HTML() string {
+	return htmlDocument(n.title(), func(b *strings.Builder) { writeHTML(b, n.title(), n.sections(), 1) })
+}
+
+// Markdown 渲染版本差异为 Markdown
+func (d *ReleaseNotesDiff) Markdown() string {
+	var b strings.Builder
+	writeMarkdown(&b, d.title(), d.sections(), 1)
+	return b.String()
+}
+
+// HTML 渲染版本差异为 HTML 文档
+func (d *ReleaseNotesDiff) HTML() string {
+	return htmlDocument(d.title(), func(b *strings.Builder) { writeHTML(b, d.title(), d.sections(), 1) })
+}
+
+// ChangelogMarkdown 渲染项目变更日志（多个版本的发布说明，按传入顺序）
+func ChangelogMarkdown(projectName string, releases []*ReleaseNotes) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s 变更日志\n", projectName)
+	for _, notes := range releases {
+		b.WriteString("\n")
+		writeMarkdown(&b, notes.title(), notes.sections(), 2)
+	}
+	return b.String()
+}
+
+// ChangelogHTML 渲染项目变更日志为 HTML 文档
+func ChangelogHTML(projectName string, releases []*ReleaseNotes) string {
+	title := projectName + " 变更日志"
+	return htmlDocument(title, func(b *strings.Builder) {
+		fmt.Fprintf(b, "<h1>%s</h1>\n", html.EscapeString(title))
+		for _, notes := range releases {
+			writeHTML(b, notes.title(), notes.sections(), 2)
+		}
+	})
+}
@@ -0,0 +1,305 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"prjflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// 报告自动化的系统配置项
+const (
+	ReportDraftEnabledKey    = "report_draft_enabled"
+	ReportDraftTimeKey       = "report_draft_time"
+	ReportReminderEnabledKey = "report_reminder_enabled"
+	ReportReminderTimeKey    = "report_reminder_time"
+	ReportReminderEmailKey   = "report_reminder_email"
+)
+
+// NotificationReportReminder 日报未提交提醒
+const NotificationReportReminder = "report_reminder"
+
+// ReportAutomationConfig 报告自动化配置
+type ReportAutomationConfig struct {
+	DraftEnabled    bool   `json:"draft_enabled"`    // 工作日自动生成日报草稿（周五同时生成周报草稿）
+	DraftTime       string `json:"draft_time"`       // 生成草稿的时间（HH:MM）
+	ReminderEnabled bool   `json:"reminder_enabled"` // 工作日提醒未提交日报的用户
+	ReminderTime    string `json:"reminder_time"`    // 提醒时间（HH:MM）
+	ReminderEmail   bool   `json:"reminder_email"`   // 提醒时同时发送邮件
+}
+
+// LoadReportAutomationConfig 读取报告自动化配置（默认不启用）
+func LoadReportAutomationConfig(db *gorm.DB) ReportAutomationConfig {
+	cfg := ReportAutomationConfig{DraftTime: "17:00", ReminderTime: "18:30"}
+	var configs []model.SystemConfig
+	db.Where("key IN ?", []string{ReportDraftEnabledKey, ReportDraftTimeKey, ReportReminderEnabledKey, ReportReminderTimeKey, ReportReminderEmailKey}).Find(&configs)
+	for _, item := range configs {
+		switch item.Key {
+		case ReportDraftEnabledKey:
+			cfg.DraftEnabled = item.Value == "true"
+		case ReportDraftTimeKey:
+			cfg.DraftTime = item.Value
+		case ReportReminderEnabledKey:
+			cfg.ReminderEnabled = item.Value == "true"
+		case ReportReminderTimeKey:
+			cfg.ReminderTime = item.Value
+		case ReportReminderEmailKey:
+			cfg.ReminderEmail = item.Value == "true"
+		}
+	}
+	return cfg
+}
+
+// SaveReportAutomationConfig 保存报告自动化配置
+func SaveReportAutomationConfig(db *gorm.DB, cfg ReportAutomationConfig) error {
+	values := []model.SystemConfig{
+		{Key: ReportDraftEnabledKey, Value: fmt.Sprintf("%t", cfg.DraftEnabled), Type: "boolean"},
+		{Key: ReportDraftTimeKey, Value: cfg.DraftTime, Type: "string"},
+		{Key: ReportReminderEnabledKey, Value: fmt.Sprintf("%t", cfg.ReminderEnabled), Type: "boolean"},
+		{Key: ReportReminderTimeKey, Value: cfg.ReminderTime, Type: "string"},
+		{Key: ReportReminderEmailKey, Value: fmt.Sprintf("%t", cfg.ReminderEmail), Type: "boolean"},
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, value := range values {
+			item := value
+			if err := tx.Where("key = ?", value.Key).
+				Assign(model.SystemConfig{Value: value.Value, Type: value.Type}).
+				FirstOrCreate(&item).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// reportDate 报告日期（与接口中按 YYYY-MM-DD 解析的日期一致）
+func reportDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// IsWorkday 是否工作日（周一至周五）
+func IsWorkday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// activeUserIDs 所有启用状态的用户
+func activeUserIDs(db *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&model.User{}).Where("status = ?", 1).Order("id ASC").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// DraftDailyReports 为当天还没有日报的启用用户根据工作内容汇总生成日报草稿，返回生成的数量
+func DraftDailyReports(db *gorm.DB, date time.Time) (int, error) {
+	day := reportDate(date)
+	userIDs, err := activeUserIDs(db)
+	if err != nil {
+		return 0, err
+	}
+	var existing []uint
+	if err := db.Model(&model.DailyReport{}).Where("date = ?", day).Pluck("user_id", &existing).Error; err != nil {
+		return 0, err
+	}
+	skip := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		skip[id] = true
+	}
+
+	created := 0
+	for _, userID := range userIDs {
+		if skip[userID] {
+			continue
+		}
+		content, _ := SummarizeWorkContent(db, userID, day, day)
+		report := model.DailyReport{Date: day, Content: content, Status: "draft", UserID: userID}
+		if err := db.Create(&report).Error; err != nil {
+			if Logger != nil {
+				Logger.Warnf("[Scheduler] Failed to draft daily report of user %d: %v", userID, err)
+			}
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+// DraftWeeklyReports 为本周（周一至周日）还没有周报的启用用户生成周报草稿，返回生成的数量
+func DraftWeeklyReports(db *gorm.DB, date time.Time) (int, error) {
+	day := reportDate(date)
+	offset := (int(day.Weekday()) + 6) % 7
+	weekStart := day.AddDate(0, 0, -offset)
+	weekEnd := weekStart.AddDate(0, 0, 6)
+
+	userIDs, err := activeUserIDs(db)
+	if err != nil {
+		return 0, err
+	}
+	var existing []uint
+	if err := db.Model(&model.WeeklyReport{}).Where("week_start = ?", weekStart).Pluck("user_id", &existing).Error; err != nil {
+		return 0, err
+	}
+	skip := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		skip[id] = true
+	}
+
+	created := 0
+	for _, userID := range userIDs {
+		if skip[userID] {
+			continue
+		}
+		// 汇总到当天为止的工作内容
+		summary, _ := SummarizeWorkContent(db, userID, weekStart, day)
+		report := model.WeeklyReport{WeekStart: weekStart, WeekEnd: weekEnd, Summary: summary, Status: "draft", UserID: userID}
+		if err := db.Create(&report).Error; err != nil {
+			if Logger != nil {
+				Logger.Warnf("[Scheduler] Failed to draft weekly report of user %d: %v", userID, err)
+			}
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+// ComplianceUser 未提交日报的用户
+type ComplianceUser struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+	Status   string `json:"status"` // none（未填写）, draft（草稿未提交）
+}
+
+// DepartmentCompliance 部门的日报提交情况
+type DepartmentCompliance struct {
+	DepartmentID   *uint            `json:"department_id"`
+	DepartmentName string           `json:"department_name"`
+	Total          int              `json:"total"`
+	Submitted      int              `json:"submitted"`
+	Missing        []ComplianceUser `json:"missing"`
+}
+
+// DailyCompliance 某一天的日报提交情况
+type DailyCompliance struct {
+	Date        string                  `json:"date"`
+	Total       int                     `json:"total"`
+	Submitted   int                     `json:"submitted"`
+	Departments []*DepartmentCompliance `json:"departments"`
+}
+
+// departmentSubtree 部门及其所有下级部门ID
+func departmentSubtree(db *gorm.DB, departmentID uint) []uint {
+	ids := []uint{departmentID}
+	visited := map[uint]bool{departmentID: true}
+	for i := 0; i < len(ids); i++ {
+		var children []uint
+		db.Model(&model.Department{}).Where("parent_id = ?", ids[i]).Pluck("id", &children)
+		for _, child := range children {
+			if !visited[child] {
+				visited[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids
+}
+
+// ReportCompliance 统计日期范围内每天各部门的日报提交情况（已提交或已审批视为已提交）
+// departmentID 不为空时只统计该部门及其下级部门；includeWeekends 为 false 时跳过周末
+func ReportCompliance(db *gorm.DB, start, end time.Time, departmentID *uint, includeWeekends bool) ([]DailyCompliance, error) {
+	query := db.Preload("Department").Where("status = ?", 1)
+	if departmentID != nil {
+		query = query.Where("department_id IN ?", departmentSubtree(db, *departmentID))
+	}
+	var users []model.User
+	if err := query.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	start, end = reportDate(start), reportDate(end)
+	var reports []model.DailyReport
+	if err := db.Select("user_id", "date", "status").Where("date >= ? AND date <= ?", start, end).Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]string, len(reports))
+	for _, report := range reports {
+		statuses[fmt.Sprintf("%d@%s", report.UserID, report.Date.Format("2006-01-02"))] = report.Status
+	}
+
+	result := make([]DailyCompliance, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if !includeWeekends && !IsWorkday(day) {
+			continue
+		}
+		date := day.Format("2006-01-02")
+		daily := DailyCompliance{Date: date, Departments: make([]*DepartmentCompliance, 0)}
+		departments := make(map[uint]*DepartmentCompliance)
+		var unassigned *DepartmentCompliance
+		for _, user := range users {
+			var dept *DepartmentCompliance
+			if user.DepartmentID == nil || user.Department == nil {
+				if unassigned == nil {
+					unassigned = &DepartmentCompliance{DepartmentName: "未分配部门", Missing: make([]ComplianceUser, 0)}
+				}
+				dept = unassigned
+			} else if dept = departments[*user.DepartmentID]; dept == nil {
+				dept = &DepartmentCompliance{DepartmentID: user.DepartmentID, DepartmentName: user.Department.Name, Missing: make([]ComplianceUser, 0)}
+				departments[*user.DepartmentID] = dept
+				daily.Departments = append(daily.Departments, dept)
+			}
+
+			dept.Total++
+			daily.Total++
+			switch status := statuses[fmt.Sprintf("%d@%s", user.ID, date)]; status {
+			case "submitted", "approved":
+				dept.Submitted++
+				daily.Submitted++
+			case "":
+				dept.Missing = append(dept.Missing, ComplianceUser{UserID: user.ID, Username: user.Username, Nickname: user.Nickname, Status: "none"})
+			default:
+				dept.Missing = append(dept.Missing, ComplianceUser{UserID: user.ID, Username: user.Username, Nickname: user.Nickname, Status: "draft"})
+			}
+		}
+		sort.SliceStable(daily.Departments, func(i, j int) bool {
+			return *daily.Departments[i].DepartmentID < *daily.Departments[j].DepartmentID
+		})
+		if unassigned != nil {
+			daily.Departments = append(daily.Departments, unassigned)
+		}
+		result = append(result, daily)
+	}
+	return result, nil
+}
+
+// RemindMissingDailyReports 提醒当天还没有提交日报的启用用户（同一天只提醒一次），返回提醒的人数
+func RemindMissingDailyReports(db *gorm.DB, date time.Time, sendMail bool) (int, error) {
+	compliance, err := ReportCompliance(db, date, date, nil, true)
+	if err != nil || len(compliance) == 0 {
+		return 0, err
+	}
+
+	day := compliance[0].Date
+	link := "/reports/daily?date=" + day
+	reminded := 0
+	for _, dept := range compliance[0].Departments {
+		for _, user := range dept.Missing {
+			var count int64
+			db.Model(&model.Notification{}).Where("user_id = ? AND type = ? AND link = ?", user.UserID, NotificationReportReminder, link).Count(&count)
+			if count > 0 {
+				continue
+			}
+			content := fmt.Sprintf("您 %s 的日报尚未填写，请及时填写并提交。", day)
+			if user.Status == "draft" {
+				content = fmt.Sprintf("您 %s 的日报还是草稿，请确认内容后提交。", day)
+			}
+			if err := Notify(db, user.UserID, NotificationReportReminder, "日报提交提醒", content, link, sendMail); err != nil {
+				return reminded, err
+			}
+			reminded++
+		}
+	}
+	return reminded, nil
+}
@@ -0,0 +1,517 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"prjflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// 汇总范围
+const (
+	RollupScopeDepartment = "department"
+	RollupScopeProject    = "project"
+)
+
+// RollupProjectHours 项目工时
+type RollupProjectHours struct {
+	ProjectID   uint    `json:"project_id"`
+	ProjectName string  `json:"project_name"`
+	Hours       float64 `json:"hours"`
+}
+
+// RollupItem 完成或新建的工作项
+type RollupItem struct {
+	Type        string `json:"type"` // task, bug, requirement
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	ProjectName string `json:"project_name"`
+	MemberName  string `json:"member_name"`
+}
+
+// RollupBlocker 阻塞与风险：暂停或逾期的任务，以及报告中“阻塞/风险/问题/困难”章节的内容
+type RollupBlocker struct {
+	Source      string `json:"source"` // task, report
+	Description string `json:"description"`
+	ProjectName string `json:"project_name,omitempty"`
+	MemberName  string `json:"member_name"`
+}
+
+// RollupReport 成员提交的日报或周报
+type RollupReport struct {
+	Type    string `json:"type"` // daily, weekly
+	ID      uint   `json:"id"`
+	Period  string `json:"period"`
+	Status  string `json:"status"`
+	Content string `json:"content"`
+}
+
+// RollupMember 成员的工作汇总
+type RollupMember struct {
+	UserID         uint                  `json:"user_id"`
+	Username       string                `json:"username"`
+	Nickname       string                `json:"nickname"`
+	DepartmentName string                `json:"department_name"`
+	Hours          float64               `json:"hours"`
+	ProjectHours   []*RollupProjectHours `json:"project_hours"`
+	Completed      []RollupItem          `json:"completed"`
+	Created        []RollupItem          `json:"created"`
+	Blockers       []RollupBlocker       `json:"blockers"`
+	Reports        []RollupReport        `json:"reports"`
+}
+
+// Name 成员显示名称
+func (m *RollupMember) Name() string {
+	if m.Nickname != "" {
+		return m.Nickname
+	}
+	return m.Username
+}
+
+// ReportRollup 部门或项目在一段时间内的工作汇总
+type ReportRollup struct {
+	Scope        string                `json:"scope"`
+	ScopeID      uint                  `json:"scope_id"`
+	ScopeName    string                `json:"scope_name"`
+	StartDate    string                `json:"start_date"`
+	EndDate      string                `json:"end_date"`
+	TotalHours   float64               `json:"total_hours"`
+	ReportCount  int                   `json:"report_count"`
+	ProjectHours []*RollupProjectHours `json:"project_hours"`
+	Members      []*RollupMember       `json:"members"`
+}
+
+// BuildDepartmentRollup 汇总部门（包括下级部门）成员的工作
+func BuildDepartmentRollup(db *gorm.DB, departmentID uint, start, end time.Time) (*ReportRollup, error) {
+	var department model.Department
+	if err := db.First(&department, departmentID).Error; err != nil {
+		return nil, err
+	}
+	var users []model.User
+	if err := db.Preload("Department").Where("status = ? AND department_id IN ?", 1, departmentSubtree(db, departmentID)).
+		Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	rollup := &ReportRollup{Scope: RollupScopeDepartment, ScopeID: department.ID, ScopeName: department.Name}
+	return rollup, rollup.build(db, users, nil, start, end)
+}
+
+// IsDepartmentLeader 判断用户是否为该部门或其上级部门的负责人
+func IsDepartmentLeader(db *gorm.DB, userID, departmentID uint) bool {
+	visited := map[uint]bool{}
+	for id := departmentID; id != 0 && !visited[id]; {
+		visited[id] = true
+		var department model.Department
+		if err := db.Select("id", "parent_id", "leader_id").First(&department, id).Error; err != nil {
+			return false
+		}
+		if department.LeaderID != nil && *department.LeaderID == userID {
+			return true
+		}
+		if department.ParentID == nil {
+			break
+		}
+		id = *department.ParentID
+	}
+	return false
+}
+
+// BuildProjectRollup 汇总项目成员在项目中的工作（工时和工作项只统计该项目，报告为成员提交的完整报告）
+func BuildProjectRollup(db *gorm.DB, projectID uint, start, end time.Time) (*ReportRollup, error) {
+	var project model.Project
+	if err := db.First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+	var users []model.User
+	if err := db.Preload("Department").
+		Where("status = ? AND id IN (SELECT user_id FROM project_members WHERE project_id = ? AND deleted_at IS NULL)", 1, projectID).
+		Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	rollup := &ReportRollup{Scope: RollupScopeProject, ScopeID: project.ID, ScopeName: project.Name}
+	return rollup, rollup.build(db, users, &project.ID, start, end)
+}
+
+// build 汇总成员的工时、完成和新建的工作项、阻塞项以及已提交的报告
+func (r *ReportRollup) build(db *gorm.DB, users []model.User, projectID *uint, start, end time.Time) error {
+	start, end = reportDate(start), reportDate(end)
+	endExclusive := end.AddDate(0, 0, 1)
+	r.StartDate, r.EndDate = start.Format("2006-01-02"), end.Format("2006-01-02")
+	r.ProjectHours = make([]*RollupProjectHours, 0)
+	r.Members = make([]*RollupMember, 0, len(users))
+
+	members := make(map[uint]*RollupMember, len(users))
+	userIDs := make([]uint, 0, len(users))
+	for _, user := range users {
+		member := &RollupMember{
+			UserID: user.ID, Username: user.Username, Nickname: user.Nickname,
+			ProjectHours: make([]*RollupProjectHours, 0), Completed: make([]RollupItem, 0), Created: make([]RollupItem, 0),
+			Blockers: make([]RollupBlocker, 0), Reports: make([]RollupReport, 0),
+		}
+		if user.Department != nil {
+			member.DepartmentName = user.Department.Name
+		}
+		members[user.ID] = member
+		userIDs = append(userIDs, user.ID)
+		r.Members = append(r.Members, member)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	projectNames := make(map[uint]string)
+	projectName := func(id uint) string {
+		if name, ok := projectNames[id]; ok {
+			return name
+		}
+		var project model.Project
+		name := "未知项目"
+		if id > 0 && db.Select("id", "name").First(&project, id).Error == nil {
+			name = project.Name
+		}
+		projectNames[id] = name
+		return name
+	}
+	inScope := func(id uint) bool { return projectID == nil || id == *projectID }
+
+	// 工时：资源分配按项目汇总（未直接关联项目时依次取关联任务、Bug、需求和资源所属的项目）
+	var allocations []struct {
+		UserID             uint
+		Hours              float64
+		ProjectID          *uint
+		TaskProject        *uint
+		BugProject         *uint
+		RequirementProject *uint
+		ResourceProject    uint
+	}
+	if err := db.Table("resource_allocations").
+		Select("resources.user_id, resource_allocations.hours, resource_allocations.project_id, tasks.project_id AS task_project, bugs.project_id AS bug_project, requirements.project_id AS requirement_project, resources.project_id AS resource_project").
+		Joins("JOIN resources ON resource_allocations.resource_id = resources.id").
+		Joins("LEFT JOIN tasks ON resource_allocations.task_id = tasks.id").
+		Joins("LEFT JOIN bugs ON resource_allocations.bug_id = bugs.id").
+		Joins("LEFT JOIN requirements ON resource_allocations.requirement_id = requirements.id").
+		Where("resource_allocations.deleted_at IS NULL AND resources.user_id IN ?", userIDs).
+		Where("resource_allocations.date >= ? AND resource_allocations.date < ?", start, endExclusive).
+		Scan(&allocations).Error; err != nil {
+		return err
+	}
+	totals := make(map[uint]*RollupProjectHours)
+	for _, alloc := range allocations {
+		var pid uint
+		for _, candidate := range []*uint{alloc.ProjectID, alloc.TaskProject, alloc.BugProject, alloc.RequirementProject, &alloc.ResourceProject} {
+			if candidate != nil {
+				pid = *candidate
+				break
+			}
+		}
+		if !inScope(pid) {
+			continue
+		}
+		member := members[alloc.UserID]
+		member.Hours += alloc.Hours
+		r.TotalHours += alloc.Hours
+		addProjectHours(&member.ProjectHours, nil, pid, projectName(pid), alloc.Hours)
+		addProjectHours(&r.ProjectHours, totals, pid, projectName(pid), alloc.Hours)
+	}
+	sort.SliceStable(r.ProjectHours, func(i, j int) bool { return r.ProjectHours[i].Hours > r.ProjectHours[j].Hours })
+
+	scoped := func(query *gorm.DB) *gorm.DB {
+		if projectID != nil {
+			return query.Where("project_id = ?", *projectID)
+		}
+		return query
+	}
+
+	// 完成的任务
+	var doneTasks []model.Task
+	scoped(db.Where("assignee_id IN ? AND status = ? AND updated_at >= ? AND updated_at < ?", userIDs, "done", start, endExclusive)).
+		Order("id ASC").Find(&doneTasks)
+	for _, task := range doneTasks {
+		member := members[*task.AssigneeID]
+		member.Completed = append(member.Completed, RollupItem{Type: "task", ID: task.ID, Title: task.Title, ProjectName: projectName(task.ProjectID), MemberName: member.Name()})
+	}
+
+	// 解决的Bug（通过操作记录找到真正的解决者）
+	var resolved []model.Action
+	scoped(db.Where("object_type = ? AND action IN ? AND actor_id IN ? AND date >= ? AND date < ?", "bug", []string{"resolved", "closed"}, userIDs, start, endExclusive)).
+		Order("date ASC").Find(&resolved)
+	seenBugs := make(map[string]bool)
+	for _, action := range resolved {
+		key := fmt.Sprintf("%d@%d", action.ActorID, action.ObjectID)
+		if seenBugs[key] {
+			continue
+		}
+		seenBugs[key] = true
+		var bug model.Bug
+		if db.Select("id", "title", "project_id").First(&bug, action.ObjectID).Error != nil || !inScope(bug.ProjectID) {
+			continue
+		}
+		member := members[action.ActorID]
+		member.Completed = append(member.Completed, RollupItem{Type: "bug", ID: bug.ID, Title: bug.Title, ProjectName: projectName(bug.ProjectID), MemberName: member.Name()})
+	}
+
+	// 新建的任务、Bug和需求
+	var createdTasks []model.Task
+	scoped(db.Where("creator_id IN ? AND created_at >= ? AND created_at < ?", userIDs, start, endExclusive)).Order("id ASC").Find(&createdTasks)
+	for _, task := range createdTasks {
+		member := members[task.CreatorID]
+		member.Created = append(member.Created, RollupItem{Type: "task", ID: task.ID, Title: task.Title, ProjectName: projectName(task.ProjectID), MemberName: member.Name()})
+	}
+	var createdBugs []model.Bug
+	scoped(db.Where("creator_id IN ? AND created_at >= ? AND created_at < ?", userIDs, start, endExclusive)).Order("id ASC").Find(&createdBugs)
+	for _, bug := range createdBugs {
+		member := members[bug.CreatorID]
+		member.Created = append(member.Created, RollupItem{Type: "bug", ID: bug.ID, Title: bug.Title, ProjectName: projectName(bug.ProjectID), MemberName: member.Name()})
+	}
+	var createdRequirements []model.Requirement
+	scoped(db.Where("creator_id IN ? AND created_at >= ? AND created_at < ?", userIDs, start, endExclusive)).Order("id ASC").Find(&createdRequirements)
+	for _, requirement := range createdRequirements {
+		member := members[requirement.CreatorID]
+		member.Created = append(member.Created, RollupItem{Type: "requirement", ID: requirement.ID, Title: requirement.Title, ProjectName: projectName(requirement.ProjectID), MemberName: member.Name()})
+	}
+
+	// 阻塞：暂停的任务和截至期末逾期未完成的任务
+	var blockedTasks []model.Task
+	scoped(db.Where("assignee_id IN ?", userIDs).
+		Where("status = ? OR (status IN ? AND COALESCE(due_date, end_date) < ?)", "pause", []string{"wait", "doing"}, endExclusive)).
+		Order("id ASC").Find(&blockedTasks)
+	for _, task := range blockedTasks {
+		member := members[*task.AssigneeID]
+		description := "任务已暂停：" + task.Title
+		if task.Status != "pause" {
+			deadline := task.DueDate
+			if deadline == nil {
+				deadline = task.EndDate
+			}
+			description = fmt.Sprintf("任务已逾期（截止 %s）：%s", deadline.Format("2006-01-02"), task.Title)
+		}
+		member.Blockers = append(member.Blockers, RollupBlocker{Source: "task", Description: description, ProjectName: projectName(task.ProjectID), MemberName: member.Name()})
+	}
+
+	// 已提交的日报和周报
+	submitted := []string{"submitted", "approved"}
+	var dailyReports []model.DailyReport
+	if err := db.Where("user_id IN ? AND status IN ? AND date >= ? AND date <= ?", userIDs, submitted, start, end).
+		Order("date ASC").Find(&dailyReports).Error; err != nil {
+		return err
+	}
+	for _, report := range dailyReports {
+		members[report.UserID].addReport(RollupReport{Type: "daily", ID: report.ID, Period: report.Date.Format("2006-01-02"), Status: report.Status, Content: report.Content})
+	}
+	var weeklyReports []model.WeeklyReport
+	if err := db.Where("user_id IN ? AND status IN ? AND week_start <= ? AND week_end >= ?", userIDs, submitted, end, start).
+		Order("week_start ASC").Find(&weeklyReports).Error; err != nil {
+		return err
+	}
+	for _, report := range weeklyReports {
+		content := report.Summary
+		if strings.TrimSpace(report.NextWeekPlan) != "" {
+			content += "\n\n## 下周计划\n\n" + report.NextWeekPlan
+		}
+		members[report.UserID].addReport(RollupReport{
+			Type: "weekly", ID: report.ID, Period: report.WeekStart.Format("2006-01-02") + " ~ " + report.WeekEnd.Format("2006-01-02"), Status: report.Status, Content: content,
+		})
+	}
+	r.ReportCount = len(dailyReports) + len(weeklyReports)
+	return nil
+}
+
+// addProjectHours 累加项目工时；index 不为空时用于按项目快速查找
+func addProjectHours(list *[]*RollupProjectHours, index map[uint]*RollupProjectHours, projectID uint, name string, hours float64) {
+	if index != nil {
+		if item, ok := index[projectID]; ok {
+			item.Hours += hours
+			return
+		}
+	} else {
+		for _, item := range *list {
+			if item.ProjectID == projectID {
+				item.Hours += hours
+				return
+			}
+		}
+	}
+	item := &RollupProjectHours{ProjectID: projectID, ProjectName: name, Hours: hours}
+	*list = append(*list, item)
+	if index != nil {
+		index[projectID] = item
+	}
+}
+
+// blockerHeadingPattern 报告中记录阻塞和风险的章节标题
+var blockerHeadingPattern = regexp.MustCompile(`(?i)阻塞|风险|问题|困难|blocker|risk`)
+
+// addReport 添加报告，并提取其中“阻塞/风险/问题/困难”章节的内容
+func (m *RollupMember) addReport(report RollupReport) {
+	m.Reports = append(m.Reports, report)
+	inSection := false
+	for _, line := range strings.Split(report.Content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			inSection = blockerHeadingPattern.MatchString(trimmed)
+			continue
+		}
+		if !inSection || trimmed == "" || strings.HasPrefix(trimmed, "|") {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimLeft(trimmed, "-*+ "))
+		if text == "" || text == "无" {
+			continue
+		}
+		m.Blockers = append(m.Blockers, RollupBlocker{Source: "report", Description: fmt.Sprintf("%s（%s）", text, report.Period), MemberName: m.Name()})
+	}
+}
+
+// Member 按用户ID查找成员
+func (r *ReportRollup) Member(userID uint) *RollupMember {
+	for _, member := range r.Members {
+		if member.UserID == userID {
+			return member
+		}
+	}
+	return nil
+}
+
+// shiftHeadings 将报告内容中的 Markdown 标题降级，嵌入到汇总文档中
+func shiftHeadings(content string, levels int) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			depth := len(line) - len(strings.TrimLeft(line, "#"))
+			lines[i] = strings.Repeat("#", min(depth+levels, 6)) + line[depth:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", "\\|"), "\n", " ")
+}
+
+func writeRollupItems(b *strings.Builder, items []RollupItem) {
+	names := map[string]string{"task": "任务", "bug": "Bug", "requirement": "需求"}
+	b.WriteString("| 类型 | 标题 | 项目 | 成员 |\n| --- | --- | --- | --- |\n")
+	for _, item := range items {
+		fmt.Fprintf(b, "| %s | #%d %s | %s | %s |\n", names[item.Type], item.ID, markdownCell(item.Title), markdownCell(item.ProjectName), markdownCell(item.MemberName))
+	}
+	b.WriteString("\n")
+}
+
+// writeMemberMarkdown 成员明细，heading 为成员标题的级别
+func (m *RollupMember) writeMarkdown(b *strings.Builder, heading int) {
+	prefix := strings.Repeat("#", heading)
+	fmt.Fprintf(b, "%s %s\n\n", prefix, m.Name())
+	if m.DepartmentName != "" {
+		fmt.Fprintf(b, "- 部门：%s\n", m.DepartmentName)
+	}
+	fmt.Fprintf(b, "- 工时：%.2f 小时\n", m.Hours)
+	for _, item := range m.ProjectHours {
+		fmt.Fprintf(b, "  - %s：%.2f 小时\n", item.ProjectName, item.Hours)
+	}
+	fmt.Fprintf(b, "- 完成 %d 项，新建 %d 项，阻塞与风险 %d 项\n\n", len(m.Completed), len(m.Created), len(m.Blockers))
+
+	if len(m.Completed) > 0 {
+		fmt.Fprintf(b, "%s# 完成事项\n\n", prefix)
+		writeRollupItems(b, m.Completed)
+	}
+	if len(m.Created) > 0 {
+		fmt.Fprintf(b, "%s# 新建事项\n\n", prefix)
+		writeRollupItems(b, m.Created)
+	}
+	if len(m.Blockers) > 0 {
+		fmt.Fprintf(b, "%s# 阻塞与风险\n\n", prefix)
+		for _, blocker := range m.Blockers {
+			fmt.Fprintf(b, "- %s\n", blocker.Description)
+		}
+		b.WriteString("\n")
+	}
+	for _, report := range m.Reports {
+		name := "日报"
+		if report.Type == "weekly" {
+			name = "周报"
+		}
+		fmt.Fprintf(b, "%s# %s %s\n\n%s\n\n", prefix, name, report.Period, strings.TrimSpace(shiftHeadings(report.Content, heading+1)))
+	}
+}
+
+// Markdown 汇总为一个 Markdown 文档
+func (r *ReportRollup) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s 工作汇总（%s ~ %s）\n\n", r.ScopeName, r.StartDate, r.EndDate)
+	fmt.Fprintf(&b, "- 成员：%d 人\n- 已提交报告：%d 份\n- 总工时：%.2f 小时\n\n", len(r.Members), r.ReportCount, r.TotalHours)
+
+	if len(r.ProjectHours) > 0 {
+		b.WriteString("## 项目工时\n\n| 项目 | 工时(小时) |\n| --- | ---: |\n")
+		for _, item := range r.ProjectHours {
+			fmt.Fprintf(&b, "| %s | %.2f |\n", markdownCell(item.ProjectName), item.Hours)
+		}
+		b.WriteString("\n")
+	}
+
+	var completed, created []RollupItem
+	var blockers []RollupBlocker
+	for _, member := range r.Members {
+		completed = append(completed, member.Completed...)
+		created = append(created, member.Created...)
+		blockers = append(blockers, member.Blockers...)
+	}
+	if len(completed) > 0 {
+		b.WriteString("## 完成事项\n\n")
+		writeRollupItems(&b, completed)
+	}
+	if len(created) > 0 {
+		b.WriteString("## 新建事项\n\n")
+		writeRollupItems(&b, created)
+	}
+	if len(blockers) > 0 {
+		b.WriteString("## 阻塞与风险\n\n")
+		for _, blocker := range blockers {
+			if blocker.ProjectName != "" {
+				fmt.Fprintf(&b, "- [%s] %s（%s）\n", blocker.MemberName, blocker.Description, blocker.ProjectName)
+			} else {
+				fmt.Fprintf(&b, "- [%s] %s\n", blocker.MemberName, blocker.Description)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.Members) > 0 {
+		b.WriteString("## 成员明细\n\n")
+		for _, member := range r.Members {
+			member.writeMarkdown(&b, 3)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// MemberMarkdown 单个成员的明细文档
+func (r *ReportRollup) MemberMarkdown(member *RollupMember) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s 工作明细（%s ~ %s）\n\n", r.ScopeName, r.StartDate, r.EndDate)
+	member.writeMarkdown(&b, 2)
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// CSV 按成员导出汇总表
+func (r *ReportRollup) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\xEF\xBB\xBF") // UTF-8 BOM，便于 Excel 正确识别中文
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"成员", "用户名", "部门", "工时(小时)", "完成事项", "新建事项", "阻塞与风险", "已提交报告"})
+	for _, member := range r.Members {
+		w.Write([]string{
+			member.Name(), member.Username, member.DepartmentName, fmt.Sprintf("%.2f", member.Hours),
+			fmt.Sprintf("%d", len(member.Completed)), fmt.Sprintf("%d", len(member.Created)),
+			fmt.Sprintf("%d", len(member.Blockers)), fmt.Sprintf("%d", len(member.Reports)),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
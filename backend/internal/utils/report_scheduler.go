@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	reportScheduler     *ReportScheduler
+	reportSchedulerOnce sync.Once
+)
+
+// ReportScheduler 报告自动化定时任务调度器：
+// 工作日按配置时间生成日报草稿（周五同时生成周报草稿），并提醒未提交日报的用户
+type ReportScheduler struct {
+	db            *gorm.DB
+	draftTimer    *time.Timer
+	reminderTimer *time.Timer
+	mu            sync.Mutex
+}
+
+// GetReportScheduler 获取报告自动化调度器单例
+func GetReportScheduler(db *gorm.DB) *ReportScheduler {
+	reportSchedulerOnce.Do(func() {
+		reportScheduler = &ReportScheduler{db: db}
+	})
+	return reportScheduler
+}
+
+// Start 读取配置并调度下次执行
+func (s *ReportScheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopTimers()
+	cfg := LoadReportAutomationConfig(s.db)
+	if cfg.DraftEnabled {
+		s.draftTimer = s.scheduleAt("draft", cfg.DraftTime, s.runDraft)
+	}
+	if cfg.ReminderEnabled {
+		s.reminderTimer = s.scheduleAt("reminder", cfg.ReminderTime, s.runReminder)
+	}
+	if !cfg.DraftEnabled && !cfg.ReminderEnabled && Logger != nil {
+		Logger.Info("[Scheduler] Report automation is disabled")
+	}
+}
+
+// Stop 停止定时任务
+func (s *ReportScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopTimers()
+	if Logger != nil {
+		Logger.Info("[Scheduler] Report scheduler stopped")
+	}
+}
+
+// Reload 重新加载配置并重启定时任务
+func (s *ReportScheduler) Reload() {
+	if Logger != nil {
+		Logger.Info("[Scheduler] Reloading report scheduler configuration")
+	}
+	s.Start()
+}
+
+func (s *ReportScheduler) stopTimers() {
+	if s.draftTimer != nil {
+		s.draftTimer.Stop()
+		s.draftTimer = nil
+	}
+	if s.reminderTimer != nil {
+		s.reminderTimer.Stop()
+		s.reminderTimer = nil
+	}
+}
+
+// scheduleAt 在下一个 HH:MM 执行任务，执行后重新调度
+func (s *ReportScheduler) scheduleAt(name, clock string, job func(time.Time)) *time.Timer {
+	at, err := time.Parse("15:04", clock)
+	if err != nil {
+		if Logger != nil {
+			Logger.Warnf("[Scheduler] Invalid report %s time format: %s", name, clock)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	nextTime := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !nextTime.After(now) {
+		nextTime = nextTime.AddDate(0, 0, 1)
+	}
+	if Logger != nil {
+		Logger.Infof("[Scheduler] Next report %s scheduled at: %s", name, nextTime.Format("2006-01-02 15:04:05"))
+	}
+
+	return time.AfterFunc(time.Until(nextTime), func() {
+		if today := time.Now(); IsWorkday(today) {
+			job(today)
+		}
+		s.Start()
+	})
+}
+
+// runDraft 生成日报草稿，周五同时生成周报草稿
+func (s *ReportScheduler) runDraft(today time.Time) {
+	daily, err := DraftDailyReports(s.db, today)
+	if err != nil {
+		if Logger != nil {
+			Logger.Errorf("[Scheduler] Daily report drafting failed: %v", err)
+		}
+		return
+	}
+	weekly := 0
+	if today.Weekday() == time.Friday {
+		if weekly, err = DraftWeeklyReports(s.db, today); err != nil && Logger != nil {
+			Logger.Errorf("[Scheduler] Weekly report drafting failed: %v", err)
+		}
+	}
+	if Logger != nil {
+		Logger.Infof("[Scheduler] Report drafting completed: %d daily, %d weekly drafts created", daily, weekly)
+	}
+}
+
+// runReminder 提醒未提交日报的用户
+func (s *ReportScheduler) runReminder(today time.Time) {
+	count, err := RemindMissingDailyReports(s.db, today, LoadReportAutomationConfig(s.db).ReminderEmail)
+	if Logger == nil {
+		return
+	}
+	if err != nil {
+		Logger.Errorf("[Scheduler] Report reminder failed: %v", err)
+		return
+	}
+	Logger.Infof("[Scheduler] Report reminder completed: %d users reminded", count)
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"fmt"
+
+	"prjflow/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// restrictedItemTables 支持访问限制的对象类型及对应表名
+var restrictedItemTables = map[string]string{
+	"bug":         "bugs",
+	"requirement": "requirements",
+	"task":        "tasks",
+}
+
+// getUserRoles 获取当前用户的全局角色代码
+func getUserRoles(c *gin.Context) []string {
+	roles, exists := c.Get("roles")
+	if !exists {
+		return []string{}
+	}
+	roleList, ok := roles.([]string)
+	if !ok {
+		return []string{}
+	}
+	return roleList
+}
+
+// RestrictedVisibleCondition 受限对象的可见性SQL条件
+// 非受限对象对所有人可见；受限对象只对创建人、负责人（Bug为分配人）和访问名单中的用户或角色可见
+func RestrictedVisibleCondition(c *gin.Context, objectType string) (string, []interface{}) {
+	table := restrictedItemTables[objectType]
+	userID := GetUserID(c)
+
+	assigneeCond := table + ".assignee_id = ?"
+	if objectType == "bug" {
+		assigneeCond = "EXISTS (SELECT 1 FROM bug_assignees WHERE bug_assignees.bug_id = bugs.id AND bug_assignees.user_id = ?)"
+	}
+
+	// 访问名单：指定用户、成员在对象所属项目中的角色（按项目角色代码或名称匹配）、全局角色
+	accessCond := fmt.Sprintf(`EXISTS (SELECT 1 FROM item_accesses WHERE item_accesses.object_type = ? AND item_accesses.object_id = %[1]s.id AND (
+		item_accesses.user_id = ?
+		OR item_accesses.role_code <> '' AND EXISTS (SELECT 1 FROM project_members WHERE project_members.project_id = %[1]s.project_id AND project_members.user_id = ? AND project_members.deleted_at IS NULL
+			AND (project_members.role = item_accesses.role_code OR project_members.role IN (SELECT name FROM project_roles WHERE project_roles.code = item_accesses.role_code AND project_roles.deleted_at IS NULL)))
+		OR item_accesses.role_code IN ?))`, table)
+
+	cond := fmt.Sprintf("(%[1]s.confidential = ? OR %[1]s.creator_id = ? OR %[2]s OR %[3]s)", table, assigneeCond, accessCond)
+	args := []interface{}{false, userID, userID, objectType, userID, userID, getUserRoles(c)}
+	return cond, args
+}
+
+// FilterRestrictedItems 过滤当前用户不可见的受限对象（管理员可以看到所有对象）
+func FilterRestrictedItems(c *gin.Context, objectType string, query *gorm.DB) *gorm.DB {
+	if IsAdmin(c) {
+		return query
+	}
+	cond, args := RestrictedVisibleCondition(c, objectType)
+	return query.Where(cond, args...)
+}
+
+// FilterHiddenRestrictedItems 只保留当前用户不可见的受限对象
+// 用于替换关联关系时保留用户看不到的关联，避免被无意清除
+func FilterHiddenRestrictedItems(c *gin.Context, objectType string, query *gorm.DB) *gorm.DB {
+	if IsAdmin(c) {
+		return query.Where("1 = 0")
+	}
+	table := restrictedItemTables[objectType]
+	cond, args := RestrictedVisibleCondition(c, objectType)
+	return query.Where(table+".confidential = ? AND NOT "+cond, append([]interface{}{true}, args...)...)
+}
+
+// RestrictedPreload 预加载关联对象时过滤当前用户不可见的受限对象
+func RestrictedPreload(c *gin.Context, objectType string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return FilterRestrictedItems(c, objectType, db)
+	}
+}
+
+// CanViewRestrictedItem 检查当前用户是否可以查看对象（只判断访问限制，不判断项目成员身份）
+func CanViewRestrictedItem(db *gorm.DB, c *gin.Context, objectType string, objectID uint) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	table, ok := restrictedItemTables[objectType]
+	if !ok {
+		return true
+	}
+	var count int64
+	FilterRestrictedItems(c, objectType, db.Table(table).Where(table+".id = ? AND "+table+".deleted_at IS NULL", objectID)).Count(&count)
+	return count > 0
+}
+
+// IsRestrictedItem 判断对象是否受限
+func IsRestrictedItem(db *gorm.DB, objectType string, objectID uint) bool {
+	table, ok := restrictedItemTables[objectType]
+	if !ok {
+		return false
+	}
+	var count int64
+	db.Table(table).Where("id = ? AND confidential = ?", objectID, true).Count(&count)
+	return count > 0
+}
+
+// RecordRestrictedView 查看受限对象时记录审计日志
+func RecordRestrictedView(db *gorm.DB, c *gin.Context, objectType string, objectID uint, comment string) {
+	userID := GetUserID(c)
+	username, _ := c.Get("username")
+	name, _ := username.(string)
+	RecordAuditLog(db, userID, name, "view_restricted", objectType, objectID, c, true, "", comment)
+}
+
+// RecordRestrictedViewIfNeeded 对象受限时记录查看审计日志
+func RecordRestrictedViewIfNeeded(db *gorm.DB, c *gin.Context, objectType string, objectID uint, comment string) {
+	if IsRestrictedItem(db, objectType, objectID) {
+		RecordRestrictedView(db, c, objectType, objectID, comment)
+	}
+}
+
+// FilterAttachmentsByRestriction 过滤关联到当前用户不可见的受限对象的附件
+func FilterAttachmentsByRestriction(c *gin.Context, query *gorm.DB) *gorm.DB {
+	if IsAdmin(c) {
+		return query
+	}
+	for _, item := range []struct{ objectType, joinTable, column string }{
+		{"bug", "bug_attachments", "bug_id"},
+		{"requirement", "requirement_attachments", "requirement_id"},
+		{"task", "task_attachments", "task_id"},
+	} {
+		table := restrictedItemTables[item.objectType]
+		cond, args := RestrictedVisibleCondition(c, item.objectType)
+		sub := fmt.Sprintf("attachments.id NOT IN (SELECT %[1]s.attachment_id FROM %[1]s JOIN %[2]s ON %[2]s.id = %[1]s.%[3]s WHERE %[2]s.confidential = ? AND NOT %[4]s)",
+			item.joinTable, table, item.column, cond)
+		query = query.Where(sub, append([]interface{}{true}, args...)...)
+	}
+	return query
+}
+
+// CanViewAttachment 检查附件是否关联到当前用户不可见的受限对象
+func CanViewAttachment(db *gorm.DB, c *gin.Context, attachmentID uint) bool {
+	if IsAdmin(c) {
+		return true
+	}
+	var count int64
+	FilterAttachmentsByRestriction(c, db.Model(&model.Attachment{}).Where("attachments.id = ?", attachmentID)).Count(&count)
+	return count > 0
+}
+
+// RestrictedObject 受限对象引用
+type RestrictedObject struct {
+	ObjectType string
+	ObjectID   uint
+}
+
+// RestrictedAttachmentObjects 获取附件关联的受限对象（用于查看附件时记录审计日志）
+func RestrictedAttachmentObjects(db *gorm.DB, attachmentID uint) []RestrictedObject {
+	var objects []RestrictedObject
+	for _, item := range []struct{ objectType, joinTable, column string }{
+		{"bug", "bug_attachments", "bug_id"},
+		{"requirement", "requirement_attachments", "requirement_id"},
+		{"task", "task_attachments", "task_id"},
+	} {
+		table := restrictedItemTables[item.objectType]
+		var ids []uint
+		db.Table(item.joinTable).
+			Joins(fmt.Sprintf("JOIN %[1]s ON %[1]s.id = %[2]s.%[3]s", table, item.joinTable, item.column)).
+			Where(item.joinTable+".attachment_id = ? AND "+table+".confidential = ? AND "+table+".deleted_at IS NULL", attachmentID, true).
+			Pluck(item.joinTable+"."+item.column, &ids)
+		for _, id := range ids {
+			objects = append(objects, RestrictedObject{ObjectType: item.objectType, ObjectID: id})
+		}
+	}
+	return objects
+}
+
+// LoadItemAccesses 批量加载对象的访问名单，按对象ID分组（用于导出和克隆受限对象）
+func LoadItemAccesses(db *gorm.DB, objectType string, objectIDs []uint) (map[uint][]model.ItemAccess, error) {
+	result := make(map[uint][]model.ItemAccess)
+	if len(objectIDs) == 0 {
+		return result, nil
+	}
+	var accesses []model.ItemAccess
+	if err := db.Where("object_type = ? AND object_id IN ?", objectType, objectIDs).Order("id ASC").Find(&accesses).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range accesses {
+		result[a.ObjectID] = append(result[a.ObjectID], a)
+	}
+	return result, nil
+}
+
+// RestrictedItemAccess 受限对象的可见范围（创建人、负责人和访问名单），用于在内存中判断多个用户的可见性
+type RestrictedItemAccess struct {
+	CreatorID   uint
+	AssigneeIDs []uint
+	UserIDs     []uint   // 访问名单中的用户
+	RoleCodes   []string // 访问名单中的角色代码
+}
+
+// LoadRestrictedItemAccess 加载受限对象的可见范围，对象不受限或不存在时返回 nil
+func LoadRestrictedItemAccess(db *gorm.DB, objectType string, objectID uint) *RestrictedItemAccess {
+	table, ok := restrictedItemTables[objectType]
+	if !ok {
+		return nil
+	}
+	var item struct {
+		CreatorID  uint
+		AssigneeID *uint
+	}
+	columns := "creator_id"
+	if objectType != "bug" {
+		columns += ", assignee_id"
+	}
+	result := db.Table(table).Select(columns).
+		Where("id = ? AND confidential = ? AND deleted_at IS NULL", objectID, true).
+		Limit(1).Scan(&item)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil
+	}
+
+	access := &RestrictedItemAccess{CreatorID: item.CreatorID}
+	if objectType == "bug" {
+		db.Table("bug_assignees").Where("bug_id = ?", objectID).Pluck("user_id", &access.AssigneeIDs)
+	} else if item.AssigneeID != nil {
+		access.AssigneeIDs = []uint{*item.AssigneeID}
+	}
+	var entries []model.ItemAccess
+	db.Where("object_type = ? AND object_id = ?", objectType, objectID).Find(&entries)
+	for _, entry := range entries {
+		if entry.UserID != nil {
+			access.UserIDs = append(access.UserIDs, *entry.UserID)
+		}
+		if entry.RoleCode != "" {
+			access.RoleCodes = append(access.RoleCodes, entry.RoleCode)
+		}
+	}
+	return access
+}
+
+// RestrictedViewer 用户在某个项目中判断受限对象可见性所需的信息
+// 加载一次后可以对多个对象在内存中判断，项目成员或项目角色变更后需要重新加载
+type RestrictedViewer struct {
+	UserID    uint
+	Admin     bool
+	roleCodes map[string]bool // 全局角色代码和用户在项目中的角色可以匹配的角色代码
+}
+
+// LoadRestrictedViewer 加载当前用户在项目中的受限对象可见性信息（与 RestrictedVisibleCondition 的判断一致）
+func LoadRestrictedViewer(db *gorm.DB, c *gin.Context, projectID uint) *RestrictedViewer {
+	viewer := &RestrictedViewer{UserID: GetUserID(c), Admin: IsAdmin(c), roleCodes: map[string]bool{}}
+	for _, code := range getUserRoles(c) {
+		viewer.roleCodes[code] = true
+	}
+
+	// 项目角色按角色代码或名称匹配
+	var member model.ProjectMember
+	if db.Where("project_id = ? AND user_id = ?", projectID, viewer.UserID).First(&member).Error == nil && member.Role != "" {
+		viewer.roleCodes[member.Role] = true
+		var codes []string
+		db.Model(&model.ProjectRole{}).Where("name = ?", member.Role).Pluck("code", &codes)
+		for _, code := range codes {
+			viewer.roleCodes[code] = true
+		}
+	}
+	return viewer
+}
+
+// CanView 判断用户是否可以查看受限对象，access 为 nil 表示对象不受限
+func (v *RestrictedViewer) CanView(access *RestrictedItemAccess) bool {
+	if access == nil || v.Admin || access.CreatorID == v.UserID {
+		return true
+	}
+	for _, id := range access.AssigneeIDs {
+		if id == v.UserID {
+			return true
+		}
+	}
+	for _, id := range access.UserIDs {
+		if id == v.UserID {
+			return true
+		}
+	}
+	for _, code := range access.RoleCodes {
+		if v.roleCodes[code] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"gorm.io/gorm"
+
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/pkg/storage"
+)
+
+// 对象存储中附件和备份使用的键前缀
+const (
+	attachmentStoragePrefix = "attachments"
+	backupStoragePrefix     = "backups"
+)
+
+// NewStorage 按存储类型创建存储：local 使用 localRoot 目录，s3 使用 s3Prefix 前缀
+func NewStorage(storageType, localRoot, s3Prefix string) (storage.Storage, error) {
+	switch storageType {
+	case "", "local":
+		return storage.NewLocalStorage(localRoot), nil
+	case "s3":
+		s3, err := storage.NewS3Storage(config.AppConfig.Storage.S3)
+		if err != nil {
+			return nil, err
+		}
+		return s3.WithPrefix(s3Prefix), nil
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", storageType)
+	}
+}
+
+// storageType 当前配置的存储类型
+func storageType() string {
+	if config.AppConfig == nil || config.AppConfig.Storage.Type == "" {
+		return "local"
+	}
+	return config.AppConfig.Storage.Type
+}
+
+// AttachmentStorageOf 指定类型的附件存储（本地存储使用上传目录）
+func AttachmentStorageOf(storageType string) (storage.Storage, error) {
+	return NewStorage(storageType, UploadStoragePath(), attachmentStoragePrefix)
+}
+
+// BackupStorageOf 指定类型的备份存储（本地存储使用备份目录）
+func BackupStorageOf(storageType string) (storage.Storage, error) {
+	return NewStorage(storageType, BackupDir(), backupStoragePrefix)
+}
+
+// AttachmentStorage 当前配置的附件存储
+func AttachmentStorage() (storage.Storage, error) {
+	return AttachmentStorageOf(storageType())
+}
+
+// BackupStorage 当前配置的备份存储
+func BackupStorage() (storage.Storage, error) {
+	return BackupStorageOf(storageType())
+}
+
+// CheckStorage 启动时检查存储配置是否可用
+func CheckStorage() error {
+	s, err := AttachmentStorage()
+	if err != nil {
+		return err
+	}
+	if s.Type() == "local" {
+		return nil
+	}
+	// 读取一个不存在的对象，能区分配置错误（如密钥、存储桶错误）和正常的“不存在”
+	if _, err := s.Stat(".prjflow-storage-check"); err != nil && !errors.Is(err, storage.ErrNotExist) {
+		return fmt.Errorf("对象存储不可用: %w", err)
+	}
+	return nil
+}
+
+// PresignDownloadURL 生成附件的临时下载地址；存储不支持或未开启时返回空字符串
+func PresignDownloadURL(s storage.Storage, key, disposition string) string {
+	if config.AppConfig == nil || !config.AppConfig.Storage.PresignDownload {
+		return ""
+	}
+	expire := time.Duration(config.AppConfig.Storage.PresignExpire) * time.Second
+	if expire <= 0 {
+		expire = 5 * time.Minute
+	}
+	url, err := s.PresignGet(key, expire, disposition)
+	if err != nil {
+		return ""
+	}
+	return url
+}
+
+// ProjectStorageUsage 项目已使用的附件存储空间（字节）和附件数
+func ProjectStorageUsage(db *gorm.DB, projectID uint) (int64, int64, error) {
+	var result struct {
+		Size  int64
+		Count int64
+	}
+	err := db.Model(&model.Attachment{}).
+		Joins("JOIN project_attachments ON project_attachments.attachment_id = attachments.id").
+		Where("project_attachments.project_id = ?", projectID).
+		Select("COALESCE(SUM(attachments.file_size), 0) AS size, COUNT(*) AS count").
+		Scan(&result).Error
+	return result.Size, result.Count, err
+}
+
+// CheckProjectStorageQuota 检查项目增加 size 字节后是否超出存储配额（配额为 0 表示不限制）
+func CheckProjectStorageQuota(db *gorm.DB, project *model.Project, size int64) error {
+	if project.StorageQuota <= 0 {
+		return nil
+	}
+	used, _, err := ProjectStorageUsage(db, project.ID)
+	if err != nil {
+		return err
+	}
+	if used+size > project.StorageQuota {
+		return fmt.Errorf("超出项目存储配额（已使用 %s / 配额 %s）", formatSize(used), formatSize(project.StorageQuota))
+	}
+	return nil
+}
+
+// StorageMigrationResult 存储迁移结果
+type StorageMigrationResult struct {
+	Attachments int   `json:"attachments"` // 复制的附件文件数（包括历史版本和缩略图）
+	Backups     int   `json:"backups"`     // 复制的备份数
+	Skipped     int   `json:"skipped"`     // 目标存储中已存在（大小相同）而跳过的文件数
+	Missing     int   `json:"missing"`     // 源存储中不存在的附件数
+	Bytes       int64 `json:"bytes"`       // 复制的字节数
+}
+
+// MigrateStorage 将附件和备份从 from 存储复制到 to 存储（local 或 s3），对象键保持不变；
+// 目标中已存在且大小相同的文件跳过，可以重复执行；deleteSource 为 true 时复制成功后删除源文件
+func MigrateStorage(db *gorm.DB, from, to string, deleteSource bool) (*StorageMigrationResult, error) {
+	if from == to {
+		return nil, fmt.Errorf("源存储和目标存储相同: %s", from)
+	}
+	result := &StorageMigrationResult{}
+
+	srcAttachments, err := AttachmentStorageOf(from)
+	if err != nil {
+		return nil, err
+	}
+	dstAttachments, err := AttachmentStorageOf(to)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := attachmentObjects(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		copied, err := migrateObject(srcAttachments, dstAttachments, object.key, object.contentType, deleteSource, result)
+		if errors.Is(err, storage.ErrNotExist) {
+			result.Missing++
+			if Logger != nil {
+				Logger.Warnf("[Storage] %s file missing: %s", object.owner, object.key)
+			}
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("迁移%s失败: %w", object.owner, err)
+		}
+		if copied {
+			result.Attachments++
+		}
+	}
+
+	srcBackups, err := BackupStorageOf(from)
+	if err != nil {
+		return result, err
+	}
+	dstBackups, err := BackupStorageOf(to)
+	if err != nil {
+		return result, err
+	}
+	var backups []string
+	if err := srcBackups.List("", func(object storage.ObjectInfo) error {
+		if ValidBackupName(object.Key) {
+			backups = append(backups, object.Key)
+		}
+		return nil
+	}); err != nil {
+		return result, err
+	}
+	for _, name := range backups {
+		copied, err := migrateObject(srcBackups, dstBackups, name, "application/zip", deleteSource, result)
+		if err != nil {
+			return result, fmt.Errorf("迁移备份 %s 失败: %w", name, err)
+		}
+		if copied {
+			result.Backups++
+		}
+	}
+	return result, nil
+}
+
+// attachmentObject 附件存储中的一个对象
+type attachmentObject struct {
+	key         string
+	contentType string
+	owner       string // 日志和错误信息中的来源说明
+}
+
+// attachmentObjects 列出附件存储中需要迁移的对象：附件、附件历史版本和图片缩略图（相同内容共用的对象只列出一次）
+func attachmentObjects(db *gorm.DB) ([]attachmentObject, error) {
+	var objects []attachmentObject
+	seen := make(map[string]bool)
+	add := func(key, contentType, owner string) {
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		objects = append(objects, attachmentObject{key: key, contentType: contentType, owner: owner})
+	}
+
+	var attachments []model.Attachment
+	if err := db.Unscoped().Select("id", "file_path", "mime_type").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		add(attachment.FilePath, attachment.MimeType, fmt.Sprintf("附件 %d", attachment.ID))
+	}
+
+	var versions []model.AttachmentVersion
+	if err := db.Select("id", "attachment_id", "version", "file_path", "mime_type").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		add(version.FilePath, version.MimeType, fmt.Sprintf("附件 %d 版本 %d", version.AttachmentID, version.Version))
+	}
+
+	var blobs []model.AttachmentBlob
+	if err := db.Where("thumbnail_path <> ''").Select("id", "hash", "thumbnail_path").Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	for _, blob := range blobs {
+		add(blob.ThumbnailPath, ThumbnailContentType(path.Ext(blob.ThumbnailPath)), "缩略图 "+blob.Hash)
+	}
+	return objects, nil
+}
+
+// migrateObject 复制一个对象，返回是否实际复制（目标已存在时跳过）
+func migrateObject(src, dst storage.Storage, key, contentType string, deleteSource bool, result *StorageMigrationResult) (bool, error) {
+	srcInfo, err := src.Stat(key)
+	if err != nil {
+		return false, err
+	}
+	copied := false
+	if dstInfo, err := dst.Stat(key); err == nil && dstInfo.Size == srcInfo.Size {
+		result.Skipped++
+	} else {
+		reader, info, err := src.Get(key)
+		if err != nil {
+			return false, err
+		}
+		err = dst.Put(key, reader, info.Size, contentType)
+		reader.Close()
+		if err != nil {
+			return false, err
+		}
+		result.Bytes += info.Size
+		copied = true
+	}
+	if deleteSource {
+		if err := src.Delete(key); err != nil {
+			return copied, err
+		}
+	}
+	return copied, nil
+}
@@ -0,0 +1,329 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 测试报告格式
+const (
+	TestReportJUnit = "junit"
+	TestReportXUnit = "xunit"
+	TestReportTAP   = "tap"
+)
+
+// TestReportCase 测试报告中的一条测试结果
+type TestReportCase struct {
+	Key      string  // 外部标识（JUnit 为 classname.name，xUnit 为测试全名，TAP 为测试描述）
+	Name     string  // 测试名称
+	Suite    string  // 所属测试集
+	Result   string  // 执行结果：pass, fail, skip
+	Message  string  // 失败或跳过的原因
+	Duration float64 // 耗时（秒）
+}
+
+// ParseTestReport 解析测试报告，format 为空时根据内容自动识别格式
+// 同一测试在报告中出现多次时合并为一条，结果取最差的一次（fail > pass > skip）
+func ParseTestReport(format string, data []byte) ([]TestReportCase, string, error) {
+	if format == "" {
+		format = DetectTestReportFormat(data)
+	}
+
+	var cases []TestReportCase
+	var err error
+	switch format {
+	case TestReportJUnit:
+		cases, err = parseJUnitReport(data)
+	case TestReportXUnit:
+		cases, err = parseXUnitReport(data)
+	case TestReportTAP:
+		cases, err = parseTAPReport(data)
+	default:
+		return nil, format, fmt.Errorf("不支持的测试报告格式：%s，有效值：junit, xunit, tap", format)
+	}
+	if err != nil {
+		return nil, format, err
+	}
+	return mergeTestReportCases(cases), format, nil
+}
+
+// DetectTestReportFormat 根据内容识别测试报告格式
+func DetectTestReportFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		return TestReportTAP
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return TestReportJUnit
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "assemblies", "assembly":
+				return TestReportXUnit
+			default:
+				return TestReportJUnit
+			}
+		}
+	}
+}
+
+// mergeTestReportCases 按外部标识合并重复的测试结果
+func mergeTestReportCases(cases []TestReportCase) []TestReportCase {
+	rank := map[string]int{"skip": 0, "pass": 1, "fail": 2}
+	index := make(map[string]int, len(cases))
+	merged := make([]TestReportCase, 0, len(cases))
+	for _, tc := range cases {
+		if i, ok := index[tc.Key]; ok {
+			merged[i].Duration += tc.Duration
+			if rank[tc.Result] > rank[merged[i].Result] {
+				merged[i].Result = tc.Result
+				merged[i].Message = tc.Message
+			}
+			continue
+		}
+		index[tc.Key] = len(merged)
+		merged = append(merged, tc)
+	}
+	return merged
+}
+
+// junitSuite JUnit 报告中的测试集（testsuite 可以嵌套）
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []struct {
+		Name      string  `xml:"name,attr"`
+		ClassName string  `xml:"classname,attr"`
+		Time      float64 `xml:"time,attr"`
+		Failure   *struct {
+			Message string `xml:"message,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:"failure"`
+		Error *struct {
+			Message string `xml:"message,attr"`
+			Text    string `xml:",chardata"`
+		} `xml:"error"`
+		Skipped *struct {
+			Message string `xml:"message,attr"`
+		} `xml:"skipped"`
+	} `xml:"testcase"`
+}
+
+// parseJUnitReport 解析 JUnit XML（根节点为 testsuites 或 testsuite）
+func parseJUnitReport(data []byte) ([]TestReportCase, error) {
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("JUnit 报告解析失败: %w", err)
+	}
+	if root.XMLName.Local != "testsuites" && root.XMLName.Local != "testsuite" {
+		return nil, fmt.Errorf("JUnit 报告解析失败: 根节点应为 testsuites 或 testsuite")
+	}
+
+	var cases []TestReportCase
+	var walk func(suite *junitSuite)
+	walk = func(suite *junitSuite) {
+		for _, tc := range suite.Cases {
+			key := tc.Name
+			if tc.ClassName != "" {
+				key = tc.ClassName + "." + tc.Name
+			}
+			result := TestReportCase{Key: key, Name: tc.Name, Suite: suite.Name, Result: "pass", Duration: tc.Time}
+			switch {
+			case tc.Failure != nil:
+				result.Result = "fail"
+				result.Message = failureMessage(tc.Failure.Message, tc.Failure.Text)
+			case tc.Error != nil:
+				result.Result = "fail"
+				result.Message = failureMessage(tc.Error.Message, tc.Error.Text)
+			case tc.Skipped != nil:
+				result.Result = "skip"
+				result.Message = tc.Skipped.Message
+			}
+			cases = append(cases, result)
+		}
+		for i := range suite.Suites {
+			walk(&suite.Suites[i])
+		}
+	}
+	if root.XMLName.Local == "testsuite" {
+		walk(&root.junitSuite)
+	} else {
+		for i := range root.Suites {
+			walk(&root.Suites[i])
+		}
+	}
+	return cases, nil
+}
+
+// parseXUnitReport 解析 xUnit.net v2 XML（根节点为 assemblies 或 assembly）
+func parseXUnitReport(data []byte) ([]TestReportCase, error) {
+	type xunitTest struct {
+		Name    string  `xml:"name,attr"`
+		Type    string  `xml:"type,attr"`
+		Method  string  `xml:"method,attr"`
+		Result  string  `xml:"result,attr"`
+		Time    float64 `xml:"time,attr"`
+		Reason  string  `xml:"reason"`
+		Failure *struct {
+			Message    string `xml:"message"`
+			StackTrace string `xml:"stack-trace"`
+		} `xml:"failure"`
+	}
+	type xunitAssembly struct {
+		Name        string `xml:"name,attr"`
+		Collections []struct {
+			Name  string      `xml:"name,attr"`
+			Tests []xunitTest `xml:"test"`
+		} `xml:"collection"`
+	}
+	var root struct {
+		XMLName    xml.Name
+		Assemblies []xunitAssembly `xml:"assembly"`
+		xunitAssembly
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("xUnit 报告解析失败: %w", err)
+	}
+	assemblies := root.Assemblies
+	if root.XMLName.Local == "assembly" {
+		assemblies = []xunitAssembly{root.xunitAssembly}
+	}
+
+	var cases []TestReportCase
+	for _, assembly := range assemblies {
+		for _, collection := range assembly.Collections {
+			for _, test := range collection.Tests {
+				name := test.Method
+				if name == "" {
+					name = test.Name
+				}
+				result := TestReportCase{Key: test.Name, Name: name, Suite: collection.Name, Duration: test.Time}
+				switch strings.ToLower(test.Result) {
+				case "pass":
+					result.Result = "pass"
+				case "fail":
+					result.Result = "fail"
+					if test.Failure != nil {
+						result.Message = failureMessage(test.Failure.Message, test.Failure.StackTrace)
+					}
+				default:
+					result.Result = "skip"
+					result.Message = strings.TrimSpace(test.Reason)
+				}
+				cases = append(cases, result)
+			}
+		}
+	}
+	return cases, nil
+}
+
+var (
+	tapTestLine  = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*(?:-\s*)?(.*)$`)
+	tapDirective = regexp.MustCompile(`(?i)\s+#\s*(skip|todo)\b\s*(.*)$`)
+)
+
+// parseTAPReport 解析 TAP（Test Anything Protocol）输出，支持 YAML 诊断块中的 message
+func parseTAPReport(data []byte) ([]TestReportCase, error) {
+	var cases []TestReportCase
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	inYAML := false
+	var yaml []string
+
+	flushYAML := func() {
+		if len(cases) == 0 || len(yaml) == 0 {
+			return
+		}
+		last := &cases[len(cases)-1]
+		for _, line := range yaml {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "message:") {
+				last.Message = strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "message:")), `"'`)
+				return
+			}
+		}
+		if last.Message == "" {
+			last.Message = strings.Join(yaml, "\n")
+		}
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if inYAML {
+			if trimmed == "..." {
+				flushYAML()
+				inYAML = false
+				yaml = nil
+			} else {
+				yaml = append(yaml, line)
+			}
+			continue
+		}
+		if trimmed == "---" && len(cases) > 0 {
+			inYAML = true
+			continue
+		}
+		if strings.HasPrefix(trimmed, "Bail out!") {
+			return nil, fmt.Errorf("TAP 报告中止: %s", strings.TrimSpace(strings.TrimPrefix(trimmed, "Bail out!")))
+		}
+		// 子测试（缩进）只统计顶层结果
+		if line != strings.TrimLeft(line, " \t") {
+			continue
+		}
+		matches := tapTestLine.FindStringSubmatch(trimmed)
+		if matches == nil {
+			continue
+		}
+
+		description := matches[3]
+		result := "pass"
+		if matches[1] == "not ok" {
+			result = "fail"
+		}
+		message := ""
+		if directive := tapDirective.FindStringSubmatch(description); directive != nil {
+			description = strings.TrimSpace(description[:len(description)-len(directive[0])])
+			result = "skip"
+			message = strings.TrimSpace(directive[2])
+		}
+		description = strings.TrimSpace(description)
+		if description == "" {
+			number, _ := strconv.Atoi(matches[2])
+			description = fmt.Sprintf("test %d", number)
+		}
+		cases = append(cases, TestReportCase{Key: description, Name: description, Result: result, Message: message})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("TAP 报告解析失败: %w", err)
+	}
+	if inYAML {
+		flushYAML()
+	}
+	return cases, nil
+}
+
+// failureMessage 合并失败信息和详细内容
+func failureMessage(message, detail string) string {
+	message = strings.TrimSpace(message)
+	detail = strings.TrimSpace(detail)
+	switch {
+	case detail == "":
+		return message
+	case message == "" || strings.Contains(detail, message):
+		return detail
+	default:
+		return message + "\n" + detail
+	}
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // 注册 GIF 解码器
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// ThumbnailMaxSize 缩略图的最大宽度和高度（像素）
+const ThumbnailMaxSize = 320
+
+// thumbnailMaxPixels 生成缩略图的原图最大像素数（避免解码超大图片占用过多内存）
+const thumbnailMaxPixels = 50 * 1000 * 1000
+
+// CanMakeThumbnail 是否支持为该类型的文件生成缩略图
+func CanMakeThumbnail(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/jpg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// ThumbnailContentType 缩略图的MIME类型
+func ThumbnailContentType(ext string) string {
+	if ext == ".png" {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// MakeThumbnail 生成等比缩小的缩略图（不放大小图）：PNG 和 GIF 输出 PNG 保留透明度，其他输出 JPEG
+// 返回缩略图内容和扩展名
+func MakeThumbnail(src io.ReadSeeker) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("无法识别的图片: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > thumbnailMaxPixels {
+		return nil, "", fmt.Errorf("图片尺寸 %dx%d 超出限制", cfg.Width, cfg.Height)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return nil, "", fmt.Errorf("解码图片失败: %w", err)
+	}
+
+	thumbnail := scaleImage(img, ThumbnailMaxSize)
+	var buf bytes.Buffer
+	if format == "png" || format == "gif" {
+		err = png.Encode(&buf, thumbnail)
+		return buf.Bytes(), ".png", err
+	}
+	err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 80})
+	return buf.Bytes(), ".jpg", err
+}
+
+// scaleImage 等比缩小到不超过 maxSize，每个目标像素取原图对应区域内最多 4x4 个采样点的平均值
+func scaleImage(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > maxSize || srcH > maxSize {
+		if srcW >= srcH {
+			dstW, dstH = maxSize, srcH*maxSize/srcW
+		} else {
+			dstW, dstH = srcW*maxSize/srcH, maxSize
+		}
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0, y1 := bounds.Min.Y+y*srcH/dstH, bounds.Min.Y+(y+1)*srcH/dstH
+		for x := 0; x < dstW; x++ {
+			x0, x1 := bounds.Min.X+x*srcW/dstW, bounds.Min.X+(x+1)*srcW/dstW
+			dst.SetNRGBA(x, y, averageColor(src, x0, y0, x1, y1))
+		}
+	}
+	return dst
+}
+
+// averageColor 区域 [x0,x1)x[y0,y1) 内采样点的平均颜色
+func averageColor(src image.Image, x0, y0, x1, y1 int) color.NRGBA {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	stepX, stepY := (x1-x0+3)/4, (y1-y0+3)/4
+	var r, g, b, a, n uint32
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			pr, pg, pb, pa := src.At(x, y).RGBA()
+			r, g, b, a, n = r+pr, g+pg, b+pb, a+pa, n+1
+		}
+	}
+	r, g, b, a = r/n, g/n, b/n, a/n
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	// RGBA() 返回预乘透明度的 16 位分量，转换为非预乘的 8 位分量
+	return color.NRGBA{
+		R: uint8((r * 0xffff / a) >> 8),
+		G: uint8((g * 0xffff / a) >> 8),
+		B: uint8((b * 0xffff / a) >> 8),
+		A: uint8(a >> 8),
+	}
+}
@@ -0,0 +1,297 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"prjflow/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// SummarizeWorkContent 汇总用户的工作内容（资源分配工时、创建的Bug和完成的任务）
+// userID: 用户ID
+// startDate: 开始日期
+// endDate: 结束日期（对于日报，startDate和endDate相同）
+// 返回：Markdown格式的工作内容摘要和总工时
+func SummarizeWorkContent(db *gorm.DB, userID uint, startDate, endDate time.Time) (string, float64) {
+	// 使用 JOIN 查询，直接通过 user_id 查询资源分配记录
+	// 这样可以确保查询到所有相关的资源分配记录，即使没有Resource记录也能查询到
+	var allocations []model.ResourceAllocation
+
+	// 将日期转换为只包含日期的格式（去掉时间部分）
+	// date字段是date类型，直接比较日期部分即可
+	// 注意：使用与工作台相同的日期范围逻辑：date >= startDate AND date < endDate+1
+	startDateOnly := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+	endDateOnly := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, endDate.Location())
+	// 结束日期加1天，使用 < 比较（与工作台逻辑一致）
+	endDateExclusive := endDateOnly.AddDate(0, 0, 1)
+
+	// 使用 JOIN 查询，通过 resources 表关联 user_id
+	// GORM 会自动处理软删除（deleted_at IS NULL）
+	// 注意：如果用户没有 Resource 记录，JOIN 查询可能返回空结果，这是正常的
+	err := db.Model(&model.ResourceAllocation{}).
+		Joins("JOIN resources ON resource_allocations.resource_id = resources.id").
+		Where("resources.user_id = ?", userID).
+		Where("resource_allocations.date >= ? AND resource_allocations.date < ?", startDateOnly, endDateExclusive).
+		Preload("Task").Preload("Bug").Preload("Requirement").Preload("Project").
+		Find(&allocations).Error
+
+	// 如果查询出错，返回空内容（不返回错误信息，避免前端显示错误）
+	if err != nil {
+		// 记录错误但不返回给前端，避免影响用户体验
+		// log.Printf("汇总工作内容查询失败: userID=%d, startDate=%s, endDate=%s, error=%v",
+		// 	userID, startDateOnly.Format("2006-01-02"), endDateOnly.Format("2006-01-02"), err)
+		// 即使资源分配查询失败，也继续查询创建的bug
+		allocations = []model.ResourceAllocation{}
+	}
+
+	// 查询用户在指定日期范围内创建的Bug（即使没有资源分配记录）
+	// 使用时间范围比较：created_at >= startDateOnly AND created_at < endDateExclusive
+	// 与资源分配的日期范围逻辑保持一致
+	var createdBugs []model.Bug
+	bugErr := db.Where("creator_id = ?", userID).
+		Where("created_at >= ? AND created_at < ?", startDateOnly, endDateExclusive).
+		Preload("Project").
+		Find(&createdBugs).Error
+	if bugErr != nil {
+		// 查询失败不影响其他汇总，继续处理
+		createdBugs = []model.Bug{}
+	}
+
+	// 查询用户在指定日期范围内完成的任务（即使没有资源分配记录）
+	// 使用时间范围比较：updated_at >= startDateOnly AND updated_at < endDateExclusive AND status = 'done'
+	// 与资源分配的日期范围逻辑保持一致
+	var completedTasks []model.Task
+	taskErr := db.Where("assignee_id = ? AND status = ?", userID, "done").
+		Where("updated_at >= ? AND updated_at < ?", startDateOnly, endDateExclusive).
+		Preload("Project").
+		Find(&completedTasks).Error
+	if taskErr != nil {
+		// 查询失败不影响其他汇总，继续处理
+		completedTasks = []model.Task{}
+	}
+
+	// 查询用户在指定日期范围内解决的Bug（通过Action表）
+	// 因为Bug解决后会自动指派回创建者，所以需要通过Action表找到真正的解决者
+	var resolvedBugActions []model.Action
+	actionErr := db.Where("object_type = ? AND action IN ? AND actor_id = ?", "bug", []string{"resolved", "closed"}, userID).
+		Where("date >= ? AND date < ?", startDateOnly, endDateExclusive).
+		Find(&resolvedBugActions).Error
+	if actionErr != nil {
+		// 查询失败不影响其他汇总，继续处理
+		resolvedBugActions = []model.Action{}
+	}
+
+	// 获取解决的Bug ID列表
+	var resolvedBugIDs []uint
+	for _, action := range resolvedBugActions {
+		resolvedBugIDs = append(resolvedBugIDs, action.ObjectID)
+	}
+
+	// 查询解决的Bug详情
+	var resolvedBugs []model.Bug
+	if len(resolvedBugIDs) > 0 {
+		bugQueryErr := db.Where("id IN ?", resolvedBugIDs).
+			Preload("Project").
+			Find(&resolvedBugs).Error
+		if bugQueryErr != nil {
+			// 查询失败不影响其他汇总，继续处理
+			resolvedBugs = []model.Bug{}
+		}
+	}
+
+	// 如果既没有资源分配记录，也没有创建的bug，也没有完成的任务，也没有解决的bug，返回空内容
+	if len(allocations) == 0 && len(createdBugs) == 0 && len(completedTasks) == 0 && len(resolvedBugs) == 0 {
+		return "暂无工作记录", 0
+	}
+
+	// 按工作类型分组汇总
+	type WorkItem struct {
+		ID          uint
+		Title       string
+		ProjectName string
+		Hours       float64
+	}
+
+	var requirements []WorkItem
+	var tasks []WorkItem
+	var bugs []WorkItem
+	var totalHours float64
+
+	for _, alloc := range allocations {
+		totalHours += alloc.Hours
+
+		projectName := "未知项目"
+		if alloc.Project != nil {
+			projectName = alloc.Project.Name
+		}
+
+		if alloc.RequirementID != nil && alloc.Requirement != nil {
+			requirements = append(requirements, WorkItem{
+				ID:          *alloc.RequirementID,
+				Title:       alloc.Requirement.Title,
+				ProjectName: projectName,
+				Hours:       alloc.Hours,
+			})
+		} else if alloc.TaskID != nil && alloc.Task != nil {
+			tasks = append(tasks, WorkItem{
+				ID:          *alloc.TaskID,
+				Title:       alloc.Task.Title,
+				ProjectName: projectName,
+				Hours:       alloc.Hours,
+			})
+		} else if alloc.BugID != nil && alloc.Bug != nil {
+			bugs = append(bugs, WorkItem{
+				ID:          *alloc.BugID,
+				Title:       alloc.Bug.Title,
+				ProjectName: projectName,
+				Hours:       alloc.Hours,
+			})
+		}
+	}
+
+	// 添加用户创建的Bug（去重，避免与资源分配中的Bug重复）
+	bugIDMap := make(map[uint]bool)
+	for _, bug := range bugs {
+		bugIDMap[bug.ID] = true
+	}
+
+	for _, bug := range createdBugs {
+		// 如果这个bug已经在资源分配中，跳过（避免重复）
+		if bugIDMap[bug.ID] {
+			continue
+		}
+
+		projectName := "未知项目"
+		if bug.Project.ID > 0 {
+			projectName = bug.Project.Name
+		}
+
+		bugs = append(bugs, WorkItem{
+			ID:          bug.ID,
+			Title:       bug.Title,
+			ProjectName: projectName,
+			Hours:       0, // 创建的bug如果没有资源分配，工时为0
+		})
+		bugIDMap[bug.ID] = true // 更新map，用于后续去重
+	}
+
+	// 添加用户解决的Bug（去重，避免与资源分配和创建的Bug重复）
+	for _, bug := range resolvedBugs {
+		// 如果这个bug已经在资源分配或创建的bug中，跳过（避免重复）
+		if bugIDMap[bug.ID] {
+			continue
+		}
+
+		projectName := "未知项目"
+		if bug.Project.ID > 0 {
+			projectName = bug.Project.Name
+		}
+
+		// 如果Bug有实际工时，使用实际工时；否则使用0
+		hours := 0.0
+		if bug.ActualHours != nil {
+			hours = *bug.ActualHours
+		}
+
+		bugs = append(bugs, WorkItem{
+			ID:          bug.ID,
+			Title:       bug.Title,
+			ProjectName: projectName,
+			Hours:       hours,
+		})
+		// 如果Bug有实际工时，也需要加到总工时中
+		if hours > 0 {
+			totalHours += hours
+		}
+	}
+
+	// 添加用户完成的任务（去重，避免与资源分配中的任务重复）
+	taskIDMap := make(map[uint]bool)
+	for _, task := range tasks {
+		taskIDMap[task.ID] = true
+	}
+
+	for _, task := range completedTasks {
+		// 如果这个任务已经在资源分配中，跳过（避免重复）
+		if taskIDMap[task.ID] {
+			continue
+		}
+
+		projectName := "未知项目"
+		if task.Project.ID > 0 {
+			projectName = task.Project.Name
+		}
+
+		// 如果任务有实际工时，使用实际工时；否则使用0
+		hours := 0.0
+		if task.ActualHours != nil {
+			hours = *task.ActualHours
+		}
+
+		tasks = append(tasks, WorkItem{
+			ID:          task.ID,
+			Title:       task.Title,
+			ProjectName: projectName,
+			Hours:       hours,
+		})
+		// 如果任务有实际工时，也需要加到总工时中
+		if hours > 0 {
+			totalHours += hours
+		}
+	}
+
+	// 生成Markdown格式的工作内容摘要（使用表格格式）
+	content := ""
+
+	if len(requirements) > 0 {
+		content += "## 需求\n\n"
+		content += "| 标题 | 项目 | 工时(小时) |\n"
+		content += "| --- | --- | ---: |\n"
+		var reqHours float64
+		for _, req := range requirements {
+			content += fmt.Sprintf("| %s | %s | %.2f |\n", req.Title, req.ProjectName, req.Hours)
+			reqHours += req.Hours
+		}
+		content += fmt.Sprintf("| **合计** | | **%.2f** |\n\n", reqHours)
+	}
+
+	if len(tasks) > 0 {
+		content += "## 任务\n\n"
+		content += "| 标题 | 项目 | 工时(小时) |\n"
+		content += "| --- | --- | ---: |\n"
+		var taskHours float64
+		for _, task := range tasks {
+			content += fmt.Sprintf("| %s | %s | %.2f |\n", task.Title, task.ProjectName, task.Hours)
+			taskHours += task.Hours
+		}
+		content += fmt.Sprintf("| **合计** | | **%.2f** |\n\n", taskHours)
+	}
+
+	if len(bugs) > 0 {
+		content += "## Bug\n\n"
+		content += "| 标题 | 项目 | 工时(小时) |\n"
+		content += "| --- | --- | ---: |\n"
+		var bugHours float64
+		for _, bug := range bugs {
+			content += fmt.Sprintf("| %s | %s | %.2f |\n", bug.Title, bug.ProjectName, bug.Hours)
+			bugHours += bug.Hours
+		}
+		content += fmt.Sprintf("| **合计** | | **%.2f** |\n\n", bugHours)
+	}
+
+	content += fmt.Sprintf("**总工时**: %.2f小时\n\n", totalHours)
+
+	// 添加工作计划表格模板（日报显示"明日工作计划"，周报显示"下周工作计划"）
+	planTitle := "明日工作计划"
+	if startDate != endDate {
+		planTitle = "下周工作计划"
+	}
+	content += fmt.Sprintf("## %s\n\n", planTitle)
+	content += "| 工作内容 | 预计工时(小时) |\n"
+	content += "| --- | ---: |\n"
+	content += "| | |\n"
+
+	return content, totalHours
+}
@@ -0,0 +1,200 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"prjflow/internal/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// 写入超时时间
+	writeWait = 10 * time.Second
+	// 等待客户端pong的超时时间
+	pongWait = 60 * time.Second
+	// 发送ping的间隔，必须小于pongWait
+	pingPeriod = (pongWait * 9) / 10
+)
+
+// BoardTopic 看板订阅主题
+func BoardTopic(boardID uint) string {
+	return fmt.Sprintf("board:%d", boardID)
+}
+
+// ProjectTopic 项目订阅主题（项目下所有看板的卡片变更）
+func ProjectTopic(projectID uint) string {
+	return fmt.Sprintf("project:%d", projectID)
+}
+
+// BoardEvent 看板卡片变更事件
+type BoardEvent struct {
+	Event      string `json:"event"`              // 事件类型：card.created, card.updated, card.moved, card.deleted
+	BoardID    uint   `json:"board_id,omitempty"` // 看板ID（项目主题的事件为空）
+	ProjectID  uint   `json:"project_id"`
+	ObjectType string `json:"object_type"` // 卡片类型：task, bug, requirement
+	ObjectID   uint   `json:"object_id"`
+	Status     string `json:"status,omitempty"`
+	ColumnID   uint   `json:"column_id,omitempty"` // 卡片所在的列（仅看板主题）
+	Rank       string `json:"rank,omitempty"`      // 卡片排序键（仅看板主题）
+	Version    int    `json:"version"`             // 卡片版本号
+	ActorID    uint   `json:"actor_id"`            // 操作人
+
+	// Restricted 受限对象的事件只发送给可以查看该对象的订阅者
+	Restricted bool `json:"-"`
+	// Access 受限对象的可见范围，广播时加载一次，订阅者在内存中判断是否可见
+	Access *utils.RestrictedItemAccess `json:"-"`
+}
+
+// Subscriber 看板订阅连接
+type Subscriber struct {
+	ws     *websocket.Conn
+	send   chan []byte
+	topics []string
+	// UserID 订阅用户
+	UserID uint
+	// canView 判断订阅用户是否可以查看受限对象的事件
+	canView func(event *BoardEvent) bool
+}
+
+// Subscribe 注册看板订阅连接，canView 为空表示接收所有事件
+func (h *Hub) Subscribe(conn *websocket.Conn, userID uint, topics []string, canView func(event *BoardEvent) bool) *Subscriber {
+	sub := &Subscriber{
+		ws:      conn,
+		send:    make(chan []byte, 256),
+		topics:  topics,
+		UserID:  userID,
+		canView: canView,
+	}
+
+	h.mu.Lock()
+	for _, topic := range topics {
+		if h.subscribers[topic] == nil {
+			h.subscribers[topic] = make(map[*Subscriber]bool)
+		}
+		h.subscribers[topic][sub] = true
+	}
+	h.mu.Unlock()
+
+	go sub.writePump()
+	go sub.readPump()
+
+	if utils.Logger != nil {
+		utils.Logger.Infof("看板订阅已注册: user=%d, topics=%v", userID, topics)
+	}
+	return sub
+}
+
+// Unsubscribe 注销看板订阅连接
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	removed := false
+	for _, topic := range sub.topics {
+		if subs, ok := h.subscribers[topic]; ok && subs[sub] {
+			delete(subs, sub)
+			if len(subs) == 0 {
+				delete(h.subscribers, topic)
+			}
+			removed = true
+		}
+	}
+	if removed {
+		close(sub.send)
+	}
+}
+
+// SubscriberCount 获取主题的订阅连接数
+func (h *Hub) SubscriberCount(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers[topic])
+}
+
+// Publish 向订阅主题的所有连接广播看板事件
+func (h *Hub) Publish(topic string, event *BoardEvent) {
+	h.mu.RLock()
+	subs := make([]*Subscriber, 0, len(h.subscribers[topic]))
+	for sub := range h.subscribers[topic] {
+		subs = append(subs, sub)
+	}
+	h.mu.RUnlock()
+
+	if len(subs) == 0 {
+		return
+	}
+
+	msgBytes, err := json.Marshal(Message{Type: "board", Data: event})
+	if err != nil {
+		return
+	}
+
+	for _, sub := range subs {
+		if event.Restricted && sub.canView != nil && !sub.canView(event) {
+			continue
+		}
+		select {
+		case sub.send <- msgBytes:
+		default:
+			// 发送缓冲区已满，客户端处理过慢，断开连接（客户端重连后重新加载看板）
+			h.Unsubscribe(sub)
+		}
+	}
+}
+
+// readPump 读取客户端消息（只处理控制消息，用于检测连接断开）
+func (s *Subscriber) readPump() {
+	defer func() {
+		GetHub().Unsubscribe(s)
+		s.ws.Close()
+	}()
+
+	s.ws.SetReadLimit(512)
+	s.ws.SetReadDeadline(time.Now().Add(pongWait))
+	s.ws.SetPongHandler(func(string) error {
+		return s.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := s.ws.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				if utils.Logger != nil {
+					utils.Logger.Errorf("看板订阅连接错误: %v", err)
+				}
+			}
+			break
+		}
+	}
+}
+
+// writePump 向客户端写入事件，并定期发送ping保持连接
+func (s *Subscriber) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		s.ws.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-s.send:
+			s.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// 订阅已注销
+				s.ws.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := s.ws.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := s.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -13,6 +13,8 @@ import (
 type Hub struct {
 	// 注册的连接，key是ticket，value是连接
 	connections map[string]*Connection
+	// 看板订阅，key是订阅主题（如 board:1、project:1），value是订阅该主题的连接
+	subscribers map[string]map[*Subscriber]bool
 	// 互斥锁
 	mu sync.RWMutex
 }
@@ -40,6 +42,7 @@ var hub *Hub
 func init() {
 	hub = &Hub{
 		connections: make(map[string]*Connection),
+		subscribers: make(map[string]map[*Subscriber]bool),
 	}
 }
 
@@ -7,6 +7,13 @@ type HubInterface interface {
 	SendMessage(ticket, messageType string, data interface{}, message string) error
 }
 
+// BoardPublisher 看板事件发布接口
+type BoardPublisher interface {
+	// Publish 向订阅主题的所有连接广播看板事件
+	Publish(topic string, event *BoardEvent)
+}
+
 // 确保Hub实现了HubInterface接口
 var _ HubInterface = (*Hub)(nil)
+var _ BoardPublisher = (*Hub)(nil)
 
@@ -39,3 +39,12 @@ func HandleWebSocket(c *gin.Context) {
 	GetHub().Register(ticket, conn)
 }
 
+
+// Upgrade 升级HTTP连接为WebSocket（用于需要先完成认证和权限检查的连接）
+func Upgrade(c *gin.Context) (*websocket.Conn, error) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil && utils.Logger != nil {
+		utils.Logger.Errorf("WebSocket升级失败: %v", err)
+	}
+	return conn, err
+}
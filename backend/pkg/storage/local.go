@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStorage 本地文件系统存储
+type LocalStorage struct {
+	Root string
+}
+
+// 确保LocalStorage实现了Storage接口
+var _ Storage = (*LocalStorage)(nil)
+
+// NewLocalStorage 创建本地存储，root 为存储根目录
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+// Type 存储类型
+func (s *LocalStorage) Type() string {
+	return "local"
+}
+
+// Path 对象在本地文件系统中的路径
+func (s *LocalStorage) Path(key string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(CleanKey(key)))
+}
+
+// Put 先写入临时文件再重命名，避免读取到写了一半的文件
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	fullPath := s.Path(key)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("创建存储目录失败: %w", err)
+	}
+	tmpPath := fullPath + ".uploading"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("写入的大小（%d）与预期（%d）不一致", written, size)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, fullPath)
+}
+
+// Get 打开文件
+func (s *LocalStorage) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	file, err := os.Open(s.Path(key))
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, nil, fmt.Errorf("%s: %w", key, ErrNotExist)
+	}
+	return file, &ObjectInfo{Key: CleanKey(key), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Stat 获取文件信息
+func (s *LocalStorage) Stat(key string) (*ObjectInfo, error) {
+	info, err := os.Stat(s.Path(key))
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotExist)
+	}
+	return &ObjectInfo{Key: CleanKey(key), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete 删除文件
+func (s *LocalStorage) Delete(key string) error {
+	err := os.Remove(s.Path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List 遍历存储目录，按键前缀过滤
+func (s *LocalStorage) List(prefix string, fn func(ObjectInfo) error) error {
+	err := filepath.WalkDir(s.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, ".uploading") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// PresignGet 本地存储不支持临时下载地址
+func (s *LocalStorage) PresignGet(key string, expires time.Duration, disposition string) (string, error) {
+	return "", ErrPresignNotSupported
+}
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"prjflow/internal/config"
+)
+
+// emptyPayloadHash 空请求体的 SHA-256
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// maxPresignExpires 临时地址的最长有效期（S3 限制为 7 天）
+const maxPresignExpires = 7 * 24 * time.Hour
+
+// S3Storage S3 兼容对象存储（AWS S3、MinIO 等），使用 AWS Signature Version 4 签名
+type S3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	prefix    string
+	pathStyle bool
+	client    *http.Client
+}
+
+// 确保S3Storage实现了Storage接口
+var _ Storage = (*S3Storage)(nil)
+
+// NewS3Storage 根据配置创建 S3 存储
+func NewS3Storage(cfg config.S3Config) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 存储未配置 bucket")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3 存储未配置 access_key 或 secret_key")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("无效的 S3 endpoint: %s", endpoint)
+	}
+	s := &S3Storage{
+		endpoint:  u,
+		region:    region,
+		bucket:    cfg.Bucket,
+		accessKey: cfg.AccessKey,
+		secretKey: cfg.SecretKey,
+		pathStyle: cfg.PathStyle,
+		client:    &http.Client{},
+	}
+	return s.WithPrefix(cfg.Prefix), nil
+}
+
+// WithPrefix 返回在当前键前缀下增加一级前缀的存储（附件和备份共用一个 bucket）
+func (s *S3Storage) WithPrefix(prefix string) *S3Storage {
+	copied := *s
+	if prefix = CleanKey(prefix); prefix != "" {
+		copied.prefix = s.prefix + prefix + "/"
+	}
+	return &copied
+}
+
+// Type 存储类型
+func (s *S3Storage) Type() string {
+	return "s3"
+}
+
+// Put 上传对象（请求体不参与签名，支持流式上传）
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		return fmt.Errorf("上传到 S3 需要指定内容长度")
+	}
+	if size == 0 {
+		r = http.NoBody
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(http.MethodPut, s.objectKey(key), nil, r, size, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get 下载对象
+func (s *S3Storage) Get(key string) (io.ReadCloser, *ObjectInfo, error) {
+	resp, err := s.do(http.MethodGet, s.objectKey(key), nil, nil, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, s.objectInfo(key, resp), nil
+}
+
+// Stat 获取对象信息
+func (s *S3Storage) Stat(key string) (*ObjectInfo, error) {
+	resp, err := s.do(http.MethodHead, s.objectKey(key), nil, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return s.objectInfo(key, resp), nil
+}
+
+// Delete 删除对象
+func (s *S3Storage) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, s.objectKey(key), nil, nil, 0, nil)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List 按键前缀列出对象（ListObjectsV2，自动翻页）
+func (s *S3Storage) List(prefix string, fn func(ObjectInfo) error) error {
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.prefix+prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("解析 S3 对象列表失败: %w", err)
+		}
+		for _, object := range result.Contents {
+			info := ObjectInfo{Key: strings.TrimPrefix(object.Key, s.prefix), Size: object.Size, ModTime: object.LastModified}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// PresignGet 生成带签名的临时下载地址
+func (s *S3Storage) PresignGet(key string, expires time.Duration, disposition string) (string, error) {
+	return s.presignGet(key, expires, disposition, time.Now()), nil
+}
+
+func (s *S3Storage) presignGet(key string, expires time.Duration, disposition string, now time.Time) string {
+	if expires <= 0 || expires > maxPresignExpires {
+		expires = maxPresignExpires
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := s.scope(amzDate[:8])
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if disposition != "" {
+		query.Set("response-content-disposition", disposition)
+	}
+	u := s.objectURL(s.objectKey(key), query)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet, u.EscapedPath(), u.RawQuery, "host:" + u.Host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + s.signature(amzDate, canonicalRequest)
+	return u.String()
+}
+
+func (s *S3Storage) objectKey(key string) string {
+	return s.prefix + CleanKey(key)
+}
+
+func (s *S3Storage) objectInfo(key string, resp *http.Response) *ObjectInfo {
+	info := &ObjectInfo{Key: CleanKey(key), Size: resp.ContentLength}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+// objectURL 对象地址：路径风格为 endpoint/bucket/key，否则为 bucket.endpoint/key
+func (s *S3Storage) objectURL(objectKey string, query url.Values) *url.URL {
+	u := *s.endpoint
+	p := strings.TrimSuffix(u.Path, "/")
+	if s.pathStyle {
+		p += "/" + s.bucket
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	if objectKey != "" || !s.pathStyle {
+		p += "/" + objectKey
+	}
+	u.Path = p
+	u.RawPath = s3Escape(p, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do 发送签名请求，404 返回 ErrNotExist，其他错误状态解析 S3 错误信息
+func (s *S3Storage) do(method, objectKey string, query url.Values, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := s.objectURL(objectKey, query)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = "UNSIGNED-PAYLOAD"
+	}
+	s.sign(req, payloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", objectKey, ErrNotExist)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var s3Err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if xml.Unmarshal(data, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("S3 %s %s 失败: %s %s", method, objectKey, s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("S3 %s %s 失败: HTTP %d", method, objectKey, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// sign 使用请求头方式签名（签名 host、x-amz-content-sha256、x-amz-date）
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders, signedHeaders, payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(amzDate[:8]), signedHeaders, s.signature(amzDate, canonicalRequest)))
+}
+
+func (s *S3Storage) scope(date string) string {
+	return date + "/" + s.region + "/s3/aws4_request"
+}
+
+func (s *S3Storage) signature(amzDate, canonicalRequest string) string {
+	date := amzDate[:8]
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + s.scope(date) + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery 按参数名排序并按 S3 规则编码查询参数
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, s3Escape(key, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape 按 RFC 3986 编码（只保留非保留字符），encodeSlash 为 false 时保留 /
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist 对象不存在（与 fs.ErrNotExist 相同，可以用 errors.Is 判断）
+var ErrNotExist = fs.ErrNotExist
+
+// ErrPresignNotSupported 存储不支持生成临时下载地址（需要由服务器转发文件内容）
+var ErrPresignNotSupported = errors.New("presigned url not supported")
+
+// ObjectInfo 存储对象信息
+type ObjectInfo struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Storage 文件存储接口：对象键使用 / 分隔的相对路径
+type Storage interface {
+	// Type 存储类型：local, s3
+	Type() string
+
+	// Put 写入对象（size 为内容长度）
+	Put(key string, r io.Reader, size int64, contentType string) error
+
+	// Get 读取对象，调用方负责关闭
+	Get(key string) (io.ReadCloser, *ObjectInfo, error)
+
+	// Stat 获取对象信息
+	Stat(key string) (*ObjectInfo, error)
+
+	// Delete 删除对象（对象不存在时不返回错误）
+	Delete(key string) error
+
+	// List 按键前缀列出对象
+	List(prefix string, fn func(ObjectInfo) error) error
+
+	// PresignGet 生成带签名的临时下载地址，disposition 为响应的 Content-Disposition（可为空）
+	PresignGet(key string, expires time.Duration, disposition string) (string, error)
+}
+
+// CleanKey 规范化对象键：统一使用 /，去掉开头的 / 以及 . 和 .. 路径段（防止访问存储根目录之外）
+func CleanKey(key string) string {
+	key = strings.ReplaceAll(key, "\\", "/")
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
@@ -0,0 +1,109 @@
+// Package storagetest 提供测试用的内存 S3 兼容服务
+package storagetest
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeS3 内存中的 S3 兼容服务（路径风格，单个存储桶），只检查请求是否带有签名，不校验签名内容
+type FakeS3 struct {
+	Bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewFakeS3Server 启动内存 S3 服务，调用方负责关闭返回的服务器
+func NewFakeS3Server(bucket string) (*FakeS3, *httptest.Server) {
+	fake := &FakeS3{Bucket: bucket, objects: make(map[string][]byte)}
+	return fake, httptest.NewServer(fake)
+}
+
+// Object 读取存储桶中的对象
+func (f *FakeS3) Object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+// Keys 存储桶中的全部对象键（已排序）
+func (f *FakeS3) Keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *FakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") || r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucketPath := "/" + f.Bucket
+	if r.URL.Path == bucketPath && r.URL.Query().Get("list-type") == "2" {
+		type content struct {
+			Key          string
+			Size         int
+			LastModified string
+		}
+		result := struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			Contents    []content
+			IsTruncated bool
+		}{}
+		prefix := r.URL.Query().Get("prefix")
+		for key, data := range f.objects {
+			if strings.HasPrefix(key, prefix) {
+				result.Contents = append(result.Contents, content{Key: key, Size: len(data), LastModified: "2026-10-18T00:00:00.000Z"})
+			}
+		}
+		xml.NewEncoder(w).Encode(result)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, bucketPath+"/") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, bucketPath+"/")
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 {
+			w.WriteHeader(http.StatusLengthRequired)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", "Sun, 18 Oct 2026 00:00:00 GMT")
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// userRoleCodes 用户的角色代码
+func userRoleCodes(user *model.User) []string {
+	codes := []string{}
+	for _, role := range user.Roles {
+		codes = append(codes, role.Code)
+	}
+	return codes
+}
+
+func TestAdminUserManagement(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	t.Run("创建用户并分配角色", func(t *testing.T) {
+		_, err := utils.AdminCreateUser(db, utils.AdminUserInput{Username: "ops", Password: "weak"})
+		assert.Error(t, err, "密码强度不足")
+		_, err = utils.AdminCreateUser(db, utils.AdminUserInput{Username: "ops", Password: "Secret123", Roles: []string{"no-such-role"}})
+		assert.ErrorContains(t, err, "no-such-role")
+
+		user, err := utils.AdminCreateUser(db, utils.AdminUserInput{Username: "ops", Password: "Secret123", Roles: []string{"admin"}})
+		require.NoError(t, err)
+		assert.Equal(t, "ops", user.Nickname, "未指定昵称时使用用户名")
+		assert.Equal(t, 1, user.Status)
+		assert.Equal(t, []string{"admin"}, userRoleCodes(user))
+		assert.True(t, utils.CheckPassword("Secret123", user.Password))
+
+		_, err = utils.AdminCreateUser(db, utils.AdminUserInput{Username: "ops", Password: "Secret123"})
+		assert.ErrorContains(t, err, "用户名已存在")
+	})
+
+	t.Run("软删除用户占用的用户名可以重新创建", func(t *testing.T) {
+		deleted := CreateTestUser(t, db, "leaver", "离职")
+		require.NoError(t, db.Delete(&deleted).Error)
+		user, err := utils.AdminCreateUser(db, utils.AdminUserInput{Username: "leaver", Nickname: "新同事", Password: "Secret123"})
+		require.NoError(t, err)
+		assert.Equal(t, "新同事", user.Nickname)
+	})
+
+	t.Run("按用户名或ID重置密码、启用禁用", func(t *testing.T) {
+		user, err := utils.FindUserByRef(db, "ops")
+		require.NoError(t, err)
+		_, err = utils.AdminResetPassword(db, fmt.Sprintf("%d", user.ID), "Weak")
+		assert.Error(t, err)
+		_, err = utils.AdminResetPassword(db, fmt.Sprintf("%d", user.ID), "NewPass456")
+		require.NoError(t, err)
+
+		_, err = utils.AdminSetUserStatus(db, "ops", false)
+		require.NoError(t, err)
+		var reloaded model.User
+		require.NoError(t, db.First(&reloaded, user.ID).Error)
+		assert.True(t, utils.CheckPassword("NewPass456", reloaded.Password))
+		assert.Equal(t, 0, reloaded.Status)
+
+		_, err = utils.AdminSetUserStatus(db, "ops", true)
+		require.NoError(t, err)
+		require.NoError(t, db.First(&reloaded, user.ID).Error)
+		assert.Equal(t, 1, reloaded.Status)
+
+		_, err = utils.FindUserByRef(db, "nobody")
+		assert.ErrorContains(t, err, "用户不存在")
+	})
+
+	t.Run("修改角色", func(t *testing.T) {
+		user, err := utils.AdminAssignRoles(db, "ops", []string{"developer", "tester"}, utils.RoleAssignAdd)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"admin", "developer", "tester"}, userRoleCodes(user))
+
+		user, err = utils.AdminAssignRoles(db, "ops", []string{"admin"}, utils.RoleAssignRemove)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"developer", "tester"}, userRoleCodes(user))
+
+		user, err = utils.AdminAssignRoles(db, "ops", []string{"admin"}, utils.RoleAssignSet)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"admin"}, userRoleCodes(user))
+
+		user, err = utils.AdminAssignRoles(db, "ops", nil, utils.RoleAssignSet)
+		require.NoError(t, err)
+		assert.Empty(t, userRoleCodes(user), "set 为空时清除所有角色")
+
+		_, err = utils.AdminAssignRoles(db, "ops", []string{"admin"}, "toggle")
+		assert.Error(t, err)
+	})
+}
+
+func TestAdminPermissionsAndSystemConfig(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	all, err := utils.ListPermissions(db, "")
+	require.NoError(t, err)
+	developer, err := utils.ListPermissions(db, "developer")
+	require.NoError(t, err)
+	require.NotEmpty(t, developer)
+	assert.Less(t, len(developer), len(all))
+	for i := 1; i < len(developer); i++ {
+		assert.Less(t, developer[i-1].Code, developer[i].Code, "按代码排序")
+	}
+	_, err = utils.ListPermissions(db, "no-such-role")
+	assert.Error(t, err)
+
+	_, set, err := utils.GetSystemConfig(db, "backup_time")
+	require.NoError(t, err)
+	assert.False(t, set)
+
+	assert.Error(t, utils.SetSystemConfig(db, "backup_time", "25:00"))
+	assert.Error(t, utils.SetSystemConfig(db, "log_level", "verbose"))
+	assert.Error(t, utils.SetSystemConfig(db, utils.BackupRetentionKey, "-1"))
+	assert.ErrorContains(t, utils.SetSystemConfig(db, "unknown_key", "1"), "backup_time")
+
+	require.NoError(t, utils.SetSystemConfig(db, "backup_time", "03:30"))
+	require.NoError(t, utils.SetSystemConfig(db, "backup_time", "04:00"))
+	require.NoError(t, utils.SetSystemConfig(db, utils.BackupRetentionKey, "30"))
+	value, set, err := utils.GetSystemConfig(db, "backup_time")
+	require.NoError(t, err)
+	assert.True(t, set)
+	assert.Equal(t, "04:00", value)
+	assert.Equal(t, 30, utils.LoadBackupRetentionDays(db), "与备份设置读取的配置一致")
+
+	var count int64
+	db.Model(&model.SystemConfig{}).Where("key = ?", "backup_time").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,232 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+)
+
+// attachmentPermissions 测试用户拥有的附件权限
+var attachmentPermissions = []string{"attachment:upload", "attachment:delete"}
+
+// postAttachmentForm 以 multipart 表单调用附件接口（上传附件或新版本）
+func postAttachmentForm(t *testing.T, handler gin.HandlerFunc, userID uint, roles []string, params gin.Params, fields map[string]string, fileName string, content []byte) map[string]interface{} {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, value := range fields {
+		require.NoError(t, writer.WriteField(key, value))
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	require.NoError(t, err)
+	part.Write(content)
+	require.NoError(t, writer.Close())
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/attachments/upload", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Params = params
+	c.Set("user_id", userID)
+	c.Set("roles", roles)
+	c.Set("permissions", attachmentPermissions)
+	handler(c)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+// requestAttachment 调用附件接口，返回原始响应（用于下载和预览）
+func requestAttachment(handler gin.HandlerFunc, userID uint, roles []string, method, target string, params gin.Params) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, nil)
+	c.Params = params
+	c.Set("user_id", userID)
+	c.Set("roles", roles)
+	c.Set("permissions", attachmentPermissions)
+	handler(c)
+	return w
+}
+
+// testPNG 生成指定尺寸的渐变 PNG 图片
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x % 256), G: uint8(y % 256), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// attachmentID 上传接口响应中的附件ID
+func attachmentID(t *testing.T, response map[string]interface{}) uint {
+	require.Equal(t, float64(200), response["code"], response["message"])
+	return uint(response["data"].(map[string]interface{})["id"].(float64))
+}
+
+func TestAttachmentDedupThumbnailAndVersions(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	oldUpload, oldStorage := config.AppConfig.Upload, config.AppConfig.Storage
+	defer func() {
+		config.AppConfig.Upload, config.AppConfig.Storage = oldUpload, oldStorage
+	}()
+	uploadDir := t.TempDir()
+	config.AppConfig.Upload = config.UploadConfig{StoragePath: uploadDir, MaxFileSize: 10 * 1024 * 1024}
+	config.AppConfig.Storage = config.StorageConfig{Type: "local"}
+
+	project := CreateTestProject(t, db, "附件去重项目")
+	handler := api.NewAttachmentHandler(db)
+	admin := []string{"admin"}
+	projectFields := map[string]string{"project_id": fmt.Sprintf("%d", project.ID)}
+	idParams := func(id uint) gin.Params { return gin.Params{{Key: "id", Value: fmt.Sprintf("%d", id)}} }
+	fileExists := func(key string) bool {
+		_, err := os.Stat(filepath.Join(uploadDir, filepath.FromSlash(key)))
+		return err == nil
+	}
+
+	t.Run("相同内容共用一个文件", func(t *testing.T) {
+		firstID := attachmentID(t, postAttachmentForm(t, handler.UploadFile, 1, admin, nil, projectFields, "a.txt", []byte("same content")))
+		secondID := attachmentID(t, postAttachmentForm(t, handler.UploadFile, 1, admin, nil, projectFields, "a-copy.txt", []byte("same content")))
+
+		var first, second model.Attachment
+		require.NoError(t, db.First(&first, firstID).Error)
+		require.NoError(t, db.First(&second, secondID).Error)
+		assert.Equal(t, first.FilePath, second.FilePath)
+		assert.Equal(t, first.ContentHash, second.ContentHash)
+		var blob model.AttachmentBlob
+		require.NoError(t, db.Where("hash = ?", first.ContentHash).First(&blob).Error)
+		assert.Equal(t, 2, blob.RefCount)
+
+		w := requestAttachment(handler.DeleteAttachment, 1, admin, http.MethodDelete, "/", idParams(firstID))
+		assert.Contains(t, w.Body.String(), "删除成功")
+		assert.True(t, fileExists(first.FilePath), "仍被其他附件引用的文件不删除")
+
+		requestAttachment(handler.DeleteAttachment, 1, admin, http.MethodDelete, "/", idParams(secondID))
+		assert.False(t, fileExists(first.FilePath), "最后一个引用删除后删除文件")
+		assert.Error(t, db.Where("hash = ?", first.ContentHash).First(&model.AttachmentBlob{}).Error)
+	})
+
+	t.Run("图片缩略图", func(t *testing.T) {
+		id := attachmentID(t, postAttachmentForm(t, handler.UploadFile, 1, admin, nil, projectFields, "截图.png", testPNG(t, 800, 400)))
+		var attachment model.Attachment
+		require.NoError(t, db.First(&attachment, id).Error)
+		var blob model.AttachmentBlob
+		require.NoError(t, db.Where("hash = ?", attachment.ContentHash).First(&blob).Error)
+		require.NotEmpty(t, blob.ThumbnailPath)
+
+		w := requestAttachment(handler.PreviewFile, 1, admin, http.MethodGet, "/?thumbnail=true", idParams(id))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		thumbnail, err := png.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, 320, thumbnail.Width)
+		assert.Equal(t, 160, thumbnail.Height)
+
+		// 非图片没有缩略图时返回原文件
+		textID := attachmentID(t, postAttachmentForm(t, handler.UploadFile, 1, admin, nil, projectFields, "说明.txt", []byte("not an image")))
+		w = requestAttachment(handler.PreviewFile, 1, admin, http.MethodGet, "/?thumbnail=true", idParams(textID))
+		assert.Equal(t, "not an image", w.Body.String())
+	})
+
+	t.Run("上传新版本和下载历史版本", func(t *testing.T) {
+		id := attachmentID(t, postAttachmentForm(t, handler.UploadFile, 1, admin, nil, projectFields, "需求文档.txt", []byte("version one")))
+		var original model.Attachment
+		require.NoError(t, db.First(&original, id).Error)
+
+		response := postAttachmentForm(t, handler.UploadAttachmentVersion, 1, admin, idParams(id), map[string]string{"comment": "补充验收标准"}, "需求文档-v2.txt", []byte("version two"))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, float64(2), response["data"].(map[string]interface{})["version"])
+
+		response = callProgramHandler(t, handler.GetAttachmentVersions, 1, admin, http.MethodGet, idParams(id), nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		list := response["data"].(map[string]interface{})["list"].([]interface{})
+		require.Len(t, list, 2)
+		latest := list[0].(map[string]interface{})
+		assert.Equal(t, float64(2), latest["version"])
+		assert.Equal(t, "补充验收标准", latest["comment"])
+		assert.Equal(t, "需求文档.txt", list[1].(map[string]interface{})["file_name"])
+
+		w := downloadTestAttachment(handler, id)
+		assert.Equal(t, "version two", w.Body.String())
+		w = requestAttachment(handler.DownloadAttachmentVersion, 1, admin, http.MethodGet, "/", append(idParams(id), gin.Param{Key: "version", Value: "1"}))
+		assert.Equal(t, "version one", w.Body.String())
+		assert.True(t, fileExists(original.FilePath), "历史版本的文件保留")
+
+		response = postAttachmentForm(t, handler.UploadAttachmentVersion, 1, admin, idParams(id), nil, "需求文档-v3.txt", []byte("version three"))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		var versions []model.AttachmentVersion
+		require.NoError(t, db.Where("attachment_id = ?", id).Order("version").Find(&versions).Error)
+		require.Len(t, versions, 3)
+		for _, version := range versions {
+			var blob model.AttachmentBlob
+			require.NoError(t, db.Where("hash = ?", version.ContentHash).First(&blob).Error)
+			expected := 1
+			if version.Version == 3 {
+				expected = 2 // 当前版本同时被附件和版本记录引用
+			}
+			assert.Equal(t, expected, blob.RefCount, "版本 %d", version.Version)
+		}
+
+		requestAttachment(handler.DeleteAttachment, 1, admin, http.MethodDelete, "/", idParams(id))
+		for _, version := range versions {
+			assert.False(t, fileExists(version.FilePath), "删除附件时删除所有版本的文件")
+		}
+		var count int64
+		db.Model(&model.AttachmentVersion{}).Where("attachment_id = ?", id).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("关联到测试单和日报", func(t *testing.T) {
+		author := CreateTestUser(t, db, "attachmentauthor", "日报作者")
+		other := CreateTestUser(t, db, "attachmentother", "其他用户")
+		member := []string{"developer"}
+
+		testCase := model.TestCase{Name: "登录测试", ProjectID: project.ID, CreatorID: 1}
+		require.NoError(t, db.Create(&testCase).Error)
+		id := attachmentID(t, postAttachmentForm(t, handler.UploadFile, 1, admin, nil, projectFields, "测试数据.csv", []byte("user,password")))
+		response := callProgramHandler(t, handler.AttachToEntity, 1, admin, http.MethodPost, idParams(id), map[string]interface{}{"test_case_id": testCase.ID})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		w := requestAttachment(handler.GetAttachments, 1, admin, http.MethodGet, fmt.Sprintf("/?test_case_id=%d", testCase.ID), nil)
+		assert.Contains(t, w.Body.String(), "测试数据.csv")
+
+		report := model.DailyReport{Date: time.Now(), Content: "今日工作", Status: "draft", UserID: author.ID}
+		require.NoError(t, db.Create(&report).Error)
+		reportFields := map[string]string{"daily_report_id": fmt.Sprintf("%d", report.ID)}
+		response = postAttachmentForm(t, handler.UploadFile, other.ID, member, nil, reportFields, "日报截图.txt", []byte("report"))
+		assert.Equal(t, float64(403), response["code"], "只能为自己的日报上传附件")
+		reportAttachmentID := attachmentID(t, postAttachmentForm(t, handler.UploadFile, author.ID, member, nil, reportFields, "日报截图.txt", []byte("report")))
+
+		w = requestAttachment(handler.GetAttachments, author.ID, member, http.MethodGet, fmt.Sprintf("/?daily_report_id=%d", report.ID), nil)
+		assert.Contains(t, w.Body.String(), "日报截图.txt")
+		w = requestAttachment(handler.DownloadFile, author.ID, member, http.MethodGet, "/", idParams(reportAttachmentID))
+		assert.Equal(t, "report", w.Body.String())
+		response = callProgramHandler(t, handler.GetAttachment, other.ID, member, http.MethodGet, idParams(reportAttachmentID), nil)
+		assert.Equal(t, float64(403), response["code"], "不能查看他人日报的附件")
+	})
+}
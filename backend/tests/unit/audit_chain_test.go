@@ -0,0 +1,182 @@
+package unit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestAuditLogHashChain(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+	require.NoError(t, utils.MigrateAuditDB(db, nil))
+
+	oldAuditDB := utils.AuditDB
+	utils.AuditDB = nil
+	defer func() { utils.AuditDB = oldAuditDB }()
+
+	// 启用哈希链之前的历史记录
+	require.NoError(t, db.Create(&model.AuditLog{UserID: 1, Username: "legacy", ActionType: "login"}).Error)
+	for _, action := range []string{"login", "create", "update", "delete", "login"} {
+		require.NoError(t, utils.RecordAuditLogSync(db, 1, "chainuser", action, "user", 1, nil, true, "", ""))
+	}
+	var logs []model.AuditLog
+	require.NoError(t, db.Order("id ASC").Find(&logs).Error)
+	require.Len(t, logs, 6)
+	assert.Empty(t, logs[1].PrevHash, "第一条链上记录没有上一条哈希")
+	assert.Equal(t, logs[1].Hash, logs[2].PrevHash)
+
+	verify := func() *utils.AuditChainReport {
+		report, err := utils.VerifyAuditChain(db)
+		require.NoError(t, err)
+		return report
+	}
+
+	t.Run("未被篡改时校验通过", func(t *testing.T) {
+		report := verify()
+		assert.True(t, report.Valid, report.Issues)
+		assert.Equal(t, 5, report.Verified)
+		assert.Equal(t, 1, report.Legacy)
+	})
+
+	t.Run("发现被修改的记录", func(t *testing.T) {
+		require.NoError(t, db.Exec("UPDATE audit_logs SET username = ? WHERE id = ?", "someone", logs[2].ID).Error)
+		report := verify()
+		assert.False(t, report.Valid)
+		require.Len(t, report.Issues, 1)
+		assert.Equal(t, logs[2].ID, report.Issues[0].ID)
+		assert.Equal(t, "modified", report.Issues[0].Type)
+		require.NoError(t, db.Exec("UPDATE audit_logs SET username = ? WHERE id = ?", "chainuser", logs[2].ID).Error)
+	})
+
+	t.Run("发现被删除的记录", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM audit_logs WHERE id = ?", logs[3].ID).Error)
+		report := verify()
+		assert.False(t, report.Valid)
+		require.Len(t, report.Issues, 1)
+		assert.Equal(t, logs[4].ID, report.Issues[0].ID)
+		assert.Equal(t, "gap", report.Issues[0].Type)
+		require.NoError(t, db.Create(&logs[3]).Error)
+		assert.True(t, verify().Valid)
+	})
+
+	t.Run("发现被清除哈希的链上记录", func(t *testing.T) {
+		var state model.AuditChainState
+		require.NoError(t, db.First(&state).Error)
+		assert.Equal(t, logs[1].ID, state.GenesisID, "记录哈希链起点")
+
+		// 清除前两条链上记录的哈希后修改内容
+		require.NoError(t, db.Exec("UPDATE audit_logs SET hash = '', prev_hash = '', username = ? WHERE id IN ?", "someone", []uint{logs[1].ID, logs[2].ID}).Error)
+		report := verify()
+		assert.False(t, report.Valid)
+		require.Len(t, report.Issues, 3)
+		assert.Equal(t, "unsealed", report.Issues[0].Type)
+		assert.Equal(t, logs[1].ID, report.Issues[0].ID)
+		assert.Equal(t, "unsealed", report.Issues[1].Type)
+		assert.Equal(t, logs[2].ID, report.Issues[1].ID)
+		assert.Equal(t, "gap", report.Issues[2].Type)
+		assert.Equal(t, logs[3].ID, report.Issues[2].ID)
+		assert.Equal(t, 1, report.Legacy)
+
+		// 没有链起点记录时，第一条链上记录的上一条哈希不为空也视为不连续
+		require.NoError(t, db.Where("1 = 1").Delete(&model.AuditChainState{}).Error)
+		report = verify()
+		assert.False(t, report.Valid)
+		require.Len(t, report.Issues, 1)
+		assert.Equal(t, "gap", report.Issues[0].Type)
+		assert.Equal(t, logs[3].ID, report.Issues[0].ID)
+
+		for _, log := range logs[1:3] {
+			require.NoError(t, db.Model(&model.AuditLog{}).Where("id = ?", log.ID).
+				UpdateColumns(map[string]interface{}{"hash": log.Hash, "prev_hash": log.PrevHash, "username": log.Username}).Error)
+		}
+		require.NoError(t, utils.MigrateAuditDB(db, nil))
+		var migrated model.AuditChainState
+		require.NoError(t, db.First(&migrated).Error)
+		assert.Equal(t, logs[1].ID, migrated.GenesisID, "迁移时补充记录链起点")
+		assert.True(t, verify().Valid)
+	})
+
+	t.Run("按操作类型归档过期记录后链仍然完整", func(t *testing.T) {
+		// 将所有记录改到 40 天前并重新计算哈希链
+		old := time.Now().AddDate(0, 0, -40).Truncate(time.Millisecond)
+		require.NoError(t, db.Model(&model.AuditLog{}).Where("1 = 1").UpdateColumn("created_at", old).Error)
+		resealAuditChain(t, db)
+
+		require.NoError(t, utils.SaveAuditRetention(db, utils.AuditRetention{DefaultDays: 365, ActionDays: map[string]int{"create": 30, "update": 0}}))
+		retention := utils.LoadAuditRetention(db)
+		assert.Equal(t, 0, retention.Days("update"))
+		assert.Equal(t, 365, retention.Days("delete"))
+
+		dir := t.TempDir()
+		count, file, err := utils.ArchiveExpiredAuditLogs(db, retention, dir)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count, "只有 create 超过保留期")
+
+		archived, err := utils.ReadAuditArchive(file)
+		require.NoError(t, err)
+		require.Len(t, archived, 1)
+		assert.Equal(t, "create", archived[0].ActionType)
+		assert.Equal(t, utils.AuditLogHash(&archived[0]), archived[0].Hash, "归档文件保留完整记录")
+
+		report := verify()
+		assert.True(t, report.Valid, report.Issues)
+		assert.Equal(t, 1, report.Archived)
+		assert.Equal(t, 5, report.Total)
+
+		// 归档后继续写入的记录接在链尾
+		require.NoError(t, utils.RecordAuditLogSync(db, 1, "chainuser", "logout", "user", 1, nil, true, "", ""))
+		assert.True(t, verify().Valid)
+	})
+
+	t.Run("按筛选条件导出", func(t *testing.T) {
+		handler := api.NewAuditLogHandler(db)
+		export := func(query string) *httptest.ResponseRecorder {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/audit-logs/export?"+query, nil)
+			handler.ExportAuditLogs(c)
+			return w
+		}
+
+		w := export("format=csv&action_type=login")
+		assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Len(t, lines, 4, "表头和三条登录记录")
+
+		w = export("format=json&action_type=logout")
+		var records []model.AuditLog
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+		require.Len(t, records, 1)
+		assert.NotEmpty(t, records[0].Hash)
+
+		w = export("format=xml")
+		assert.Contains(t, w.Body.String(), "无效的导出格式")
+	})
+}
+
+// resealAuditChain 按ID顺序重新计算哈希链（用于构造历史数据）
+func resealAuditChain(t *testing.T, db *gorm.DB) {
+	var logs []model.AuditLog
+	require.NoError(t, db.Where("hash <> ?", "").Order("id ASC").Find(&logs).Error)
+	prev := ""
+	for i := range logs {
+		logs[i].PrevHash = prev
+		logs[i].Hash = utils.AuditLogHash(&logs[i])
+		require.NoError(t, db.Model(&logs[i]).UpdateColumns(map[string]interface{}{"prev_hash": logs[i].PrevHash, "hash": logs[i].Hash}).Error)
+		prev = logs[i].Hash
+	}
+}
@@ -0,0 +1,166 @@
+package unit
+
+import (
+	"archive/zip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"prjflow/internal/api"
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestFullBackupAndRestore(t *testing.T) {
+	// 初始化测试配置；备份使用独立的文件数据库
+	TeardownTestDB(t, SetupTestDB(t))
+	oldDatabase, oldUpload := config.AppConfig.Database, config.AppConfig.Upload
+	defer func() {
+		config.AppConfig.Database, config.AppConfig.Upload = oldDatabase, oldUpload
+	}()
+
+	dataDir := t.TempDir()
+	dbPath := filepath.Join(dataDir, "data.db")
+	uploadDir := filepath.Join(dataDir, "uploads")
+	config.AppConfig.Database = config.DatabaseConfig{Type: "sqlite", DSN: dbPath}
+	config.AppConfig.Upload.StoragePath = uploadDir
+
+	openDB := func() *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		require.NoError(t, err)
+		return db
+	}
+	closeDB := func(db *gorm.DB) {
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.Close()
+	}
+
+	db := openDB()
+	require.NoError(t, utils.AutoMigrate(db))
+	CreateTestProject(t, db, "备份项目")
+	require.NoError(t, os.MkdirAll(filepath.Join(uploadDir, "2026", "10"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "2026", "10", "a.txt"), []byte("原始附件"), 0644))
+
+	backupDir := utils.BackupDir()
+	assert.Equal(t, filepath.Join(dataDir, "backups"), backupDir)
+	info, err := utils.CreateBackup(db, nil, backupDir)
+	require.NoError(t, err)
+	assert.True(t, utils.ValidBackupName(info.Name))
+	assert.Equal(t, "sqlite", info.Manifest.DatabaseType)
+	assert.Equal(t, "local", info.Manifest.UploadStorage)
+	assert.Equal(t, 1, info.Manifest.UploadFiles)
+	require.NotNil(t, info.Manifest.File("data.db"))
+	require.NotNil(t, info.Manifest.File("uploads/2026/10/a.txt"))
+	backupPath := filepath.Join(backupDir, info.Name)
+
+	t.Run("列表和校验", func(t *testing.T) {
+		backups, err := utils.ListBackups(backupDir)
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		assert.Equal(t, info.Name, backups[0].Name)
+
+		manifest, err := utils.VerifyBackup(backupPath)
+		require.NoError(t, err)
+		assert.Len(t, manifest.Files, 2)
+	})
+
+	t.Run("发现被篡改的备份", func(t *testing.T) {
+		tampered := filepath.Join(t.TempDir(), info.Name)
+		rewriteZipEntry(t, backupPath, tampered, "uploads/2026/10/a.txt", []byte("被替换的附件"))
+		_, err := utils.VerifyBackup(tampered)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "校验和不一致")
+	})
+
+	t.Run("备份接口", func(t *testing.T) {
+		handler := api.NewSystemHandler(db)
+		response := callProgramHandler(t, handler.GetBackups, 1, []string{"admin"}, http.MethodGet, nil, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, float64(1), response["data"].(map[string]interface{})["total"])
+		assert.Equal(t, float64(utils.DefaultBackupRetentionDays), response["data"].(map[string]interface{})["retention_days"])
+
+		response = callProgramHandler(t, handler.DeleteBackup, 1, []string{"admin"}, http.MethodDelete, gin.Params{{Key: "name", Value: "../data.db"}}, nil)
+		assert.Equal(t, float64(400), response["code"], "不允许访问备份目录之外的文件")
+
+		response = callProgramHandler(t, handler.VerifyBackup, 1, []string{"admin"}, http.MethodPost, gin.Params{{Key: "name", Value: info.Name}}, nil)
+		assert.Equal(t, float64(200), response["code"], response["message"])
+
+		require.NoError(t, utils.SaveBackupRetentionDays(db, 30))
+		assert.Equal(t, 30, utils.LoadBackupRetentionDays(db))
+	})
+
+	t.Run("暂存恢复在启动时执行", func(t *testing.T) {
+		_, err := utils.StageRestore(backupDir, info.Name, "admin")
+		require.NoError(t, err)
+		pending := utils.GetPendingRestore(backupDir)
+		require.NotNil(t, pending)
+		assert.Equal(t, info.Name, pending.Name)
+
+		// 备份之后的改动
+		require.NoError(t, db.Where("1 = 1").Delete(&model.Project{}).Error)
+		require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "2026", "10", "a.txt"), []byte("修改后的附件"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "new.txt"), []byte("新附件"), 0644))
+		closeDB(db)
+
+		result, err := utils.ApplyPendingRestore()
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.True(t, result.Success)
+		assert.Nil(t, utils.GetPendingRestore(backupDir), "恢复请求只执行一次")
+		assert.True(t, utils.GetLastRestoreResult(backupDir).Success)
+
+		restored := openDB()
+		defer closeDB(restored)
+		var count int64
+		restored.Model(&model.Project{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		content, err := os.ReadFile(filepath.Join(uploadDir, "2026", "10", "a.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, "原始附件", string(content))
+		_, err = os.Stat(filepath.Join(uploadDir, "new.txt"))
+		assert.True(t, os.IsNotExist(err), "备份之后上传的文件不在恢复结果中")
+
+		previous, err := filepath.Glob(filepath.Join(dataDir, "data.db.before-restore-*"))
+		require.NoError(t, err)
+		assert.Len(t, previous, 1, "恢复前的数据库文件被保留")
+	})
+}
+
+// rewriteZipEntry 复制压缩包并替换其中一个文件的内容（保留原清单）
+func rewriteZipEntry(t *testing.T, src, dst, name string, content []byte) {
+	reader, err := zip.OpenReader(src)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer out.Close()
+	writer := zip.NewWriter(out)
+	for _, file := range reader.File {
+		w, err := writer.Create(file.Name)
+		require.NoError(t, err)
+		if file.Name == name {
+			_, err = w.Write(content)
+			require.NoError(t, err)
+			continue
+		}
+		rc, err := file.Open()
+		require.NoError(t, err)
+		_, err = io.Copy(w, rc)
+		rc.Close()
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+}
@@ -3,16 +3,25 @@ package unit
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	gorillaws "github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"prjflow/internal/api"
+	"prjflow/internal/config"
+	"prjflow/internal/middleware"
 	"prjflow/internal/model"
+	"prjflow/internal/utils"
+	"prjflow/internal/websocket"
+	"prjflow/pkg/auth"
 )
 
 func TestBoardHandler_GetProjectBoards(t *testing.T) {
@@ -533,3 +542,369 @@ func TestBoardHandler_GetBoardTasks(t *testing.T) {
 	})
 }
 
+
+func TestRankBetween(t *testing.T) {
+	t.Run("生成的排序键位于两者之间", func(t *testing.T) {
+		cases := [][2]string{{"", ""}, {"", "i"}, {"i", ""}, {"a", "b"}, {"a", "a1"}, {"az", "b"}, {"", "01"}}
+		for _, tc := range cases {
+			rank := utils.RankBetween(tc[0], tc[1])
+			if tc[0] != "" {
+				assert.Greater(t, rank, tc[0])
+			}
+			if tc[1] != "" {
+				assert.Less(t, rank, tc[1])
+			}
+			assert.NotEqual(t, byte('0'), rank[len(rank)-1])
+		}
+	})
+
+	t.Run("等间距排序键递增", func(t *testing.T) {
+		ranks := utils.RankSequence(100)
+		require.Len(t, ranks, 100)
+		for i := 1; i < len(ranks); i++ {
+			assert.Less(t, ranks[i-1], ranks[i])
+		}
+	})
+}
+
+// moveBoardCard 调用看板卡片移动接口，返回响应
+func moveBoardCard(t *testing.T, handler *api.BoardHandler, boardID uint, objectType string, objectID, columnID uint, position *int) map[string]interface{} {
+	body := map[string]interface{}{"column_id": fmt.Sprintf("%d", columnID)}
+	if position != nil {
+		body["position"] = *position
+	}
+	return moveBoardCardWithBody(t, handler, boardID, objectType, objectID, body)
+}
+
+// moveBoardCardWithBody 以指定请求体调用看板卡片移动接口，返回响应
+func moveBoardCardWithBody(t *testing.T, handler *api.BoardHandler, boardID uint, objectType string, objectID uint, body map[string]interface{}) map[string]interface{} {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	jsonData, _ := json.Marshal(body)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/api/boards/move", bytes.NewBuffer(jsonData))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{
+		{Key: "id", Value: fmt.Sprintf("%d", boardID)},
+		{Key: "type", Value: objectType},
+		{Key: "card_id", Value: fmt.Sprintf("%d", objectID)},
+	}
+	c.Set("user_id", uint(1))
+	c.Set("roles", []string{"admin"})
+
+	handler.MoveCard(c)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestBoardHandler_WIPLimit(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "WIP看板项目")
+	user := CreateTestUser(t, db, "wipuser", "WIP用户")
+	board := &model.Board{Name: "WIP看板", ProjectID: project.ID, CardTypes: "task"}
+	require.NoError(t, db.Create(board).Error)
+	todo := &model.BoardColumn{Name: "待办", BoardID: board.ID, Status: "wait", Sort: 1}
+	blocking := &model.BoardColumn{Name: "进行中", BoardID: board.ID, Status: "doing", Sort: 2, WIPLimit: 1, WIPPolicy: "block"}
+	warning := &model.BoardColumn{Name: "暂停", BoardID: board.ID, Status: "pause", Sort: 3, WIPLimit: 1, WIPPolicy: "warn"}
+	for _, column := range []*model.BoardColumn{todo, blocking, warning} {
+		require.NoError(t, db.Create(column).Error)
+	}
+
+	tasks := make([]*model.Task, 3)
+	for i := range tasks {
+		tasks[i] = &model.Task{Title: fmt.Sprintf("WIP任务%d", i), ProjectID: project.ID, CreatorID: user.ID, Status: "wait"}
+		require.NoError(t, db.Create(tasks[i]).Error)
+	}
+	require.NoError(t, db.Model(tasks[2]).Update("status", "pause").Error)
+
+	handler := api.NewBoardHandler(db)
+
+	t.Run("未达到上限时可以移入", func(t *testing.T) {
+		response := moveBoardCard(t, handler, board.ID, "task", tasks[0].ID, blocking.ID, nil)
+		assert.Equal(t, float64(200), response["code"])
+	})
+
+	t.Run("block策略拒绝超出上限", func(t *testing.T) {
+		response := moveBoardCard(t, handler, board.ID, "task", tasks[1].ID, blocking.ID, nil)
+		assert.Equal(t, float64(400), response["code"])
+
+		var task model.Task
+		require.NoError(t, db.First(&task, tasks[1].ID).Error)
+		assert.Equal(t, "wait", task.Status)
+	})
+
+	t.Run("列内调整顺序不受上限限制", func(t *testing.T) {
+		position := 0
+		response := moveBoardCard(t, handler, board.ID, "task", tasks[0].ID, blocking.ID, &position)
+		assert.Equal(t, float64(200), response["code"])
+	})
+
+	t.Run("warn策略允许移入并提示", func(t *testing.T) {
+		response := moveBoardCard(t, handler, board.ID, "task", tasks[1].ID, warning.ID, nil)
+		assert.Equal(t, float64(200), response["code"])
+		assert.Contains(t, response["message"], "WIP")
+
+		var task model.Task
+		require.NoError(t, db.First(&task, tasks[1].ID).Error)
+		assert.Equal(t, "pause", task.Status)
+	})
+}
+
+func TestBoardHandler_CardOrderAndSwimlanes(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "排序看板项目")
+	alice := CreateTestUser(t, db, "boardalice", "Alice")
+	board := &model.Board{Name: "混合看板", ProjectID: project.ID, CardTypes: "task,bug"}
+	require.NoError(t, db.Create(board).Error)
+	active := &model.BoardColumn{Name: "处理中", BoardID: board.ID, Status: "doing", BugStatus: "active", Sort: 1}
+	done := &model.BoardColumn{Name: "完成", BoardID: board.ID, Status: "done", BugStatus: "resolved", Sort: 2}
+	require.NoError(t, db.Create(active).Error)
+	require.NoError(t, db.Create(done).Error)
+
+	task1 := &model.Task{Title: "任务一", ProjectID: project.ID, CreatorID: alice.ID, AssigneeID: &alice.ID, Status: "doing", Priority: "high"}
+	task2 := &model.Task{Title: "任务二", ProjectID: project.ID, CreatorID: alice.ID, Status: "doing", Priority: "low"}
+	require.NoError(t, db.Create(task1).Error)
+	require.NoError(t, db.Create(task2).Error)
+	bug := &model.Bug{Title: "看板Bug", ProjectID: project.ID, CreatorID: alice.ID, Status: "active", Priority: "urgent"}
+	require.NoError(t, db.Create(bug).Error)
+
+	handler := api.NewBoardHandler(db)
+
+	getBoard := func(query string) map[string]interface{} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/boards/tasks"+query, nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", board.ID)}}
+		c.Set("user_id", alice.ID)
+		c.Set("roles", []string{"admin"})
+		handler.GetBoardTasks(c)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, float64(200), response["code"])
+		return response["data"].(map[string]interface{})
+	}
+	cardTitles := func(cards interface{}) []string {
+		titles := []string{}
+		for _, card := range cards.([]interface{}) {
+			titles = append(titles, card.(map[string]interface{})["title"].(string))
+		}
+		return titles
+	}
+	columnKey := fmt.Sprintf("%d", active.ID)
+
+	t.Run("移动后保留卡片顺序", func(t *testing.T) {
+		position := 0
+		require.Equal(t, float64(200), moveBoardCard(t, handler, board.ID, "task", task1.ID, active.ID, &position)["code"])
+		position = 1
+		require.Equal(t, float64(200), moveBoardCard(t, handler, board.ID, "bug", bug.ID, active.ID, &position)["code"])
+
+		data := getBoard("")
+		cards := data["cards_by_column"].(map[string]interface{})[columnKey]
+		assert.Equal(t, []string{"任务一", "看板Bug", "任务二"}, cardTitles(cards))
+
+		tasks := data["tasks_by_column"].(map[string]interface{})[columnKey].([]interface{})
+		assert.Len(t, tasks, 2)
+	})
+
+	t.Run("Bug卡片按列映射更新状态", func(t *testing.T) {
+		response := moveBoardCard(t, handler, board.ID, "bug", bug.ID, done.ID, nil)
+		require.Equal(t, float64(200), response["code"])
+
+		var updated model.Bug
+		require.NoError(t, db.First(&updated, bug.ID).Error)
+		assert.Equal(t, "resolved", updated.Status)
+	})
+
+	t.Run("看板不显示的卡片类型不能移动", func(t *testing.T) {
+		requirement := &model.Requirement{Title: "看板需求", ProjectID: project.ID, CreatorID: alice.ID, Status: "active"}
+		require.NoError(t, db.Create(requirement).Error)
+		response := moveBoardCard(t, handler, board.ID, "requirement", requirement.ID, active.ID, nil)
+		assert.Equal(t, float64(400), response["code"])
+	})
+
+	t.Run("按相邻卡片计算插入位置", func(t *testing.T) {
+		task3 := &model.Task{Title: "任务三", ProjectID: project.ID, CreatorID: alice.ID, Status: "doing", Priority: "low"}
+		require.NoError(t, db.Create(task3).Error)
+
+		position := 0
+		require.Equal(t, float64(200), moveBoardCard(t, handler, board.ID, "task", task2.ID, active.ID, &position)["code"])
+		position = 1
+		require.Equal(t, float64(200), moveBoardCard(t, handler, board.ID, "task", task3.ID, active.ID, &position)["code"])
+		cards := getBoard("")["cards_by_column"].(map[string]interface{})[columnKey]
+		assert.Equal(t, []string{"任务二", "任务三", "任务一"}, cardTitles(cards))
+
+		position = 10
+		require.Equal(t, float64(200), moveBoardCard(t, handler, board.ID, "task", task2.ID, active.ID, &position)["code"])
+		cards = getBoard("")["cards_by_column"].(map[string]interface{})[columnKey]
+		assert.Equal(t, []string{"任务三", "任务一", "任务二"}, cardTitles(cards), "位置超出时放到最后")
+	})
+
+	t.Run("按负责人和优先级分泳道", func(t *testing.T) {
+		lanes := getBoard("?swimlane=assignee")["swimlanes"].([]interface{})
+		require.Len(t, lanes, 2)
+		assert.Equal(t, "Alice", lanes[0].(map[string]interface{})["name"])
+		assert.Equal(t, "none", lanes[1].(map[string]interface{})["key"])
+
+		lanes = getBoard("?swimlane=priority")["swimlanes"].([]interface{})
+		keys := []string{}
+		for _, lane := range lanes {
+			keys = append(keys, lane.(map[string]interface{})["key"].(string))
+		}
+		assert.Equal(t, []string{"urgent", "high", "low"}, keys)
+	})
+}
+
+// readBoardEvent 读取一条看板事件
+func readBoardEvent(t *testing.T, conn *gorillaws.Conn) map[string]interface{} {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var message map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "board", message["type"])
+	return message["data"].(map[string]interface{})
+}
+
+func TestBoardHandler_LiveUpdates(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "实时看板项目")
+	admin := CreateTestUser(t, db, "liveadmin", "看板管理员")
+	developer := CreateTestUser(t, db, "livedeveloper", "看板开发")
+	AddUserToProject(t, db, developer.ID, project.ID, "member")
+	board := &model.Board{Name: "实时看板", ProjectID: project.ID, CardTypes: "task"}
+	require.NoError(t, db.Create(board).Error)
+	todo := &model.BoardColumn{Name: "待办", BoardID: board.ID, Status: "wait", Sort: 1}
+	doing := &model.BoardColumn{Name: "进行中", BoardID: board.ID, Status: "doing", Sort: 2}
+	require.NoError(t, db.Create(todo).Error)
+	require.NoError(t, db.Create(doing).Error)
+
+	task := &model.Task{Title: "实时任务", ProjectID: project.ID, CreatorID: admin.ID, Status: "wait"}
+	secret := &model.Task{Title: "受限任务", ProjectID: project.ID, CreatorID: admin.ID, Status: "wait", Confidential: true}
+	require.NoError(t, db.Create(task).Error)
+	require.NoError(t, db.Create(secret).Error)
+
+	// 初始化JWT配置
+	if config.AppConfig == nil {
+		config.AppConfig = &config.Config{}
+	}
+	jwtConfig := config.AppConfig.JWT
+	config.AppConfig.JWT = config.JWTConfig{Secret: "test-secret-key-for-unit-testing", Expiration: 24}
+	defer func() { config.AppConfig.JWT = jwtConfig }()
+
+	handler := api.NewBoardHandler(db)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("db", db)
+		c.Next()
+	})
+	r.GET("/ws/boards/:id", middleware.TokenFromQuery(), middleware.Auth(), handler.SubscribeBoard)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	topic := websocket.BoardTopic(board.ID)
+	subscribe := func(userID uint, username string, roles []string) *gorillaws.Conn {
+		before := websocket.GetHub().SubscriberCount(topic)
+		token, err := auth.GenerateToken(userID, username, roles)
+		require.NoError(t, err)
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + fmt.Sprintf("/ws/boards/%d?token=%s", board.ID, token)
+		conn, _, err := gorillaws.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return websocket.GetHub().SubscriberCount(topic) > before }, time.Second, 10*time.Millisecond)
+		return conn
+	}
+
+	t.Run("未认证不能订阅", func(t *testing.T) {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + fmt.Sprintf("/ws/boards/%d", board.ID)
+		_, _, err := gorillaws.DefaultDialer.Dial(url, nil)
+		assert.Error(t, err)
+	})
+
+	adminConn := subscribe(admin.ID, admin.Username, []string{"admin"})
+	defer adminConn.Close()
+	developerConn := subscribe(developer.ID, developer.Username, []string{"developer"})
+	defer developerConn.Close()
+
+	t.Run("移动卡片广播给订阅者", func(t *testing.T) {
+		response := moveBoardCardWithBody(t, handler, board.ID, "task", task.ID, map[string]interface{}{"column_id": fmt.Sprintf("%d", doing.ID), "version": 0})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, float64(1), response["data"].(map[string]interface{})["version"])
+
+		for _, conn := range []*gorillaws.Conn{adminConn, developerConn} {
+			event := readBoardEvent(t, conn)
+			assert.Equal(t, "card.moved", event["event"])
+			assert.Equal(t, float64(task.ID), event["object_id"])
+			assert.Equal(t, float64(doing.ID), event["column_id"])
+			assert.Equal(t, "doing", event["status"])
+			assert.Equal(t, float64(1), event["version"])
+			assert.NotEmpty(t, event["rank"])
+		}
+	})
+
+	t.Run("版本号过期的移动返回冲突", func(t *testing.T) {
+		response := moveBoardCardWithBody(t, handler, board.ID, "task", task.ID, map[string]interface{}{"column_id": fmt.Sprintf("%d", todo.ID), "version": 0})
+		assert.Equal(t, float64(409), response["code"])
+		assert.Equal(t, float64(1), response["data"].(map[string]interface{})["version"])
+
+		var updated model.Task
+		require.NoError(t, db.First(&updated, task.ID).Error)
+		assert.Equal(t, "doing", updated.Status)
+	})
+
+	t.Run("修改任务广播更新事件并递增版本号", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPatch, "/api/tasks/status", bytes.NewBufferString(`{"status":"done"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", task.ID)}}
+		c.Set("user_id", admin.ID)
+		c.Set("roles", []string{"admin"})
+		api.NewTaskHandler(db).UpdateTaskStatus(c)
+
+		event := readBoardEvent(t, adminConn)
+		assert.Equal(t, "card.updated", event["event"])
+		assert.Equal(t, "done", event["status"])
+		assert.Equal(t, float64(2), event["version"])
+		readBoardEvent(t, developerConn)
+	})
+
+	t.Run("受限卡片的事件只发送给可以查看的用户", func(t *testing.T) {
+		response := moveBoardCard(t, handler, board.ID, "task", secret.ID, doing.ID, nil)
+		require.Equal(t, float64(200), response["code"])
+		response = moveBoardCard(t, handler, board.ID, "task", task.ID, doing.ID, nil)
+		require.Equal(t, float64(200), response["code"])
+
+		assert.Equal(t, float64(secret.ID), readBoardEvent(t, adminConn)["object_id"])
+		assert.Equal(t, float64(task.ID), readBoardEvent(t, adminConn)["object_id"])
+		// 开发人员看不到受限任务，收到的下一条事件是普通任务的移动
+		assert.Equal(t, float64(task.ID), readBoardEvent(t, developerConn)["object_id"])
+	})
+
+	t.Run("项目成员角色变更后重新判断可见性", func(t *testing.T) {
+		require.NoError(t, db.Create(&model.ItemAccess{ObjectType: "task", ObjectID: secret.ID, RoleCode: "live-reviewer", CreatorID: admin.ID}).Error)
+		var member model.ProjectMember
+		require.NoError(t, db.Where("project_id = ? AND user_id = ?", project.ID, developer.ID).First(&member).Error)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/api/projects/members", bytes.NewBufferString(`{"role":"live-reviewer"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", project.ID)}, {Key: "member_id", Value: fmt.Sprintf("%d", member.ID)}}
+		c.Set("user_id", admin.ID)
+		c.Set("roles", []string{"admin"})
+		api.NewProjectHandler(db).UpdateProjectMember(c)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		response := moveBoardCard(t, handler, board.ID, "task", secret.ID, todo.ID, nil)
+		require.Equal(t, float64(200), response["code"])
+		assert.Equal(t, float64(secret.ID), readBoardEvent(t, adminConn)["object_id"])
+		assert.Equal(t, float64(secret.ID), readBoardEvent(t, developerConn)["object_id"])
+	})
+}
@@ -0,0 +1,220 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func newConfidentialContext(userID uint, roles ...string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if len(roles) == 0 {
+		roles = []string{"developer"}
+	}
+	c.Set("user_id", userID)
+	c.Set("username", fmt.Sprintf("user%d", userID))
+	c.Set("roles", roles)
+	return c, w
+}
+
+func TestConfidential_BugVisibility(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	creator := CreateTestUser(t, db, "confcreator", "创建人")
+	assignee := CreateTestUser(t, db, "confassignee", "分配人")
+	granted := CreateTestUser(t, db, "confgranted", "授权用户")
+	lead := CreateTestUser(t, db, "conflead", "负责人")
+	member := CreateTestUser(t, db, "confmember", "普通成员")
+	project := CreateTestProject(t, db, "保密项目")
+	for _, u := range []*model.User{creator, assignee, granted, member} {
+		AddUserToProject(t, db, u.ID, project.ID, "开发")
+	}
+	AddUserToProject(t, db, lead.ID, project.ID, "项目经理")
+
+	publicBug := &model.Bug{Title: "公开Bug", ProjectID: project.ID, CreatorID: creator.ID, Status: "active"}
+	secretBug := &model.Bug{Title: "安全漏洞", ProjectID: project.ID, CreatorID: creator.ID, Status: "active", Confidential: true}
+	require.NoError(t, db.Create(publicBug).Error)
+	require.NoError(t, db.Create(secretBug).Error)
+	require.NoError(t, db.Create(&model.BugAssignee{BugID: secretBug.ID, UserID: assignee.ID}).Error)
+	require.NoError(t, db.Create(&model.ItemAccess{ObjectType: "bug", ObjectID: secretBug.ID, UserID: &granted.ID, CreatorID: creator.ID}).Error)
+	require.NoError(t, db.Create(&model.ItemAccess{ObjectType: "bug", ObjectID: secretBug.ID, RoleCode: "owner", CreatorID: creator.ID}).Error)
+
+	visibleTitles := func(userID uint, roles ...string) []string {
+		c, _ := newConfidentialContext(userID, roles...)
+		var bugs []model.Bug
+		require.NoError(t, utils.FilterBugsByUser(db, c, db.Model(&model.Bug{})).Order("id").Find(&bugs).Error)
+		titles := make([]string, 0, len(bugs))
+		for _, bug := range bugs {
+			titles = append(titles, bug.Title)
+		}
+		return titles
+	}
+
+	t.Run("普通成员看不到保密Bug", func(t *testing.T) {
+		assert.Equal(t, []string{"公开Bug"}, visibleTitles(member.ID))
+		c, _ := newConfidentialContext(member.ID)
+		assert.False(t, utils.CheckBugAccess(db, c, secretBug.ID))
+		assert.True(t, utils.CheckBugAccess(db, c, publicBug.ID))
+	})
+
+	t.Run("创建人、分配人和访问名单用户可以看到", func(t *testing.T) {
+		for _, u := range []*model.User{creator, assignee, granted} {
+			assert.Equal(t, []string{"公开Bug", "安全漏洞"}, visibleTitles(u.ID), u.Username)
+			c, _ := newConfidentialContext(u.ID)
+			assert.True(t, utils.CheckBugAccess(db, c, secretBug.ID), u.Username)
+		}
+	})
+
+	t.Run("按项目角色授权", func(t *testing.T) {
+		assert.Equal(t, []string{"公开Bug", "安全漏洞"}, visibleTitles(lead.ID))
+	})
+
+	t.Run("管理员可以看到所有Bug", func(t *testing.T) {
+		assert.Len(t, visibleTitles(member.ID, "admin"), 2)
+	})
+}
+
+func TestConfidential_GetBugAudit(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+	require.NoError(t, db.AutoMigrate(&model.AuditLog{}))
+
+	creator := CreateTestUser(t, db, "auditcreator", "创建人")
+	member := CreateTestUser(t, db, "auditmember", "普通成员")
+	project := CreateTestProject(t, db, "审计项目")
+	AddUserToProject(t, db, creator.ID, project.ID, "开发")
+	AddUserToProject(t, db, member.ID, project.ID, "开发")
+
+	bug := &model.Bug{Title: "保密Bug", ProjectID: project.ID, CreatorID: creator.ID, Status: "active", Confidential: true}
+	require.NoError(t, db.Create(bug).Error)
+
+	handler := api.NewBugHandler(db)
+	getBug := func(userID uint) float64 {
+		c, w := newConfidentialContext(userID)
+		c.Params = gin.Params{gin.Param{Key: "id", Value: fmt.Sprintf("%d", bug.ID)}}
+		handler.GetBug(c)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["code"].(float64)
+	}
+
+	assert.Equal(t, float64(403), getBug(member.ID))
+	assert.Equal(t, float64(200), getBug(creator.ID))
+
+	var logs []model.AuditLog
+	require.NoError(t, db.Where("action_type = ? AND resource_type = ?", "view_restricted", "bug").Find(&logs).Error)
+	require.Len(t, logs, 1)
+	assert.Equal(t, creator.ID, logs[0].UserID)
+	assert.Equal(t, bug.ID, logs[0].ResourceID)
+}
+
+func TestConfidential_UpdateBugAccess(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	creator := CreateTestUser(t, db, "accesseditor", "创建人")
+	granted := CreateTestUser(t, db, "accessgrantee", "授权用户")
+	project := CreateTestProject(t, db, "访问名单项目")
+	AddUserToProject(t, db, creator.ID, project.ID, "开发")
+	AddUserToProject(t, db, granted.ID, project.ID, "开发")
+
+	bug := &model.Bug{Title: "待保密Bug", ProjectID: project.ID, CreatorID: creator.ID, Status: "active"}
+	require.NoError(t, db.Create(bug).Error)
+
+	handler := api.NewItemAccessHandler(db)
+	body, _ := json.Marshal(map[string]interface{}{
+		"confidential": true,
+		"user_ids":     []uint{granted.ID, granted.ID},
+		"role_codes":   []string{"owner", " "},
+	})
+	c, w := newConfidentialContext(creator.ID)
+	c.Request = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/bugs/%d/access", bug.ID), bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{gin.Param{Key: "id", Value: fmt.Sprintf("%d", bug.ID)}}
+	handler.UpdateBugAccess(c)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, float64(200), response["code"])
+	data := response["data"].(map[string]interface{})
+	assert.Equal(t, true, data["confidential"])
+	assert.Len(t, data["users"], 1)
+	assert.Equal(t, []interface{}{"owner"}, data["role_codes"])
+
+	var updated model.Bug
+	require.NoError(t, db.First(&updated, bug.ID).Error)
+	assert.True(t, updated.Confidential)
+
+	var historyCount int64
+	db.Model(&model.History{}).Where("field IN ?", []string{"confidential", "access_user_ids", "access_role_codes"}).Count(&historyCount)
+	assert.Equal(t, int64(3), historyCount)
+
+	grantedCtx, _ := newConfidentialContext(granted.ID)
+	assert.True(t, utils.CheckBugAccess(db, grantedCtx, bug.ID))
+
+	// 只修改访问名单时保持受限标记
+	body, _ = json.Marshal(map[string]interface{}{"user_ids": []uint{}})
+	c, w = newConfidentialContext(creator.ID)
+	c.Request = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/bugs/%d/access", bug.ID), bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{gin.Param{Key: "id", Value: fmt.Sprintf("%d", bug.ID)}}
+	handler.UpdateBugAccess(c)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, float64(200), response["code"])
+	assert.Equal(t, true, response["data"].(map[string]interface{})["confidential"])
+	require.NoError(t, db.First(&updated, bug.ID).Error)
+	assert.True(t, updated.Confidential, "未提交受限标记时不改为公开")
+	assert.False(t, utils.CheckBugAccess(db, grantedCtx, bug.ID))
+}
+
+func TestConfidential_PreloadedRelations(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	creator := CreateTestUser(t, db, "preloadcreator", "创建人")
+	member := CreateTestUser(t, db, "preloadmember", "普通成员")
+	project := CreateTestProject(t, db, "关联保密项目")
+	AddUserToProject(t, db, creator.ID, project.ID, "开发")
+	AddUserToProject(t, db, member.ID, project.ID, "开发")
+
+	requirement := &model.Requirement{Title: "保密需求", ProjectID: project.ID, CreatorID: creator.ID, Status: "active", Confidential: true}
+	require.NoError(t, db.Create(requirement).Error)
+	secretTask := &model.Task{Title: "保密任务", ProjectID: project.ID, CreatorID: creator.ID, Status: "wait", Confidential: true}
+	require.NoError(t, db.Create(secretTask).Error)
+	task := &model.Task{Title: "公开任务", ProjectID: project.ID, CreatorID: creator.ID, Status: "wait", RequirementID: &requirement.ID}
+	require.NoError(t, db.Create(task).Error)
+	require.NoError(t, db.Model(task).Association("Dependencies").Append(secretTask))
+
+	getTask := func(userID uint) map[string]interface{} {
+		c, w := newConfidentialContext(userID)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", task.ID)}}
+		api.NewTaskHandler(db).GetTask(c)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		return response["data"].(map[string]interface{})
+	}
+
+	data := getTask(member.ID)
+	assert.Nil(t, data["requirement"], "看不到的保密需求不随任务返回")
+	assert.Empty(t, data["dependencies"], "看不到的保密任务不作为依赖返回")
+
+	data = getTask(creator.ID)
+	assert.Equal(t, "保密需求", data["requirement"].(map[string]interface{})["title"])
+	assert.Len(t, data["dependencies"], 1)
+}
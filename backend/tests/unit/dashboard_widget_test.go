@@ -0,0 +1,134 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+)
+
+func TestDashboardHandler_Widgets(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	user := CreateTestUser(t, db, "widgetuser", "组件用户")
+	outsider := CreateTestUser(t, db, "widgetoutsider", "项目外用户")
+	admin := CreateTestUser(t, db, "widgetadmin", "管理员")
+	project := CreateTestProject(t, db, "组件项目")
+	other := CreateTestProject(t, db, "其他项目")
+	AddUserToProject(t, db, user.ID, project.ID, "member")
+
+	for _, status := range []string{"wait", "wait", "doing", "done"} {
+		require.NoError(t, db.Create(&model.Task{Title: "任务-" + status, ProjectID: project.ID, CreatorID: user.ID, AssigneeID: &user.ID, Status: status}).Error)
+	}
+	require.NoError(t, db.Create(&model.Task{Title: "其他项目任务", ProjectID: other.ID, CreatorID: admin.ID, Status: "wait"}).Error)
+
+	handler := api.NewDashboardHandler(db)
+	developer := []string{"developer"}
+	widgetByID := func(response map[string]interface{}, id string) map[string]interface{} {
+		for _, item := range response["data"].(map[string]interface{})["widgets"].([]interface{}) {
+			if widget := item.(map[string]interface{}); widget["id"] == id {
+				return widget
+			}
+		}
+		return nil
+	}
+
+	t.Run("组件注册表", func(t *testing.T) {
+		response := callProgramHandler(t, handler.GetDashboardWidgets, user.ID, developer, http.MethodGet, nil, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		names := make([]string, 0)
+		for _, item := range response["data"].([]interface{}) {
+			names = append(names, item.(map[string]interface{})["name"].(string))
+		}
+		assert.Contains(t, names, "my_tasks")
+		assert.Contains(t, names, "item_count")
+	})
+
+	t.Run("工作台只计算配置的组件并缓存", func(t *testing.T) {
+		response := callProgramHandler(t, handler.SaveDashboardConfig, user.ID, developer, http.MethodPost, nil, map[string]interface{}{
+			"widgets": []map[string]interface{}{{"widget": "unknown"}},
+		})
+		assert.Equal(t, float64(400), response["code"], "未注册的组件")
+
+		response = callProgramHandler(t, handler.SaveDashboardConfig, user.ID, developer, http.MethodPost, nil, map[string]interface{}{
+			"widgets": []map[string]interface{}{
+				{"widget": "my_tasks"},
+				{"widget": "item_count", "title": "未完成任务", "params": map[string]interface{}{
+					"project_id": project.ID, "filter": map[string]interface{}{"type": "task", "status": []string{"wait", "doing"}},
+				}},
+				{"widget": "item_count", "params": map[string]interface{}{
+					"project_id": other.ID, "filter": map[string]interface{}{"type": "task"},
+				}},
+			},
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+
+		response = callProgramHandler(t, handler.GetDashboard, user.ID, developer, http.MethodGet, nil, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Nil(t, response["data"].(map[string]interface{})["tasks"], "配置组件后不再计算固定统计")
+
+		myTasks := widgetByID(response, "w1")
+		require.NotNil(t, myTasks)
+		assert.Equal(t, "我的任务", myTasks["title"])
+		assert.Equal(t, float64(2), myTasks["data"].(map[string]interface{})["todo"])
+		assert.Equal(t, false, myTasks["cached"])
+
+		open := widgetByID(response, "w2")
+		require.NotNil(t, open)
+		assert.Equal(t, float64(3), open["data"].(map[string]interface{})["total"])
+
+		forbidden := widgetByID(response, "w3")
+		require.NotNil(t, forbidden)
+		assert.NotEmpty(t, forbidden["error"], "无权访问的项目")
+		assert.Nil(t, forbidden["data"])
+
+		require.NoError(t, db.Create(&model.Task{Title: "新任务", ProjectID: project.ID, CreatorID: user.ID, AssigneeID: &user.ID, Status: "wait"}).Error)
+		response = callProgramHandler(t, handler.GetDashboard, user.ID, developer, http.MethodGet, nil, nil)
+		myTasks = widgetByID(response, "w1")
+		assert.Equal(t, true, myTasks["cached"])
+		assert.Equal(t, float64(2), myTasks["data"].(map[string]interface{})["todo"], "缓存有效期内返回缓存数据")
+	})
+
+	t.Run("项目仪表板", func(t *testing.T) {
+		response := callProgramHandler(t, handler.CreateSharedDashboard, user.ID, developer, http.MethodPost, nil, map[string]interface{}{
+			"name": "团队看板", "scope": "team",
+		})
+		assert.Equal(t, float64(403), response["code"], "只有管理员可以创建团队仪表板")
+
+		response = callProgramHandler(t, handler.CreateSharedDashboard, admin.ID, []string{"admin"}, http.MethodPost, nil, map[string]interface{}{
+			"name": "项目看板", "scope": "project", "project_id": project.ID,
+			"widgets": []map[string]interface{}{
+				{"widget": "item_list", "params": map[string]interface{}{"filter": map[string]interface{}{"type": "task", "status": []string{"doing"}}}},
+			},
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		dashboardID := uint(response["data"].(map[string]interface{})["id"].(float64))
+		params := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", dashboardID)}}
+
+		response = callProgramHandler(t, handler.GetSharedDashboard, user.ID, developer, http.MethodGet, params, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		widget := widgetByID(response, "w1")
+		require.NotNil(t, widget)
+		assert.Equal(t, float64(project.ID), widget["params"].(map[string]interface{})["project_id"], "默认使用仪表板所属项目")
+		list := widget["data"].(map[string]interface{})["list"].([]interface{})
+		require.Len(t, list, 1)
+		assert.Equal(t, "任务-doing", list[0].(map[string]interface{})["title"])
+
+		response = callProgramHandler(t, handler.GetSharedDashboard, outsider.ID, developer, http.MethodGet, params, nil)
+		assert.Equal(t, float64(403), response["code"])
+		response = callProgramHandler(t, handler.GetSharedDashboards, outsider.ID, developer, http.MethodGet, nil, nil)
+		assert.Len(t, response["data"].([]interface{}), 0)
+
+		response = callProgramHandler(t, handler.UpdateSharedDashboard, user.ID, developer, http.MethodPut, params, map[string]interface{}{"name": "改名"})
+		assert.Equal(t, float64(403), response["code"], "普通成员不能修改项目仪表板")
+		response = callProgramHandler(t, handler.DeleteSharedDashboard, admin.ID, []string{"admin"}, http.MethodDelete, params, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+	})
+}
@@ -0,0 +1,236 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/config"
+	"prjflow/internal/middleware"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestDeploymentHandler_TrackBuildsAndEnvironments(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "部署项目")
+	user := CreateTestUser(t, db, "deployer", "部署人员")
+	AddUserToProject(t, db, user.ID, project.ID, "owner")
+	v1 := &model.Version{VersionNumber: "v1.0", ProjectID: project.ID}
+	v2 := &model.Version{VersionNumber: "v1.1", ProjectID: project.ID}
+	require.NoError(t, db.Create(v1).Error)
+	require.NoError(t, db.Create(v2).Error)
+
+	handler := api.NewDeploymentHandler(db)
+	roles := []string{"developer"}
+	idParams := func(id uint) gin.Params { return gin.Params{{Key: "id", Value: fmt.Sprintf("%d", id)}} }
+
+	response := callProgramHandler(t, handler.CreateEnvironment, user.ID, roles, http.MethodPost, idParams(project.ID), map[string]interface{}{"init": true})
+	require.Equal(t, float64(200), response["code"], response["message"])
+	require.Len(t, response["data"], 4)
+	response = callProgramHandler(t, handler.CreateEnvironment, user.ID, roles, http.MethodPost, idParams(project.ID), map[string]interface{}{"name": "生产", "code": "prod"})
+	assert.Equal(t, float64(400), response["code"], "环境编码在项目内唯一")
+
+	environment := func(code string) model.Environment {
+		var env model.Environment
+		require.NoError(t, db.Where("project_id = ? AND code = ?", project.ID, code).First(&env).Error)
+		return env
+	}
+	test, prod := environment("test"), environment("prod")
+
+	createBuild := func(version *model.Version, number string) uint {
+		response := callProgramHandler(t, handler.CreateBuild, user.ID, roles, http.MethodPost, idParams(version.ID), map[string]interface{}{
+			"build_number": number, "commit_sha": "abc123",
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		return uint(response["data"].(map[string]interface{})["id"].(float64))
+	}
+	build1 := createBuild(v1, "100")
+	build2 := createBuild(v2, "101")
+	response = callProgramHandler(t, handler.CreateBuild, user.ID, roles, http.MethodPost, idParams(v1.ID), map[string]interface{}{"build_number": "100"})
+	assert.Equal(t, float64(400), response["code"], "同一版本内构建号不能重复")
+
+	deploy := func(env model.Environment, buildID uint, status, deployedAt string) {
+		response := callProgramHandler(t, handler.CreateDeployment, user.ID, roles, http.MethodPost, idParams(env.ID), map[string]interface{}{
+			"build_id": buildID, "status": status, "deployed_at": deployedAt,
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+	}
+	deploy(prod, build1, "success", "2026-10-01T10:00:00Z")
+	deploy(test, build1, "success", "2026-10-01T09:00:00Z")
+	deploy(test, build2, "success", "2026-10-05T09:00:00Z")
+	deploy(prod, build2, "failed", "2026-10-06T09:00:00Z")
+
+	t.Run("环境列表显示当前成功部署的构建", func(t *testing.T) {
+		response := callProgramHandler(t, handler.GetEnvironments, user.ID, roles, http.MethodGet, idParams(project.ID), nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		current := make(map[string]interface{})
+		for _, item := range response["data"].([]interface{}) {
+			entry := item.(map[string]interface{})
+			current[entry["environment"].(map[string]interface{})["code"].(string)] = entry["current_deployment"]
+		}
+		assert.Nil(t, current["dev"])
+		assert.Equal(t, float64(build2), current["test"].(map[string]interface{})["build_id"])
+		assert.Equal(t, float64(build1), current["prod"].(map[string]interface{})["build_id"], "部署失败不改变当前构建")
+	})
+
+	t.Run("查询包含Bug修复的环境", func(t *testing.T) {
+		bug := &model.Bug{Title: "登录失败", ProjectID: project.ID, CreatorID: user.ID, Status: "resolved", ResolvedVersionID: &v2.ID}
+		require.NoError(t, db.Create(bug).Error)
+
+		response := callProgramHandler(t, handler.GetBugEnvironments, user.ID, roles, http.MethodGet, idParams(bug.ID), nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		fixed := make(map[string]bool)
+		for _, item := range response["data"].(map[string]interface{})["environments"].([]interface{}) {
+			entry := item.(map[string]interface{})
+			fixed[entry["environment"].(map[string]interface{})["code"].(string)] = entry["contains_fix"].(bool)
+			if entry["environment"].(map[string]interface{})["code"] == "test" {
+				assert.Contains(t, entry["fix_deployed_at"], "2026-10-05")
+			}
+		}
+		assert.Equal(t, map[string]bool{"dev": false, "test": true, "staging": false, "prod": false}, fixed)
+	})
+
+	t.Run("按版本号而不是创建顺序判断是否包含修复", func(t *testing.T) {
+		bug := &model.Bug{Title: "导出超时", ProjectID: project.ID, CreatorID: user.ID, Status: "resolved", ResolvedVersionID: &v2.ID}
+		require.NoError(t, db.Create(bug).Error)
+		// 解决版本之后才创建的旧分支补丁版本不包含修复，更高的版本包含修复
+		hotfix := &model.Version{VersionNumber: "v1.0.1", ProjectID: project.ID}
+		next := &model.Version{VersionNumber: "v1.2", ProjectID: project.ID}
+		require.NoError(t, db.Create(hotfix).Error)
+		require.NoError(t, db.Create(next).Error)
+		deploy(environment("staging"), createBuild(hotfix, "102"), "success", "2026-10-07T09:00:00Z")
+		deploy(environment("dev"), createBuild(next, "103"), "success", "2026-10-07T10:00:00Z")
+
+		response := callProgramHandler(t, handler.GetBugEnvironments, user.ID, roles, http.MethodGet, idParams(bug.ID), nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		fixed := make(map[string]bool)
+		for _, item := range response["data"].(map[string]interface{})["environments"].([]interface{}) {
+			entry := item.(map[string]interface{})
+			fixed[entry["environment"].(map[string]interface{})["code"].(string)] = entry["contains_fix"].(bool)
+		}
+		assert.Equal(t, map[string]bool{"dev": true, "test": true, "staging": false, "prod": false}, fixed)
+
+		// Bug 关联的是发现问题的版本，部署该版本的环境不包含修复
+		require.NoError(t, db.Model(bug).Association("Versions").Append(hotfix))
+		response = callProgramHandler(t, handler.GetBugEnvironments, user.ID, roles, http.MethodGet, idParams(bug.ID), nil)
+		for _, item := range response["data"].(map[string]interface{})["environments"].([]interface{}) {
+			entry := item.(map[string]interface{})
+			if entry["environment"].(map[string]interface{})["code"] == "staging" {
+				assert.Equal(t, false, entry["contains_fix"])
+			}
+		}
+	})
+
+	t.Run("已部署的构建不能删除", func(t *testing.T) {
+		response := callProgramHandler(t, handler.DeleteBuild, user.ID, roles, http.MethodDelete, idParams(build1), nil)
+		assert.Equal(t, float64(400), response["code"])
+	})
+
+	t.Run("按环境筛选部署记录", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/projects/%d/deployments?environment_id=%d", project.ID, prod.ID), nil)
+		c.Params = idParams(project.ID)
+		c.Set("user_id", user.ID)
+		handler.GetDeployments(c)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(2), data["total"])
+		assert.Equal(t, "failed", data["list"].([]interface{})[0].(map[string]interface{})["status"])
+	})
+}
+
+func TestDeploymentHandler_CI(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	if config.AppConfig == nil {
+		config.AppConfig = &config.Config{}
+	}
+	previous := config.AppConfig.Upload
+	config.AppConfig.Upload = config.UploadConfig{StoragePath: t.TempDir(), MaxFileSize: 10 * 1024 * 1024}
+	defer func() { config.AppConfig.Upload = previous }()
+
+	project := CreateTestProject(t, db, "CI部署项目")
+	owner := CreateTestUser(t, db, "cideployer", "CI部署")
+	AddUserToProject(t, db, owner.ID, project.ID, "owner")
+	require.NoError(t, db.Create(&model.Environment{Name: "生产环境", Code: "prod", ProjectID: project.ID}).Error)
+
+	plain, hash, err := utils.GenerateAPIToken()
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&model.APIToken{Name: "CI", TokenHash: hash, Scopes: model.StringArray{model.APITokenScopeDeployments}, ProjectID: project.ID, CreatorID: owner.ID}).Error)
+
+	handler := api.NewDeploymentHandler(db)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/ci/builds", middleware.APITokenAuth(db, model.APITokenScopeDeployments), handler.CIRegisterBuild)
+	router.POST("/api/ci/deployments", middleware.APITokenAuth(db, model.APITokenScopeDeployments), handler.CIRecordDeployment)
+	router.POST("/api/ci/test-results", middleware.APITokenAuth(db, model.APITokenScopeTestResults), api.NewTestRunHandler(db).UploadTestReport)
+	send := func(path, contentType string, body *bytes.Buffer) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, body)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Token "+plain)
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("CI登记构建并上传构建产物", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		require.NoError(t, writer.WriteField("commit_sha", "deadbeef"))
+		part, err := writer.CreateFormFile("artifacts", "app.tar.gz")
+		require.NoError(t, err)
+		part.Write([]byte("artifact"))
+		require.NoError(t, writer.Close())
+
+		response := send("/api/ci/builds?version=v2.0&build_number=42", writer.FormDataContentType(), body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, true, data["created"])
+		assert.Equal(t, true, data["version_created"])
+		build := data["build"].(map[string]interface{})
+		assert.Equal(t, "ci", build["source"])
+		assert.Equal(t, "deadbeef", build["commit_sha"])
+		require.Len(t, build["attachments"], 1)
+
+		response = send("/api/ci/builds?version=v2.0&build_number=42&commit_sha=cafe", "application/json", &bytes.Buffer{})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, false, response["data"].(map[string]interface{})["created"], "重复上报同一构建时更新而不是新建")
+	})
+
+	t.Run("CI记录部署", func(t *testing.T) {
+		body := bytes.NewBufferString(`{"environment":"prod","version":"v2.0","build_number":"42"}`)
+		response := send("/api/ci/deployments", "application/json", body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, "success", response["data"].(map[string]interface{})["status"])
+
+		body = bytes.NewBufferString(`{"environment":"prod","version":"v9","build_number":"42"}`)
+		assert.Equal(t, float64(404), send("/api/ci/deployments", "application/json", body)["code"], "部署时不自动创建版本")
+
+		var actions int64
+		db.Model(&model.Action{}).Where("object_type = ? AND action = ?", "version", "deployed").Count(&actions)
+		assert.Equal(t, int64(1), actions)
+	})
+
+	t.Run("令牌权限范围不足", func(t *testing.T) {
+		assert.Equal(t, float64(403), send("/api/ci/test-results", "text/plain", bytes.NewBufferString("ok 1"))["code"])
+	})
+}
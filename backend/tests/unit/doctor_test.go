@@ -0,0 +1,178 @@
+package unit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// doctorIssueObjects 按检查名称汇总报告中的问题对象
+func doctorIssueObjects(t *testing.T, report *utils.DoctorReport) map[string][]string {
+	objects := make(map[string][]string)
+	for _, result := range report.Results {
+		assert.Empty(t, result.Error, "检查 %s 执行失败", result.Name)
+		for _, issue := range result.Issues {
+			objects[result.Name] = append(objects[result.Name], issue.Object)
+		}
+	}
+	return objects
+}
+
+func TestDoctorFindAndRepair(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	oldUpload, oldStorage := config.AppConfig.Upload, config.AppConfig.Storage
+	defer func() {
+		config.AppConfig.Upload, config.AppConfig.Storage = oldUpload, oldStorage
+	}()
+	uploadDir := t.TempDir()
+	config.AppConfig.Upload = config.UploadConfig{StoragePath: uploadDir, MaxFileSize: 10 * 1024 * 1024}
+	config.AppConfig.Storage = config.StorageConfig{Type: "local"}
+	old := time.Now().Add(-2 * time.Hour)
+
+	project := CreateTestProject(t, db, "数据检查项目")
+	member := CreateTestUser(t, db, "doctormember", "成员")
+	outsider := CreateTestUser(t, db, "doctoroutsider", "非成员")
+
+	// 工时和进度：资源分配 5 小时，任务记录的实际工时和进度没有更新
+	estimated := 10.0
+	task := model.Task{Title: "工时不一致", ProjectID: project.ID, Status: "wait", EstimatedHours: &estimated}
+	require.NoError(t, db.Create(&task).Error)
+	resource := model.Resource{UserID: member.ID, ProjectID: project.ID}
+	require.NoError(t, db.Create(&resource).Error)
+	require.NoError(t, db.Create(&model.ResourceAllocation{ResourceID: resource.ID, Date: time.Now(), Hours: 5, TaskID: &task.ID}).Error)
+
+	// 重复的项目成员，以及不是项目成员的人员资源
+	AddUserToProject(t, db, member.ID, project.ID, "member")
+	owner := AddUserToProject(t, db, member.ID, project.ID, "owner")
+	strayResource := model.Resource{UserID: outsider.ID, ProjectID: project.ID}
+	require.NoError(t, db.Create(&strayResource).Error)
+
+	// 指向不存在附件的关联
+	require.NoError(t, db.Exec("INSERT INTO project_attachments (project_id, attachment_id) VALUES (?, ?)", project.ID, 99999).Error)
+
+	// 没有关联任何对象的附件，以及引用数错误的文件内容
+	orphanBlob, _, err := utils.StoreAttachmentContent(db, bytes.NewReader([]byte("orphan")), "orphan.txt", "text/plain")
+	require.NoError(t, err)
+	orphan := model.Attachment{FileName: "orphan.txt", FilePath: orphanBlob.FilePath, FileSize: orphanBlob.FileSize, ContentHash: orphanBlob.Hash, CreatorID: member.ID}
+	require.NoError(t, db.Create(&orphan).Error)
+	require.NoError(t, db.Model(&orphan).UpdateColumn("created_at", old).Error)
+
+	linkedBlob, _, err := utils.StoreAttachmentContent(db, bytes.NewReader([]byte("linked")), "linked.txt", "text/plain")
+	require.NoError(t, err)
+	linked := model.Attachment{FileName: "linked.txt", FilePath: linkedBlob.FilePath, FileSize: linkedBlob.FileSize, ContentHash: linkedBlob.Hash, CreatorID: member.ID}
+	require.NoError(t, db.Create(&linked).Error)
+	require.NoError(t, db.Model(&linked).Association("Projects").Append(project))
+	require.NoError(t, db.Model(&model.AttachmentBlob{}).Where("hash = ?", linkedBlob.Hash).UpdateColumn("ref_count", 3).Error)
+
+	// 存储中没有被引用的文件（刚写入的文件可能还在上传，不处理）
+	strayFile := filepath.Join(uploadDir, "2026", "01", "02", "stray.bin")
+	require.NoError(t, os.MkdirAll(filepath.Dir(strayFile), 0755))
+	require.NoError(t, os.WriteFile(strayFile, []byte("stray"), 0644))
+	require.NoError(t, os.Chtimes(strayFile, old, old))
+	recentFile := filepath.Join(uploadDir, "2026", "01", "02", "uploading.bin")
+	require.NoError(t, os.WriteFile(recentFile, []byte("recent"), 0644))
+
+	report, err := utils.RunDoctor(db, utils.DoctorOptions{})
+	require.NoError(t, err)
+	objects := doctorIssueObjects(t, report)
+	assert.Contains(t, objects["actual-hours"], fmt.Sprintf("tasks#%d", task.ID))
+	assert.Contains(t, objects["task-progress"], fmt.Sprintf("tasks#%d", task.ID))
+	assert.Equal(t, []string{fmt.Sprintf("projects#%d users#%d", project.ID, member.ID)}, objects["project-members"])
+	assert.Equal(t, []string{fmt.Sprintf("resources#%d", strayResource.ID)}, objects["resources"], "成员的资源不报告")
+	assert.Contains(t, objects["many2many"], "project_attachments.attachment_id")
+	assert.Equal(t, []string{fmt.Sprintf("attachments#%d", orphan.ID)}, objects["attachment-rows"])
+	assert.Equal(t, []string{"attachment_blobs#" + linkedBlob.Hash}, objects["attachment-refs"])
+	assert.Equal(t, []string{"2026/01/02/stray.bin"}, objects["attachment-files"])
+	assert.Equal(t, report.Issues, report.Fixable)
+
+	// dry-run：执行修复后回滚，数据和文件不变
+	report, err = utils.RunDoctor(db, utils.DoctorOptions{Repair: true, DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, report.Fixable, report.Fixed)
+	assert.Equal(t, 2, report.DeletedFiles, "孤立附件的文件和孤立文件")
+	var reloaded model.Task
+	require.NoError(t, db.First(&reloaded, task.ID).Error)
+	assert.Equal(t, 0.0, *reloaded.ActualHours)
+	assert.FileExists(t, strayFile)
+	assert.NoError(t, db.First(&model.Attachment{}, orphan.ID).Error)
+
+	// 修复
+	report, err = utils.RunDoctor(db, utils.DoctorOptions{Repair: true})
+	require.NoError(t, err)
+	assert.True(t, report.Repaired)
+	assert.Equal(t, 0, report.Unresolved())
+
+	require.NoError(t, db.First(&reloaded, task.ID).Error)
+	assert.Equal(t, 5.0, *reloaded.ActualHours)
+	assert.Equal(t, 50, reloaded.Progress)
+	assert.Equal(t, "doing", reloaded.Status)
+	var members []model.ProjectMember
+	require.NoError(t, db.Where("project_id = ? AND user_id = ?", project.ID, member.ID).Find(&members).Error)
+	require.Len(t, members, 1)
+	assert.Equal(t, owner.ID, members[0].ID, "保留项目负责人的成员记录")
+	assert.ErrorIs(t, db.First(&model.Resource{}, strayResource.ID).Error, gorm.ErrRecordNotFound)
+	assert.NoError(t, db.First(&model.Resource{}, resource.ID).Error)
+	assert.ErrorIs(t, db.Unscoped().First(&model.Attachment{}, orphan.ID).Error, gorm.ErrRecordNotFound)
+	assert.NoFileExists(t, filepath.Join(uploadDir, filepath.FromSlash(orphanBlob.FilePath)))
+	var blob model.AttachmentBlob
+	require.NoError(t, db.Where("hash = ?", linkedBlob.Hash).First(&blob).Error)
+	assert.Equal(t, 1, blob.RefCount)
+	assert.NoFileExists(t, strayFile)
+	assert.FileExists(t, recentFile)
+
+	report, err = utils.RunDoctor(db, utils.DoctorOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Issues, "修复后再次检查没有问题: %v", doctorIssueObjects(t, report))
+}
+
+func TestDoctorChecksAreSelectableAndPluggable(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	_, err := utils.RunDoctor(db, utils.DoctorOptions{Checks: []string{"no-such-check"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "actual-hours")
+
+	fixed := false
+	utils.RegisterDoctorCheck(utils.DoctorCheck{
+		Name:        "unit-test",
+		Description: "测试注册的检查",
+		Run: func(db *gorm.DB) ([]utils.DoctorIssue, error) {
+			return []utils.DoctorIssue{
+				utils.NewDoctorIssue("fixable", "可以修复", func(r *utils.DoctorRepair) error {
+					fixed = true
+					return nil
+				}),
+				utils.NewDoctorIssue("manual", "需要人工处理", nil),
+			}, nil
+		},
+	})
+	// 恢复为没有问题的检查，避免影响其他执行全部检查的测试
+	defer utils.RegisterDoctorCheck(utils.DoctorCheck{
+		Name: "unit-test",
+		Run:  func(db *gorm.DB) ([]utils.DoctorIssue, error) { return nil, nil },
+	})
+
+	report, err := utils.RunDoctor(db, utils.DoctorOptions{Checks: []string{"unit-test"}, Repair: true})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	assert.Equal(t, 2, report.Issues)
+	assert.Equal(t, 1, report.Fixed)
+	assert.True(t, fixed)
+	assert.Equal(t, 1, report.Unresolved(), "不能自动修复的问题仍需处理")
+	assert.Equal(t, "unit-test", report.Results[0].Issues[1].Check)
+}
@@ -0,0 +1,205 @@
+package unit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestParseGitReferences(t *testing.T) {
+	refs := utils.ParseGitReferences("Fix bug#12 and task #3, see story#4\n修复 bug#5；关联 req#6，fixes BUG#12")
+	assert.Equal(t, []utils.GitReference{
+		{ObjectType: "bug", ObjectID: 12, Close: true},
+		{ObjectType: "task", ObjectID: 3, Close: false},
+		{ObjectType: "requirement", ObjectID: 4, Close: false},
+		{ObjectType: "bug", ObjectID: 5, Close: true},
+		{ObjectType: "requirement", ObjectID: 6, Close: false},
+	}, refs)
+
+	assert.Empty(t, utils.ParseGitReferences("debug#1 bug#0 #42"))
+}
+
+// loadGitWebhookFixture 读取录制的 Webhook 载荷并替换其中的工作项ID占位符
+func loadGitWebhookFixture(t *testing.T, name string, values map[string]string) []byte {
+	data, err := os.ReadFile(filepath.Join("testdata", "git_webhooks", name))
+	require.NoError(t, err)
+	payload := string(data)
+	for key, value := range values {
+		payload = strings.ReplaceAll(payload, "{{"+key+"}}", value)
+	}
+	return []byte(payload)
+}
+
+func signGitWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestGitWebhookHandler_Receive(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "代码关联项目")
+	other := CreateTestProject(t, db, "其他项目")
+	owner := CreateTestUser(t, db, "gitowner", "仓库管理员")
+	alice := CreateTestUser(t, db, "alice", "Alice")
+	require.NoError(t, db.Model(alice).Update("email", "alice@example.com").Error)
+	AddUserToProject(t, db, owner.ID, project.ID, "owner")
+
+	handler := api.NewGitWebhookHandler(db)
+	response := callProgramHandler(t, handler.CreateProjectWebhook, owner.ID, []string{"developer"}, http.MethodPost,
+		gin.Params{{Key: "id", Value: fmt.Sprintf("%d", project.ID)}}, map[string]interface{}{"name": "shop"})
+	require.Equal(t, float64(200), response["code"], response["message"])
+	data := response["data"].(map[string]interface{})
+	secret := data["secret"].(string)
+	require.NotEmpty(t, secret)
+	webhookID := uint(data["webhook"].(map[string]interface{})["id"].(float64))
+	assert.Equal(t, fmt.Sprintf("/api/webhooks/git/%d", webhookID), data["url"])
+	_, hasSecret := data["webhook"].(map[string]interface{})["secret"]
+	assert.False(t, hasSecret, "Webhook 详情中不返回密钥")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/webhooks/git/:id", handler.ReceiveGitWebhook)
+	deliver := func(headers map[string]string, body []byte) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/webhooks/git/%d", webhookID), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	github := func(event string, body []byte) map[string]string {
+		return map[string]string{"X-GitHub-Event": event, "X-Hub-Signature-256": "sha256=" + signGitWebhook(secret, body)}
+	}
+	countActions := func(objectType string, objectID uint, action string) int64 {
+		var count int64
+		db.Model(&model.Action{}).Where("object_type = ? AND object_id = ? AND action = ?", objectType, objectID, action).Count(&count)
+		return count
+	}
+
+	t.Run("签名或令牌错误时拒绝", func(t *testing.T) {
+		body := loadGitWebhookFixture(t, "github_push.json", nil)
+		headers := github("push", body)
+		headers["X-Hub-Signature-256"] = "sha256=" + signGitWebhook("wrong", body)
+		assert.Equal(t, float64(401), deliver(headers, body)["code"])
+		assert.Equal(t, float64(401), deliver(map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "wrong"}, body)["code"])
+		assert.Equal(t, float64(401), deliver(map[string]string{"X-GitHub-Event": "push", "X-Gitea-Event": "push", "X-Gitea-Signature": "00"}, body)["code"])
+		assert.Equal(t, float64(400), deliver(map[string]string{"X-Hub-Signature-256": "sha256=" + signGitWebhook(secret, body)}, body)["code"], "无法识别来源")
+	})
+
+	t.Run("推送到默认分支时关联提交并解决Bug、完成任务", func(t *testing.T) {
+		bug := &model.Bug{Title: "购物车为空时崩溃", ProjectID: project.ID, CreatorID: owner.ID, Status: "active"}
+		task := &model.Task{Title: "整理购物车代码", ProjectID: project.ID, CreatorID: owner.ID, Status: "doing"}
+		require.NoError(t, db.Create(bug).Error)
+		require.NoError(t, db.Create(task).Error)
+
+		body := loadGitWebhookFixture(t, "github_push.json", map[string]string{"BUG_ID": fmt.Sprint(bug.ID), "TASK_ID": fmt.Sprint(task.ID)})
+		response := deliver(github("push", body), body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Len(t, response["data"].(map[string]interface{})["linked"], 2)
+
+		require.NoError(t, db.First(bug, bug.ID).Error)
+		assert.Equal(t, "resolved", bug.Status)
+		require.NoError(t, db.First(task, task.ID).Error)
+		assert.Equal(t, "doing", task.Status, "仅引用未带关闭关键字时不变更状态")
+
+		var action model.Action
+		require.NoError(t, db.Where("object_type = ? AND object_id = ? AND action = ?", "bug", bug.ID, "commit_linked").First(&action).Error)
+		assert.Equal(t, alice.ID, action.ActorID, "按提交作者邮箱匹配操作人")
+		assert.Contains(t, action.Comment, "0d1a26e6")
+		assert.Equal(t, int64(1), countActions("task", task.ID, "commit_linked"))
+
+		var link model.CodeLink
+		require.NoError(t, db.Where("object_type = ? AND object_id = ?", "bug", bug.ID).First(&link).Error)
+		assert.Equal(t, "acme/shop", link.Repository)
+		assert.Equal(t, "commit", link.Kind)
+
+		// 重复推送不重复记录
+		response = deliver(github("push", body), body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Empty(t, response["data"].(map[string]interface{})["linked"])
+		assert.Equal(t, int64(1), countActions("bug", bug.ID, "commit_linked"))
+
+		var webhook model.GitWebhook
+		require.NoError(t, db.First(&webhook, webhookID).Error)
+		assert.NotNil(t, webhook.LastDeliveryAt)
+	})
+
+	t.Run("推送到非默认分支只关联不变更状态", func(t *testing.T) {
+		bug := &model.Bug{Title: "金额显示错误", ProjectID: project.ID, CreatorID: owner.ID, Status: "active"}
+		require.NoError(t, db.Create(bug).Error)
+
+		body := loadGitWebhookFixture(t, "gitlab_push.json", map[string]string{"BUG_ID": fmt.Sprint(bug.ID)})
+		response := deliver(map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": secret}, body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Len(t, response["data"].(map[string]interface{})["linked"], 1)
+
+		require.NoError(t, db.First(bug, bug.ID).Error)
+		assert.Equal(t, "active", bug.Status)
+		assert.Equal(t, int64(1), countActions("bug", bug.ID, "commit_linked"))
+	})
+
+	t.Run("不关联其他项目的工作项", func(t *testing.T) {
+		bug := &model.Bug{Title: "其他项目的Bug", ProjectID: other.ID, CreatorID: owner.ID, Status: "active"}
+		require.NoError(t, db.Create(bug).Error)
+
+		body := loadGitWebhookFixture(t, "gitea_pull_request.json", map[string]string{"BUG_ID": fmt.Sprint(bug.ID)})
+		headers := map[string]string{"X-GitHub-Event": "pull_request", "X-Gitea-Event": "pull_request", "X-Gitea-Signature": signGitWebhook(secret, body)}
+		response := deliver(headers, body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Empty(t, response["data"].(map[string]interface{})["linked"])
+
+		require.NoError(t, db.First(bug, bug.ID).Error)
+		assert.Equal(t, "active", bug.Status)
+		assert.Equal(t, int64(0), countActions("bug", bug.ID, "merge_request_linked"))
+	})
+
+	t.Run("合并请求合并后完成任务", func(t *testing.T) {
+		task := &model.Task{Title: "购物车重构", ProjectID: project.ID, CreatorID: owner.ID, Status: "doing"}
+		require.NoError(t, db.Create(task).Error)
+		headers := map[string]string{"X-Gitlab-Event": "Merge Request Hook", "X-Gitlab-Token": secret}
+
+		body := loadGitWebhookFixture(t, "gitlab_merge_request.json", map[string]string{"TASK_ID": fmt.Sprint(task.ID), "STATE": "opened"})
+		response := deliver(headers, body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, "merge_request", response["data"].(map[string]interface{})["event"])
+		require.NoError(t, db.First(task, task.ID).Error)
+		assert.Equal(t, "doing", task.Status, "合并请求未合并时不变更状态")
+
+		body = loadGitWebhookFixture(t, "gitlab_merge_request.json", map[string]string{"TASK_ID": fmt.Sprint(task.ID), "STATE": "merged"})
+		response = deliver(headers, body)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		require.NoError(t, db.First(task, task.ID).Error)
+		assert.Equal(t, "done", task.Status)
+		assert.Equal(t, 100, task.Progress)
+		assert.Equal(t, int64(2), countActions("task", task.ID, "merge_request_linked"), "状态变化时重新记录")
+
+		var link model.CodeLink
+		require.NoError(t, db.Where("object_type = ? AND object_id = ? AND kind = ?", "task", task.ID, "merge_request").First(&link).Error)
+		assert.Equal(t, "merged", link.State)
+		assert.Equal(t, "7", link.Ref)
+	})
+}
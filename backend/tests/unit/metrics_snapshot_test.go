@@ -0,0 +1,240 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestMetricsSnapshot_Backfill(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "指标快照项目")
+	user := CreateTestUser(t, db, "metricuser", "指标快照用户")
+	AddUserToProject(t, db, user.ID, project.ID, "member")
+
+	now := time.Now()
+	daysAgo := func(n int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.Local).AddDate(0, 0, -n)
+	}
+	date := func(n int) string { return daysAgo(n).Format(utils.MetricDateLayout) }
+
+	// Bug A：5天前创建，3天前解决
+	bugA := &model.Bug{Title: "BugA", ProjectID: project.ID, CreatorID: user.ID, Status: "resolved", Priority: "high", Severity: "critical"}
+	bugA.CreatedAt = daysAgo(5)
+	require.NoError(t, db.Create(bugA).Error)
+	recordTestStatusChange(t, db, "bug", bugA.ID, user.ID, daysAgo(3), "active", "resolved")
+
+	// Bug B：4天前创建，2天前删除
+	bugB := &model.Bug{Title: "BugB", ProjectID: project.ID, CreatorID: user.ID, Status: "active", Priority: "low", Severity: "low"}
+	bugB.CreatedAt = daysAgo(4)
+	require.NoError(t, db.Create(bugB).Error)
+	require.NoError(t, db.Unscoped().Model(bugB).Update("deleted_at", daysAgo(2)).Error)
+
+	// 任务：预估10小时，3天前记录4小时
+	estimated := 10.0
+	task := &model.Task{Title: "任务", ProjectID: project.ID, CreatorID: user.ID, Status: "doing", EstimatedHours: &estimated}
+	task.CreatedAt = daysAgo(5)
+	require.NoError(t, db.Create(task).Error)
+	resource := &model.Resource{UserID: user.ID, ProjectID: project.ID}
+	require.NoError(t, db.Create(resource).Error)
+	require.NoError(t, db.Create(&model.ResourceAllocation{ResourceID: resource.ID, TaskID: &task.ID, Date: daysAgo(3), Hours: 4}).Error)
+
+	count, err := utils.BackfillProjectMetrics(db, project.ID, daysAgo(5), now, false)
+	require.NoError(t, err)
+	assert.Equal(t, 5, count, "只回填今天之前的日期")
+
+	value := func(day, objectType, dimension, name string) float64 {
+		var snapshot model.ProjectMetricSnapshot
+		if err := db.Where("project_id = ? AND date = ? AND object_type = ? AND dimension = ? AND name = ?",
+			project.ID, day, objectType, dimension, name).First(&snapshot).Error; err != nil {
+			return 0
+		}
+		return snapshot.Value
+	}
+
+	t.Run("根据状态变更历史还原每天的状态分布", func(t *testing.T) {
+		assert.Equal(t, float64(2), value(date(4), "bug", "status", "active"))
+		assert.Equal(t, float64(1), value(date(4), "bug", "severity", "critical"))
+		assert.Equal(t, float64(1), value(date(4), "bug", "created", "total"))
+		assert.Equal(t, float64(1), value(date(3), "bug", "status", "resolved"))
+		assert.Equal(t, float64(1), value(date(3), "bug", "status", "active"))
+		assert.Equal(t, float64(0), value(date(3), "bug", "severity", "critical"), "已解决的Bug不计入严重程度分布")
+		assert.Equal(t, float64(0), value(date(2), "bug", "status", "active"), "已删除的Bug不再计入")
+	})
+
+	t.Run("剩余工时和累计完成工时", func(t *testing.T) {
+		assert.Equal(t, float64(10), value(date(4), "task", "hours", "remaining"))
+		assert.Equal(t, float64(0), value(date(4), "task", "hours", "completed"))
+		assert.Equal(t, float64(6), value(date(3), "task", "hours", "remaining"))
+		assert.Equal(t, float64(4), value(date(1), "task", "hours", "completed"))
+	})
+
+	t.Run("已有快照的日期不重复回填", func(t *testing.T) {
+		count, err := utils.BackfillProjectMetrics(db, project.ID, daysAgo(5), daysAgo(1), false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		count, err = utils.BackfillProjectMetrics(db, project.ID, daysAgo(5), daysAgo(1), true)
+		require.NoError(t, err)
+		assert.Equal(t, 5, count)
+	})
+
+	t.Run("项目进度接口读取指标快照", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", user.ID)
+		c.Set("roles", []string{"developer"})
+		c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/projects/%d/progress", project.ID), nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", project.ID)}}
+
+		api.NewProjectHandler(db).GetProjectProgress(c)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+
+		bugTrend := data["bug_trend"].([]interface{})
+		require.Len(t, bugTrend, 30)
+		day4 := bugTrend[25].(map[string]interface{})
+		assert.Equal(t, date(4), day4["date"])
+		assert.Equal(t, float64(2), day4["open"])
+		today := bugTrend[29].(map[string]interface{})
+		assert.Equal(t, float64(0), today["open"])
+
+		hoursTrend := data["hours_trend"].([]interface{})
+		require.Len(t, hoursTrend, 30)
+		last := hoursTrend[29].(map[string]interface{})
+		assert.Equal(t, float64(6), last["remaining_hours"])
+		assert.Equal(t, float64(4), last["completed_hours"])
+
+		var saved int64
+		db.Model(&model.ProjectMetricSnapshot{}).Where("project_id = ? AND date = ?", project.ID, date(20)).Count(&saved)
+		assert.Equal(t, int64(0), saved, "读取时不回填缺少的快照")
+	})
+
+	t.Run("工作台趋势汇总参与的项目", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", user.ID)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/dashboard", nil)
+
+		api.NewDashboardHandler(db).GetDashboard(c)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		trends := response["data"].(map[string]interface{})["trends"].([]interface{})
+		require.Len(t, trends, 14)
+		day4 := trends[9].(map[string]interface{})
+		assert.Equal(t, date(4), day4["date"])
+		assert.Equal(t, float64(2), day4["open_bugs"])
+		assert.Equal(t, float64(1), day4["open_tasks"])
+	})
+
+	t.Run("管理员回填接口", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		body := fmt.Sprintf(`{"project_id":%d,"start_date":"%s","end_date":"%s","overwrite":true}`, project.ID, date(2), date(1))
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/system/metrics/backfill", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		api.NewSystemHandler(db).BackfillMetrics(c)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, float64(2), response["data"].(map[string]interface{})["days"])
+	})
+}
+
+func TestMetricsSnapshot_HistoryBackfill(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "空项目")
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	require.NoError(t, db.Model(project).Update("created_at", today.AddDate(0, 0, -3)).Error)
+
+	count, err := utils.BackfillAllProjectHistory(db, today.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "从项目创建日开始回填")
+
+	count, err = utils.BackfillAllProjectHistory(db, today.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count, "没有数据的日期也已记录，不重复计算")
+}
+
+func TestMetricsSnapshot_RestrictedItems(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "受限指标项目")
+	owner := CreateTestUser(t, db, "metricowner", "受限Bug创建人")
+	viewer := CreateTestUser(t, db, "metricviewer", "普通成员")
+	AddUserToProject(t, db, owner.ID, project.ID, "member")
+	AddUserToProject(t, db, viewer.ID, project.ID, "member")
+
+	now := time.Now()
+	daysAgo := func(n int) time.Time {
+		return time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.Local).AddDate(0, 0, -n)
+	}
+	public := &model.Bug{Title: "普通Bug", ProjectID: project.ID, CreatorID: owner.ID, Status: "active", Severity: "low"}
+	public.CreatedAt = daysAgo(3)
+	secret := &model.Bug{Title: "安全漏洞", ProjectID: project.ID, CreatorID: owner.ID, Status: "active", Severity: "critical", Confidential: true}
+	secret.CreatedAt = daysAgo(3)
+	require.NoError(t, db.Create(public).Error)
+	require.NoError(t, db.Create(secret).Error)
+	_, err := utils.BackfillProjectMetrics(db, project.ID, daysAgo(3), daysAgo(1), false)
+	require.NoError(t, err)
+	// 1天前缺少快照
+	require.NoError(t, db.Where("project_id = ? AND date = ?", project.ID, daysAgo(1).Format(utils.MetricDateLayout)).Delete(&model.ProjectMetricSnapshot{}).Error)
+
+	bugTrend := func(userID uint) []interface{} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("user_id", userID)
+		c.Set("roles", []string{"developer"})
+		c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/projects/%d/progress", project.ID), nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", project.ID)}}
+		api.NewProjectHandler(db).GetProjectProgress(c)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		return response["data"].(map[string]interface{})["bug_trend"].([]interface{})
+	}
+
+	t.Run("不可见的受限Bug不计入趋势", func(t *testing.T) {
+		trend := bugTrend(viewer.ID)
+		for _, index := range []int{27, 29} { // 2天前（快照）和今天（实时计算）
+			day := trend[index].(map[string]interface{})
+			assert.Equal(t, float64(1), day["open"], day["date"])
+			assert.Equal(t, float64(0), day["by_severity"].(map[string]interface{})["critical"])
+		}
+		assert.Equal(t, float64(1), trend[26].(map[string]interface{})["count"], "新建数量不含受限Bug")
+		assert.Equal(t, float64(0), trend[28].(map[string]interface{})["open"], "没有快照的日期不扣除")
+	})
+
+	t.Run("可以查看的用户统计受限Bug", func(t *testing.T) {
+		trend := bugTrend(owner.ID)
+		assert.Equal(t, float64(2), trend[27].(map[string]interface{})["open"])
+		assert.Equal(t, float64(2), trend[29].(map[string]interface{})["open"])
+	})
+}
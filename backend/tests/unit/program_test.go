@@ -0,0 +1,232 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// callProgramHandler 以指定用户调用项目集处理函数，返回响应数据
+func callProgramHandler(t *testing.T, handler gin.HandlerFunc, userID uint, roles []string, method string, params gin.Params, body interface{}) map[string]interface{} {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	c.Request = httptest.NewRequest(method, "/api/programs", reader)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+	c.Set("user_id", userID)
+	c.Set("roles", roles)
+
+	handler(c)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestProgram_PermissionScoping(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	director := CreateTestUser(t, db, "programdirector", "总监")
+	developer := CreateTestUser(t, db, "programdeveloper", "开发")
+	program := &model.Program{Name: "支付产品线", Code: "PAY", Status: "active"}
+	require.NoError(t, db.Create(program).Error)
+	inProgram := CreateTestProject(t, db, "支付网关")
+	outside := CreateTestProject(t, db, "独立项目")
+	require.NoError(t, db.Model(inProgram).Update("program_id", program.ID).Error)
+	require.NoError(t, db.Create(&model.ProgramMember{ProgramID: program.ID, UserID: director.ID, Role: "viewer"}).Error)
+	require.NoError(t, db.Create(&model.ProgramMember{ProgramID: program.ID, UserID: developer.ID, Role: "viewer"}).Error)
+	AddUserToProject(t, db, developer.ID, inProgram.ID, "member")
+
+	bug := &model.Bug{Title: "支付失败", ProjectID: inProgram.ID, CreatorID: developer.ID, Status: "active"}
+	require.NoError(t, db.Create(bug).Error)
+
+	newContext := func(userID uint) *gin.Context {
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("user_id", userID)
+		c.Set("roles", []string{"department_manager"})
+		return c
+	}
+
+	t.Run("项目集成员可以查看项目集内的项目", func(t *testing.T) {
+		c := newContext(director.ID)
+		var projects []model.Project
+		require.NoError(t, utils.FilterProjectsByUser(db, c, db.Model(&model.Project{})).Find(&projects).Error)
+		require.Len(t, projects, 1)
+		assert.Equal(t, inProgram.ID, projects[0].ID)
+
+		assert.True(t, utils.CheckProjectAccess(db, c, inProgram.ID))
+		assert.False(t, utils.CheckProjectAccess(db, c, outside.ID))
+		assert.True(t, utils.CheckBugAccess(db, c, bug.ID))
+	})
+
+	t.Run("项目集角色限制项目内权限", func(t *testing.T) {
+		c := newContext(director.ID)
+		assert.True(t, utils.HasProjectPermission(db, c, inProgram.ID, "bug:read"))
+		assert.False(t, utils.HasProjectPermission(db, c, inProgram.ID, "bug:update"))
+		assert.False(t, utils.CanManageProgram(db, c, program.ID))
+	})
+
+	t.Run("项目成员身份优先于项目集角色", func(t *testing.T) {
+		c := newContext(developer.ID)
+		assert.True(t, utils.HasProjectPermission(db, c, inProgram.ID, "bug:update"))
+	})
+}
+
+func TestProgram_StatisticsAndPortfolio(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	owner := CreateTestUser(t, db, "programowner", "项目集负责人")
+	projectA := CreateTestProject(t, db, "组合项目A")
+	projectB := CreateTestProject(t, db, "组合项目B")
+	AddUserToProject(t, db, owner.ID, projectA.ID, "owner")
+	AddUserToProject(t, db, owner.ID, projectB.ID, "owner")
+
+	start := time.Now().AddDate(0, -2, 0)
+	end := time.Now().AddDate(0, 0, -1)
+	require.NoError(t, db.Model(projectA).Updates(map[string]interface{}{"start_date": start, "end_date": end}).Error)
+
+	estimated, actual := 10.0, 30.0
+	require.NoError(t, db.Create(&model.Task{Title: "延期任务", ProjectID: projectA.ID, CreatorID: owner.ID, Status: "doing", Progress: 50, EstimatedHours: &estimated, ActualHours: &actual}).Error)
+	require.NoError(t, db.Create(&model.Task{Title: "完成任务", ProjectID: projectB.ID, CreatorID: owner.ID, Status: "done", EstimatedHours: &estimated, ActualHours: &estimated}).Error)
+	require.NoError(t, db.Create(&model.Bug{Title: "严重Bug", ProjectID: projectA.ID, CreatorID: owner.ID, Status: "active", Severity: "critical"}).Error)
+	require.NoError(t, db.Create(&model.Bug{Title: "一般Bug", ProjectID: projectB.ID, CreatorID: owner.ID, Status: "active", Severity: "high"}).Error)
+
+	handler := api.NewProgramHandler(db)
+	roles := []string{"project_manager"}
+
+	created := callProgramHandler(t, handler.CreateProgram, owner.ID, roles, http.MethodPost, nil, map[string]interface{}{
+		"name": "组合产品线", "code": "PORTFOLIO", "project_ids": []uint{projectA.ID, projectB.ID},
+	})
+	require.Equal(t, float64(200), created["code"], created["message"])
+	programID := uint(created["data"].(map[string]interface{})["id"].(float64))
+	params := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", programID)}}
+
+	t.Run("汇总统计等于各项目统计之和", func(t *testing.T) {
+		response := callProgramHandler(t, handler.GetProgramStatistics, owner.ID, roles, http.MethodGet, params, nil)
+		require.Equal(t, float64(200), response["code"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(2), data["total_projects"])
+		assert.Equal(t, float64(2), data["total_tasks"])
+		assert.Equal(t, float64(1), data["done_tasks"])
+		assert.Equal(t, float64(2), data["open_bugs"])
+		assert.Equal(t, float64(2), data["total_members"])
+	})
+
+	t.Run("组合看板计算健康度", func(t *testing.T) {
+		response := callProgramHandler(t, handler.GetProgramPortfolio, owner.ID, roles, http.MethodGet, params, nil)
+		require.Equal(t, float64(200), response["code"])
+		items := response["data"].(map[string]interface{})["projects"].([]interface{})
+		require.Len(t, items, 2)
+
+		healthA := items[0].(map[string]interface{})["health"].(map[string]interface{})
+		assert.Equal(t, "red", healthA["schedule"])
+		assert.Equal(t, "red", healthA["budget"])
+		assert.Equal(t, "red", healthA["quality"])
+		assert.Equal(t, "red", healthA["overall"])
+
+		healthB := items[1].(map[string]interface{})["health"].(map[string]interface{})
+		assert.Equal(t, "unknown", healthB["schedule"])
+		assert.Equal(t, "green", healthB["budget"])
+		assert.Equal(t, "yellow", healthB["quality"])
+		assert.Equal(t, "yellow", healthB["overall"])
+	})
+
+	t.Run("非成员不能访问项目集", func(t *testing.T) {
+		outsider := CreateTestUser(t, db, "programoutsider", "外部用户")
+		response := callProgramHandler(t, handler.GetProgram, outsider.ID, roles, http.MethodGet, params, nil)
+		assert.Equal(t, float64(403), response["code"])
+	})
+
+	t.Run("已属于其他项目集的项目不能加入", func(t *testing.T) {
+		response := callProgramHandler(t, handler.CreateProgram, owner.ID, roles, http.MethodPost, nil, map[string]interface{}{
+			"name": "另一产品线", "code": "OTHER", "project_ids": []uint{projectA.ID},
+		})
+		assert.Equal(t, float64(400), response["code"])
+	})
+}
+
+func TestProgram_CrossProjectRequirement(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	owner := CreateTestUser(t, db, "reqprogramowner", "项目集负责人")
+	partner := CreateTestUser(t, db, "reqpartner", "协作项目成员")
+	projectA := CreateTestProject(t, db, "需求主项目")
+	projectB := CreateTestProject(t, db, "需求协作项目")
+	other := CreateTestProject(t, db, "项目集外项目")
+	AddUserToProject(t, db, owner.ID, projectA.ID, "owner")
+	AddUserToProject(t, db, partner.ID, projectB.ID, "member")
+
+	program := &model.Program{Name: "需求产品线", Code: "REQ", Status: "active"}
+	require.NoError(t, db.Create(program).Error)
+	require.NoError(t, db.Create(&model.ProgramMember{ProgramID: program.ID, UserID: owner.ID, Role: "owner"}).Error)
+	require.NoError(t, db.Model(&model.Project{}).Where("id IN ?", []uint{projectA.ID, projectB.ID}).Update("program_id", program.ID).Error)
+
+	requirement := &model.Requirement{Title: "统一登录", ProjectID: projectA.ID, CreatorID: owner.ID, Status: "active"}
+	require.NoError(t, db.Create(requirement).Error)
+
+	partnerCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	partnerCtx.Set("user_id", partner.ID)
+	partnerCtx.Set("roles", []string{"developer"})
+	assert.False(t, utils.CheckRequirementAccess(db, partnerCtx, requirement.ID))
+
+	handler := api.NewProgramHandler(db)
+	roles := []string{"project_manager"}
+	params := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", program.ID)}, {Key: "requirement_id", Value: fmt.Sprintf("%d", requirement.ID)}}
+
+	t.Run("共同实现的项目必须在项目集内", func(t *testing.T) {
+		response := callProgramHandler(t, handler.SetProgramRequirement, owner.ID, roles, http.MethodPut, params, map[string]interface{}{"project_ids": []uint{other.ID}})
+		assert.Equal(t, float64(400), response["code"])
+	})
+
+	t.Run("协作项目成员可以访问项目集级需求", func(t *testing.T) {
+		response := callProgramHandler(t, handler.SetProgramRequirement, owner.ID, roles, http.MethodPut, params, map[string]interface{}{"project_ids": []uint{projectA.ID, projectB.ID}})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(program.ID), data["program_id"])
+		assert.Len(t, data["linked_projects"], 1)
+
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set("user_id", partner.ID)
+		ctx.Set("roles", []string{"developer"})
+		assert.True(t, utils.CheckRequirementAccess(db, ctx, requirement.ID))
+
+		var requirements []model.Requirement
+		require.NoError(t, utils.FilterRequirementsByUser(db, ctx, db.Model(&model.Requirement{})).Find(&requirements).Error)
+		require.Len(t, requirements, 1)
+		assert.Equal(t, requirement.ID, requirements[0].ID)
+	})
+
+	t.Run("取消项目集归属", func(t *testing.T) {
+		response := callProgramHandler(t, handler.RemoveProgramRequirement, owner.ID, roles, http.MethodDelete, params, nil)
+		require.Equal(t, float64(200), response["code"])
+
+		var updated model.Requirement
+		require.NoError(t, db.Preload("LinkedProjects").First(&updated, requirement.ID).Error)
+		assert.Nil(t, updated.ProgramID)
+		assert.Empty(t, updated.LinkedProjects)
+	})
+}
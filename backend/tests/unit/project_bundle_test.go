@@ -0,0 +1,301 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestProjectBundle_ExportImport(t *testing.T) {
+	sourceDB := SetupTestDB(t)
+	defer TeardownTestDB(t, sourceDB)
+	targetDB, targetPath := SetupTestDBWithFile(t)
+	defer TeardownTestDBWithFile(t, targetDB, targetPath)
+
+	storagePath := t.TempDir()
+	oldStoragePath := config.AppConfig.Upload.StoragePath
+	config.AppConfig.Upload.StoragePath = storagePath
+	defer func() { config.AppConfig.Upload.StoragePath = oldStoragePath }()
+
+	// 来源实例数据
+	owner := CreateTestUser(t, sourceDB, "bundleowner", "数据包负责人")
+	ghost := CreateTestUser(t, sourceDB, "bundleghost", "不存在的用户")
+	project := &model.Project{Name: "迁移项目", Code: "MIGRATE", Status: "doing"}
+	require.NoError(t, sourceDB.Create(project).Error)
+	AddUserToProject(t, sourceDB, owner.ID, project.ID, "项目经理")
+	AddUserToProject(t, sourceDB, ghost.ID, project.ID, "开发")
+
+	requirement := &model.Requirement{Title: "导出需求", ProjectID: project.ID, CreatorID: owner.ID, Status: "active", Priority: "high"}
+	require.NoError(t, sourceDB.Create(requirement).Error)
+	design := &model.Task{Title: "设计", ProjectID: project.ID, CreatorID: owner.ID, RequirementID: &requirement.ID, AssigneeID: &owner.ID, Status: "done"}
+	require.NoError(t, sourceDB.Create(design).Error)
+	dev := &model.Task{Title: "开发", ProjectID: project.ID, CreatorID: owner.ID, AssigneeID: &ghost.ID, Status: "doing"}
+	require.NoError(t, sourceDB.Create(dev).Error)
+	require.NoError(t, sourceDB.Create(&model.TaskDependency{TaskID: dev.ID, DependencyID: design.ID, Type: "finish_to_start"}).Error)
+	bug := &model.Bug{Title: "导出Bug", ProjectID: project.ID, CreatorID: ghost.ID, Status: "active", Priority: "high", Severity: "major"}
+	require.NoError(t, sourceDB.Create(bug).Error)
+	require.NoError(t, sourceDB.Model(bug).Association("Assignees").Append(owner))
+	_, err := utils.RecordAction(sourceDB, "bug", bug.ID, "opened", owner.ID, "新建Bug", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(storagePath, "2024/01/01"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(storagePath, "2024/01/01/spec.txt"), []byte("spec content"), 0644))
+	attachment := &model.Attachment{FileName: "spec.txt", FilePath: "2024/01/01/spec.txt", FileSize: 12, MimeType: "text/plain", CreatorID: owner.ID}
+	require.NoError(t, sourceDB.Create(attachment).Error)
+	require.NoError(t, sourceDB.Model(attachment).Association("Requirements").Append(requirement))
+
+	var buf bytes.Buffer
+	require.NoError(t, utils.WriteProjectBundle(sourceDB, nil, project.ID, &buf))
+	data := buf.Bytes()
+
+	openBundle := func() *zip.Reader {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		return zr
+	}
+
+	// 目标实例：只有负责人存在，项目编码已被占用
+	targetOwner := CreateTestUser(t, targetDB, "bundleowner", "数据包负责人")
+	importer := CreateTestUser(t, targetDB, "bundleimporter", "导入人")
+	require.NoError(t, targetDB.Create(&model.Project{Name: "已有项目", Code: "MIGRATE"}).Error)
+
+	var importedProjectID uint
+
+	t.Run("首次导入", func(t *testing.T) {
+		result, err := utils.ImportProjectBundle(targetDB, openBundle(), importer.ID, nil)
+		require.NoError(t, err)
+		importedProjectID = result.ProjectID
+
+		var imported model.Project
+		require.NoError(t, targetDB.First(&imported, result.ProjectID).Error)
+		assert.Equal(t, "迁移项目", imported.Name)
+		assert.Equal(t, "MIGRATE-1", imported.Code)
+		assert.Equal(t, 1, result.Created["project"])
+		assert.Equal(t, 2, result.Created["task"])
+		assert.Equal(t, 1, result.Skipped["member"])
+
+		conflicts := ""
+		for _, c := range result.Conflicts {
+			conflicts += c + "\n"
+		}
+		assert.Contains(t, conflicts, "bundleghost")
+		assert.Contains(t, conflicts, "MIGRATE")
+
+		var tasks []model.Task
+		targetDB.Where("project_id = ?", result.ProjectID).Order("id ASC").Find(&tasks)
+		require.Len(t, tasks, 2)
+		require.NotNil(t, tasks[0].AssigneeID)
+		assert.Equal(t, targetOwner.ID, *tasks[0].AssigneeID)
+		assert.Nil(t, tasks[1].AssigneeID)
+		require.NotNil(t, tasks[0].RequirementID)
+
+		var dep model.TaskDependency
+		require.NoError(t, targetDB.Where("task_id = ?", tasks[1].ID).First(&dep).Error)
+		assert.Equal(t, tasks[0].ID, dep.DependencyID)
+
+		var importedBug model.Bug
+		require.NoError(t, targetDB.Preload("Assignees").Where("project_id = ?", result.ProjectID).First(&importedBug).Error)
+		assert.Equal(t, importer.ID, importedBug.CreatorID)
+		require.Len(t, importedBug.Assignees, 1)
+		assert.Equal(t, targetOwner.ID, importedBug.Assignees[0].ID)
+
+		var actionCount int64
+		targetDB.Model(&model.Action{}).Where("object_type = ? AND object_id = ?", "bug", importedBug.ID).Count(&actionCount)
+		assert.Equal(t, int64(1), actionCount)
+
+		var importedReq model.Requirement
+		require.NoError(t, targetDB.Preload("Attachments").Where("project_id = ?", result.ProjectID).First(&importedReq).Error)
+		require.Len(t, importedReq.Attachments, 1)
+		content, err := os.ReadFile(filepath.Join(storagePath, importedReq.Attachments[0].FilePath))
+		require.NoError(t, err)
+		assert.Equal(t, "spec content", string(content))
+	})
+
+	t.Run("重复导入不产生重复数据", func(t *testing.T) {
+		result, err := utils.ImportProjectBundle(targetDB, openBundle(), importer.ID, nil)
+		require.NoError(t, err)
+		assert.Equal(t, importedProjectID, result.ProjectID)
+		assert.Equal(t, 0, result.Created["project"])
+		assert.Equal(t, 1, result.Updated["project"])
+		assert.Equal(t, 2, result.Updated["task"])
+		assert.Equal(t, 0, result.Created["attachment"])
+
+		var projectCount, taskCount, depCount, attachmentCount, actionCount int64
+		targetDB.Model(&model.Project{}).Where("name = ?", "迁移项目").Count(&projectCount)
+		targetDB.Model(&model.Task{}).Where("project_id = ?", importedProjectID).Count(&taskCount)
+		targetDB.Model(&model.TaskDependency{}).Count(&depCount)
+		targetDB.Model(&model.Attachment{}).Count(&attachmentCount)
+		targetDB.Model(&model.Action{}).Where("object_type = ?", "bug").Count(&actionCount)
+		assert.Equal(t, int64(1), projectCount)
+		assert.Equal(t, int64(2), taskCount)
+		assert.Equal(t, int64(1), depCount)
+		assert.Equal(t, int64(1), attachmentCount)
+		assert.Equal(t, int64(1), actionCount)
+	})
+
+	t.Run("更新已导入的项目需要该项目的管理权限", func(t *testing.T) {
+		handler := api.NewProjectBundleHandler(targetDB)
+		memberCount := func(userID uint) int64 {
+			var count int64
+			targetDB.Model(&model.ProjectMember{}).Where("project_id = ? AND user_id = ?", importedProjectID, userID).Count(&count)
+			return count
+		}
+
+		outsider := CreateTestUser(t, targetDB, "bundleoutsider", "其他用户")
+		response := postAttachmentForm(t, handler.ImportProject, outsider.ID, []string{"developer"}, nil, nil, "bundle.zip", data)
+		assert.Equal(t, float64(403), response["code"], response["message"])
+		assert.Equal(t, int64(0), memberCount(outsider.ID), "不能通过导入成为已有项目的成员")
+
+		admin := CreateTestUser(t, targetDB, "bundleadmin", "管理员")
+		response = postAttachmentForm(t, handler.ImportProject, admin.ID, []string{"admin"}, nil, nil, "bundle.zip", data)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		result := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(importedProjectID), result["project_id"])
+		assert.Equal(t, false, result["project_created"])
+		assert.Equal(t, int64(0), memberCount(admin.ID), "更新已有项目时不添加成员")
+	})
+
+	t.Run("伪造的数据包不能改动其他导入项目的数据", func(t *testing.T) {
+		// 沿用来源实例和对象ID，只更换来源项目ID
+		zr := openBundle()
+		bundle, err := utils.ReadProjectBundle(zr)
+		require.NoError(t, err)
+		bundle.Project.ID += 1000
+		bundle.Project.Name = "伪造项目"
+		for i := range bundle.Tasks {
+			bundle.Tasks[i].Title = "被篡改"
+		}
+		var forged bytes.Buffer
+		zw := zip.NewWriter(&forged)
+		for _, f := range zr.File {
+			w, err := zw.Create(f.Name)
+			require.NoError(t, err)
+			if f.Name == utils.ProjectBundleManifest {
+				require.NoError(t, json.NewEncoder(w).Encode(bundle))
+				continue
+			}
+			r, err := f.Open()
+			require.NoError(t, err)
+			_, err = io.Copy(w, r)
+			r.Close()
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		forgedZR, err := zip.NewReader(bytes.NewReader(forged.Bytes()), int64(forged.Len()))
+		require.NoError(t, err)
+
+		result, err := utils.ImportProjectBundle(targetDB, forgedZR, importer.ID, nil)
+		require.NoError(t, err)
+		assert.NotEqual(t, importedProjectID, result.ProjectID)
+		assert.Equal(t, 2, result.Created["task"], "映射到其他项目的任务作为新任务导入")
+		assert.Equal(t, 0, result.Updated["task"])
+
+		var tasks []model.Task
+		targetDB.Where("project_id = ?", importedProjectID).Find(&tasks)
+		require.Len(t, tasks, 2)
+		for _, task := range tasks {
+			assert.NotEqual(t, "被篡改", task.Title)
+		}
+		var forgedTasks int64
+		targetDB.Model(&model.Task{}).Where("project_id = ?", result.ProjectID).Count(&forgedTasks)
+		assert.Equal(t, int64(2), forgedTasks)
+
+		var requirement model.Requirement
+		require.NoError(t, targetDB.Preload("Attachments").Where("project_id = ?", result.ProjectID).First(&requirement).Error)
+		require.Len(t, requirement.Attachments, 1)
+		var originalRequirement model.Requirement
+		require.NoError(t, targetDB.Preload("Attachments").Where("project_id = ?", importedProjectID).First(&originalRequirement).Error)
+		require.Len(t, originalRequirement.Attachments, 1)
+		assert.NotEqual(t, originalRequirement.Attachments[0].ID, requirement.Attachments[0].ID, "不复用其他项目的附件")
+	})
+
+	t.Run("无效数据包", func(t *testing.T) {
+		var empty bytes.Buffer
+		zw := zip.NewWriter(&empty)
+		require.NoError(t, zw.Close())
+		zr, err := zip.NewReader(bytes.NewReader(empty.Bytes()), int64(empty.Len()))
+		require.NoError(t, err)
+
+		_, err = utils.ImportProjectBundle(targetDB, zr, importer.ID, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestProjectBundle_RestrictedItems(t *testing.T) {
+	sourceDB := SetupTestDB(t)
+	defer TeardownTestDB(t, sourceDB)
+	targetDB, targetPath := SetupTestDBWithFile(t)
+	defer TeardownTestDBWithFile(t, targetDB, targetPath)
+
+	owner := CreateTestUser(t, sourceDB, "restrictedowner", "负责人")
+	member := CreateTestUser(t, sourceDB, "restrictedmember", "成员")
+	project := CreateTestProject(t, sourceDB, "受限导出项目")
+	AddUserToProject(t, sourceDB, owner.ID, project.ID, "owner")
+	AddUserToProject(t, sourceDB, member.ID, project.ID, "member")
+
+	requirement := &model.Requirement{Title: "受限需求", ProjectID: project.ID, CreatorID: owner.ID, Status: "active", Confidential: true}
+	require.NoError(t, sourceDB.Create(requirement).Error)
+	require.NoError(t, sourceDB.Create(&model.ItemAccess{ObjectType: "requirement", ObjectID: requirement.ID, UserID: &member.ID, CreatorID: owner.ID}).Error)
+	require.NoError(t, sourceDB.Create(&model.ItemAccess{ObjectType: "requirement", ObjectID: requirement.ID, RoleCode: "owner", CreatorID: owner.ID}).Error)
+	hiddenBug := &model.Bug{Title: "保密Bug", ProjectID: project.ID, CreatorID: owner.ID, Status: "active", Confidential: true}
+	require.NoError(t, sourceDB.Create(hiddenBug).Error)
+	task := &model.Task{Title: "修复保密Bug", ProjectID: project.ID, CreatorID: owner.ID, Status: "wait", Confidential: true}
+	require.NoError(t, sourceDB.Create(task).Error)
+	attachment := &model.Attachment{FileName: "exploit.txt", FilePath: "2024/01/01/exploit.txt", CreatorID: owner.ID}
+	require.NoError(t, sourceDB.Create(attachment).Error)
+	require.NoError(t, sourceDB.Model(attachment).Association("Bugs").Append(hiddenBug))
+	require.NoError(t, sourceDB.Model(attachment).Association("Projects").Append(project))
+
+	t.Run("只导出导出人可见的受限对象", func(t *testing.T) {
+		c, _ := newConfidentialContext(member.ID)
+		bundle, err := utils.BuildProjectBundle(sourceDB, c, project.ID)
+		require.NoError(t, err)
+		require.Len(t, bundle.Requirements, 1)
+		assert.True(t, bundle.Requirements[0].Confidential)
+		assert.Len(t, bundle.Requirements[0].Access, 2)
+		assert.Empty(t, bundle.Bugs)
+		assert.Empty(t, bundle.Tasks)
+		assert.Empty(t, bundle.Attachments, "关联到不可见保密Bug的附件不导出")
+
+		c, _ = newConfidentialContext(member.ID, "admin")
+		bundle, err = utils.BuildProjectBundle(sourceDB, c, project.ID)
+		require.NoError(t, err)
+		assert.Len(t, bundle.Bugs, 1)
+		assert.Len(t, bundle.Tasks, 1)
+	})
+
+	t.Run("导入时保留访问限制和访问名单", func(t *testing.T) {
+		var buf bytes.Buffer
+		c, _ := newConfidentialContext(member.ID)
+		require.NoError(t, utils.WriteProjectBundle(sourceDB, c, project.ID, &buf))
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+
+		targetMember := CreateTestUser(t, targetDB, "restrictedmember", "成员")
+		importer := CreateTestUser(t, targetDB, "restrictedimporter", "导入人")
+		result, err := utils.ImportProjectBundle(targetDB, zr, importer.ID, nil)
+		require.NoError(t, err)
+
+		var imported model.Requirement
+		require.NoError(t, targetDB.Where("project_id = ?", result.ProjectID).First(&imported).Error)
+		assert.True(t, imported.Confidential)
+		var accesses []model.ItemAccess
+		targetDB.Where("object_type = ? AND object_id = ?", "requirement", imported.ID).Order("id ASC").Find(&accesses)
+		require.Len(t, accesses, 2)
+		require.NotNil(t, accesses[0].UserID)
+		assert.Equal(t, targetMember.ID, *accesses[0].UserID)
+		assert.Equal(t, "owner", accesses[1].RoleCode)
+	})
+}
@@ -0,0 +1,206 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// recordTestStatusChange 写入一条指定时间的状态变更记录
+func recordTestStatusChange(t *testing.T, db *gorm.DB, objectType string, objectID, actorID uint, at time.Time, oldStatus, newStatus string) {
+	action := &model.Action{ObjectType: objectType, ObjectID: objectID, ActorID: actorID, Action: "edited", Date: at}
+	require.NoError(t, db.Create(action).Error)
+	require.NoError(t, db.Create(&model.History{ActionID: action.ID, Field: "status", Old: oldStatus, New: newStatus}).Error)
+}
+
+// getProjectFlow 调用项目流动分析接口，返回响应
+func getProjectFlow(t *testing.T, handler *api.ProjectHandler, projectID, userID uint, query string) map[string]interface{} {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/projects/%d/flow?%s", projectID, query), nil)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", projectID)}}
+	c.Set("user_id", userID)
+	c.Set("roles", []string{"developer"})
+
+	handler.GetProjectFlow(c)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestFlowMetrics_ItemStats(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2026, 3, d, h, 0, 0, 0, time.Local) }
+	workflow := utils.FlowWorkflows["task"]
+
+	t.Run("按状态变更计算停留时长、开始和完成时间", func(t *testing.T) {
+		item := &utils.FlowItem{
+			Status: "closed", CreatedAt: day(1, 0), UpdatedAt: day(6, 0),
+			Transitions: []utils.StatusTransition{
+				{At: day(2, 0), From: "wait", To: "doing"},
+				{At: day(4, 0), From: "doing", To: "done"},
+				{At: day(5, 0), From: "done", To: "closed"},
+			},
+		}
+		stats := workflow.ItemStats(item, day(10, 0))
+		assert.Equal(t, 24.0, stats.TimeInStatus["wait"])
+		assert.Equal(t, 48.0, stats.TimeInStatus["doing"])
+		require.NotNil(t, stats.StartedAt)
+		assert.Equal(t, day(2, 0), *stats.StartedAt)
+		require.NotNil(t, stats.DoneAt)
+		assert.Equal(t, day(4, 0), *stats.DoneAt)
+	})
+
+	t.Run("历史记录缺失时以更新时间补齐当前状态", func(t *testing.T) {
+		item := &utils.FlowItem{Status: "doing", CreatedAt: day(1, 0), UpdatedAt: day(3, 0)}
+		spans := utils.BuildStatusSpans(item)
+		assert.Equal(t, "doing", utils.StatusAt(spans, day(2, 0)))
+
+		item.Transitions = []utils.StatusTransition{{At: day(2, 0), From: "wait", To: "pause"}}
+		spans = utils.BuildStatusSpans(item)
+		assert.Equal(t, "wait", utils.StatusAt(spans, day(1, 12)))
+		assert.Equal(t, "pause", utils.StatusAt(spans, day(2, 12)))
+		assert.Equal(t, "doing", utils.StatusAt(spans, day(3, 12)))
+	})
+
+	t.Run("百分位数使用线性插值", func(t *testing.T) {
+		stats := utils.NewDurationStats([]float64{4, 1, 3, 2})
+		assert.Equal(t, 4, stats.Count)
+		assert.Equal(t, 2.5, stats.P50)
+		assert.Equal(t, 3.55, stats.P85)
+		assert.Equal(t, 1.0, stats.Min)
+		assert.Equal(t, 4.0, stats.Max)
+	})
+}
+
+func TestProjectHandler_GetProjectFlow(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "流动分析项目")
+	user := CreateTestUser(t, db, "flowuser", "流动分析用户")
+	AddUserToProject(t, db, user.ID, project.ID, "member")
+	day := func(d, h int) time.Time { return time.Date(2026, 3, d, h, 0, 0, 0, time.Local) }
+
+	createTask := func(title, status string, createdAt time.Time) *model.Task {
+		task := &model.Task{Title: title, ProjectID: project.ID, CreatorID: user.ID, Status: status}
+		task.CreatedAt = createdAt
+		require.NoError(t, db.Create(task).Error)
+		return task
+	}
+
+	taskA := createTask("任务A", "done", day(1, 9))
+	recordTestStatusChange(t, db, "task", taskA.ID, user.ID, day(3, 9), "wait", "doing")
+	recordTestStatusChange(t, db, "task", taskA.ID, user.ID, day(5, 9), "doing", "done")
+
+	taskB := createTask("任务B", "done", day(2, 9))
+	recordTestStatusChange(t, db, "task", taskB.ID, user.ID, day(2, 21), "wait", "doing")
+	recordTestStatusChange(t, db, "task", taskB.ID, user.ID, day(10, 9), "doing", "done")
+
+	taskC := createTask("任务C", "doing", day(4, 9))
+	recordTestStatusChange(t, db, "task", taskC.ID, user.ID, day(6, 0), "wait", "doing")
+
+	createTask("任务D", "wait", day(4, 9))
+
+	handler := api.NewProjectHandler(db)
+	response := getProjectFlow(t, handler, project.ID, user.ID, "object_type=task&start_date=2026-03-01&end_date=2026-03-14")
+	require.Equal(t, float64(200), response["code"], response["message"])
+	data := response["data"].(map[string]interface{})
+
+	t.Run("前置时间和周期时间分布", func(t *testing.T) {
+		lead := data["lead_time"].(map[string]interface{})
+		assert.Equal(t, float64(2), lead["count"])
+		assert.Equal(t, float64(4), lead["min"])
+		assert.Equal(t, float64(8), lead["max"])
+		assert.Equal(t, float64(6), lead["p50"])
+
+		cycle := data["cycle_time"].(map[string]interface{})
+		assert.Equal(t, float64(2), cycle["count"])
+		assert.Equal(t, 4.75, cycle["p50"])
+	})
+
+	t.Run("状态停留时长", func(t *testing.T) {
+		var doing map[string]interface{}
+		for _, entry := range data["time_in_status"].([]interface{}) {
+			if e := entry.(map[string]interface{}); e["status"] == "doing" {
+				doing = e
+			}
+		}
+		require.NotNil(t, doing)
+		assert.Equal(t, float64(114), doing["average_hours"])
+		assert.Equal(t, float64(2), doing["items"])
+	})
+
+	t.Run("累积流图", func(t *testing.T) {
+		cfd := data["cumulative_flow"].([]interface{})
+		require.Len(t, cfd, 14)
+		day4 := cfd[3].(map[string]interface{})
+		assert.Equal(t, "2026-03-04", day4["date"])
+		counts := day4["counts"].(map[string]interface{})
+		assert.Equal(t, float64(2), counts["doing"])
+		assert.Equal(t, float64(2), counts["wait"])
+		assert.Equal(t, float64(0), counts["done"])
+
+		last := cfd[13].(map[string]interface{})["counts"].(map[string]interface{})
+		assert.Equal(t, float64(2), last["done"])
+		assert.Equal(t, float64(1), last["doing"])
+	})
+
+	t.Run("每周吞吐量", func(t *testing.T) {
+		throughput := data["throughput"].([]interface{})
+		require.Len(t, throughput, 3)
+		counts := make(map[string]float64)
+		for _, entry := range throughput {
+			e := entry.(map[string]interface{})
+			counts[e["week_start"].(string)] = e["count"].(float64)
+		}
+		assert.Equal(t, float64(0), counts["2026-02-23"])
+		assert.Equal(t, float64(1), counts["2026-03-02"])
+		assert.Equal(t, float64(1), counts["2026-03-09"])
+	})
+
+	t.Run("在制品老化", func(t *testing.T) {
+		aging := data["aging_wip"].([]interface{})
+		require.Len(t, aging, 1)
+		item := aging[0].(map[string]interface{})
+		assert.Equal(t, float64(taskC.ID), item["id"])
+		assert.Equal(t, float64(9), item["age_days"])
+	})
+
+	t.Run("无效的对象类型和日期", func(t *testing.T) {
+		assert.Equal(t, float64(400), getProjectFlow(t, handler, project.ID, user.ID, "object_type=story")["code"])
+		assert.Equal(t, float64(400), getProjectFlow(t, handler, project.ID, user.ID, "start_date=2026-03-10&end_date=2026-03-01")["code"])
+	})
+
+	t.Run("修改任务状态记录状态变更历史", func(t *testing.T) {
+		task := createTask("任务E", "wait", time.Now())
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPatch, "/api/tasks/status", bytes.NewBufferString(`{"status":"doing"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", task.ID)}}
+		c.Set("user_id", user.ID)
+		c.Set("roles", []string{"admin"})
+		api.NewTaskHandler(db).UpdateTaskStatus(c)
+
+		var count int64
+		db.Table("histories").Joins("JOIN actions ON actions.id = histories.action_id").
+			Where("actions.object_type = ? AND actions.object_id = ? AND histories.field = ? AND histories.new = ?", "task", task.ID, "status", "doing").
+			Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+}
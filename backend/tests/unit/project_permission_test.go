@@ -0,0 +1,197 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"prjflow/internal/api"
+	"prjflow/internal/middleware"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+// runProjectPermission 通过路由执行项目权限中间件，返回响应码（200表示放行）
+func runProjectPermission(t *testing.T, db *gorm.DB, userID uint, roles, perms []string, permCode string, resolver utils.ProjectResolver, path string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/objects/:id", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("roles", roles)
+		c.Set("permissions", perms)
+		c.Next()
+	}, middleware.RequireProjectPermission(db, permCode, resolver), func(c *gin.Context) {
+		utils.Success(c, nil)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, path, nil))
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return int(response["code"].(float64))
+}
+
+func TestProjectPermission_RequireProjectPermission(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	user := CreateTestUser(t, db, "projectpermuser", "项目权限用户")
+	viewerProject := CreateTestProject(t, db, "只读项目")
+	devProject := CreateTestProject(t, db, "开发项目")
+	ownerProject := CreateTestProject(t, db, "负责项目")
+	AddUserToProject(t, db, user.ID, viewerProject.ID, "viewer")
+	AddUserToProject(t, db, user.ID, devProject.ID, "developer") // 没有对应的项目角色
+	AddUserToProject(t, db, user.ID, ownerProject.ID, "项目经理")    // 按名称匹配 owner
+
+	viewerBug := &model.Bug{Title: "只读项目Bug", ProjectID: viewerProject.ID, CreatorID: user.ID, Status: "active"}
+	devBug := &model.Bug{Title: "开发项目Bug", ProjectID: devProject.ID, CreatorID: user.ID, Status: "active"}
+	require.NoError(t, db.Create(viewerBug).Error)
+	require.NoError(t, db.Create(devBug).Error)
+
+	bugResolver := utils.ProjectFromObjectParam("bugs", "id")
+	globalPerms := []string{"bug:read", "bug:update"}
+
+	t.Run("项目角色优先于全局权限", func(t *testing.T) {
+		code := runProjectPermission(t, db, user.ID, []string{"developer"}, globalPerms, "bug:update", bugResolver, fmt.Sprintf("/objects/%d", viewerBug.ID))
+		assert.Equal(t, 403, code)
+	})
+
+	t.Run("没有项目角色时使用全局权限", func(t *testing.T) {
+		code := runProjectPermission(t, db, user.ID, []string{"developer"}, globalPerms, "bug:update", bugResolver, fmt.Sprintf("/objects/%d", devBug.ID))
+		assert.Equal(t, 200, code)
+
+		code = runProjectPermission(t, db, user.ID, []string{"developer"}, globalPerms, "bug:delete", bugResolver, fmt.Sprintf("/objects/%d", devBug.ID))
+		assert.Equal(t, 403, code)
+	})
+
+	t.Run("项目角色授予全局角色没有的权限", func(t *testing.T) {
+		code := runProjectPermission(t, db, user.ID, []string{"developer"}, globalPerms, "project:manage", utils.ProjectFromParam("id"), fmt.Sprintf("/objects/%d", ownerProject.ID))
+		assert.Equal(t, 200, code)
+	})
+
+	t.Run("默认项目经理角色不授予删除权限", func(t *testing.T) {
+		for _, perm := range []string{"project:delete", "task:delete", "bug:delete", "requirement:delete", "test-case:delete", "attachment:delete"} {
+			code := runProjectPermission(t, db, user.ID, []string{"developer"}, globalPerms, perm, utils.ProjectFromParam("id"), fmt.Sprintf("/objects/%d", ownerProject.ID))
+			assert.Equal(t, 403, code, perm)
+		}
+	})
+
+	t.Run("管理员不受项目角色限制", func(t *testing.T) {
+		code := runProjectPermission(t, db, user.ID, []string{"admin"}, []string{}, "bug:update", bugResolver, fmt.Sprintf("/objects/%d", viewerBug.ID))
+		assert.Equal(t, 200, code)
+	})
+
+	t.Run("对象不存在时按全局权限检查", func(t *testing.T) {
+		code := runProjectPermission(t, db, user.ID, []string{"developer"}, globalPerms, "bug:update", bugResolver, "/objects/99999")
+		assert.Equal(t, 200, code)
+
+		code = runProjectPermission(t, db, user.ID, []string{"developer"}, globalPerms, "bug:delete", bugResolver, "/objects/99999")
+		assert.Equal(t, 403, code)
+	})
+}
+
+func TestProjectPermission_CheckAccess(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	guestRole := model.ProjectRole{Name: "访客", Code: "guest", Status: 1}
+	require.NoError(t, db.Create(&guestRole).Error)
+	var projectRead model.Permission
+	require.NoError(t, db.Where("code = ?", "project:read").First(&projectRead).Error)
+	require.NoError(t, db.Model(&guestRole).Association("Permissions").Replace([]model.Permission{projectRead}))
+
+	creator := CreateTestUser(t, db, "accesscreator", "创建人")
+	guest := CreateTestUser(t, db, "accessguest", "访客")
+	member := CreateTestUser(t, db, "accessmember", "成员")
+	outsider := CreateTestUser(t, db, "accessoutsider", "非成员")
+	project := CreateTestProject(t, db, "访问控制项目")
+	AddUserToProject(t, db, guest.ID, project.ID, "guest")
+	AddUserToProject(t, db, member.ID, project.ID, "开发")
+
+	bug := &model.Bug{Title: "访问控制Bug", ProjectID: project.ID, CreatorID: creator.ID, Status: "active"}
+	require.NoError(t, db.Create(bug).Error)
+
+	newContext := func(userID uint) *gin.Context {
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("user_id", userID)
+		c.Set("roles", []string{"developer"})
+		c.Set("permissions", []string{"bug:read", "project:read"})
+		return c
+	}
+
+	t.Run("项目角色缺少读取权限", func(t *testing.T) {
+		assert.True(t, utils.CheckProjectAccess(db, newContext(guest.ID), project.ID))
+		assert.False(t, utils.CheckBugAccess(db, newContext(guest.ID), bug.ID))
+	})
+
+	t.Run("未定义项目角色的成员可以访问", func(t *testing.T) {
+		assert.True(t, utils.CheckBugAccess(db, newContext(member.ID), bug.ID))
+	})
+
+	t.Run("创建人始终可以访问", func(t *testing.T) {
+		assert.True(t, utils.CheckBugAccess(db, newContext(creator.ID), bug.ID))
+	})
+
+	t.Run("非成员即使有全局权限也不能访问", func(t *testing.T) {
+		assert.False(t, utils.CheckProjectAccess(db, newContext(outsider.ID), project.ID))
+		assert.False(t, utils.CheckBugAccess(db, newContext(outsider.ID), bug.ID))
+	})
+}
+
+func TestProjectPermission_ProjectFromBody(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	body := []byte(`{"project_id": 12, "title": "新Bug"}`)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/bugs", bytes.NewReader(body))
+
+	projectID, ok := utils.ProjectFromBody("project_id")(db, c)
+	assert.True(t, ok)
+	assert.Equal(t, uint(12), projectID)
+
+	// 请求体需要保留给处理函数
+	remaining, err := io.ReadAll(c.Request.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, remaining)
+}
+
+func TestProjectPermission_AssignRolePermissionsAudit(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+	require.NoError(t, db.AutoMigrate(&model.AuditLog{}))
+
+	var role model.ProjectRole
+	require.NoError(t, db.Where("code = ?", "viewer").First(&role).Error)
+	var permission model.Permission
+	require.NoError(t, db.Where("code = ?", "project:read").First(&permission).Error)
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	body, _ := json.Marshal(map[string]interface{}{"permission_ids": []uint{permission.ID}})
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/project-roles/permissions", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", role.ID)}}
+	c.Set("user_id", uint(1))
+	c.Set("username", "admin")
+	c.Set("roles", []string{"admin"})
+	api.NewPermissionHandler(db).AssignProjectRolePermissions(c)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var log model.AuditLog
+	require.NoError(t, db.Where("action_type = ? AND resource_type = ? AND resource_id = ?", "assign_permissions", "project_role", role.ID).First(&log).Error)
+	assert.Equal(t, "project:read", log.Comment)
+}
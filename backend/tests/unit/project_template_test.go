@@ -0,0 +1,366 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+)
+
+func TestProjectTemplateHandler_CreateProjectTemplate(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	user := CreateTestUser(t, db, "templateuser", "模板用户")
+	handler := api.NewProjectTemplateHandler(db)
+
+	t.Run("创建模板成功", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		reqBody := map[string]interface{}{
+			"name": "标准研发模板",
+			"content": map[string]interface{}{
+				"boards": []map[string]interface{}{
+					{"name": "研发看板", "columns": []map[string]interface{}{{"name": "待办", "status": "wait"}}},
+				},
+				"tasks": []map[string]interface{}{
+					{"key": "design", "title": "设计"},
+					{"key": "dev", "title": "开发", "depends_on": []string{"design"}},
+				},
+			},
+		}
+		jsonData, _ := json.Marshal(reqBody)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/project-templates", bytes.NewBuffer(jsonData))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", user.ID)
+
+		handler.CreateProjectTemplate(c)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, float64(200), response["code"])
+
+		var template model.ProjectTemplate
+		require.NoError(t, db.Where("name = ?", "标准研发模板").First(&template).Error)
+		assert.Len(t, template.Content.Tasks, 2)
+		assert.Equal(t, []string{"design"}, template.Content.Tasks[1].DependsOn)
+	})
+
+	t.Run("创建模板失败-循环依赖", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		reqBody := map[string]interface{}{
+			"name": "循环模板",
+			"content": map[string]interface{}{
+				"tasks": []map[string]interface{}{
+					{"key": "a", "title": "A", "depends_on": []string{"b"}},
+					{"key": "b", "title": "B", "depends_on": []string{"a"}},
+				},
+			},
+		}
+		jsonData, _ := json.Marshal(reqBody)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/project-templates", bytes.NewBuffer(jsonData))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", user.ID)
+
+		handler.CreateProjectTemplate(c)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(400), response["code"])
+	})
+
+	t.Run("创建模板失败-依赖不存在", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		reqBody := map[string]interface{}{
+			"name": "缺失依赖模板",
+			"content": map[string]interface{}{
+				"tasks": []map[string]interface{}{
+					{"key": "a", "title": "A", "depends_on": []string{"missing"}},
+				},
+			},
+		}
+		jsonData, _ := json.Marshal(reqBody)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/project-templates", bytes.NewBuffer(jsonData))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", user.ID)
+
+		handler.CreateProjectTemplate(c)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(400), response["code"])
+	})
+}
+
+func TestProjectTemplateHandler_CreateProjectFromTemplate(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	creator := CreateTestUser(t, db, "fromtplcreator", "创建者")
+	member := CreateTestUser(t, db, "fromtplmember", "成员")
+	tag := CreateTestTag(t, db, "模板标签")
+	module := &model.Module{Name: "模板模块", Code: "TPL_MODULE", Status: 1}
+	require.NoError(t, db.Create(module).Error)
+
+	startOffset, dueOffset, releaseOffset := 0, 5, 30
+	template := &model.ProjectTemplate{
+		Name: "实例化模板",
+		Content: model.ProjectTemplateContent{
+			Members:   []model.TemplateMember{{UserID: member.ID, Role: "developer"}},
+			TagIDs:    []uint{tag.ID},
+			ModuleIDs: []uint{module.ID},
+			Boards: []model.TemplateBoard{{
+				Name: "默认看板",
+				Columns: []model.TemplateBoardColumn{
+					{Name: "待办", Status: "wait", Sort: 1},
+					{Name: "完成", Status: "done", Sort: 2},
+				},
+			}},
+			Tasks: []model.TemplateTask{
+				{Key: "plan", Title: "计划", StartOffset: &startOffset},
+				{Key: "build", Title: "构建", DueOffset: &dueOffset, DependsOn: []string{"plan"}},
+			},
+			Versions: []model.TemplateVersion{{VersionNumber: "v1.0", ReleaseOffset: &releaseOffset}},
+		},
+	}
+	require.NoError(t, db.Create(template).Error)
+
+	handler := api.NewProjectTemplateHandler(db)
+
+	t.Run("从模板创建项目", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		reqBody := map[string]interface{}{
+			"name":       "模板项目",
+			"code":       "TPL_PROJECT",
+			"start_date": "2024-03-01",
+		}
+		jsonData, _ := json.Marshal(reqBody)
+		c.Request = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/project-templates/%d/projects", template.ID), bytes.NewBuffer(jsonData))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{gin.Param{Key: "id", Value: fmt.Sprintf("%d", template.ID)}}
+		c.Set("user_id", creator.ID)
+		c.Set("roles", []string{"project_manager"})
+
+		handler.CreateProjectFromTemplate(c)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		require.Equal(t, float64(200), response["code"], response["message"])
+
+		var project model.Project
+		require.NoError(t, db.Preload("Tags").Preload("Modules").Where("code = ?", "TPL_PROJECT").First(&project).Error)
+		assert.Len(t, project.Tags, 1)
+		assert.Len(t, project.Modules, 1)
+
+		var members []model.ProjectMember
+		db.Where("project_id = ?", project.ID).Find(&members)
+		assert.Len(t, members, 2) // 模板成员 + 创建者
+
+		var boards []model.Board
+		db.Where("project_id = ?", project.ID).Preload("Columns").Find(&boards)
+		require.Len(t, boards, 1)
+		assert.Len(t, boards[0].Columns, 2)
+
+		var plan, build model.Task
+		require.NoError(t, db.Where("project_id = ? AND title = ?", project.ID, "计划").First(&plan).Error)
+		require.NoError(t, db.Where("project_id = ? AND title = ?", project.ID, "构建").Preload("Dependencies").First(&build).Error)
+		require.Len(t, build.Dependencies, 1)
+		assert.Equal(t, plan.ID, build.Dependencies[0].ID)
+		require.NotNil(t, build.DueDate)
+		assert.Equal(t, "2024-03-06", build.DueDate.Format("2006-01-02"))
+
+		var version model.Version
+		require.NoError(t, db.Where("project_id = ?", project.ID).First(&version).Error)
+		assert.Equal(t, "wait", version.Status)
+		require.NotNil(t, version.ReleaseDate)
+		assert.Equal(t, "2024-03-31", version.ReleaseDate.Format("2006-01-02"))
+	})
+
+	t.Run("模板不存在", func(t *testing.T) {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		jsonData, _ := json.Marshal(map[string]interface{}{"name": "不存在"})
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/project-templates/999/projects", bytes.NewBuffer(jsonData))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{gin.Param{Key: "id", Value: "999"}}
+		c.Set("user_id", creator.ID)
+
+		handler.CreateProjectFromTemplate(c)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Equal(t, float64(404), response["code"])
+	})
+}
+
+func TestProjectTemplateHandler_CloneProject(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	owner := CreateTestUser(t, db, "cloneowner", "克隆负责人")
+	outsider := CreateTestUser(t, db, "cloneoutsider", "外部用户")
+	startDate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	source := CreateTestProject(t, db, "克隆源项目")
+	source.StartDate = &startDate
+	db.Save(source)
+	AddUserToProject(t, db, owner.ID, source.ID, "owner")
+
+	openReq := &model.Requirement{Title: "未关闭需求", Status: "active", ProjectID: source.ID, CreatorID: owner.ID}
+	closedReq := &model.Requirement{Title: "已关闭需求", Status: "closed", ProjectID: source.ID, CreatorID: owner.ID}
+	db.Create(openReq)
+	db.Create(closedReq)
+
+	taskStart := startDate.AddDate(0, 0, 3)
+	first := &model.Task{Title: "第一步", Status: "done", Progress: 100, ProjectID: source.ID, CreatorID: owner.ID, RequirementID: &openReq.ID, StartDate: &taskStart}
+	db.Create(first)
+	second := &model.Task{Title: "第二步", Status: "doing", ProjectID: source.ID, CreatorID: owner.ID}
+	db.Create(second)
+	db.Create(&model.TaskDependency{TaskID: second.ID, DependencyID: first.ID, Type: "finish_to_start"})
+
+	handler := api.NewProjectTemplateHandler(db)
+
+	cloneRequest := func(userID uint, body map[string]interface{}) map[string]interface{} {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		jsonData, _ := json.Marshal(body)
+		c.Request = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/projects/%d/clone", source.ID), bytes.NewBuffer(jsonData))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{gin.Param{Key: "id", Value: fmt.Sprintf("%d", source.ID)}}
+		c.Set("user_id", userID)
+		c.Set("roles", []string{"project_manager"})
+		handler.CloneProject(c)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	t.Run("克隆项目包含未关闭需求", func(t *testing.T) {
+		response := cloneRequest(owner.ID, map[string]interface{}{
+			"name":                 "克隆项目",
+			"code":                 "CLONE_PROJECT",
+			"start_date":           "2024-06-01",
+			"include_requirements": true,
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+
+		var project model.Project
+		require.NoError(t, db.Where("code = ?", "CLONE_PROJECT").First(&project).Error)
+
+		var requirements []model.Requirement
+		db.Where("project_id = ?", project.ID).Find(&requirements)
+		require.Len(t, requirements, 1)
+		assert.Equal(t, "未关闭需求", requirements[0].Title)
+
+		var clonedFirst, clonedSecond model.Task
+		require.NoError(t, db.Where("project_id = ? AND title = ?", project.ID, "第一步").First(&clonedFirst).Error)
+		require.NoError(t, db.Where("project_id = ? AND title = ?", project.ID, "第二步").Preload("Dependencies").First(&clonedSecond).Error)
+
+		// 任务状态重置，关联和依赖重新映射到新对象
+		assert.Equal(t, "wait", clonedFirst.Status)
+		assert.Equal(t, 0, clonedFirst.Progress)
+		require.NotNil(t, clonedFirst.RequirementID)
+		assert.Equal(t, requirements[0].ID, *clonedFirst.RequirementID)
+		require.NotNil(t, clonedFirst.StartDate)
+		assert.Equal(t, "2024-06-04", clonedFirst.StartDate.Format("2006-01-02"))
+		require.Len(t, clonedSecond.Dependencies, 1)
+		assert.Equal(t, clonedFirst.ID, clonedSecond.Dependencies[0].ID)
+	})
+
+	t.Run("克隆项目不包含需求", func(t *testing.T) {
+		response := cloneRequest(owner.ID, map[string]interface{}{
+			"name": "克隆项目2",
+			"code": "CLONE_PROJECT_2",
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+
+		var project model.Project
+		require.NoError(t, db.Where("code = ?", "CLONE_PROJECT_2").First(&project).Error)
+
+		var count int64
+		db.Model(&model.Requirement{}).Where("project_id = ?", project.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+
+		var task model.Task
+		require.NoError(t, db.Where("project_id = ? AND title = ?", project.ID, "第一步").First(&task).Error)
+		assert.Nil(t, task.RequirementID)
+	})
+
+	t.Run("非项目成员不能克隆", func(t *testing.T) {
+		response := cloneRequest(outsider.ID, map[string]interface{}{"name": "无权克隆"})
+		assert.Equal(t, float64(403), response["code"])
+	})
+
+	t.Run("只克隆可见的受限任务并保留访问名单", func(t *testing.T) {
+		reviewer := CreateTestUser(t, db, "clonereviewer", "评审人")
+		AddUserToProject(t, db, reviewer.ID, source.ID, "member")
+		visible := &model.Task{Title: "受限任务", Status: "wait", ProjectID: source.ID, CreatorID: owner.ID, Confidential: true}
+		require.NoError(t, db.Create(visible).Error)
+		require.NoError(t, db.Create(&model.ItemAccess{ObjectType: "task", ObjectID: visible.ID, UserID: &reviewer.ID, CreatorID: owner.ID}).Error)
+		require.NoError(t, db.Create(&model.ItemAccess{ObjectType: "task", ObjectID: visible.ID, RoleCode: "viewer", CreatorID: owner.ID}).Error)
+		hidden := &model.Task{Title: "他人的受限任务", Status: "wait", ProjectID: source.ID, CreatorID: reviewer.ID, Confidential: true}
+		require.NoError(t, db.Create(hidden).Error)
+
+		response := cloneRequest(owner.ID, map[string]interface{}{"name": "克隆项目3", "code": "CLONE_PROJECT_3"})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		var project model.Project
+		require.NoError(t, db.Where("code = ?", "CLONE_PROJECT_3").First(&project).Error)
+
+		var count int64
+		db.Model(&model.Task{}).Where("project_id = ? AND title = ?", project.ID, "他人的受限任务").Count(&count)
+		assert.Equal(t, int64(0), count, "不能克隆当前用户不可见的受限任务")
+
+		var cloned model.Task
+		require.NoError(t, db.Where("project_id = ? AND title = ?", project.ID, "受限任务").First(&cloned).Error)
+		assert.True(t, cloned.Confidential)
+		var accesses []model.ItemAccess
+		db.Where("object_type = ? AND object_id = ?", "task", cloned.ID).Order("id ASC").Find(&accesses)
+		require.Len(t, accesses, 2)
+		require.NotNil(t, accesses[0].UserID)
+		assert.Equal(t, reviewer.ID, *accesses[0].UserID)
+		assert.Equal(t, "viewer", accesses[1].RoleCode)
+
+		// 保存为模板时不包含受限任务
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"name":"共享模板"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{gin.Param{Key: "id", Value: fmt.Sprintf("%d", source.ID)}}
+		c.Set("user_id", owner.ID)
+		c.Set("roles", []string{"project_manager"})
+		handler.SaveProjectAsTemplate(c)
+		var template model.ProjectTemplate
+		require.NoError(t, db.Where("name = ?", "共享模板").First(&template).Error)
+		for _, task := range template.Content.Tasks {
+			assert.NotContains(t, []string{"受限任务", "他人的受限任务"}, task.Title)
+			assert.False(t, task.Confidential)
+		}
+		assert.Len(t, template.Content.Tasks, 2)
+	})
+}
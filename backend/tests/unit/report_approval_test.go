@@ -0,0 +1,139 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+)
+
+func TestReportHandler_ApprovalChain(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	head := CreateTestUser(t, db, "rdhead", "研发总监")
+	lead := CreateTestUser(t, db, "backendlead", "后端组长")
+	deputy := CreateTestUser(t, db, "rddeputy", "研发副总监")
+	author := CreateTestUser(t, db, "backenddev", "后端开发")
+
+	center := &model.Department{Name: "研发中心", Code: "RD", LeaderID: &head.ID}
+	require.NoError(t, db.Create(center).Error)
+	team := &model.Department{Name: "后端组", Code: "RD-BE", ParentID: &center.ID, Level: 2, LeaderID: &lead.ID}
+	require.NoError(t, db.Create(team).Error)
+	require.NoError(t, db.Model(&model.User{}).Where("id IN ?", []uint{lead.ID, author.ID}).Update("department_id", team.ID).Error)
+
+	handler := api.NewReportHandler(db)
+	roles := []string{"developer"}
+	idParams := func(id uint) gin.Params { return gin.Params{{Key: "id", Value: fmt.Sprintf("%d", id)}} }
+
+	response := callProgramHandler(t, handler.CreateApprovalChain, head.ID, roles, http.MethodPost, nil, map[string]interface{}{
+		"name": "组长-部门负责人",
+		"steps": []map[string]interface{}{
+			{"stage": 1, "approver_type": "leader", "level": 0},
+			{"stage": 2, "approver_type": "leader", "level": 1},
+		},
+	})
+	require.Equal(t, float64(200), response["code"], response["message"])
+
+	response = callProgramHandler(t, handler.CreateDailyReport, author.ID, roles, http.MethodPost, nil, map[string]interface{}{
+		"date": "2026-10-16", "content": "完成接口开发", "status": "submitted",
+	})
+	require.Equal(t, float64(200), response["code"], response["message"])
+	reportID := uint(response["data"].(map[string]interface{})["id"].(float64))
+
+	pendingCount := func(userID uint) int {
+		response := callProgramHandler(t, handler.GetPendingApprovals, userID, roles, http.MethodGet, nil, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		return int(response["data"].(map[string]interface{})["total"].(float64))
+	}
+	approve := func(userID uint, status, comment string) map[string]interface{} {
+		return callProgramHandler(t, handler.ApproveDailyReport, userID, roles, http.MethodPost, idParams(reportID), map[string]interface{}{
+			"status": status, "comment": comment,
+		})
+	}
+	reportStatus := func() string {
+		var report model.DailyReport
+		require.NoError(t, db.First(&report, reportID).Error)
+		return report.Status
+	}
+
+	t.Run("按部门树生成逐级审批", func(t *testing.T) {
+		var records []model.DailyReportApproval
+		require.NoError(t, db.Where("daily_report_id = ?", reportID).Order("stage ASC").Find(&records).Error)
+		require.Len(t, records, 2)
+		assert.Equal(t, lead.ID, records[0].ApproverID)
+		assert.Equal(t, 1, records[0].Stage)
+		assert.Equal(t, head.ID, records[1].ApproverID)
+		assert.Equal(t, 2, records[1].Stage)
+	})
+
+	t.Run("前一阶段通过前后续审批人不可见", func(t *testing.T) {
+		assert.Equal(t, 0, pendingCount(head.ID))
+		response := callProgramHandler(t, handler.GetDailyReport, head.ID, roles, http.MethodGet, idParams(reportID), nil)
+		assert.Equal(t, float64(403), response["code"])
+		assert.Equal(t, float64(403), approve(head.ID, "approved", "")["code"])
+
+		assert.Equal(t, 1, pendingCount(lead.ID))
+		require.Equal(t, float64(200), approve(lead.ID, "approved", "")["code"])
+		assert.Equal(t, "submitted", reportStatus())
+		assert.Equal(t, 0, pendingCount(lead.ID))
+		assert.Equal(t, 1, pendingCount(head.ID))
+	})
+
+	t.Run("驳回后退回草稿并保留批注", func(t *testing.T) {
+		assert.Equal(t, float64(400), approve(head.ID, "rejected", "")["code"], "驳回时必须填写批注")
+		require.Equal(t, float64(200), approve(head.ID, "rejected", "请补充测试情况")["code"])
+		assert.Equal(t, "draft", reportStatus())
+		assert.Equal(t, 0, pendingCount(head.ID))
+
+		var record model.DailyReportApproval
+		require.NoError(t, db.Where("daily_report_id = ? AND approver_id = ?", reportID, head.ID).First(&record).Error)
+		assert.Equal(t, "rejected", record.Status)
+		assert.Equal(t, "请补充测试情况", record.Comment)
+	})
+
+	t.Run("重新提交后开始新一轮审批并支持委托代理", func(t *testing.T) {
+		response := callProgramHandler(t, handler.UpdateDailyReportStatus, author.ID, roles, http.MethodPatch, idParams(reportID), map[string]interface{}{"status": "submitted"})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		var count int64
+		db.Model(&model.DailyReportApproval{}).Where("daily_report_id = ? AND round = ?", reportID, 2).Count(&count)
+		assert.Equal(t, int64(2), count)
+		db.Model(&model.DailyReportApproval{}).Where("daily_report_id = ? AND round = ?", reportID, 1).Count(&count)
+		assert.Equal(t, int64(2), count, "上一轮审批记录保留")
+
+		today := time.Now().Format("2006-01-02")
+		response = callProgramHandler(t, handler.CreateApprovalDelegation, head.ID, roles, http.MethodPost, nil, map[string]interface{}{
+			"delegate_id": deputy.ID, "start_date": today, "end_date": today, "reason": "年假",
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+
+		require.Equal(t, float64(200), approve(lead.ID, "approved", "")["code"])
+		assert.Equal(t, 1, pendingCount(deputy.ID))
+		require.Equal(t, float64(200), approve(deputy.ID, "approved", "代审批")["code"])
+		assert.Equal(t, "approved", reportStatus())
+
+		var record model.DailyReportApproval
+		require.NoError(t, db.Where("daily_report_id = ? AND approver_id = ? AND round = ?", reportID, head.ID, 2).First(&record).Error)
+		assert.Equal(t, "approved", record.Status)
+		require.NotNil(t, record.ActedByID)
+		assert.Equal(t, deputy.ID, *record.ActedByID)
+	})
+
+	t.Run("组长本人的报告由上级部门负责人审批", func(t *testing.T) {
+		response := callProgramHandler(t, handler.CreateWeeklyReport, lead.ID, roles, http.MethodPost, nil, map[string]interface{}{
+			"week_start": "2026-10-12", "week_end": "2026-10-18", "summary": "本周总结", "status": "submitted",
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		var records []model.WeeklyReportApproval
+		require.NoError(t, db.Where("weekly_report_id = ?", uint(response["data"].(map[string]interface{})["id"].(float64))).Find(&records).Error)
+		require.Len(t, records, 1)
+		assert.Equal(t, head.ID, records[0].ApproverID)
+	})
+}
@@ -0,0 +1,124 @@
+package unit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestReportAutomation(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	dev := &model.Department{Name: "研发部", Code: "DEV"}
+	require.NoError(t, db.Create(dev).Error)
+	qa := &model.Department{Name: "测试组", Code: "DEV-QA", ParentID: &dev.ID, Level: 2}
+	require.NoError(t, db.Create(qa).Error)
+
+	submitted := CreateTestUser(t, db, "submitteduser", "已提交")
+	drafted := CreateTestUser(t, db, "drafteduser", "草稿")
+	missing := CreateTestUser(t, db, "missinguser", "未填写")
+	disabled := CreateTestUser(t, db, "disableduser", "已禁用")
+	require.NoError(t, db.Model(&model.User{}).Where("id IN ?", []uint{submitted.ID, drafted.ID}).Update("department_id", dev.ID).Error)
+	require.NoError(t, db.Model(missing).Update("department_id", qa.ID).Error)
+	require.NoError(t, db.Model(disabled).Update("status", 0).Error)
+
+	friday := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Create(&model.DailyReport{Date: friday, Content: "已提交", Status: "submitted", UserID: submitted.ID}).Error)
+	require.NoError(t, db.Create(&model.DailyReport{Date: friday, Content: "草稿", Status: "draft", UserID: drafted.ID}).Error)
+
+	t.Run("统计每天各部门未提交日报的用户", func(t *testing.T) {
+		days, err := utils.ReportCompliance(db, friday, friday.AddDate(0, 0, 2), nil, false)
+		require.NoError(t, err)
+		require.Len(t, days, 1, "默认跳过周末")
+		assert.Equal(t, "2026-10-16", days[0].Date)
+		assert.Equal(t, 3, days[0].Total)
+		assert.Equal(t, 1, days[0].Submitted)
+		require.Len(t, days[0].Departments, 2)
+		assert.Equal(t, "研发部", days[0].Departments[0].DepartmentName)
+		require.Len(t, days[0].Departments[0].Missing, 1)
+		assert.Equal(t, "draft", days[0].Departments[0].Missing[0].Status)
+		assert.Equal(t, "none", days[0].Departments[1].Missing[0].Status)
+
+		days, err = utils.ReportCompliance(db, friday, friday, &qa.ID, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1, days[0].Total, "按部门筛选")
+	})
+
+	t.Run("提醒未提交的用户并发送邮件", func(t *testing.T) {
+		previous := utils.SendMail
+		defer func() { utils.SendMail = previous }()
+		var recipients []string
+		utils.SendMail = func(to []string, subject, body string) error {
+			recipients = append(recipients, to...)
+			return nil
+		}
+
+		count, err := utils.RemindMissingDailyReports(db, friday, true)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.ElementsMatch(t, []string{drafted.Email, missing.Email}, recipients)
+
+		count, err = utils.RemindMissingDailyReports(db, friday, true)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count, "同一天只提醒一次")
+
+		handler := api.NewNotificationHandler(db)
+		response := callProgramHandler(t, handler.GetNotifications, missing.ID, []string{"developer"}, http.MethodGet, nil, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(1), data["unread"])
+		assert.Contains(t, data["list"].([]interface{})[0].(map[string]interface{})["content"], "2026-10-16")
+
+		response = callProgramHandler(t, handler.MarkAllNotificationsRead, missing.ID, []string{"developer"}, http.MethodPut, nil, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		response = callProgramHandler(t, handler.GetNotifications, missing.ID, []string{"developer"}, http.MethodGet, nil, nil)
+		assert.Equal(t, float64(0), response["data"].(map[string]interface{})["unread"])
+	})
+
+	t.Run("为没有报告的启用用户生成草稿", func(t *testing.T) {
+		count, err := utils.DraftDailyReports(db, friday)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		var report model.DailyReport
+		require.NoError(t, db.Where("user_id = ?", missing.ID).First(&report).Error)
+		assert.Equal(t, "draft", report.Status)
+		assert.NotEmpty(t, report.Content)
+
+		count, err = utils.DraftDailyReports(db, friday)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count, "已有日报时不重复生成")
+
+		count, err = utils.DraftWeeklyReports(db, friday)
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+		var weekly model.WeeklyReport
+		require.NoError(t, db.Where("user_id = ?", submitted.ID).First(&weekly).Error)
+		assert.Equal(t, "2026-10-12", weekly.WeekStart.Format("2006-01-02"))
+		assert.Equal(t, "2026-10-18", weekly.WeekEnd.Format("2006-01-02"))
+
+		var disabledReports int64
+		db.Model(&model.DailyReport{}).Where("user_id = ?", disabled.ID).Count(&disabledReports)
+		assert.Equal(t, int64(0), disabledReports, "禁用用户不生成草稿")
+	})
+
+	t.Run("保存和读取自动化配置", func(t *testing.T) {
+		require.NoError(t, utils.SaveReportAutomationConfig(db, utils.ReportAutomationConfig{
+			DraftEnabled: true, DraftTime: "17:30", ReminderTime: "19:00", ReminderEmail: true,
+		}))
+		cfg := utils.LoadReportAutomationConfig(db)
+		assert.True(t, cfg.DraftEnabled)
+		assert.False(t, cfg.ReminderEnabled)
+		assert.Equal(t, "17:30", cfg.DraftTime)
+		assert.Equal(t, "19:00", cfg.ReminderTime)
+		assert.True(t, cfg.ReminderEmail)
+	})
+}
@@ -0,0 +1,172 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestReportRollup(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	dev := &model.Department{Name: "研发部", Code: "DEV"}
+	require.NoError(t, db.Create(dev).Error)
+	backend := &model.Department{Name: "后端组", Code: "DEV-BE", ParentID: &dev.ID, Level: 2}
+	require.NoError(t, db.Create(backend).Error)
+
+	alice := CreateTestUser(t, db, "rollupalice", "Alice")
+	bob := CreateTestUser(t, db, "rollupbob", "Bob")
+	outsider := CreateTestUser(t, db, "rollupother", "Other")
+	require.NoError(t, db.Model(alice).Update("department_id", dev.ID).Error)
+	require.NoError(t, db.Model(bob).Update("department_id", backend.ID).Error)
+	require.NoError(t, db.Model(dev).Update("leader_id", alice.ID).Error)
+
+	crm := CreateTestProject(t, db, "CRM")
+	erp := CreateTestProject(t, db, "ERP")
+	AddUserToProject(t, db, alice.ID, crm.ID, "member")
+	AddUserToProject(t, db, bob.ID, crm.ID, "member")
+
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	wednesday := monday.AddDate(0, 0, 2)
+	at := wednesday.Add(10 * time.Hour)
+
+	// 工时：Alice 在 CRM 记录 6 小时（通过任务关联）、ERP 记录 2 小时；Bob 在 CRM 记录 3 小时；上周的工时不统计
+	doneTask := &model.Task{Title: "客户列表", ProjectID: crm.ID, CreatorID: alice.ID, AssigneeID: &alice.ID, Status: "done"}
+	require.NoError(t, db.Create(doneTask).Error)
+	require.NoError(t, db.Model(doneTask).UpdateColumns(map[string]interface{}{"created_at": at, "updated_at": at}).Error)
+	aliceCRM := &model.Resource{UserID: alice.ID, ProjectID: crm.ID}
+	aliceERP := &model.Resource{UserID: alice.ID, ProjectID: erp.ID}
+	bobCRM := &model.Resource{UserID: bob.ID, ProjectID: crm.ID}
+	require.NoError(t, db.Create(aliceCRM).Error)
+	require.NoError(t, db.Create(aliceERP).Error)
+	require.NoError(t, db.Create(bobCRM).Error)
+	require.NoError(t, db.Create(&model.ResourceAllocation{ResourceID: aliceCRM.ID, Date: wednesday, Hours: 6, TaskID: &doneTask.ID}).Error)
+	require.NoError(t, db.Create(&model.ResourceAllocation{ResourceID: aliceERP.ID, Date: wednesday, Hours: 2}).Error)
+	require.NoError(t, db.Create(&model.ResourceAllocation{ResourceID: bobCRM.ID, Date: monday, Hours: 3}).Error)
+	require.NoError(t, db.Create(&model.ResourceAllocation{ResourceID: bobCRM.ID, Date: monday.AddDate(0, 0, -1), Hours: 5}).Error)
+
+	// Bob 新建并解决了一个Bug，另有一个暂停的任务
+	bug := &model.Bug{Title: "导出乱码", ProjectID: crm.ID, CreatorID: bob.ID, Status: "resolved"}
+	require.NoError(t, db.Create(bug).Error)
+	require.NoError(t, db.Model(bug).UpdateColumn("created_at", at).Error)
+	require.NoError(t, db.Create(&model.Action{ObjectType: "bug", ObjectID: bug.ID, ProjectID: crm.ID, ActorID: bob.ID, Action: "resolved", Date: at}).Error)
+	pausedTask := &model.Task{Title: "对接支付", ProjectID: crm.ID, CreatorID: bob.ID, AssigneeID: &bob.ID, Status: "pause"}
+	require.NoError(t, db.Create(pausedTask).Error)
+	require.NoError(t, db.Model(pausedTask).UpdateColumn("created_at", monday.AddDate(0, 0, -7)).Error)
+
+	// 已提交的报告参与汇总，草稿和部门外用户的报告不参与
+	require.NoError(t, db.Create(&model.DailyReport{Date: wednesday, Content: "## 今日工作\n完成客户列表\n\n## 风险\n- 接口文档未确定", Status: "submitted", UserID: alice.ID}).Error)
+	require.NoError(t, db.Create(&model.DailyReport{Date: wednesday, Content: "草稿内容", Status: "draft", UserID: bob.ID}).Error)
+	require.NoError(t, db.Create(&model.WeeklyReport{WeekStart: monday, WeekEnd: monday.AddDate(0, 0, 6), Summary: "本周修复导出问题", Status: "approved", UserID: bob.ID}).Error)
+	require.NoError(t, db.Create(&model.DailyReport{Date: wednesday, Content: "其他部门", Status: "submitted", UserID: outsider.ID}).Error)
+
+	sunday := monday.AddDate(0, 0, 6)
+
+	t.Run("部门汇总包括下级部门成员", func(t *testing.T) {
+		rollup, err := utils.BuildDepartmentRollup(db, dev.ID, monday, sunday)
+		require.NoError(t, err)
+		require.Len(t, rollup.Members, 2)
+		assert.Equal(t, 11.0, rollup.TotalHours)
+		assert.Equal(t, 2, rollup.ReportCount)
+		require.Len(t, rollup.ProjectHours, 2)
+		assert.Equal(t, crm.Name, rollup.ProjectHours[0].ProjectName)
+		assert.Equal(t, 9.0, rollup.ProjectHours[0].Hours)
+
+		aliceRollup := rollup.Member(alice.ID)
+		require.NotNil(t, aliceRollup)
+		assert.Equal(t, 8.0, aliceRollup.Hours)
+		require.Len(t, aliceRollup.Completed, 1)
+		assert.Equal(t, "客户列表", aliceRollup.Completed[0].Title)
+		require.Len(t, aliceRollup.Blockers, 1)
+		assert.Contains(t, aliceRollup.Blockers[0].Description, "接口文档未确定")
+
+		bobRollup := rollup.Member(bob.ID)
+		require.NotNil(t, bobRollup)
+		assert.Equal(t, "后端组", bobRollup.DepartmentName)
+		require.Len(t, bobRollup.Completed, 1)
+		assert.Equal(t, "bug", bobRollup.Completed[0].Type)
+		require.Len(t, bobRollup.Created, 1, "上周新建的任务不统计")
+		require.Len(t, bobRollup.Blockers, 1)
+		assert.Contains(t, bobRollup.Blockers[0].Description, "对接支付")
+		require.Len(t, bobRollup.Reports, 1)
+		assert.Equal(t, "weekly", bobRollup.Reports[0].Type)
+
+		markdown := rollup.Markdown()
+		assert.Contains(t, markdown, "# 研发部 工作汇总（2026-10-12 ~ 2026-10-18）")
+		assert.Contains(t, markdown, "#### 风险", "报告中的标题降级嵌入")
+		assert.NotContains(t, markdown, "草稿内容")
+		assert.NotContains(t, markdown, "其他部门")
+	})
+
+	t.Run("项目汇总只统计该项目的工时", func(t *testing.T) {
+		rollup, err := utils.BuildProjectRollup(db, crm.ID, monday, sunday)
+		require.NoError(t, err)
+		assert.Equal(t, 9.0, rollup.TotalHours)
+		require.Len(t, rollup.ProjectHours, 1)
+		assert.Equal(t, 6.0, rollup.Member(alice.ID).Hours)
+	})
+
+	t.Run("按成员查看和导出", func(t *testing.T) {
+		handler := api.NewReportHandler(db)
+		call := func(query string) *httptest.ResponseRecorder {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/reports/rollup/departments/1?start_date=2026-10-12&end_date=2026-10-18"+query, nil)
+			c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", dev.ID)}}
+			c.Set("user_id", alice.ID)
+			c.Set("roles", []string{"developer"})
+			handler.GetDepartmentRollup(c)
+			return w
+		}
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(call(fmt.Sprintf("&member_id=%d", bob.ID)).Body.Bytes(), &response))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, "rollupbob", response["data"].(map[string]interface{})["username"])
+
+		require.NoError(t, json.Unmarshal(call(fmt.Sprintf("&member_id=%d", outsider.ID)).Body.Bytes(), &response))
+		assert.Equal(t, float64(404), response["code"])
+
+		w := call("&format=markdown&download=1")
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+		assert.Contains(t, w.Body.String(), "## 成员明细")
+
+		w = call("&format=csv")
+		assert.Contains(t, w.Body.String(), "Alice,rollupalice,研发部,8.00,1,1,1,1")
+	})
+
+	t.Run("只有管理员和本部门或上级部门负责人可以查看", func(t *testing.T) {
+		handler := api.NewReportHandler(db)
+		code := func(departmentID, userID uint, roles ...string) float64 {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/reports/rollup/departments/1?start_date=2026-10-12&end_date=2026-10-18", nil)
+			c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", departmentID)}}
+			c.Set("user_id", userID)
+			c.Set("roles", roles)
+			handler.GetDepartmentRollup(c)
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			return response["code"].(float64)
+		}
+
+		assert.Equal(t, float64(200), code(backend.ID, alice.ID, "developer"), "上级部门负责人可以查看下级部门")
+		assert.Equal(t, float64(403), code(dev.ID, bob.ID, "developer"), "下级部门成员不能查看上级部门")
+		assert.Equal(t, float64(403), code(backend.ID, outsider.ID, "department_manager"), "其他部门经理不能查看")
+		assert.Equal(t, float64(200), code(backend.ID, outsider.ID, "admin"))
+	})
+}
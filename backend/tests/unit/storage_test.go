@@ -0,0 +1,165 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/config"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+	"prjflow/pkg/storage/storagetest"
+)
+
+// uploadTestAttachment 以管理员身份上传附件到项目
+func uploadTestAttachment(t *testing.T, handler *api.AttachmentHandler, projectID uint, fileName string, content []byte) map[string]interface{} {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	require.NoError(t, writer.WriteField("project_id", fmt.Sprintf("%d", projectID)))
+	part, err := writer.CreateFormFile("file", fileName)
+	require.NoError(t, err)
+	part.Write(content)
+	require.NoError(t, writer.Close())
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/attachments/upload", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	c.Set("user_id", uint(1))
+	c.Set("roles", []string{"admin"})
+	c.Set("permissions", []string{"attachment:upload"})
+	handler.UploadFile(c)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+// downloadTestAttachment 以管理员身份下载附件，返回响应
+func downloadTestAttachment(handler *api.AttachmentHandler, id uint) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/attachments/%d/download", id), nil)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", id)}}
+	c.Set("user_id", uint(1))
+	c.Set("roles", []string{"admin"})
+	handler.DownloadFile(c)
+	return w
+}
+
+func TestAttachmentStorageAndQuota(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	oldDatabase, oldUpload, oldStorage := config.AppConfig.Database, config.AppConfig.Upload, config.AppConfig.Storage
+	defer func() {
+		config.AppConfig.Database, config.AppConfig.Upload, config.AppConfig.Storage = oldDatabase, oldUpload, oldStorage
+	}()
+	// 备份目录在数据库文件所在目录下，使用临时目录避免迁移其他测试生成的备份
+	dataDir := t.TempDir()
+	config.AppConfig.Database = config.DatabaseConfig{Type: "sqlite", DSN: filepath.Join(dataDir, "data.db")}
+	require.NoError(t, os.MkdirAll(utils.BackupDir(), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(utils.BackupDir(), "backup_20261018_020000.zip"), []byte("backup"), 0644))
+	uploadDir := filepath.Join(dataDir, "uploads")
+	config.AppConfig.Upload = config.UploadConfig{StoragePath: uploadDir, MaxFileSize: 10 * 1024 * 1024}
+	config.AppConfig.Storage = config.StorageConfig{Type: "local", PresignDownload: true, PresignExpire: 300}
+
+	project := CreateTestProject(t, db, "存储配额项目")
+	require.NoError(t, db.Model(project).Update("storage_quota", 10).Error)
+	handler := api.NewAttachmentHandler(db)
+	projectHandler := api.NewProjectHandler(db)
+	projectParams := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", project.ID)}}
+
+	var first model.Attachment
+	t.Run("上传到本地存储", func(t *testing.T) {
+		response := uploadTestAttachment(t, handler, project.ID, "说明.txt", []byte("12345678"))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		require.NoError(t, db.Last(&first).Error)
+
+		content, err := os.ReadFile(filepath.Join(uploadDir, filepath.FromSlash(first.FilePath)))
+		require.NoError(t, err)
+		assert.Equal(t, "12345678", string(content))
+
+		w := downloadTestAttachment(handler, first.ID)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "12345678", w.Body.String())
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "filename*=UTF-8''%E8%AF%B4%E6%98%8E.txt")
+	})
+
+	t.Run("超出项目存储配额", func(t *testing.T) {
+		response := uploadTestAttachment(t, handler, project.ID, "b.txt", []byte("12345678"))
+		assert.Equal(t, float64(400), response["code"])
+		assert.Contains(t, response["message"], "超出项目存储配额")
+
+		response = callProgramHandler(t, projectHandler.GetProjectStorage, 1, []string{"admin"}, http.MethodGet, projectParams, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, float64(8), data["used"])
+		assert.Equal(t, float64(1), data["files"])
+		assert.Equal(t, float64(10), data["quota"])
+
+		response = callProgramHandler(t, projectHandler.UpdateProjectStorageQuota, 1, []string{"admin"}, http.MethodPut, projectParams, map[string]interface{}{"quota": 0})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		// 内容不同（相同内容会共用一个文件）
+		response = uploadTestAttachment(t, handler, project.ID, "b.txt", []byte("87654321"))
+		assert.Equal(t, float64(200), response["code"], "取消配额后可以继续上传")
+	})
+
+	t.Run("迁移到对象存储", func(t *testing.T) {
+		fake, server := storagetest.NewFakeS3Server("prjflow")
+		defer server.Close()
+		config.AppConfig.Storage.S3 = config.S3Config{
+			Endpoint: server.URL, Region: "us-east-1", Bucket: "prjflow",
+			AccessKey: "minio", SecretKey: "minio123", PathStyle: true,
+		}
+
+		result, err := utils.MigrateStorage(db, "local", "s3", false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Attachments)
+		assert.Equal(t, 1, result.Backups)
+		assert.Equal(t, int64(22), result.Bytes)
+		data, ok := fake.Object("attachments/" + first.FilePath)
+		require.True(t, ok)
+		assert.Equal(t, "12345678", string(data))
+
+		result, err = utils.MigrateStorage(db, "local", "s3", false)
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.Attachments)
+		assert.Equal(t, 3, result.Skipped, "已迁移的文件跳过，可以重复执行")
+		_, ok = fake.Object("backups/backup_20261018_020000.zip")
+		assert.True(t, ok)
+
+		// 切换到对象存储后从存储读取附件
+		config.AppConfig.Storage.Type = "s3"
+		require.NoError(t, os.RemoveAll(uploadDir))
+		w := downloadTestAttachment(handler, first.ID)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "12345678", w.Body.String())
+
+		response := callProgramHandler(t, handler.GetDownloadURL, 1, []string{"admin"}, http.MethodGet, gin.Params{{Key: "id", Value: fmt.Sprintf("%d", first.ID)}}, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		url := response["data"].(map[string]interface{})
+		assert.Equal(t, true, url["presigned"])
+		assert.Contains(t, url["url"], server.URL+"/prjflow/attachments/"+first.FilePath+"?")
+		assert.Contains(t, url["url"], "X-Amz-Signature=")
+
+		backups, err := utils.ListStoredBackups()
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		assert.Equal(t, "backup_20261018_020000.zip", backups[0].Name)
+		assert.Equal(t, "2026-10-18 02:00:00", backups[0].CreatedAt.Format("2006-01-02 15:04:05"), "备份时间取自文件名")
+	})
+}
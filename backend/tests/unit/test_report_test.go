@@ -0,0 +1,215 @@
+package unit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/middleware"
+	"prjflow/internal/model"
+	"prjflow/internal/utils"
+)
+
+func TestParseTestReport(t *testing.T) {
+	t.Run("JUnit", func(t *testing.T) {
+		report := `<?xml version="1.0"?>
+<testsuites>
+  <testsuite name="auth">
+    <testcase classname="auth.Login" name="ok" time="0.5"/>
+    <testcase classname="auth.Login" name="bad" time="1.2"><failure message="expected 200">stack</failure></testcase>
+    <testsuite name="nested">
+      <testcase classname="auth.Logout" name="skipped"><skipped message="flaky"/></testcase>
+      <testcase classname="auth.Logout" name="boom"><error message="panic"/></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+		cases, format, err := utils.ParseTestReport("", []byte(report))
+		require.NoError(t, err)
+		assert.Equal(t, utils.TestReportJUnit, format)
+		require.Len(t, cases, 4)
+		assert.Equal(t, "auth.Login.ok", cases[0].Key)
+		assert.Equal(t, "pass", cases[0].Result)
+		assert.Equal(t, "fail", cases[1].Result)
+		assert.Equal(t, "expected 200\nstack", cases[1].Message)
+		assert.Equal(t, "nested", cases[2].Suite)
+		assert.Equal(t, "skip", cases[2].Result)
+		assert.Equal(t, "fail", cases[3].Result)
+	})
+
+	t.Run("xUnit", func(t *testing.T) {
+		report := `<assemblies><assembly name="Tests.dll"><collection name="Calc">
+  <test name="Calc.Add" method="Add" result="Pass" time="0.01"/>
+  <test name="Calc.Div" method="Div" result="Fail"><failure><message>divide by zero</message></failure></test>
+  <test name="Calc.Sub" method="Sub" result="Skip"><reason>todo</reason></test>
+</collection></assembly></assemblies>`
+		cases, format, err := utils.ParseTestReport("", []byte(report))
+		require.NoError(t, err)
+		assert.Equal(t, utils.TestReportXUnit, format)
+		require.Len(t, cases, 3)
+		assert.Equal(t, "Calc.Div", cases[1].Key)
+		assert.Equal(t, "Div", cases[1].Name)
+		assert.Equal(t, "fail", cases[1].Result)
+		assert.Equal(t, "divide by zero", cases[1].Message)
+		assert.Equal(t, "skip", cases[2].Result)
+		assert.Equal(t, "todo", cases[2].Message)
+	})
+
+	t.Run("TAP", func(t *testing.T) {
+		report := "TAP version 13\n1..4\nok 1 - adds numbers\nnot ok 2 - divides numbers\n  ---\n  message: 'division by zero'\n  ...\nok 3 - network # SKIP offline\nok 4 - adds numbers\n"
+		cases, format, err := utils.ParseTestReport("", []byte(report))
+		require.NoError(t, err)
+		assert.Equal(t, utils.TestReportTAP, format)
+		require.Len(t, cases, 3, "重复的测试合并为一条")
+		assert.Equal(t, "divides numbers", cases[1].Key)
+		assert.Equal(t, "fail", cases[1].Result)
+		assert.Equal(t, "division by zero", cases[1].Message)
+		assert.Equal(t, "skip", cases[2].Result)
+		assert.Equal(t, "offline", cases[2].Message)
+
+		_, _, err = utils.ParseTestReport(utils.TestReportTAP, []byte("ok 1 - a\nBail out! database down\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("不支持的格式", func(t *testing.T) {
+		_, _, err := utils.ParseTestReport("nunit", []byte("<x/>"))
+		assert.Error(t, err)
+	})
+}
+
+func TestTestReportIngestion(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "CI项目")
+	owner := CreateTestUser(t, db, "ciowner", "CI负责人")
+	AddUserToProject(t, db, owner.ID, project.ID, "owner")
+	projectParams := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", project.ID)}}
+
+	response := callProgramHandler(t, api.NewAPITokenHandler(db).CreateProjectAPIToken, owner.ID, []string{"developer"}, http.MethodPost, projectParams, map[string]interface{}{
+		"name": "GitLab CI",
+	})
+	require.Equal(t, float64(200), response["code"], response["message"])
+	token := response["data"].(map[string]interface{})["token"].(string)
+	require.True(t, strings.HasPrefix(token, "pft_"))
+
+	var stored model.APIToken
+	require.NoError(t, db.First(&stored).Error)
+	assert.NotEqual(t, token, stored.TokenHash, "令牌只保存哈希值")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/ci/test-results", middleware.APITokenAuth(db, model.APITokenScopeTestResults), api.NewTestRunHandler(db).UploadTestReport)
+	upload := func(token, query, report string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/ci/test-results"+query, strings.NewReader(report))
+		if token != "" {
+			req.Header.Set("X-API-Token", token)
+		}
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+	junit := func(loginFailed bool) string {
+		login := `<testcase classname="auth" name="login"/>`
+		if loginFailed {
+			login = `<testcase classname="auth" name="login"><failure message="401"/></testcase>`
+		}
+		return `<testsuite name="auth">` + login + `<testcase classname="auth" name="logout"/></testsuite>`
+	}
+	ids := func(data map[string]interface{}, key string) []interface{} {
+		return data[key].([]interface{})
+	}
+
+	t.Run("令牌校验", func(t *testing.T) {
+		assert.Equal(t, float64(401), upload("", "", junit(false))["code"])
+		assert.Equal(t, float64(401), upload("pft_invalid", "", junit(false))["code"])
+
+		plain, hash, err := utils.GenerateAPIToken()
+		require.NoError(t, err)
+		require.NoError(t, db.Create(&model.APIToken{Name: "只读", TokenHash: hash, Scopes: model.StringArray{"other"}, ProjectID: project.ID, CreatorID: owner.ID}).Error)
+		assert.Equal(t, float64(403), upload(plain, "", junit(false))["code"])
+	})
+
+	var bugID uint
+	t.Run("首次上传创建测试单、版本和Bug", func(t *testing.T) {
+		response := upload(token, "?version=v2.0", junit(true))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, true, data["version_created"])
+		assert.Equal(t, float64(2), data["created_cases"])
+		require.Len(t, ids(data, "bugs_opened"), 1)
+		bugID = uint(ids(data, "bugs_opened")[0].(float64))
+
+		var testCase model.TestCase
+		require.NoError(t, db.Where("project_id = ? AND external_key = ?", project.ID, "auth.login").First(&testCase).Error)
+		assert.Equal(t, "fail", testCase.Result)
+
+		var bug model.Bug
+		require.NoError(t, db.Preload("Versions").First(&bug, bugID).Error)
+		assert.Equal(t, "active", bug.Status)
+		assert.Equal(t, owner.ID, bug.CreatorID)
+		require.Len(t, bug.Versions, 1)
+		assert.Equal(t, "v2.0", bug.Versions[0].VersionNumber)
+
+		var run model.TestRun
+		require.NoError(t, db.First(&run, uint(data["test_run_id"].(float64))).Error)
+		assert.Equal(t, "ci", run.Source)
+	})
+
+	t.Run("同一失败再次上传不重复创建Bug", func(t *testing.T) {
+		response := upload(token, "?version=v2.0", junit(true))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, false, data["version_created"])
+		assert.Equal(t, float64(0), data["created_cases"])
+		assert.Empty(t, ids(data, "bugs_opened"))
+
+		var count int64
+		db.Model(&model.Bug{}).Where("project_id = ?", project.ID).Count(&count)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("测试通过后自动解决Bug", func(t *testing.T) {
+		response := upload(token, "?version=v2.1&format=junit", junit(false))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, []interface{}{float64(bugID)}, ids(data, "bugs_resolved"))
+
+		var bug model.Bug
+		require.NoError(t, db.First(&bug, bugID).Error)
+		assert.Equal(t, "resolved", bug.Status)
+
+		var actions int64
+		db.Model(&model.Action{}).Where("object_type = ? AND object_id = ? AND action = ?", "bug", bugID, "resolved").Count(&actions)
+		assert.Equal(t, int64(1), actions)
+	})
+
+	t.Run("再次失败时重新激活Bug", func(t *testing.T) {
+		response := upload(token, "?version=v2.2", junit(true))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, []interface{}{float64(bugID)}, ids(data, "bugs_reopened"))
+		assert.Empty(t, ids(data, "bugs_opened"))
+
+		var bug model.Bug
+		require.NoError(t, db.Preload("Versions").First(&bug, bugID).Error)
+		assert.Equal(t, "active", bug.Status)
+		assert.Len(t, bug.Versions, 2)
+	})
+
+	t.Run("吊销令牌后不能再上传", func(t *testing.T) {
+		params := append(projectParams, gin.Param{Key: "token_id", Value: fmt.Sprintf("%d", stored.ID)})
+		response := callProgramHandler(t, api.NewAPITokenHandler(db).DeleteProjectAPIToken, owner.ID, []string{"developer"}, http.MethodDelete, params, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, float64(401), upload(token, "", junit(false))["code"])
+	})
+}
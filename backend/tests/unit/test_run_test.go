@@ -0,0 +1,164 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"prjflow/internal/api"
+	"prjflow/internal/model"
+)
+
+func TestTestRunHandler_ExecutionByVersion(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "测试执行项目")
+	tester := CreateTestUser(t, db, "runtester", "测试人员")
+	AddUserToProject(t, db, tester.ID, project.ID, "member")
+	v1 := &model.Version{VersionNumber: "v1.0", ProjectID: project.ID}
+	v2 := &model.Version{VersionNumber: "v1.1", ProjectID: project.ID}
+	require.NoError(t, db.Create(v1).Error)
+	require.NoError(t, db.Create(v2).Error)
+
+	roles := []string{"developer"}
+	caseHandler := api.NewTestCaseHandler(db)
+	runHandler := api.NewTestRunHandler(db)
+	idParams := func(id uint) gin.Params { return gin.Params{{Key: "id", Value: fmt.Sprintf("%d", id)}} }
+	caseParams := func(runID, caseID uint) gin.Params {
+		return gin.Params{{Key: "id", Value: fmt.Sprintf("%d", runID)}, {Key: "case_id", Value: fmt.Sprintf("%d", caseID)}}
+	}
+
+	// 创建带结构化步骤的测试单
+	response := callProgramHandler(t, caseHandler.CreateTestCase, tester.ID, roles, http.MethodPost, nil, map[string]interface{}{
+		"name": "登录", "project_id": project.ID,
+		"steps": []map[string]string{
+			{"action": "打开登录页", "expected": "显示登录表单"},
+			{"action": "输入正确的用户名和密码", "expected": "进入工作台"},
+		},
+	})
+	require.Equal(t, float64(200), response["code"], response["message"])
+	testCaseID := uint(response["data"].(map[string]interface{})["id"].(float64))
+	steps := response["data"].(map[string]interface{})["steps"].([]interface{})
+	require.Len(t, steps, 2)
+	step1 := uint(steps[0].(map[string]interface{})["id"].(float64))
+	step2 := uint(steps[1].(map[string]interface{})["id"].(float64))
+
+	response = callProgramHandler(t, runHandler.CreateTestSuite, tester.ID, roles, http.MethodPost, nil, map[string]interface{}{
+		"name": "冒烟测试", "project_id": project.ID, "test_case_ids": []uint{testCaseID},
+	})
+	require.Equal(t, float64(200), response["code"], response["message"])
+	suiteID := uint(response["data"].(map[string]interface{})["id"].(float64))
+
+	createRun := func(name string, versionID uint) (uint, uint) {
+		response := callProgramHandler(t, runHandler.CreateTestRun, tester.ID, roles, http.MethodPost, nil, map[string]interface{}{
+			"name": name, "project_id": project.ID, "version_id": versionID, "test_suite_id": suiteID,
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		cases := data["cases"].([]interface{})
+		require.Len(t, cases, 1)
+		return uint(data["id"].(float64)), uint(cases[0].(map[string]interface{})["id"].(float64))
+	}
+	run1, runCase1 := createRun("v1.0 冒烟", v1.ID)
+
+	t.Run("按步骤记录执行结果并推断测试单结果", func(t *testing.T) {
+		response := callProgramHandler(t, runHandler.RecordTestResult, tester.ID, roles, http.MethodPost, caseParams(run1, runCase1), map[string]interface{}{
+			"steps": []map[string]interface{}{
+				{"step_id": step1, "result": "pass"},
+				{"step_id": step2, "result": "fail", "actual": "提示密码错误"},
+			},
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, "fail", data["result"])
+		assert.Equal(t, float64(tester.ID), data["tester_id"])
+
+		var run model.TestRun
+		require.NoError(t, db.First(&run, run1).Error)
+		assert.Equal(t, "done", run.Status)
+		assert.NotNil(t, run.FinishedAt)
+	})
+
+	t.Run("不属于测试单的步骤", func(t *testing.T) {
+		response := callProgramHandler(t, runHandler.RecordTestResult, tester.ID, roles, http.MethodPost, caseParams(run1, runCase1), map[string]interface{}{
+			"steps": []map[string]interface{}{{"step_id": 9999, "result": "pass"}},
+		})
+		assert.Equal(t, float64(400), response["code"])
+	})
+
+	t.Run("根据失败步骤创建Bug并关联测试单和版本", func(t *testing.T) {
+		response := callProgramHandler(t, runHandler.CreateBugFromStep, tester.ID, roles, http.MethodPost, caseParams(run1, runCase1), map[string]interface{}{
+			"step_id": step2, "severity": "high",
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		bugID := uint(response["data"].(map[string]interface{})["id"].(float64))
+
+		var bug model.Bug
+		require.NoError(t, db.Preload("Versions").First(&bug, bugID).Error)
+		assert.Equal(t, "high", bug.Severity)
+		assert.Contains(t, bug.Description, "提示密码错误")
+		require.Len(t, bug.Versions, 1)
+		assert.Equal(t, v1.ID, bug.Versions[0].ID)
+
+		var links int64
+		db.Model(&model.TestCaseBug{}).Where("test_case_id = ? AND bug_id = ?", testCaseID, bugID).Count(&links)
+		assert.Equal(t, int64(1), links)
+
+		var stepResult model.TestStepResult
+		require.NoError(t, db.Where("test_run_case_id = ? AND step_id = ?", runCase1, step2).First(&stepResult).Error)
+		require.NotNil(t, stepResult.BugID)
+		assert.Equal(t, bugID, *stepResult.BugID)
+
+		again := callProgramHandler(t, runHandler.CreateBugFromStep, tester.ID, roles, http.MethodPost, caseParams(run1, runCase1), map[string]interface{}{"step_id": step2})
+		assert.Equal(t, float64(400), again["code"], "同一步骤不能重复创建Bug")
+		passed := callProgramHandler(t, runHandler.CreateBugFromStep, tester.ID, roles, http.MethodPost, caseParams(run1, runCase1), map[string]interface{}{"step_id": step1})
+		assert.Equal(t, float64(400), passed["code"], "通过的步骤不能创建Bug")
+	})
+
+	t.Run("同一测试单在多个版本中执行", func(t *testing.T) {
+		run2, runCase2 := createRun("v1.1 冒烟", v2.ID)
+		response := callProgramHandler(t, runHandler.RecordTestResult, tester.ID, roles, http.MethodPost, caseParams(run2, runCase2), map[string]interface{}{
+			"result": "pass", "comment": "已修复",
+		})
+		require.Equal(t, float64(200), response["code"], response["message"])
+
+		detail := callProgramHandler(t, runHandler.GetTestRun, tester.ID, roles, http.MethodGet, idParams(run2), nil)
+		require.Equal(t, float64(200), detail["code"], detail["message"])
+		summary := detail["data"].(map[string]interface{})["summary"].(map[string]interface{})
+		assert.Equal(t, float64(100), summary["pass_rate"])
+
+		response = callProgramHandler(t, caseHandler.GetTestCaseStatistics, tester.ID, roles, http.MethodGet, nil, nil)
+		require.Equal(t, float64(200), response["code"])
+		stats := response["data"].(map[string]interface{})
+		byVersion := make(map[string]map[string]interface{})
+		for _, entry := range stats["version_stats"].([]interface{}) {
+			e := entry.(map[string]interface{})
+			byVersion[e["version_number"].(string)] = e
+		}
+		require.Contains(t, byVersion, "v1.0")
+		require.Contains(t, byVersion, "v1.1")
+		assert.Equal(t, float64(1), byVersion["v1.0"]["failed"])
+		assert.Equal(t, float64(0), byVersion["v1.0"]["pass_rate"])
+		assert.Equal(t, float64(1), byVersion["v1.1"]["passed"])
+		assert.Equal(t, float64(100), byVersion["v1.1"]["pass_rate"])
+
+		var testCase model.TestCase
+		require.NoError(t, db.First(&testCase, testCaseID).Error)
+		assert.Equal(t, "pass", testCase.Result, "测试单记录最近一次执行结果")
+	})
+
+	t.Run("版本必须属于项目", func(t *testing.T) {
+		other := CreateTestProject(t, db, "其他项目")
+		otherVersion := &model.Version{VersionNumber: "v9", ProjectID: other.ID}
+		require.NoError(t, db.Create(otherVersion).Error)
+		response := callProgramHandler(t, runHandler.CreateTestRun, tester.ID, roles, http.MethodPost, nil, map[string]interface{}{
+			"name": "错误版本", "project_id": project.ID, "version_id": otherVersion.ID, "test_case_ids": []uint{testCaseID},
+		})
+		assert.Equal(t, float64(400), response["code"])
+	})
+}
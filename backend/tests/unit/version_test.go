@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -507,3 +508,228 @@ func TestVersionHandler_GetVersionsWithFilters(t *testing.T) {
 	})
 }
 
+
+func TestVersionHandler_ReleaseGate(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "发布门禁项目")
+	user := CreateTestUser(t, db, "gateuser", "发布负责人")
+	AddUserToProject(t, db, user.ID, project.ID, "owner")
+	version := &model.Version{VersionNumber: "v3.0", ProjectID: project.ID, Status: "wait"}
+	require.NoError(t, db.Create(version).Error)
+
+	bug := &model.Bug{Title: "支付崩溃", ProjectID: project.ID, CreatorID: user.ID, Status: "active", Severity: "critical"}
+	minor := &model.Bug{Title: "文案错误", ProjectID: project.ID, CreatorID: user.ID, Status: "active", Severity: "low"}
+	requirement := &model.Requirement{Title: "退款", ProjectID: project.ID, CreatorID: user.ID, Status: "active"}
+	require.NoError(t, db.Create(bug).Error)
+	require.NoError(t, db.Create(minor).Error)
+	require.NoError(t, db.Create(requirement).Error)
+	require.NoError(t, db.Model(version).Association("Bugs").Append(bug, minor))
+	require.NoError(t, db.Model(version).Association("Requirements").Append(requirement))
+
+	// 版本中执行了两个测试单，一个通过一个失败
+	run := &model.TestRun{Name: "回归", Status: "done", ProjectID: project.ID, VersionID: version.ID, CreatorID: user.ID}
+	require.NoError(t, db.Create(run).Error)
+	for i, result := range []string{"pass", "fail"} {
+		testCase := &model.TestCase{Name: fmt.Sprintf("用例%d", i), ProjectID: project.ID, CreatorID: user.ID}
+		require.NoError(t, db.Create(testCase).Error)
+		require.NoError(t, db.Create(&model.TestRunCase{TestRunID: run.ID, TestCaseID: testCase.ID, Result: result}).Error)
+	}
+
+	handler := api.NewVersionHandler(db)
+	roles := []string{"developer"}
+	projectParams := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", project.ID)}}
+	versionParams := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", version.ID)}}
+
+	t.Run("未配置门禁时可以直接检查通过", func(t *testing.T) {
+		response := callProgramHandler(t, handler.CheckReleaseGate, user.ID, roles, http.MethodGet, versionParams, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, false, data["enabled"])
+		assert.Equal(t, true, data["passed"])
+	})
+
+	response := callProgramHandler(t, handler.UpdateReleaseGate, user.ID, roles, http.MethodPut, projectParams, map[string]interface{}{
+		"enabled": true, "blocking_bug_severities": []string{"critical", "high"}, "require_requirements_closed": true, "min_test_pass_rate": 80,
+	})
+	require.Equal(t, float64(200), response["code"], response["message"])
+
+	t.Run("门禁检查列出未通过的检查项", func(t *testing.T) {
+		response := callProgramHandler(t, handler.CheckReleaseGate, user.ID, roles, http.MethodGet, versionParams, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+		data := response["data"].(map[string]interface{})
+		assert.Equal(t, false, data["passed"])
+		checks := data["checks"].([]interface{})
+		require.Len(t, checks, 3)
+		bugCheck := checks[0].(map[string]interface{})
+		assert.Equal(t, "blocking_bugs", bugCheck["key"])
+		require.Len(t, bugCheck["items"], 1, "低严重程度的Bug不阻止发布")
+		assert.Equal(t, float64(bug.ID), bugCheck["items"].([]interface{})[0].(map[string]interface{})["id"])
+		assert.Equal(t, false, checks[1].(map[string]interface{})["passed"])
+		assert.Contains(t, checks[2].(map[string]interface{})["message"], "50.00%")
+	})
+
+	t.Run("未通过门禁时不能发布", func(t *testing.T) {
+		response := callProgramHandler(t, handler.ReleaseVersion, user.ID, roles, http.MethodPost, versionParams, nil)
+		assert.Equal(t, float64(400), response["code"])
+		response = callProgramHandler(t, handler.UpdateVersionStatus, user.ID, roles, http.MethodPatch, versionParams, map[string]interface{}{"status": "normal"})
+		assert.Equal(t, float64(400), response["code"])
+		response = callProgramHandler(t, handler.UpdateVersion, user.ID, roles, http.MethodPut, versionParams, map[string]interface{}{"status": "normal"})
+		assert.Equal(t, float64(400), response["code"], "编辑版本时改为已发布同样检查门禁")
+		response = callProgramHandler(t, handler.UpdateVersion, user.ID, roles, http.MethodPut, versionParams, map[string]interface{}{"status": "wait", "release_notes": "草稿"})
+		assert.Equal(t, float64(200), response["code"], "不发布时可以正常编辑")
+		response = callProgramHandler(t, handler.CreateVersion, user.ID, roles, http.MethodPost, nil, map[string]interface{}{
+			"version_number": "v9.9", "project_id": project.ID, "status": "normal",
+		})
+		assert.Equal(t, float64(400), response["code"], "不能创建已发布的版本绕过门禁")
+
+		var current model.Version
+		require.NoError(t, db.First(&current, version.ID).Error)
+		assert.Equal(t, "wait", current.Status)
+	})
+
+	t.Run("只有管理员可以填写原因跳过门禁", func(t *testing.T) {
+		response := callProgramHandler(t, handler.ReleaseVersion, user.ID, roles, http.MethodPost, versionParams, map[string]interface{}{"override": true, "reason": "紧急修复"})
+		assert.Equal(t, float64(403), response["code"])
+		response = callProgramHandler(t, handler.ReleaseVersion, user.ID, []string{"admin"}, http.MethodPost, versionParams, map[string]interface{}{"override": true})
+		assert.Equal(t, float64(400), response["code"], "跳过门禁必须填写原因")
+
+		response = callProgramHandler(t, handler.ReleaseVersion, user.ID, []string{"admin"}, http.MethodPost, versionParams, map[string]interface{}{"override": true, "reason": "紧急修复线上故障"})
+		require.Equal(t, float64(200), response["code"], response["message"])
+		assert.Equal(t, "normal", response["data"].(map[string]interface{})["status"])
+
+		var action model.Action
+		require.NoError(t, db.Where("object_type = ? AND object_id = ? AND action = ?", "version", version.ID, "released").First(&action).Error)
+		assert.Equal(t, "紧急修复线上故障", action.Comment)
+		assert.Equal(t, project.ID, action.ProjectID)
+		assert.Contains(t, action.Extra, `"override":true`)
+		assert.Contains(t, action.Extra, "blocking_bugs")
+	})
+
+	t.Run("满足门禁后正常发布", func(t *testing.T) {
+		other := &model.Version{VersionNumber: "v3.1", ProjectID: project.ID, Status: "wait"}
+		require.NoError(t, db.Create(other).Error)
+		require.NoError(t, db.Model(run).Update("version_id", other.ID).Error)
+
+		params := gin.Params{{Key: "id", Value: fmt.Sprintf("%d", other.ID)}}
+		response := callProgramHandler(t, handler.ReleaseVersion, user.ID, roles, http.MethodPost, params, nil)
+		assert.Equal(t, float64(400), response["code"], "测试通过率仍低于要求")
+
+		require.NoError(t, db.Model(&model.TestRunCase{}).Where("test_run_id = ? AND result = ?", run.ID, "fail").Update("result", "pass").Error)
+		response = callProgramHandler(t, handler.ReleaseVersion, user.ID, roles, http.MethodPost, params, nil)
+		require.Equal(t, float64(200), response["code"], response["message"])
+	})
+}
+
+func TestVersionHandler_ReleaseNotes(t *testing.T) {
+	db := SetupTestDB(t)
+	defer TeardownTestDB(t, db)
+
+	project := CreateTestProject(t, db, "发布说明项目")
+	user := CreateTestUser(t, db, "notesuser", "发布说明用户")
+	AddUserToProject(t, db, user.ID, project.ID, "member")
+	payment := &model.Module{Name: "支付", Code: "notes_payment"}
+	require.NoError(t, db.Create(payment).Error)
+
+	releaseDate := time.Date(2026, 9, 1, 0, 0, 0, 0, time.Local)
+	v1 := &model.Version{VersionNumber: "v1.0", ProjectID: project.ID, Status: "normal", ReleaseDate: &releaseDate}
+	v2 := &model.Version{VersionNumber: "v1.1", ProjectID: project.ID, Status: "wait"}
+	require.NoError(t, db.Create(v1).Error)
+	require.NoError(t, db.Create(v2).Error)
+
+	login := &model.Requirement{Title: "扫码登录", ProjectID: project.ID, CreatorID: user.ID, Status: "closed"}
+	refund := &model.Requirement{Title: "退款", ProjectID: project.ID, CreatorID: user.ID, Status: "closed"}
+	require.NoError(t, db.Create(login).Error)
+	require.NoError(t, db.Create(refund).Error)
+	require.NoError(t, db.Model(v1).Association("Requirements").Append(login))
+	require.NoError(t, db.Model(v2).Association("Requirements").Append(refund))
+
+	crash := &model.Bug{Title: "支付崩溃", ProjectID: project.ID, CreatorID: user.ID, Status: "resolved", Severity: "critical", ModuleID: &payment.ID, ResolvedVersionID: &v2.ID}
+	typo := &model.Bug{Title: "文案错误", ProjectID: project.ID, CreatorID: user.ID, Status: "closed", Severity: "low", ResolvedVersionID: &v2.ID}
+	slow := &model.Bug{Title: "列表加载慢", ProjectID: project.ID, CreatorID: user.ID, Status: "active", Severity: "medium"}
+	secret := &model.Bug{Title: "越权漏洞", ProjectID: project.ID, CreatorID: 9999, Status: "resolved", Severity: "high", ResolvedVersionID: &v2.ID, Confidential: true}
+	for _, bug := range []*model.Bug{crash, typo, slow, secret} {
+		require.NoError(t, db.Create(bug).Error)
+	}
+	require.NoError(t, db.Model(v1).Association("Bugs").Append(crash, slow))
+	require.NoError(t, db.Model(v2).Association("Bugs").Append(slow))
+
+	handler := api.NewVersionHandler(db)
+	request := func(handlerFunc gin.HandlerFunc, method string, id uint, query string) *httptest.ResponseRecorder {
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(method, "/api/versions?"+query, nil)
+		c.Params = gin.Params{{Key: "id", Value: fmt.Sprintf("%d", id)}}
+		c.Set("user_id", user.ID)
+		c.Set("roles", []string{"developer"})
+		handlerFunc(c)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, float64(200), response["code"], response["message"])
+		return response["data"].(map[string]interface{})
+	}
+
+	t.Run("按模块分组修复的Bug并列出已知问题", func(t *testing.T) {
+		data := decode(request(handler.GetReleaseNotes, http.MethodGet, v2.ID, ""))
+		assert.Len(t, data["requirements"], 1)
+		groups := data["fixed_bugs"].([]interface{})
+		require.Len(t, groups, 2, "受限Bug对无权限的用户不可见")
+		assert.Equal(t, "支付", groups[0].(map[string]interface{})["name"])
+		assert.Equal(t, "其他", groups[1].(map[string]interface{})["name"])
+		known := data["known_issues"].([]interface{})
+		require.Len(t, known, 1)
+		assert.Equal(t, float64(slow.ID), known[0].(map[string]interface{})["id"])
+	})
+
+	t.Run("按严重程度分组并导出Markdown和HTML", func(t *testing.T) {
+		w := request(handler.GetReleaseNotes, http.MethodGet, v2.ID, "template=severity&format=markdown")
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/markdown")
+		markdown := w.Body.String()
+		assert.Contains(t, markdown, "# 发布说明项目 v1.1")
+		assert.Contains(t, markdown, "### 严重")
+		assert.Contains(t, markdown, fmt.Sprintf("- #%d 支付崩溃（严重程度：严重）", crash.ID))
+		assert.Less(t, bytes.Index(w.Body.Bytes(), []byte("### 严重")), bytes.Index(w.Body.Bytes(), []byte("### 低")))
+
+		w = request(handler.GetReleaseNotes, http.MethodGet, v2.ID, "format=html&download=1")
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+		assert.Contains(t, w.Body.String(), "<h2>已知问题</h2>")
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(request(handler.GetReleaseNotes, http.MethodGet, v2.ID, "template=unknown").Body.Bytes(), &response))
+		assert.Equal(t, float64(400), response["code"])
+	})
+
+	t.Run("比较两个版本", func(t *testing.T) {
+		data := decode(request(handler.DiffReleaseNotes, http.MethodGet, v2.ID, fmt.Sprintf("base=%d", v1.ID)))
+		assert.Equal(t, float64(refund.ID), data["added_requirements"].([]interface{})[0].(map[string]interface{})["id"])
+		assert.Equal(t, float64(login.ID), data["removed_requirements"].([]interface{})[0].(map[string]interface{})["id"])
+		assert.Len(t, data["fixed_bugs"], 2)
+		assert.Empty(t, data["new_issues"], "两个版本都存在的已知问题不是新问题")
+	})
+
+	t.Run("生成并保存发布说明时排除受限Bug", func(t *testing.T) {
+		w := request(handler.GenerateReleaseNotes, http.MethodPost, v2.ID, "")
+		decode(w)
+		var saved model.Version
+		require.NoError(t, db.First(&saved, v2.ID).Error)
+		assert.Contains(t, saved.ReleaseNotes, "支付崩溃")
+		assert.NotContains(t, saved.ReleaseNotes, "越权漏洞")
+	})
+
+	t.Run("项目变更日志只包含已发布版本", func(t *testing.T) {
+		w := request(handler.GetProjectChangelog, http.MethodGet, project.ID, "")
+		data := decode(w)
+		releases := data["releases"].([]interface{})
+		require.Len(t, releases, 1)
+		assert.Equal(t, "v1.0", releases[0].(map[string]interface{})["version_number"])
+
+		w = request(handler.GetProjectChangelog, http.MethodGet, project.ID, "format=markdown")
+		assert.Contains(t, w.Body.String(), "# 发布说明项目 变更日志")
+		assert.Contains(t, w.Body.String(), "## 发布说明项目 v1.0（2026-09-01）")
+	})
+}
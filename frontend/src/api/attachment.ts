@@ -8,6 +8,8 @@ export interface Attachment {
   file_path: string
   file_size: number
   mime_type: string
+  content_hash?: string
+  version?: number
   creator_id: number
   creator?: {
     id: number
@@ -24,6 +26,26 @@ export interface AttachToEntityRequest {
   task_id?: number
   bug_id?: number
   version_id?: number
+  test_case_id?: number
+  daily_report_id?: number
+  weekly_report_id?: number
+}
+
+export interface AttachmentVersion {
+  id: number
+  attachment_id: number
+  version: number
+  file_name: string
+  file_size: number
+  mime_type: string
+  comment?: string
+  creator_id: number
+  creator?: {
+    id: number
+    username: string
+    nickname: string
+  }
+  created_at?: string
 }
 
 // 上传文件
@@ -80,12 +102,14 @@ export const getPreviewUrl = (id: number): string => {
 }
 
 // 获取预览文件的Blob URL（用于需要认证的预览）
-export const getPreviewBlobUrl = async (id: number): Promise<string> => {
+// thumbnail 为 true 时获取图片缩略图（非图片返回原文件）
+export const getPreviewBlobUrl = async (id: number, thumbnail = false): Promise<string> => {
   const authStore = useAuthStore()
   const token = authStore.token
 
   const response = await axios.get(`/api/attachments/${id}/preview`, {
     responseType: 'blob',
+    params: thumbnail ? { thumbnail: true } : undefined,
     headers: {
       'Authorization': token ? `Bearer ${token}` : ''
     }
@@ -94,8 +118,31 @@ export const getPreviewBlobUrl = async (id: number): Promise<string> => {
   return window.URL.createObjectURL(response.data)
 }
 
+export interface AttachmentDownloadUrl {
+  url: string
+  presigned: boolean
+  expires_in?: number
+}
+
+// 获取附件下载地址（对象存储返回带签名的临时地址）
+export const getDownloadUrl = async (id: number, inline = false): Promise<AttachmentDownloadUrl> => {
+  return request.get(`/attachments/${id}/download-url`, { params: inline ? { inline: true } : undefined })
+}
+
 // 下载文件
 export const downloadFile = async (id: number, fileName: string): Promise<void> => {
+  // 对象存储的临时地址由浏览器直接下载，不经过服务器
+  const downloadUrl = await getDownloadUrl(id)
+  if (downloadUrl.presigned) {
+    const link = document.createElement('a')
+    link.href = downloadUrl.url
+    link.setAttribute('download', fileName)
+    document.body.appendChild(link)
+    link.click()
+    link.remove()
+    return
+  }
+
   const authStore = useAuthStore()
   const token = authStore.token
 
@@ -129,6 +176,9 @@ export const getAttachments = async (params?: {
   task_id?: number
   bug_id?: number
   version_id?: number
+  test_case_id?: number
+  daily_report_id?: number
+  weekly_report_id?: number
 }): Promise<Attachment[]> => {
   return request.get('/attachments', { params })
 }
@@ -138,3 +188,42 @@ export const attachToEntity = async (id: number, data: AttachToEntityRequest): P
   return request.post(`/attachments/${id}/attach`, data)
 }
 
+
+// 上传附件的新版本
+export const uploadAttachmentVersion = async (id: number, file: File, comment?: string): Promise<Attachment> => {
+  const formData = new FormData()
+  formData.append('file', file)
+  if (comment) {
+    formData.append('comment', comment)
+  }
+  return request.post(`/attachments/${id}/versions`, formData, {
+    headers: { 'Content-Type': 'multipart/form-data' }
+  })
+}
+
+// 获取附件的版本历史
+export const getAttachmentVersions = async (id: number): Promise<{ attachment_id: number; current_version: number; list: AttachmentVersion[] }> => {
+  return request.get(`/attachments/${id}/versions`)
+}
+
+// 下载附件的指定版本
+export const downloadAttachmentVersion = async (id: number, version: number, fileName: string): Promise<void> => {
+  const authStore = useAuthStore()
+  const token = authStore.token
+
+  const response = await axios.get(`/api/attachments/${id}/versions/${version}/download`, {
+    responseType: 'blob',
+    headers: {
+      'Authorization': token ? `Bearer ${token}` : ''
+    }
+  })
+
+  const url = window.URL.createObjectURL(new Blob([response.data]))
+  const link = document.createElement('a')
+  link.href = url
+  link.setAttribute('download', fileName)
+  document.body.appendChild(link)
+  link.click()
+  link.remove()
+  window.URL.revokeObjectURL(url)
+}